@@ -6,7 +6,7 @@ data "azurerm_client_config" "current" {}
 
 # First, create a resource group
 module "resource_group" {
-  source = "../../resource-group"
+  source = "../../../resource-group"
 
   name     = "rg-ca-example"
   location = "eastus2"
@@ -37,7 +37,7 @@ resource "azurerm_application_insights" "example" {
 
 # Create a Container Registry
 module "container_registry" {
-  source = "../../container-registry"
+  source = "../../../container-registry"
 
   name                = "acrcacomplete"
   resource_group_name = module.resource_group.name
@@ -53,7 +53,7 @@ module "container_registry" {
 
 # Create a Key Vault
 module "key_vault" {
-  source = "../../key-vault"
+  source = "../../../key-vault"
 
   name                = "kv-ca-complete"
   resource_group_name = module.resource_group.name
@@ -22,6 +22,159 @@
 #   }
 #------------------------------------------------------------------------------
 
+#------------------------------------------------------------------------------
+# Registry Authentication
+#------------------------------------------------------------------------------
+# Identity modes pull with a managed identity; credential modes store the
+# registry password as an app secret. Exactly one applies per registry.
+#------------------------------------------------------------------------------
+locals {
+  registry_uses_password        = contains(["admin_credentials", "service_principal"], var.registry_auth_mode)
+  registry_password_secret_name = "registry-password"
+
+  registry_identity = (
+    var.registry_auth_mode == "system_identity" ? "System" :
+    var.registry_auth_mode == "user_identity" ? var.registry_identity_id :
+    null
+  )
+
+  # User-assigned identities on the app: the registry pull identity and the
+  # identity resolving Key Vault secret references
+  user_identity_ids = distinct(compact([
+    var.registry_auth_mode == "user_identity" ? var.registry_identity_id : null,
+    length(var.key_vault_secrets) > 0 ? var.key_vault_secret_identity_id : null,
+  ]))
+}
+
+#------------------------------------------------------------------------------
+# Scale Rule Authentication
+#------------------------------------------------------------------------------
+# Custom scale rules authenticate with app secrets by name. A name that is not
+# a secret of the app only fails once Azure provisions the revision, so the
+# names are checked at plan time instead.
+#------------------------------------------------------------------------------
+locals {
+  app_secret_names = concat(
+    keys(var.secrets),
+    keys(var.key_vault_secrets),
+    local.registry_uses_password ? [local.registry_password_secret_name] : []
+  )
+
+  # "<rule>/<secret>" for each authentication naming a secret the app lacks
+  missing_scale_rule_secrets = flatten([
+    for rule in var.custom_scale_rules : [
+      for auth in rule.authentication : "${rule.name}/${auth.secret_name}"
+      if !contains(local.app_secret_names, auth.secret_name)
+    ]
+  ])
+}
+
+#------------------------------------------------------------------------------
+# Replica Resources
+#------------------------------------------------------------------------------
+# On the Consumption plan the containers of a replica (main container plus
+# sidecars) must add up to one of these CPU and memory combinations; a
+# container's own CPU and memory need not pair. Azure only rejects other
+# totals when the revision is provisioned, so they are checked at plan time
+# instead.
+# tests/helpers/containerapps.go holds the same table for the tests.
+#------------------------------------------------------------------------------
+locals {
+  # vCPU (formatted with two decimals) => memory in Gi
+  consumption_resource_combinations = {
+    "0.25" = 0.5
+    "0.50" = 1
+    "0.75" = 1.5
+    "1.00" = 2
+    "1.25" = 2.5
+    "1.50" = 3
+    "1.75" = 3.5
+    "2.00" = 4
+  }
+
+  container_resources = concat(
+    [{ name = var.container_name, cpu = var.container_cpu, memory_gi = tonumber(trimsuffix(var.container_memory, "Gi")) }],
+    [for sidecar in var.sidecar_containers : { name = sidecar.name, cpu = sidecar.cpu, memory_gi = tonumber(trimsuffix(sidecar.memory, "Gi")) }]
+  )
+
+  # Name of the serverless profile in workload profiles environments
+  consumption_workload_profile = "Consumption"
+
+  total_cpu       = sum([for container in local.container_resources : container.cpu])
+  total_memory_gi = sum([for container in local.container_resources : container.memory_gi])
+}
+
+#------------------------------------------------------------------------------
+# Name Uniqueness
+#------------------------------------------------------------------------------
+# Container App names are unique per resource group across all environments,
+# and the module gives each app an environment of its own. A second instance
+# reusing a name in the same resource group otherwise only fails at apply,
+# when azurerm finds the resource already exists and asks for it to be
+# imported, so the apps already deployed in the resource group are read at
+# plan time instead.
+#
+# This instance's own app is told apart by its instance tag: the names it was
+# first created with, kept in state, so renaming the app or its environment
+# does not count the resources being replaced as another instance's. The
+# marker is read from the input of terraform_data.instance, which is the
+# current names while the instance is planned for creation and the state's
+# value afterwards, so it is known at plan time either way.
+#
+# Apps are listed per subscription and filtered by resource group, which
+# works before the resource group exists.
+#
+# NOTE: two instances created in the same apply are not caught: neither
+# exists yet when the plan is made.
+#------------------------------------------------------------------------------
+locals {
+  # Names identifying a new instance, lower case like Azure compares them
+  instance_marker = lower("${var.resource_group_name}/${var.environment_name}/${var.name}")
+}
+
+resource "terraform_data" "instance" {
+  input = local.instance_marker
+
+  lifecycle {
+    # The first names identify the instance for good, across renames
+    ignore_changes = [input]
+  }
+}
+
+data "azapi_resource_list" "container_apps" {
+  type                   = "Microsoft.App/containerApps@2023-05-01"
+  parent_id              = "/subscriptions/${data.azurerm_client_config.current.subscription_id}"
+  response_export_values = ["value"]
+}
+
+locals {
+  # Tag carrying the instance marker on the app
+  instance_tag = "ModuleInstance"
+
+  # Apps in the resource group other than this instance's: name and
+  # environment name, lower case. IDs are
+  # /subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.App/<type>/<name>
+  resource_group_apps = [
+    for app in jsondecode(data.azapi_resource_list.container_apps.output).value : {
+      name        = lower(app.name)
+      environment = lower(split("/", app.properties.managedEnvironmentId)[8])
+    }
+    if lower(split("/", app.id)[4]) == lower(var.resource_group_name) && try(app.tags[local.instance_tag], "") != terraform_data.instance.input
+  ]
+
+  # Environments already running an app named var.name
+  app_name_holders = distinct([
+    for app in local.resource_group_apps : app.environment
+    if app.name == lower(var.name) && app.environment != lower(var.environment_name)
+  ])
+
+  # Apps already running in var.environment_name
+  environment_name_holders = [
+    for app in local.resource_group_apps : app.name
+    if app.environment == lower(var.environment_name) && app.name != lower(var.name)
+  ]
+}
+
 #------------------------------------------------------------------------------
 # Container App Environment
 #------------------------------------------------------------------------------
@@ -55,8 +208,56 @@ resource "azurerm_container_app_environment" "this" {
   internal_load_balancer_enabled = var.infrastructure_subnet_id != null ? var.internal_load_balancer_enabled : null
   zone_redundancy_enabled        = var.infrastructure_subnet_id != null ? var.zone_redundancy_enabled : null
 
+  # Workload profiles plan (optional)
+  # Consumption-only environments ignore the subnet's route table; the
+  # serverless Consumption profile keeps the same billing while honouring
+  # user-defined routes, e.g. to an egress firewall
+  dynamic "workload_profile" {
+    for_each = var.workload_profiles_enabled ? [local.consumption_workload_profile] : []
+    content {
+      name                  = workload_profile.value
+      workload_profile_type = "Consumption"
+    }
+  }
+
   # Resource tags for organization and cost management
   tags = var.tags
+
+  lifecycle {
+    precondition {
+      condition     = length(local.environment_name_holders) == 0
+      error_message = "Container App environment ${var.environment_name} in resource group ${var.resource_group_name} already runs ${join(", ", local.environment_name_holders)}, so it belongs to another instance of this module. Choose another environment_name."
+    }
+  }
+}
+
+#------------------------------------------------------------------------------
+# Container App Environment Storage: NFS Azure Files (Optional)
+#------------------------------------------------------------------------------
+# Registers each NFS share with the environment so apps can mount it.
+# NFS shares (Premium FileStorage) authenticate by network, not by key: the
+# environment must be VNet-integrated and the share reachable from its subnet
+# through a private endpoint or service endpoint.
+#
+# NOTE: azurerm_container_app_environment_storage only supports SMB shares,
+# so NFS storage is created with the azapi provider.
+#------------------------------------------------------------------------------
+resource "azapi_resource" "nfs_storage" {
+  for_each = { for volume in var.nfs_volumes : volume.name => volume }
+
+  type      = "Microsoft.App/managedEnvironments/storages@2024-02-02-preview"
+  name      = each.key
+  parent_id = azurerm_container_app_environment.this.id
+
+  body = jsonencode({
+    properties = {
+      nfsAzureFile = {
+        server     = each.value.server
+        shareName  = each.value.share_name
+        accessMode = each.value.access_mode
+      }
+    }
+  })
 }
 
 #------------------------------------------------------------------------------
@@ -92,13 +293,19 @@ resource "azurerm_container_app" "this" {
   # - Multiple: Multiple revisions for blue/green deployments
   revision_mode = var.revision_mode
 
+  # Runs on the Consumption profile when the environment uses workload profiles
+  workload_profile_name = var.workload_profiles_enabled ? local.consumption_workload_profile : null
+
   # System-assigned managed identity
   # This identity is used to authenticate with Azure services:
   # - Azure Container Registry (pull images)
   # - Azure Key Vault (read secrets)
   # - Azure Storage, SQL, etc.
+  # User-assigned identities are added when they pull images or resolve
+  # Key Vault secret references
   identity {
-    type = "SystemAssigned"
+    type         = length(local.user_identity_ids) > 0 ? "SystemAssigned, UserAssigned" : "SystemAssigned"
+    identity_ids = length(local.user_identity_ids) > 0 ? local.user_identity_ids : null
   }
 
   # Container template configuration
@@ -123,11 +330,11 @@ resource "azurerm_container_app" "this" {
       image = var.container_image
 
       # CPU allocation (0.25 - 2.0 vCPU)
-      # Must be paired with appropriate memory
+      # Must be paired with the matching memory
       cpu = var.container_cpu
 
       # Memory allocation (0.5Gi - 4Gi)
-      # Rule: 0.5Gi per 0.25 vCPU, 1Gi per 0.5 vCPU, etc.
+      # Rule: 0.5Gi per 0.25 vCPU (see local.consumption_resource_combinations)
       memory = var.container_memory
 
       # Environment variables (non-sensitive)
@@ -191,6 +398,48 @@ resource "azurerm_container_app" "this" {
           success_count_threshold = var.readiness_probe_success_threshold
         }
       }
+
+      # NFS volume mounts (optional)
+      dynamic "volume_mounts" {
+        for_each = var.nfs_volumes
+        content {
+          name = volume_mounts.value.name
+          path = volume_mounts.value.mount_path
+        }
+      }
+    }
+
+    # Sidecar containers (optional)
+    # Share the replica's network and lifecycle with the main container
+    dynamic "container" {
+      for_each = var.sidecar_containers
+      content {
+        name    = container.value.name
+        image   = container.value.image
+        cpu     = container.value.cpu
+        memory  = container.value.memory
+        command = container.value.command
+        args    = container.value.args
+
+        dynamic "env" {
+          for_each = container.value.environment_variables
+          content {
+            name  = env.key
+            value = env.value
+          }
+        }
+      }
+    }
+
+    # NFS Azure Files volumes (optional)
+    # Backed by the environment storage of the same name
+    dynamic "volume" {
+      for_each = azapi_resource.nfs_storage
+      content {
+        name         = volume.key
+        storage_type = "NfsAzureFile"
+        storage_name = volume.value.name
+      }
     }
 
     # HTTP-based autoscaling (KEDA)
@@ -211,6 +460,16 @@ resource "azurerm_container_app" "this" {
         name             = custom_scale_rule.value.name
         custom_rule_type = custom_scale_rule.value.type
         metadata         = custom_scale_rule.value.metadata
+
+        # Scaler credentials, such as a queue connection string, read from
+        # app secrets
+        dynamic "authentication" {
+          for_each = custom_scale_rule.value.authentication
+          content {
+            secret_name       = authentication.value.secret_name
+            trigger_parameter = authentication.value.trigger_parameter
+          }
+        }
       }
     }
   }
@@ -240,7 +499,7 @@ resource "azurerm_container_app" "this" {
 
       # Insecure connections
       # false: Redirect HTTP to HTTPS (recommended)
-      # true: Allow HTTP (not recommended for production)
+      # true: Allow HTTP (refused when tagged Environment = prod)
       allow_insecure_connections = var.allow_insecure_connections
 
       # Traffic weight configuration
@@ -279,15 +538,18 @@ resource "azurerm_container_app" "this" {
   }
 
   # Registry configuration for private container registries
-  # Authentication is handled via Managed Identity (RBAC)
+  # Authentication is handled via Managed Identity (RBAC) by default
   # The AcrPull role is assigned separately below
+  # Credential modes reference the registry-password secret instead
   # Note: For initial deployment, we temporarily use a public image to avoid
   # circular dependency. CI/CD will update with ACR image after managed identity exists.
   dynamic "registry" {
-    for_each = var.registry_server != null ? [1] : []
+    for_each = var.registry_server != "" ? [1] : []
     content {
-      server   = var.registry_server
-      identity = "system"
+      server               = var.registry_server
+      identity             = local.registry_identity
+      username             = local.registry_uses_password ? var.registry_username : null
+      password_secret_name = local.registry_uses_password ? local.registry_password_secret_name : null
     }
   }
 
@@ -302,8 +564,29 @@ resource "azurerm_container_app" "this" {
     }
   }
 
-  # Resource tags for organization and cost management
-  tags = var.tags
+  # Key Vault references: Azure resolves the latest version of each secret
+  # with the given identity, so the value never passes through terraform
+  dynamic "secret" {
+    for_each = var.key_vault_secrets
+    content {
+      name                = secret.key
+      key_vault_secret_id = secret.value
+      identity            = var.key_vault_secret_identity_id
+    }
+  }
+
+  # Registry password, only present for credential auth modes
+  dynamic "secret" {
+    for_each = local.registry_uses_password ? [1] : []
+    content {
+      name  = local.registry_password_secret_name
+      value = var.registry_password
+    }
+  }
+
+  # Resource tags for organization and cost management, plus the instance
+  # marker the name uniqueness check recognizes this instance's app by
+  tags = merge(var.tags, { (local.instance_tag) = terraform_data.instance.input })
 
   # Lifecycle management
   lifecycle {
@@ -332,10 +615,60 @@ resource "azurerm_container_app" "this" {
       error_message = "Container CPU must be between 0.25 and 2.0 vCPU."
     }
 
+    precondition {
+      condition     = lookup(local.consumption_resource_combinations, format("%.2f", local.total_cpu), null) == local.total_memory_gi
+      error_message = "All containers together request ${local.total_cpu} vCPU and ${local.total_memory_gi}Gi, which is not a Consumption combination. Totals must pair 0.5Gi per 0.25 vCPU, from 0.25 vCPU / 0.5Gi up to 2 vCPU / 4Gi."
+    }
+
+    precondition {
+      condition     = !contains([for sidecar in var.sidecar_containers : sidecar.name], var.container_name)
+      error_message = "Sidecar container names must differ from the main container name (${var.container_name})."
+    }
+
+    precondition {
+      condition     = !(var.allow_insecure_connections && contains(["prod", "production"], lower(lookup(var.tags, "Environment", ""))))
+      error_message = "allow_insecure_connections must be false in production (Environment tag \"${lookup(var.tags, "Environment", "")}\"): plain HTTP ingress is not allowed there."
+    }
+
+    precondition {
+      condition     = length(var.nfs_volumes) == 0 || var.infrastructure_subnet_id != null
+      error_message = "NFS volumes (${join(", ", [for volume in var.nfs_volumes : volume.name])}) require a VNet-integrated environment: set infrastructure_subnet_id. NFS Azure Files shares are only reachable from a virtual network."
+    }
+
+    precondition {
+      condition     = !var.ingress_sticky_sessions_enabled || (var.ingress_enabled && var.ingress_transport != "tcp" && var.revision_mode == "Single")
+      error_message = "Sticky sessions need HTTP ingress in Single revision mode (ingress_enabled = ${var.ingress_enabled}, ingress_transport = ${var.ingress_transport}, revision_mode = ${var.revision_mode})."
+    }
+
     precondition {
       condition     = var.ingress_target_port > 0 && var.ingress_target_port <= 65535
       error_message = "Ingress target port must be a valid port number (1-65535)."
     }
+
+    precondition {
+      condition     = length(var.key_vault_secrets) == 0 || var.key_vault_secret_identity_id != null
+      error_message = "Key Vault secret references (${join(", ", keys(var.key_vault_secrets))}) need key_vault_secret_identity_id: a user-assigned identity holding Key Vault Secrets User before the app is created. The system-assigned identity does not exist until then."
+    }
+
+    precondition {
+      condition     = var.registry_auth_mode != "user_identity" || var.registry_identity_id != null
+      error_message = "registry_auth_mode user_identity pulls images with registry_identity_id: set it to a user-assigned identity holding AcrPull on the registry."
+    }
+
+    precondition {
+      condition     = !local.registry_uses_password || (var.registry_username != null && var.registry_password != null)
+      error_message = "registry_auth_mode ${var.registry_auth_mode} pulls images with a username and password: set registry_username and registry_password."
+    }
+
+    precondition {
+      condition     = length(local.missing_scale_rule_secrets) == 0
+      error_message = "Scale rules authenticate with secrets the app does not have (${join(", ", local.missing_scale_rule_secrets)}): add them to secrets or key_vault_secrets."
+    }
+
+    precondition {
+      condition     = length(local.app_name_holders) == 0
+      error_message = "A Container App named ${var.name} already runs in environment ${join(", ", local.app_name_holders)} of resource group ${var.resource_group_name}. App names are unique per resource group across environments: choose another name."
+    }
   }
 }
 
@@ -397,6 +730,35 @@ resource "azapi_resource" "auth_configs" {
   depends_on = [azurerm_container_app.this]
 }
 
+#------------------------------------------------------------------------------
+# Ingress Sticky Sessions (Optional)
+#------------------------------------------------------------------------------
+# Session affinity pins each client to the replica that served its first
+# request, through a cookie the ingress sets. azurerm_container_app has no
+# argument for it, so it is patched onto the app's ingress with azapi.
+#
+# NOTE: HTTP ingress times out requests after 240 seconds; the platform does
+# not let apps change that bound.
+#------------------------------------------------------------------------------
+resource "azapi_update_resource" "sticky_sessions" {
+  count = var.ingress_enabled && var.ingress_sticky_sessions_enabled ? 1 : 0
+
+  type        = "Microsoft.App/containerApps@2023-05-01"
+  resource_id = azurerm_container_app.this.id
+
+  body = jsonencode({
+    properties = {
+      configuration = {
+        ingress = {
+          stickySessions = {
+            affinity = "sticky"
+          }
+        }
+      }
+    }
+  })
+}
+
 #------------------------------------------------------------------------------
 # RBAC: ACR Pull Access (Optional)
 #------------------------------------------------------------------------------
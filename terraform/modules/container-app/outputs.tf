@@ -71,7 +71,8 @@ output "latest_revision_fqdn" {
 }
 
 # outbound_ip_addresses - List of outbound IP addresses
-# Used for firewall rules when the app calls external services
+# Used for firewall rules when the app calls external services; downstream
+# database firewalls depend on it, see TestContainerAppOutboundIPContract
 output "outbound_ip_addresses" {
   description = "List of outbound IP addresses for the container app"
   value       = azurerm_container_app.this.outbound_ip_addresses
@@ -0,0 +1,399 @@
+#------------------------------------------------------------------------------
+# Azure Container App Module - Native Tests
+#------------------------------------------------------------------------------
+# Plan-only assertions against mocked providers, so they need no Azure
+# credentials. Run with `terraform test` from the module folder; the Go suite
+# runs them through TestModuleNativeTerraformTests.
+#------------------------------------------------------------------------------
+
+mock_provider "azurerm" {}
+
+mock_provider "azapi" {
+  # No app deployed yet in the subscription
+  mock_data "azapi_resource_list" {
+    defaults = {
+      output = "{\"value\":[]}"
+    }
+  }
+}
+
+variables {
+  name                       = "ca-tftest-dev"
+  environment_name           = "cae-tftest-dev"
+  resource_group_name        = "rg-tftest-dev"
+  location                   = "eastus2"
+  log_analytics_workspace_id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.OperationalInsights/workspaces/log-tftest-dev"
+  container_image            = "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest"
+  tags                       = { Environment = "dev" }
+}
+
+run "defaults" {
+  command = plan
+
+  assert {
+    condition     = azurerm_container_app.this.ingress[0].external_enabled && azurerm_container_app.this.ingress[0].target_port == 8080
+    error_message = "Ingress should be external on port 8080 by default"
+  }
+
+  assert {
+    condition     = !azurerm_container_app.this.ingress[0].allow_insecure_connections
+    error_message = "Ingress should refuse plain HTTP by default"
+  }
+
+  assert {
+    condition     = azurerm_container_app.this.identity[0].type == "SystemAssigned"
+    error_message = "The app should pull with its system-assigned identity by default"
+  }
+
+  assert {
+    condition     = azurerm_container_app.this.template[0].min_replicas == 1 && azurerm_container_app.this.template[0].max_replicas == 10
+    error_message = "The app should scale between 1 and 10 replicas by default"
+  }
+
+  assert {
+    condition     = length(azapi_resource.auth_configs) == 0 && length(azurerm_role_assignment.acr_pull) == 0
+    error_message = "Authentication and the AcrPull assignment should be opt-in"
+  }
+}
+
+run "rejects_min_above_max_replicas" {
+  command = plan
+
+  variables {
+    min_replicas = 5
+    max_replicas = 2
+  }
+
+  expect_failures = [azurerm_container_app.this]
+}
+
+run "rejects_unpaired_cpu_and_memory" {
+  command = plan
+
+  variables {
+    container_cpu    = 0.5
+    container_memory = "2Gi"
+  }
+
+  expect_failures = [azurerm_container_app.this]
+}
+
+run "allows_unpaired_container_with_paired_total" {
+  command = plan
+
+  # 0.75 vCPU / 1Gi plus 0.25 vCPU / 1Gi is 1 vCPU / 2Gi in total
+  variables {
+    container_cpu    = 0.75
+    container_memory = "1Gi"
+    sidecar_containers = [{
+      name   = "otel-collector"
+      image  = "otel/opentelemetry-collector:0.98.0"
+      cpu    = 0.25
+      memory = "1Gi"
+    }]
+  }
+
+  assert {
+    condition     = length(azurerm_container_app.this.template[0].container) == 2
+    error_message = "Only the replica total should need to be a Consumption combination"
+  }
+}
+
+run "rejects_insecure_ingress_in_production" {
+  command = plan
+
+  variables {
+    allow_insecure_connections = true
+    tags                       = { Environment = "prod" }
+  }
+
+  expect_failures = [azurerm_container_app.this]
+}
+
+run "rejects_unsupported_cpu" {
+  command = plan
+
+  variables {
+    container_cpu = 3
+  }
+
+  expect_failures = [var.container_cpu]
+}
+
+run "mounts_nfs_volumes_in_vnet" {
+  command = plan
+
+  variables {
+    infrastructure_subnet_id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.Network/virtualNetworks/vnet-tftest-dev/subnets/snet-container-app"
+    nfs_volumes = [{
+      name       = "data"
+      server     = "sttftest.file.core.windows.net"
+      share_name = "/sttftest/data"
+      mount_path = "/mnt/data"
+    }]
+  }
+
+  assert {
+    condition     = length(azapi_resource.nfs_storage) == 1
+    error_message = "Each NFS volume should be registered as environment storage"
+  }
+
+  assert {
+    condition     = azurerm_container_app.this.template[0].volume[0].storage_type == "NfsAzureFile" && azurerm_container_app.this.template[0].container[0].volume_mounts[0].path == "/mnt/data"
+    error_message = "The NFS volume should be mounted into the main container"
+  }
+}
+
+run "rejects_nfs_volumes_without_vnet" {
+  command = plan
+
+  variables {
+    nfs_volumes = [{
+      name       = "data"
+      server     = "sttftest.file.core.windows.net"
+      share_name = "/sttftest/data"
+      mount_path = "/mnt/data"
+    }]
+  }
+
+  expect_failures = [azurerm_container_app.this]
+}
+
+run "patches_sticky_sessions" {
+  command = plan
+
+  variables {
+    ingress_sticky_sessions_enabled = true
+  }
+
+  assert {
+    condition     = length(azapi_update_resource.sticky_sessions) == 1
+    error_message = "Sticky sessions should be patched onto the app's ingress"
+  }
+}
+
+run "rejects_sticky_sessions_with_multiple_revisions" {
+  command = plan
+
+  variables {
+    ingress_sticky_sessions_enabled = true
+    revision_mode                   = "Multiple"
+  }
+
+  expect_failures = [azurerm_container_app.this]
+}
+
+run "references_key_vault_secrets" {
+  command = plan
+
+  variables {
+    key_vault_secrets = {
+      appinsights-connection-string = "https://kv-tftest-dev.vault.azure.net/secrets/appinsights-connection-string"
+    }
+    key_vault_secret_identity_id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id-tftest-dev"
+    secret_environment_variables = {
+      APPLICATIONINSIGHTS_CONNECTION_STRING = "appinsights-connection-string"
+    }
+  }
+
+  assert {
+    condition     = one([for secret in azurerm_container_app.this.secret : secret.identity if secret.name == "appinsights-connection-string"]) == var.key_vault_secret_identity_id
+    error_message = "The Key Vault reference should be resolved with the given identity"
+  }
+
+  assert {
+    condition     = azurerm_container_app.this.identity[0].type == "SystemAssigned, UserAssigned"
+    error_message = "The identity resolving Key Vault references should be attached to the app"
+  }
+}
+
+run "rejects_user_identity_pull_without_identity" {
+  command = plan
+
+  variables {
+    registry_server    = "crtftestdev.azurecr.io"
+    registry_auth_mode = "user_identity"
+  }
+
+  expect_failures = [azurerm_container_app.this]
+}
+
+run "rejects_credential_pull_without_password" {
+  command = plan
+
+  variables {
+    registry_server    = "crtftestdev.azurecr.io"
+    registry_auth_mode = "admin_credentials"
+    registry_username  = "crtftestdev"
+  }
+
+  expect_failures = [azurerm_container_app.this]
+}
+
+run "pulls_with_service_principal_credentials" {
+  command = plan
+
+  variables {
+    registry_server    = "crtftestdev.azurecr.io"
+    registry_auth_mode = "service_principal"
+    registry_username  = "00000000-0000-0000-0000-000000000000"
+    registry_password  = "not-a-real-secret"
+  }
+
+  assert {
+    condition     = azurerm_container_app.this.registry[0].username == var.registry_username && azurerm_container_app.this.registry[0].password_secret_name == "registry-password"
+    error_message = "The registry should be pulled from with the service principal's client ID and the registry-password secret"
+  }
+
+  assert {
+    condition     = azurerm_container_app.this.registry[0].identity == null
+    error_message = "A credential pull should not also name an identity"
+  }
+
+  assert {
+    condition     = contains([for secret in azurerm_container_app.this.secret : secret.name], "registry-password")
+    error_message = "The password should be stored as the registry-password secret"
+  }
+}
+
+run "rejects_key_vault_secrets_without_identity" {
+  command = plan
+
+  variables {
+    key_vault_secrets = {
+      appinsights-connection-string = "https://kv-tftest-dev.vault.azure.net/secrets/appinsights-connection-string"
+    }
+  }
+
+  expect_failures = [azurerm_container_app.this]
+}
+
+run "rejects_malformed_key_vault_secret_id" {
+  command = plan
+
+  variables {
+    key_vault_secrets = {
+      appinsights-connection-string = "kv-tftest-dev/appinsights-connection-string"
+    }
+    key_vault_secret_identity_id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id-tftest-dev"
+  }
+
+  expect_failures = [var.key_vault_secrets]
+}
+
+run "rejects_app_name_taken_in_another_environment" {
+  command = plan
+
+  override_data {
+    target = data.azapi_resource_list.container_apps
+    values = {
+      output = <<-EOT
+        {"value": [
+          {
+            "name": "ca-tftest-dev",
+            "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.App/containerApps/ca-tftest-dev",
+            "properties": {"managedEnvironmentId": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.App/managedEnvironments/cae-other-dev"}
+          }
+        ]}
+      EOT
+    }
+  }
+
+  expect_failures = [azurerm_container_app.this]
+}
+
+run "rejects_environment_name_of_another_instance" {
+  command = plan
+
+  override_data {
+    target = data.azapi_resource_list.container_apps
+    values = {
+      output = <<-EOT
+        {"value": [
+          {
+            "name": "ca-other-dev",
+            "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.App/containerApps/ca-other-dev",
+            "properties": {"managedEnvironmentId": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.App/managedEnvironments/cae-tftest-dev"}
+          }
+        ]}
+      EOT
+    }
+  }
+
+  expect_failures = [azurerm_container_app_environment.this]
+}
+
+run "allows_own_app_and_same_names_in_other_groups" {
+  command = plan
+
+  override_data {
+    target = data.azapi_resource_list.container_apps
+    values = {
+      output = <<-EOT
+        {"value": [
+          {
+            "name": "ca-tftest-dev",
+            "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.App/containerApps/ca-tftest-dev",
+            "properties": {"managedEnvironmentId": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.App/managedEnvironments/cae-tftest-dev"}
+          },
+          {
+            "name": "ca-tftest-dev",
+            "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-prod/providers/Microsoft.App/containerApps/ca-tftest-dev",
+            "properties": {"managedEnvironmentId": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-prod/providers/Microsoft.App/managedEnvironments/cae-other-prod"}
+          }
+        ]}
+      EOT
+    }
+  }
+
+  assert {
+    condition     = length(local.app_name_holders) == 0 && length(local.environment_name_holders) == 0
+    error_message = "Re-planning an instance's own app, or the same name in another resource group, should not count as a collision"
+  }
+
+  assert {
+    condition     = azurerm_container_app.this.tags["ModuleInstance"] == "rg-tftest-dev/cae-tftest-dev/ca-tftest-dev"
+    error_message = "The app should carry the names it is first created with as its instance tag"
+  }
+}
+
+# The runs below share state: the instance is applied, then renamed
+run "applies_instance" {
+  command = apply
+}
+
+run "allows_renaming_own_app_and_environment" {
+  command = plan
+
+  variables {
+    name             = "ca-tftest-renamed"
+    environment_name = "cae-tftest-renamed"
+  }
+
+  # The deployed app, under its old names, carries this instance's tag
+  override_data {
+    target = data.azapi_resource_list.container_apps
+    values = {
+      output = <<-EOT
+        {"value": [
+          {
+            "name": "ca-tftest-dev",
+            "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.App/containerApps/ca-tftest-dev",
+            "tags": {"Environment": "dev", "ModuleInstance": "rg-tftest-dev/cae-tftest-dev/ca-tftest-dev"},
+            "properties": {"managedEnvironmentId": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.App/managedEnvironments/cae-tftest-dev"}
+          }
+        ]}
+      EOT
+    }
+  }
+
+  assert {
+    condition     = length(local.app_name_holders) == 0 && length(local.environment_name_holders) == 0
+    error_message = "Renaming an instance's own app and environment should not count its deployed resources as another instance's"
+  }
+
+  assert {
+    condition     = azurerm_container_app.this.tags["ModuleInstance"] == "rg-tftest-dev/cae-tftest-dev/ca-tftest-dev"
+    error_message = "The instance tag should keep the names the instance was first created with"
+  }
+}
@@ -85,6 +85,17 @@ variable "zone_redundancy_enabled" {
   default     = false
 }
 
+# workload_profiles_enabled - Use the workload profiles plan
+# false = Consumption-only environment
+# true = Workload profiles environment running the app on the Consumption
+#        profile; required for user-defined routes on infrastructure_subnet_id
+# Changing it replaces the environment
+variable "workload_profiles_enabled" {
+  description = "Run the environment on the workload profiles plan (Consumption profile), required for user-defined routes"
+  type        = bool
+  default     = false
+}
+
 #------------------------------------------------------------------------------
 # Container App Configuration
 #------------------------------------------------------------------------------
@@ -144,13 +155,82 @@ variable "container_cpu" {
 
 # container_memory - Memory allocation (0.5Gi - 4Gi)
 variable "container_memory" {
-  description = "Memory allocation (0.5Gi, 1Gi, 1.5Gi, 2Gi, 3Gi, 4Gi)"
+  description = "Memory allocation, paired with container_cpu (0.5Gi, 1Gi, 1.5Gi, 2Gi, 2.5Gi, 3Gi, 3.5Gi, 4Gi)"
   type        = string
   default     = "1Gi"
 
   validation {
-    condition     = contains(["0.5Gi", "1Gi", "1.5Gi", "2Gi", "3Gi", "4Gi"], var.container_memory)
-    error_message = "Memory must be 0.5Gi, 1Gi, 1.5Gi, 2Gi, 3Gi, or 4Gi"
+    condition     = contains(["0.5Gi", "1Gi", "1.5Gi", "2Gi", "2.5Gi", "3Gi", "3.5Gi", "4Gi"], var.container_memory)
+    error_message = "Memory must be 0.5Gi, 1Gi, 1.5Gi, 2Gi, 2.5Gi, 3Gi, 3.5Gi, or 4Gi"
+  }
+}
+
+# sidecar_containers - Additional containers in every replica
+# All containers together must request a Consumption combination (see
+# local.consumption_resource_combinations in main.tf)
+variable "sidecar_containers" {
+  description = "Additional containers run next to the main container in each replica"
+  type = list(object({
+    name                  = string
+    image                 = string
+    cpu                   = number
+    memory                = string
+    command               = optional(list(string))
+    args                  = optional(list(string))
+    environment_variables = optional(map(string), {})
+  }))
+  default = []
+
+  validation {
+    condition     = alltrue([for sidecar in var.sidecar_containers : sidecar.cpu > 0 && floor(sidecar.cpu * 4) == sidecar.cpu * 4])
+    error_message = "Sidecar CPU must be a positive multiple of 0.25 vCPU"
+  }
+
+  validation {
+    condition     = alltrue([for sidecar in var.sidecar_containers : can(regex("^[0-9]+(\\.[0-9]+)?Gi$", sidecar.memory))])
+    error_message = "Sidecar memory must be given in Gi, e.g. 0.5Gi"
+  }
+
+  validation {
+    condition     = length(distinct([for sidecar in var.sidecar_containers : sidecar.name])) == length(var.sidecar_containers)
+    error_message = "Sidecar container names must be unique"
+  }
+}
+
+#------------------------------------------------------------------------------
+# Storage Volumes
+#------------------------------------------------------------------------------
+
+# nfs_volumes - NFS Azure Files shares mounted into the main container
+# Each share is registered as storage on the environment under name and
+# mounted at mount_path. NFS shares are only reachable from a VNet, so they
+# require infrastructure_subnet_id (checked at plan time).
+# server = <account>.file.core.windows.net
+# share_name = /<account>/<share>
+variable "nfs_volumes" {
+  description = "NFS Azure Files shares (Premium FileStorage) mounted into the main container; requires infrastructure_subnet_id"
+  type = list(object({
+    name        = string
+    server      = string
+    share_name  = string
+    mount_path  = string
+    access_mode = optional(string, "ReadWrite")
+  }))
+  default = []
+
+  validation {
+    condition     = alltrue([for volume in var.nfs_volumes : contains(["ReadOnly", "ReadWrite"], volume.access_mode)])
+    error_message = "NFS volume access mode must be ReadOnly or ReadWrite"
+  }
+
+  validation {
+    condition     = alltrue([for volume in var.nfs_volumes : startswith(volume.mount_path, "/")])
+    error_message = "NFS volume mount paths must be absolute"
+  }
+
+  validation {
+    condition     = length(distinct([for volume in var.nfs_volumes : volume.name])) == length(var.nfs_volumes)
+    error_message = "NFS volume names must be unique"
   }
 }
 
@@ -184,6 +264,28 @@ variable "secrets" {
   # NOTE: sensitive = true cannot be used with for_each in Terraform
 }
 
+# key_vault_secrets - Secrets the Container App reads from Key Vault
+# Keyed by Container App secret name, valued by versionless Key Vault secret
+# ID; refer to them from secret_environment_variables like other secrets
+variable "key_vault_secrets" {
+  description = "Map of Container App secret name to Key Vault secret ID (versionless for the latest version), resolved with key_vault_secret_identity_id"
+  type        = map(string)
+  default     = {}
+
+  validation {
+    condition     = alltrue([for id in values(var.key_vault_secrets) : can(regex("^https://[^/]+/secrets/[^/]+(/[^/]+)?$", id))])
+    error_message = "Key Vault secret IDs must look like https://<vault>.vault.azure.net/secrets/<name>, optionally followed by /<version>."
+  }
+}
+
+# key_vault_secret_identity_id - Identity resolving key_vault_secrets
+# Must be user-assigned: it needs Key Vault Secrets User before the app exists
+variable "key_vault_secret_identity_id" {
+  description = "ID of the user-assigned identity that reads key_vault_secrets (required if key_vault_secrets is set)"
+  type        = string
+  default     = null
+}
+
 #------------------------------------------------------------------------------
 # Scaling Configuration
 #------------------------------------------------------------------------------
@@ -229,13 +331,19 @@ variable "http_scale_concurrent_requests" {
 }
 
 # custom_scale_rules - Custom KEDA scale rules
-# For queue-based, CPU/memory, or custom metric scaling
+# For queue-based, CPU/memory, or custom metric scaling. Scalers that need
+# credentials (Service Bus, Storage Queue) read them from app secrets through
+# authentication: each entry passes a secret as a trigger parameter
 variable "custom_scale_rules" {
-  description = "List of custom scale rules (for queue-based, etc.)"
+  description = "List of custom scale rules (for queue-based, etc.), with app secrets passed to the scaler as trigger parameters"
   type = list(object({
     name     = string
     type     = string
     metadata = map(string)
+    authentication = optional(list(object({
+      secret_name       = string
+      trigger_parameter = string
+    })), [])
   }))
   default = []
 }
@@ -429,12 +537,22 @@ variable "ingress_transport" {
   }
 }
 
+# Must stay false when tags mark the app as production (Environment = prod)
 variable "allow_insecure_connections" {
   description = "Allow insecure HTTP connections (false = HTTPS only)"
   type        = bool
   default     = false
 }
 
+# ingress_sticky_sessions_enabled - Session affinity
+# Pins each client to one replica with an affinity cookie. Only supported for
+# HTTP ingress in Single revision mode (checked at plan time)
+variable "ingress_sticky_sessions_enabled" {
+  description = "Route each client to the same replica with an affinity cookie (Single revision mode, HTTP ingress)"
+  type        = bool
+  default     = false
+}
+
 variable "traffic_latest_revision" {
   description = "Route traffic to latest revision"
   type        = bool
@@ -479,6 +597,41 @@ variable "registry_server" {
   default     = ""
 }
 
+# registry_auth_mode - How the app authenticates image pulls
+# system_identity: System-assigned identity with AcrPull (recommended)
+# user_identity: User-assigned identity (registry_identity_id) with AcrPull
+# admin_credentials / service_principal: Username + password stored as an
+#   app secret (registry-password); use only where identities are not possible
+variable "registry_auth_mode" {
+  description = "Registry authentication mode (system_identity, user_identity, admin_credentials, service_principal)"
+  type        = string
+  default     = "system_identity"
+
+  validation {
+    condition     = contains(["system_identity", "user_identity", "admin_credentials", "service_principal"], var.registry_auth_mode)
+    error_message = "Registry auth mode must be system_identity, user_identity, admin_credentials, or service_principal"
+  }
+}
+
+variable "registry_identity_id" {
+  description = "ID of the user-assigned identity used to pull images (required if registry_auth_mode = user_identity)"
+  type        = string
+  default     = null
+}
+
+variable "registry_username" {
+  description = "Registry username: ACR admin user or service principal client ID (required for credential auth modes)"
+  type        = string
+  default     = null
+}
+
+variable "registry_password" {
+  description = "Registry password: ACR admin password or service principal secret (required for credential auth modes)"
+  type        = string
+  default     = null
+  sensitive   = true
+}
+
 variable "enable_acr_pull" {
   description = "Enable ACR pull role assignment for the container app"
   type        = bool
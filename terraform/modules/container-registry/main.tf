@@ -38,10 +38,11 @@ resource "azurerm_container_registry" "this" {
   # - Premium: Geo-replication, private endpoints, retention policies
   sku = var.sku
 
-  # Security: Admin user is disabled
+  # Security: Admin user is disabled by default
   # We use Managed Identity for authentication instead of admin credentials
   # This is more secure as it eliminates static credentials
-  admin_enabled = false
+  # Opt in only for tooling that cannot use Azure AD (see var.admin_enabled)
+  admin_enabled = var.admin_enabled
 
   # Network access configuration
   # true: Allow public internet access (suitable for dev)
@@ -57,10 +58,51 @@ resource "azurerm_container_registry" "this" {
   # Enables Docker Content Trust for image signing and verification
   trust_policy_enabled = var.sku == "Premium" && var.trust_policy_enabled
 
+  # Quarantine policy (Premium SKU only)
+  # New images are quarantined until a scanner marks them as passed;
+  # until then only identities with AcrQuarantineReader can pull them
+  quarantine_policy_enabled = var.sku == "Premium" && var.quarantine_policy_enabled
+
   # Resource tags for organization and cost management
   tags = var.tags
 }
 
+#------------------------------------------------------------------------------
+# Soft Delete Policy (Optional)
+#------------------------------------------------------------------------------
+# Deleted tags and manifests are kept, listed as deleted, for the retention
+# window and can be restored until then. azurerm_container_registry has no
+# argument for the policy, so it is patched onto the registry with azapi.
+#
+# NOTE: Setting soft_delete_enabled back to false stops managing the policy;
+# it stays as it was on the registry.
+#------------------------------------------------------------------------------
+resource "azapi_update_resource" "soft_delete_policy" {
+  count = var.soft_delete_enabled ? 1 : 0
+
+  type        = "Microsoft.ContainerRegistry/registries@2023-11-01-preview"
+  resource_id = azurerm_container_registry.this.id
+
+  body = jsonencode({
+    properties = {
+      policies = {
+        softDeletePolicy = {
+          status        = "enabled"
+          retentionDays = var.soft_delete_retention_days
+        }
+      }
+    }
+  })
+
+  lifecycle {
+    # The registry refuses the two policies together
+    precondition {
+      condition     = !(var.sku == "Premium" && var.retention_enabled)
+      error_message = "Soft delete cannot be enabled together with the retention policy for untagged manifests: set soft_delete_enabled or retention_enabled, not both."
+    }
+  }
+}
+
 #------------------------------------------------------------------------------
 # Scope Map (Optional)
 #------------------------------------------------------------------------------
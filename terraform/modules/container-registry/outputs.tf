@@ -33,22 +33,22 @@ output "login_server" {
 }
 
 #------------------------------------------------------------------------------
-# Admin Credentials (Null - Admin Disabled)
+# Admin Credentials (Null Unless Admin Enabled)
 #------------------------------------------------------------------------------
-# These outputs return null because admin_enabled = false
+# These outputs return null unless admin_enabled = true
 # We use Managed Identity for authentication instead of admin credentials
-# These are included for API compatibility with modules expecting these outputs
+# Always marked sensitive so credentials never appear in plan or apply output
 #------------------------------------------------------------------------------
 
 output "admin_username" {
-  description = "The admin username (null since admin is disabled)"
-  value       = null
+  description = "The admin username (null unless admin_enabled = true)"
+  value       = var.admin_enabled ? azurerm_container_registry.this.admin_username : null
   sensitive   = true
 }
 
 output "admin_password" {
-  description = "The admin password (null since admin is disabled)"
-  value       = null
+  description = "The admin password (null unless admin_enabled = true)"
+  value       = var.admin_enabled ? azurerm_container_registry.this.admin_password : null
   sensitive   = true
 }
 
@@ -58,7 +58,19 @@ output "admin_password" {
 
 # identity - The managed identity configuration of the registry
 # Used for customer-managed encryption key scenarios
+# null rather than an empty list when the registry has no identity
 output "identity" {
-  description = "The identity block of the container registry (if configured)"
-  value       = azurerm_container_registry.this.identity
+  description = "The identity block of the container registry (null if not configured)"
+  value       = length(azurerm_container_registry.this.identity) > 0 ? azurerm_container_registry.this.identity : null
+}
+
+#------------------------------------------------------------------------------
+# Token Authentication Outputs
+#------------------------------------------------------------------------------
+
+# pull_scope_map_id - Read-only scope map for registry tokens
+# null unless create_scope_maps = true
+output "pull_scope_map_id" {
+  description = "ID of the read-only scope map for registry tokens (null unless create_scope_maps = true)"
+  value       = try(azurerm_container_registry_scope_map.pull[0].id, null)
 }
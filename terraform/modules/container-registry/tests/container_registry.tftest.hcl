@@ -0,0 +1,108 @@
+#------------------------------------------------------------------------------
+# Azure Container Registry Module - Native Tests
+#------------------------------------------------------------------------------
+# Plan-only assertions against a mocked provider, so they need no Azure
+# credentials. Run with `terraform test` from the module folder; the Go suite
+# runs them through TestModuleNativeTerraformTests.
+#------------------------------------------------------------------------------
+
+mock_provider "azurerm" {}
+
+mock_provider "azapi" {}
+
+variables {
+  name                       = "acrtftestdev"
+  resource_group_name        = "rg-tftest-dev"
+  location                   = "eastus2"
+  log_analytics_workspace_id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.OperationalInsights/workspaces/log-tftest-dev"
+}
+
+run "secure_defaults" {
+  command = plan
+
+  assert {
+    condition     = azurerm_container_registry.this.sku == "Basic" && !azurerm_container_registry.this.admin_enabled
+    error_message = "Registry should default to Basic with the admin user disabled"
+  }
+
+  assert {
+    condition     = length(azurerm_container_registry_scope_map.pull) == 0
+    error_message = "The pull scope map should be opt-in"
+  }
+
+  assert {
+    condition     = length(azapi_update_resource.soft_delete_policy) == 0
+    error_message = "Soft delete should be opt-in"
+  }
+}
+
+run "premium_features_ignored_below_premium" {
+  command = plan
+
+  variables {
+    sku                       = "Standard"
+    trust_policy_enabled      = true
+    quarantine_policy_enabled = true
+  }
+
+  assert {
+    condition     = !azurerm_container_registry.this.trust_policy_enabled && !azurerm_container_registry.this.quarantine_policy_enabled
+    error_message = "Trust and quarantine policies should only apply to Premium registries"
+  }
+}
+
+run "premium_quarantine" {
+  command = plan
+
+  variables {
+    sku                       = "Premium"
+    quarantine_policy_enabled = true
+    create_scope_maps         = true
+  }
+
+  assert {
+    condition     = azurerm_container_registry.this.quarantine_policy_enabled
+    error_message = "Premium registries should enable the quarantine policy when asked"
+  }
+
+  assert {
+    condition     = length(azurerm_container_registry_scope_map.pull) == 1
+    error_message = "create_scope_maps should create the pull scope map"
+  }
+}
+
+run "soft_delete" {
+  command = plan
+
+  variables {
+    soft_delete_enabled        = true
+    soft_delete_retention_days = 30
+  }
+
+  assert {
+    condition     = jsondecode(azapi_update_resource.soft_delete_policy[0].body).properties.policies.softDeletePolicy.retentionDays == 30
+    error_message = "soft_delete_retention_days should set the soft delete policy's retention"
+  }
+}
+
+run "soft_delete_rejects_retention_policy" {
+  command = plan
+
+  variables {
+    sku                 = "Premium"
+    retention_enabled   = true
+    soft_delete_enabled = true
+  }
+
+  expect_failures = [azapi_update_resource.soft_delete_policy]
+}
+
+run "rejects_invalid_name" {
+  command = plan
+
+  variables {
+    name = "acr-tftest-dev"
+  }
+
+  expect_failures = [var.name]
+}
@@ -70,6 +70,15 @@ variable "public_network_access_enabled" {
 # Security Configuration
 #------------------------------------------------------------------------------
 
+# admin_enabled - Enable the registry admin user
+# Disabled by default: the admin user is a single shared credential with
+# push/pull on every repository. Prefer Managed Identity (AcrPull) instead
+variable "admin_enabled" {
+  description = "Enable the admin user (not recommended; credentials are exposed as sensitive outputs)"
+  type        = bool
+  default     = false
+}
+
 # encryption_enabled - Customer-managed encryption keys
 # Requires Premium SKU and Key Vault integration
 variable "encryption_enabled" {
@@ -86,6 +95,15 @@ variable "trust_policy_enabled" {
   default     = false
 }
 
+# quarantine_policy_enabled - Quarantine new images until scanned
+# Pushed and imported images cannot be pulled by regular users until their
+# quarantine state is set to Passed by an AcrQuarantineWriter
+variable "quarantine_policy_enabled" {
+  description = "Quarantine pushed and imported images until they are marked as scanned (Premium SKU only)"
+  type        = bool
+  default     = false
+}
+
 # create_scope_maps - Create scope maps for token-based auth
 # Used for granular access control to repositories
 variable "create_scope_maps" {
@@ -119,6 +137,33 @@ variable "retention_days" {
   }
 }
 
+#------------------------------------------------------------------------------
+# Soft Delete Configuration
+#------------------------------------------------------------------------------
+
+# soft_delete_enabled - Keep deleted artifacts recoverable
+# Deleted tags and manifests stay listed as deleted, and can be restored,
+# until soft_delete_retention_days have passed. Not supported together with
+# the retention policy for untagged manifests
+variable "soft_delete_enabled" {
+  description = "Keep deleted tags and manifests recoverable for soft_delete_retention_days (not with retention_enabled)"
+  type        = bool
+  default     = false
+}
+
+# soft_delete_retention_days - How long deleted artifacts stay recoverable
+# Only applies when soft_delete_enabled is true
+variable "soft_delete_retention_days" {
+  description = "Number of days deleted tags and manifests can be restored before they are purged"
+  type        = number
+  default     = 7
+
+  validation {
+    condition     = var.soft_delete_retention_days >= 1 && var.soft_delete_retention_days <= 90
+    error_message = "Soft delete retention days must be between 1 and 90"
+  }
+}
+
 #------------------------------------------------------------------------------
 # Diagnostic Settings
 #------------------------------------------------------------------------------
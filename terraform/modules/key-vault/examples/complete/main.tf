@@ -6,7 +6,7 @@ data "azurerm_client_config" "current" {}
 
 # First, create a resource group
 module "resource_group" {
-  source = "../../resource-group"
+  source = "../../../resource-group"
 
   name     = "rg-kv-example"
   location = "eastus2"
@@ -184,14 +184,18 @@ resource "azurerm_key_vault_secret" "secrets" {
   # Secret name (becomes the identifier in Key Vault)
   name = each.key
 
-  # Secret value (protected in state, not shown in logs)
+  # Secret value (protected in state, not shown in logs). A new value is
+  # written as a new version in place; older versions are kept
   value = each.value
 
   # Reference to the Key Vault
   key_vault_id = azurerm_key_vault.this.id
 
-  # Content type for metadata
-  content_type = "text/plain"
+  # Optional metadata; secrets without an entry in secret_metadata are plain text
+  content_type    = try(var.secret_metadata[each.key].content_type, "text/plain")
+  not_before_date = try(var.secret_metadata[each.key].not_before_date, null)
+  expiration_date = try(var.secret_metadata[each.key].expiration_date, null)
+  tags            = try(var.secret_metadata[each.key].tags, {})
 
   # Ensure RBAC assignment is complete before creating secrets
   depends_on = [azurerm_role_assignment.deployer]
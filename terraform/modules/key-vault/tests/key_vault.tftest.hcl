@@ -0,0 +1,85 @@
+#------------------------------------------------------------------------------
+# Azure Key Vault Module - Native Tests
+#------------------------------------------------------------------------------
+# Plan-only assertions against a mocked provider, so they need no Azure
+# credentials. Run with `terraform test` from the module folder; the Go suite
+# runs them through TestModuleNativeTerraformTests.
+#------------------------------------------------------------------------------
+
+mock_provider "azurerm" {
+  mock_data "azurerm_client_config" {
+    defaults = {
+      tenant_id = "00000000-0000-0000-0000-000000000000"
+    }
+  }
+}
+
+variables {
+  name                       = "kv-tftest-dev"
+  resource_group_name        = "rg-tftest-dev"
+  location                   = "eastus2"
+  log_analytics_workspace_id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.OperationalInsights/workspaces/log-tftest-dev"
+}
+
+run "secure_defaults" {
+  command = plan
+
+  assert {
+    condition     = azurerm_key_vault.this.rbac_authorization_enabled
+    error_message = "Key Vault should use RBAC authorization"
+  }
+
+  assert {
+    condition     = azurerm_key_vault.this.purge_protection_enabled && azurerm_key_vault.this.soft_delete_retention_days == 90
+    error_message = "Key Vault should enable purge protection and keep deleted items for 90 days by default"
+  }
+
+  assert {
+    condition     = azurerm_key_vault.this.tenant_id == "00000000-0000-0000-0000-000000000000"
+    error_message = "Key Vault should use the tenant of the current client"
+  }
+
+  assert {
+    condition     = length(azurerm_key_vault.this.network_acls) == 0 && length(azurerm_role_assignment.deployer) == 0
+    error_message = "Network ACLs and the deployer role assignment should be opt-in"
+  }
+
+  assert {
+    condition     = length(azurerm_monitor_diagnostic_setting.keyvault) == 1
+    error_message = "Diagnostics should be enabled by default"
+  }
+}
+
+run "network_acls" {
+  command = plan
+
+  variables {
+    network_acls_enabled = true
+    allowed_ip_ranges    = ["203.0.113.0/24"]
+  }
+
+  assert {
+    condition     = azurerm_key_vault.this.network_acls[0].default_action == "Deny" && azurerm_key_vault.this.network_acls[0].bypass == "AzureServices"
+    error_message = "Network ACLs should deny by default and let trusted Azure services through"
+  }
+}
+
+run "rejects_short_retention" {
+  command = plan
+
+  variables {
+    soft_delete_retention_days = 5
+  }
+
+  expect_failures = [var.soft_delete_retention_days]
+}
+
+run "rejects_uppercase_name" {
+  command = plan
+
+  variables {
+    name = "Kv-TfTest-Dev"
+  }
+
+  expect_failures = [azurerm_key_vault.this]
+}
@@ -196,6 +196,30 @@ variable "secrets" {
   # The secret values are still protected in Terraform state
 }
 
+# secret_metadata - Content type, activation window and tags per secret
+# Keyed by secret name; changing metadata updates the current secret version
+# in place, only a changed value creates a new version
+variable "secret_metadata" {
+  description = "Metadata for secrets, keyed by secret name. Dates are RFC 3339 UTC timestamps (e.g. 2026-01-01T00:00:00Z)."
+  type = map(object({
+    content_type    = optional(string, "text/plain")
+    not_before_date = optional(string)
+    expiration_date = optional(string)
+    tags            = optional(map(string), {})
+  }))
+  default = {}
+
+  validation {
+    condition = alltrue([
+      for metadata in values(var.secret_metadata) : alltrue([
+        for date in [metadata.not_before_date, metadata.expiration_date] :
+        date == null || can(formatdate("YYYY", date))
+      ])
+    ])
+    error_message = "Secret not_before_date and expiration_date must be RFC 3339 timestamps"
+  }
+}
+
 #------------------------------------------------------------------------------
 # Optional Variables
 #------------------------------------------------------------------------------
@@ -2,12 +2,14 @@
 # Networking Module - main.tf
 #------------------------------------------------------------------------------
 # Creates a Virtual Network with two subnets to support private endpoints and
-# Container App environment VNet injection.
+# Container App environment VNet injection, and optionally an Azure Firewall
+# that only lets the Container App subnet reach an allow-list of FQDNs.
 #
 # Subnet layout (defaults):
 #   10.0.1.0/24  snet-private-endpoints  — Key Vault and ACR private endpoints
 #   10.0.2.0/23  snet-container-app      — Container App environment injection
 #                                          (/23 is the Azure minimum for this use)
+#   10.0.5.0/26  AzureFirewallSubnet     — Egress firewall (egress_firewall_enabled)
 #
 # Usage:
 #   module "networking" {
@@ -73,3 +75,122 @@ resource "azurerm_subnet" "container_app" {
     }
   }
 }
+
+#------------------------------------------------------------------------------
+# Egress Firewall (Optional)
+#------------------------------------------------------------------------------
+# Routes all outbound traffic of the Container App subnet through an Azure
+# Firewall whose policy allows HTTPS to egress_allowed_fqdns and denies the
+# rest. Only workload profiles environments honour the route table, see the
+# container-app module's workload_profiles_enabled.
+#------------------------------------------------------------------------------
+locals {
+  egress_firewall_count = var.egress_firewall_enabled ? 1 : 0
+}
+
+# Azure requires this exact subnet name for the firewall
+resource "azurerm_subnet" "firewall" {
+  count = local.egress_firewall_count
+
+  name                 = "AzureFirewallSubnet"
+  resource_group_name  = var.resource_group_name
+  virtual_network_name = azurerm_virtual_network.this.name
+  address_prefixes     = [var.firewall_subnet_cidr]
+}
+
+resource "azurerm_public_ip" "firewall" {
+  count = local.egress_firewall_count
+
+  name                = "pip-afw-${var.vnet_name}"
+  resource_group_name = var.resource_group_name
+  location            = var.location
+  allocation_method   = "Static"
+  sku                 = "Standard"
+
+  tags = var.tags
+}
+
+# Standard tier: application rules with wildcard FQDNs need at least Standard
+resource "azurerm_firewall_policy" "egress" {
+  count = local.egress_firewall_count
+
+  name                = "afwp-${var.vnet_name}"
+  resource_group_name = var.resource_group_name
+  location            = var.location
+  sku                 = "Standard"
+
+  tags = var.tags
+}
+
+# The allow-list. Anything not matched here is denied by the firewall
+resource "azurerm_firewall_policy_rule_collection_group" "egress" {
+  count = local.egress_firewall_count
+
+  name               = "egress-allow-list"
+  firewall_policy_id = azurerm_firewall_policy.egress[0].id
+  priority           = 100
+
+  application_rule_collection {
+    name     = "container-app-egress"
+    priority = 100
+    action   = "Allow"
+
+    rule {
+      name              = "allowed-fqdns"
+      source_addresses  = [var.container_app_subnet_cidr]
+      destination_fqdns = var.egress_allowed_fqdns
+
+      protocols {
+        type = "Https"
+        port = 443
+      }
+    }
+  }
+}
+
+resource "azurerm_firewall" "egress" {
+  count = local.egress_firewall_count
+
+  name                = "afw-${var.vnet_name}"
+  resource_group_name = var.resource_group_name
+  location            = var.location
+  sku_name            = "AZFW_VNet"
+  sku_tier            = "Standard"
+  firewall_policy_id  = azurerm_firewall_policy.egress[0].id
+
+  ip_configuration {
+    name                 = "egress"
+    subnet_id            = azurerm_subnet.firewall[0].id
+    public_ip_address_id = azurerm_public_ip.firewall[0].id
+  }
+
+  tags = var.tags
+
+  # Rules must exist before traffic is sent through the firewall
+  depends_on = [azurerm_firewall_policy_rule_collection_group.egress]
+}
+
+# Default route of the Container App subnet: everything via the firewall
+resource "azurerm_route_table" "egress" {
+  count = local.egress_firewall_count
+
+  name                = "rt-${var.vnet_name}-egress"
+  resource_group_name = var.resource_group_name
+  location            = var.location
+
+  route {
+    name                   = "default-via-firewall"
+    address_prefix         = "0.0.0.0/0"
+    next_hop_type          = "VirtualAppliance"
+    next_hop_in_ip_address = azurerm_firewall.egress[0].ip_configuration[0].private_ip_address
+  }
+
+  tags = var.tags
+}
+
+resource "azurerm_subnet_route_table_association" "container_app" {
+  count = local.egress_firewall_count
+
+  subnet_id      = azurerm_subnet.container_app.id
+  route_table_id = azurerm_route_table.egress[0].id
+}
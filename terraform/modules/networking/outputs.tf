@@ -21,3 +21,18 @@ output "container_app_subnet_id" {
   description = "Resource ID of the Container App environment subnet (used for VNet injection)"
   value       = azurerm_subnet.container_app.id
 }
+
+output "egress_firewall_private_ip" {
+  description = "Private IP of the egress firewall, the Container App subnet's next hop (null when egress_firewall_enabled is false)"
+  value       = try(azurerm_firewall.egress[0].ip_configuration[0].private_ip_address, null)
+}
+
+output "egress_firewall_public_ip" {
+  description = "Public IP the Container Apps' outbound traffic leaves from (null when egress_firewall_enabled is false)"
+  value       = try(azurerm_public_ip.firewall[0].ip_address, null)
+}
+
+output "egress_route_table_name" {
+  description = "Name of the Container App subnet's route table (null when egress_firewall_enabled is false)"
+  value       = try(azurerm_route_table.egress[0].name, null)
+}
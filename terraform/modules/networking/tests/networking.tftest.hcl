@@ -0,0 +1,67 @@
+#------------------------------------------------------------------------------
+# Networking Module - Native Tests
+#------------------------------------------------------------------------------
+# Plan-only assertions against a mocked provider, so they need no Azure
+# credentials. Run with `terraform test` from the module folder; the Go suite
+# runs them through TestModuleNativeTerraformTests.
+#------------------------------------------------------------------------------
+
+mock_provider "azurerm" {}
+
+variables {
+  vnet_name           = "vnet-tftest-dev"
+  resource_group_name = "rg-tftest-dev"
+  location            = "eastus2"
+}
+
+run "defaults" {
+  command = plan
+
+  assert {
+    condition     = contains(azurerm_virtual_network.this.address_space, "10.0.0.0/16")
+    error_message = "The virtual network should use the default address space"
+  }
+
+  assert {
+    condition     = contains(azurerm_subnet.container_app.address_prefixes, "10.0.2.0/23")
+    error_message = "The Container App subnet should be a /23, the smallest Container Apps accepts"
+  }
+
+  assert {
+    condition     = azurerm_subnet.container_app.delegation[0].service_delegation[0].name == "Microsoft.App/environments"
+    error_message = "The Container App subnet should be delegated to Container Apps environments"
+  }
+
+  assert {
+    condition     = length(azurerm_firewall.egress) == 0 && length(azurerm_route_table.egress) == 0
+    error_message = "The egress firewall should be opt-in"
+  }
+}
+
+run "egress_firewall" {
+  command = plan
+
+  variables {
+    egress_firewall_enabled = true
+  }
+
+  assert {
+    condition     = length(azurerm_firewall.egress) == 1 && length(azurerm_subnet_route_table_association.container_app) == 1
+    error_message = "The egress firewall should be created and the Container App subnet routed through it"
+  }
+
+  assert {
+    condition     = azurerm_route_table.egress[0].route[0].address_prefix == "0.0.0.0/0"
+    error_message = "All Container App egress should go through the firewall"
+  }
+}
+
+run "rejects_wildcard_egress" {
+  command = plan
+
+  variables {
+    egress_allowed_fqdns = ["*"]
+  }
+
+  expect_failures = [var.egress_allowed_fqdns]
+}
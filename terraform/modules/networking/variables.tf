@@ -40,6 +40,55 @@ variable "container_app_subnet_cidr" {
   default     = "10.0.2.0/23"
 }
 
+variable "egress_firewall_enabled" {
+  description = "Route the Container App subnet's outbound traffic through an Azure Firewall that only allows egress_allowed_fqdns"
+  type        = bool
+  default     = false
+}
+
+variable "firewall_subnet_cidr" {
+  description = "CIDR block for AzureFirewallSubnet when egress_firewall_enabled is true. Azure requires /26 or larger."
+  type        = string
+  default     = "10.0.5.0/26"
+}
+
+# The platform, registry, Key Vault and Application Insights endpoints the
+# Container Apps need, over HTTPS only. Add to it rather than widening it
+variable "egress_allowed_fqdns" {
+  description = "FQDNs the Container App subnet may reach over HTTPS through the egress firewall; wildcards like *.vault.azure.net are allowed"
+  type        = list(string)
+  default = [
+    # Container Apps platform images
+    "mcr.microsoft.com",
+    "*.data.mcr.microsoft.com",
+    # Managed identity tokens
+    "login.microsoftonline.com",
+    "*.login.microsoftonline.com",
+    "*.login.microsoft.com",
+    "*.identity.azure.net",
+    # Azure Container Registry login servers and layer storage
+    "*.azurecr.io",
+    "*.blob.core.windows.net",
+    # Key Vault
+    "*.vault.azure.net",
+    # Application Insights ingestion
+    "dc.services.visualstudio.com",
+    "*.in.applicationinsights.azure.com",
+  ]
+
+  validation {
+    condition     = length(var.egress_allowed_fqdns) > 0
+    error_message = "egress_allowed_fqdns must list at least one FQDN; the Container Apps platform cannot start without egress."
+  }
+
+  validation {
+    condition = alltrue([
+      for fqdn in var.egress_allowed_fqdns : can(regex("^(\\*\\.)?([a-z0-9-]+\\.)+[a-z]{2,}$", fqdn))
+    ])
+    error_message = "egress_allowed_fqdns entries must be lowercase host names, optionally starting with \"*.\", without scheme, port or path; a bare \"*\" would allow all egress."
+  }
+}
+
 variable "tags" {
   description = "Tags to apply to all networking resources"
   type        = map(string)
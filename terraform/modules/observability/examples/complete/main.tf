@@ -3,7 +3,7 @@
 
 # First, create a resource group
 module "resource_group" {
-  source = "../../resource-group"
+  source = "../../../resource-group"
 
   name     = "rg-obs-example"
   location = "eastus2"
@@ -195,3 +195,200 @@ resource "azurerm_application_insights_standard_web_test" "health" {
   # Resource tags for organization and cost management
   tags = var.tags
 }
+
+#------------------------------------------------------------------------------
+# Resource Health Alert (Optional)
+#------------------------------------------------------------------------------
+# Activity log alert on Resource Health events of every resource of
+# alert_resource_types within alert_scopes. Scoping by resource group and
+# type instead of listing resources means apps added later are covered
+# without changing this module. Created only when alert_scopes is not empty.
+# The action group is shared with the ingestion alerts below.
+#------------------------------------------------------------------------------
+locals {
+  action_group_enabled = length(var.alert_scopes) > 0 || var.ingestion_alerts_enabled
+}
+
+resource "azurerm_monitor_action_group" "alerts" {
+  count = local.action_group_enabled ? 1 : 0
+
+  name                = "ag-${var.app_insights_name}"
+  resource_group_name = var.resource_group_name
+
+  # Shown in notifications; at most 12 characters
+  short_name = substr(replace(var.app_insights_name, "-", ""), 0, 12)
+
+  dynamic "email_receiver" {
+    for_each = var.alert_email_receivers
+    content {
+      name                    = "email-${email_receiver.key}"
+      email_address           = email_receiver.value
+      use_common_alert_schema = true
+    }
+  }
+
+  dynamic "webhook_receiver" {
+    for_each = var.alert_webhook_receivers
+    content {
+      name                    = "webhook-${webhook_receiver.key}"
+      service_uri             = webhook_receiver.value
+      use_common_alert_schema = true
+    }
+  }
+
+  # Resource tags for organization and cost management
+  tags = var.tags
+}
+
+resource "azurerm_monitor_activity_log_alert" "resource_health" {
+  count = length(var.alert_scopes) > 0 ? 1 : 0
+
+  name                = "alert-health-${var.app_insights_name}"
+  resource_group_name = var.resource_group_name
+
+  # Activity log alerts are global resources
+  location    = "global"
+  scopes      = var.alert_scopes
+  description = "Resource Health of ${join(", ", var.alert_resource_types)} left Available"
+
+  criteria {
+    category       = "ResourceHealth"
+    resource_types = var.alert_resource_types
+
+    # Fire when a resource becomes unavailable or degraded, not on recovery
+    resource_health {
+      current  = ["Unavailable", "Degraded"]
+      previous = ["Available"]
+    }
+  }
+
+  action {
+    action_group_id = azurerm_monitor_action_group.alerts[0].id
+  }
+
+  # Resource tags for organization and cost management
+  tags = var.tags
+}
+
+#------------------------------------------------------------------------------
+# Ingestion Alerts (Optional)
+#------------------------------------------------------------------------------
+# Log search alerts guarding the workspace's cost. The daily cap alert fires
+# once the cap stops data collection, which is when telemetry starts going
+# missing; the threshold alert fires before that, at a share of the cap; the
+# anomaly alert catches a sudden flood whether or not there is a cap.
+# Billable ingestion is read from the Usage table, which reports MB.
+# Created only when ingestion_alerts_enabled is true.
+#------------------------------------------------------------------------------
+locals {
+  daily_cap_alerts_enabled = var.ingestion_alerts_enabled && var.log_analytics_daily_quota_gb != null
+
+  ingestion_threshold_mb = local.daily_cap_alerts_enabled ? var.log_analytics_daily_quota_gb * 1000 * var.ingestion_alert_threshold_percent / 100 : null
+}
+
+resource "azurerm_monitor_scheduled_query_rules_alert_v2" "daily_cap" {
+  count = local.daily_cap_alerts_enabled ? 1 : 0
+
+  name                = "alert-cap-${var.log_analytics_name}"
+  resource_group_name = var.resource_group_name
+  location            = var.location
+  description         = "Log Analytics workspace ${var.log_analytics_name} reached its daily cap of ${var.log_analytics_daily_quota_gb} GB and stopped collecting data"
+  severity            = 1
+
+  scopes               = [azurerm_log_analytics_workspace.this.id]
+  evaluation_frequency = "PT5M"
+  window_duration      = "PT15M"
+
+  criteria {
+    time_aggregation_method = "Count"
+    operator                = "GreaterThan"
+    threshold               = 0
+
+    # Log Analytics records an OverQuota operation when the cap is reached
+    query = <<-QUERY
+      _LogOperation
+      | where Category =~ "Ingestion"
+      | where Detail has "OverQuota"
+    QUERY
+  }
+
+  action {
+    action_groups = [azurerm_monitor_action_group.alerts[0].id]
+  }
+
+  # Resource tags for organization and cost management
+  tags = var.tags
+}
+
+resource "azurerm_monitor_scheduled_query_rules_alert_v2" "ingestion_threshold" {
+  count = local.daily_cap_alerts_enabled ? 1 : 0
+
+  name                = "alert-ingest-${var.log_analytics_name}"
+  resource_group_name = var.resource_group_name
+  location            = var.location
+  description         = "Log Analytics workspace ${var.log_analytics_name} ingested ${var.ingestion_alert_threshold_percent}% of its daily cap of ${var.log_analytics_daily_quota_gb} GB today"
+  severity            = 2
+
+  scopes               = [azurerm_log_analytics_workspace.this.id]
+  evaluation_frequency = "PT1H"
+  window_duration      = "P1D"
+
+  criteria {
+    time_aggregation_method = "Count"
+    operator                = "GreaterThan"
+    threshold               = 0
+
+    query = <<-QUERY
+      Usage
+      | where TimeGenerated > startofday(now())
+      | where IsBillable
+      | summarize IngestedMB = sum(Quantity)
+      | where IngestedMB > ${local.ingestion_threshold_mb}
+    QUERY
+  }
+
+  action {
+    action_groups = [azurerm_monitor_action_group.alerts[0].id]
+  }
+
+  # Resource tags for organization and cost management
+  tags = var.tags
+}
+
+resource "azurerm_monitor_scheduled_query_rules_alert_v2" "ingestion_anomaly" {
+  count = var.ingestion_alerts_enabled ? 1 : 0
+
+  name                = "alert-anomaly-${var.log_analytics_name}"
+  resource_group_name = var.resource_group_name
+  location            = var.location
+  description         = "Log Analytics workspace ${var.log_analytics_name} ingested more than ${var.ingestion_anomaly_factor} times its average hourly volume in the last hour"
+  severity            = 2
+
+  scopes               = [azurerm_log_analytics_workspace.this.id]
+  evaluation_frequency = "PT1H"
+  window_duration      = "P2D"
+
+  criteria {
+    time_aggregation_method = "Count"
+    operator                = "GreaterThan"
+    threshold               = 0
+
+    # A new workspace has no baseline yet, so it never fires
+    query = <<-QUERY
+      let hourly = Usage
+      | where IsBillable
+      | summarize IngestedMB = sum(Quantity) by bin(TimeGenerated, 1h);
+      let baseline = toscalar(hourly | where TimeGenerated < ago(1h) | summarize avg(IngestedMB));
+      hourly
+      | where TimeGenerated >= ago(1h)
+      | where IngestedMB > ${var.ingestion_anomaly_factor} * baseline
+    QUERY
+  }
+
+  action {
+    action_groups = [azurerm_monitor_action_group.alerts[0].id]
+  }
+
+  # Resource tags for organization and cost management
+  tags = var.tags
+}
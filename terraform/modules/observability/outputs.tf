@@ -85,3 +85,51 @@ output "app_insights_app_id" {
   description = "The app ID for Application Insights"
   value       = azurerm_application_insights.this.app_id
 }
+
+#------------------------------------------------------------------------------
+# Alert Outputs
+#------------------------------------------------------------------------------
+
+# resource_health_alert_id - The activity log alert on Resource Health
+# Null when alert_scopes is empty
+output "resource_health_alert_id" {
+  description = "The ID of the Resource Health activity log alert (null when alert_scopes is empty)"
+  value       = try(azurerm_monitor_activity_log_alert.resource_health[0].id, null)
+}
+
+# alert_action_group_id - The action group notified by the alerts
+# Other alerts can reuse it
+output "alert_action_group_id" {
+  description = "The ID of the alert action group (null when alert_scopes is empty and ingestion_alerts_enabled is false)"
+  value       = try(azurerm_monitor_action_group.alerts[0].id, null)
+}
+
+# daily_cap_alert_id - The log search alert on the workspace's daily cap
+# Null without ingestion alerts or a daily quota
+output "daily_cap_alert_id" {
+  description = "The ID of the daily cap alert (null when ingestion_alerts_enabled is false or there is no daily quota)"
+  value       = try(azurerm_monitor_scheduled_query_rules_alert_v2.daily_cap[0].id, null)
+}
+
+# ingestion_threshold_alert_id - The alert on a share of the daily cap
+output "ingestion_threshold_alert_id" {
+  description = "The ID of the ingestion threshold alert (null when ingestion_alerts_enabled is false or there is no daily quota)"
+  value       = try(azurerm_monitor_scheduled_query_rules_alert_v2.ingestion_threshold[0].id, null)
+}
+
+# ingestion_anomaly_alert_id - The alert on an unusual hour of ingestion
+output "ingestion_anomaly_alert_id" {
+  description = "The ID of the ingestion anomaly alert (null when ingestion_alerts_enabled is false)"
+  value       = try(azurerm_monitor_scheduled_query_rules_alert_v2.ingestion_anomaly[0].id, null)
+}
+
+#------------------------------------------------------------------------------
+# Availability Test Outputs
+#------------------------------------------------------------------------------
+
+# availability_test_name - The standard web test name
+# Results in AppAvailabilityResults carry it in their Name column
+output "availability_test_name" {
+  description = "The name of the availability test (null when create_availability_test is false)"
+  value       = try(azurerm_application_insights_standard_web_test.health[0].name, null)
+}
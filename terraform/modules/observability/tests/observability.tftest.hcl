@@ -0,0 +1,145 @@
+#------------------------------------------------------------------------------
+# Observability Module - Native Tests
+#------------------------------------------------------------------------------
+# Plan-only assertions against a mocked provider, so they need no Azure
+# credentials. Run with `terraform test` from the module folder; the Go suite
+# runs them through TestModuleNativeTerraformTests.
+#------------------------------------------------------------------------------
+
+mock_provider "azurerm" {}
+
+variables {
+  resource_group_name = "rg-tftest-dev"
+  location            = "eastus2"
+  log_analytics_name  = "log-tftest-dev"
+  app_insights_name   = "appi-tftest-dev"
+}
+
+run "defaults" {
+  command = plan
+
+  assert {
+    condition     = azurerm_log_analytics_workspace.this.sku == "PerGB2018" && azurerm_log_analytics_workspace.this.retention_in_days == 30
+    error_message = "Log Analytics should default to PerGB2018 with 30 days of retention"
+  }
+
+  assert {
+    condition     = azurerm_application_insights.this.application_type == "web" && azurerm_application_insights.this.sampling_percentage == 100
+    error_message = "Application Insights should default to a web app without sampling"
+  }
+
+  assert {
+    condition     = length(azurerm_application_insights_standard_web_test.health) == 0
+    error_message = "The availability test should be opt-in"
+  }
+
+  assert {
+    condition     = length(azurerm_monitor_action_group.alerts) == 0 && length(azurerm_monitor_activity_log_alert.resource_health) == 0
+    error_message = "Resource health alerts should only be created for alert scopes"
+  }
+}
+
+run "resource_health_alerts" {
+  command = plan
+
+  variables {
+    alert_scopes = ["/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev"]
+  }
+
+  assert {
+    condition     = length(azurerm_monitor_action_group.alerts) == 1 && length(azurerm_monitor_activity_log_alert.resource_health) == 1
+    error_message = "Alert scopes should create the action group and resource health alert"
+  }
+}
+
+run "rejects_short_retention" {
+  command = plan
+
+  variables {
+    log_analytics_retention_days = 5
+  }
+
+  expect_failures = [var.log_analytics_retention_days]
+}
+
+run "rejects_invalid_alert_scope" {
+  command = plan
+
+  variables {
+    alert_scopes = ["rg-tftest-dev"]
+  }
+
+  expect_failures = [var.alert_scopes]
+}
+
+run "availability_test_locations" {
+  command = plan
+
+  variables {
+    create_availability_test = true
+    health_check_url         = "https://app.example.com/health"
+    test_locations           = ["us-va-ash-azr", "emea-nl-ams-azr", "apac-sg-sin-azr"]
+  }
+
+  assert {
+    condition     = length(azurerm_application_insights_standard_web_test.health[0].geo_locations) == 3
+    error_message = "The availability test should run from every test location"
+  }
+}
+
+run "rejects_unknown_test_location" {
+  command = plan
+
+  variables {
+    test_locations = ["us-va-ash-azr", "eastus"]
+  }
+
+  expect_failures = [var.test_locations]
+}
+
+run "ingestion_alerts" {
+  command = plan
+
+  variables {
+    log_analytics_daily_quota_gb = 2
+    ingestion_alerts_enabled     = true
+  }
+
+  assert {
+    condition     = length(azurerm_monitor_action_group.alerts) == 1 && length(azurerm_monitor_activity_log_alert.resource_health) == 0
+    error_message = "Ingestion alerts should create the action group without a resource health alert"
+  }
+
+  assert {
+    condition     = length(azurerm_monitor_scheduled_query_rules_alert_v2.daily_cap) == 1 && length(azurerm_monitor_scheduled_query_rules_alert_v2.ingestion_threshold) == 1 && length(azurerm_monitor_scheduled_query_rules_alert_v2.ingestion_anomaly) == 1
+    error_message = "A daily quota with ingestion alerts should create the cap, threshold and anomaly alerts"
+  }
+
+  assert {
+    condition     = strcontains(azurerm_monitor_scheduled_query_rules_alert_v2.ingestion_threshold[0].criteria[0].query, "IngestedMB > 1600")
+    error_message = "The threshold alert should fire at 80% of a 2 GB quota, 1600 MB"
+  }
+}
+
+run "ingestion_alerts_without_quota" {
+  command = plan
+
+  variables {
+    ingestion_alerts_enabled = true
+  }
+
+  assert {
+    condition     = length(azurerm_monitor_scheduled_query_rules_alert_v2.daily_cap) == 0 && length(azurerm_monitor_scheduled_query_rules_alert_v2.ingestion_threshold) == 0 && length(azurerm_monitor_scheduled_query_rules_alert_v2.ingestion_anomaly) == 1
+    error_message = "Without a daily quota only the anomaly alert applies"
+  }
+}
+
+run "rejects_ingestion_threshold_over_100" {
+  command = plan
+
+  variables {
+    ingestion_alert_threshold_percent = 120
+  }
+
+  expect_failures = [var.ingestion_alert_threshold_percent]
+}
@@ -78,6 +78,12 @@ variable "log_analytics_daily_quota_gb" {
   description = "Daily ingestion quota in GB (null for unlimited)"
   type        = number
   default     = null
+
+  # 0.023 GB is the smallest cap Log Analytics accepts
+  validation {
+    condition     = var.log_analytics_daily_quota_gb == null ? true : var.log_analytics_daily_quota_gb >= 0.023
+    error_message = "Daily quota must be at least 0.023 GB, or null for unlimited"
+  }
 }
 
 #------------------------------------------------------------------------------
@@ -205,6 +211,27 @@ variable "test_locations" {
     "us-va-ash-azr", # US East (Ashburn, VA)
     "us-ca-sjc-azr", # US West (San Jose, CA)
   ]
+
+  # The codes Application Insights accepts as standard web test locations,
+  # see the module README for the region each one is in
+  validation {
+    condition = alltrue([for location in var.test_locations : contains([
+      "us-va-ash-azr", "us-ca-sjc-azr", "us-tx-sn1-azr", "us-il-ch1-azr", "us-fl-mia-edge",
+      "emea-nl-ams-azr", "emea-gb-db3-azr", "emea-ru-msa-edge", "emea-se-sto-edge", "emea-fr-pra-edge", "emea-ch-zrh-edge",
+      "apac-jp-kaw-edge", "apac-sg-sin-azr", "apac-hk-hkn-azr", "latam-br-gru-edge", "emea-au-syd-edge",
+    ], location)])
+    error_message = "Test locations must be availability test location codes such as us-va-ash-azr; see the module README for the full list"
+  }
+
+  validation {
+    condition     = length(var.test_locations) >= 1 && length(var.test_locations) <= 16
+    error_message = "Test locations must list between 1 and 16 locations"
+  }
+
+  validation {
+    condition     = length(distinct(var.test_locations)) == length(var.test_locations)
+    error_message = "Test locations must not contain duplicates"
+  }
 }
 
 # health_check_headers - HTTP headers for health check requests
@@ -214,3 +241,98 @@ variable "health_check_headers" {
   type        = map(string)
   default     = {}
 }
+
+#------------------------------------------------------------------------------
+# Alert Configuration
+#------------------------------------------------------------------------------
+
+# alert_scopes - Resource groups (or subscriptions) watched by the alerts
+# Every resource of alert_resource_types inside a scope is covered, including
+# resources created later, so new apps need no change to this module
+variable "alert_scopes" {
+  description = "Resource group or subscription IDs whose resources of alert_resource_types are alerted on (empty = no alerts)"
+  type        = list(string)
+  default     = []
+
+  validation {
+    condition     = alltrue([for scope in var.alert_scopes : can(regex("^/subscriptions/[^/]+(/resourceGroups/[^/]+)?$", scope))])
+    error_message = "Alert scopes must be subscription or resource group IDs, not individual resources"
+  }
+
+  validation {
+    condition     = length(distinct([for scope in var.alert_scopes : lower(scope)])) == length(var.alert_scopes)
+    error_message = "Alert scopes must not contain duplicates"
+  }
+}
+
+# alert_resource_types - Resource types covered within alert_scopes
+variable "alert_resource_types" {
+  description = "Resource types alerted on within alert_scopes"
+  type        = list(string)
+  default     = ["Microsoft.App/containerApps"]
+
+  validation {
+    condition     = length(var.alert_resource_types) > 0 && alltrue([for resource_type in var.alert_resource_types : can(regex("^[A-Za-z]+\\.[A-Za-z]+(/[A-Za-z]+)+$", resource_type))])
+    error_message = "Alert resource types must be a non-empty list of types such as Microsoft.App/containerApps"
+  }
+}
+
+# alert_email_receivers - Who is notified when an alert fires
+variable "alert_email_receivers" {
+  description = "Email addresses notified when an alert fires"
+  type        = list(string)
+  default     = []
+}
+
+# alert_webhook_receivers - Webhooks called when an alert fires
+# Receivers get the common alert schema, keyed by a short name
+variable "alert_webhook_receivers" {
+  description = "Webhook URLs called when an alert fires, by receiver name"
+  type        = map(string)
+  default     = {}
+
+  validation {
+    condition     = alltrue([for uri in values(var.alert_webhook_receivers) : can(regex("^https://", uri))])
+    error_message = "Alert webhook receivers must be https:// URLs"
+  }
+}
+
+#------------------------------------------------------------------------------
+# Ingestion Alert Configuration
+#------------------------------------------------------------------------------
+
+# ingestion_alerts_enabled - Alert on the workspace's ingestion volume
+# Log search alerts on the workspace: the daily cap was reached, ingestion
+# passed ingestion_alert_threshold_percent of the cap (both only with
+# log_analytics_daily_quota_gb set), and an hour ingested far more than usual
+variable "ingestion_alerts_enabled" {
+  description = "Create alerts on the workspace's daily cap and ingestion volume"
+  type        = bool
+  default     = false
+}
+
+# ingestion_alert_threshold_percent - When the approaching-cap alert fires
+variable "ingestion_alert_threshold_percent" {
+  description = "Percentage of the daily quota ingested in a day that fires the ingestion threshold alert (1-100)"
+  type        = number
+  default     = 80
+
+  validation {
+    condition     = var.ingestion_alert_threshold_percent >= 1 && var.ingestion_alert_threshold_percent <= 100
+    error_message = "Ingestion alert threshold must be between 1 and 100 percent of the daily quota"
+  }
+}
+
+# ingestion_anomaly_factor - When the ingestion anomaly alert fires
+# An hour ingesting more than this many times the hourly average of the
+# previous two days is an anomaly
+variable "ingestion_anomaly_factor" {
+  description = "Multiple of the average hourly ingestion that fires the ingestion anomaly alert (greater than 1, at most 100)"
+  type        = number
+  default     = 3
+
+  validation {
+    condition     = var.ingestion_anomaly_factor > 1 && var.ingestion_anomaly_factor <= 100
+    error_message = "Ingestion anomaly factor must be greater than 1 and at most 100"
+  }
+}
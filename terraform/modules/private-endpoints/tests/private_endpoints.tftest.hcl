@@ -0,0 +1,52 @@
+#------------------------------------------------------------------------------
+# Private Endpoints Module - Native Tests
+#------------------------------------------------------------------------------
+# Plan-only assertions against a mocked provider, so they need no Azure
+# credentials. Run with `terraform test` from the module folder; the Go suite
+# runs them through TestModuleNativeTerraformTests.
+#------------------------------------------------------------------------------
+
+mock_provider "azurerm" {}
+
+variables {
+  resource_group_name        = "rg-tftest-dev"
+  location                   = "eastus2"
+  environment                = "dev"
+  vnet_id                    = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.Network/virtualNetworks/vnet-tftest-dev"
+  private_endpoint_subnet_id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.Network/virtualNetworks/vnet-tftest-dev/subnets/snet-private-endpoints"
+  key_vault_id               = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.KeyVault/vaults/kv-tftest-dev"
+  container_registry_id      = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-tftest-dev/providers/Microsoft.ContainerRegistry/registries/acrtftestdev"
+}
+
+run "canonical_dns_zones" {
+  command = plan
+
+  assert {
+    condition     = azurerm_private_dns_zone.keyvault.name == "privatelink.vaultcore.azure.net" && azurerm_private_dns_zone.acr.name == "privatelink.azurecr.io"
+    error_message = "Private DNS zones should use the canonical privatelink zone names"
+  }
+
+  assert {
+    condition     = !azurerm_private_dns_zone_virtual_network_link.keyvault.registration_enabled && !azurerm_private_dns_zone_virtual_network_link.acr.registration_enabled
+    error_message = "DNS zone links should not auto-register VM hostnames"
+  }
+}
+
+run "endpoints" {
+  command = plan
+
+  assert {
+    condition     = azurerm_private_endpoint.keyvault.private_service_connection[0].subresource_names[0] == "vault"
+    error_message = "The Key Vault endpoint should connect the vault sub-resource"
+  }
+
+  assert {
+    condition     = azurerm_private_endpoint.acr.private_service_connection[0].subresource_names[0] == "registry"
+    error_message = "The registry endpoint should connect the registry sub-resource"
+  }
+
+  assert {
+    condition     = azurerm_private_endpoint.keyvault.subnet_id == var.private_endpoint_subnet_id && azurerm_private_endpoint.acr.subnet_id == var.private_endpoint_subnet_id
+    error_message = "Both endpoints should be placed in the private endpoint subnet"
+  }
+}
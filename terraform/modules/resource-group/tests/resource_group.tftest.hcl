@@ -0,0 +1,49 @@
+#------------------------------------------------------------------------------
+# Azure Resource Group Module - Native Tests
+#------------------------------------------------------------------------------
+# Plan-only assertions against a mocked provider, so they need no Azure
+# credentials. Run with `terraform test` from the module folder; the Go suite
+# runs them through TestModuleNativeTerraformTests.
+#------------------------------------------------------------------------------
+
+mock_provider "azurerm" {}
+
+variables {
+  name     = "rg-tftest-dev"
+  location = "eastus2"
+  tags     = { Environment = "dev" }
+}
+
+run "creates_resource_group" {
+  command = plan
+
+  assert {
+    condition     = azurerm_resource_group.this.name == "rg-tftest-dev"
+    error_message = "Resource group should use the given name"
+  }
+
+  assert {
+    condition     = azurerm_resource_group.this.tags["Environment"] == "dev"
+    error_message = "Resource group should carry the given tags"
+  }
+}
+
+run "rejects_name_without_prefix" {
+  command = plan
+
+  variables {
+    name = "tftest-dev"
+  }
+
+  expect_failures = [var.name]
+}
+
+run "rejects_unapproved_location" {
+  command = plan
+
+  variables {
+    location = "westeurope"
+  }
+
+  expect_failures = [var.location]
+}
@@ -0,0 +1,142 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// appInsightsEnvVar is where the app expects its connection string
+const appInsightsEnvVar = "APPLICATIONINSIGHTS_CONNECTION_STRING"
+
+// TestAppInsightsConnectionStringSensitive checks that the observability
+// module only hands out its credentials as sensitive outputs, so a
+// composition passing them on cannot print them by accident
+func TestAppInsightsConnectionStringSensitive(t *testing.T) {
+	t.Parallel()
+
+	sensitive, err := helpers.SensitiveOutputsE("../modules/observability")
+	if err != nil {
+		t.Fatalf("Reading observability outputs: %v", err)
+	}
+	for _, output := range []string{"app_insights_connection_string", "app_insights_instrumentation_key", "log_analytics_primary_shared_key"} {
+		assert.True(t, sensitive[output], "%s should be a sensitive output", output)
+	}
+}
+
+// containerAppSecretConfig is the part of `az containerapp show` describing
+// how the app gets its secrets
+type containerAppSecretConfig struct {
+	Secrets []struct {
+		Name        string `json:"name"`
+		KeyVaultURL string `json:"keyVaultUrl"`
+		Identity    string `json:"identity"`
+	} `json:"secrets"`
+	Env []struct {
+		Name      string `json:"name"`
+		Value     string `json:"value"`
+		SecretRef string `json:"secretRef"`
+	} `json:"env"`
+}
+
+// TestAppInsightsConnectionStringKeyVaultHandoff deploys the composition that
+// writes the App Insights connection string into Key Vault and has the echo
+// fixture app read it through a Key Vault reference. The secret must exist
+// with the connection string, no output of the composition may carry it, the
+// app must reference the vault secret rather than hold the value, and the
+// value must reach the container
+func TestAppInsightsConnectionStringKeyVaultHandoff(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name": config.GenerateResourceGroupName("ai-secret"),
+			"location":            config.Location,
+			"name_suffix":         config.UniqueID,
+			"tags":                helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/app-insights-secret", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	// First apply creates the vault, its secret and the registry; the app
+	// follows once the echo image is in the registry
+	phases.Start("apply")
+	helpers.DeployWithRegionFallback(t, config, terraformOptions, vars, func() error {
+		if _, err := terraform.InitAndApplyE(t, terraformOptions); err != nil {
+			return err
+		}
+		phases.Start("build")
+		image := helpers.BuildFixtureImage(t, "echo", terraform.Output(t, terraformOptions, "registry_login_server"))
+		terraformOptions.Vars["container_image"] = image.Reference
+
+		phases.Start("apply")
+		_, err := terraform.ApplyE(t, terraformOptions)
+		return err
+	})
+	phases.Start("verify")
+
+	var connectionString string
+	helpers.AzCLIJSON(t, &connectionString, "keyvault", "secret", "show",
+		"--vault-name", terraform.Output(t, terraformOptions, "key_vault_name"),
+		"--name", terraform.Output(t, terraformOptions, "secret_name"),
+		"--query", "value")
+	if !helpers.AssertAppInsightsConnectionString(t, connectionString, "") {
+		t.Fatal("The vault secret should hold an App Insights connection string")
+	}
+
+	outputs := terraform.OutputAll(t, terraformOptions)
+	for name, value := range outputs {
+		// Compared without assert.Contains, which would print the secret
+		assert.False(t, strings.Contains(fmt.Sprint(value), connectionString), "output %s exposes the connection string", name)
+	}
+
+	var app containerAppSecretConfig
+	helpers.AzCLIJSON(t, &app, "containerapp", "show",
+		"--resource-group", terraform.Output(t, terraformOptions, "resource_group_name"),
+		"--name", terraform.Output(t, terraformOptions, "container_app_name"),
+		"--query", "{secrets: properties.configuration.secrets, env: properties.template.containers[0].env}")
+
+	secretRef := ""
+	for _, env := range app.Env {
+		if env.Name == appInsightsEnvVar {
+			assert.True(t, env.Value == "", "%s should not be a plain app setting", appInsightsEnvVar)
+			secretRef = env.SecretRef
+		}
+	}
+	if assert.NotEmpty(t, secretRef, "%s should reference an app secret", appInsightsEnvVar) {
+		referenced := false
+		for _, secret := range app.Secrets {
+			if secret.Name == secretRef {
+				referenced = true
+				assert.Equal(t, terraform.Output(t, terraformOptions, "secret_versionless_id"), secret.KeyVaultURL,
+					"app secret %s should be a reference to the vault secret", secretRef)
+				assert.True(t, strings.EqualFold(terraform.Output(t, terraformOptions, "secrets_identity_id"), secret.Identity),
+					"app secret %s should be resolved with the user-assigned identity, got %q", secretRef, secret.Identity)
+			}
+		}
+		assert.True(t, referenced, "app secret %s is not defined", secretRef)
+	}
+
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+	environment := envFromApp(t, applicationURL, appInsightsEnvVar)
+	assert.True(t, environment[appInsightsEnvVar] == connectionString,
+		"the container should see the vault's connection string in %s", appInsightsEnvVar)
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+)
+
+// whatIfModules are the modules compared by default; ARM_WHAT_IF_MODULES
+// overrides them with a comma-separated list
+var whatIfModules = []string{"container-registry", "key-vault", "networking", "observability"}
+
+// whatIfReport is the what_if report entry of one module
+type whatIfReport struct {
+	Baseline  []helpers.WhatIfDivergence `json:"baseline"`
+	TagChange []helpers.WhatIfDivergence `json:"tag_change"`
+}
+
+// TestARMWhatIfMatchesPlan deploys each selected module, exports its
+// resource group as an ARM template and runs ARM What-If on it twice: once
+// unchanged, against a plan that should change nothing, and once with the
+// tags of the resources terraform plans to retag, against that plan. Every
+// resource the two disagree on is a place where the provider and ARM see
+// the resource differently, which tends to surface as drift or a surprise
+// at apply time
+func TestARMWhatIfMatchesPlan(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_ARM_WHAT_IF") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_ARM_WHAT_IF=true to compare ARM What-If with terraform plans")
+	}
+
+	modules := whatIfModules
+	if selected := os.Getenv("ARM_WHAT_IF_MODULES"); selected != "" {
+		modules = strings.Split(selected, ",")
+	}
+
+	for _, module := range modules {
+		module := strings.TrimSpace(module)
+		t.Run(module, func(t *testing.T) {
+			t.Parallel()
+
+			config := helpers.NewTestConfig(t)
+			cost.TrackRun(t, config)
+			tags := helpers.StandardTags(t.Name())
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/what-if", map[string]interface{}{
+				"resource_group_name": config.GenerateResourceGroupName("wi"),
+				"location":            config.Location,
+				"module":              module,
+				"name_suffix":         config.UniqueID,
+				"tags":                tags,
+				"resource_group_tags": tags,
+			})
+			helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+			phases := helpers.TrackPhases(t)
+			helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+			defer helpers.Destroy(t, terraformOptions)
+			defer phases.Start("destroy")
+			phases.Start("apply")
+			helpers.InitAndApply(t, terraformOptions)
+			phases.Start("verify")
+
+			resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+			template, err := helpers.ExportResourceGroupTemplateE(t, resourceGroupName)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			report := whatIfReport{}
+			defer func() { helpers.RecordReport(t, "what_if", module, report) }()
+
+			// The exported template describes what is deployed, so neither
+			// side should change anything
+			baselineOptions := *terraformOptions
+			baselineOptions.PlanFilePath = filepath.Join(terraformOptions.TerraformDir, "baseline.tfplan")
+			baselinePlan := helpers.InitAndPlanAndShow(t, &baselineOptions)
+			report.Baseline = compareWhatIf(t, resourceGroupName, baselinePlan, template)
+			for _, divergence := range report.Baseline {
+				t.Errorf("Unchanged configuration: %s", divergence)
+			}
+
+			// A tag change, written into the template the way terraform
+			// plans it
+			updatedTags := map[string]interface{}{"CostCenter": "platform-tests"}
+			for key, value := range tags {
+				updatedTags[key] = value
+			}
+			updatedTags["Environment"] = "test-retagged"
+
+			updatedVars := map[string]interface{}{}
+			for key, value := range terraformOptions.Vars {
+				updatedVars[key] = value
+			}
+			updatedVars["tags"] = updatedTags
+
+			updatedOptions := *terraformOptions
+			updatedOptions.Vars = updatedVars
+			updatedOptions.PlanFilePath = filepath.Join(terraformOptions.TerraformDir, "tags.tfplan")
+			tagPlan := helpers.InitAndPlanAndShow(t, &updatedOptions)
+
+			plannedTags, err := helpers.PlannedTagsE(tagPlan)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changed, err := helpers.SetTemplateTagsE(template, config.SubscriptionID, resourceGroupName, plannedTags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed == 0 {
+				t.Fatalf("None of the %d resources terraform retags is in the exported template", len(plannedTags))
+			}
+			report.TagChange = compareWhatIf(t, resourceGroupName, tagPlan, template)
+			for _, divergence := range report.TagChange {
+				t.Errorf("Tag change: %s", divergence)
+			}
+		})
+	}
+}
+
+// compareWhatIf runs What-If for template and returns where it disagrees
+// with planJSON
+func compareWhatIf(t *testing.T, resourceGroupName, planJSON string, template map[string]interface{}) []helpers.WhatIfDivergence {
+	whatIf, err := helpers.WhatIfE(t, resourceGroupName, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	divergences, err := helpers.CompareWhatIfE(planJSON, whatIf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return divergences
+}
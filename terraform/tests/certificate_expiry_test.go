@@ -0,0 +1,99 @@
+package test
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// defaultCertificateExpiryDays is how far ahead TestCertificateExpiry looks
+// for expiring certificates
+const defaultCertificateExpiryDays = 30
+
+// certificateEndpointOutputs are the outputs of a long-lived environment
+// naming endpoints it serves over TLS
+var certificateEndpointOutputs = []string{
+	"container_app_url",
+	"container_app_fqdn",
+	"container_registry_login_server",
+	"key_vault_uri",
+}
+
+// TestCertificateExpiry is a sentinel for the long-lived environments: it
+// reads the endpoints and Key Vault of each from its state, and fails for
+// every TLS certificate an endpoint serves and every enabled Key Vault
+// certificate that expires within TEST_CERT_EXPIRY_DAYS. Every certificate
+// found lands in the certificate_expiry report. Opt in with
+// TEST_CERT_EXPIRY=true; the nightly drift pipeline runs it
+func TestCertificateExpiry(t *testing.T) {
+	t.Parallel()
+
+	if os.Getenv("TEST_CERT_EXPIRY") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_CERT_EXPIRY=true to check certificates of long-lived environments for expiry")
+	}
+
+	days := defaultCertificateExpiryDays
+	if value := os.Getenv("TEST_CERT_EXPIRY_DAYS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			t.Fatalf("Invalid TEST_CERT_EXPIRY_DAYS %q: %v", value, err)
+		}
+		days = parsed
+	}
+	within := time.Duration(days) * 24 * time.Hour
+
+	environments := []string{"dev"}
+	if selected := os.Getenv("TEST_CERT_EXPIRY_ENVIRONMENTS"); selected != "" {
+		environments = strings.Split(selected, ",")
+	}
+
+	for _, env := range environments {
+		env := strings.TrimSpace(env)
+		t.Run(env, func(t *testing.T) {
+			t.Parallel()
+
+			options := environmentOptions(t, env, "check its certificates for expiry")
+			if _, err := terraform.InitE(t, options); err != nil {
+				t.Fatalf("Initializing %s: %v", env, err)
+			}
+
+			var certs []helpers.CertificateExpiry
+			seen := map[string]bool{}
+			for _, output := range certificateEndpointOutputs {
+				endpoint, err := terraform.OutputE(t, options, output)
+				if err != nil || endpoint == "" {
+					t.Logf("%s has no %s output, not checking it", env, output)
+					continue
+				}
+				cert, err := helpers.EndpointCertificateExpiryE(endpoint)
+				if err != nil {
+					t.Errorf("Reading the certificate of %s: %v", endpoint, err)
+					continue
+				}
+				if !seen[cert.Source] {
+					seen[cert.Source] = true
+					certs = append(certs, *cert)
+				}
+			}
+
+			if vaultName, err := terraform.OutputE(t, options, "key_vault_name"); err != nil || vaultName == "" {
+				t.Logf("%s has no key_vault_name output, not checking Key Vault certificates", env)
+			} else if vaultCerts, err := helpers.KeyVaultCertificateExpiriesE(t, vaultName); err != nil {
+				t.Error(err)
+			} else {
+				certs = append(certs, vaultCerts...)
+			}
+
+			helpers.RecordReport(t, "certificate_expiry", env, certs)
+			for _, cert := range helpers.ExpiringCertificates(certs, time.Now(), within) {
+				t.Errorf("%s, within %d days", cert, days)
+			}
+			t.Logf("Checked %d certificates of %s", len(certs), env)
+		})
+	}
+}
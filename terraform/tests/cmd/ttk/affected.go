@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+func init() {
+	register(command{
+		name:    "affected",
+		summary: "list the tests affected by changed files",
+		run:     runAffected,
+	})
+}
+
+// sharedPaths affect every test when they change
+var sharedPaths = []string{"helpers", "go.mod", "go.sum", "testdata"}
+
+type affectedResult struct {
+	// Changed are the changed files, relative to the tests folder
+	Changed []string `json:"changed"`
+	// All is true when a shared file changed and every test is affected
+	All   bool     `json:"all"`
+	Tests []string `json:"tests"`
+	// RunPattern selects the affected tests with go test -run
+	RunPattern string `json:"run_pattern,omitempty"`
+}
+
+func (r affectedResult) writeText(w io.Writer) {
+	switch {
+	case r.All:
+		fmt.Fprintln(w, "Shared test code changed, every test is affected")
+	case len(r.Tests) == 0:
+		fmt.Fprintln(w, "No tests affected")
+		return
+	}
+	for _, test := range r.Tests {
+		fmt.Fprintln(w, test)
+	}
+	fmt.Fprintf(w, "\ngo test -run '%s'\n", r.RunPattern)
+}
+
+func runAffected(config *Config, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("affected", flag.ContinueOnError)
+	base := flags.String("base", "", "git revision to diff against instead of listing files")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	changed := flags.Args()
+	if *base != "" {
+		var err error
+		if changed, err = gitChangedFiles(config.TestsDir, *base); err != nil {
+			return nil, err
+		}
+	} else if len(changed) == 0 {
+		return nil, fmt.Errorf("%w: give changed files or -base", errUsage)
+	}
+
+	tests, err := findTestsE(config.TestsDir)
+	if err != nil {
+		return nil, err
+	}
+	relative := make([]string, 0, len(changed))
+	for _, file := range changed {
+		absolute, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		path, err := filepath.Rel(config.TestsDir, absolute)
+		if err != nil {
+			return nil, err
+		}
+		relative = append(relative, filepath.ToSlash(path))
+	}
+	return affectedTests(tests, relative), nil
+}
+
+// gitChangedFiles returns the absolute paths of the files changed since base
+func gitChangedFiles(dir, base string) ([]string, error) {
+	top, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("finding git root: %w", err)
+	}
+	output, err := exec.Command("git", "-C", dir, "diff", "--name-only", base).Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing changes since %s: %w", base, err)
+	}
+	var files []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			files = append(files, filepath.Join(strings.TrimSpace(string(top)), line))
+		}
+	}
+	return files, nil
+}
+
+// affectedTests returns the tests affected by changes to changed, paths
+// relative to the tests folder
+func affectedTests(tests []testInfo, changed []string) affectedResult {
+	result := affectedResult{Changed: changed, Tests: []string{}}
+	affected := map[string]bool{}
+	for _, file := range changed {
+		for _, shared := range sharedPaths {
+			if pathWithin(file, shared) {
+				result.All = true
+			}
+		}
+		for _, test := range tests {
+			if file == test.File {
+				affected[test.Name] = true
+			}
+			for _, path := range test.Paths {
+				if pathWithin(file, path) {
+					affected[test.Name] = true
+				}
+			}
+		}
+	}
+
+	for _, test := range tests {
+		if result.All || affected[test.Name] {
+			result.Tests = append(result.Tests, test.Name)
+		}
+	}
+	sort.Strings(result.Tests)
+	if len(result.Tests) > 0 {
+		quoted := make([]string, len(result.Tests))
+		for i, test := range result.Tests {
+			quoted[i] = regexp.QuoteMeta(test)
+		}
+		result.RunPattern = "^(" + strings.Join(quoted, "|") + ")$"
+	}
+	return result
+}
+
+// pathWithin reports whether file is path or inside it. path may be a glob,
+// matched against as many leading segments of file as it has
+func pathWithin(file, path string) bool {
+	if file == path || strings.HasPrefix(file, path+"/") {
+		return true
+	}
+	if !strings.ContainsAny(path, "*?[") {
+		return false
+	}
+	segments := strings.Split(file, "/")
+	count := strings.Count(path, "/") + 1
+	if len(segments) < count {
+		return false
+	}
+	matched, _ := filepath.Match(path, strings.Join(segments[:count], "/"))
+	return matched
+}
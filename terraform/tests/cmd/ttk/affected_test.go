@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAffectedTests(t *testing.T) {
+	t.Parallel()
+
+	tests := []testInfo{
+		{Name: "TestApp", File: "app_test.go", Paths: []string{"../modules/app", "fixtures/app"}},
+		{Name: "TestReadmes", File: "readme_test.go", Paths: []string{"../modules/*/README.md"}},
+		{Name: "TestVault", File: "vault_test.go", Paths: []string{"../modules/vault"}},
+	}
+
+	testCases := []struct {
+		name    string
+		changed []string
+		tests   []string
+		all     bool
+	}{
+		{"module file", []string{"../modules/app/main.tf"}, []string{"TestApp"}, false},
+		{"module readme", []string{"../modules/vault/README.md"}, []string{"TestReadmes", "TestVault"}, false},
+		{"fixture", []string{"fixtures/app/outputs.tf"}, []string{"TestApp"}, false},
+		{"similar module name", []string{"../modules/app-gateway/main.tf"}, []string{}, false},
+		{"test file", []string{"vault_test.go"}, []string{"TestVault"}, false},
+		{"helper", []string{"helpers/azure.go"}, []string{"TestApp", "TestReadmes", "TestVault"}, true},
+		{"unrelated", []string{"../../docs/README.md"}, []string{}, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := affectedTests(tests, tc.changed)
+			assert.Equal(t, tc.tests, result.Tests)
+			assert.Equal(t, tc.all, result.All)
+		})
+	}
+
+	assert.Equal(t, "^(TestApp)$", affectedTests(tests, []string{"fixtures/app/main.tf"}).RunPattern)
+}
+
+func TestPathWithin(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, pathWithin("../modules/app", "../modules/app"))
+	assert.True(t, pathWithin("../modules/app/main.tf", "../modules"))
+	assert.False(t, pathWithin("../modules-old/main.tf", "../modules"))
+	assert.True(t, pathWithin("../environments/dev/main.tf", "../environments/*/main.tf"))
+	assert.False(t, pathWithin("../environments/dev/variables.tf", "../environments/*/main.tf"))
+	assert.False(t, pathWithin("../environments", "../environments/*/main.tf"))
+}
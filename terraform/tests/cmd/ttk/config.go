@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// testsModulePath is the Go module of terraform/tests, used to find it
+const testsModulePath = "github.com/pollinate/risk-scoring-api/terraform/tests"
+
+// Config is the configuration shared by every command. It comes from the
+// same environment variables the tests read, so ttk sees what `go test` sees
+type Config struct {
+	// TestsDir is the terraform/tests folder
+	TestsDir string `json:"tests_dir"`
+	// TerraformRoot is the folder above it holding modules and environments
+	TerraformRoot  string `json:"terraform_root"`
+	SubscriptionID string `json:"subscription_id,omitempty"`
+	TenantID       string `json:"tenant_id,omitempty"`
+	Location       string `json:"location"`
+	RunID          string `json:"run_id,omitempty"`
+	// Namespace separates engineers sharing a subscription (see
+	// helpers.Namespace)
+	Namespace string `json:"namespace"`
+}
+
+// loadConfig reads the configuration. testsDir may be empty, in which case
+// the tests folder is searched from the working directory upwards, also
+// looking into terraform/tests below each folder
+func loadConfig(testsDir string) (*Config, error) {
+	if testsDir == "" {
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		if testsDir, err = findTestsDir(workingDir); err != nil {
+			return nil, err
+		}
+	}
+	testsDir, err := filepath.Abs(testsDir)
+	if err != nil {
+		return nil, err
+	}
+	if !isTestsDir(testsDir) {
+		return nil, fmt.Errorf("%s is not the terraform/tests folder (no go.mod for %s)", testsDir, testsModulePath)
+	}
+
+	return &Config{
+		TestsDir:       testsDir,
+		TerraformRoot:  filepath.Dir(testsDir),
+		SubscriptionID: os.Getenv("ARM_SUBSCRIPTION_ID"),
+		TenantID:       os.Getenv("ARM_TENANT_ID"),
+		Location:       getEnvOrDefault("ARM_LOCATION", "eastus2"),
+		RunID:          strings.ToLower(getEnvOrDefault("RESUME_RUN_ID", os.Getenv("TEST_RUN_ID"))),
+		Namespace:      helpers.Namespace(),
+	}, nil
+}
+
+// findTestsDir returns the tests folder containing dir, or found at
+// terraform/tests below dir or one of its parents
+func findTestsDir(dir string) (string, error) {
+	for current := dir; ; current = filepath.Dir(current) {
+		for _, candidate := range []string{current, filepath.Join(current, "terraform", "tests")} {
+			if isTestsDir(candidate) {
+				return candidate, nil
+			}
+		}
+		if filepath.Dir(current) == current {
+			return "", errors.New("terraform/tests not found from the working directory; use -dir")
+		}
+	}
+}
+
+// isTestsDir reports whether dir holds the go.mod of the tests module
+func isTestsDir(dir string) bool {
+	file, err := os.Open(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "module" {
+			return fields[1] == testsModulePath
+		}
+	}
+	return false
+}
+
+// getEnvOrDefault gets an environment variable or returns a default value
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
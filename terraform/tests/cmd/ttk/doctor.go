@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// minTerraformVersion is the oldest terraform the modules support
+const minTerraformVersion = "1.5.0"
+
+func init() {
+	register(command{
+		name:    "doctor",
+		summary: "check tools and Azure login needed to run the tests",
+		run:     runDoctor,
+	})
+}
+
+// check is the outcome of one doctor check: "ok", "warn" or "fail"
+type check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+type doctorResult struct {
+	Checks []check `json:"checks"`
+}
+
+func (r doctorResult) writeText(w io.Writer) {
+	for _, c := range r.Checks {
+		fmt.Fprintf(w, "%-4s  %-12s %s\n", c.Status, c.Name, c.Detail)
+	}
+}
+
+// optionalTools are only needed by opt-in tests or checks
+var optionalTools = []struct {
+	tool      string
+	args      []string
+	neededFor string
+}{
+	{"notation", []string{"version"}, "TEST_SUPPLY_CHAIN"},
+	{"oras", []string{"version"}, "TEST_SUPPLY_CHAIN"},
+	{"gosec", []string{"-version"}, "the security scan in run-tests.sh"},
+}
+
+func runDoctor(config *Config, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	result := doctorResult{Checks: []check{
+		commandCheck("go", "version"),
+		terraformCheck(),
+		commandCheck("az", "version", "--query", `"azure-cli"`, "--output", "tsv"),
+		azureLoginCheck(config),
+		azureAuthCheck(os.Getenv),
+	}}
+	for _, optional := range optionalTools {
+		c := commandCheck(optional.tool, optional.args...)
+		if c.Status == "fail" {
+			c.Status = "warn"
+			c.Detail += ", needed for " + optional.neededFor
+		}
+		result.Checks = append(result.Checks, c)
+	}
+
+	for _, c := range result.Checks {
+		if c.Status == "fail" {
+			return result, errFailed
+		}
+	}
+	return result, nil
+}
+
+// commandCheck runs a tool with args and reports the first line it prints
+func commandCheck(tool string, args ...string) check {
+	if _, err := exec.LookPath(tool); err != nil {
+		return check{Name: tool, Status: "fail", Detail: "not installed"}
+	}
+	output, err := exec.Command(tool, args...).Output()
+	if err != nil {
+		return check{Name: tool, Status: "fail", Detail: err.Error()}
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return check{Name: tool, Status: "ok", Detail: line}
+}
+
+// terraformCheck checks that terraform is at least minTerraformVersion
+func terraformCheck() check {
+	if _, err := exec.LookPath("terraform"); err != nil {
+		return check{Name: "terraform", Status: "fail", Detail: "not installed"}
+	}
+	output, err := exec.Command("terraform", "version", "-json").Output()
+	if err != nil {
+		return check{Name: "terraform", Status: "fail", Detail: err.Error()}
+	}
+	var version struct {
+		TerraformVersion string `json:"terraform_version"`
+	}
+	if err := json.Unmarshal(output, &version); err != nil {
+		return check{Name: "terraform", Status: "fail", Detail: fmt.Sprintf("reading version: %v", err)}
+	}
+	if compareVersions(version.TerraformVersion, minTerraformVersion) < 0 {
+		return check{Name: "terraform", Status: "fail",
+			Detail: fmt.Sprintf("v%s, the modules need >= %s", version.TerraformVersion, minTerraformVersion)}
+	}
+	return check{Name: "terraform", Status: "ok", Detail: "v" + version.TerraformVersion}
+}
+
+// azureLoginCheck checks that the Azure CLI is logged in, to the
+// subscription in ARM_SUBSCRIPTION_ID when that is set
+func azureLoginCheck(config *Config) check {
+	output, err := exec.Command("az", "account", "show", "--query", "{id: id, name: name}", "--output", "json").Output()
+	if err != nil {
+		return check{Name: "azure login", Status: "fail", Detail: "not logged in, run az login"}
+	}
+	var account struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(output, &account); err != nil {
+		return check{Name: "azure login", Status: "fail", Detail: fmt.Sprintf("reading account: %v", err)}
+	}
+	if config.SubscriptionID != "" && !strings.EqualFold(config.SubscriptionID, account.ID) {
+		return check{Name: "azure login", Status: "fail",
+			Detail: fmt.Sprintf("logged in to %s, but ARM_SUBSCRIPTION_ID is %s", account.ID, config.SubscriptionID)}
+	}
+	return check{Name: "azure login", Status: "ok", Detail: fmt.Sprintf("%s (%s)", account.Name, account.ID)}
+}
+
+// azureAuthCheck checks that the ARM_* variables read with getenv configure
+// the sign-in mode they ask for, as NewTestConfig will, and that a managed
+// identity can get a token
+func azureAuthCheck(getenv func(string) string) check {
+	mode, err := helpers.AuthModeFromEnvE(getenv)
+	if err != nil {
+		return check{Name: "azure auth", Status: "fail", Detail: err.Error()}
+	}
+	if mode == helpers.AuthManagedIdentity {
+		cloud, err := helpers.CloudByName(getenv("ARM_ENVIRONMENT"))
+		if err != nil {
+			return check{Name: "azure auth", Status: "fail", Detail: err.Error()}
+		}
+		if _, err := helpers.ManagedIdentityTokenE(getenv, cloud.ResourceManager); err != nil {
+			return check{Name: "azure auth", Status: "fail", Detail: err.Error()}
+		}
+	}
+	return check{Name: "azure auth", Status: "ok", Detail: string(mode)}
+}
+
+// compareVersions compares dotted numeric versions such as "1.5.7", ignoring
+// pre-release suffixes, and returns -1, 0 or 1
+func compareVersions(a, b string) int {
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var numberA, numberB int
+		if i < len(partsA) {
+			numberA, _ = strconv.Atoi(strings.SplitN(partsA[i], "-", 2)[0])
+		}
+		if i < len(partsB) {
+			numberB, _ = strconv.Atoi(strings.SplitN(partsB[i], "-", 2)[0])
+		}
+		switch {
+		case numberA < numberB:
+			return -1
+		case numberA > numberB:
+			return 1
+		}
+	}
+	return 0
+}
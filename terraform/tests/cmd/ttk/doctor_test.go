@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, compareVersions("1.5.0", "1.5.0"))
+	assert.Equal(t, 1, compareVersions("1.10.2", "1.5.0"))
+	assert.Equal(t, -1, compareVersions("1.4.7", "1.5.0"))
+	assert.Equal(t, 0, compareVersions("1.5", "1.5.0"))
+	assert.Equal(t, 0, compareVersions("1.5.0-beta1", "1.5.0"))
+}
+
+func TestAzureAuthCheck(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{"ARM_USE_OIDC": "true", "ARM_CLIENT_ID": "client", "ARM_TENANT_ID": "tenant", "ARM_OIDC_TOKEN": "token"}
+	getenv := func(name string) string { return env[name] }
+	assert.Equal(t, check{Name: "azure auth", Status: "ok", Detail: "oidc"}, azureAuthCheck(getenv))
+
+	env["ARM_CLIENT_SECRET"] = "secret"
+	assert.Equal(t, "fail", azureAuthCheck(getenv).Status, "a secret would be used before the federated token")
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+func init() {
+	register(command{
+		name:    "drift",
+		summary: "report drift of a long-lived environment from a refresh-only plan",
+		run:     runDrift,
+	})
+}
+
+// driftPlanFile is the refresh-only plan, in the copied environment
+const driftPlanFile = "drift.tfplan"
+
+// tfRunner runs a terraform command in dir and returns its stdout
+type tfRunner func(dir string, args ...string) ([]byte, error)
+
+// terraformCommand runs the installed terraform
+func terraformCommand(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("terraform", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return nil, fmt.Errorf("terraform %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return output, err
+}
+
+// driftResult is the drift report and the file it was written to
+type driftResult struct {
+	Path string `json:"path,omitempty"`
+	helpers.DriftReport
+}
+
+func (r driftResult) writeText(w io.Writer) {
+	for _, resource := range r.Resources {
+		fmt.Fprintln(w, resource)
+	}
+	if len(r.Resources) == 0 {
+		fmt.Fprintf(w, "No drift in %s\n", r.Environment)
+	} else {
+		fmt.Fprintf(w, "%d resources drifted in %s, %d in managed attributes\n",
+			len(r.Resources), r.Environment, len(r.ManagedDrift()))
+	}
+	if r.Path != "" {
+		fmt.Fprintf(w, "Written report to %s\n", r.Path)
+	}
+}
+
+// listFlag is a flag that may be given several times
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// runDrift runs a refresh-only plan against the state of an environment and
+// reports what changed in Azure since the last apply. It exits 1 when
+// attributes the configuration manages drifted, still printing the report
+func runDrift(config *Config, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("drift", flag.ContinueOnError)
+	env := flags.String("env", "dev", "environment under ../environments to check")
+	var backendConfig, varFiles listFlag
+	flags.Var(&backendConfig, "backend-config", "backend.hcl file or key=value, repeatable (default: the environment's backend.hcl)")
+	flags.Var(&varFiles, "var-file", "variables file, repeatable (default: the environment's terraform.tfvars if present)")
+	output := flags.String("o", "", "file to write the JSON report to")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	envDir := filepath.Join(config.TerraformRoot, "environments", *env)
+	if _, err := os.Stat(envDir); err != nil {
+		return nil, fmt.Errorf("%w: no environment %s", errUsage, envDir)
+	}
+	if len(backendConfig) == 0 {
+		defaultConfig := filepath.Join(envDir, "backend.hcl")
+		if _, err := os.Stat(defaultConfig); err != nil {
+			return nil, fmt.Errorf("%w: pass -backend-config or create %s from backend.hcl.example", errUsage, defaultConfig)
+		}
+		backendConfig = listFlag{defaultConfig}
+	}
+	if len(varFiles) == 0 {
+		if _, err := os.Stat(filepath.Join(envDir, "terraform.tfvars")); err == nil {
+			varFiles = listFlag{filepath.Join(envDir, "terraform.tfvars")}
+		}
+	}
+
+	report, err := environmentDriftE(terraformCommand, config.TerraformRoot, *env, backendConfig, varFiles)
+	if err != nil {
+		return nil, err
+	}
+	report.CheckedAt = time.Now().UTC().Format(time.RFC3339)
+
+	result := driftResult{Path: *output, DriftReport: report}
+	if *output != "" {
+		content, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(*output, append(content, '\n'), 0o600); err != nil {
+			return nil, err
+		}
+	}
+	if len(report.ManagedDrift()) > 0 {
+		return result, errFailed
+	}
+	return result, nil
+}
+
+// environmentDriftE copies the modules and the environment to a temp folder,
+// so init leaves the tree alone, and reads the drift of its state from a
+// refresh-only plan. The plan takes no state lock and the state is never
+// written, so it is safe to run next to pipeline applies. backendConfig
+// entries are files or key=value pairs as `terraform init` takes them
+func environmentDriftE(tf tfRunner, terraformRoot, env string, backendConfig, varFiles []string) (helpers.DriftReport, error) {
+	report := helpers.DriftReport{Environment: env}
+
+	copyRoot, err := os.MkdirTemp("", "ttk-drift-")
+	if err != nil {
+		return report, err
+	}
+	defer os.RemoveAll(copyRoot)
+	for _, folder := range []string{"modules", filepath.Join("environments", env)} {
+		if err := copyTerraformFolder(filepath.Join(terraformRoot, folder), filepath.Join(copyRoot, folder)); err != nil {
+			return report, err
+		}
+	}
+	dir := filepath.Join(copyRoot, "environments", env)
+
+	initArgs := []string{"init", "-input=false", "-no-color", "-reconfigure"}
+	for _, value := range backendConfig {
+		if !strings.Contains(value, "=") {
+			if value, err = filepath.Abs(value); err != nil {
+				return report, err
+			}
+		}
+		initArgs = append(initArgs, "-backend-config="+value)
+	}
+	planArgs := []string{"plan", "-refresh-only", "-input=false", "-no-color", "-lock=false", "-out=" + driftPlanFile}
+	for _, file := range varFiles {
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return report, err
+		}
+		planArgs = append(planArgs, "-var-file="+file)
+	}
+
+	for _, args := range [][]string{initArgs, planArgs} {
+		if _, err := tf(dir, args...); err != nil {
+			return report, err
+		}
+	}
+	planJSON, err := tf(dir, "show", "-json", driftPlanFile)
+	if err != nil {
+		return report, err
+	}
+	if report.Resources, err = helpers.ResourceDriftE(string(planJSON)); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// copyTerraformFolder copies source to destination, leaving out what
+// terratest leaves out of copied modules: hidden files and folders but the
+// lock file, state files and terraform.tfvars
+func copyTerraformFolder(source, destination string) error {
+	if err := os.MkdirAll(destination, 0o750); err != nil {
+		return err
+	}
+	return files.CopyFolderContentsWithFilter(source, destination, func(path string) bool {
+		if files.PathIsTerraformLockFile(path) {
+			return true
+		}
+		return !files.PathContainsHiddenFileOrFolder(path) && !files.PathContainsTerraformStateOrVars(path)
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironmentDrift(t *testing.T) {
+	t.Parallel()
+
+	testsDir := writeTestsTree(t, map[string]string{
+		"tests/go.mod":                        "module " + testsModulePath + "\n",
+		"modules/registry/main.tf":            "",
+		"environments/dev/main.tf":            "",
+		"environments/dev/terraform.tfvars":   "",
+		"environments/dev/.terraform/plugins": "",
+	})
+	root := filepath.Dir(testsDir)
+
+	var calls []string
+	tf := func(dir string, args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		assert.FileExists(t, filepath.Join(dir, "main.tf"))
+		assert.FileExists(t, filepath.Join(dir, "..", "..", "modules", "registry", "main.tf"))
+		assert.NoFileExists(t, filepath.Join(dir, "terraform.tfvars"))
+		assert.NoDirExists(t, filepath.Join(dir, ".terraform"))
+		if args[0] != "show" {
+			return nil, nil
+		}
+		return []byte(`{
+			"resource_drift": [
+				{"address": "module.registry.azurerm_container_registry.this", "mode": "managed", "change": {"actions": ["update"],
+					"before": {"admin_enabled": false}, "after": {"admin_enabled": true}}}
+			],
+			"configuration": {"root_module": {"module_calls": {"registry": {"module": {"resources": [
+				{"address": "azurerm_container_registry.this", "mode": "managed", "expressions": {"admin_enabled": {}}}
+			]}}}}}
+		}`), nil
+	}
+
+	varFile := filepath.Join(root, "environments", "dev", "terraform.tfvars")
+	report, err := environmentDriftE(tf, root, "dev", []string{"key=finrisk-dev.tfstate"}, []string{varFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{
+		"init -input=false -no-color -reconfigure -backend-config=key=finrisk-dev.tfstate",
+		"plan -refresh-only -input=false -no-color -lock=false -out=drift.tfplan -var-file=" + varFile,
+		"show -json drift.tfplan",
+	}, calls)
+	assert.Equal(t, "dev", report.Environment)
+	if assert.Len(t, report.ManagedDrift(), 1) {
+		assert.Equal(t, "module.registry.azurerm_container_registry.this", report.Resources[0].Address)
+	}
+
+	var text bytes.Buffer
+	driftResult{DriftReport: report}.writeText(&text)
+	assert.Equal(t, "module.registry.azurerm_container_registry.this: admin_enabled changed outside terraform\n"+
+		"1 resources drifted in dev, 1 in managed attributes\n", text.String())
+
+	_, err = environmentDriftE(func(dir string, args ...string) ([]byte, error) {
+		return nil, fmt.Errorf("terraform %s: no credentials", args[0])
+	}, root, "dev", nil, nil)
+	assert.EqualError(t, err, "terraform init: no credentials")
+}
+
+func TestDriftUsage(t *testing.T) {
+	t.Parallel()
+
+	testsDir := writeTestsTree(t, map[string]string{
+		"tests/go.mod":             "module " + testsModulePath + "\n",
+		"environments/dev/main.tf": "",
+	})
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{"-dir", testsDir, "drift", "-env", "staging"}, &stdout, &stderr),
+		"the environment must exist")
+	assert.Equal(t, 2, run([]string{"-dir", testsDir, "drift"}, &stdout, &stderr),
+		"dev has no backend.hcl")
+	assert.Contains(t, stderr.String(), "backend.hcl.example")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+func init() {
+	register(command{
+		name:    "inventory",
+		summary: "list test resource groups in the subscription, by namespace",
+		run:     runInventory,
+	})
+}
+
+// testGroup is a resource group created by the tests
+type testGroup struct {
+	Name      string `json:"name"`
+	Location  string `json:"location"`
+	Namespace string `json:"namespace"`
+	TestName  string `json:"test_name,omitempty"`
+	// CreatedAt is the group's CreatedAt tag, empty for groups created
+	// before tests stamped it
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+type inventoryResult struct {
+	// Namespace is the namespace listed, "" for all of them
+	Namespace string      `json:"namespace,omitempty"`
+	Groups    []testGroup `json:"groups"`
+}
+
+func (r inventoryResult) writeText(w io.Writer) {
+	for _, group := range r.Groups {
+		fmt.Fprintf(w, "%-12s %-50s %-12s %s\n", group.Namespace, group.Name, group.Location, group.TestName)
+	}
+	scope := "all namespaces"
+	if r.Namespace != "" {
+		scope = "namespace " + r.Namespace
+	}
+	fmt.Fprintf(w, "%d test resource groups in %s\n", len(r.Groups), scope)
+}
+
+// namespaceFlags adds the flags selecting a namespace, which default to the
+// runner's own, and returns the namespace to filter on ("" for all)
+func namespaceFlags(flags *flag.FlagSet, config *Config) func() string {
+	namespace := flags.String("namespace", config.Namespace, "namespace whose resource groups to include (default: TEST_NAMESPACE, the CI job or USER)")
+	all := flags.Bool("all", false, "include every namespace")
+	return func() string {
+		if *all {
+			return ""
+		}
+		return helpers.NormalizeNamespace(*namespace)
+	}
+}
+
+func runInventory(config *Config, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("inventory", flag.ContinueOnError)
+	namespace := namespaceFlags(flags, config)
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	groups, err := listTestGroupsE(azCommand, namespace())
+	if err != nil {
+		return nil, err
+	}
+	return inventoryResult{Namespace: namespace(), Groups: groups}, nil
+}
+
+// listTestGroupsE lists the resource groups tagged ManagedBy=terratest in
+// namespace, or in every namespace when it is "", sorted by namespace and
+// name. Groups created before namespaces existed are in namespace ""
+func listTestGroupsE(az azRunner, namespace string) ([]testGroup, error) {
+	output, err := az("group", "list", "--tag", "ManagedBy=terratest")
+	if err != nil {
+		return nil, err
+	}
+	var listed []struct {
+		Name     string            `json:"name"`
+		Location string            `json:"location"`
+		Tags     map[string]string `json:"tags"`
+	}
+	if err := json.Unmarshal(output, &listed); err != nil {
+		return nil, fmt.Errorf("decoding resource groups: %w", err)
+	}
+
+	groups := []testGroup{}
+	for _, group := range listed {
+		groupNamespace := group.Tags[helpers.NamespaceTag]
+		if namespace != "" && groupNamespace != namespace {
+			continue
+		}
+		groups = append(groups, testGroup{
+			Name:      group.Name,
+			Location:  group.Location,
+			Namespace: groupNamespace,
+			TestName:  group.Tags["TestName"],
+			CreatedAt: group.Tags[helpers.CreatedAtTag],
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Namespace != groups[j].Namespace {
+			return groups[i].Namespace < groups[j].Namespace
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups, nil
+}
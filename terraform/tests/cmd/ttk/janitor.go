@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+func init() {
+	register(command{
+		name:    "janitor",
+		summary: "delete leftover test resource groups of a namespace",
+		run:     runJanitor,
+	})
+}
+
+type janitorResult struct {
+	Namespace string      `json:"namespace,omitempty"`
+	Groups    []testGroup `json:"groups"`
+	// OlderThan is the minimum age of the groups cleaned, "" for any age
+	OlderThan string `json:"older_than,omitempty"`
+	// Kept are the groups of the namespace left alone for being younger
+	// than OlderThan or of unknown age
+	Kept []testGroup `json:"kept,omitempty"`
+	// Deleted is false for a dry run
+	Deleted bool `json:"deleted"`
+}
+
+func (r janitorResult) writeText(w io.Writer) {
+	for _, group := range r.Groups {
+		fmt.Fprintf(w, "%-12s %s\n", group.Namespace, group.Name)
+	}
+	if len(r.Kept) > 0 {
+		fmt.Fprintf(w, "Keeping %d resource groups created less than %s ago or of unknown age\n", len(r.Kept), r.OlderThan)
+	}
+	switch {
+	case len(r.Groups) == 0:
+		fmt.Fprintln(w, "Nothing to clean up")
+	case r.Deleted:
+		fmt.Fprintf(w, "Deleting %d resource groups in the background\n", len(r.Groups))
+	default:
+		fmt.Fprintf(w, "Would delete %d resource groups; run again with -yes to delete them\n", len(r.Groups))
+	}
+}
+
+// runJanitor deletes the test resource groups of a namespace, by default the
+// runner's own, so cleaning up never touches another engineer's running
+// tests. Without -yes it only lists what it would delete. With -older-than
+// it leaves groups younger than that alone, so it can run while tests do
+func runJanitor(config *Config, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("janitor", flag.ContinueOnError)
+	namespace := namespaceFlags(flags, config)
+	confirmed := flags.Bool("yes", false, "delete the resource groups instead of listing them")
+	olderThan := flags.Duration("older-than", 0, "only clean groups whose CreatedAt tag is at least this old, e.g. 6h (default: any age)")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if *olderThan < 0 {
+		return nil, fmt.Errorf("%w: -older-than must not be negative", errUsage)
+	}
+	return cleanTestGroupsE(azCommand, helpers.SystemClock, namespace(), *olderThan, *confirmed)
+}
+
+// expiredGroups splits groups into those at least olderThan old by clock and
+// the rest. A group without a CreatedAt tag has no known age and is kept,
+// since it may belong to a running test; olderThan 0 expires every group
+func expiredGroups(groups []testGroup, olderThan time.Duration, clock helpers.Clock) (expired, kept []testGroup) {
+	expired = []testGroup{}
+	for _, group := range groups {
+		if olderThan == 0 {
+			expired = append(expired, group)
+			continue
+		}
+		age, known := helpers.ResourceAge(map[string]string{helpers.CreatedAtTag: group.CreatedAt}, clock)
+		if known && age >= olderThan {
+			expired = append(expired, group)
+		} else {
+			kept = append(kept, group)
+		}
+	}
+	return expired, kept
+}
+
+// cleanTestGroupsE deletes the test resource groups of namespace, or of
+// every namespace when it is "", that are at least olderThan old, without
+// waiting for the deletions; with confirmed false it only lists them
+func cleanTestGroupsE(az azRunner, clock helpers.Clock, namespace string, olderThan time.Duration, confirmed bool) (janitorResult, error) {
+	groups, err := listTestGroupsE(az, namespace)
+	if err != nil {
+		return janitorResult{}, err
+	}
+	expired, kept := expiredGroups(groups, olderThan, clock)
+	result := janitorResult{Namespace: namespace, Groups: expired, Kept: kept, Deleted: confirmed}
+	if olderThan > 0 {
+		result.OlderThan = olderThan.String()
+	}
+	if !confirmed {
+		return result, nil
+	}
+	for _, group := range expired {
+		if _, err := az("group", "delete", "--name", group.Name, "--yes", "--no-wait"); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
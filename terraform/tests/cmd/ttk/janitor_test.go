@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+const testGroupList = `[
+  {"name": "rg-ca-https-test-jane-abc123", "location": "eastus2",
+   "tags": {"ManagedBy": "terratest", "TestName": "TestContainerAppHTTPS", "TestNamespace": "jane"}},
+  {"name": "rg-ca-exec-test-bob-def456", "location": "westus2",
+   "tags": {"ManagedBy": "terratest", "TestName": "TestContainerAppExec", "TestNamespace": "bob"}},
+  {"name": "rg-acr-test-ghi789", "location": "eastus2", "tags": {"ManagedBy": "terratest"}}
+]`
+
+func TestCleanTestGroups(t *testing.T) {
+	t.Parallel()
+
+	var deleted []string
+	az := func(args ...string) ([]byte, error) {
+		switch args[0] + " " + args[1] {
+		case "group list":
+			return []byte(testGroupList), nil
+		case "group delete":
+			deleted = append(deleted, args[3])
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unexpected az %s", strings.Join(args, " "))
+	}
+
+	all, err := listTestGroupsE(az, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"", "bob", "jane"}, []string{all[0].Namespace, all[1].Namespace, all[2].Namespace})
+	}
+
+	result, err := cleanTestGroupsE(az, helpers.SystemClock, "jane", 0, false)
+	if assert.NoError(t, err) {
+		assert.False(t, result.Deleted)
+		assert.Len(t, result.Groups, 1)
+		assert.Empty(t, deleted, "a dry run deletes nothing")
+	}
+
+	result, err = cleanTestGroupsE(az, helpers.SystemClock, "jane", 0, true)
+	if assert.NoError(t, err) {
+		assert.True(t, result.Deleted)
+		assert.Equal(t, []string{"rg-ca-https-test-jane-abc123"}, deleted, "only the namespace's groups are deleted")
+	}
+}
+
+const agedGroupList = `[
+  {"name": "rg-old", "location": "eastus2",
+   "tags": {"ManagedBy": "terratest", "TestNamespace": "jane", "CreatedAt": "2026-03-01T06:00:00Z"}},
+  {"name": "rg-boundary", "location": "eastus2",
+   "tags": {"ManagedBy": "terratest", "TestNamespace": "jane", "CreatedAt": "2026-03-01T09:00:00Z"}},
+  {"name": "rg-running", "location": "eastus2",
+   "tags": {"ManagedBy": "terratest", "TestNamespace": "jane", "CreatedAt": "2026-03-01T11:30:00Z"}},
+  {"name": "rg-untagged", "location": "eastus2",
+   "tags": {"ManagedBy": "terratest", "TestNamespace": "jane"}},
+  {"name": "rg-garbled", "location": "eastus2",
+   "tags": {"ManagedBy": "terratest", "TestNamespace": "jane", "CreatedAt": "yesterday"}}
+]`
+
+func TestCleanTestGroupsOlderThan(t *testing.T) {
+	t.Parallel()
+
+	var deleted []string
+	az := func(args ...string) ([]byte, error) {
+		switch args[0] + " " + args[1] {
+		case "group list":
+			return []byte(agedGroupList), nil
+		case "group delete":
+			deleted = append(deleted, args[3])
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unexpected az %s", strings.Join(args, " "))
+	}
+	names := func(groups []testGroup) []string {
+		var names []string
+		for _, group := range groups {
+			names = append(names, group.Name)
+		}
+		return names
+	}
+
+	clock := helpers.NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	result, err := cleanTestGroupsE(az, clock, "jane", 3*time.Hour, true)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "3h0m0s", result.OlderThan)
+		assert.Equal(t, []string{"rg-boundary", "rg-old"}, names(result.Groups), "groups exactly as old as -older-than are cleaned")
+		assert.Equal(t, []string{"rg-garbled", "rg-running", "rg-untagged"}, names(result.Kept), "young groups and groups of unknown age are kept")
+		assert.Equal(t, []string{"rg-boundary", "rg-old"}, deleted)
+	}
+
+	// A day later the running test's group has expired too
+	clock.Advance(24 * time.Hour)
+	result, err = cleanTestGroupsE(az, clock, "jane", 3*time.Hour, false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"rg-boundary", "rg-old", "rg-running"}, names(result.Groups))
+		assert.Equal(t, []string{"rg-garbled", "rg-untagged"}, names(result.Kept))
+	}
+}
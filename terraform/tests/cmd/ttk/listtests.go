@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func init() {
+	register(command{
+		name:    "list-tests",
+		summary: "list the tests with their gating and the paths they use",
+		run:     runListTests,
+	})
+}
+
+type listTestsResult struct {
+	Tests []testInfo `json:"tests"`
+}
+
+func (r listTestsResult) writeText(w io.Writer) {
+	for _, test := range r.Tests {
+		var tags []string
+		if test.Slow {
+			tags = append(tags, "slow")
+		}
+		for _, variable := range test.OptIn {
+			tags = append(tags, variable+"=true")
+		}
+		if test.KnownIssue != "" {
+			tags = append(tags, "expected-failure="+test.KnownIssue)
+		}
+		fmt.Fprintf(w, "%-45s %-32s %s\n", test.Name, fmt.Sprintf("%s:%d", test.File, test.Line), strings.Join(tags, " "))
+	}
+}
+
+func runListTests(config *Config, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("list-tests", flag.ContinueOnError)
+	short := flags.Bool("short", false, "only tests that run with -short")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	tests, err := findTestsE(config.TestsDir)
+	if err != nil {
+		return nil, err
+	}
+	result := listTestsResult{Tests: []testInfo{}}
+	for _, test := range tests {
+		if !*short || !test.Slow {
+			result.Tests = append(result.Tests, test)
+		}
+	}
+	return result, nil
+}
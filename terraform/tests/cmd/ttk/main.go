@@ -0,0 +1,137 @@
+// Command ttk is the single entry point to the terraform test toolkit. Every
+// subcommand shares the same configuration (see loadConfig) and, with -json,
+// prints one JSON document:
+//
+//	{"command": "doctor", "ok": true, "result": {...}}
+//
+// Run `go run ./cmd/ttk help` from terraform/tests for the list of commands.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command is a ttk subcommand. run returns the result to print; a result
+// that implements textWriter is printed as text unless -json is given
+type command struct {
+	name    string
+	summary string
+	run     func(config *Config, args []string) (interface{}, error)
+}
+
+// textWriter is implemented by results with a human-readable form
+type textWriter interface {
+	writeText(w io.Writer)
+}
+
+// envelope is the JSON output of every command
+type envelope struct {
+	Command string      `json:"command"`
+	OK      bool        `json:"ok"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// errFailed reports a command that ran but found problems, e.g. failed
+// doctor checks; its result is still printed
+var errFailed = errors.New("checks failed")
+
+// errUsage reports invalid arguments
+var errUsage = errors.New("invalid usage")
+
+var commands = map[string]command{}
+
+// register adds a subcommand; each command's file registers it in init
+func register(c command) {
+	commands[c.name] = c
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line in args and returns the exit code: 0 on
+// success, 1 when the command failed and 2 on invalid usage
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ttk", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	jsonOutput := flags.Bool("json", false, "print the result as JSON")
+	testsDir := flags.String("dir", "", "terraform/tests folder (default: found from the working directory)")
+	flags.Usage = func() { usage(flags, stderr) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || flags.Arg(0) == "help" {
+		usage(flags, stderr)
+		return 2
+	}
+
+	name := flags.Arg(0)
+	c, exists := commands[name]
+	if !exists {
+		fmt.Fprintf(stderr, "ttk: unknown command %q\n\n", name)
+		usage(flags, stderr)
+		return 2
+	}
+
+	config, err := loadConfig(*testsDir)
+	var result interface{}
+	if err == nil {
+		result, err = c.run(config, flags.Args()[1:])
+	}
+
+	if *jsonOutput {
+		output := envelope{Command: name, OK: err == nil, Result: result}
+		if err != nil {
+			output.Error = err.Error()
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(output); encodeErr != nil {
+			fmt.Fprintf(stderr, "ttk: encoding result: %v\n", encodeErr)
+			return 1
+		}
+	} else {
+		if text, ok := result.(textWriter); ok {
+			text.writeText(stdout)
+		} else if result != nil {
+			fmt.Fprintf(stdout, "%v\n", result)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "ttk %s: %v\n", name, err)
+		}
+	}
+
+	switch {
+	case errors.Is(err, errUsage):
+		return 2
+	case err != nil:
+		return 1
+	}
+	return 0
+}
+
+// usage prints the global flags and the list of commands
+func usage(flags *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "Usage: ttk [-json] [-dir DIR] <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-12s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	flags.SetOutput(w)
+	flags.PrintDefaults()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunUsage(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "list-tests")
+
+	stderr.Reset()
+	assert.Equal(t, 2, run([]string{"deploy"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown command "deploy"`)
+}
+
+func TestRunJSON(t *testing.T) {
+	t.Parallel()
+
+	testsDir := writeTestsTree(t, map[string]string{
+		"tests/go.mod":        "module " + testsModulePath + "\n",
+		"tests/vault_test.go": "package test\n\nimport \"testing\"\n\nfunc TestVault(t *testing.T) {\n\t_ = \"../modules/vault\"\n}\n",
+	})
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-json", "-dir", testsDir, "affected", filepath.Join(testsDir, "..", "modules", "vault", "main.tf")}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+
+	var output struct {
+		Command string         `json:"command"`
+		OK      bool           `json:"ok"`
+		Result  affectedResult `json:"result"`
+	}
+	if assert.NoError(t, json.Unmarshal(stdout.Bytes(), &output)) {
+		assert.Equal(t, "affected", output.Command)
+		assert.True(t, output.OK)
+		assert.Equal(t, []string{"TestVault"}, output.Result.Tests)
+	}
+
+	stdout.Reset()
+	assert.Equal(t, 2, run([]string{"-json", "-dir", testsDir, "affected"}, &stdout, &stderr), "affected needs files or -base")
+	var failure envelope
+	if assert.NoError(t, json.Unmarshal(stdout.Bytes(), &failure)) {
+		assert.False(t, failure.OK)
+		assert.Contains(t, failure.Error, "give changed files")
+	}
+}
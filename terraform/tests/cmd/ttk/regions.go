@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+func init() {
+	register(command{
+		name:    "regions",
+		summary: "refresh the catalog of resource types and SKUs per region",
+		run:     runRegions,
+	})
+}
+
+// azRunner runs an Azure CLI command and returns its stdout
+type azRunner func(args ...string) ([]byte, error)
+
+// azCommand runs the installed Azure CLI
+func azCommand(args ...string) ([]byte, error) {
+	output, err := exec.Command("az", append(args, "--only-show-errors", "--output", "json")...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return nil, fmt.Errorf("az %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return output, err
+}
+
+// regionsResult is the refreshed catalog and the file it was written to
+type regionsResult struct {
+	Path    string                 `json:"path"`
+	Catalog *helpers.RegionCatalog `json:"catalog"`
+}
+
+func (r regionsResult) writeText(w io.Writer) {
+	names := make([]string, 0, len(r.Catalog.Regions))
+	for name := range r.Catalog.Regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		capabilities := r.Catalog.Regions[name]
+		fmt.Fprintf(w, "%-20s %d/%d resource types, %d zonal\n", name,
+			len(capabilities.ResourceTypes), len(helpers.CatalogResourceTypes), len(capabilities.ZonalResourceTypes))
+	}
+	fmt.Fprintf(w, "Written %d regions to %s\n", len(names), r.Path)
+}
+
+func runRegions(config *Config, args []string) (interface{}, error) {
+	if len(args) == 0 || args[0] != "update" {
+		return nil, fmt.Errorf("%w: regions needs the update subcommand", errUsage)
+	}
+	flags := flag.NewFlagSet("regions update", flag.ContinueOnError)
+	regions := flags.String("regions", "", "comma-separated regions to catalog (default: every region offering a tracked resource type)")
+	output := flags.String("o", filepath.Join(config.TestsDir, helpers.RegionCatalogFile), "file to write the catalog to")
+	if err := flags.Parse(args[1:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	var only []string
+	for _, region := range strings.Split(*regions, ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			only = append(only, region)
+		}
+	}
+	catalog, err := updateRegionCatalogE(azCommand, only)
+	if err != nil {
+		return nil, err
+	}
+	catalog.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+
+	content, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(*output, append(content, '\n'), 0o600); err != nil {
+		return nil, err
+	}
+	return regionsResult{Path: *output, Catalog: catalog}, nil
+}
+
+// updateRegionCatalogE reads the providers of the tracked resource types
+// and the Container Apps workload profile types of every cataloged region
+// through az, and builds the catalog. regions limits it to those regions;
+// empty catalogs every region offering one of the tracked resource types
+func updateRegionCatalogE(az azRunner, regions []string) (*helpers.RegionCatalog, error) {
+	namespaces := map[string]bool{}
+	for _, resourceType := range helpers.CatalogResourceTypes {
+		namespace, _, _ := strings.Cut(resourceType, "/")
+		namespaces[namespace] = true
+	}
+	sortedNamespaces := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		sortedNamespaces = append(sortedNamespaces, namespace)
+	}
+	sort.Strings(sortedNamespaces)
+
+	var providers []helpers.ResourceProvider
+	for _, namespace := range sortedNamespaces {
+		output, err := az("provider", "show", "--namespace", namespace)
+		if err != nil {
+			return nil, err
+		}
+		var provider helpers.ResourceProvider
+		if err := json.Unmarshal(output, &provider); err != nil {
+			return nil, fmt.Errorf("decoding provider %s: %w", namespace, err)
+		}
+		providers = append(providers, provider)
+	}
+
+	catalog := helpers.BuildRegionCatalog(providers, helpers.CatalogResourceTypes, regions, nil)
+	skus := map[string]map[string][]string{}
+	for name, capabilities := range catalog.Regions {
+		if !containsString(capabilities.ResourceTypes, helpers.ResourceTypeContainerAppEnvironment) {
+			continue
+		}
+		output, err := az("containerapp", "env", "workload-profile", "list-supported", "--location", name)
+		if err != nil {
+			return nil, err
+		}
+		var profiles []struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(output, &profiles); err != nil {
+			return nil, fmt.Errorf("decoding workload profiles of %s: %w", name, err)
+		}
+		for _, profile := range profiles {
+			if skus[name] == nil {
+				skus[name] = map[string][]string{}
+			}
+			skus[name][helpers.ResourceTypeContainerAppEnvironment] = append(skus[name][helpers.ResourceTypeContainerAppEnvironment], profile.Name)
+		}
+	}
+	return helpers.BuildRegionCatalog(providers, helpers.CatalogResourceTypes, regions, skus), nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateRegionCatalog(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	az := func(args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		switch strings.Join(args, " ") {
+		case "provider show --namespace Microsoft.App":
+			return []byte(`{"namespace": "Microsoft.App", "resourceTypes": [
+				{"resourceType": "managedEnvironments", "locations": ["East US 2", "Central US"]}]}`), nil
+		case "containerapp env workload-profile list-supported --location eastus2":
+			return []byte(`[{"name": "Consumption"}, {"name": "D4"}]`), nil
+		}
+		if args[0] == "provider" {
+			return []byte(`{"namespace": "` + args[3] + `", "resourceTypes": []}`), nil
+		}
+		return nil, fmt.Errorf("unexpected az %v", args)
+	}
+
+	catalog, err := updateRegionCatalogE(az, []string{"eastus2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"eastus2"}, keys(catalog.Regions))
+	assert.Equal(t, []string{"Consumption", "D4"}, catalog.Regions["eastus2"].SKUs[helpers.ResourceTypeContainerAppEnvironment])
+	assert.NotContains(t, calls, "containerapp env workload-profile list-supported --location centralus",
+		"regions outside the list are not queried")
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{"-dir", writeTestsTree(t, map[string]string{"tests/go.mod": "module " + testsModulePath + "\n"}), "regions"}, &stdout, &stderr),
+		"regions needs the update subcommand")
+}
+
+// keys returns the sorted keys of a region map
+func keys(regions map[string]*helpers.RegionCapabilities) []string {
+	var names []string
+	for name := range regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
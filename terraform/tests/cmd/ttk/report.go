@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+func init() {
+	register(command{
+		name:    "report",
+		summary: "summarize a test run, diff two runs for a pull request or export one to Log Analytics",
+		run:     runReport,
+	})
+}
+
+// summaryResult is the summary of a run and the file it was written to
+type summaryResult struct {
+	Path    string              `json:"path,omitempty"`
+	Summary *helpers.RunSummary `json:"summary"`
+}
+
+func (r summaryResult) writeText(w io.Writer) {
+	failed, expected := 0, 0
+	for _, outcome := range r.Summary.Tests {
+		switch outcome.Status {
+		case "fail":
+			failed++
+		case helpers.StatusExpectedFailure:
+			expected++
+		}
+	}
+	fmt.Fprintf(w, "Run %s: %d tests, %d failed", r.Summary.RunID, len(r.Summary.Tests), failed)
+	if expected > 0 {
+		fmt.Fprintf(w, " (and %d expected failures)", expected)
+	}
+	fmt.Fprintf(w, ", %d cost profiles\n", len(r.Summary.CostProfiles))
+	if r.Path != "" {
+		fmt.Fprintf(w, "Written to %s\n", r.Path)
+	}
+}
+
+// diffResult prints a run diff as the Markdown posted on pull requests
+type diffResult struct {
+	*helpers.RunDiff
+	Regressed bool `json:"regressed"`
+}
+
+func (r diffResult) writeText(w io.Writer) {
+	fmt.Fprint(w, r.Markdown())
+}
+
+// exportResult is what was exported of a run, and where to
+type exportResult struct {
+	RunID    string `json:"run_id"`
+	Table    string `json:"table"`
+	Endpoint string `json:"endpoint"`
+	Records  int    `json:"records"`
+	Batches  int    `json:"batches"`
+}
+
+func (r exportResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Exported %d tests of run %s to %s in %d batches through %s\n", r.Records, r.RunID, r.Table, r.Batches, r.Endpoint)
+}
+
+func runReport(config *Config, args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: report needs a subcommand, summary, diff or export", errUsage)
+	}
+	switch args[0] {
+	case "summary":
+		return runReportSummary(config, args[1:])
+	case "diff":
+		return runReportDiff(args[1:])
+	case "export":
+		return runReportExport(config, args[1:])
+	}
+	return nil, fmt.Errorf("%w: unknown report subcommand %q", errUsage, args[0])
+}
+
+// runReportSummary builds a run summary from `go test -json` output and the
+// run's report folder, ready to be kept as a CI artifact
+func runReportSummary(config *Config, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("report summary", flag.ContinueOnError)
+	goTestJSON := flags.String("go-test", "", "file with the run's `go test -json` output")
+	runID := flags.String("run", config.RunID, "run ID whose reports to include (default: TEST_RUN_ID)")
+	output := flags.String("o", "", "file to write the summary to")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if *goTestJSON == "" || *runID == "" {
+		return nil, fmt.Errorf("%w: give -go-test and -run (or TEST_RUN_ID)", errUsage)
+	}
+
+	file, err := os.Open(*goTestJSON)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	summary, err := helpers.BuildRunSummaryE(file, filepath.Join(config.TestsDir, helpers.ReportDir(*runID)))
+	if err != nil {
+		return nil, err
+	}
+
+	result := summaryResult{Path: *output, Summary: summary}
+	if *output != "" {
+		content, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(*output, append(content, '\n'), 0o600); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// runReportDiff compares two run summaries. With -fail-on-regression it
+// exits 1 when the second run regressed, still printing the diff
+func runReportDiff(args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("report diff", flag.ContinueOnError)
+	options := helpers.DefaultRunDiffOptions
+	flags.Float64Var(&options.DurationThreshold, "threshold", options.DurationThreshold, "relative slowdown that counts as a regression")
+	flags.DurationVar(&options.MinDuration, "min-duration", options.MinDuration, "ignore slowdowns of tests faster than this")
+	failOnRegression := flags.Bool("fail-on-regression", false, "exit 1 when the second run regressed")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() != 2 {
+		return nil, fmt.Errorf("%w: give the summaries of two runs, before then after", errUsage)
+	}
+
+	before, err := helpers.ReadRunSummaryE(flags.Arg(0))
+	if err != nil {
+		return nil, err
+	}
+	after, err := helpers.ReadRunSummaryE(flags.Arg(1))
+	if err != nil {
+		return nil, err
+	}
+
+	diff := helpers.DiffRuns(before, after, options)
+	result := diffResult{RunDiff: diff, Regressed: diff.Regressed()}
+	if *failOnRegression && result.Regressed {
+		return result, errFailed
+	}
+	return result, nil
+}
+
+// runReportExport uploads a run summary to the suite metrics table, a row
+// per test, as the signed-in Azure CLI user
+func runReportExport(config *Config, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("report export", flag.ContinueOnError)
+	client := &helpers.LogsIngestionClient{}
+	flags.StringVar(&client.Endpoint, "endpoint", os.Getenv("TEST_SUITE_METRICS_ENDPOINT"), "logs ingestion endpoint of the data collection endpoint (default: TEST_SUITE_METRICS_ENDPOINT)")
+	flags.StringVar(&client.RuleID, "rule", os.Getenv("TEST_SUITE_METRICS_RULE_ID"), "immutable ID of the data collection rule (default: TEST_SUITE_METRICS_RULE_ID)")
+	flags.StringVar(&client.Stream, "stream", helpers.SuiteMetricsStream, "stream of the data collection rule")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() != 1 {
+		return nil, fmt.Errorf("%w: give the summary of one run", errUsage)
+	}
+	if client.Endpoint == "" || client.RuleID == "" {
+		return nil, fmt.Errorf("%w: give -endpoint and -rule (or TEST_SUITE_METRICS_ENDPOINT and TEST_SUITE_METRICS_RULE_ID)", errUsage)
+	}
+
+	cloud, err := helpers.CloudByName(os.Getenv("ARM_ENVIRONMENT"))
+	if err != nil {
+		return nil, err
+	}
+	summary, err := helpers.ReadRunSummaryE(flags.Arg(0))
+	if err != nil {
+		return nil, err
+	}
+	return exportRunE(azCommand, cloud, client, summary, config.Namespace, time.Now())
+}
+
+// exportRunE uploads summary with a Logs Ingestion API token of cloud from az
+func exportRunE(az azRunner, cloud helpers.Cloud, client *helpers.LogsIngestionClient, summary *helpers.RunSummary, namespace string, finished time.Time) (exportResult, error) {
+	client.Token = func() (string, error) {
+		output, err := az("account", "get-access-token", "--resource", cloud.MonitorResource, "--query", "accessToken")
+		if err != nil {
+			return "", err
+		}
+		var token string
+		if err := json.Unmarshal(output, &token); err != nil {
+			return "", fmt.Errorf("decoding the access token: %w", err)
+		}
+		return token, nil
+	}
+
+	records := helpers.SuiteMetricRecords(summary, namespace, finished)
+	batches, err := client.UploadE(records)
+	result := exportResult{
+		RunID:    summary.RunID,
+		Table:    helpers.SuiteMetricsTable,
+		Endpoint: client.Endpoint,
+		Records:  len(records),
+		Batches:  batches,
+	}
+	return result, err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunReportSummaryAndDiff(t *testing.T) {
+	t.Parallel()
+
+	testsDir := writeTestsTree(t, map[string]string{
+		"tests/go.mod": "module " + testsModulePath + "\n",
+		"tests/logs/reports/pr-7/cost_profiles.json": `{"app": {"azurerm_container_app_environment": 2}}`,
+		"before.json": `{"run_id": "main", "tests": {"TestApp": {"status": "pass", "duration_seconds": 100}},
+			"cost_profiles": {"app": {"azurerm_container_app_environment": 1}}}`,
+		"go-test.json": `{"Action":"fail","Package":"example/tests","Test":"TestApp","Elapsed":40}` + "\n",
+	})
+	root := filepath.Dir(testsDir)
+	after := filepath.Join(root, "after.json")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-dir", testsDir, "report", "summary", "-go-test", filepath.Join(root, "go-test.json"), "-run", "pr-7", "-o", after}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Run pr-7: 1 tests, 1 failed, 1 cost profiles")
+	if _, err := os.Stat(after); err != nil {
+		t.Fatalf("Summary not written: %v", err)
+	}
+
+	stdout.Reset()
+	code = run([]string{"-dir", testsDir, "report", "diff", filepath.Join(root, "before.json"), after}, &stdout, &stderr)
+	assert.Equal(t, 0, code, "regressions only fail the command with -fail-on-regression")
+	assert.Contains(t, stdout.String(), "- `TestApp`")
+	assert.Contains(t, stdout.String(), "| app | `azurerm_container_app_environment` | 1 | 2 |")
+
+	stdout.Reset()
+	code = run([]string{"-json", "-dir", testsDir, "report", "diff", "-fail-on-regression", filepath.Join(root, "before.json"), after}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	var output struct {
+		OK     bool `json:"ok"`
+		Result struct {
+			NewlyFailing []string `json:"newly_failing"`
+			Regressed    bool     `json:"regressed"`
+		} `json:"result"`
+	}
+	if assert.NoError(t, json.Unmarshal(stdout.Bytes(), &output)) {
+		assert.False(t, output.OK)
+		assert.True(t, output.Result.Regressed)
+		assert.Equal(t, []string{"TestApp"}, output.Result.NewlyFailing)
+	}
+
+	assert.Equal(t, 2, run([]string{"-dir", testsDir, "report", "diff", after}, &stdout, &stderr), "diff needs two runs")
+	assert.Equal(t, 2, run([]string{"-dir", testsDir, "report", "publish"}, &stdout, &stderr))
+}
+
+func TestExportRun(t *testing.T) {
+	t.Parallel()
+
+	var uploaded []helpers.SuiteMetricRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer monitor-token", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &uploaded))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	az := func(args ...string) ([]byte, error) {
+		if strings.Join(args, " ") == "account get-access-token --resource https://monitor.azure.us --query accessToken" {
+			return []byte(`"monitor-token"`), nil
+		}
+		return nil, fmt.Errorf("unexpected az %v", args)
+	}
+	summary := &helpers.RunSummary{RunID: "pr-7", Tests: map[string]helpers.TestOutcome{
+		"TestApp": {Status: "fail", DurationSeconds: 40},
+	}}
+	client := &helpers.LogsIngestionClient{Endpoint: server.URL, RuleID: "dcr-0123"}
+	result, err := exportRunE(az, helpers.USGovernmentCloud, client, summary, "ci", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	if assert.NoError(t, err) {
+		assert.Equal(t, exportResult{RunID: "pr-7", Table: helpers.SuiteMetricsTable, Endpoint: server.URL, Records: 1, Batches: 1}, result)
+		assert.Equal(t, []helpers.SuiteMetricRecord{{
+			TimeGenerated: "2026-03-01T12:00:00Z", RunID: "pr-7", Namespace: "ci", Test: "TestApp", Status: "fail", DurationSeconds: 40,
+		}}, uploaded)
+	}
+
+	var stdout, stderr bytes.Buffer
+	testsDir := writeTestsTree(t, map[string]string{"tests/go.mod": "module " + testsModulePath + "\n"})
+	assert.Equal(t, 2, run([]string{"-dir", testsDir, "report", "export", "-endpoint", server.URL, "-rule", "", "pr.json"}, &stdout, &stderr),
+		"export needs the data collection rule")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+func init() {
+	register(command{
+		name:    "sweep",
+		summary: "delete test resource groups of any namespace older than a TTL",
+		run:     runSweep,
+	})
+}
+
+type sweepResult struct {
+	SubscriptionID string                       `json:"subscription_id"`
+	Prefix         string                       `json:"prefix"`
+	MaxAge         string                       `json:"max_age"`
+	Groups         []helpers.StaleResourceGroup `json:"groups"`
+	// Deleted is false for a dry run
+	Deleted bool `json:"deleted"`
+}
+
+func (r sweepResult) writeText(w io.Writer) {
+	for _, group := range r.Groups {
+		fmt.Fprintf(w, "%-50s %-12s created %s ago\n", group.Name, group.Location, group.Age.Round(time.Minute))
+	}
+	switch {
+	case len(r.Groups) == 0:
+		fmt.Fprintf(w, "No %s*-test-* resource groups older than %s\n", r.Prefix, r.MaxAge)
+	case r.Deleted:
+		fmt.Fprintf(w, "Deleting %d resource groups older than %s in the background\n", len(r.Groups), r.MaxAge)
+	default:
+		fmt.Fprintf(w, "Would delete %d resource groups older than %s; run again with -yes to delete them\n", len(r.Groups), r.MaxAge)
+	}
+}
+
+// runSweep deletes the test resource groups that failed runs left behind in
+// the subscription, whatever their namespace, once their CreatedAt tag is
+// older than -max-age. Unlike janitor it goes by name rather than by the
+// ManagedBy tag, and it never touches groups of unknown age. Without -yes it
+// only lists what it would delete
+func runSweep(config *Config, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	subscriptionID := flags.String("subscription", config.SubscriptionID, "subscription to sweep (default: ARM_SUBSCRIPTION_ID)")
+	prefix := flags.String("prefix", helpers.DefaultSweepPrefix, "name prefix of the test resource groups; only <prefix>*-test-* groups are swept")
+	maxAge := flags.Duration("max-age", 24*time.Hour, "delete groups whose CreatedAt tag is at least this old")
+	confirmed := flags.Bool("yes", false, "delete the resource groups instead of listing them")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	switch {
+	case *subscriptionID == "":
+		return nil, fmt.Errorf("%w: set ARM_SUBSCRIPTION_ID or -subscription", errUsage)
+	case *prefix == "":
+		return nil, fmt.Errorf("%w: -prefix must not be empty", errUsage)
+	case *maxAge <= 0:
+		return nil, fmt.Errorf("%w: -max-age must be positive", errUsage)
+	}
+
+	result := sweepResult{SubscriptionID: *subscriptionID, Prefix: *prefix, MaxAge: maxAge.String(), Deleted: *confirmed}
+	var err error
+	if *confirmed {
+		result.Groups, err = helpers.SweepStaleResources(*subscriptionID, *prefix, *maxAge)
+	} else {
+		result.Groups, err = helpers.StaleResourceGroupsE(*subscriptionID, *prefix, *maxAge)
+	}
+	return result, err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSweepUsage(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{SubscriptionID: "sub"}
+	for _, args := range [][]string{
+		{"-max-age", "0s"},
+		{"-max-age", "-1h"},
+		{"-prefix", ""},
+		{"-subscription", ""},
+		{"-unknown"},
+	} {
+		_, err := runSweep(config, args)
+		assert.True(t, errors.Is(err, errUsage), "%v should be a usage error, got %v", args, err)
+	}
+}
+
+func TestSweepResultText(t *testing.T) {
+	t.Parallel()
+
+	result := sweepResult{Prefix: "rg-", MaxAge: "24h0m0s", Groups: []helpers.StaleResourceGroup{
+		{Name: "rg-kv-test-bob-def456", Location: "westus2", Age: 30*time.Hour + 20*time.Second},
+	}}
+	var text bytes.Buffer
+	result.writeText(&text)
+	assert.Contains(t, text.String(), "rg-kv-test-bob-def456")
+	assert.Contains(t, text.String(), "created 30h0m0s ago")
+	assert.Contains(t, text.String(), "Would delete 1 resource groups older than 24h0m0s; run again with -yes")
+
+	text.Reset()
+	sweepResult{Prefix: "rg-", MaxAge: "24h0m0s"}.writeText(&text)
+	assert.Equal(t, "No rg-*-test-* resource groups older than 24h0m0s\n", text.String())
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// testInfo describes a top-level test of the tests package, from its source
+type testInfo struct {
+	Name    string `json:"name"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Summary string `json:"summary,omitempty"`
+	// Slow tests skip themselves in -short mode
+	Slow bool `json:"slow"`
+	// OptIn lists the environment variables the test must be enabled with
+	OptIn []string `json:"opt_in,omitempty"`
+	// KnownIssue is the issue of a test marked with helpers.ExpectedFailure
+	KnownIssue string `json:"known_issue,omitempty"`
+	// Paths are the terraform folders and files the test uses, relative to
+	// the tests folder and possibly globs, including modules its fixtures call
+	Paths []string `json:"paths,omitempty"`
+}
+
+// pathPrefixes mark string literals in tests that name terraform paths
+var pathPrefixes = []string{"./fixtures", "../modules", "../environments", "testdata/"}
+
+// sharedFixturePaths are the paths behind helpers that deploy a fixture
+// shared by the run's tests, which tests never name themselves
+var sharedFixturePaths = map[string][]string{
+	"SharedWebhookReceiver":       {"fixtures/webhook-receiver", "fixtures/apps/webhook"},
+	"SharedWebhookReceiverE":      {"fixtures/webhook-receiver", "fixtures/apps/webhook"},
+	"AcquireSharedObservability":  {"fixtures/shared-observability"},
+	"AcquireSharedObservabilityE": {"fixtures/shared-observability"},
+	"AcquireSharedVNet":           {"fixtures/shared-vnet"},
+	"AcquireSharedVNetE":          {"fixtures/shared-vnet"},
+}
+
+// findTestsE parses the _test.go files of the tests package in testsDir
+func findTestsE(testsDir string) ([]testInfo, error) {
+	files, err := filepath.Glob(filepath.Join(testsDir, "*_test.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	fileSet := token.NewFileSet()
+	var tests []testInfo
+	for _, file := range files {
+		parsed, err := parser.ParseFile(fileSet, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range parsed.Decls {
+			function, ok := decl.(*ast.FuncDecl)
+			if !ok || function.Recv != nil || !strings.HasPrefix(function.Name.Name, "Test") || function.Name.Name == "TestMain" {
+				continue
+			}
+			test := testInfo{
+				Name: function.Name.Name,
+				File: filepath.Base(file),
+				Line: fileSet.Position(function.Pos()).Line,
+			}
+			if function.Doc != nil {
+				test.Summary = firstSentence(function.Doc.Text())
+			}
+			inspectTest(function.Body, &test)
+			if test.Paths, err = expandFixturePaths(testsDir, test.Paths); err != nil {
+				return nil, fmt.Errorf("%s: %w", test.Name, err)
+			}
+			tests = append(tests, test)
+		}
+	}
+	sort.Slice(tests, func(i, j int) bool { return tests[i].Name < tests[j].Name })
+	return tests, nil
+}
+
+// inspectTest fills in what the body of a test reveals: whether it skips in
+// short mode, the variables it must be enabled with, whether it is expected
+// to fail and the paths it uses
+func inspectTest(body *ast.BlockStmt, test *testInfo) {
+	paths := map[string]bool{}
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.SelectorExpr:
+			if ident, ok := n.X.(*ast.Ident); ok && ident.Name == "testing" && n.Sel.Name == "Short" {
+				test.Slow = true
+			}
+		case *ast.IfStmt:
+			if variable, ok := optInVariable(n); ok {
+				test.OptIn = append(test.OptIn, variable)
+			}
+		case *ast.CallExpr:
+			if name, ok := calledHelper(n); ok {
+				for _, path := range sharedFixturePaths[name] {
+					paths[path] = true
+				}
+			}
+			// Helpers naming a module or fixture app instead of a path
+			if name, ok := calledHelper(n); ok && len(n.Args) >= 2 {
+				if argument, ok := stringLiteral(n.Args[1]); ok {
+					switch name {
+					case "CopyModuleToTemp":
+						paths["../modules/"+argument] = true
+					case "BuildFixtureImage":
+						paths["fixtures/apps/"+argument] = true
+					case "ExpectedFailure":
+						test.KnownIssue = argument
+					}
+				}
+			}
+		case *ast.CompositeLit:
+			// Stack modules name a module instead of a path
+			if module, ok := stackModuleName(n); ok {
+				paths["../modules/"+module] = true
+			}
+		case *ast.BasicLit:
+			if value, ok := stringLiteral(n); ok {
+				for _, prefix := range pathPrefixes {
+					if strings.HasPrefix(value, prefix) {
+						paths[filepath.ToSlash(filepath.Clean(value))] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+	for path := range paths {
+		test.Paths = append(test.Paths, path)
+	}
+	sort.Strings(test.Paths)
+}
+
+// stackModuleName returns the Module of a helpers.StackModule literal
+func stackModuleName(literal *ast.CompositeLit) (string, bool) {
+	selector, ok := literal.Type.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != "StackModule" {
+		return "", false
+	}
+	if ident, ok := selector.X.(*ast.Ident); !ok || ident.Name != "helpers" {
+		return "", false
+	}
+	for _, element := range literal.Elts {
+		field, ok := element.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		if key, ok := field.Key.(*ast.Ident); ok && key.Name == "Module" {
+			return stringLiteral(field.Value)
+		}
+	}
+	return "", false
+}
+
+// optInVariable matches `if os.Getenv("X") != "true" { ... t.Skip... }`
+func optInVariable(statement *ast.IfStmt) (string, bool) {
+	condition, ok := statement.Cond.(*ast.BinaryExpr)
+	if !ok || condition.Op != token.NEQ {
+		return "", false
+	}
+	call, ok := condition.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return "", false
+	}
+	if name, ok := calledPackageFunction(call, "os"); !ok || name != "Getenv" {
+		return "", false
+	}
+	variable, ok := stringLiteral(call.Args[0])
+	if !ok {
+		return "", false
+	}
+
+	skips := false
+	ast.Inspect(statement.Body, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpr); ok {
+			if selector, ok := call.Fun.(*ast.SelectorExpr); ok && strings.HasPrefix(selector.Sel.Name, "Skip") {
+				skips = true
+			}
+		}
+		return !skips
+	})
+	return variable, skips
+}
+
+// calledHelper returns the name of the helpers package function call calls
+func calledHelper(call *ast.CallExpr) (string, bool) {
+	return calledPackageFunction(call, "helpers")
+}
+
+// calledPackageFunction returns the name of the function of pkg call calls
+func calledPackageFunction(call *ast.CallExpr, pkg string) (string, bool) {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	ident, ok := selector.X.(*ast.Ident)
+	if !ok || ident.Name != pkg {
+		return "", false
+	}
+	return selector.Sel.Name, true
+}
+
+// stringLiteral returns the value of a string literal expression
+func stringLiteral(expr ast.Expr) (string, bool) {
+	literal, ok := expr.(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(literal.Value)
+	return value, err == nil
+}
+
+// firstSentence returns the first sentence of a doc comment on one line
+func firstSentence(doc string) string {
+	text := strings.Join(strings.Fields(doc), " ")
+	if end := strings.Index(text, ". "); end >= 0 {
+		return text[:end]
+	}
+	return strings.TrimSuffix(text, ".")
+}
+
+// expandFixturePaths adds the local modules called by the fixtures in paths,
+// recursively, as paths relative to testsDir
+func expandFixturePaths(testsDir string, paths []string) ([]string, error) {
+	expanded := map[string]bool{}
+	var visit func(dir string) error
+	visit = func(dir string) error {
+		if expanded[dir] {
+			return nil
+		}
+		expanded[dir] = true
+		sources, err := localModuleSourcesE(filepath.Join(testsDir, dir))
+		if err != nil {
+			return err
+		}
+		for _, source := range sources {
+			if err := visit(filepath.ToSlash(filepath.Clean(filepath.Join(dir, source)))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(filepath.Join(testsDir, path))
+		if strings.HasPrefix(path, "fixtures/") && err == nil && info.IsDir() {
+			if err := visit(path); err != nil {
+				return nil, err
+			}
+		} else {
+			expanded[path] = true
+		}
+	}
+
+	result := make([]string, 0, len(expanded))
+	for path := range expanded {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// localModuleSourcesE returns the local sources ("./..." or "../...") of the
+// module calls in the terraform files of dir
+func localModuleSourcesE(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	parser := hclparse.NewParser()
+	var sources []string
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil, fmt.Errorf("%s is not native HCL syntax", file)
+		}
+		for _, block := range body.Blocks {
+			source, exists := block.Body.Attributes["source"]
+			if block.Type != "module" || !exists {
+				continue
+			}
+			value, diags := source.Expr.Value(nil)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("source of module %s in %s is not a literal: %w", block.Labels[0], file, diags)
+			}
+			if local := value.AsString(); strings.HasPrefix(local, "./") || strings.HasPrefix(local, "../") {
+				sources = append(sources, local)
+			}
+		}
+	}
+	return sources, nil
+}
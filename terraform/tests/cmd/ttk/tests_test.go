@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestsTree writes files, keyed by path relative to the terraform root,
+// and returns the tests folder
+func writeTestsTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(root, "tests")
+}
+
+const sampleTests = `package test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// TestAppDeploy deploys the app fixture. It is slow
+func TestAppDeploy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("slow")
+	}
+	if os.Getenv("TEST_APP") != "true" {
+		t.Skip("Set TEST_APP=true")
+	}
+	_ = helpers.DefaultTerraformOptions(t, "./fixtures/app", nil)
+	helpers.BuildFixtureImage(t, "echo", "registry")
+	helpers.SharedWebhookReceiver(t)
+}
+
+// TestVaultValidation plans the vault module
+func TestVaultValidation(t *testing.T) {
+	_ = "../modules/vault"
+	_ = helpers.CopyModuleToTemp(t, "network")
+	_, _ = helpers.NewStack(helpers.StackModule{Name: "dns", Module: "private-dns"})
+}
+
+func helperNotATest(t *testing.T) {}
+
+func TestMain(m *testing.M) {}
+`
+
+func TestFindTests(t *testing.T) {
+	t.Parallel()
+
+	testsDir := writeTestsTree(t, map[string]string{
+		"tests/go.mod":               "module " + testsModulePath + "\n",
+		"tests/app_test.go":          sampleTests,
+		"tests/fixtures/app/main.tf": "module \"app\" {\n  source = \"../../../modules/app\"\n}\n",
+		"modules/app/main.tf":        "module \"identity\" {\n  source = \"../identity\"\n}\n\nmodule \"naming\" {\n  source = \"Azure/naming/azurerm\"\n}\n",
+		"modules/identity/main.tf":   "variable \"name\" {}\n",
+	})
+
+	tests, err := findTestsE(testsDir)
+	if !assert.NoError(t, err) || !assert.Len(t, tests, 2) {
+		return
+	}
+
+	assert.Equal(t, testInfo{
+		Name:    "TestAppDeploy",
+		File:    "app_test.go",
+		Line:    11,
+		Summary: "TestAppDeploy deploys the app fixture",
+		Slow:    true,
+		OptIn:   []string{"TEST_APP"},
+		Paths: []string{"../modules/app", "../modules/identity", "fixtures/app", "fixtures/apps/echo",
+			"fixtures/apps/webhook", "fixtures/webhook-receiver"},
+	}, tests[0])
+	assert.Equal(t, testInfo{
+		Name:    "TestVaultValidation",
+		File:    "app_test.go",
+		Line:    24,
+		Summary: "TestVaultValidation plans the vault module",
+		Paths:   []string{"../modules/network", "../modules/private-dns", "../modules/vault"},
+	}, tests[1])
+}
+
+func TestFindTestsExpectedFailure(t *testing.T) {
+	t.Parallel()
+
+	testsDir := writeTestsTree(t, map[string]string{
+		"tests/go.mod": "module " + testsModulePath + "\n",
+		"tests/known_test.go": `package test
+
+import (
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+func TestKnown(t *testing.T) {
+	helpers.ExpectedFailure(t, "https://github.com/pollinate/risk-scoring-api/issues/12")
+}
+`,
+	})
+
+	tests, err := findTestsE(testsDir)
+	if assert.NoError(t, err) && assert.Len(t, tests, 1) {
+		assert.Equal(t, "https://github.com/pollinate/risk-scoring-api/issues/12", tests[0].KnownIssue)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	testsDir := writeTestsTree(t, map[string]string{
+		"tests/go.mod":  "module " + testsModulePath + "\n\ngo 1.21\n",
+		"other/go.mod":  "module example.com/other\n",
+		"tests/helpers": "",
+	})
+	t.Setenv("ARM_LOCATION", "westeurope")
+	t.Setenv("TEST_RUN_ID", "Run42")
+	t.Setenv("RESUME_RUN_ID", "")
+
+	config, err := loadConfig(testsDir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, filepath.Dir(testsDir), config.TerraformRoot)
+		assert.Equal(t, "westeurope", config.Location)
+		assert.Equal(t, "run42", config.RunID)
+	}
+
+	found, err := findTestsDir(filepath.Join(testsDir, "helpers"))
+	assert.NoError(t, err)
+	assert.Equal(t, testsDir, found)
+	found, err = findTestsDir(filepath.Dir(filepath.Dir(testsDir)))
+	assert.Error(t, err, "terraform/tests is not below the temp folder")
+	assert.Empty(t, found)
+
+	_, err = loadConfig(filepath.Join(filepath.Dir(testsDir), "other"))
+	assert.Error(t, err)
+}
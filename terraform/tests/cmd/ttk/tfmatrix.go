@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/hashicorp/terraform-exec/tfinstall"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+func init() {
+	register(command{
+		name:    "tf-matrix",
+		summary: "run the validation suites against several terraform versions",
+		run:     runTerraformMatrix,
+	})
+}
+
+const (
+	// defaultMatrixVersions are the oldest terraform the modules support
+	// (minTerraformVersion), the first with the mock providers the native
+	// tests need, and the newest release the suites are known to pass on
+	defaultMatrixVersions = "1.5.7,1.7.5,1.9.8"
+
+	// defaultMatrixRun selects the suites that only plan: the input
+	// validation tests and the modules' native tests
+	defaultMatrixRun = "Validation$|^TestModuleNativeTerraformTests$"
+
+	// nativeTestsSuite runs the modules' native tests, whose mock providers
+	// need terraform >= minNativeTestVersion
+	nativeTestsSuite     = "TestModuleNativeTerraformTests"
+	minNativeTestVersion = "1.7.0"
+)
+
+// terraformInstaller returns the terraform binary of an exact version,
+// installing it when needed
+type terraformInstaller func(ctx context.Context, version string) (string, error)
+
+// suiteRunner runs go test with args in testsDir, with env as its whole
+// environment, and returns its -json output. A run with failed tests still
+// returns its output alongside the error
+type suiteRunner func(ctx context.Context, testsDir string, env []string, args ...string) ([]byte, error)
+
+// matrixVersion is the outcome of the suites under one terraform version
+type matrixVersion struct {
+	Version string `json:"version"`
+	// TerraformPath is the binary the suites ran with
+	TerraformPath string `json:"terraform_path,omitempty"`
+	// RunID is the TEST_RUN_ID of the version's run, naming its report folder
+	RunID   string `json:"run_id"`
+	Passed  int    `json:"passed"`
+	Skipped int    `json:"skipped"`
+	// Failed are the tests that failed under this version, without the
+	// parents of failed subtests
+	Failed []string `json:"failed,omitempty"`
+	// Error is why the suites did not run, e.g. a failed download or build
+	Error string `json:"error,omitempty"`
+	// Excluded says which suites were left out under this version, and why
+	Excluded string `json:"excluded,omitempty"`
+}
+
+// matrixResult is the outcome of the suites under every version
+type matrixResult struct {
+	Run      string          `json:"run"`
+	Versions []matrixVersion `json:"versions"`
+	// VersionSpecific maps the tests that failed under some versions and
+	// passed under others to the versions they failed under. These are the
+	// compatibility breaks; a test failing everywhere is broken regardless
+	VersionSpecific map[string][]string `json:"version_specific,omitempty"`
+}
+
+// ok reports whether every version ran and passed
+func (r matrixResult) ok() bool {
+	for _, version := range r.Versions {
+		if version.Error != "" || len(version.Failed) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r matrixResult) writeText(w io.Writer) {
+	for _, version := range r.Versions {
+		switch {
+		case version.Error != "":
+			fmt.Fprintf(w, "%-8s error  %s\n", version.Version, version.Error)
+		case len(version.Failed) > 0:
+			fmt.Fprintf(w, "%-8s FAIL   %d passed, %d skipped, %d failed\n", version.Version, version.Passed, version.Skipped, len(version.Failed))
+			for _, test := range version.Failed {
+				fmt.Fprintf(w, "           %s\n", test)
+			}
+		default:
+			fmt.Fprintf(w, "%-8s ok     %d passed, %d skipped\n", version.Version, version.Passed, version.Skipped)
+		}
+		if version.Excluded != "" {
+			fmt.Fprintf(w, "           %s\n", version.Excluded)
+		}
+	}
+	if len(r.VersionSpecific) > 0 {
+		tests := make([]string, 0, len(r.VersionSpecific))
+		for test := range r.VersionSpecific {
+			tests = append(tests, test)
+		}
+		sort.Strings(tests)
+		fmt.Fprintln(w, "\nFailing under some versions only:")
+		for _, test := range tests {
+			fmt.Fprintf(w, "  %s (%s)\n", test, strings.Join(r.VersionSpecific[test], ", "))
+		}
+	}
+}
+
+// runTerraformMatrix installs each requested terraform version with
+// terraform-exec's tfinstall and runs the selected suites with it first on
+// PATH, one version after the other. It exits 1 when a version fails
+func runTerraformMatrix(config *Config, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("tf-matrix", flag.ContinueOnError)
+	versions := flags.String("versions", defaultMatrixVersions, "comma-separated terraform versions to run the suites with")
+	runPattern := flags.String("run", defaultMatrixRun, "go test -run pattern selecting the suites")
+	installDir := flags.String("install-dir", "", "folder terraform versions are installed and kept in (default: the user cache folder)")
+	timeout := flags.Duration("timeout", 60*time.Minute, "go test timeout per version")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	matrix, err := parseMatrixVersions(*versions)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	if *installDir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		*installDir = filepath.Join(cacheDir, "ttk", "terraform")
+	}
+	runID := config.RunID
+	if runID == "" {
+		runID = "tf-matrix-" + time.Now().UTC().Format("20060102-150405")
+	}
+
+	run := func(ctx context.Context, testsDir string, env []string, args ...string) ([]byte, error) {
+		return goTestCommand(ctx, testsDir, env, append([]string{"-timeout", timeout.String()}, args...)...)
+	}
+	result := terraformMatrix(context.Background(), terraformVersionInstaller(*installDir), run,
+		config.TestsDir, matrix, *runPattern, runID, os.Environ())
+	if !result.ok() {
+		return result, errFailed
+	}
+	return result, nil
+}
+
+// parseMatrixVersions splits a comma-separated list of exact versions,
+// keeping the order and dropping duplicates
+func parseMatrixVersions(list string) ([]string, error) {
+	seen := map[string]bool{}
+	var versions []string
+	for _, version := range strings.Split(list, ",") {
+		version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+		if version == "" || seen[version] {
+			continue
+		}
+		if parts := strings.Split(strings.SplitN(version, "-", 2)[0], "."); len(parts) != 3 {
+			return nil, fmt.Errorf("%q is not an exact terraform version, e.g. 1.5.7", version)
+		}
+		seen[version] = true
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no terraform versions given")
+	}
+	return versions, nil
+}
+
+// terraformVersionInstaller installs each version in its own folder under
+// installDir, where it is reused by later runs. tfinstall checks downloads
+// against HashiCorp's signed checksums
+func terraformVersionInstaller(installDir string) terraformInstaller {
+	return func(ctx context.Context, version string) (string, error) {
+		dir := filepath.Join(installDir, version)
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return "", err
+		}
+		return tfinstall.Find(ctx,
+			tfinstall.ExactPath(filepath.Join(dir, terraformExecutable())),
+			tfinstall.ExactVersion(version, dir))
+	}
+}
+
+// terraformExecutable is the file name of the terraform binary
+func terraformExecutable() string {
+	if runtime.GOOS == "windows" {
+		return "terraform.exe"
+	}
+	return "terraform"
+}
+
+// goTestCommand runs go test in testsDir
+func goTestCommand(ctx context.Context, testsDir string, env []string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", append([]string{"test"}, args...)...)
+	cmd.Dir = testsDir
+	cmd.Env = env
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, err
+}
+
+// terraformMatrix runs the suites matching runPattern once per version,
+// leaving out the native tests under versions older than
+// minNativeTestVersion, which could not load their mock providers. The
+// binary install returns is checked with terraform-exec to be the version
+// asked for, then put first on PATH, which is where terratest and the
+// helpers find terraform. Each version's run gets its own TEST_RUN_ID, so
+// its reports and plan cache stay apart from the other versions'
+func terraformMatrix(ctx context.Context, install terraformInstaller, run suiteRunner, testsDir string, versions []string, runPattern, runID string, environ []string) matrixResult {
+	result := matrixResult{Run: runPattern}
+	outcomes := map[string]map[string]helpers.TestOutcome{}
+	for _, version := range versions {
+		outcome := matrixVersion{Version: version, RunID: runID + "-tf" + strings.ReplaceAll(version, ".", "-")}
+		tests, err := runMatrixVersion(ctx, install, run, testsDir, runPattern, &outcome, environ)
+		if err != nil {
+			outcome.Error = err.Error()
+		}
+		outcomes[version] = tests
+		result.Versions = append(result.Versions, outcome)
+	}
+	result.VersionSpecific = versionSpecificFailures(versions, outcomes)
+	return result
+}
+
+// runMatrixVersion runs the suites under outcome.Version and fills in
+// outcome. The error is for suites that could not run at all
+func runMatrixVersion(ctx context.Context, install terraformInstaller, run suiteRunner, testsDir, runPattern string, outcome *matrixVersion, environ []string) (map[string]helpers.TestOutcome, error) {
+	path, err := install(ctx, outcome.Version)
+	if err != nil {
+		return nil, fmt.Errorf("installing terraform %s: %w", outcome.Version, err)
+	}
+	outcome.TerraformPath = path
+	tf, err := tfexec.NewTerraform(testsDir, path)
+	if err != nil {
+		return nil, err
+	}
+	installed, _, err := tf.Version(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("reading the version of %s: %w", path, err)
+	}
+	if installed.String() != outcome.Version {
+		return nil, fmt.Errorf("%s is terraform %s, not %s", path, installed, outcome.Version)
+	}
+
+	args := []string{"-json", "-short", "-count=1", "-run", runPattern}
+	if compareVersions(outcome.Version, minNativeTestVersion) < 0 {
+		args = append(args, "-skip", "^"+nativeTestsSuite+"$")
+		outcome.Excluded = fmt.Sprintf("%s not run: terraform test with mock providers needs terraform >= %s",
+			nativeTestsSuite, minNativeTestVersion)
+	}
+	env := matrixEnv(environ, filepath.Dir(path), outcome.RunID)
+	output, runErr := run(ctx, testsDir, env, append(args, ".")...)
+	tests, err := helpers.ParseGoTestJSONE(bytes.NewReader(output))
+	if err != nil {
+		return nil, err
+	}
+	// go test fails when a test does, which the outcomes report; without
+	// any outcome the suites did not build or run
+	if runErr != nil && len(tests) == 0 {
+		return nil, fmt.Errorf("running the suites: %w", runErr)
+	}
+
+	for _, test := range tests {
+		switch test.Status {
+		case "pass":
+			outcome.Passed++
+		case "skip":
+			outcome.Skipped++
+		}
+	}
+	outcome.Failed = failedLeaves(tests)
+	return tests, nil
+}
+
+// matrixEnv returns environ with binDir first on PATH and TEST_RUN_ID set to
+// runID. RESUME_RUN_ID is dropped, as it would override TEST_RUN_ID
+func matrixEnv(environ []string, binDir, runID string) []string {
+	path := binDir
+	env := make([]string, 0, len(environ)+2)
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		switch name {
+		case "PATH":
+			if value != "" {
+				path += string(os.PathListSeparator) + value
+			}
+		case "TEST_RUN_ID", "RESUME_RUN_ID":
+		default:
+			env = append(env, variable)
+		}
+	}
+	return append(env, "PATH="+path, "TEST_RUN_ID="+runID)
+}
+
+// sortedTests returns the names of tests, sorted
+func sortedTests(tests map[string]helpers.TestOutcome) []string {
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// failedLeaves returns the failed tests of tests, leaving out those that
+// failed only because one of their subtests did
+func failedLeaves(tests map[string]helpers.TestOutcome) []string {
+	parents := map[string]bool{}
+	for name, outcome := range tests {
+		for outcome.Status == "fail" && strings.Contains(name, "/") {
+			name = name[:strings.LastIndex(name, "/")]
+			parents[name] = true
+		}
+	}
+	var leaves []string
+	for _, name := range sortedTests(tests) {
+		if tests[name].Status == "fail" && !parents[name] {
+			leaves = append(leaves, name)
+		}
+	}
+	return leaves
+}
+
+// versionSpecificFailures maps each test that failed under some versions and
+// passed under at least one other to the versions it failed under, in the
+// order of versions
+func versionSpecificFailures(versions []string, outcomes map[string]map[string]helpers.TestOutcome) map[string][]string {
+	passed := map[string]bool{}
+	for _, tests := range outcomes {
+		for name, outcome := range tests {
+			if outcome.Status == "pass" {
+				passed[name] = true
+			}
+		}
+	}
+
+	specific := map[string][]string{}
+	for _, version := range versions {
+		for _, name := range failedLeaves(outcomes[version]) {
+			if passed[name] {
+				specific[name] = append(specific[name], version)
+			}
+		}
+	}
+	if len(specific) == 0 {
+		return nil
+	}
+	return specific
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTerraformVersion writes a script standing in for terraform version
+// reporting version
+func fakeTerraformVersion(t *testing.T, version string) string {
+	binary := filepath.Join(t.TempDir(), "terraform")
+	script := fmt.Sprintf("#!/bin/sh\necho '{\"terraform_version\": \"%s\", \"provider_selections\": {}}'\n", version)
+	if err := os.WriteFile(binary, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return binary
+}
+
+// goTestEvents renders test outcomes as go test -json output
+func goTestEvents(outcomes map[string]string) []byte {
+	var output bytes.Buffer
+	for test, action := range outcomes {
+		fmt.Fprintf(&output, "{\"Action\": %q, \"Package\": \"tests\", \"Test\": %q, \"Elapsed\": 0.1}\n", action, test)
+	}
+	return output.Bytes()
+}
+
+func TestParseMatrixVersions(t *testing.T) {
+	t.Parallel()
+
+	versions, err := parseMatrixVersions(" 1.5.7, v1.9.8,1.5.7,,1.10.0-beta1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"1.5.7", "1.9.8", "1.10.0-beta1"}, versions)
+	}
+	_, err = parseMatrixVersions("1.5")
+	assert.Error(t, err, "a version constraint is not an exact version")
+	_, err = parseMatrixVersions(" , ")
+	assert.Error(t, err)
+
+	defaults, err := parseMatrixVersions(defaultMatrixVersions)
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(defaults[0], strings.TrimSuffix(minTerraformVersion, ".0")+"."),
+			"the matrix should start at the oldest terraform the modules support, %s", minTerraformVersion)
+	}
+}
+
+func TestMatrixEnv(t *testing.T) {
+	t.Parallel()
+
+	env := matrixEnv([]string{"HOME=/home/ci", "PATH=/usr/bin:/bin", "TEST_RUN_ID=ci-42", "RESUME_RUN_ID=ci-41"}, "/cache/1.5.7", "ci-42-tf1-5-7")
+	assert.ElementsMatch(t, []string{"HOME=/home/ci", "PATH=/cache/1.5.7" + string(os.PathListSeparator) + "/usr/bin:/bin", "TEST_RUN_ID=ci-42-tf1-5-7"}, env)
+	assert.Contains(t, matrixEnv(nil, "/cache/1.5.7", "run"), "PATH=/cache/1.5.7")
+}
+
+func TestFailedLeaves(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"TestA-b", "TestA/case", "TestC"}, failedLeaves(map[string]helpers.TestOutcome{
+		"TestA":      {Status: "fail"},
+		"TestA/case": {Status: "fail"},
+		"TestA/ok":   {Status: "pass"},
+		"TestA-b":    {Status: "fail"},
+		"TestB":      {Status: "pass"},
+		"TestC":      {Status: "fail"},
+	}))
+	assert.Empty(t, failedLeaves(nil))
+}
+
+func TestTerraformMatrix(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake terraform is a shell script")
+	}
+
+	binaries := map[string]string{
+		"1.5.7": fakeTerraformVersion(t, "1.5.7"),
+		"1.7.5": fakeTerraformVersion(t, "1.7.5"),
+		// A stale binary in the install folder must not pass for 1.9.8
+		"1.9.8": fakeTerraformVersion(t, "1.9.0"),
+	}
+	install := func(ctx context.Context, version string) (string, error) {
+		if binary, exists := binaries[version]; exists {
+			return binary, nil
+		}
+		return "", errors.New("no such release")
+	}
+
+	var paths []string
+	run := func(ctx context.Context, testsDir string, env []string, args ...string) ([]byte, error) {
+		var path, runID string
+		for _, variable := range env {
+			if value, found := strings.CutPrefix(variable, "PATH="); found {
+				path = value
+			}
+			if value, found := strings.CutPrefix(variable, "TEST_RUN_ID="); found {
+				runID = value
+			}
+		}
+		paths = append(paths, path)
+		switch runID {
+		case "ci-42-tf1-5-7":
+			assert.Equal(t, []string{"-json", "-short", "-count=1", "-run", defaultMatrixRun,
+				"-skip", "^TestModuleNativeTerraformTests$", "."}, args, "native tests need terraform 1.7")
+			return goTestEvents(map[string]string{
+				"TestKeyVaultNameValidation":           "pass",
+				"TestContainerAppInputValidation":      "fail",
+				"TestContainerAppInputValidation/case": "fail",
+				"TestObservabilityRetentionValidation": "fail",
+			}), errors.New("exit status 1")
+		case "ci-42-tf1-7-5":
+			assert.Equal(t, []string{"-json", "-short", "-count=1", "-run", defaultMatrixRun, "."}, args)
+			return goTestEvents(map[string]string{
+				"TestKeyVaultNameValidation":           "pass",
+				"TestContainerAppInputValidation":      "pass",
+				"TestContainerAppInputValidation/case": "pass",
+				"TestModuleNativeTerraformTests":       "pass",
+				"TestObservabilityRetentionValidation": "fail",
+			}), errors.New("exit status 1")
+		}
+		t.Errorf("Unexpected run %s", runID)
+		return nil, nil
+	}
+
+	testsDir := t.TempDir()
+	result := terraformMatrix(context.Background(), install, run, testsDir, []string{"1.5.7", "1.7.5", "1.9.8", "1.4.0"},
+		defaultMatrixRun, "ci-42", []string{"PATH=/usr/bin"})
+
+	assert.Equal(t, []string{
+		filepath.Dir(binaries["1.5.7"]) + string(os.PathListSeparator) + "/usr/bin",
+		filepath.Dir(binaries["1.7.5"]) + string(os.PathListSeparator) + "/usr/bin",
+	}, paths, "each version should run with its own terraform first on PATH")
+	if !assert.Len(t, result.Versions, 4) {
+		return
+	}
+	assert.Equal(t, matrixVersion{
+		Version:       "1.5.7",
+		TerraformPath: binaries["1.5.7"],
+		RunID:         "ci-42-tf1-5-7",
+		Passed:        1,
+		Failed:        []string{"TestContainerAppInputValidation/case", "TestObservabilityRetentionValidation"},
+		Excluded:      "TestModuleNativeTerraformTests not run: terraform test with mock providers needs terraform >= 1.7.0",
+	}, result.Versions[0])
+	assert.Equal(t, 4, result.Versions[1].Passed)
+	assert.Equal(t, []string{"TestObservabilityRetentionValidation"}, result.Versions[1].Failed)
+	assert.Contains(t, result.Versions[2].Error, "is terraform 1.9.0, not 1.9.8")
+	assert.Contains(t, result.Versions[3].Error, "installing terraform 1.4.0: no such release")
+
+	assert.Equal(t, map[string][]string{"TestContainerAppInputValidation/case": {"1.5.7"}}, result.VersionSpecific,
+		"only the failure that passes under another version is a compatibility break")
+	assert.False(t, result.ok())
+
+	var text bytes.Buffer
+	result.writeText(&text)
+	assert.Contains(t, text.String(), "1.7.5    FAIL   4 passed, 0 skipped, 1 failed\n")
+	assert.Contains(t, text.String(), "\n           TestModuleNativeTerraformTests not run: terraform test with mock providers needs terraform >= 1.7.0\n")
+	assert.Contains(t, text.String(), "Failing under some versions only:\n  TestContainerAppInputValidation/case (1.5.7)\n")
+}
+
+func TestTerraformMatrixBuildFailure(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake terraform is a shell script")
+	}
+
+	binary := fakeTerraformVersion(t, "1.5.7")
+	install := func(ctx context.Context, version string) (string, error) { return binary, nil }
+	run := func(ctx context.Context, testsDir string, env []string, args ...string) ([]byte, error) {
+		return []byte("# github.com/pollinate/risk-scoring-api/terraform/tests\n./x_test.go:1: syntax error\n"), errors.New("exit status 1")
+	}
+
+	result := terraformMatrix(context.Background(), install, run, t.TempDir(), []string{"1.5.7"}, defaultMatrixRun, "run", nil)
+	if assert.Len(t, result.Versions, 1) {
+		assert.Equal(t, "running the suites: exit status 1", result.Versions[0].Error)
+	}
+	assert.False(t, result.ok())
+	assert.Nil(t, result.VersionSpecific)
+}
@@ -0,0 +1,159 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// defaultColdStartSLO is the default budget for the first request to an app
+// that has scaled to zero
+const defaultColdStartSLO = 30 * time.Second
+
+// coldStartMeasurement is one scale-from-zero request and a warm request
+// right after it, for comparison
+type coldStartMeasurement struct {
+	Region string `json:"region"`
+	Image  string `json:"image"`
+	// IdleSeconds is how long the app took to scale to zero once idle
+	IdleSeconds        float64 `json:"idle_seconds"`
+	ColdStatus         int     `json:"cold_status"`
+	ColdLatencySeconds float64 `json:"cold_latency_seconds"`
+	WarmLatencySeconds float64 `json:"warm_latency_seconds"`
+	SLOSeconds         float64 `json:"slo_seconds"`
+	MeasuredAt         string  `json:"measured_at"`
+}
+
+// TestContainerAppColdStartLatency deploys the public fixture app with
+// min_replicas = 0, waits without sending requests until it has no replica
+// left, then times the first request, which has to start a replica, and a
+// warm request after it. The measurement goes to the cold_start report and
+// the first request is asserted against the SLO. Opt in with
+// TEST_COLD_START=true; override the budget with TEST_COLD_START_SLO_SECONDS
+func TestContainerAppColdStartLatency(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_COLD_START") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_COLD_START=true to measure scale-to-zero cold-start latency")
+	}
+
+	slo := defaultColdStartSLO
+	if value := os.Getenv("TEST_COLD_START_SLO_SECONDS"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			t.Fatalf("Invalid TEST_COLD_START_SLO_SECONDS %q: %v", value, err)
+		}
+		slo = time.Duration(seconds) * time.Second
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name": config.GenerateResourceGroupName("cold"),
+			"location":            config.Location,
+			"name_suffix":         config.UniqueID,
+			"min_replicas":        0,
+			"tags":                helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-public", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApplyWithRegionFallback(t, config, terraformOptions, vars)
+	phases.Start("verify")
+
+	resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+	appName := terraform.Output(t, terraformOptions, "container_app_name")
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+
+	// Serve once so the revision is known to work before it goes idle
+	client := &http.Client{Timeout: slo + 2*time.Minute}
+	retry.DoWithRetry(t, "waiting for the app to serve", 30, 20*time.Second, func() (string, error) {
+		status, _, err := timeRequest(client, applicationURL)
+		if err != nil {
+			return "", err
+		}
+		if status != http.StatusOK {
+			return "", fmt.Errorf("%s returned %d", applicationURL, status)
+		}
+		return "", nil
+	})
+
+	// Scale-in follows the HTTP scale rule's cooldown, about five minutes;
+	// nothing may call the app meanwhile
+	idleSince := time.Now()
+	_, err := retry.DoWithRetryE(t, "waiting for the app to scale to zero", 40, 30*time.Second, func() (string, error) {
+		replicas, err := helpers.ReplicaCountE(t, resourceGroupName, appName)
+		if err != nil {
+			return "", err
+		}
+		if replicas > 0 {
+			return "", fmt.Errorf("%s still has %d replicas", appName, replicas)
+		}
+		return "", nil
+	})
+	if err != nil {
+		t.Fatalf("App did not scale to zero: %v", err)
+	}
+	idle := time.Since(idleSince)
+
+	coldStatus, coldLatency, err := timeRequest(client, applicationURL)
+	if err != nil {
+		t.Fatalf("First request after scaling to zero failed after %s: %v", coldLatency, err)
+	}
+	_, warmLatency, err := timeRequest(client, applicationURL)
+	if err != nil {
+		t.Fatalf("Warm request failed: %v", err)
+	}
+
+	measurement := coldStartMeasurement{
+		Region:             config.Location,
+		Image:              terraform.Output(t, terraformOptions, "container_image"),
+		IdleSeconds:        idle.Seconds(),
+		ColdStatus:         coldStatus,
+		ColdLatencySeconds: coldLatency.Seconds(),
+		WarmLatencySeconds: warmLatency.Seconds(),
+		SLOSeconds:         slo.Seconds(),
+		MeasuredAt:         time.Now().UTC().Format(time.RFC3339),
+	}
+	helpers.RecordReport(t, "cold_start", config.Location, measurement)
+
+	assert.Equal(t, http.StatusOK, coldStatus, "the first request should be served once a replica starts")
+	assert.LessOrEqual(t, measurement.ColdLatencySeconds, measurement.SLOSeconds,
+		"Cold-start latency %.1fs exceeds the %s SLO (warm request: %.2fs)", measurement.ColdLatencySeconds, slo, measurement.WarmLatencySeconds)
+}
+
+// timeRequest sends a GET to endpoint and returns the status and the time
+// until the whole response was read
+func timeRequest(client *http.Client, endpoint string) (int, time.Duration, error) {
+	started := time.Now()
+	response, err := client.Get(endpoint)
+	if err != nil {
+		return 0, time.Since(started), err
+	}
+	defer response.Body.Close()
+	if _, err := io.Copy(io.Discard, response.Body); err != nil {
+		return response.StatusCode, time.Since(started), err
+	}
+	return response.StatusCode, time.Since(started), nil
+}
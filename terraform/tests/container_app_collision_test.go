@@ -0,0 +1,133 @@
+package test
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// collisionApp is an entry of the container-app-collision fixture's apps
+// output
+type collisionApp struct {
+	ID                       string `json:"id"`
+	Name                     string `json:"name"`
+	EnvironmentID            string `json:"environment_id"`
+	EnvironmentName          string `json:"environment_name"`
+	EnvironmentDefaultDomain string `json:"environment_default_domain"`
+	IngressFQDN              string `json:"ingress_fqdn"`
+	ApplicationURL           string `json:"application_url"`
+}
+
+// TestContainerAppNameCollisions deploys one instance of the container-app
+// module, then plans a second one in the same resource group reusing its
+// app name in another environment, or its environment for another app.
+// Both must be refused by the module's preconditions at plan, before Azure
+// is asked for anything. A second instance with names of its own, sharing
+// the first's name prefix and port, must then deploy next to it, and each
+// instance's outputs must describe its own app and environment
+func TestContainerAppNameCollisions(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	firstApp := "ca-col-" + config.UniqueID
+	firstEnvironment := "cae-col-" + config.UniqueID
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-collision", map[string]interface{}{
+		"resource_group_name":    config.GenerateResourceGroupName("col"),
+		"location":               config.Location,
+		"name_suffix":            config.UniqueID,
+		"first_app_name":         firstApp,
+		"first_environment_name": firstEnvironment,
+		"tags":                   helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	collisions := []struct {
+		name            string
+		app             string
+		environment     string
+		expectedMessage string
+	}{
+		{
+			name:            "app_name_in_another_environment",
+			app:             firstApp,
+			environment:     firstEnvironment + "-b",
+			expectedMessage: fmt.Sprintf("A Container App named %s already runs in environment %s", firstApp, firstEnvironment),
+		},
+		{
+			name:            "environment_of_another_instance",
+			app:             firstApp + "-b",
+			environment:     firstEnvironment,
+			expectedMessage: fmt.Sprintf("Container App environment %s in resource group %s already runs %s", firstEnvironment, terraformOptions.Vars["resource_group_name"], firstApp),
+		},
+	}
+	for _, collision := range collisions {
+		terraformOptions.Vars["second_app_name"] = collision.app
+		terraformOptions.Vars["second_environment_name"] = collision.environment
+		_, err := terraform.PlanE(t, terraformOptions)
+		if assert.Error(t, err, "%s: the plan should refuse the second instance", collision.name) {
+			assert.Contains(t, err.Error(), "Resource precondition failed", collision.name)
+			assert.Contains(t, err.Error(), collision.expectedMessage, collision.name)
+		}
+	}
+
+	// Names that only share a prefix, on the same port, do not collide
+	phases.Start("apply")
+	terraformOptions.Vars["second_app_name"] = firstApp + "-b"
+	terraformOptions.Vars["second_environment_name"] = firstEnvironment + "-b"
+	helpers.Apply(t, terraformOptions)
+	phases.Start("verify")
+	// A re-plan sees both apps deployed; neither may count as a collision
+	helpers.AssertIdempotent(t, terraformOptions)
+
+	var apps map[string]collisionApp
+	terraform.OutputStruct(t, terraformOptions, "apps", &apps)
+	expected := map[string][2]string{
+		"first":  {firstApp, firstEnvironment},
+		"second": {firstApp + "-b", firstEnvironment + "-b"},
+	}
+	if !assert.Len(t, apps, len(expected)) {
+		return
+	}
+	assert.NotEqual(t, apps["first"].ID, apps["second"].ID)
+	assert.NotEqual(t, apps["first"].EnvironmentID, apps["second"].EnvironmentID)
+	assert.NotEqual(t, apps["first"].EnvironmentDefaultDomain, apps["second"].EnvironmentDefaultDomain)
+
+	for key, names := range expected {
+		app := apps[key]
+		assert.Equal(t, names[0], app.Name, "%s app name", key)
+		assert.Equal(t, names[1], app.EnvironmentName, "%s environment name", key)
+		assert.Equal(t, app.Name+"."+app.EnvironmentDefaultDomain, app.IngressFQDN, "%s FQDN should be its app in its environment", key)
+
+		applicationURL, err := url.Parse(app.ApplicationURL)
+		if assert.NoError(t, err, "%s application URL", key) {
+			assert.True(t, strings.HasPrefix(applicationURL.Host, app.Name+"--") && strings.HasSuffix(applicationURL.Host, "."+app.EnvironmentDefaultDomain),
+				"%s application URL %s should be a revision of its app in its environment", key, app.ApplicationURL)
+		}
+
+		var environmentID string
+		helpers.AzCLIJSON(t, &environmentID, "containerapp", "show", "--ids", app.ID, "--query", "properties.managedEnvironmentId")
+		assert.True(t, strings.EqualFold(app.EnvironmentID, environmentID),
+			"%s environment_id %s should be the environment Azure runs the app in, %s", key, app.EnvironmentID, environmentID)
+
+		helpers.RequireEndpointReady(t, "https://"+app.IngressFQDN)
+	}
+}
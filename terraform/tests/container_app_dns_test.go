@@ -0,0 +1,128 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// resolveResult is the response of the echo fixture's /resolve endpoint
+type resolveResult struct {
+	Host      string   `json:"host"`
+	Addresses []string `json:"addresses"`
+	Error     string   `json:"error"`
+}
+
+// TestContainerAppCustomDNS tests name resolution from inside a VNet-integrated
+// Container App, both with Azure-provided DNS and with custom DNS servers
+// pointing at a Private DNS Resolver. The private zone record must resolve and
+// public names must still resolve through the custom servers
+func TestContainerAppCustomDNS(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	testCases := []struct {
+		mode          string
+		customServers bool
+	}{
+		{"azure_provided", false},
+		{"private_resolver", true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.mode, func(t *testing.T) {
+			t.Parallel()
+
+			config := helpers.NewTestConfig(t)
+			cost.TrackRun(t, config)
+			vars := func() map[string]interface{} {
+				return map[string]interface{}{
+					"resource_group_name": config.GenerateResourceGroupName("ca-dns"),
+					"location":            config.Location,
+					"name_suffix":         config.UniqueID,
+					"dns_mode":            tc.mode,
+					"tags":                helpers.StandardTags(t.Name()),
+				}
+			}
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-dns", vars())
+			helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+			phases := helpers.TrackPhases(t)
+			helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+			defer helpers.Destroy(t, terraformOptions)
+			defer phases.Start("destroy")
+
+			// First apply creates the network, DNS and registry; the app
+			// follows once the echo image is in the registry
+			phases.Start("apply")
+			helpers.DeployWithRegionFallback(t, config, terraformOptions, vars, func() error {
+				if _, err := terraform.InitAndApplyE(t, terraformOptions); err != nil {
+					return err
+				}
+				phases.Start("build")
+				image := helpers.BuildFixtureImage(t, "echo", terraform.Output(t, terraformOptions, "registry_login_server"))
+				terraformOptions.Vars["container_image"] = image.Reference
+				phases.Start("apply")
+				_, err := terraform.ApplyE(t, terraformOptions)
+				return err
+			})
+			phases.Start("verify")
+
+			dnsServers := terraform.OutputList(t, terraformOptions, "vnet_dns_servers")
+			assert.Equal(t, tc.customServers, len(dnsServers) > 0, "VNet custom DNS servers configured")
+
+			applicationURL := terraform.Output(t, terraformOptions, "application_url")
+			probeFQDN := terraform.Output(t, terraformOptions, "probe_record_fqdn")
+			probeIP := terraform.Output(t, terraformOptions, "probe_record_ip")
+
+			helpers.RequireEndpointReady(t, applicationURL)
+
+			// The first replica can take a few minutes to start on a new environment
+			private := resolveFromApp(t, applicationURL, probeFQDN)
+			assert.Empty(t, private.Error, "Private zone record should resolve inside the app")
+			assert.Equal(t, []string{probeIP}, private.Addresses, "Private zone record address")
+
+			public := resolveFromApp(t, applicationURL, "mcr.microsoft.com")
+			assert.Empty(t, public.Error, "Public names should still resolve inside the app")
+			assert.NotEmpty(t, public.Addresses, "Public name addresses")
+		})
+	}
+}
+
+// resolveFromApp asks the echo fixture at applicationURL to resolve host,
+// retrying until the app answers
+func resolveFromApp(t *testing.T, applicationURL, host string) resolveResult {
+	endpoint := fmt.Sprintf("%s/resolve?host=%s", applicationURL, url.QueryEscape(host))
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var result resolveResult
+	retry.DoWithRetry(t, fmt.Sprintf("resolving %s from the container app", host), 30, 20*time.Second, func() (string, error) {
+		response, err := client.Get(endpoint)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s returned %d", endpoint, response.StatusCode)
+		}
+		if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+			return "", fmt.Errorf("decoding resolve result: %w", err)
+		}
+		return "", nil
+	})
+	return result
+}
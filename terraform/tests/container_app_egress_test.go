@@ -0,0 +1,178 @@
+package test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// disallowedEgressTargets are destinations outside the networking module's
+// default allow-list: general internet, package registries, Azure Resource
+// Manager, and plain HTTP to an otherwise harmless host
+var disallowedEgressTargets = []string{
+	"https://example.com/",
+	"https://www.bing.com/",
+	"https://api.github.com/",
+	"https://pypi.org/simple/",
+	"https://management.azure.com/",
+	"http://example.com/",
+}
+
+// TestContainerAppEgressAllowList deploys the echo fixture app behind the
+// networking module's egress firewall with the default allow-list. The list
+// must be sufficient: the image is pulled from ACR, the app reads a Key Vault
+// secret with its identity and Application Insights accepts its telemetry.
+// It must also be tight: requests from the app to anything else are refused.
+// Opt in with TEST_EGRESS_FIREWALL=true, since the firewall is billed hourly
+func TestContainerAppEgressAllowList(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_EGRESS_FIREWALL") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_EGRESS_FIREWALL=true to deploy an egress firewall and verify its allow-list")
+	}
+
+	const secretName = "egress-probe"
+
+	runnerIP, err := helpers.RunnerPublicIPE(t)
+	if err != nil {
+		t.Fatalf("Finding the runner's public IP: %v", err)
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	secretValue := "egress-" + config.UniqueID
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/egress-firewall", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("egr"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"runner_ip":           runnerIP,
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	// A Standard firewall is about $900 a month; fail before applying anything dearer
+	helpers.AssertCostBelow(t, helpers.EstimatePlanCost(t, terraformOptions), 1000)
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	// First apply: firewall, observability stack, registry and vault
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	vaultName := terraform.Output(t, terraformOptions, "key_vault_name")
+	vaultURI := terraform.Output(t, terraformOptions, "vault_uri")
+	loginServer := terraform.Output(t, terraformOptions, "registry_login_server")
+	if !helpers.AssertKeyVaultURI(t, vaultURI, vaultName) {
+		t.Fatal("The probe reads the vault through its URI")
+	}
+
+	// The deployer's data-plane role can take a few minutes to propagate
+	retry.DoWithRetry(t, "writing the probe secret", 18, 10*time.Second, func() (string, error) {
+		return helpers.AzCLIE(t, "keyvault", "secret", "set", "--vault-name", vaultName,
+			"--name", secretName, "--value", secretValue, "--query", "id", "--output", "tsv")
+	})
+
+	phases.Start("build")
+	image := helpers.BuildFixtureImage(t, "echo", loginServer)
+
+	// Second apply: the app, whose first image pull goes through the firewall
+	phases.Start("apply")
+	terraformOptions.Vars["container_image"] = image.Reference
+	helpers.Apply(t, terraformOptions)
+	phases.Start("verify")
+
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+
+	// Sufficient: a replica serving requests means the platform images and
+	// the app image were pulled through the firewall
+	client := &http.Client{Timeout: 30 * time.Second}
+	retry.DoWithRetry(t, "waiting for the app behind the egress firewall", 30, 20*time.Second, func() (string, error) {
+		response, err := client.Get(applicationURL + "/health")
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s/health returned %d", applicationURL, response.StatusCode)
+		}
+		return "", nil
+	})
+
+	registryProbe, err := helpers.ProbeEgressE(t, applicationURL, fmt.Sprintf("https://%s/v2/", loginServer))
+	if assert.NoError(t, err) {
+		helpers.RecordReport(t, "egress", registryProbe.URL, registryProbe)
+		assert.True(t, registryProbe.Reached(), "the app should reach its registry, got status %d: %s", registryProbe.Status, registryProbe.Error)
+	}
+
+	// Sufficient: managed identity token and Key Vault read
+	readURL := fmt.Sprintf("%s/keyvault?vault=%s&secret=%s", applicationURL, url.QueryEscape(vaultURI), secretName)
+	var read keyVaultRead
+	// The app's Key Vault Secrets User assignment can take minutes to reach
+	// the data plane
+	_, err = retry.DoWithRetryE(t, "reading the secret through the firewall", 30, 20*time.Second, func() (string, error) {
+		response, err := client.Get(readURL)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		read = keyVaultRead{}
+		if err := json.NewDecoder(response.Body).Decode(&read); err != nil {
+			return "", err
+		}
+		if read.Status != http.StatusOK {
+			return "", fmt.Errorf("app read returned %d %s %s", read.Status, read.Code, read.Error)
+		}
+		return "", nil
+	})
+	if assert.NoError(t, err, "the app should read Key Vault through the firewall") {
+		expected := sha256.Sum256([]byte(secretValue))
+		assert.Equal(t, hex.EncodeToString(expected[:]), read.ValueSHA256)
+	}
+
+	// Sufficient: Application Insights accepts the app's telemetry
+	var traced traceResponse
+	_, err = retry.DoWithRetryE(t, "sending telemetry through the firewall", 6, 10*time.Second, func() (string, error) {
+		response, err := client.Get(applicationURL + "/trace")
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		traced = traceResponse{}
+		if err := json.NewDecoder(response.Body).Decode(&traced); err != nil {
+			return "", err
+		}
+		if traced.TelemetryError != "" {
+			return "", fmt.Errorf("telemetry refused: %s", traced.TelemetryError)
+		}
+		return "", nil
+	})
+	assert.NoError(t, err, "the app should send telemetry to Application Insights through the firewall")
+
+	// Tight: everything else is refused, by a reset for HTTPS or the
+	// firewall's deny status for plain HTTP
+	for _, target := range disallowedEgressTargets {
+		probe, err := helpers.ProbeEgressE(t, applicationURL, target)
+		if !assert.NoError(t, err, "probing %s", target) {
+			continue
+		}
+		helpers.RecordReport(t, "egress", target, probe)
+		assert.False(t, probe.Reached(), "the firewall should refuse %s, but the app got status %d", target, probe.Status)
+	}
+}
@@ -0,0 +1,174 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// envTestPrefix starts the name of every variable the test sets, so the
+	// echo fixture's /env endpoint dumps exactly those
+	envTestPrefix = "ENV_TEST_"
+
+	// envAppAddress is the app in the container-app-env fixture
+	envAppAddress = "module.container_app[0].azurerm_container_app.this"
+)
+
+// envTestJSON is a JSON payload passed as a variable value
+const envTestJSON = `{"service":"risk-scoring","weights":[0.25,1e-3],"nested":{"quote":"a \"b\"","path":"C:\\temp","template":"${var.name}"}}`
+
+// envTestValues are values terraform, the provider or the container runtime
+// could mangle: template and shell sequences, quotes, control characters,
+// JSON and non-ASCII text
+var envTestValues = map[string]string{
+	envTestPrefix + "DOLLAR":     "costs $5 or $HOME and $$",
+	envTestPrefix + "TEMPLATE":   "${var.name} %{if true}directive%{endif} $${escaped}",
+	envTestPrefix + "SHELL":      "$(echo injected) `id` ; && | > /dev/null",
+	envTestPrefix + "QUOTES":     `it's "double" 'single' \ backslash \n not a newline`,
+	envTestPrefix + "NEWLINES":   "line one\nline two\r\n\ttabbed\n",
+	envTestPrefix + "JSON":       envTestJSON,
+	envTestPrefix + "EQUALS":     "key=value==",
+	envTestPrefix + "UNICODE":    "héllo wörld ✓ 日本",
+	envTestPrefix + "WHITESPACE": "  leading and trailing  ",
+}
+
+// TestContainerAppEnvironmentVariableValues deploys the echo fixture app with
+// environment variables whose values contain $, quotes, newlines and JSON.
+// The values must reach the plan unchanged and, once the app runs, the
+// container must see exactly the same bytes through the /env endpoint
+func TestContainerAppEnvironmentVariableValues(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	// Logs go to the run's shared workspace instead of one per test
+	shared := helpers.AcquireSharedObservability(t)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name":        config.GenerateResourceGroupName("ca-env"),
+			"location":                   config.Location,
+			"name_suffix":                config.UniqueID,
+			"log_analytics_workspace_id": shared.WorkspaceID,
+			"tags":                       helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-env", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	// -var arguments are parsed as HCL, which would read the values as
+	// templates and escapes, so they go through a JSON var file instead
+	helpers.WriteTFVarsFile(t, filepath.Join(terraformOptions.TerraformDir, "environment.tfvars.json"), map[string]interface{}{
+		"environment_variables": envTestValues,
+	})
+	terraformOptions.VarFiles = []string{"environment.tfvars.json"}
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	// First apply creates the workspace and registry; the app follows once
+	// the echo image is in the registry
+	phases.Start("apply")
+	helpers.DeployWithRegionFallback(t, config, terraformOptions, vars, func() error {
+		if _, err := terraform.InitAndApplyE(t, terraformOptions); err != nil {
+			return err
+		}
+		phases.Start("build")
+		image := helpers.BuildFixtureImage(t, "echo", terraform.Output(t, terraformOptions, "registry_login_server"))
+		terraformOptions.Vars["container_image"] = image.Reference
+
+		// The plan must carry the values unchanged before Azure sees them
+		planOptions := *terraformOptions
+		planOptions.PlanFilePath = filepath.Join(terraformOptions.TerraformDir, "env.tfplan")
+		plan := helpers.InitAndPlanAndShowWithStruct(t, &planOptions)
+		app := plan.ResourcePlannedValuesMap[envAppAddress]
+		if assert.NotNil(t, app, "Plan should contain the container app") {
+			assert.Equal(t, envTestValues, plannedContainerEnv(t, app.AttributeValues), "planned environment variables")
+		}
+
+		phases.Start("apply")
+		_, err := terraform.ApplyE(t, terraformOptions)
+		return err
+	})
+	phases.Start("verify")
+
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+
+	environment := envFromApp(t, applicationURL, envTestPrefix)
+	for name, expected := range envTestValues {
+		assert.Equal(t, expected, environment[name], "%s inside the container", name)
+	}
+	assert.Len(t, environment, len(envTestValues), "the container should see exactly the variables set")
+
+	var payload map[string]interface{}
+	if assert.NoError(t, json.Unmarshal([]byte(environment[envTestPrefix+"JSON"]), &payload), "the JSON value should still parse") {
+		var expected map[string]interface{}
+		if err := json.Unmarshal([]byte(envTestJSON), &expected); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, payload)
+	}
+}
+
+// plannedContainerEnv returns the plain environment variables of the main
+// container in a planned azurerm_container_app
+func plannedContainerEnv(t *testing.T, attributes map[string]interface{}) map[string]string {
+	environment := map[string]string{}
+	templates, _ := attributes["template"].([]interface{})
+	if !assert.Len(t, templates, 1) {
+		return environment
+	}
+	containers, _ := templates[0].(map[string]interface{})["container"].([]interface{})
+	if !assert.NotEmpty(t, containers) {
+		return environment
+	}
+	env, _ := containers[0].(map[string]interface{})["env"].([]interface{})
+	for _, entry := range env {
+		variable := entry.(map[string]interface{})
+		if value, ok := variable["value"].(string); ok {
+			environment[variable["name"].(string)] = value
+		}
+	}
+	return environment
+}
+
+// envFromApp reads the variables starting with prefix from the echo fixture
+// at applicationURL, retrying until the app answers
+func envFromApp(t *testing.T, applicationURL, prefix string) map[string]string {
+	endpoint := fmt.Sprintf("%s/env?prefix=%s", applicationURL, prefix)
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var environment map[string]string
+	retry.DoWithRetry(t, "reading environment variables from the container app", 30, 20*time.Second, func() (string, error) {
+		response, err := client.Get(endpoint)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s returned %d", endpoint, response.StatusCode)
+		}
+		if err := json.NewDecoder(response.Body).Decode(&environment); err != nil {
+			return "", fmt.Errorf("decoding environment: %w", err)
+		}
+		return "", nil
+	})
+	return environment
+}
@@ -0,0 +1,171 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// ephemeralCPU is the CPU of the fixture's container, which sets its
+// ephemeral storage
+const ephemeralCPU = 0.5
+
+// ephemeralFilesDir is the folder of the container's own filesystem the app
+// writes files to
+const ephemeralFilesDir = "/scratch"
+
+// TestContainerAppEphemeralStorage deploys the echo fixture app as a single
+// replica writing files to a folder of its own filesystem. A file written
+// there must be read back until the revision restarts, and be gone on the
+// replica that replaces it: ephemeral storage lives and dies with the
+// replica. The storage Azure reports must be the size Consumption gives the
+// container's CPU, since the module has no variable to size it
+func TestContainerAppEphemeralStorage(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_EPHEMERAL_STORAGE") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Skipping ephemeral storage test: set TEST_EPHEMERAL_STORAGE=true (restarts a revision)")
+	}
+
+	expectedStorage, ok := helpers.ConsumptionEphemeralStorage(ephemeralCPU)
+	if !ok {
+		t.Fatalf("%v vCPU is not a Consumption combination", ephemeralCPU)
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	// Logs go to the run's shared workspace instead of one per test
+	shared := helpers.AcquireSharedObservability(t)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name":        config.GenerateResourceGroupName("ca-eph"),
+			"location":                   config.Location,
+			"name_suffix":                config.UniqueID,
+			"log_analytics_workspace_id": shared.WorkspaceID,
+			"container_cpu":              ephemeralCPU,
+			"files_dir":                  ephemeralFilesDir,
+			"tags":                       helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-env", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	// First apply creates the registry; the app follows once the echo image
+	// is in it
+	phases.Start("apply")
+	helpers.DeployWithRegionFallback(t, config, terraformOptions, vars, func() error {
+		if _, err := terraform.InitAndApplyE(t, terraformOptions); err != nil {
+			return err
+		}
+		phases.Start("build")
+		image := helpers.BuildFixtureImage(t, "echo", terraform.Output(t, terraformOptions, "registry_login_server"))
+		terraformOptions.Vars["container_image"] = image.Reference
+
+		phases.Start("apply")
+		_, err := terraform.ApplyE(t, terraformOptions)
+		return err
+	})
+	phases.Start("verify")
+
+	resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+	appName := terraform.Output(t, terraformOptions, "container_app_name")
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+
+	storage, err := helpers.ContainerEphemeralStorageE(t, resourceGroupName, appName, "api")
+	if assert.NoError(t, err) {
+		assert.Equal(t, expectedStorage, storage, "ephemeral storage of a %v vCPU container", ephemeralCPU)
+	}
+
+	name := "eph-" + strings.ToLower(random.UniqueId()) + ".txt"
+	content := fmt.Sprintf("written by %s at %s\n", t.Name(), time.Now().UTC().Format(time.RFC3339Nano))
+	endpoint := fmt.Sprintf("%s/file?name=%s", applicationURL, name)
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	// get reads the file, returning the status, body and answering replica
+	get := func() (int, string, string, error) {
+		response, err := client.Get(endpoint)
+		if err != nil {
+			return 0, "", "", err
+		}
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		return response.StatusCode, string(body), response.Header.Get("X-Echo-Replica"), err
+	}
+
+	var writer string
+	retry.DoWithRetry(t, "writing a file to the container's filesystem", 30, 20*time.Second, func() (string, error) {
+		request, err := http.NewRequest(http.MethodPut, endpoint, strings.NewReader(content))
+		if err != nil {
+			return "", err
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		if response.StatusCode != http.StatusNoContent {
+			return "", fmt.Errorf("writing %s returned %d: %s", name, response.StatusCode, strings.TrimSpace(string(body)))
+		}
+		writer = response.Header.Get("X-Echo-Replica")
+		return "", nil
+	})
+	if writer == "" {
+		t.Fatal("The app should name the replica that wrote the file")
+	}
+
+	status, body, replica, err := get()
+	if !assert.NoError(t, err) || !assert.Equal(t, http.StatusOK, status, "reading %s from replica %s before the restart", name, replica) {
+		return
+	}
+	assert.Equal(t, content, body, "the file should read back unchanged before the restart")
+
+	revision, err := helpers.RestartRevisionE(t, resourceGroupName, appName)
+	if err != nil {
+		t.Fatalf("Restarting the app: %v", err)
+	}
+	t.Logf("Restarted revision %s, whose replica %s wrote %s", revision, writer, name)
+
+	// The restart replaces the replica; wait until another one answers
+	var reader string
+	retry.DoWithRetry(t, "reading the file from the replica that replaced the writer", 40, 15*time.Second, func() (string, error) {
+		status, body, replica, err := get()
+		if err != nil {
+			return "", err
+		}
+		if replica == writer || replica == "" {
+			return "", fmt.Errorf("replica %q still answers", writer)
+		}
+		reader = replica
+		switch status {
+		case http.StatusNotFound:
+			return "", nil
+		case http.StatusOK:
+			return "", retry.FatalError{Underlying: fmt.Errorf("replica %s still has %s after the restart: %q", replica, name, body)}
+		}
+		return "", retry.FatalError{Underlying: fmt.Errorf("reading %s from replica %s returned %d: %s", name, replica, status, strings.TrimSpace(body))}
+	})
+
+	helpers.RecordReport(t, "ephemeral", "ephemeral_storage", storage)
+	helpers.RecordReport(t, "ephemeral", "replicas", []string{writer, reader})
+}
@@ -0,0 +1,75 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContainerAppExec runs commands inside a replica of a deployed app and
+// checks the runtime environment Container Apps injects, which ingress
+// probing can't observe
+func TestContainerAppExec(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name": config.GenerateResourceGroupName("ca-exec"),
+			"location":            config.Location,
+			"name_suffix":         config.UniqueID,
+			"tags":                helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-public", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApplyWithRegionFallback(t, config, terraformOptions, vars)
+	phases.Start("verify")
+
+	resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+	appName := terraform.Output(t, terraformOptions, "container_app_name")
+
+	// The first replica can take a while to start after apply
+	var result *helpers.ExecResult
+	retry.DoWithRetry(t, "waiting for a replica to exec into", 20, 15*time.Second, func() (string, error) {
+		var err error
+		result, err = helpers.ContainerAppExecE(t, resourceGroupName, appName, "env")
+		return "", err
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		env := map[string]string{}
+		for _, line := range strings.Split(result.Output, "\n") {
+			if name, value, found := strings.Cut(line, "="); found {
+				env[name] = value
+			}
+		}
+
+		assert.Equal(t, 0, result.ExitCode, "env should succeed")
+		assert.Equal(t, appName, env["CONTAINER_APP_NAME"], "CONTAINER_APP_NAME should be injected")
+		assert.Equal(t, result.Revision, env["CONTAINER_APP_REVISION"], "CONTAINER_APP_REVISION should be injected")
+		assert.Equal(t, result.Replica, env["CONTAINER_APP_REPLICA_NAME"], "CONTAINER_APP_REPLICA_NAME should be injected")
+	})
+
+	t.Run("exit_code", func(t *testing.T) {
+		failed := helpers.ContainerAppExec(t, resourceGroupName, appName, "exit 3")
+		assert.Equal(t, 3, failed.ExitCode, "Exit code of the command should be returned")
+	})
+}
@@ -0,0 +1,152 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/planassert"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContainerAppInsecureIngressValidation plans the container-app module
+// with allow_insecure_connections under different Environment tags. Plain
+// HTTP ingress must be refused for production apps whatever the tag's case,
+// and stay available elsewhere
+func TestContainerAppInsecureIngressValidation(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		environment   string
+		insecure      bool
+		expectedError string
+	}{
+		{"prod_https_only", "prod", false, ""},
+		{"prod_insecure", "prod", true, "must be false in production"},
+		{"production_insecure", "Production", true, "must be false in production"},
+		{"prod_uppercase_insecure", "PROD", true, "must be false in production"},
+		{"dev_insecure", "dev", true, ""},
+		{"untagged_insecure", "", true, ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tags := map[string]string{}
+			if tc.environment != "" {
+				tags["Environment"] = tc.environment
+			}
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-plan", map[string]interface{}{
+				"allow_insecure_connections": tc.insecure,
+				"tags":                       tags,
+			})
+
+			planJSON, err := helpers.CachedPlanE(t, terraformOptions)
+			if tc.expectedError != "" {
+				if assert.Error(t, err, "Expected insecure ingress to be refused for Environment %q", tc.environment) {
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Planning Environment %q with allow_insecure_connections %v: %v", tc.environment, tc.insecure, err)
+			}
+
+			plan := planassert.Parse(t, planJSON)
+			planassert.AssertAttributeEquals(t, plan, containerAppAddress, "ingress.0.allow_insecure_connections", tc.insecure)
+		})
+	}
+}
+
+// TestContainerAppHTTPSEnforcement deploys a public app with
+// allow_insecure_connections = false and checks what clients see: plain HTTP
+// is redirected to HTTPS or refused, never served, and HTTPS meets the TLS
+// baseline. Allowing insecure connections afterwards must make plain HTTP
+// answer, which shows the probe tells the two settings apart
+func TestContainerAppHTTPSEnforcement(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name":        config.GenerateResourceGroupName("ca-https"),
+			"location":                   config.Location,
+			"name_suffix":                config.UniqueID,
+			"allow_insecure_connections": false,
+			"tags":                       helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-public", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApplyWithRegionFallback(t, config, terraformOptions, vars)
+	phases.Start("verify")
+
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+
+	// The first replica can take a few minutes to start on a new environment
+	client := &http.Client{Timeout: 30 * time.Second}
+	retry.DoWithRetry(t, "waiting for the app over HTTPS", 30, 20*time.Second, func() (string, error) {
+		response, err := client.Get(applicationURL)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s returned %d", applicationURL, response.StatusCode)
+		}
+		return "", nil
+	})
+
+	plain, err := helpers.CheckPlainHTTPE(applicationURL)
+	if err != nil {
+		t.Fatalf("Requesting the app over plain HTTP: %v", err)
+	}
+	assert.True(t, plain.EnforcesHTTPS(), "%s should be redirected to HTTPS or refused, got status %d (Location %q)", plain.URL, plain.StatusCode, plain.Location)
+	helpers.AssertTLSBaseline(t, applicationURL)
+
+	// Allowing insecure connections must make plain HTTP answer
+	updatedVars := map[string]interface{}{}
+	for key, value := range terraformOptions.Vars {
+		updatedVars[key] = value
+	}
+	updatedVars["allow_insecure_connections"] = true
+
+	updatedOptions := *terraformOptions
+	updatedOptions.Vars = updatedVars
+
+	phases.Start("apply")
+	helpers.Apply(t, &updatedOptions)
+	phases.Start("verify")
+
+	// Ingress settings take a moment to reach the edge
+	retry.DoWithRetry(t, "waiting for plain HTTP to be served", 20, 15*time.Second, func() (string, error) {
+		result, err := helpers.CheckPlainHTTPE(applicationURL)
+		if err != nil {
+			return "", err
+		}
+		if result.EnforcesHTTPS() || result.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s still not served over plain HTTP: status %d, refused %v", result.URL, result.StatusCode, result.Refused)
+		}
+		return "", nil
+	})
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/planassert"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// stickySessionsAddress is the affinity patch in the container-app-plan
+	// fixture
+	stickySessionsAddress = "module.container_app.azapi_update_resource.sticky_sessions[0]"
+
+	// ingressTimeoutTolerance allows for connection setup and the gateway's
+	// own timer granularity around helpers.IngressRequestTimeout
+	ingressTimeoutTolerance = 20 * time.Second
+)
+
+// TestContainerAppStickySessionsValidation plans the container-app module
+// with session affinity. Azure only supports it for HTTP ingress in Single
+// revision mode, so other combinations must be refused at plan time
+func TestContainerAppStickySessionsValidation(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		sticky        bool
+		revisionMode  string
+		transport     string
+		expectedError string
+	}{
+		{"sticky_http", true, "Single", "http", ""},
+		{"sticky_http2", true, "Single", "http2", ""},
+		{"not_sticky", false, "Multiple", "tcp", ""},
+		{"sticky_multiple_revisions", true, "Multiple", "http", "Sticky sessions need HTTP ingress in Single revision mode"},
+		{"sticky_tcp", true, "Single", "tcp", "Sticky sessions need HTTP ingress in Single revision mode"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-plan", map[string]interface{}{
+				"ingress_sticky_sessions_enabled": tc.sticky,
+				"revision_mode":                   tc.revisionMode,
+				"ingress_transport":               tc.transport,
+			})
+
+			planJSON, err := helpers.CachedPlanE(t, terraformOptions)
+			if tc.expectedError != "" {
+				if assert.Error(t, err, "Expected sticky sessions with %s ingress in %s revision mode to be refused", tc.transport, tc.revisionMode) {
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Planning sticky sessions %v with %s ingress in %s revision mode: %v", tc.sticky, tc.transport, tc.revisionMode, err)
+			}
+
+			plan := planassert.Parse(t, planJSON)
+			// Affinity is patched onto the ingress exactly when enabled
+			if tc.sticky {
+				planassert.AssertResourcePlanned(t, plan, stickySessionsAddress)
+			} else {
+				planassert.AssertResourceNotPlanned(t, plan, stickySessionsAddress)
+			}
+		})
+	}
+}
+
+// TestContainerAppIngressBehavior deploys the echo fixture app on two
+// replicas with sticky sessions and checks the ingress behaves as configured:
+// a client keeping its affinity cookie always reaches the same replica, and a
+// response slower than the ingress request timeout is cut at that bound
+// rather than waited for
+func TestContainerAppIngressBehavior(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name":     config.GenerateResourceGroupName("ca-ingress"),
+			"location":                config.Location,
+			"name_suffix":             config.UniqueID,
+			"sticky_sessions_enabled": true,
+			"tags":                    helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-ingress", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	// First apply creates the workspace and registry; the app follows once
+	// the echo image is in the registry
+	phases.Start("apply")
+	helpers.DeployWithRegionFallback(t, config, terraformOptions, vars, func() error {
+		if _, err := terraform.InitAndApplyE(t, terraformOptions); err != nil {
+			return err
+		}
+		phases.Start("build")
+		image := helpers.BuildFixtureImage(t, "echo", terraform.Output(t, terraformOptions, "registry_login_server"))
+		terraformOptions.Vars["container_image"] = image.Reference
+
+		phases.Start("apply")
+		_, err := terraform.ApplyE(t, terraformOptions)
+		return err
+	})
+	phases.Start("verify")
+
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+	verifyStart := time.Now()
+
+	t.Run("sticky_sessions", func(t *testing.T) {
+		// Replicas can still be starting after apply; a client that lands on
+		// a starting replica gets errors rather than another replica
+		var replicas map[string]int
+		retry.DoWithRetry(t, "sending requests with the affinity cookie", 20, 15*time.Second, func() (string, error) {
+			var err error
+			replicas, err = helpers.StickyReplicasE(applicationURL+"/health", 20)
+			return "", err
+		})
+		helpers.RecordReport(t, "ingress", "sticky_replicas", replicas)
+		assert.Len(t, replicas, 1, "every request with the affinity cookie should reach the same replica, got %v", replicas)
+	})
+
+	t.Run("request_timeout", func(t *testing.T) {
+		fast := helpers.ProbeSlowResponse(applicationURL, 5*time.Second)
+		assert.True(t, fast.Answered(), "a response well inside the timeout should come through: %+v", fast)
+
+		slow := helpers.ProbeSlowResponse(applicationURL, helpers.IngressRequestTimeout+time.Minute)
+		helpers.RecordReport(t, "ingress", "request_timeout", slow)
+		assert.True(t, slow.CutAt(helpers.IngressRequestTimeout, ingressTimeoutTolerance),
+			"a response slower than %s should be cut at that bound: %+v", helpers.IngressRequestTimeout, slow)
+	})
+
+	// Azure's view of the same traffic: the requests above reached the app
+	// and a replica served them
+	t.Run("platform_metrics", func(t *testing.T) {
+		appID := terraform.Output(t, terraformOptions, "container_app_id")
+		window := time.Since(verifyStart) + 5*time.Minute
+		helpers.AssertContainerAppRequests(t, appID, window, 20)
+		helpers.AssertContainerAppReplicas(t, appID, window, 1)
+		helpers.AssertContainerAppCPUUsage(t, appID, window)
+	})
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContainerAppIPv6Readiness probes the ingress of a Container App over
+// IPv4 and IPv6 in every region listed in TEST_IPV6_REGIONS and records what
+// each region supports in the ipv6 report. A region that publishes AAAA
+// records must also answer over IPv6; one that doesn't is recorded, not failed.
+// Regions the region catalog shows without Container Apps are skipped
+func TestContainerAppIPv6Readiness(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	regions := []string{"eastus2"}
+	if value := os.Getenv("TEST_IPV6_REGIONS"); value != "" {
+		regions = strings.Split(value, ",")
+	}
+
+	helpers.RunRegionMatrix(t, regions, []helpers.RegionRequirement{
+		helpers.NeedsResourceType(helpers.ResourceTypeLogAnalyticsWorkspace),
+		helpers.NeedsResourceType(helpers.ResourceTypeContainerAppEnvironment),
+		helpers.NeedsResourceType(helpers.ResourceTypeContainerApp),
+	}, func(t *testing.T, region string) {
+		config := helpers.NewTestConfig(t)
+		cost.TrackRun(t, config)
+		terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-public", map[string]interface{}{
+			"resource_group_name": config.GenerateResourceGroupName("ca-ipv6"),
+			"location":            region,
+			"name_suffix":         config.UniqueID,
+			"tags":                helpers.StandardTags(t.Name()),
+		})
+		helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+		phases := helpers.TrackPhases(t)
+		helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+		defer helpers.Destroy(t, terraformOptions)
+		defer phases.Start("destroy")
+		phases.Start("apply")
+		helpers.InitAndApply(t, terraformOptions)
+		phases.Start("verify")
+
+		applicationURL := terraform.Output(t, terraformOptions, "application_url")
+
+		// Ingress answers once the first replica is running
+		var probe helpers.DualStackProbe
+		retry.DoWithRetry(t, "waiting for ingress over IPv4", 30, 20*time.Second, func() (string, error) {
+			probe = helpers.ProbeDualStack(t, region, applicationURL)
+			if !probe.IPv4Reachable {
+				return "", fmt.Errorf("%s not reachable over IPv4: %s", probe.FQDN, probe.IPv4Error)
+			}
+			return "", nil
+		})
+		helpers.RecordReport(t, "ipv6", region, probe)
+
+		assert.True(t, probe.IPv4Reachable, "Ingress should be reachable over IPv4")
+		switch {
+		case !probe.AdvertisesIPv6():
+			t.Logf("%s: ingress has no AAAA records, dual-stack ingress is not available", region)
+		case !probe.RunnerHasIPv6:
+			t.Logf("%s: ingress has AAAA records but the runner has no IPv6 route, reachability not verified", region)
+		default:
+			assert.True(t, probe.IPv6Reachable,
+				"Ingress advertises IPv6 addresses %v but is not reachable over IPv6: %s", probe.IPv6Addresses, probe.IPv6Error)
+		}
+	})
+}
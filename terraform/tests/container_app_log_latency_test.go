@@ -0,0 +1,148 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// defaultLogIngestionSLO is the default end-to-end budget for a console log
+// line to become queryable in Log Analytics
+const defaultLogIngestionSLO = 5 * time.Minute
+
+// logIngestionMeasurement is one synthetic log line's trip into Log Analytics
+type logIngestionMeasurement struct {
+	Region         string    `json:"region"`
+	Marker         string    `json:"marker"`
+	EmittedAt      time.Time `json:"emitted_at"`
+	IngestedAt     time.Time `json:"ingested_at"`
+	LatencySeconds float64   `json:"latency_seconds"`
+	SLOSeconds     float64   `json:"slo_seconds"`
+}
+
+// TestContainerAppLogIngestionLatency emits a synthetic log line from the
+// echo fixture app, polls Log Analytics until it arrives and asserts the
+// latency against the SLO. Opt in with TEST_LOG_INGESTION_SLO=true; override
+// the budget with TEST_LOG_INGESTION_SLO_SECONDS
+func TestContainerAppLogIngestionLatency(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_LOG_INGESTION_SLO") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_LOG_INGESTION_SLO=true to measure log ingestion latency")
+	}
+
+	slo := defaultLogIngestionSLO
+	if value := os.Getenv("TEST_LOG_INGESTION_SLO_SECONDS"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			t.Fatalf("Invalid TEST_LOG_INGESTION_SLO_SECONDS %q: %v", value, err)
+		}
+		slo = time.Duration(seconds) * time.Second
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name": config.GenerateResourceGroupName("log"),
+			"location":            config.Location,
+			"name_suffix":         config.UniqueID,
+			"tags":                helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/log-ingestion", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	// First apply creates the workspace and registry; the app follows once
+	// the echo image is in the registry
+	phases.Start("apply")
+	helpers.DeployWithRegionFallback(t, config, terraformOptions, vars, func() error {
+		if _, err := terraform.InitAndApplyE(t, terraformOptions); err != nil {
+			return err
+		}
+		phases.Start("build")
+		image := helpers.BuildFixtureImage(t, "echo", terraform.Output(t, terraformOptions, "registry_login_server"))
+		terraformOptions.Vars["container_image"] = image.Reference
+		phases.Start("apply")
+		_, err := terraform.ApplyE(t, terraformOptions)
+		return err
+	})
+	phases.Start("verify")
+
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	workspaceID := terraform.Output(t, terraformOptions, "log_analytics_workspace_id")
+	marker := fmt.Sprintf("synthetic-%s-%s", helpers.RunID(), config.UniqueID)
+
+	helpers.RequireEndpointReady(t, applicationURL)
+
+	// The first replica can take a few minutes to start on a new environment
+	logURL := fmt.Sprintf("%s/log?marker=%s", applicationURL, url.QueryEscape(marker))
+	var emittedAt time.Time
+	retry.DoWithRetry(t, "emitting synthetic log line", 30, 20*time.Second, func() (string, error) {
+		emittedAt = time.Now().UTC()
+		response, err := http.Get(logURL)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s returned %d", logURL, response.StatusCode)
+		}
+		return "", nil
+	})
+
+	// Console logs land in ContainerAppConsoleLogs_CL with the log-analytics
+	// destination and in ContainerAppConsoleLogs with diagnostic settings
+	query := fmt.Sprintf(`union isfuzzy=true ContainerAppConsoleLogs_CL, ContainerAppConsoleLogs
+| where tostring(column_ifexists("Log_s", column_ifexists("Log", ""))) has "%s"
+| summarize IngestedAt = min(ingestion_time())`, marker)
+
+	// Keep polling past the SLO so a breach still reports the real latency
+	var ingestedAt time.Time
+	pollInterval := 15 * time.Second
+	_, err := retry.DoWithRetryE(t, "waiting for synthetic log line in Log Analytics", int(2*slo/pollInterval), pollInterval, func() (string, error) {
+		rows, err := helpers.QueryLogAnalyticsE(t, workspaceID, query)
+		if err != nil {
+			return "", err
+		}
+		if len(rows) == 0 || rows[0]["IngestedAt"] == nil || rows[0]["IngestedAt"] == "" {
+			return "", fmt.Errorf("marker %s not ingested yet", marker)
+		}
+		ingestedAt, err = time.Parse(time.RFC3339Nano, fmt.Sprint(rows[0]["IngestedAt"]))
+		return "", err
+	})
+	if err != nil {
+		t.Fatalf("Synthetic log line was not ingested within %s: %v", 2*slo, err)
+	}
+
+	measurement := logIngestionMeasurement{
+		Region:         config.Location,
+		Marker:         marker,
+		EmittedAt:      emittedAt,
+		IngestedAt:     ingestedAt,
+		LatencySeconds: ingestedAt.Sub(emittedAt).Seconds(),
+		SLOSeconds:     slo.Seconds(),
+	}
+	helpers.RecordReport(t, "log_ingestion", config.Location, measurement)
+
+	assert.LessOrEqual(t, measurement.LatencySeconds, measurement.SLOSeconds,
+		"Log ingestion latency %.0fs exceeds the %s SLO", measurement.LatencySeconds, slo)
+}
@@ -0,0 +1,203 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/planassert"
+	"github.com/stretchr/testify/assert"
+)
+
+// nfsPlanSubnetID is a placeholder infrastructure subnet for plans
+const nfsPlanSubnetID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-plan/providers/Microsoft.Network/virtualNetworks/vnet-plan/subnets/snet-container-app"
+
+// nfsVolume returns an nfs_volumes entry for a share of the stplan account
+func nfsVolume(name, mountPath, accessMode string) map[string]interface{} {
+	volume := map[string]interface{}{
+		"name":       name,
+		"server":     "stplan.file.core.windows.net",
+		"share_name": "/stplan/" + name,
+		"mount_path": mountPath,
+	}
+	if accessMode != "" {
+		volume["access_mode"] = accessMode
+	}
+	return volume
+}
+
+// TestContainerAppNFSVolumeValidation plans the container-app module with
+// NFS volumes. NFS shares are only reachable from a virtual network, so an
+// environment on the Azure-managed network must be refused at plan time
+// rather than fail when replicas try to mount the share
+func TestContainerAppNFSVolumeValidation(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		subnetID      string
+		volumes       []map[string]interface{}
+		expectedError string
+	}{
+		{"vnet_integrated", nfsPlanSubnetID, []map[string]interface{}{nfsVolume("data", "/mnt/data", "")}, ""},
+		{"read_only", nfsPlanSubnetID, []map[string]interface{}{nfsVolume("data", "/mnt/data", "ReadOnly")}, ""},
+		{"no_volumes_managed_network", "", nil, ""},
+		{"managed_network", "", []map[string]interface{}{nfsVolume("data", "/mnt/data", "")}, "require a VNet-integrated environment"},
+		{"invalid_access_mode", nfsPlanSubnetID, []map[string]interface{}{nfsVolume("data", "/mnt/data", "Write")}, "must be ReadOnly or ReadWrite"},
+		{"relative_mount_path", nfsPlanSubnetID, []map[string]interface{}{nfsVolume("data", "mnt/data", "")}, "must be absolute"},
+		{"duplicate_name", nfsPlanSubnetID, []map[string]interface{}{nfsVolume("data", "/mnt/a", ""), nfsVolume("data", "/mnt/b", "")}, "must be unique"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			volumes := tc.volumes
+			if volumes == nil {
+				volumes = []map[string]interface{}{}
+			}
+			vars := map[string]interface{}{"nfs_volumes": volumes}
+			if tc.subnetID != "" {
+				vars["infrastructure_subnet_id"] = tc.subnetID
+			}
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-plan", vars)
+
+			planJSON, err := helpers.CachedPlanE(t, terraformOptions)
+			if tc.expectedError != "" {
+				if assert.Error(t, err, "Expected NFS volumes %v to be refused", tc.volumes) {
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Planning NFS volumes %v: %v", tc.volumes, err)
+			}
+
+			plan := planassert.Parse(t, planJSON)
+			if planassert.AssertAttributeLen(t, plan, containerAppAddress, "template.0.volume", len(volumes), "one template volume per NFS volume") && len(volumes) > 0 {
+				planassert.AssertAttributeEquals(t, plan, containerAppAddress, "template.0.volume.0.storage_type", "NfsAzureFile")
+			}
+			for _, volume := range volumes {
+				address := fmt.Sprintf("module.container_app.azapi_resource.nfs_storage[%q]", volume["name"])
+				planassert.AssertResourcePlanned(t, plan, address, "environment storage for %s", volume["name"])
+			}
+		})
+	}
+}
+
+// TestContainerAppNFSVolumeReadWrite deploys the echo fixture app with a
+// Premium NFS share mounted on both of its replicas. A file written through
+// the app must be read back unchanged, and from the other replica too, which
+// shows it lives on the share rather than in the container's filesystem
+func TestContainerAppNFSVolumeReadWrite(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_NFS_MOUNTS") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Skipping NFS mount test: set TEST_NFS_MOUNTS=true (provisions a Premium file share)")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name": config.GenerateResourceGroupName("ca-nfs"),
+			"location":            config.Location,
+			"name_suffix":         config.UniqueID,
+			"tags":                helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-nfs", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	// First apply creates the network, share and registry; the app follows
+	// once the echo image is in the registry
+	phases.Start("apply")
+	helpers.DeployWithRegionFallback(t, config, terraformOptions, vars, func() error {
+		if _, err := terraform.InitAndApplyE(t, terraformOptions); err != nil {
+			return err
+		}
+		phases.Start("build")
+		image := helpers.BuildFixtureImage(t, "echo", terraform.Output(t, terraformOptions, "registry_login_server"))
+		terraformOptions.Vars["container_image"] = image.Reference
+
+		phases.Start("apply")
+		_, err := terraform.ApplyE(t, terraformOptions)
+		return err
+	})
+	phases.Start("verify")
+
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+
+	name := "nfs-" + strings.ToLower(random.UniqueId()) + ".txt"
+	content := fmt.Sprintf("written by %s at %s\n", t.Name(), time.Now().UTC().Format(time.RFC3339Nano))
+	endpoint := fmt.Sprintf("%s/file?name=%s", applicationURL, name)
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var writer string
+	retry.DoWithRetry(t, "writing a file to the NFS share through the app", 30, 20*time.Second, func() (string, error) {
+		request, err := http.NewRequest(http.MethodPut, endpoint, strings.NewReader(content))
+		if err != nil {
+			return "", err
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		if response.StatusCode != http.StatusNoContent {
+			return "", fmt.Errorf("writing %s returned %d: %s", name, response.StatusCode, strings.TrimSpace(string(body)))
+		}
+		writer = response.Header.Get("X-Echo-Replica")
+		return "", nil
+	})
+
+	// Requests are spread over the replicas; keep reading until one other
+	// than the writer has answered
+	readers := map[string]bool{}
+	retry.DoWithRetry(t, "reading the file back from another replica", 30, 5*time.Second, func() (string, error) {
+		response, err := client.Get(endpoint)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return "", err
+		}
+		replica := response.Header.Get("X-Echo-Replica")
+		if response.StatusCode != http.StatusOK {
+			return "", retry.FatalError{Underlying: fmt.Errorf("reading %s from replica %s returned %d: %s", name, replica, response.StatusCode, strings.TrimSpace(string(body)))}
+		}
+		if string(body) != content {
+			return "", retry.FatalError{Underlying: fmt.Errorf("replica %s read %q, want %q", replica, body, content)}
+		}
+		readers[replica] = true
+		if replica == writer {
+			return "", fmt.Errorf("only the writing replica %s has answered so far", writer)
+		}
+		return "", nil
+	})
+
+	helpers.RecordReport(t, "nfs", "replicas_read", len(readers))
+	assert.NotEmpty(t, writer, "the app should name the replica that wrote the file")
+}
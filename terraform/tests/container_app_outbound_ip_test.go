@@ -0,0 +1,108 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// outboundIPs is the outbound_ip_addresses output of an apply, next to what
+// ARM reported for the app then
+type outboundIPs struct {
+	Apply  string   `json:"apply"`
+	Output []string `json:"output"`
+	Azure  []string `json:"azure"`
+}
+
+// TestContainerAppOutboundIPContract checks the contract downstream database
+// firewall rules rely on: the container-app module's outbound_ip_addresses
+// output lists exactly the addresses Azure reports the app egresses from,
+// and the list stays the same across re-applies, both one that changes
+// nothing and one that rolls out a new revision. A list that moved would
+// lock the app out of every database allowing the old one
+func TestContainerAppOutboundIPContract(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name": config.GenerateResourceGroupName("ca-egress-ip"),
+			"location":            config.Location,
+			"name_suffix":         config.UniqueID,
+			"min_replicas":        0,
+			"tags":                helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-public", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApplyWithRegionFallback(t, config, terraformOptions, vars)
+	phases.Start("verify")
+
+	resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+	appName := terraform.Output(t, terraformOptions, "container_app_name")
+
+	// read returns the output and ARM's list after an apply, failing the
+	// test when they are not the same addresses
+	var applies []outboundIPs
+	read := func(apply string, options *terraform.Options) []string {
+		output, err := helpers.NormalizeIPsE(terraform.OutputList(t, options, "outbound_ip_addresses"))
+		if err != nil {
+			t.Fatalf("outbound_ip_addresses after the %s apply: %v", apply, err)
+		}
+		reported, err := helpers.OutboundIPsE(t, resourceGroupName, appName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		azure, err := helpers.NormalizeIPsE(reported)
+		if err != nil {
+			t.Fatalf("Outbound IPs Azure reports after the %s apply: %v", apply, err)
+		}
+
+		assert.NotEmpty(t, output, "outbound_ip_addresses should list the addresses to allow after the %s apply", apply)
+		added, removed := helpers.DiffIPs(azure, output)
+		assert.Empty(t, removed, "outbound_ip_addresses after the %s apply misses addresses Azure reports; a firewall built from it would block them", apply)
+		assert.Empty(t, added, "outbound_ip_addresses after the %s apply lists addresses Azure does not report", apply)
+		applies = append(applies, outboundIPs{Apply: apply, Output: output, Azure: azure})
+		return output
+	}
+	defer func() { helpers.RecordReport(t, "outbound_ips", t.Name(), applies) }()
+
+	initial := read("first", terraformOptions)
+
+	phases.Start("apply")
+	helpers.Apply(t, terraformOptions)
+	phases.Start("verify")
+	added, removed := helpers.DiffIPs(initial, read("unchanged", terraformOptions))
+	assert.Empty(t, added, "re-applying without changes should not add outbound IPs")
+	assert.Empty(t, removed, "re-applying without changes should not remove outbound IPs")
+
+	// A scale change is revision-scoped, so this apply rolls out a revision
+	updatedVars := map[string]interface{}{}
+	for key, value := range terraformOptions.Vars {
+		updatedVars[key] = value
+	}
+	updatedVars["min_replicas"] = 1
+	updatedOptions := *terraformOptions
+	updatedOptions.Vars = updatedVars
+
+	phases.Start("apply")
+	helpers.Apply(t, &updatedOptions)
+	phases.Start("verify")
+	added, removed = helpers.DiffIPs(initial, read("new revision", &updatedOptions))
+	assert.Empty(t, added, "rolling out a revision should not add outbound IPs")
+	assert.Empty(t, removed, "rolling out a revision should not remove outbound IPs")
+}
@@ -0,0 +1,150 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// containerAppRegistry is a registries entry of a container app configuration
+type containerAppRegistry struct {
+	Server            string `json:"server"`
+	Identity          string `json:"identity"`
+	Username          string `json:"username"`
+	PasswordSecretRef string `json:"passwordSecretRef"`
+}
+
+// TestContainerAppRegistryAuthMatrix tests every registry auth mode of the
+// container-app module: each must pull a private image into a Running app and
+// leave no credentials on the app that the mode does not need
+func TestContainerAppRegistryAuthMatrix(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	testCases := []struct {
+		mode         string
+		usesIdentity bool
+		usesPassword bool
+	}{
+		{"system_identity", true, false},
+		{"user_identity", true, false},
+		{"admin_credentials", false, true},
+		{"service_principal", false, true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.mode, func(t *testing.T) {
+			t.Parallel()
+
+			config := helpers.NewTestConfig(t)
+			cost.TrackRun(t, config)
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-registry-auth", map[string]interface{}{
+				"resource_group_name": config.GenerateResourceGroupName("ca-auth"),
+				"location":            config.Location,
+				"name_suffix":         config.UniqueID,
+				"registry_auth_mode":  tc.mode,
+				"tags":                helpers.StandardTags(t.Name()),
+			})
+			helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+			if tc.mode == "service_principal" {
+				principal := createPullServicePrincipal(t, fmt.Sprintf("sp-acr-pull-%s", config.UniqueID))
+				defer helpers.AzCLIE(t, "ad", "app", "delete", "--id", principal.AppID)
+
+				terraformOptions.EnvVars["TF_VAR_service_principal_client_id"] = principal.AppID
+				terraformOptions.EnvVars["TF_VAR_service_principal_object_id"] = principal.ObjectID
+				terraformOptions.EnvVars["TF_VAR_service_principal_secret"] = principal.Password
+			}
+
+			phases := helpers.TrackPhases(t)
+			helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+			defer helpers.Destroy(t, terraformOptions)
+			defer phases.Start("destroy")
+			phases.Start("apply")
+			helpers.InitAndApply(t, terraformOptions)
+			phases.Start("verify")
+
+			resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+			appName := terraform.Output(t, terraformOptions, "container_app_name")
+			loginServer := terraform.Output(t, terraformOptions, "registry_login_server")
+			passwordSecret := terraform.Output(t, terraformOptions, "registry_password_secret_name")
+
+			// Identity role assignments can take minutes to reach ACR, so the
+			// first revision may retry its pull before it is running
+			retry.DoWithRetry(t, "waiting for container app to run", 30, 20*time.Second, func() (string, error) {
+				status, err := helpers.AzCLIE(t, "containerapp", "show", "--name", appName,
+					"--resource-group", resourceGroupName, "--query", "properties.runningStatus", "--output", "tsv")
+				if err != nil {
+					return "", err
+				}
+				if strings.TrimSpace(status) != "Running" {
+					return "", fmt.Errorf("container app status is %q", strings.TrimSpace(status))
+				}
+				return status, nil
+			})
+
+			var registries []containerAppRegistry
+			helpers.AzCLIJSON(t, &registries, "containerapp", "show", "--name", appName,
+				"--resource-group", resourceGroupName, "--query", "properties.configuration.registries")
+			if assert.Len(t, registries, 1, "App should have exactly one registry") {
+				registry := registries[0]
+				assert.Equal(t, loginServer, registry.Server)
+				assert.Equal(t, tc.usesIdentity, registry.Identity != "", "Registry identity set")
+				assert.Equal(t, tc.usesPassword, registry.Username != "", "Registry username set")
+				assert.Equal(t, tc.usesPassword, registry.PasswordSecretRef == passwordSecret, "Registry password secret referenced")
+			}
+
+			var secrets []struct {
+				Name string `json:"name"`
+			}
+			helpers.AzCLIJSON(t, &secrets, "containerapp", "secret", "list", "--name", appName,
+				"--resource-group", resourceGroupName)
+			var secretNames []string
+			for _, secret := range secrets {
+				secretNames = append(secretNames, secret.Name)
+			}
+			if tc.usesPassword {
+				assert.Equal(t, []string{passwordSecret}, secretNames, "Only the registry password secret should exist")
+			} else {
+				assert.Empty(t, secretNames, "Identity modes should leave no secrets on the app")
+			}
+		})
+	}
+}
+
+// pullServicePrincipal holds credentials of a service principal created for a test
+type pullServicePrincipal struct {
+	AppID    string `json:"appId"`
+	Password string `json:"password"`
+	ObjectID string
+}
+
+// createPullServicePrincipal creates a service principal with no role
+// assignments; the fixture grants it AcrPull on the test registry. The test
+// is skipped when the runner may not create app registrations, and fails on
+// any other error
+func createPullServicePrincipal(t *testing.T, name string) pullServicePrincipal {
+	var principal pullServicePrincipal
+	if err := helpers.AzCLIJSONE(t, &principal, "ad", "sp", "create-for-rbac", "--name", name); err != nil {
+		if helpers.IsDirectoryPermissionError(err) {
+			helpers.SkipWithReason(t, helpers.SkipPermissions,
+				fmt.Sprintf("Cannot create service principal (requires Entra ID application permissions): %v", err))
+		}
+		t.Fatalf("Creating service principal %s: %v", name, err)
+	}
+
+	principal.ObjectID = strings.TrimSpace(helpers.AzCLI(t, "ad", "sp", "show", "--id", principal.AppID,
+		"--query", "id", "--output", "tsv"))
+	return principal
+}
@@ -0,0 +1,191 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/planassert"
+	"github.com/stretchr/testify/assert"
+)
+
+// containerAppAddress is the app in the container-app-plan fixture
+const containerAppAddress = "module.container_app.azurerm_container_app.this"
+
+// sidecar returns a sidecar_containers entry of the given size
+func sidecar(name string, cpu float64, memory string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   name,
+		"image":  "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest",
+		"cpu":    cpu,
+		"memory": memory,
+	}
+}
+
+// TestContainerAppReplicaResourceTotals plans the container-app module with
+// sidecars. Azure only accepts a replica whose containers add up to a
+// Consumption CPU / memory combination, and only says so late with a
+// confusing error, so the module must refuse other totals at plan time
+func TestContainerAppReplicaResourceTotals(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		cpu           float64
+		memory        string
+		sidecars      []map[string]interface{}
+		expectedError string
+	}{
+		{"single_container", 0.5, "1Gi", nil, ""},
+		{"one_sidecar", 0.5, "1Gi", []map[string]interface{}{sidecar("otel", 0.25, "0.5Gi")}, ""},
+		{"two_sidecars", 0.25, "0.5Gi", []map[string]interface{}{sidecar("otel", 0.25, "0.5Gi"), sidecar("proxy", 0.5, "1Gi")}, ""},
+		{"maximum_total", 1.0, "2Gi", []map[string]interface{}{sidecar("worker", 1.0, "2Gi")}, ""},
+		{"memory_mismatch", 0.5, "1Gi", []map[string]interface{}{sidecar("otel", 0.25, "1Gi")}, "not a Consumption combination"},
+		{"cpu_mismatch", 0.5, "1Gi", []map[string]interface{}{sidecar("otel", 0.5, "0.5Gi")}, "not a Consumption combination"},
+		{"over_maximum", 1.5, "3Gi", []map[string]interface{}{sidecar("worker", 1.0, "2Gi")}, "not a Consumption combination"},
+		{"single_container_mismatch", 0.5, "3Gi", nil, "not a Consumption combination"},
+		{"sidecar_cpu_step", 0.5, "1Gi", []map[string]interface{}{sidecar("otel", 0.1, "0.5Gi")}, "multiple of 0.25 vCPU"},
+		{"sidecar_memory_unit", 0.5, "1Gi", []map[string]interface{}{sidecar("otel", 0.25, "512Mi")}, "must be given in Gi"},
+		{"duplicate_sidecar", 0.25, "0.5Gi", []map[string]interface{}{sidecar("otel", 0.25, "0.5Gi"), sidecar("otel", 0.25, "0.5Gi")}, "must be unique"},
+		{"sidecar_named_like_main", 0.5, "1Gi", []map[string]interface{}{sidecar("api", 0.25, "0.5Gi")}, "must differ from the main container name"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sidecars := tc.sidecars
+			if sidecars == nil {
+				sidecars = []map[string]interface{}{}
+			}
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-plan", map[string]interface{}{
+				"container_cpu":      tc.cpu,
+				"container_memory":   tc.memory,
+				"sidecar_containers": sidecars,
+			})
+
+			planJSON, err := helpers.CachedPlanE(t, terraformOptions)
+			if tc.expectedError != "" {
+				if assert.Error(t, err, "Expected %s plus %v to be refused", tc.memory, tc.sidecars) {
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Planning %v vCPU / %s with sidecars %v: %v", tc.cpu, tc.memory, tc.sidecars, err)
+			}
+
+			plan := planassert.Parse(t, planJSON)
+			planassert.AssertAttributeLen(t, plan, containerAppAddress, "template.0.container", 1+len(tc.sidecars),
+				"every sidecar should be a container of the app")
+		})
+	}
+}
+
+// TestContainerAppResourceTableMatchesModule checks that the module's
+// pairing table and the one the tests use agree, so neither drifts from the
+// Consumption combinations alone
+func TestContainerAppResourceTableMatchesModule(t *testing.T) {
+	t.Parallel()
+
+	moduleCombinations, err := helpers.ModuleResourceCombinationsE("../modules/container-app")
+	if err != nil {
+		t.Fatalf("Reading the module's resource combinations: %v", err)
+	}
+
+	expected := map[float64]float64{}
+	for _, combination := range helpers.ConsumptionResourceCombinations {
+		memoryGi, err := helpers.MemoryGi(combination.Memory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[combination.CPU] = memoryGi
+	}
+	assert.Equal(t, expected, moduleCombinations)
+}
+
+// TestContainerAppResourcePairings plans the container-app module with every
+// Consumption CPU / memory combination and with mismatched pairings taken
+// from the same table. Azure only checks the replica total, so a container
+// whose own CPU and memory do not pair is accepted next to sidecars that
+// make the total pair, and the module must refuse only totals that do not
+func TestContainerAppResourcePairings(t *testing.T) {
+	t.Parallel()
+
+	type pairingCase struct {
+		name     string
+		cpu      float64
+		memory   string
+		sidecars []map[string]interface{}
+	}
+
+	var valid, invalid []pairingCase
+	combinations := helpers.ConsumptionResourceCombinations
+	for i, combination := range combinations {
+		valid = append(valid, pairingCase{fmt.Sprintf("%v_%s", combination.CPU, combination.Memory), combination.CPU, combination.Memory, nil})
+
+		// Pair each CPU with the memory of the next combination
+		mismatched := combinations[(i+1)%len(combinations)].Memory
+		invalid = append(invalid, pairingCase{fmt.Sprintf("%v_%s", combination.CPU, mismatched), combination.CPU, mismatched, nil})
+	}
+	valid = append(valid,
+		// 0.75 vCPU / 1Gi and 0.25 vCPU / 1Gi add up to 1 vCPU / 2Gi
+		pairingCase{"unpaired_with_valid_total", 0.75, "1Gi", []map[string]interface{}{sidecar("otel", 0.25, "1Gi")}},
+	)
+	invalid = append(invalid,
+		pairingCase{"smallest_cpu_largest_memory", 0.25, "4Gi", nil},
+		// Each container pairs, but 0.5 vCPU / 1.5Gi does not
+		pairingCase{"paired_with_invalid_total", 0.25, "0.5Gi", []map[string]interface{}{sidecar("otel", 0.25, "1Gi")}},
+	)
+
+	run := func(t *testing.T, tc pairingCase, shouldFail bool) {
+		containers := []helpers.ContainerResources{{CPU: tc.cpu, Memory: tc.memory}}
+		for _, container := range tc.sidecars {
+			containers = append(containers, helpers.ContainerResources{CPU: container["cpu"].(float64), Memory: container["memory"].(string)})
+		}
+		assert.Equal(t, !shouldFail, helpers.IsConsumptionReplica(containers), "the shared table disagrees with the case")
+
+		sidecars := tc.sidecars
+		if sidecars == nil {
+			sidecars = []map[string]interface{}{}
+		}
+		terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-plan", map[string]interface{}{
+			"container_cpu":      tc.cpu,
+			"container_memory":   tc.memory,
+			"sidecar_containers": sidecars,
+		})
+
+		planJSON, err := helpers.CachedPlanE(t, terraformOptions)
+		if shouldFail {
+			if assert.Error(t, err, "Expected %v vCPU / %s with sidecars %v to be refused", tc.cpu, tc.memory, tc.sidecars) {
+				assert.Contains(t, err.Error(), "not a Consumption combination")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Planning %v vCPU / %s with sidecars %v: %v", tc.cpu, tc.memory, tc.sidecars, err)
+		}
+
+		plan := planassert.Parse(t, planJSON)
+		if planassert.AssertAttributeLen(t, plan, containerAppAddress, "template.0.container", len(containers)) {
+			planassert.AssertAttributeEquals(t, plan, containerAppAddress, "template.0.container.0.cpu", tc.cpu)
+			planassert.AssertAttributeEquals(t, plan, containerAppAddress, "template.0.container.0.memory", tc.memory)
+		}
+	}
+
+	for _, tc := range valid {
+		tc := tc
+		t.Run("valid_"+tc.name, func(t *testing.T) {
+			t.Parallel()
+			run(t, tc, false)
+		})
+	}
+	for _, tc := range invalid {
+		tc := tc
+		t.Run("invalid_"+tc.name, func(t *testing.T) {
+			t.Parallel()
+			run(t, tc, true)
+		})
+	}
+}
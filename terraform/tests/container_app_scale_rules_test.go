@@ -0,0 +1,192 @@
+package test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/planassert"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// queueScaleLength and queueScaleMaxReplicas match the scale rule of the
+	// container-app-queue-scale fixture
+	queueScaleLength      = 5
+	queueScaleMaxReplicas = 3
+
+	// queueScaleDepth is how many messages the test enqueues; enough for
+	// more replicas than the rule allows
+	queueScaleDepth = 20
+
+	// queueScaleOutTimeout covers KEDA polling the queue and replicas
+	// starting; queueScaleInTimeout adds the five minute cooldown
+	queueScaleOutTimeout = 10 * time.Minute
+	queueScaleInTimeout  = 15 * time.Minute
+)
+
+// queueScaleReport is the queue_scaling report entry of a run
+type queueScaleReport struct {
+	Depth           int     `json:"depth"`
+	QueueLength     int     `json:"queue_length"`
+	Replicas        int     `json:"replicas"`
+	ScaleOutSeconds float64 `json:"scale_out_seconds"`
+	ScaleInSeconds  float64 `json:"scale_in_seconds"`
+}
+
+// scaleRule returns a custom_scale_rules entry of the given type that
+// authenticates with secretName, or without authentication for ""
+func scaleRule(name, ruleType, secretName string, metadata map[string]string) map[string]interface{} {
+	rule := map[string]interface{}{
+		"name":     name,
+		"type":     ruleType,
+		"metadata": metadata,
+	}
+	if secretName != "" {
+		rule["authentication"] = []map[string]interface{}{{
+			"secret_name":       secretName,
+			"trigger_parameter": "connection",
+		}}
+	}
+	return rule
+}
+
+// TestContainerAppScaleRuleAuthValidation plans the container-app module with
+// Storage Queue and Service Bus scale rules that authenticate with app
+// secrets. A rule naming a secret the app does not have must be refused at
+// plan time, and a valid rule must reach the plan with its authentication
+func TestContainerAppScaleRuleAuthValidation(t *testing.T) {
+	t.Parallel()
+
+	queueMetadata := map[string]string{"queueName": "jobs", "queueLength": "5", "accountName": "stplan"}
+	serviceBusMetadata := map[string]string{"queueName": "jobs", "messageCount": "5", "namespace": "sb-plan"}
+
+	testCases := []struct {
+		name          string
+		secrets       map[string]string
+		rule          map[string]interface{}
+		expectedError string
+	}{
+		{"storage_queue", map[string]string{"queue-connection": "placeholder"}, scaleRule("queue-depth", "azure-queue", "queue-connection", queueMetadata), ""},
+		{"service_bus", map[string]string{"servicebus-connection": "placeholder"}, scaleRule("bus-depth", "azure-servicebus", "servicebus-connection", serviceBusMetadata), ""},
+		{"no_authentication", map[string]string{}, scaleRule("queue-depth", "azure-queue", "", queueMetadata), ""},
+		{"missing_secret", map[string]string{}, scaleRule("queue-depth", "azure-queue", "queue-connection", queueMetadata), "queue-depth/queue-connection"},
+		{"misspelled_secret", map[string]string{"servicebus-connection": "placeholder"}, scaleRule("bus-depth", "azure-servicebus", "servicebus-conection", serviceBusMetadata), "bus-depth/servicebus-conection"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-plan", map[string]interface{}{
+				"secrets":            tc.secrets,
+				"custom_scale_rules": []map[string]interface{}{tc.rule},
+			})
+
+			planJSON, err := helpers.CachedPlanE(t, terraformOptions)
+			if tc.expectedError != "" {
+				if assert.Error(t, err, "Expected scale rule %s to be refused", tc.rule["name"]) {
+					assert.Contains(t, err.Error(), "Scale rules authenticate with secrets the app does not have")
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Planning scale rule %s: %v", tc.rule["name"], err)
+			}
+
+			plan := planassert.Parse(t, planJSON)
+			if !planassert.AssertAttributeLen(t, plan, containerAppAddress, "template.0.custom_scale_rule", 1) {
+				return
+			}
+			const rule = "template.0.custom_scale_rule.0"
+			planassert.AssertAttributeEquals(t, plan, containerAppAddress, rule+".custom_rule_type", tc.rule["type"])
+
+			expected, _ := tc.rule["authentication"].([]map[string]interface{})
+			if planassert.AssertAttributeLen(t, plan, containerAppAddress, rule+".authentication", len(expected)) && len(expected) > 0 {
+				planassert.AssertAttributeEquals(t, plan, containerAppAddress, rule+".authentication.0.secret_name", expected[0]["secret_name"])
+				planassert.AssertAttributeEquals(t, plan, containerAppAddress, rule+".authentication.0.trigger_parameter", "connection")
+			}
+		})
+	}
+}
+
+// TestContainerAppQueueScaleRule deploys the hello-world app with a Storage
+// Queue scale rule that authenticates with a connection string app secret and
+// lets the app scale to zero. Enqueueing messages must scale it out to the
+// replica count the queue depth calls for, and clearing the queue must scale
+// it back to zero. A scaler that cannot authenticate never sees the messages
+// and leaves the app at zero. Opt in with TEST_QUEUE_SCALING=true, since
+// scaling out and back in takes up to half an hour
+func TestContainerAppQueueScaleRule(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_QUEUE_SCALING") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_QUEUE_SCALING=true to test authenticated queue scale rules")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-queue-scale", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("qscale"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"queue_length":        queueScaleLength,
+		"max_replicas":        queueScaleMaxReplicas,
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+	appName := terraform.Output(t, terraformOptions, "container_app_name")
+	queue := terraform.Output(t, terraformOptions, "queue_name")
+	connectionString := helpers.SensitiveOutput(t, terraformOptions, "queue_connection_string")
+
+	// An empty queue leaves nothing to run: start from zero replicas
+	if _, err := helpers.WaitForReplicaCountE(t, resourceGroupName, appName, 0, queueScaleInTimeout); err != nil {
+		t.Fatalf("App did not settle at zero replicas with an empty queue: %v", err)
+	}
+
+	filled := time.Now()
+	if err := helpers.PutQueueMessagesE(t, connectionString, queue, queueScaleDepth); err != nil {
+		t.Fatalf("Filling the queue: %v", err)
+	}
+	replicas := helpers.QueueReplicas(queueScaleDepth, queueScaleLength, 0, queueScaleMaxReplicas)
+	scaleOut, err := helpers.WaitForReplicaCountE(t, resourceGroupName, appName, replicas, queueScaleOutTimeout)
+	if !assert.NoError(t, err, "%d queued messages should scale the app to %d replicas; a scaler that cannot authenticate keeps it at zero",
+		queueScaleDepth, replicas) {
+		return
+	}
+
+	if err := helpers.ClearQueueE(t, connectionString, queue); err != nil {
+		t.Fatalf("Clearing the queue: %v", err)
+	}
+	scaleIn, err := helpers.WaitForReplicaCountE(t, resourceGroupName, appName, 0, queueScaleInTimeout)
+	assert.NoError(t, err, "an empty queue should scale the app back to zero")
+	// Azure Monitor should have seen the scale-out too, not just the API
+	helpers.AssertContainerAppReplicas(t, terraform.Output(t, terraformOptions, "container_app_id"),
+		time.Since(filled)+5*time.Minute, replicas)
+
+	helpers.RecordReport(t, "queue_scaling", config.Location, queueScaleReport{
+		Depth:           queueScaleDepth,
+		QueueLength:     queueScaleLength,
+		Replicas:        replicas,
+		ScaleOutSeconds: scaleOut.Seconds(),
+		ScaleInSeconds:  scaleIn.Seconds(),
+	})
+}
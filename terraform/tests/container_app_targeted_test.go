@@ -0,0 +1,79 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContainerAppTargetedRecreate deploys a public app, then destroys and
+// re-applies only the container app with -target. The environment must be
+// kept, which its default domain shows since a new environment gets a new
+// one, and the app must answer again. The targeted helpers check with a full
+// plan that each step left the stack consistent: after the destroy only the
+// app is left to create, and after the apply nothing is
+func TestContainerAppTargetedRecreate(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name": config.GenerateResourceGroupName("ca-tgt"),
+			"location":            config.Location,
+			"name_suffix":         config.UniqueID,
+			"tags":                helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/container-app-public", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApplyWithRegionFallback(t, config, terraformOptions, vars)
+	phases.Start("verify")
+
+	resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+	environmentName := "cae-pub-" + config.UniqueID
+	environmentDomain := func() string {
+		return helpers.AzCLI(t, "containerapp", "env", "show", "--resource-group", resourceGroupName,
+			"--name", environmentName, "--query", "properties.defaultDomain", "--output", "tsv")
+	}
+	domain := environmentDomain()
+
+	phases.Start("apply")
+	helpers.DestroyTarget(t, terraformOptions, containerAppAddress)
+	helpers.ApplyTarget(t, terraformOptions, containerAppAddress)
+	phases.Start("verify")
+
+	assert.Equal(t, domain, environmentDomain(), "recreating the app should keep its environment")
+
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+	client := &http.Client{Timeout: 30 * time.Second}
+	retry.DoWithRetry(t, "waiting for the recreated app", 30, 20*time.Second, func() (string, error) {
+		response, err := client.Get(applicationURL)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s returned %d", applicationURL, response.StatusCode)
+		}
+		return "", nil
+	})
+}
@@ -1,12 +1,12 @@
 package test
 
 import (
-	"fmt"
 	"strings"
 	"testing"
 
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/basevars"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -54,14 +54,7 @@ func TestContainerAppInputValidation(t *testing.T) {
 
 				terraformOptions := &terraform.Options{
 					TerraformDir: "../modules/container-app",
-					Vars: map[string]interface{}{
-						"name":                      tc.appName,
-						"environment_name":          fmt.Sprintf("cae-test-%s", uniqueID),
-						"resource_group_name":       "rg-nonexistent",
-						"location":                  "eastus2",
-						"log_analytics_workspace_id": "/subscriptions/test/resourceGroups/test/providers/Microsoft.OperationalInsights/workspaces/test",
-						"container_image":           "nginx:latest",
-					},
+					Vars:         basevars.MinimalContainerAppVars(uniqueID).With("name", tc.appName),
 				}
 
 				if tc.shouldFail {
@@ -97,15 +90,7 @@ func TestContainerAppInputValidation(t *testing.T) {
 
 				terraformOptions := &terraform.Options{
 					TerraformDir: "../modules/container-app",
-					Vars: map[string]interface{}{
-						"name":                      fmt.Sprintf("ca-test-%s", uniqueID),
-						"environment_name":          fmt.Sprintf("cae-test-%s", uniqueID),
-						"resource_group_name":       "rg-nonexistent",
-						"location":                  "eastus2",
-						"log_analytics_workspace_id": "/subscriptions/test/resourceGroups/test/providers/Microsoft.OperationalInsights/workspaces/test",
-						"container_image":           "nginx:latest",
-						"container_cpu":             tc.cpu,
-					},
+					Vars:         basevars.MinimalContainerAppVars(uniqueID).With("container_cpu", tc.cpu),
 				}
 
 				if tc.shouldFail {
@@ -141,15 +126,7 @@ func TestContainerAppInputValidation(t *testing.T) {
 
 				terraformOptions := &terraform.Options{
 					TerraformDir: "../modules/container-app",
-					Vars: map[string]interface{}{
-						"name":                      fmt.Sprintf("ca-test-%s", uniqueID),
-						"environment_name":          fmt.Sprintf("cae-test-%s", uniqueID),
-						"resource_group_name":       "rg-nonexistent",
-						"location":                  "eastus2",
-						"log_analytics_workspace_id": "/subscriptions/test/resourceGroups/test/providers/Microsoft.OperationalInsights/workspaces/test",
-						"container_image":           "nginx:latest",
-						"container_memory":          tc.memory,
-					},
+					Vars:         basevars.MinimalContainerAppVars(uniqueID).With("container_memory", tc.memory),
 				}
 
 				if tc.shouldFail {
@@ -185,16 +162,7 @@ func TestContainerAppInputValidation(t *testing.T) {
 
 				terraformOptions := &terraform.Options{
 					TerraformDir: "../modules/container-app",
-					Vars: map[string]interface{}{
-						"name":                      fmt.Sprintf("ca-test-%s", uniqueID),
-						"environment_name":          fmt.Sprintf("cae-test-%s", uniqueID),
-						"resource_group_name":       "rg-nonexistent",
-						"location":                  "eastus2",
-						"log_analytics_workspace_id": "/subscriptions/test/resourceGroups/test/providers/Microsoft.OperationalInsights/workspaces/test",
-						"container_image":           "nginx:latest",
-						"min_replicas":              tc.minReplicas,
-						"max_replicas":              tc.maxReplicas,
-					},
+					Vars:         basevars.MinimalContainerAppVars(uniqueID).With("min_replicas", tc.minReplicas).With("max_replicas", tc.maxReplicas),
 				}
 
 				if tc.shouldFail {
@@ -229,15 +197,7 @@ func TestContainerAppInputValidation(t *testing.T) {
 
 				terraformOptions := &terraform.Options{
 					TerraformDir: "../modules/container-app",
-					Vars: map[string]interface{}{
-						"name":                      fmt.Sprintf("ca-test-%s", uniqueID),
-						"environment_name":          fmt.Sprintf("cae-test-%s", uniqueID),
-						"resource_group_name":       "rg-nonexistent",
-						"location":                  "eastus2",
-						"log_analytics_workspace_id": "/subscriptions/test/resourceGroups/test/providers/Microsoft.OperationalInsights/workspaces/test",
-						"container_image":           "nginx:latest",
-						"traffic_percentage":        tc.percentage,
-					},
+					Vars:         basevars.MinimalContainerAppVars(uniqueID).With("traffic_percentage", tc.percentage),
 				}
 
 				if tc.shouldFail {
@@ -273,15 +233,7 @@ func TestContainerAppTransportValidation(t *testing.T) {
 
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/container-app",
-				Vars: map[string]interface{}{
-					"name":                      fmt.Sprintf("ca-test-%s", uniqueID),
-					"environment_name":          fmt.Sprintf("cae-test-%s", uniqueID),
-					"resource_group_name":       "rg-nonexistent",
-					"location":                  "eastus2",
-					"log_analytics_workspace_id": "/subscriptions/test/resourceGroups/test/providers/Microsoft.OperationalInsights/workspaces/test",
-					"container_image":           "nginx:latest",
-					"ingress_transport":         tc.transport,
-				},
+				Vars:         basevars.MinimalContainerAppVars(uniqueID).With("ingress_transport", tc.transport),
 			}
 
 			if tc.shouldFail {
@@ -315,15 +267,7 @@ func TestContainerAppRevisionModeValidation(t *testing.T) {
 
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/container-app",
-				Vars: map[string]interface{}{
-					"name":                      fmt.Sprintf("ca-test-%s", uniqueID),
-					"environment_name":          fmt.Sprintf("cae-test-%s", uniqueID),
-					"resource_group_name":       "rg-nonexistent",
-					"location":                  "eastus2",
-					"log_analytics_workspace_id": "/subscriptions/test/resourceGroups/test/providers/Microsoft.OperationalInsights/workspaces/test",
-					"container_image":           "nginx:latest",
-					"revision_mode":             tc.revisionMode,
-				},
+				Vars:         basevars.MinimalContainerAppVars(uniqueID).With("revision_mode", tc.revisionMode),
 			}
 
 			if tc.shouldFail {
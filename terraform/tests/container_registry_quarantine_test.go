@@ -0,0 +1,124 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// quarantineImportSource is a small public image imported into the registry
+const quarantineImportSource = "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest"
+
+// fixtureScanReport stands in for the report a scanner attaches when it
+// releases an image
+type fixtureScanReport struct {
+	Scanner         string `json:"scanner"`
+	Result          string `json:"result"`
+	Vulnerabilities int    `json:"vulnerabilities"`
+	ScannedAt       string `json:"scanned_at"`
+}
+
+// TestContainerRegistryQuarantine walks through the quarantine workflow on a
+// Premium registry with quarantine_policy_enabled: a pushed image and an
+// imported one both land in the Quarantined state, a consumer token on the
+// module's read-only scope map cannot pull them, and once released with a
+// scan report the same token can. Opt in with TEST_REGISTRY_QUARANTINE=true,
+// since it uses a Premium registry
+func TestContainerRegistryQuarantine(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_REGISTRY_QUARANTINE") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_REGISTRY_QUARANTINE=true to test the ACR quarantine workflow")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/registry-quarantine", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("acrqt"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	// A Premium registry is about $50 a month; fail before applying anything dearer
+	helpers.AssertCostBelow(t, helpers.EstimatePlanCost(t, terraformOptions), 60)
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	registryName := terraform.Output(t, terraformOptions, "registry_name")
+	loginServer := terraform.Output(t, terraformOptions, "login_server")
+	tokenName := terraform.Output(t, terraformOptions, "consumer_token_name")
+	tokenPassword := helpers.SensitiveOutput(t, terraformOptions, "consumer_token_password")
+
+	image := helpers.BuildFixtureImage(t, "echo", loginServer)
+	pushed := helpers.DigestReference(image.Reference, image.Digest)
+
+	importTarget := "imported/helloworld:latest"
+	if err := helpers.ImportImageE(t, registryName, quarantineImportSource, importTarget); err != nil {
+		t.Fatalf("Importing %s: %v", quarantineImportSource, err)
+	}
+	importedDigest, err := helpers.RegistryDigestE(t, loginServer+"/"+importTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := helpers.DigestReference(loginServer+"/"+importTarget, importedDigest)
+
+	for name, reference := range map[string]string{"pushed": pushed, "imported": imported} {
+		reference := reference
+		t.Run(name, func(t *testing.T) {
+			// The quarantine state is set shortly after the manifest lands
+			var state *helpers.ManifestQuarantine
+			_, err := retry.DoWithRetryE(t, "reading the quarantine state", 12, 5*time.Second, func() (string, error) {
+				var err error
+				if state, err = helpers.ManifestQuarantineE(t, reference); err != nil {
+					return "", err
+				}
+				if state.State == "" {
+					return "", fmt.Errorf("%s has no quarantine state yet", reference)
+				}
+				return "", nil
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, helpers.QuarantineStateQuarantined, state.State, "new images should be quarantined")
+			assert.Error(t, helpers.PullManifestWithTokenE(reference, tokenName, tokenPassword),
+				"a consumer should not pull a quarantined image")
+
+			report := fixtureScanReport{
+				Scanner:   "terratest",
+				Result:    "passed",
+				ScannedAt: time.Now().UTC().Format(time.RFC3339),
+			}
+			if err := helpers.ReleaseQuarantineE(t, reference, report); err != nil {
+				t.Fatalf("Releasing %s: %v", reference, err)
+			}
+
+			released, err := helpers.ManifestQuarantineE(t, reference)
+			if assert.NoError(t, err) {
+				assert.Equal(t, helpers.QuarantineStatePassed, released.State)
+				assert.Contains(t, released.Details, `"scanner":"terratest"`, "the scan report should be kept with the manifest")
+			}
+			_, err = retry.DoWithRetryE(t, "pulling the released image", 12, 5*time.Second, func() (string, error) {
+				return "", helpers.PullManifestWithTokenE(reference, tokenName, tokenPassword)
+			})
+			assert.NoError(t, err, "a consumer should pull the image once released")
+		})
+	}
+}
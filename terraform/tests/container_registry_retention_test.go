@@ -0,0 +1,143 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// retentionRepository holds the manifests pushed by the retention test
+	retentionRepository = "retention"
+
+	// retentionPurgeTimeout bounds the wait for the registry to delete
+	// untagged manifests with retention_days = 0; deletion runs in the
+	// background, not on push
+	retentionPurgeTimeout = 60 * time.Minute
+	retentionPollInterval = time.Minute
+)
+
+// TestContainerRegistryRetention checks the module's retention_enabled and
+// retention_days end to end. It applies a Premium registry that keeps
+// untagged manifests for 0 days, the shortest window the module allows, and
+// pushes two tagged images, one image whose tag then moves to a newer image
+// and one image pushed by digest only. A dry run over the manifest list must
+// pick exactly the two untagged manifests; the registry must then delete
+// those and keep every tagged one. Opt in with TEST_REGISTRY_RETENTION=true,
+// since it uses a Premium registry
+func TestContainerRegistryRetention(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_REGISTRY_RETENTION") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_REGISTRY_RETENTION=true to test untagged manifest retention in ACR")
+	}
+
+	const retentionDays = 0
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/registry-supply-chain", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("acrret"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"sku":                 "Premium",
+		"retention_enabled":   true,
+		"retention_days":      retentionDays,
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	// A Premium registry is about $50 a month; fail before applying anything dearer
+	helpers.AssertCostBelow(t, helpers.EstimatePlanCost(t, terraformOptions), 60)
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	registryName := terraform.Output(t, terraformOptions, "registry_name")
+	repository := terraform.Output(t, terraformOptions, "login_server") + "/" + retentionRepository
+
+	push := func(reference string) string {
+		digest, err := helpers.PushRandomImageE(t, reference)
+		if err != nil {
+			t.Fatalf("Pushing to %s: %v", reference, err)
+		}
+		return digest
+	}
+	// Untagged manifests only count once the policy is on, so push after apply
+	stable := push(repository + ":stable")
+	moved := push(repository + ":moving")
+	moving := push(repository + ":moving")
+	byDigest := push(repository)
+	tagged := []string{stable, moving}
+	untagged := []string{moved, byDigest}
+	sort.Strings(tagged)
+	sort.Strings(untagged)
+
+	manifests, err := helpers.RegistryManifestsE(t, registryName, retentionRepository)
+	if err != nil {
+		t.Fatalf("Listing manifests of %s: %v", repository, err)
+	}
+	// A minute of slack covers clock skew between the runner and the registry
+	assert.Equal(t, untagged, helpers.RetentionDryRun(manifests, retentionDays, time.Now().Add(time.Minute)),
+		"the dry run should select exactly the untagged manifests")
+
+	pushed := time.Now()
+	_, err = retry.DoWithRetryE(t, "waiting for retention to delete untagged manifests",
+		int(retentionPurgeTimeout/retentionPollInterval), retentionPollInterval, func() (string, error) {
+			manifests, err = helpers.RegistryManifestsE(t, registryName, retentionRepository)
+			if err != nil {
+				return "", err
+			}
+			if remaining := intersect(digests(manifests), untagged); len(remaining) > 0 {
+				return "", fmt.Errorf("untagged manifests not deleted yet: %v", remaining)
+			}
+			return "", nil
+		})
+	if !assert.NoError(t, err, "retention should delete untagged manifests within %s", retentionPurgeTimeout) {
+		return
+	}
+	t.Logf("Retention deleted the untagged manifests within %s", time.Since(pushed).Round(time.Second))
+
+	assert.Equal(t, tagged, intersect(digests(manifests), tagged), "retention should keep every tagged manifest")
+	assert.Empty(t, helpers.RetentionDryRun(manifests, retentionDays, time.Now().Add(time.Minute)),
+		"nothing should be left for retention to delete")
+}
+
+// digests returns the digests of manifests
+func digests(manifests []helpers.RegistryManifest) []string {
+	var result []string
+	for _, manifest := range manifests {
+		result = append(result, manifest.Digest)
+	}
+	return result
+}
+
+// intersect returns the values of want found in have, in want's order
+func intersect(have, want []string) []string {
+	found := map[string]bool{}
+	for _, value := range have {
+		found[value] = true
+	}
+	result := []string{}
+	for _, value := range want {
+		if found[value] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
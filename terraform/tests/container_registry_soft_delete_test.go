@@ -0,0 +1,131 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// softDeleteRepository holds the images pushed by the soft delete test
+const softDeleteRepository = "soft-delete"
+
+// TestContainerRegistrySoftDelete checks the module's soft_delete_enabled and
+// soft_delete_retention_days end to end. It applies a registry with soft
+// delete on, checks the registry reports the policy, then pushes two tagged
+// images and deletes one. The deleted tag must be gone from the repository
+// but listed among its deleted tags with the digest it pointed to, while the
+// retention window runs; restoring it must bring the tag back to the same
+// digest and the other image must be untouched throughout. Opt in with
+// TEST_REGISTRY_SOFT_DELETE=true, since the policy is in preview
+func TestContainerRegistrySoftDelete(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_REGISTRY_SOFT_DELETE") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_REGISTRY_SOFT_DELETE=true to test deleted artifact recovery in ACR")
+	}
+
+	const retentionDays = 1
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/registry-supply-chain", map[string]interface{}{
+		"resource_group_name":        config.GenerateResourceGroupName("acrsd"),
+		"location":                   config.Location,
+		"name_suffix":                config.UniqueID,
+		"soft_delete_enabled":        true,
+		"soft_delete_retention_days": retentionDays,
+		"tags":                       helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	registryName := terraform.Output(t, terraformOptions, "registry_name")
+	repository := terraform.Output(t, terraformOptions, "login_server") + "/" + softDeleteRepository
+
+	policy, err := helpers.SoftDeletePolicyE(t, registryName)
+	if err != nil {
+		t.Fatalf("Reading the soft delete policy of %s: %v", registryName, err)
+	}
+	assert.True(t, policy.Enabled(), "soft_delete_enabled should turn the policy on")
+	assert.Equal(t, retentionDays, policy.RetentionDays, "soft_delete_retention_days should set the policy's retention")
+
+	push := func(tag string) string {
+		digest, err := helpers.PushRandomImageE(t, repository+":"+tag)
+		if err != nil {
+			t.Fatalf("Pushing %s:%s: %v", repository, tag, err)
+		}
+		return digest
+	}
+	kept := push("kept")
+	deleted := push("deleted")
+
+	deletedImage := softDeleteRepository + ":deleted"
+	if err := helpers.DeleteImageE(t, registryName, deletedImage); err != nil {
+		t.Fatalf("Deleting %s: %v", deletedImage, err)
+	}
+	deletedAt := time.Now()
+	if _, err := helpers.RegistryDigestE(t, repository+":deleted"); err == nil {
+		t.Errorf("%s:deleted should be gone once deleted", repository)
+	}
+
+	// Deleted artifacts can take a moment to be listed
+	retry.DoWithRetry(t, "waiting for the deleted tag to be listed", 20, 15*time.Second, func() (string, error) {
+		tags, err := helpers.DeletedTagsE(t, registryName, softDeleteRepository)
+		if err != nil {
+			return "", err
+		}
+		if digests := helpers.DeletedDigests(tags, "deleted"); len(digests) == 0 {
+			return "", fmt.Errorf("deleted tag not listed yet: %v", tags)
+		}
+		return "", nil
+	})
+	tags, err := helpers.DeletedTagsE(t, registryName, softDeleteRepository)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{deleted}, helpers.DeletedDigests(tags, "deleted"), "the deleted tag should be listed with the digest it pointed to")
+	assert.Empty(t, helpers.DeletedDigests(tags, "kept"), "tags never deleted are not listed")
+
+	if !assert.Less(t, time.Since(deletedAt), retentionDays*24*time.Hour, "the restore must happen within the retention window") {
+		return
+	}
+	if err := helpers.RestoreTagE(t, registryName, deletedImage, deleted); err != nil {
+		t.Fatalf("Restoring %s: %v", deletedImage, err)
+	}
+
+	retry.DoWithRetry(t, "waiting for the restored tag", 12, 10*time.Second, func() (string, error) {
+		digest, err := helpers.RegistryDigestE(t, repository+":deleted")
+		if err != nil {
+			return "", err
+		}
+		if digest != deleted {
+			return "", fmt.Errorf("%s:deleted points to %s, not %s", repository, digest, deleted)
+		}
+		return "", nil
+	})
+	digest, err := helpers.RegistryDigestE(t, repository+":kept")
+	if assert.NoError(t, err, "the image never deleted should still be there") {
+		assert.Equal(t, kept, digest)
+	}
+	tags, err = helpers.DeletedTagsE(t, registryName, softDeleteRepository)
+	if assert.NoError(t, err) {
+		assert.Empty(t, helpers.DeletedDigests(tags, "deleted"), "a restored tag should no longer be listed as deleted")
+	}
+}
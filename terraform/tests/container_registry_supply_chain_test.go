@@ -0,0 +1,130 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixtureSBOM returns a minimal SPDX 2.3 document describing a fixture image
+func fixtureSBOM(t *testing.T, reference string) []byte {
+	sbom, err := json.Marshal(map[string]interface{}{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              reference,
+		"documentNamespace": "https://terratest.invalid/spdx/" + helpers.RunID() + "/" + t.Name(),
+		"creationInfo": map[string]interface{}{
+			"created":  "2024-01-01T00:00:00Z",
+			"creators": []string{"Tool: terratest"},
+		},
+		"packages": []map[string]interface{}{{
+			"SPDXID":           "SPDXRef-Package-echo",
+			"name":             "echo",
+			"downloadLocation": "NOASSERTION",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Encoding SBOM: %v", err)
+	}
+	return sbom
+}
+
+// TestContainerRegistrySupplyChainArtifacts pushes a notation-signed image
+// with an SPDX SBOM attached to registries with different artifact-related
+// settings. It checks that the signature verifies and that both artifacts can
+// be discovered through oras and the OCI referrers API and pulled back. The
+// Premium case enables content trust and retention with zero days, which
+// makes the untagged artifact manifests eligible for cleanup at once
+func TestContainerRegistrySupplyChainArtifacts(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_SUPPLY_CHAIN") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_SUPPLY_CHAIN=true to test signatures and SBOMs in ACR")
+	}
+
+	registries := map[string]map[string]interface{}{
+		"basic": {"sku": "Basic"},
+		"premium-retention": {
+			"sku":                  "Premium",
+			"retention_enabled":    true,
+			"retention_days":       0,
+			"trust_policy_enabled": true,
+		},
+	}
+
+	for name, settings := range registries {
+		name, settings := name, settings
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tools := helpers.NewSupplyChainTools(t)
+
+			config := helpers.NewTestConfig(t)
+			cost.TrackRun(t, config)
+			vars := map[string]interface{}{
+				"resource_group_name": config.GenerateResourceGroupName("acrsc"),
+				"location":            config.Location,
+				"name_suffix":         config.UniqueID,
+				"tags":                helpers.StandardTags(t.Name()),
+			}
+			for key, value := range settings {
+				vars[key] = value
+			}
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/registry-supply-chain", vars)
+			helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+			phases := helpers.TrackPhases(t)
+			helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+			defer helpers.Destroy(t, terraformOptions)
+			defer phases.Start("destroy")
+			phases.Start("apply")
+			helpers.InitAndApply(t, terraformOptions)
+			phases.Start("verify")
+
+			loginServer := terraform.Output(t, terraformOptions, "login_server")
+			image := helpers.BuildFixtureImage(t, "echo", loginServer)
+			reference := helpers.DigestReference(image.Reference, image.Digest)
+			if err := tools.LoginE(t, loginServer); err != nil {
+				t.Fatalf("Getting credentials for %s: %v", loginServer, err)
+			}
+
+			if err := tools.SignE(t, reference); err != nil {
+				t.Fatalf("Signing %s: %v", reference, err)
+			}
+			sbom := fixtureSBOM(t, reference)
+			if err := tools.AttachSBOME(t, reference, sbom); err != nil {
+				t.Fatalf("Attaching SBOM to %s: %v", reference, err)
+			}
+
+			assert.NoError(t, tools.VerifyE(t, reference), "the signature should verify")
+
+			signatures, err := tools.DiscoverE(t, reference, helpers.NotationSignatureType)
+			assert.NoError(t, err)
+			assert.Len(t, signatures, 1, "oras should discover the signature")
+			sboms, err := tools.DiscoverE(t, reference, helpers.SPDXSBOMType)
+			assert.NoError(t, err)
+			if assert.Len(t, sboms, 1, "oras should discover the SBOM") {
+				pulled, err := tools.PullArtifactE(t, helpers.DigestReference(reference, sboms[0]))
+				if assert.NoError(t, err) {
+					assert.JSONEq(t, string(sbom), string(pulled), "the SBOM should round-trip")
+				}
+			}
+
+			// Last, so retention has had the whole test to remove anything
+			types, err := helpers.ReferrerTypesE(t, reference)
+			if assert.NoError(t, err) {
+				assert.Equal(t, 1, types[helpers.NotationSignatureType], "the referrers API should list the signature")
+				assert.Equal(t, 1, types[helpers.SPDXSBOMType], "the referrers API should list the SBOM")
+			}
+		})
+	}
+}
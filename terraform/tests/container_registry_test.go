@@ -2,12 +2,16 @@ package test
 
 import (
 	"fmt"
+	"net/http"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/gruntwork-io/terratest/modules/azure"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/basevars"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,10 +20,11 @@ func TestContainerRegistryBasic(t *testing.T) {
 	t.Parallel()
 
 	subscriptionID := azure.GetSubscriptionID(t)
-	uniqueID := strings.ToLower(random.UniqueId())
+	uniqueID := helpers.StageUniqueID(t)
 	resourceGroupName := fmt.Sprintf("rg-acr-test-%s", uniqueID)
 	acrName := fmt.Sprintf("acrtest%s", uniqueID)
 	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.ContainerRegistry/registries")
 
 	// First create resource group
 	rgOptions := &terraform.Options{
@@ -33,8 +38,9 @@ func TestContainerRegistryBasic(t *testing.T) {
 			},
 		},
 	}
-	defer terraform.Destroy(t, rgOptions)
-	terraform.InitAndApply(t, rgOptions)
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, rgOptions)
+	helpers.InitAndApply(t, rgOptions)
 
 	// Create ACR
 	acrOptions := &terraform.Options{
@@ -50,8 +56,12 @@ func TestContainerRegistryBasic(t *testing.T) {
 			},
 		},
 	}
-	defer terraform.Destroy(t, acrOptions)
-	terraform.InitAndApply(t, acrOptions)
+	defer helpers.Destroy(t, acrOptions)
+	helpers.InitAndApply(t, acrOptions)
+	helpers.StartValidation(t)
+	helpers.AssertCostProfile(t, acrOptions, "container-registry")
+	helpers.AssertIdempotent(t, acrOptions)
+	helpers.CheckAdvisorRecommendations(t, resourceGroupName)
 
 	// Verify ACR exists
 	acr := azure.GetContainerRegistry(t, resourceGroupName, acrName, subscriptionID)
@@ -64,9 +74,7 @@ func TestContainerRegistryBasic(t *testing.T) {
 	assert.NotEmpty(t, outputs["login_server"], "Login server output should not be empty")
 
 	// Verify login server format
-	loginServer := outputs["login_server"].(string)
-	assert.Contains(t, loginServer, acrName, "Login server should contain ACR name")
-	assert.Contains(t, loginServer, ".azurecr.io", "Login server should be Azure Container Registry")
+	helpers.AssertRegistryLoginServer(t, outputs["login_server"].(string), acrName)
 }
 
 // TestContainerRegistrySkuValidation tests SKU validation
@@ -90,16 +98,10 @@ func TestContainerRegistrySkuValidation(t *testing.T) {
 			t.Parallel()
 
 			uniqueID := strings.ToLower(random.UniqueId())
-			acrName := fmt.Sprintf("acrtest%s", uniqueID)
 
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/container-registry",
-				Vars: map[string]interface{}{
-					"name":                acrName,
-					"resource_group_name": "rg-nonexistent", // Will fail before this
-					"location":            "eastus2",
-					"sku":                 tc.sku,
-				},
+				Vars:         basevars.MinimalContainerRegistryVars(uniqueID).With("sku", tc.sku),
 			}
 
 			if tc.shouldFail {
@@ -151,14 +153,11 @@ func TestContainerRegistryNameValidation(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
+			uniqueID := strings.ToLower(random.UniqueId())
+
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/container-registry",
-				Vars: map[string]interface{}{
-					"name":                tc.acrName,
-					"resource_group_name": "rg-nonexistent",
-					"location":            "eastus2",
-					"sku":                 "Basic",
-				},
+				Vars:         basevars.MinimalContainerRegistryVars(uniqueID).With("name", tc.acrName),
 			}
 
 			if tc.shouldFail {
@@ -175,14 +174,15 @@ func TestContainerRegistryWithDiagnostics(t *testing.T) {
 
 	// This test is marked as slow because it requires Log Analytics
 	if testing.Short() {
-		t.Skip("Skipping slow test in short mode")
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
 	}
 
 	subscriptionID := azure.GetSubscriptionID(t)
-	uniqueID := strings.ToLower(random.UniqueId())
+	uniqueID := helpers.StageUniqueID(t)
 	resourceGroupName := fmt.Sprintf("rg-acr-diag-test-%s", uniqueID)
 	acrName := fmt.Sprintf("acrdiag%s", uniqueID)
 	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.ContainerRegistry/registries")
 
 	// Create resource group
 	rgOptions := &terraform.Options{
@@ -192,8 +192,9 @@ func TestContainerRegistryWithDiagnostics(t *testing.T) {
 			"location": location,
 		},
 	}
-	defer terraform.Destroy(t, rgOptions)
-	terraform.InitAndApply(t, rgOptions)
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, rgOptions)
+	helpers.InitAndApply(t, rgOptions)
 
 	// Create Log Analytics workspace
 	workspaceID := createLogAnalyticsWorkspace(t, resourceGroupName, location, uniqueID)
@@ -202,24 +203,190 @@ func TestContainerRegistryWithDiagnostics(t *testing.T) {
 	acrOptions := &terraform.Options{
 		TerraformDir: "../modules/container-registry",
 		Vars: map[string]interface{}{
-			"name":                      acrName,
-			"resource_group_name":       resourceGroupName,
-			"location":                  location,
-			"sku":                       "Basic",
+			"name":                       acrName,
+			"resource_group_name":        resourceGroupName,
+			"location":                   location,
+			"sku":                        "Basic",
 			"log_analytics_workspace_id": workspaceID,
 			"tags": map[string]string{
 				"Environment": "test",
 			},
 		},
 	}
-	defer terraform.Destroy(t, acrOptions)
-	terraform.InitAndApply(t, acrOptions)
+	defer helpers.Destroy(t, acrOptions)
+	helpers.InitAndApply(t, acrOptions)
+	helpers.StartValidation(t)
 
 	// Verify ACR exists
 	acr := azure.GetContainerRegistry(t, resourceGroupName, acrName, subscriptionID)
 	assert.NotNil(t, acr, "Container Registry should exist")
 }
 
+// TestContainerRegistryAdminUserDefault tests that the admin user is disabled
+// unless explicitly enabled and that credential outputs are always sensitive
+func TestContainerRegistryAdminUserDefault(t *testing.T) {
+	t.Parallel()
+
+	uniqueID := strings.ToLower(random.UniqueId())
+	moduleDir := helpers.CopyModuleToTemp(t, "container-registry")
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: moduleDir,
+		PlanFilePath: filepath.Join(moduleDir, "admin-default.tfplan"),
+		Logger:       helpers.RedactingLogger,
+		Vars: map[string]interface{}{
+			"name":                fmt.Sprintf("acrtest%s", uniqueID),
+			"resource_group_name": "rg-nonexistent",
+			"location":            "eastus2",
+			"enable_diagnostics":  false,
+		},
+	}
+
+	plan := helpers.InitAndPlanAndShowWithStruct(t, terraformOptions)
+
+	registry := plan.ResourcePlannedValuesMap["azurerm_container_registry.this"]
+	if assert.NotNil(t, registry, "Plan should contain the container registry") {
+		assert.Equal(t, false, registry.AttributeValues["admin_enabled"], "Admin user should be disabled by default")
+	}
+
+	for _, name := range []string{"admin_username", "admin_password"} {
+		change := plan.RawPlan.OutputChanges[name]
+		if assert.NotNil(t, change, "Plan should contain output %s", name) {
+			assert.Nil(t, change.After, "Output %s should be null when admin is disabled", name)
+			assert.Equal(t, true, change.AfterSensitive, "Output %s should be sensitive", name)
+		}
+	}
+}
+
+// TestContainerRegistryAdminUserEnabled tests the opt-in admin user path:
+// credentials are exposed only as sensitive outputs and actually authenticate
+func TestContainerRegistryAdminUserEnabled(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	subscriptionID := azure.GetSubscriptionID(t)
+	uniqueID := helpers.StageUniqueID(t)
+	resourceGroupName := fmt.Sprintf("rg-acr-admin-test-%s", uniqueID)
+	acrName := fmt.Sprintf("acradmin%s", uniqueID)
+	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.ContainerRegistry/registries")
+
+	rgOptions := &terraform.Options{
+		TerraformDir: "../modules/resource-group",
+		Vars: map[string]interface{}{
+			"name":     resourceGroupName,
+			"location": location,
+		},
+	}
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, rgOptions)
+	helpers.InitAndApply(t, rgOptions)
+
+	acrOptions := &terraform.Options{
+		TerraformDir: helpers.CopyModuleToTemp(t, "container-registry"),
+		Logger:       helpers.RedactingLogger,
+		Vars: map[string]interface{}{
+			"name":                acrName,
+			"resource_group_name": resourceGroupName,
+			"location":            location,
+			"sku":                 "Basic",
+			"admin_enabled":       true,
+			"enable_diagnostics":  false,
+		},
+	}
+	defer helpers.Destroy(t, acrOptions)
+	helpers.InitAndApply(t, acrOptions)
+	helpers.StartValidation(t)
+
+	assert.True(t, helpers.OutputIsSensitive(t, acrOptions, "admin_username"), "admin_username should be sensitive")
+	assert.True(t, helpers.OutputIsSensitive(t, acrOptions, "admin_password"), "admin_password should be sensitive")
+
+	username := helpers.SensitiveOutput(t, acrOptions, "admin_username")
+	password := helpers.SensitiveOutput(t, acrOptions, "admin_password")
+	assert.Equal(t, acrName, username, "Admin username should be the registry name")
+	assert.NotEmpty(t, password, "Admin password should be exposed when admin is enabled")
+
+	catalogURL := fmt.Sprintf("https://%s/v2/_catalog", terraform.Output(t, acrOptions, "login_server"))
+	assert.Equal(t, http.StatusOK, registryStatusCode(t, catalogURL, username, password),
+		"Admin credentials should authenticate against the registry")
+	assert.Equal(t, http.StatusUnauthorized, registryStatusCode(t, catalogURL, username, password+"x"),
+		"Wrong admin password should be rejected")
+}
+
+// TestContainerRegistryFixtureImages tests publishing the fixture apps to ACR
+// and that unchanged sources are not rebuilt
+func TestContainerRegistryFixtureImages(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	subscriptionID := azure.GetSubscriptionID(t)
+	uniqueID := helpers.StageUniqueID(t)
+	resourceGroupName := fmt.Sprintf("rg-acr-images-test-%s", uniqueID)
+	acrName := fmt.Sprintf("acrimages%s", uniqueID)
+	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.ContainerRegistry/registries")
+
+	rgOptions := &terraform.Options{
+		TerraformDir: "../modules/resource-group",
+		Vars: map[string]interface{}{
+			"name":     resourceGroupName,
+			"location": location,
+		},
+	}
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, rgOptions)
+	helpers.InitAndApply(t, rgOptions)
+
+	acrOptions := &terraform.Options{
+		TerraformDir: helpers.CopyModuleToTemp(t, "container-registry"),
+		Vars: map[string]interface{}{
+			"name":                acrName,
+			"resource_group_name": resourceGroupName,
+			"location":            location,
+			"sku":                 "Basic",
+			"enable_diagnostics":  false,
+		},
+	}
+	defer helpers.Destroy(t, acrOptions)
+	helpers.InitAndApply(t, acrOptions)
+	helpers.StartValidation(t)
+	loginServer := terraform.Output(t, acrOptions, "login_server")
+
+	for _, app := range []string{"echo", "grpc"} {
+		image := helpers.BuildFixtureImage(t, app, loginServer)
+		assert.True(t, image.Built, "%s should be built on a fresh registry", app)
+		assert.True(t, strings.HasPrefix(image.Reference, loginServer+"/fixtures/"+app+":"),
+			"%s should be published under fixtures/ in the test registry", app)
+
+		cached := helpers.BuildFixtureImage(t, app, loginServer)
+		assert.False(t, cached.Built, "%s should not be rebuilt when sources are unchanged", app)
+		assert.Equal(t, image.Reference, cached.Reference)
+		assert.Equal(t, image.Digest, cached.Digest)
+	}
+}
+
+// registryStatusCode calls a registry API endpoint with basic auth and returns the HTTP status
+func registryStatusCode(t *testing.T, url, username, password string) int {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("Creating request for %s: %v", url, err)
+	}
+	request.SetBasicAuth(username, password)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Calling %s: %v", url, err)
+	}
+	defer response.Body.Close()
+	return response.StatusCode
+}
+
 // Helper function to create Log Analytics workspace
 func createLogAnalyticsWorkspace(t *testing.T, resourceGroupName, location, uniqueID string) string {
 	workspaceName := fmt.Sprintf("log-test-%s", uniqueID)
@@ -227,16 +394,16 @@ func createLogAnalyticsWorkspace(t *testing.T, resourceGroupName, location, uniq
 	workspaceOptions := &terraform.Options{
 		TerraformDir: "../modules/observability",
 		Vars: map[string]interface{}{
-			"resource_group_name":  resourceGroupName,
-			"location":             location,
-			"log_analytics_name":   workspaceName,
-			"app_insights_name":    fmt.Sprintf("appi-test-%s", uniqueID),
+			"resource_group_name": resourceGroupName,
+			"location":            location,
+			"log_analytics_name":  workspaceName,
+			"app_insights_name":   fmt.Sprintf("appi-test-%s", uniqueID),
 			"tags": map[string]string{
 				"Test": "true",
 			},
 		},
 	}
 
-	terraform.InitAndApply(t, workspaceOptions)
+	helpers.InitAndApply(t, workspaceOptions)
 	return terraform.Output(t, workspaceOptions, "log_analytics_workspace_id")
 }
@@ -0,0 +1,31 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// TestNoNewDeprecationWarnings validates every module and environment and
+// fails when terraform reports a warning, such as a deprecated argument or a
+// provider deprecation notice, that testdata/deprecations.json does not
+// accept yet. Validation needs no Azure credentials, only provider downloads
+func TestNoNewDeprecationWarnings(t *testing.T) {
+	t.Parallel()
+
+	modules, err := filepath.Glob("../modules/*/versions.tf")
+	if err != nil || len(modules) == 0 {
+		t.Fatalf("No modules found: %v", err)
+	}
+	environments, err := filepath.Glob("../environments/*/main.tf")
+	if err != nil || len(environments) == 0 {
+		t.Fatalf("No environment compositions found: %v", err)
+	}
+
+	var dirs []string
+	for _, file := range append(modules, environments...) {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+	helpers.AssertNoNewWarnings(t, dirs)
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// driftTag is the tag the drift tests add outside terraform
+const driftTag = "Drift=out-of-band"
+
+// driftCase is how TestModuleDriftDetection changes a module's deployment
+// outside terraform and the drift the next plan must report
+type driftCase struct {
+	// args is the az command run against the fixture's drift_target_id,
+	// which is passed with --ids or --resource-id
+	args []string
+	// address and attribute are the resource and configured attribute the
+	// change drifts
+	address   string
+	attribute string
+}
+
+// tagDrift merges driftTag into the tags of a resource
+func tagDrift(address string) driftCase {
+	return driftCase{
+		args:      []string{"tag", "update", "--operation", "Merge", "--tags", driftTag, "--resource-id"},
+		address:   address,
+		attribute: "tags",
+	}
+}
+
+// propertyDrift sets a property of a resource through Resource Manager
+func propertyDrift(property, address, attribute string) driftCase {
+	return driftCase{
+		args:      []string{"resource", "update", "--set", property, "--ids"},
+		address:   address,
+		attribute: attribute,
+	}
+}
+
+// moduleDriftCases are the drift tests of each module, by module directory.
+// Tags drift on every resource; where a module pins a setting that matters
+// more, the test changes that instead
+var moduleDriftCases = map[string]driftCase{
+	"container-app":      tagDrift("module.container_app[0].azurerm_container_app.this"),
+	"container-registry": propertyDrift("properties.adminUserEnabled=true", "module.container_registry[0].azurerm_container_registry.this", "admin_enabled"),
+	"key-vault":          tagDrift("module.key_vault[0].azurerm_key_vault.this"),
+	"networking":         tagDrift("module.networking[0].azurerm_virtual_network.this"),
+	"observability":      propertyDrift("properties.retentionInDays=90", "module.observability[0].azurerm_log_analytics_workspace.this", "retention_in_days"),
+	"private-endpoints":  tagDrift("module.private_endpoints[0].azurerm_private_endpoint.acr"),
+	"resource-group":     tagDrift("module.resource_group.azurerm_resource_group.this"),
+}
+
+// TestModuleDriftCasesCoverModules checks every module has a drift test
+func TestModuleDriftCasesCoverModules(t *testing.T) {
+	t.Parallel()
+
+	dirs, err := filepath.Glob("../modules/*/main.tf")
+	if err != nil || len(dirs) == 0 {
+		t.Fatalf("Finding modules: %v", err)
+	}
+	var modules []string
+	for _, dir := range dirs {
+		modules = append(modules, filepath.Base(filepath.Dir(dir)))
+	}
+	var cases []string
+	for module := range moduleDriftCases {
+		cases = append(cases, module)
+	}
+	sort.Strings(cases)
+	assert.Equal(t, modules, cases, "every module should have a drift test in moduleDriftCases")
+}
+
+// TestModuleDriftDetection deploys each module, changes one of its resources
+// outside terraform and checks the next plan reports the change as managed
+// drift on the expected attribute and puts it back, and that once applied
+// nothing is left to change. Opt in with TEST_MODULE_DRIFT=true: it deploys
+// every module
+func TestModuleDriftDetection(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_MODULE_DRIFT") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_MODULE_DRIFT=true to change each module's resources outside terraform and check the plan reconciles them")
+	}
+
+	for module, drift := range moduleDriftCases {
+		module, drift := module, drift
+		t.Run(module, func(t *testing.T) {
+			t.Parallel()
+
+			config := helpers.NewTestConfig(t)
+			cost.TrackRun(t, config)
+			vars := map[string]interface{}{
+				"resource_group_name": config.GenerateResourceGroupName("drift"),
+				"location":            config.Location,
+				"module":              module,
+				"name_suffix":         config.UniqueID,
+				"tags":                helpers.StandardTags(t.Name()),
+			}
+			if module == "private-endpoints" {
+				// The endpoints only need a subnet, and must be in the
+				// VNet's region. The deferred destroy removes them before
+				// the reference to the VNet is released
+				vnet := helpers.AcquireSharedVNet(t)
+				vars["location"] = vnet.Location
+				vars["vnet_id"] = vnet.VNetID
+				vars["private_endpoint_subnet_id"] = vnet.PrivateEndpointSubnetID
+			}
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/module-drift", vars)
+			helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+			phases := helpers.TrackPhases(t)
+			helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+			defer helpers.Destroy(t, terraformOptions)
+			defer phases.Start("destroy")
+			phases.Start("apply")
+			helpers.InitAndApply(t, terraformOptions)
+			phases.Start("verify")
+
+			targetID := terraform.Output(t, terraformOptions, "drift_target_id")
+			drifted := helpers.MutateAndDetectDrift(t, terraformOptions, func() error {
+				_, err := helpers.AzCLIE(t, append(drift.args, targetID)...)
+				return err
+			})
+
+			var found []string
+			for _, resource := range drifted {
+				if resource.Address == drift.address {
+					assert.Contains(t, resource.Managed, drift.attribute, "%s should have drifted on %s", drift.address, drift.attribute)
+					return
+				}
+				found = append(found, resource.String())
+			}
+			t.Errorf("The plan should report drift of %s, found: %s", drift.address, strings.Join(found, "; "))
+		})
+	}
+}
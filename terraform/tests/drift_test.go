@@ -0,0 +1,104 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// TestEnvironmentDrift runs a refresh-only plan against the state of each
+// long-lived environment and fails for every resource deleted or changed
+// outside terraform in an attribute the configuration sets. Drift in
+// attributes left to the provider is only logged. Both land in the drift
+// report. The plan takes no state lock and never writes the state
+func TestEnvironmentDrift(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_DRIFT") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_DRIFT=true to check long-lived environments for drift")
+	}
+
+	environments := []string{"dev"}
+	if selected := os.Getenv("TEST_DRIFT_ENVIRONMENTS"); selected != "" {
+		environments = strings.Split(selected, ",")
+	}
+
+	for _, env := range environments {
+		env := strings.TrimSpace(env)
+		t.Run(env, func(t *testing.T) {
+			t.Parallel()
+
+			options := environmentOptions(t, env, "check it for drift")
+			options.PlanFilePath = filepath.Join(options.TerraformDir, "drift.tfplan")
+
+			if _, err := terraform.InitE(t, options); err != nil {
+				t.Fatalf("Initializing %s: %v", env, err)
+			}
+			if _, err := terraform.RunTerraformCommandE(t, options, terraform.FormatArgs(options, "plan", "-refresh-only", "-input=false")...); err != nil {
+				t.Fatalf("Planning refresh of %s: %v", env, err)
+			}
+			planJSON, err := terraform.ShowE(t, options)
+			if err != nil {
+				t.Fatalf("Reading refresh plan of %s: %v", env, err)
+			}
+
+			resources, err := helpers.ResourceDriftE(planJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			report := helpers.DriftReport{
+				Environment: env,
+				CheckedAt:   time.Now().UTC().Format(time.RFC3339),
+				Resources:   resources,
+			}
+			helpers.RecordReport(t, "drift", env, report)
+
+			for _, resource := range resources {
+				if resource.ManagedDrift() {
+					t.Error(resource)
+				} else {
+					t.Log(resource)
+				}
+			}
+		})
+	}
+}
+
+// environmentOptions returns options for a copy of the long-lived
+// environment env, set up to init against its state with the environment's
+// backend.hcl. The test is skipped, with what it would have done (purpose),
+// when there is no backend.hcl
+func environmentOptions(t *testing.T, env, purpose string) *terraform.Options {
+	envDir := filepath.Join("../environments", env)
+	backendConfig, err := filepath.Abs(filepath.Join(envDir, "backend.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(backendConfig); err != nil {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, fmt.Sprintf("Create %s from backend.hcl.example to %s", backendConfig, purpose))
+	}
+
+	options := &terraform.Options{
+		TerraformDir: helpers.CopyTerraformDirToTemp(t, envDir),
+		NoColor:      true,
+		Logger:       helpers.RedactingLogger,
+		Reconfigure:  true,
+		EnvVars:      map[string]string{"TF_CLI_ARGS_init": "-backend-config=" + backendConfig},
+	}
+	// The copy leaves terraform.tfvars behind, so pass the original
+	if varFile, err := filepath.Abs(filepath.Join(envDir, "terraform.tfvars")); err == nil {
+		if _, err := os.Stat(varFile); err == nil {
+			options.VarFiles = []string{varFile}
+		}
+	}
+	return options
+}
@@ -0,0 +1,27 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// TestModuleErrorMessageCatalog compares the error message of every module
+// validation, precondition and postcondition with the reviewed catalog in
+// testdata/error-messages.json, so user-facing error text that automation
+// matches on only changes deliberately
+func TestModuleErrorMessageCatalog(t *testing.T) {
+	t.Parallel()
+
+	modules, err := filepath.Glob("../modules/*/variables.tf")
+	if err != nil || len(modules) == 0 {
+		t.Fatalf("No modules found: %v", err)
+	}
+
+	var dirs []string
+	for _, variables := range modules {
+		dirs = append(dirs, filepath.Dir(variables))
+	}
+	helpers.AssertErrorMessageCatalog(t, dirs)
+}
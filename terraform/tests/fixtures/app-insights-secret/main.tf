@@ -0,0 +1,113 @@
+# App Insights Secret Fixture
+# Hands the Application Insights connection string to the echo fixture app
+# through Key Vault: terraform writes it as a vault secret and the app gets it
+# as a Key Vault reference resolved by a user-assigned identity, so the value
+# is never a plain app setting or a fixture output.
+
+data "azurerm_client_config" "current" {}
+
+locals {
+  # Name of the Container App secret holding the Key Vault reference
+  app_secret_name = "appinsights-connection-string"
+}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  log_analytics_name  = "log-ais-${var.name_suffix}"
+  app_insights_name   = "appi-ais-${var.name_suffix}"
+  tags                = var.tags
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                = "acrais${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+module "key_vault" {
+  source = "../../../modules/key-vault"
+
+  name                       = "kv-ais-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  soft_delete_retention_days = 7
+  purge_protection_enabled   = false
+  enable_diagnostics         = false
+  deployer_object_id         = data.azurerm_client_config.current.object_id
+  tags                       = var.tags
+}
+
+# A resource of its own rather than an entry in the module's secrets map, so
+# the sensitive value stays out of a for_each
+resource "azurerm_key_vault_secret" "app_insights" {
+  name         = "appinsights-connection-string"
+  value        = module.observability.app_insights_connection_string
+  key_vault_id = module.key_vault.id
+  content_type = "text/plain"
+
+  # The deployer's Key Vault role is assigned inside the module
+  depends_on = [module.key_vault]
+}
+
+# Key Vault references are resolved when the app is created, before its
+# system-assigned identity could be granted anything
+resource "azurerm_user_assigned_identity" "secrets" {
+  name                = "id-ais-${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  tags                = var.tags
+}
+
+resource "azurerm_role_assignment" "secrets_user" {
+  scope                = module.key_vault.id
+  role_definition_name = "Key Vault Secrets User"
+  principal_id         = azurerm_user_assigned_identity.secrets.principal_id
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.container_image == "" ? 0 : 1
+
+  name                       = "ca-ais-${var.name_suffix}"
+  environment_name           = "cae-ais-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = module.observability.log_analytics_workspace_id
+
+  container_image = var.container_image
+  min_replicas    = 1
+  max_replicas    = 1
+
+  registry_server       = module.container_registry.login_server
+  registry_auth_mode    = "system_identity"
+  enable_acr_pull       = true
+  container_registry_id = module.container_registry.id
+
+  key_vault_secrets = {
+    (local.app_secret_name) = azurerm_key_vault_secret.app_insights.versionless_id
+  }
+  key_vault_secret_identity_id = azurerm_user_assigned_identity.secrets.id
+  secret_environment_variables = {
+    APPLICATIONINSIGHTS_CONNECTION_STRING = local.app_secret_name
+  }
+
+  tags = var.tags
+
+  depends_on = [azurerm_role_assignment.secrets_user]
+}
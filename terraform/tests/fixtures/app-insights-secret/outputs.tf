@@ -0,0 +1,34 @@
+# App Insights Secret Fixture - Outputs
+# The connection string itself is deliberately not an output
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "registry_login_server" {
+  value = module.container_registry.login_server
+}
+
+output "key_vault_name" {
+  value = module.key_vault.name
+}
+
+output "secret_name" {
+  value = azurerm_key_vault_secret.app_insights.name
+}
+
+output "secret_versionless_id" {
+  value = azurerm_key_vault_secret.app_insights.versionless_id
+}
+
+output "secrets_identity_id" {
+  value = azurerm_user_assigned_identity.secrets.id
+}
+
+output "container_app_name" {
+  value = try(module.container_app[0].name, "")
+}
+
+output "application_url" {
+  value = try(module.container_app[0].application_url, "")
+}
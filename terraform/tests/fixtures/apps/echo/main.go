@@ -0,0 +1,405 @@
+// Command echo is a minimal HTTP server used as a Container App test image.
+// It answers every request with the request line, headers and body so tests
+// can assert on ingress, headers and routing without a real workload.
+// /resolve?host=<name> resolves a name from inside the container,
+// /log?marker=<id> writes a synthetic log line to stdout,
+// /env?prefix=<prefix> dumps the environment variables starting with prefix,
+// /trace?downstream=<url> records a W3C-traced request (and its call to url)
+// in Application Insights, /egress?url=<url> reports whether an outbound
+// request to url gets an answer, /keyvault?vault=<uri>&secret=<name> reads
+// a secret with the app's managed identity, /file?name=<name> reads (GET)
+// or writes (PUT) a file in FILES_DIR, such as a mounted volume or a folder
+// of the container's own filesystem, and /slow?seconds=<n> answers after n
+// seconds. Every response names the replica that served it in the
+// X-Echo-Replica header
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", ok)
+	mux.HandleFunc("/ready", ok)
+	mux.HandleFunc("/resolve", resolve)
+	mux.HandleFunc("/log", syntheticLog)
+	mux.HandleFunc("/env", envDump)
+	mux.HandleFunc("/trace", trace)
+	mux.HandleFunc("/egress", egress)
+	mux.HandleFunc("/keyvault", keyVaultSecret)
+	mux.HandleFunc("/file", file)
+	mux.HandleFunc("/slow", slow)
+	mux.HandleFunc("/", echo)
+
+	log.Printf("echo listening on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, withReplica(mux)))
+}
+
+// withReplica sets X-Echo-Replica to the host name, which is the replica
+// name in Container Apps, on every response
+func withReplica(next http.Handler) http.Handler {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Printf("reading host name: %v", err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hostname != "" {
+			w.Header().Set("X-Echo-Replica", hostname)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func echo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s %s %s\n", r.Method, r.URL.RequestURI(), r.Proto)
+
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range r.Header[name] {
+			fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+
+	fmt.Fprintln(w)
+	if _, err := io.Copy(w, r.Body); err != nil {
+		log.Printf("echoing body: %v", err)
+	}
+}
+
+func ok(w http.ResponseWriter, _ *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// resolve looks up the host query parameter with the container's resolver
+// and reports the addresses, or the error, as JSON
+func resolve(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	if host == "" {
+		http.Error(w, "host query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	result := struct {
+		Host      string   `json:"host"`
+		Addresses []string `json:"addresses"`
+		Error     string   `json:"error,omitempty"`
+	}{Host: host}
+	addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Addresses = addresses
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("encoding resolve result: %v", err)
+	}
+}
+
+// syntheticLog writes one JSON line carrying the marker query parameter and
+// the emission time to stdout, and returns the same line to the caller
+func syntheticLog(w http.ResponseWriter, r *http.Request) {
+	marker := r.URL.Query().Get("marker")
+	if marker == "" {
+		http.Error(w, "marker query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	line, err := json.Marshal(struct {
+		Marker    string `json:"synthetic_marker"`
+		EmittedAt string `json:"emitted_at"`
+	}{marker, time.Now().UTC().Format(time.RFC3339Nano)})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(os.Stdout, string(line))
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, string(line))
+}
+
+// envDump returns the environment variables whose names start with the
+// prefix query parameter as a JSON object, byte for byte. The prefix is
+// required so platform variables such as IDENTITY_HEADER are never exposed
+func envDump(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		http.Error(w, "prefix query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	variables := map[string]string{}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, prefix) {
+			variables[name] = value
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(variables); err != nil {
+		log.Printf("encoding environment: %v", err)
+	}
+}
+
+// egressResult is the outcome of an outbound request from the app
+type egressResult struct {
+	URL string `json:"url"`
+	// Status is the HTTP status received, or 0 if no response arrived
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// egress sends a GET to the url query parameter from inside the container
+// and reports the response status, or the connection error. The response
+// body is discarded
+func egress(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	if target == "" {
+		http.Error(w, "url query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	result := egressResult{URL: target}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err == nil {
+		var response *http.Response
+		// Redirects would probe other hosts than the one asked for
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		if response, err = client.Do(request); err == nil {
+			result.Status = response.StatusCode
+			response.Body.Close()
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("encoding egress result: %v", err)
+	}
+}
+
+// keyVaultResult is the outcome of reading a secret from inside the app. The
+// secret value is never returned, only its hash
+type keyVaultResult struct {
+	Vault  string `json:"vault"`
+	Secret string `json:"secret"`
+	// Status is the Key Vault response status, or 0 if the vault was not reached
+	Status int `json:"status"`
+	// Code is the Key Vault error code, e.g. ForbiddenByFirewall
+	Code        string `json:"code,omitempty"`
+	ValueSHA256 string `json:"value_sha256,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// keyVaultSecret reads the secret query parameter from the vault query
+// parameter with a token for the app's system-assigned identity
+func keyVaultSecret(w http.ResponseWriter, r *http.Request) {
+	vault := strings.TrimRight(r.URL.Query().Get("vault"), "/")
+	secret := r.URL.Query().Get("secret")
+	if vault == "" || secret == "" {
+		http.Error(w, "vault and secret query parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	result := keyVaultResult{Vault: vault, Secret: secret}
+	if err := readKeyVaultSecret(r.Context(), &result); err != nil {
+		result.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("encoding keyvault result: %v", err)
+	}
+}
+
+func readKeyVaultSecret(ctx context.Context, result *keyVaultResult) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	token, err := managedIdentityToken(ctx, "https://vault.azure.net")
+	if err != nil {
+		return fmt.Errorf("getting managed identity token: %w", err)
+	}
+
+	secretURL := fmt.Sprintf("%s/secrets/%s?api-version=7.4", result.Vault, url.PathEscape(result.Secret))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, secretURL, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		Value string `json:"value"`
+		Error struct {
+			Code       string `json:"code"`
+			InnerError struct {
+				Code string `json:"code"`
+			} `json:"innererror"`
+		} `json:"error"`
+	}
+	result.Status = response.StatusCode
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return fmt.Errorf("decoding Key Vault response: %w", err)
+	}
+	result.Code = body.Error.Code
+	if body.Error.InnerError.Code != "" {
+		result.Code = body.Error.InnerError.Code
+	}
+	if response.StatusCode == http.StatusOK {
+		sum := sha256.Sum256([]byte(body.Value))
+		result.ValueSHA256 = hex.EncodeToString(sum[:])
+	}
+	return nil
+}
+
+// managedIdentityToken gets a token for resource from the Container Apps
+// identity endpoint
+func managedIdentityToken(ctx context.Context, resource string) (string, error) {
+	endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER")
+	if endpoint == "" || header == "" {
+		return "", fmt.Errorf("no managed identity: IDENTITY_ENDPOINT is not set")
+	}
+
+	query := url.Values{"resource": {resource}, "api-version": {"2019-08-01"}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("X-IDENTITY-HEADER", header)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("identity endpoint returned %d", response.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// maxFileSize bounds what /file writes
+const maxFileSize = 1 << 20
+
+// file reads or writes the file named by the name query parameter in
+// FILES_DIR
+func file(w http.ResponseWriter, r *http.Request) {
+	dir := os.Getenv("FILES_DIR")
+	if dir == "" {
+		http.Error(w, "FILES_DIR is not set", http.StatusNotFound)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		http.Error(w, "name query parameter must be a plain file name", http.StatusBadRequest)
+		return
+	}
+
+	path := filepath.Join(dir, name)
+	switch r.Method {
+	case http.MethodGet:
+		content, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			http.Error(w, "no such file", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if _, err := w.Write(content); err != nil {
+			log.Printf("writing file content: %v", err)
+		}
+	case http.MethodPut:
+		content, err := io.ReadAll(io.LimitReader(r.Body, maxFileSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// The image has no folders of its own, so a FILES_DIR on the
+		// container's filesystem is created on first write
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// maxSlowSeconds bounds how long /slow waits, past any ingress timeout
+const maxSlowSeconds = 900
+
+// slow answers after the seconds query parameter, or when the client goes
+// away, so callers can see where the ingress gives up on a late response
+func slow(w http.ResponseWriter, r *http.Request) {
+	seconds, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+	if err != nil || seconds < 0 || seconds > maxSlowSeconds {
+		http.Error(w, fmt.Sprintf("seconds query parameter must be 0 to %d", maxSlowSeconds), http.StatusBadRequest)
+		return
+	}
+
+	started := time.Now()
+	select {
+	case <-time.After(time.Duration(seconds) * time.Second):
+		fmt.Fprintf(w, "answered after %ds\n", seconds)
+	case <-r.Context().Done():
+		log.Printf("slow request abandoned after %s", time.Since(started).Round(time.Second))
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// traceResult is what /trace returns: the spans this app recorded and, when
+// it called a downstream app, that app's result
+type traceResult struct {
+	Role    string `json:"role"`
+	TraceID string `json:"trace_id"`
+	// ParentID is the span ID received in traceparent, empty for a new trace
+	ParentID string `json:"parent_id,omitempty"`
+	// SpanID is the ID of the request span recorded for this call
+	SpanID string `json:"span_id"`
+	// DependencyID is the ID of the span recorded for the downstream call
+	DependencyID   string       `json:"dependency_id,omitempty"`
+	Downstream     *traceResult `json:"downstream,omitempty"`
+	DownstreamCode int          `json:"downstream_status,omitempty"`
+	Error          string       `json:"error,omitempty"`
+	TelemetryError string       `json:"telemetry_error,omitempty"`
+}
+
+// trace joins the W3C trace in the traceparent header, or starts one, and
+// records the request in Application Insights. With a downstream query
+// parameter it first calls that URL with a traceparent naming its own
+// dependency span, so the downstream app's request is its child
+func trace(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	result := traceResult{Role: roleName(), SpanID: randomHex(8)}
+	if traceID, parentID, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		result.TraceID, result.ParentID = traceID, parentID
+	} else {
+		result.TraceID = randomHex(16)
+	}
+
+	var telemetry []envelope
+	if downstream := r.URL.Query().Get("downstream"); downstream != "" {
+		result.DependencyID = randomHex(8)
+		callStarted := time.Now()
+		err := callDownstream(r.Context(), downstream, &result)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		telemetry = append(telemetry, dependencyEnvelope(&result, downstream, callStarted, err == nil && result.DownstreamCode < 400))
+	}
+
+	status := http.StatusOK
+	if result.Error != "" {
+		status = http.StatusBadGateway
+	}
+	telemetry = append(telemetry, requestEnvelope(&result, r, started, status))
+	if err := sendTelemetry(r.Context(), telemetry); err != nil {
+		result.TelemetryError = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("encoding trace result: %v", err)
+	}
+}
+
+// callDownstream calls target as a child of result's dependency span and
+// records the downstream app's answer in result
+func callDownstream(ctx context.Context, target string, result *traceResult) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-01", result.TraceID, result.DependencyID))
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	result.DownstreamCode = response.StatusCode
+	var downstream traceResult
+	if err := json.NewDecoder(response.Body).Decode(&downstream); err != nil {
+		return fmt.Errorf("decoding downstream result: %w", err)
+	}
+	result.Downstream = &downstream
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("downstream returned %d", response.StatusCode)
+	}
+	return nil
+}
+
+// parseTraceparent returns the trace and parent span IDs of a version 00
+// traceparent header
+func parseTraceparent(header string) (traceID, parentID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" || !isHex(parts[1], 32) || !isHex(parts[2], 16) || !isHex(parts[3], 2) {
+		return "", "", false
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func isHex(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	for _, c := range value {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(size int) string {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		log.Fatalf("reading random bytes: %v", err)
+	}
+	return hex.EncodeToString(value)
+}
+
+// roleName is the cloud role the app reports, the Container App's name when
+// running in Container Apps
+func roleName() string {
+	if name := os.Getenv("CONTAINER_APP_NAME"); name != "" {
+		return name
+	}
+	return "echo"
+}
+
+// envelope is one Application Insights telemetry item in the ingestion
+// endpoint's format
+type envelope struct {
+	Name string            `json:"name"`
+	Time string            `json:"time"`
+	IKey string            `json:"iKey"`
+	Tags map[string]string `json:"tags"`
+	Data struct {
+		BaseType string                 `json:"baseType"`
+		BaseData map[string]interface{} `json:"baseData"`
+	} `json:"data"`
+}
+
+func newEnvelope(name, baseType string, result *traceResult, parentID string, started time.Time, baseData map[string]interface{}) envelope {
+	item := envelope{
+		Name: "Microsoft.ApplicationInsights." + name,
+		Time: started.UTC().Format(time.RFC3339Nano),
+		Tags: map[string]string{
+			"ai.operation.id":       result.TraceID,
+			"ai.operation.parentId": parentID,
+			"ai.cloud.role":         result.Role,
+		},
+	}
+	baseData["ver"] = 2
+	item.Data.BaseType = baseType
+	item.Data.BaseData = baseData
+	return item
+}
+
+func requestEnvelope(result *traceResult, r *http.Request, started time.Time, status int) envelope {
+	return newEnvelope("Request", "RequestData", result, result.ParentID, started, map[string]interface{}{
+		"id":           result.SpanID,
+		"name":         r.Method + " " + r.URL.Path,
+		"url":          "https://" + r.Host + r.URL.RequestURI(),
+		"duration":     formatDuration(time.Since(started)),
+		"responseCode": fmt.Sprint(status),
+		"success":      status < 400,
+	})
+}
+
+func dependencyEnvelope(result *traceResult, target string, started time.Time, success bool) envelope {
+	host := target
+	if parsed, err := url.Parse(target); err == nil {
+		host = parsed.Host
+	}
+	return newEnvelope("RemoteDependency", "RemoteDependencyData", result, result.SpanID, started, map[string]interface{}{
+		"id":         result.DependencyID,
+		"name":       "GET " + host,
+		"type":       "HTTP",
+		"target":     host,
+		"data":       target,
+		"duration":   formatDuration(time.Since(started)),
+		"resultCode": fmt.Sprint(result.DownstreamCode),
+		"success":    success,
+	})
+}
+
+// formatDuration renders d in the d.hh:mm:ss.fffffff form Application
+// Insights expects
+func formatDuration(d time.Duration) string {
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	return fmt.Sprintf("%d.%02d:%02d:%02d.%07d", days, d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second, d%time.Second/100)
+}
+
+// sendTelemetry posts items to the ingestion endpoint named by
+// APPLICATIONINSIGHTS_CONNECTION_STRING
+func sendTelemetry(ctx context.Context, items []envelope) error {
+	settings := map[string]string{}
+	for _, part := range strings.Split(os.Getenv("APPLICATIONINSIGHTS_CONNECTION_STRING"), ";") {
+		if key, value, found := strings.Cut(part, "="); found {
+			settings[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	instrumentationKey := settings["instrumentationkey"]
+	if instrumentationKey == "" {
+		return fmt.Errorf("APPLICATIONINSIGHTS_CONNECTION_STRING has no InstrumentationKey")
+	}
+	endpoint := settings["ingestionendpoint"]
+	if endpoint == "" {
+		endpoint = "https://dc.services.visualstudio.com"
+	}
+	for i := range items {
+		items[i].IKey = instrumentationKey
+	}
+
+	body, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/v2/track", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// The endpoint answers 200 only when it accepted every item
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("ingestion endpoint returned %d", response.StatusCode)
+	}
+	return nil
+}
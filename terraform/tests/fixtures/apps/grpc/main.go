@@ -0,0 +1,36 @@
+// Command grpc is a minimal gRPC server used as a Container App test image.
+// It serves the standard health service and reflection, so grpcurl or a
+// health client can probe it without generated stubs
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "50051"
+	}
+
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatalf("listening on :%s: %v", port, err)
+	}
+
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	log.Printf("grpc listening on :%s", port)
+	log.Fatal(server.Serve(listener))
+}
@@ -0,0 +1,198 @@
+// Command webhook is a Container App test image that receives webhooks
+// (budget alerts, Monitor action groups, ACR webhooks) on
+// POST /hooks/<source>?token=<WEBHOOK_TOKEN> and records each one as a
+// message on the storage queue QUEUE_URL, using the app's system-assigned
+// identity. Tests read the queue with helpers.WaitForWebhookE; <source>
+// lets every test pick out its own deliveries
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// maxMessageBytes is what a queue message holds once base64 encoded,
+	// leaving room for the envelope around the body
+	maxMessageBytes = 64 << 10
+	maxBodyBytes    = 44 << 10
+	// queueAPIVersion is the Storage REST API version that takes AAD tokens
+	queueAPIVersion = "2021-08-06"
+)
+
+// validSource keeps sources usable as test names and query filters
+var validSource = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// payload is the queue message recorded for one delivery; it mirrors
+// helpers.WebhookPayload
+type payload struct {
+	Source      string            `json:"source"`
+	ReceivedAt  time.Time         `json:"received_at"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`
+	Truncated   bool              `json:"truncated,omitempty"`
+}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", ok)
+	mux.HandleFunc("/ready", ok)
+	mux.HandleFunc("/hooks/", hook)
+
+	log.Printf("webhook receiver listening on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, mux))
+}
+
+func ok(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// hook records a delivery on the queue and answers 202 once it is stored,
+// so a sender that retries on failure does not lose it
+func hook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "webhooks are POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := os.Getenv("WEBHOOK_TOKEN")
+	if token == "" || subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+		http.Error(w, "unknown token", http.StatusUnauthorized)
+		return
+	}
+	source := strings.TrimPrefix(r.URL.Path, "/hooks/")
+	if !validSource.MatchString(source) {
+		http.Error(w, "source must be 1-128 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	delivery := payload{
+		Source:      source,
+		ReceivedAt:  time.Now().UTC(),
+		ContentType: r.Header.Get("Content-Type"),
+		Headers:     map[string]string{},
+		Body:        string(body),
+	}
+	if len(body) > maxBodyBytes {
+		delivery.Body = string(body[:maxBodyBytes])
+		delivery.Truncated = true
+	}
+	for name := range r.Header {
+		// Credentials of the sender stay out of the queue
+		if name != "Authorization" && name != "Cookie" {
+			delivery.Headers[name] = r.Header.Get(name)
+		}
+	}
+
+	if err := enqueue(r.Context(), delivery); err != nil {
+		log.Printf("recording %s webhook: %v", source, err)
+		http.Error(w, "could not record the webhook", http.StatusServiceUnavailable)
+		return
+	}
+	log.Printf("recorded %s webhook (%d bytes)", source, len(body))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// enqueue puts delivery on QUEUE_URL as base64-encoded JSON
+func enqueue(ctx context.Context, delivery payload) error {
+	queueURL := strings.TrimRight(os.Getenv("QUEUE_URL"), "/")
+	if queueURL == "" {
+		return fmt.Errorf("QUEUE_URL is not set")
+	}
+	content, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	text := base64.StdEncoding.EncodeToString(content)
+	if len(text) > maxMessageBytes {
+		return fmt.Errorf("message of %d bytes exceeds the queue limit", len(text))
+	}
+	var message bytes.Buffer
+	message.WriteString("<QueueMessage><MessageText>")
+	if err := xml.EscapeText(&message, []byte(text)); err != nil {
+		return err
+	}
+	message.WriteString("</MessageText></QueueMessage>")
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	token, err := managedIdentityToken(ctx, "https://storage.azure.com/")
+	if err != nil {
+		return fmt.Errorf("getting managed identity token: %w", err)
+	}
+
+	// Messages are kept until the tests read them, not the default 7 days
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, queueURL+"/messages?messagettl=-1", &message)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("x-ms-version", queueAPIVersion)
+	request.Header.Set("Content-Type", "application/xml")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("queue returned %d: %s", response.StatusCode, detail)
+	}
+	return nil
+}
+
+// managedIdentityToken gets a token for resource from the Container Apps
+// identity endpoint
+func managedIdentityToken(ctx context.Context, resource string) (string, error) {
+	endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER")
+	if endpoint == "" || header == "" {
+		return "", fmt.Errorf("no managed identity: IDENTITY_ENDPOINT is not set")
+	}
+
+	query := url.Values{"resource": {resource}, "api-version": {"2019-08-01"}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("X-IDENTITY-HEADER", header)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("identity endpoint returned %d", response.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
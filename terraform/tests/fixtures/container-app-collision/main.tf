@@ -0,0 +1,51 @@
+# Container App Collision Fixture
+# Two instances of the container-app module in one resource group, each
+# with its own environment, serving on the same port. The second instance
+# is only deployed once its names are set, so a test can deploy the first
+# and then plan a second that reuses its names.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+locals {
+  instances = merge(
+    { first = { name = var.first_app_name, environment_name = var.first_environment_name } },
+    var.second_app_name == "" ? {} : { second = { name = var.second_app_name, environment_name = var.second_environment_name } }
+  )
+}
+
+module "container_app" {
+  source   = "../../../modules/container-app"
+  for_each = local.instances
+
+  name                       = each.value.name
+  environment_name           = each.value.environment_name
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.this.id
+
+  container_image     = var.container_image
+  ingress_target_port = 80
+  min_replicas        = 1
+  max_replicas        = 1
+
+  # The hello-world image has no /health or /ready endpoints
+  liveness_probe_enabled  = false
+  readiness_probe_enabled = false
+
+  tags = var.tags
+}
@@ -0,0 +1,16 @@
+# Container App Collision Fixture - Outputs
+
+# Per instance (first, second): what the module says it deployed
+output "apps" {
+  value = {
+    for key, app in module.container_app : key => {
+      id                         = app.id
+      name                       = app.name
+      environment_id             = app.environment_id
+      environment_name           = app.environment_name
+      environment_default_domain = app.environment_default_domain
+      ingress_fqdn               = app.ingress_fqdn
+      application_url            = app.application_url
+    }
+  }
+}
@@ -0,0 +1,51 @@
+# Container App Collision Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for the names of shared fixture resources"
+  type        = string
+}
+
+variable "first_app_name" {
+  description = "Name of the first Container App"
+  type        = string
+}
+
+variable "first_environment_name" {
+  description = "Name of the first app's environment"
+  type        = string
+}
+
+variable "second_app_name" {
+  description = "Name of the second Container App; empty deploys only the first"
+  type        = string
+  default     = ""
+}
+
+variable "second_environment_name" {
+  description = "Name of the second app's environment"
+  type        = string
+  default     = ""
+}
+
+variable "container_image" {
+  description = "Public image served by both apps"
+  type        = string
+  default     = "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest"
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,163 @@
+# Container App DNS Fixture
+# Deploys a VNet-integrated Container App environment whose VNet resolves a
+# private DNS zone, either through Azure-provided DNS or through custom DNS
+# servers pointing at a Private DNS Resolver inbound endpoint.
+
+locals {
+  use_resolver = var.dns_mode == "private_resolver"
+}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+module "networking" {
+  source = "../../../modules/networking"
+
+  vnet_name           = "vnet-dns-${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  tags                = var.tags
+}
+
+#------------------------------------------------------------------------------
+# Private DNS zone with the record the app resolves
+#------------------------------------------------------------------------------
+
+resource "azurerm_private_dns_zone" "this" {
+  name                = var.private_zone_name
+  resource_group_name = module.resource_group.name
+  tags                = var.tags
+}
+
+resource "azurerm_private_dns_a_record" "probe" {
+  name                = "probe"
+  zone_name           = azurerm_private_dns_zone.this.name
+  resource_group_name = module.resource_group.name
+  ttl                 = 60
+  records             = [var.probe_record_ip]
+}
+
+resource "azurerm_private_dns_zone_virtual_network_link" "this" {
+  name                  = "link-${var.name_suffix}"
+  resource_group_name   = module.resource_group.name
+  private_dns_zone_name = azurerm_private_dns_zone.this.name
+  virtual_network_id    = module.networking.vnet_id
+  registration_enabled  = false
+  tags                  = var.tags
+}
+
+#------------------------------------------------------------------------------
+# Private DNS Resolver used as the VNet's custom DNS server
+#------------------------------------------------------------------------------
+
+resource "azurerm_subnet" "dns_inbound" {
+  count = local.use_resolver ? 1 : 0
+
+  name                 = "snet-dns-inbound"
+  resource_group_name  = module.resource_group.name
+  virtual_network_name = module.networking.vnet_name
+  address_prefixes     = ["10.0.4.0/28"]
+
+  delegation {
+    name = "Microsoft.Network.dnsResolvers"
+
+    service_delegation {
+      name    = "Microsoft.Network/dnsResolvers"
+      actions = ["Microsoft.Network/virtualNetworks/subnets/join/action"]
+    }
+  }
+}
+
+resource "azurerm_private_dns_resolver" "this" {
+  count = local.use_resolver ? 1 : 0
+
+  name                = "dnspr-${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  virtual_network_id  = module.networking.vnet_id
+  tags                = var.tags
+}
+
+resource "azurerm_private_dns_resolver_inbound_endpoint" "this" {
+  count = local.use_resolver ? 1 : 0
+
+  name                    = "in-${var.name_suffix}"
+  private_dns_resolver_id = azurerm_private_dns_resolver.this[0].id
+  location                = module.resource_group.location
+  tags                    = var.tags
+
+  ip_configurations {
+    private_ip_allocation_method = "Dynamic"
+    subnet_id                    = azurerm_subnet.dns_inbound[0].id
+  }
+}
+
+# Set outside the networking module because the resolver lives in the VNet
+resource "azurerm_virtual_network_dns_servers" "this" {
+  count = local.use_resolver ? 1 : 0
+
+  virtual_network_id = module.networking.vnet_id
+  dns_servers        = [azurerm_private_dns_resolver_inbound_endpoint.this[0].ip_configurations[0].private_ip_address]
+}
+
+#------------------------------------------------------------------------------
+# Registry and Container App
+#------------------------------------------------------------------------------
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                = "acrdns${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.container_image == "" ? 0 : 1
+
+  name                       = "ca-dns-${var.name_suffix}"
+  environment_name           = "cae-dns-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.this.id
+
+  # External ingress keeps the fixture endpoint reachable from the test runner
+  infrastructure_subnet_id       = module.networking.container_app_subnet_id
+  internal_load_balancer_enabled = false
+
+  container_image = var.container_image
+  min_replicas    = 1
+  max_replicas    = 1
+
+  registry_server       = module.container_registry.login_server
+  registry_auth_mode    = "system_identity"
+  enable_acr_pull       = true
+  container_registry_id = module.container_registry.id
+
+  tags = var.tags
+
+  # Replicas read the VNet DNS settings when they start
+  depends_on = [
+    azurerm_private_dns_zone_virtual_network_link.this,
+    azurerm_private_dns_a_record.probe,
+    azurerm_virtual_network_dns_servers.this,
+  ]
+}
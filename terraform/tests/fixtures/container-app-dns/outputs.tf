@@ -0,0 +1,29 @@
+# Container App DNS Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "registry_login_server" {
+  value = module.container_registry.login_server
+}
+
+output "container_app_name" {
+  value = try(module.container_app[0].name, "")
+}
+
+output "application_url" {
+  value = try(module.container_app[0].application_url, "")
+}
+
+output "probe_record_fqdn" {
+  value = "${azurerm_private_dns_a_record.probe.name}.${azurerm_private_dns_zone.this.name}"
+}
+
+output "probe_record_ip" {
+  value = var.probe_record_ip
+}
+
+output "vnet_dns_servers" {
+  value = try(azurerm_virtual_network_dns_servers.this[0].dns_servers, [])
+}
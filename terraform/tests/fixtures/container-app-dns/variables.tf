@@ -0,0 +1,53 @@
+# Container App DNS Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "dns_mode" {
+  description = "VNet DNS configuration: azure_provided or private_resolver (custom DNS servers)"
+  type        = string
+
+  validation {
+    condition     = contains(["azure_provided", "private_resolver"], var.dns_mode)
+    error_message = "dns_mode must be azure_provided or private_resolver."
+  }
+}
+
+variable "private_zone_name" {
+  description = "Private DNS zone linked to the VNet"
+  type        = string
+  default     = "fixture.internal"
+}
+
+variable "probe_record_ip" {
+  description = "Address of the probe A record in the private zone"
+  type        = string
+  default     = "10.0.1.10"
+}
+
+# The app is deployed in a second apply, once the test has published the
+# echo fixture image to the registry created by the first one
+variable "container_image" {
+  description = "Echo fixture image to run; empty skips the container app"
+  type        = string
+  default     = ""
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
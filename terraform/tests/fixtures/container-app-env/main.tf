@@ -0,0 +1,73 @@
+# Container App Environment Variables Fixture
+# Deploys the echo fixture app as a single replica with the environment
+# variables under test, so tests can read their values back from inside the
+# running container. With files_dir set, the app writes files to that folder
+# of the container's own filesystem, not a volume, so tests can check the
+# files are gone once the revision restarts.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+# Tests pass the run's shared workspace (helpers.AcquireSharedObservability);
+# without one the fixture creates its own
+resource "azurerm_log_analytics_workspace" "this" {
+  count = var.log_analytics_workspace_id == "" ? 1 : 0
+
+  name                = "log-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+locals {
+  log_analytics_workspace_id = var.log_analytics_workspace_id == "" ? azurerm_log_analytics_workspace.this[0].id : var.log_analytics_workspace_id
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                = "acrenv${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.container_image == "" ? 0 : 1
+
+  name                       = "ca-env-${var.name_suffix}"
+  environment_name           = "cae-env-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = local.log_analytics_workspace_id
+
+  container_image  = var.container_image
+  container_cpu    = var.container_cpu
+  container_memory = var.container_memory
+  # One replica, so every request reaches the replica that wrote a file
+  # until a restart replaces it
+  min_replicas = 1
+  max_replicas = 1
+
+  environment_variables = merge(
+    var.environment_variables,
+    var.files_dir == null ? {} : { FILES_DIR = var.files_dir }
+  )
+
+  registry_server       = module.container_registry.login_server
+  registry_auth_mode    = "system_identity"
+  enable_acr_pull       = true
+  container_registry_id = module.container_registry.id
+
+  tags = var.tags
+}
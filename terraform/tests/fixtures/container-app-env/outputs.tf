@@ -0,0 +1,17 @@
+# Container App Environment Variables Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "registry_login_server" {
+  value = module.container_registry.login_server
+}
+
+output "application_url" {
+  value = try(module.container_app[0].application_url, "")
+}
+
+output "container_app_name" {
+  value = try(module.container_app[0].name, "")
+}
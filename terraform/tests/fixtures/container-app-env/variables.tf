@@ -0,0 +1,65 @@
+# Container App Environment Variables Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "log_analytics_workspace_id" {
+  description = "ID of an existing Log Analytics workspace for the app's logs; empty creates one"
+  type        = string
+  default     = ""
+}
+
+# The app is deployed in a second apply, once the test has published the
+# echo fixture image to the registry created by the first one
+variable "container_image" {
+  description = "Echo fixture image to run; empty skips the container app"
+  type        = string
+  default     = ""
+}
+
+# Pass values through a JSON var file: -var arguments are parsed as HCL, so
+# quotes, newlines and template sequences would not arrive unchanged
+variable "environment_variables" {
+  description = "Plain environment variables of the app's container"
+  type        = map(string)
+  default     = {}
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
+
+# Ephemeral storage is not configurable: Container Apps sizes it from the
+# container's CPU
+variable "container_cpu" {
+  description = "CPU of the app's container"
+  type        = number
+  default     = 0.5
+}
+
+variable "container_memory" {
+  description = "Memory of the app's container, paired with container_cpu"
+  type        = string
+  default     = "1Gi"
+}
+
+variable "files_dir" {
+  description = "Folder of the container's filesystem the app writes files to (FILES_DIR); null leaves it unset"
+  type        = string
+  default     = null
+}
@@ -0,0 +1,59 @@
+# Container App Ingress Fixture
+# Deploys the echo fixture app on two replicas with the ingress settings under
+# test, so tests can check session affinity across replicas and how the
+# ingress handles responses slower than its request timeout.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                = "acring${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.container_image == "" ? 0 : 1
+
+  name                       = "ca-ing-${var.name_suffix}"
+  environment_name           = "cae-ing-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.this.id
+
+  container_image = var.container_image
+  # Two replicas, so affinity has a choice to make; the HTTP scale rule is
+  # off so slow requests held open don't add replicas
+  min_replicas            = 2
+  max_replicas            = 2
+  http_scale_rule_enabled = false
+
+  ingress_sticky_sessions_enabled = var.sticky_sessions_enabled
+
+  registry_server       = module.container_registry.login_server
+  registry_auth_mode    = "system_identity"
+  enable_acr_pull       = true
+  container_registry_id = module.container_registry.id
+
+  tags = var.tags
+}
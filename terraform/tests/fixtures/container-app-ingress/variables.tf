@@ -0,0 +1,37 @@
+# Container App Ingress Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+# The app is deployed in a second apply, once the test has published the
+# echo fixture image to the registry created by the first one
+variable "container_image" {
+  description = "Echo fixture image to run; empty skips the container app"
+  type        = string
+  default     = ""
+}
+
+variable "sticky_sessions_enabled" {
+  description = "Whether ingress pins clients to a replica"
+  type        = bool
+  default     = true
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,151 @@
+# Container App NFS Fixture
+# Deploys the echo fixture app in a VNet-integrated environment with an NFS
+# Azure Files share (Premium FileStorage) mounted through the container-app
+# module's nfs_volumes. The share is reached over a private endpoint, and two
+# replicas share it, so tests can write a file through one and read it back
+# through the other.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+module "networking" {
+  source = "../../../modules/networking"
+
+  vnet_name           = "vnet-nfs-${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  tags                = var.tags
+}
+
+#------------------------------------------------------------------------------
+# Premium NFS file share, reachable from the VNet over a private endpoint
+#------------------------------------------------------------------------------
+
+# NFS does not use TLS, so secure transfer must be off; mounts are still only
+# accepted from the private endpoint, as NFS refuses public network clients
+resource "azurerm_storage_account" "nfs" {
+  name                       = "stnfs${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  account_kind               = "FileStorage"
+  account_tier               = "Premium"
+  account_replication_type   = "LRS"
+  https_traffic_only_enabled = false
+  min_tls_version            = "TLS1_2"
+  tags                       = var.tags
+}
+
+# 100 GiB is the smallest Premium share
+resource "azurerm_storage_share" "nfs" {
+  name               = "data"
+  storage_account_id = azurerm_storage_account.nfs.id
+  enabled_protocol   = "NFS"
+  quota              = 100
+}
+
+resource "azurerm_private_dns_zone" "file" {
+  name                = "privatelink.file.core.windows.net"
+  resource_group_name = module.resource_group.name
+  tags                = var.tags
+}
+
+resource "azurerm_private_dns_zone_virtual_network_link" "file" {
+  name                  = "link-file-${var.name_suffix}"
+  resource_group_name   = module.resource_group.name
+  private_dns_zone_name = azurerm_private_dns_zone.file.name
+  virtual_network_id    = module.networking.vnet_id
+  registration_enabled  = false
+  tags                  = var.tags
+}
+
+resource "azurerm_private_endpoint" "file" {
+  name                = "pe-file-${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  subnet_id           = module.networking.private_endpoint_subnet_id
+  tags                = var.tags
+
+  private_service_connection {
+    name                           = "psc-file-${var.name_suffix}"
+    private_connection_resource_id = azurerm_storage_account.nfs.id
+    subresource_names              = ["file"]
+    is_manual_connection           = false
+  }
+
+  private_dns_zone_group {
+    name                 = "file"
+    private_dns_zone_ids = [azurerm_private_dns_zone.file.id]
+  }
+}
+
+#------------------------------------------------------------------------------
+# Registry and Container App
+#------------------------------------------------------------------------------
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                = "acrnfs${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.container_image == "" ? 0 : 1
+
+  name                       = "ca-nfs-${var.name_suffix}"
+  environment_name           = "cae-nfs-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.this.id
+
+  # External ingress keeps the fixture endpoint reachable from the test runner
+  infrastructure_subnet_id       = module.networking.container_app_subnet_id
+  internal_load_balancer_enabled = false
+
+  container_image = var.container_image
+  # Two replicas, so a file written through one is read through the other
+  min_replicas = 2
+  max_replicas = 2
+
+  environment_variables = {
+    FILES_DIR = var.mount_path
+  }
+  nfs_volumes = [{
+    name       = "nfs-data"
+    server     = "${azurerm_storage_account.nfs.name}.file.core.windows.net"
+    share_name = "/${azurerm_storage_account.nfs.name}/${azurerm_storage_share.nfs.name}"
+    mount_path = var.mount_path
+  }]
+
+  registry_server       = module.container_registry.login_server
+  registry_auth_mode    = "system_identity"
+  enable_acr_pull       = true
+  container_registry_id = module.container_registry.id
+
+  tags = var.tags
+
+  # Replicas mount the share when they start, through the private endpoint
+  depends_on = [
+    azurerm_private_endpoint.file,
+    azurerm_private_dns_zone_virtual_network_link.file,
+  ]
+}
@@ -0,0 +1,21 @@
+# Container App NFS Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "registry_login_server" {
+  value = module.container_registry.login_server
+}
+
+output "storage_account_name" {
+  value = azurerm_storage_account.nfs.name
+}
+
+output "container_app_name" {
+  value = try(module.container_app[0].name, "")
+}
+
+output "application_url" {
+  value = try(module.container_app[0].application_url, "")
+}
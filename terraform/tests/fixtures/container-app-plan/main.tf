@@ -0,0 +1,32 @@
+# Container App Plan Fixture
+# Plans the container-app module with fixed names and placeholder IDs so
+# validation tests can check variable validations and preconditions without
+# deploying anything. Every input a test varies is a variable here.
+
+module "container_app" {
+  source = "../../../modules/container-app"
+
+  name                       = "ca-plan"
+  environment_name           = "cae-plan"
+  resource_group_name        = "rg-plan"
+  location                   = var.location
+  log_analytics_workspace_id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-plan/providers/Microsoft.OperationalInsights/workspaces/log-plan"
+
+  container_image    = "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest"
+  container_cpu      = var.container_cpu
+  container_memory   = var.container_memory
+  sidecar_containers = var.sidecar_containers
+
+  revision_mode                   = var.revision_mode
+  ingress_transport               = var.ingress_transport
+  ingress_sticky_sessions_enabled = var.ingress_sticky_sessions_enabled
+  allow_insecure_connections      = var.allow_insecure_connections
+
+  infrastructure_subnet_id = var.infrastructure_subnet_id
+  nfs_volumes              = var.nfs_volumes
+
+  secrets            = var.secrets
+  custom_scale_rules = var.custom_scale_rules
+
+  tags = var.tags
+}
@@ -0,0 +1,5 @@
+# Container App Plan Fixture - Outputs
+
+output "container_app_name" {
+  value = module.container_app.name
+}
@@ -0,0 +1,101 @@
+# Container App Plan Fixture - Variables
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "container_cpu" {
+  description = "CPU of the main container"
+  type        = number
+  default     = 0.5
+}
+
+variable "container_memory" {
+  description = "Memory of the main container"
+  type        = string
+  default     = "1Gi"
+}
+
+variable "sidecar_containers" {
+  description = "Sidecar containers passed to the module"
+  type = list(object({
+    name                  = string
+    image                 = string
+    cpu                   = number
+    memory                = string
+    command               = optional(list(string))
+    args                  = optional(list(string))
+    environment_variables = optional(map(string), {})
+  }))
+  default = []
+}
+
+variable "revision_mode" {
+  description = "Revision mode of the app"
+  type        = string
+  default     = "Single"
+}
+
+variable "ingress_transport" {
+  description = "Ingress transport protocol"
+  type        = string
+  default     = "http"
+}
+
+variable "ingress_sticky_sessions_enabled" {
+  description = "Whether ingress pins clients to a replica"
+  type        = bool
+  default     = false
+}
+
+variable "allow_insecure_connections" {
+  description = "Whether ingress also serves plain HTTP"
+  type        = bool
+  default     = false
+}
+
+variable "infrastructure_subnet_id" {
+  description = "Subnet the environment is integrated with; null for the Azure-managed network"
+  type        = string
+  default     = null
+}
+
+variable "nfs_volumes" {
+  description = "NFS Azure Files volumes passed to the module"
+  type = list(object({
+    name        = string
+    server      = string
+    share_name  = string
+    mount_path  = string
+    access_mode = optional(string, "ReadWrite")
+  }))
+  default = []
+}
+
+variable "secrets" {
+  description = "App secrets passed to the module; placeholder values only"
+  type        = map(string)
+  default     = {}
+}
+
+variable "custom_scale_rules" {
+  description = "Custom scale rules passed to the module"
+  type = list(object({
+    name     = string
+    type     = string
+    metadata = map(string)
+    authentication = optional(list(object({
+      secret_name       = string
+      trigger_parameter = string
+    })), [])
+  }))
+  default = []
+}
+
+variable "tags" {
+  description = "Tags passed to the module"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,43 @@
+# Container App Public Fixture
+# Deploys the smallest externally reachable Container App (Azure-managed
+# network, public ingress, hello-world image) for tests that probe ingress.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+
+  name                       = "ca-pub-${var.name_suffix}"
+  environment_name           = "cae-pub-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.this.id
+
+  container_image     = var.container_image
+  ingress_target_port = 80
+  min_replicas        = var.min_replicas
+  max_replicas        = 1
+
+  allow_insecure_connections = var.allow_insecure_connections
+
+  # The hello-world image has no /health or /ready endpoints
+  liveness_probe_enabled  = false
+  readiness_probe_enabled = false
+
+  tags = var.tags
+}
@@ -0,0 +1,29 @@
+# Container App Public Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "location" {
+  value = module.resource_group.location
+}
+
+output "container_app_name" {
+  value = module.container_app.name
+}
+
+output "ingress_fqdn" {
+  value = module.container_app.ingress_fqdn
+}
+
+output "application_url" {
+  value = module.container_app.application_url
+}
+
+output "container_image" {
+  value = var.container_image
+}
+
+output "outbound_ip_addresses" {
+  value = module.container_app.outbound_ip_addresses
+}
@@ -0,0 +1,42 @@
+# Container App Public Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "container_image" {
+  description = "Public image served by the app"
+  type        = string
+  default     = "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest"
+}
+
+# 0 lets the app scale to zero when idle
+variable "min_replicas" {
+  description = "Minimum number of replicas"
+  type        = number
+  default     = 1
+}
+
+variable "allow_insecure_connections" {
+  description = "Whether ingress also serves plain HTTP"
+  type        = bool
+  default     = false
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,80 @@
+# Container App Queue Scale Fixture
+# Deploys the hello-world app with a Storage Queue scale rule that
+# authenticates with the account's connection string, stored as an app
+# secret. The app never reads the queue, so its depth stays wherever the test
+# puts it and the replica count shows what the scaler sees.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+# Shared keys stay enabled: the scaler authenticates with a connection string
+resource "azurerm_storage_account" "queue" {
+  name                            = "stq${var.name_suffix}"
+  resource_group_name             = module.resource_group.name
+  location                        = module.resource_group.location
+  account_tier                    = "Standard"
+  account_replication_type        = "LRS"
+  min_tls_version                 = "TLS1_2"
+  allow_nested_items_to_be_public = false
+  tags                            = var.tags
+}
+
+resource "azurerm_storage_queue" "jobs" {
+  name               = "jobs"
+  storage_account_id = azurerm_storage_account.queue.id
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+
+  name                       = "ca-queue-${var.name_suffix}"
+  environment_name           = "cae-queue-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.this.id
+
+  container_image     = "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest"
+  ingress_target_port = 80
+
+  # Only the queue scales the app: nothing calls it over HTTP
+  min_replicas            = 0
+  max_replicas            = var.max_replicas
+  http_scale_rule_enabled = false
+
+  secrets = {
+    queue-connection = azurerm_storage_account.queue.primary_connection_string
+  }
+  custom_scale_rules = [{
+    name = "queue-depth"
+    type = "azure-queue"
+    metadata = {
+      queueName   = azurerm_storage_queue.jobs.name
+      queueLength = tostring(var.queue_length)
+      accountName = azurerm_storage_account.queue.name
+    }
+    authentication = [{
+      secret_name       = "queue-connection"
+      trigger_parameter = "connection"
+    }]
+  }]
+
+  # The hello-world image has no /health or /ready endpoints
+  liveness_probe_enabled  = false
+  readiness_probe_enabled = false
+
+  tags = var.tags
+}
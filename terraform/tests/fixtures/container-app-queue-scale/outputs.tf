@@ -0,0 +1,22 @@
+# Container App Queue Scale Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "container_app_name" {
+  value = module.container_app.name
+}
+
+output "container_app_id" {
+  value = module.container_app.id
+}
+
+output "queue_name" {
+  value = azurerm_storage_queue.jobs.name
+}
+
+output "queue_connection_string" {
+  value     = azurerm_storage_account.queue.primary_connection_string
+  sensitive = true
+}
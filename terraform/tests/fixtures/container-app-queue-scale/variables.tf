@@ -0,0 +1,35 @@
+# Container App Queue Scale Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "queue_length" {
+  description = "Queue messages per replica the scale rule targets"
+  type        = number
+  default     = 5
+}
+
+variable "max_replicas" {
+  description = "Maximum number of replicas the queue can scale the app to"
+  type        = number
+  default     = 3
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,116 @@
+# Container App Registry Auth Fixture
+# Deploys a private registry holding a test image and a container app that
+# pulls it using one of the container-app module's registry auth modes.
+
+locals {
+  image_repository = "registry-auth/helloworld:test"
+  use_admin        = var.registry_auth_mode == "admin_credentials"
+  use_sp           = var.registry_auth_mode == "service_principal"
+  use_user_id      = var.registry_auth_mode == "user_identity"
+}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                = "acrauth${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  admin_enabled       = local.use_admin
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+# Import the test image so the app has something private to pull
+resource "terraform_data" "image" {
+  triggers_replace = [module.container_registry.id, var.source_image]
+
+  provisioner "local-exec" {
+    command = "az acr import --name ${module.container_registry.name} --source ${var.source_image} --image ${local.image_repository} --force --only-show-errors"
+  }
+}
+
+#------------------------------------------------------------------------------
+# Pull identities (only the one matching registry_auth_mode is created)
+#------------------------------------------------------------------------------
+
+resource "azurerm_user_assigned_identity" "pull" {
+  count = local.use_user_id ? 1 : 0
+
+  name                = "id-pull-${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  tags                = var.tags
+}
+
+resource "azurerm_role_assignment" "user_identity_pull" {
+  count = local.use_user_id ? 1 : 0
+
+  scope                = module.container_registry.id
+  role_definition_name = "AcrPull"
+  principal_id         = azurerm_user_assigned_identity.pull[0].principal_id
+}
+
+resource "azurerm_role_assignment" "service_principal_pull" {
+  count = local.use_sp ? 1 : 0
+
+  scope                = module.container_registry.id
+  role_definition_name = "AcrPull"
+  principal_id         = var.service_principal_object_id
+}
+
+#------------------------------------------------------------------------------
+# Container App
+#------------------------------------------------------------------------------
+
+module "container_app" {
+  source = "../../../modules/container-app"
+
+  name                       = "ca-auth-${var.name_suffix}"
+  environment_name           = "cae-auth-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.this.id
+
+  container_image     = "${module.container_registry.login_server}/${local.image_repository}"
+  ingress_target_port = 80
+  min_replicas        = 1
+  max_replicas        = 1
+
+  # The hello-world image has no /health or /ready endpoints
+  liveness_probe_enabled  = false
+  readiness_probe_enabled = false
+
+  registry_server       = module.container_registry.login_server
+  registry_auth_mode    = var.registry_auth_mode
+  registry_identity_id  = local.use_user_id ? azurerm_user_assigned_identity.pull[0].id : null
+  registry_username     = local.use_admin ? module.container_registry.admin_username : (local.use_sp ? var.service_principal_client_id : null)
+  registry_password     = local.use_admin ? module.container_registry.admin_password : (local.use_sp ? var.service_principal_secret : null)
+  enable_acr_pull       = var.registry_auth_mode == "system_identity"
+  container_registry_id = module.container_registry.id
+
+  tags = var.tags
+
+  depends_on = [
+    terraform_data.image,
+    azurerm_role_assignment.user_identity_pull,
+    azurerm_role_assignment.service_principal_pull,
+  ]
+}
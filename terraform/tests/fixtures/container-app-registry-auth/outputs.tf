@@ -0,0 +1,17 @@
+# Container App Registry Auth Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "container_app_name" {
+  value = module.container_app.name
+}
+
+output "registry_login_server" {
+  value = module.container_registry.login_server
+}
+
+output "registry_password_secret_name" {
+  value = "registry-password"
+}
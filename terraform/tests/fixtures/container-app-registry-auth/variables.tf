@@ -0,0 +1,55 @@
+# Container App Registry Auth Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "registry_auth_mode" {
+  description = "Registry auth mode passed to the container-app module"
+  type        = string
+}
+
+variable "source_image" {
+  description = "Public image imported into the test registry and run by the app"
+  type        = string
+  default     = "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest"
+}
+
+# Service principal credentials are supplied as TF_VAR_* environment
+# variables by the test so they never reach tfvars files or command lines
+variable "service_principal_client_id" {
+  description = "Client ID of the pull service principal (service_principal mode)"
+  type        = string
+  default     = ""
+}
+
+variable "service_principal_object_id" {
+  description = "Object ID of the pull service principal (service_principal mode)"
+  type        = string
+  default     = ""
+}
+
+variable "service_principal_secret" {
+  description = "Client secret of the pull service principal (service_principal mode)"
+  type        = string
+  default     = ""
+  sensitive   = true
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,109 @@
+# Egress Firewall Fixture
+# Deploys the networking module with its egress firewall and the module's
+# default allow-list, and the echo fixture app in a workload profiles
+# environment on the firewalled subnet. The app pulls from ACR, reads Key
+# Vault and sends telemetry to Application Insights through the firewall, so
+# tests can check the allow-list is sufficient, and probe other hosts to check
+# it is tight.
+
+data "azurerm_client_config" "current" {}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  log_analytics_name  = "log-egr-${var.name_suffix}"
+  app_insights_name   = "appi-egr-${var.name_suffix}"
+  tags                = var.tags
+}
+
+module "networking" {
+  source = "../../../modules/networking"
+
+  vnet_name               = "vnet-egr-${var.name_suffix}"
+  resource_group_name     = module.resource_group.name
+  location                = module.resource_group.location
+  egress_firewall_enabled = true
+  tags                    = var.tags
+}
+
+# The app's public ingress is not behind the firewall: replies to the runner
+# must leave directly, or the firewall drops them as asymmetric traffic
+resource "azurerm_route" "runner" {
+  name                = "runner-direct"
+  resource_group_name = module.resource_group.name
+  route_table_name    = module.networking.egress_route_table_name
+  address_prefix      = "${var.runner_ip}/32"
+  next_hop_type       = "Internet"
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                = "acregr${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+# The probe secret is written by the test
+module "key_vault" {
+  source = "../../../modules/key-vault"
+
+  name                       = "kv-egr-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  soft_delete_retention_days = 7
+  purge_protection_enabled   = false
+  enable_diagnostics         = false
+  deployer_object_id         = data.azurerm_client_config.current.object_id
+  tags                       = var.tags
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.container_image == "" ? 0 : 1
+
+  name                       = "ca-egr-${var.name_suffix}"
+  environment_name           = "cae-egr-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = module.observability.log_analytics_workspace_id
+
+  # Route tables only apply to workload profiles environments
+  infrastructure_subnet_id       = module.networking.container_app_subnet_id
+  internal_load_balancer_enabled = false
+  workload_profiles_enabled      = true
+
+  container_image = var.container_image
+  min_replicas    = 1
+  max_replicas    = 1
+
+  environment_variables = {
+    APPLICATIONINSIGHTS_CONNECTION_STRING = module.observability.app_insights_connection_string
+  }
+
+  registry_server       = module.container_registry.login_server
+  registry_auth_mode    = "system_identity"
+  enable_acr_pull       = true
+  container_registry_id = module.container_registry.id
+
+  enable_key_vault_access = true
+  key_vault_id            = module.key_vault.id
+
+  tags = var.tags
+
+  # The first image pull must already go through the firewall
+  depends_on = [module.networking, azurerm_route.runner]
+}
@@ -0,0 +1,21 @@
+# Egress Firewall Fixture - Outputs
+
+output "registry_login_server" {
+  value = module.container_registry.login_server
+}
+
+output "key_vault_name" {
+  value = module.key_vault.name
+}
+
+output "vault_uri" {
+  value = module.key_vault.vault_uri
+}
+
+output "application_url" {
+  value = try(module.container_app[0].application_url, "")
+}
+
+output "egress_firewall_public_ip" {
+  value = module.networking.egress_firewall_public_ip
+}
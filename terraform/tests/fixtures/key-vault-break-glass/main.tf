@@ -0,0 +1,33 @@
+# Key Vault Break-Glass Fixture
+# A firewalled, RBAC-only vault for TestKeyVaultBreakGlass: default Deny, no
+# IP rules and no bypass, so the only way in from outside is the break-glass
+# procedure's role assignment and firewall exception. The firewall starts
+# open so the test can write the secret, then is closed by a second apply.
+
+data "azurerm_client_config" "current" {}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+# Secrets are written by the test, not terraform: with the firewall closed,
+# terraform could no longer refresh them from the runner
+module "key_vault" {
+  source = "../../../modules/key-vault"
+
+  name                        = "kv-bg-${var.name_suffix}"
+  resource_group_name         = module.resource_group.name
+  location                    = module.resource_group.location
+  soft_delete_retention_days  = 7
+  purge_protection_enabled    = false
+  enable_diagnostics          = false
+  network_acls_enabled        = true
+  network_acls_bypass         = "None"
+  network_acls_default_action = var.firewall_default_action
+  deployer_object_id          = data.azurerm_client_config.current.object_id
+  tags                        = var.tags
+}
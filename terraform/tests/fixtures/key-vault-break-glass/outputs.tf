@@ -0,0 +1,13 @@
+# Key Vault Break-Glass Fixture - Outputs
+
+output "key_vault_id" {
+  value = module.key_vault.id
+}
+
+output "key_vault_name" {
+  value = module.key_vault.name
+}
+
+output "vault_uri" {
+  value = module.key_vault.vault_uri
+}
@@ -0,0 +1,78 @@
+# Key Vault Bypass Fixture
+# Reproduces the dev environment's vault firewall (default Deny, bypass
+# AzureServices, no IP rules) with the echo fixture app reading from it with
+# its system-assigned identity and Key Vault Secrets User, so the test can
+# check whether the trusted-service bypass lets the app through.
+
+data "azurerm_client_config" "current" {}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                = "acrkvb${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+# Secrets are written by the test, not terraform: with the firewall closed,
+# terraform could no longer refresh them from the runner
+module "key_vault" {
+  source = "../../../modules/key-vault"
+
+  name                        = "kv-kvb-${var.name_suffix}"
+  resource_group_name         = module.resource_group.name
+  location                    = module.resource_group.location
+  soft_delete_retention_days  = 7
+  purge_protection_enabled    = false
+  enable_diagnostics          = false
+  network_acls_enabled        = true
+  network_acls_bypass         = "AzureServices"
+  network_acls_default_action = var.firewall_default_action
+  deployer_object_id          = data.azurerm_client_config.current.object_id
+  tags                        = var.tags
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.container_image == "" ? 0 : 1
+
+  name                       = "ca-kvb-${var.name_suffix}"
+  environment_name           = "cae-kvb-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.this.id
+
+  container_image = var.container_image
+  min_replicas    = 1
+  max_replicas    = 1
+
+  registry_server       = module.container_registry.login_server
+  registry_auth_mode    = "system_identity"
+  enable_acr_pull       = true
+  container_registry_id = module.container_registry.id
+
+  enable_key_vault_access = true
+  key_vault_id            = module.key_vault.id
+
+  tags = var.tags
+}
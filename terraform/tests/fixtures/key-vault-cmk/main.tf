@@ -0,0 +1,144 @@
+# Key Vault CMK Consumers Fixture
+# Composes the key-vault module with the services that encrypt their data
+# with a customer-managed key (CMK) from it:
+# - Container Registry (Premium) using a user-assigned identity
+# - Log Analytics dedicated cluster using its system-assigned identity (opt-in)
+
+data "azurerm_client_config" "current" {}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  log_analytics_name  = var.log_analytics_name
+  app_insights_name   = var.app_insights_name
+  tags                = var.tags
+}
+
+# CMKs require soft delete and purge protection on the vault
+module "key_vault" {
+  source = "../../../modules/key-vault"
+
+  name                       = var.key_vault_name
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  soft_delete_retention_days = 7
+  purge_protection_enabled   = true
+  enable_diagnostics         = false
+  deployer_object_id         = data.azurerm_client_config.current.object_id
+  tags                       = var.tags
+}
+
+#------------------------------------------------------------------------------
+# Container Registry (Premium) with CMK
+#------------------------------------------------------------------------------
+
+resource "azurerm_key_vault_key" "acr" {
+  name         = "cmk-acr"
+  key_vault_id = module.key_vault.id
+  key_type     = "RSA"
+  key_size     = 2048
+  key_opts     = ["wrapKey", "unwrapKey"]
+}
+
+# ACR only supports user-assigned identities for CMK at creation time
+resource "azurerm_user_assigned_identity" "acr" {
+  name                = "id-${var.acr_name}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  tags                = var.tags
+}
+
+# Key-scoped role: grants get/wrapKey/unwrapKey on this key only
+resource "azurerm_role_assignment" "acr_crypto" {
+  scope                = azurerm_key_vault_key.acr.resource_versionless_id
+  role_definition_name = "Key Vault Crypto Service Encryption User"
+  principal_id         = azurerm_user_assigned_identity.acr.principal_id
+}
+
+resource "azurerm_container_registry" "cmk" {
+  name                = var.acr_name
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Premium"
+  admin_enabled       = false
+
+  identity {
+    type         = "UserAssigned"
+    identity_ids = [azurerm_user_assigned_identity.acr.id]
+  }
+
+  encryption {
+    key_vault_key_id   = azurerm_key_vault_key.acr.versionless_id
+    identity_client_id = azurerm_user_assigned_identity.acr.client_id
+  }
+
+  tags = var.tags
+
+  depends_on = [azurerm_role_assignment.acr_crypto]
+}
+
+#------------------------------------------------------------------------------
+# Log Analytics dedicated cluster with CMK (opt-in)
+#------------------------------------------------------------------------------
+
+resource "azurerm_key_vault_key" "log_analytics" {
+  count = var.enable_log_analytics_cmk ? 1 : 0
+
+  name         = "cmk-log-analytics"
+  key_vault_id = module.key_vault.id
+  key_type     = "RSA"
+  key_size     = 2048
+  key_opts     = ["wrapKey", "unwrapKey"]
+}
+
+resource "azurerm_log_analytics_cluster" "cmk" {
+  count = var.enable_log_analytics_cmk ? 1 : 0
+
+  name                = var.log_analytics_cluster_name
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  size_gb             = 100
+
+  identity {
+    type = "SystemAssigned"
+  }
+
+  tags = var.tags
+}
+
+resource "azurerm_role_assignment" "log_analytics_crypto" {
+  count = var.enable_log_analytics_cmk ? 1 : 0
+
+  scope                = azurerm_key_vault_key.log_analytics[0].resource_versionless_id
+  role_definition_name = "Key Vault Crypto Service Encryption User"
+  principal_id         = azurerm_log_analytics_cluster.cmk[0].identity[0].principal_id
+}
+
+resource "azurerm_log_analytics_cluster_customer_managed_key" "cmk" {
+  count = var.enable_log_analytics_cmk ? 1 : 0
+
+  log_analytics_cluster_id = azurerm_log_analytics_cluster.cmk[0].id
+  key_vault_key_id         = azurerm_key_vault_key.log_analytics[0].versionless_id
+
+  depends_on = [azurerm_role_assignment.log_analytics_crypto]
+}
+
+resource "azurerm_log_analytics_linked_service" "cluster" {
+  count = var.enable_log_analytics_cmk ? 1 : 0
+
+  resource_group_name = module.resource_group.name
+  workspace_id        = module.observability.log_analytics_workspace_id
+  write_access_id     = azurerm_log_analytics_cluster.cmk[0].id
+
+  depends_on = [azurerm_log_analytics_cluster_customer_managed_key.cmk]
+}
@@ -0,0 +1,49 @@
+# Key Vault CMK Consumers Fixture - Outputs
+
+output "key_vault_id" {
+  value = module.key_vault.id
+}
+
+output "key_vault_name" {
+  value = module.key_vault.name
+}
+
+output "acr_id" {
+  value = azurerm_container_registry.cmk.id
+}
+
+output "acr_name" {
+  value = azurerm_container_registry.cmk.name
+}
+
+output "acr_key_id" {
+  value = azurerm_key_vault_key.acr.resource_versionless_id
+}
+
+output "acr_key_name" {
+  value = azurerm_key_vault_key.acr.name
+}
+
+output "acr_identity_principal_id" {
+  value = azurerm_user_assigned_identity.acr.principal_id
+}
+
+output "log_analytics_workspace_id" {
+  value = module.observability.log_analytics_workspace_id
+}
+
+output "log_analytics_cluster_id" {
+  value = var.enable_log_analytics_cmk ? azurerm_log_analytics_cluster.cmk[0].id : ""
+}
+
+output "log_analytics_cluster_principal_id" {
+  value = var.enable_log_analytics_cmk ? azurerm_log_analytics_cluster.cmk[0].identity[0].principal_id : ""
+}
+
+output "log_analytics_key_id" {
+  value = var.enable_log_analytics_cmk ? azurerm_key_vault_key.log_analytics[0].resource_versionless_id : ""
+}
+
+output "log_analytics_key_name" {
+  value = var.enable_log_analytics_cmk ? azurerm_key_vault_key.log_analytics[0].name : ""
+}
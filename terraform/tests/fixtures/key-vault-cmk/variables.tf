@@ -0,0 +1,53 @@
+# Key Vault CMK Consumers Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "key_vault_name" {
+  description = "Name of the Key Vault that holds the customer-managed keys"
+  type        = string
+}
+
+variable "acr_name" {
+  description = "Name of the Premium container registry encrypted with a CMK"
+  type        = string
+}
+
+variable "log_analytics_name" {
+  description = "Name of the Log Analytics workspace"
+  type        = string
+}
+
+variable "app_insights_name" {
+  description = "Name of the Application Insights component"
+  type        = string
+}
+
+# enable_log_analytics_cmk - Create a dedicated Log Analytics cluster
+# Clusters carry a 100 GB/day commitment tier and cannot be deleted for
+# 14 days, so they are opt-in even for integration runs
+variable "enable_log_analytics_cmk" {
+  description = "Create a Log Analytics dedicated cluster encrypted with a CMK and link the workspace to it"
+  type        = bool
+  default     = false
+}
+
+variable "log_analytics_cluster_name" {
+  description = "Name of the Log Analytics dedicated cluster (used when enable_log_analytics_cmk = true)"
+  type        = string
+  default     = ""
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
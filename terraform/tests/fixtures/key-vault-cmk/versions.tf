@@ -0,0 +1,19 @@
+terraform {
+  required_version = ">= 1.5.0"
+
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+  }
+}
+
+provider "azurerm" {
+  features {
+    key_vault {
+      # Test vaults use purge protection, so they cannot be purged on destroy
+      purge_soft_delete_on_destroy = false
+    }
+  }
+}
@@ -0,0 +1,34 @@
+# Key Vault Network Rollout Fixture
+# A vault with a secret and its firewall on, for TestKeyVaultNetworkRollout
+# to re-apply with a different default action while it reads the secret.
+# The runner's address is always in the IP rules, as any client that must
+# keep working through a firewall change would be, so terraform can refresh
+# the secret in every phase.
+
+data "azurerm_client_config" "current" {}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "key_vault" {
+  source = "../../../modules/key-vault"
+
+  name                        = "kv-net-${var.name_suffix}"
+  resource_group_name         = module.resource_group.name
+  location                    = module.resource_group.location
+  soft_delete_retention_days  = 7
+  purge_protection_enabled    = false
+  enable_diagnostics          = false
+  network_acls_enabled        = true
+  network_acls_bypass         = "AzureServices"
+  network_acls_default_action = var.firewall_default_action
+  allowed_ip_ranges           = ["${var.runner_ip}/32"]
+  deployer_object_id          = data.azurerm_client_config.current.object_id
+  secrets                     = { (var.secret_name) = var.name_suffix }
+  tags                        = var.tags
+}
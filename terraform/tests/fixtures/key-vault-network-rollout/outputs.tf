@@ -0,0 +1,13 @@
+# Key Vault Network Rollout Fixture - Outputs
+
+output "key_vault_name" {
+  value = module.key_vault.name
+}
+
+output "vault_uri" {
+  value = module.key_vault.vault_uri
+}
+
+output "secret_name" {
+  value = var.secret_name
+}
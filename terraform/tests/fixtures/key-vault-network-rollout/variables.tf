@@ -0,0 +1,40 @@
+# Key Vault Network Rollout Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "runner_ip" {
+  description = "Public IPv4 address of the test runner, allowed through the firewall"
+  type        = string
+}
+
+variable "firewall_default_action" {
+  description = "Default action of the vault firewall (Allow or Deny)"
+  type        = string
+  default     = "Allow"
+}
+
+variable "secret_name" {
+  description = "Name of the secret the test reads"
+  type        = string
+  default     = "probe"
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,29 @@
+# Key Vault Secrets Fixture
+# Creates a vault through the key-vault module with secrets and their
+# metadata passed straight through, so the test can change the metadata
+# alone and read the result back from the data plane.
+
+data "azurerm_client_config" "current" {}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "key_vault" {
+  source = "../../../modules/key-vault"
+
+  name                       = "kv-smd-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  soft_delete_retention_days = 7
+  purge_protection_enabled   = false
+  enable_diagnostics         = false
+  deployer_object_id         = data.azurerm_client_config.current.object_id
+  secrets                    = var.secrets
+  secret_metadata            = var.secret_metadata
+  tags                       = var.tags
+}
@@ -0,0 +1,13 @@
+# Key Vault Secrets Fixture - Outputs
+
+output "key_vault_name" {
+  value = module.key_vault.name
+}
+
+output "key_vault_id" {
+  value = module.key_vault.id
+}
+
+output "vault_uri" {
+  value = module.key_vault.vault_uri
+}
@@ -0,0 +1,40 @@
+# Key Vault Secrets Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "secrets" {
+  description = "Secrets passed to the key-vault module"
+  type        = map(string)
+  default     = {}
+}
+
+variable "secret_metadata" {
+  description = "Secret metadata passed to the key-vault module"
+  type = map(object({
+    content_type    = optional(string, "text/plain")
+    not_before_date = optional(string)
+    expiration_date = optional(string)
+    tags            = optional(map(string), {})
+  }))
+  default = {}
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,107 @@
+# Least-Privilege Fixture
+# Deploys one module into a resource group the test runner created. Terraform
+# runs as a test principal holding only the roles the module's README lists
+# under Required Permissions, so anything more the module needs fails the
+# apply. Optional features that need extra roles are switched on so their
+# documented roles are exercised too.
+
+data "azurerm_client_config" "current" {}
+
+data "azurerm_resource_group" "this" {
+  name = var.resource_group_name
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+  count  = var.module == "container-registry" ? 1 : 0
+
+  name                = "acrlp${var.name_suffix}"
+  resource_group_name = data.azurerm_resource_group.this.name
+  location            = data.azurerm_resource_group.this.location
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+module "key_vault" {
+  source = "../../../modules/key-vault"
+  count  = var.module == "key-vault" ? 1 : 0
+
+  name                       = "kv-lp-${var.name_suffix}"
+  resource_group_name        = data.azurerm_resource_group.this.name
+  location                   = data.azurerm_resource_group.this.location
+  soft_delete_retention_days = 7
+  purge_protection_enabled   = false
+  enable_diagnostics         = false
+  deployer_object_id         = data.azurerm_client_config.current.object_id
+  secrets                    = { "least-privilege" = var.name_suffix }
+  tags                       = var.tags
+}
+
+module "networking" {
+  source = "../../../modules/networking"
+  count  = var.module == "networking" ? 1 : 0
+
+  vnet_name           = "vnet-lp-${var.name_suffix}"
+  resource_group_name = data.azurerm_resource_group.this.name
+  location            = data.azurerm_resource_group.this.location
+  tags                = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+  count  = var.module == "observability" ? 1 : 0
+
+  resource_group_name = data.azurerm_resource_group.this.name
+  location            = data.azurerm_resource_group.this.location
+  log_analytics_name  = "log-lp-${var.name_suffix}"
+  app_insights_name   = "appi-lp-${var.name_suffix}"
+  tags                = var.tags
+}
+
+# The container app pulls a public image; the registry only gives the
+# AcrPull assignment a scope
+resource "azurerm_log_analytics_workspace" "container_app" {
+  count = var.module == "container-app" ? 1 : 0
+
+  name                = "log-lp-${var.name_suffix}"
+  location            = data.azurerm_resource_group.this.location
+  resource_group_name = data.azurerm_resource_group.this.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+resource "azurerm_container_registry" "container_app" {
+  count = var.module == "container-app" ? 1 : 0
+
+  name                = "acrlp${var.name_suffix}"
+  resource_group_name = data.azurerm_resource_group.this.name
+  location            = data.azurerm_resource_group.this.location
+  sku                 = "Basic"
+  tags                = var.tags
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.module == "container-app" ? 1 : 0
+
+  name                       = "ca-lp-${var.name_suffix}"
+  environment_name           = "cae-lp-${var.name_suffix}"
+  resource_group_name        = data.azurerm_resource_group.this.name
+  location                   = data.azurerm_resource_group.this.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.container_app[0].id
+
+  container_image     = "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest"
+  ingress_target_port = 80
+  min_replicas        = 0
+  max_replicas        = 1
+
+  # The hello-world image has no /health or /ready endpoints
+  liveness_probe_enabled  = false
+  readiness_probe_enabled = false
+
+  enable_acr_pull       = true
+  container_registry_id = azurerm_container_registry.container_app[0].id
+
+  tags = var.tags
+}
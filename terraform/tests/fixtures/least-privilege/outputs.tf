@@ -0,0 +1,6 @@
+# Least-Privilege Fixture - Outputs
+
+output "principal_object_id" {
+  description = "Object ID terraform ran as, to confirm the test principal was used"
+  value       = data.azurerm_client_config.current.object_id
+}
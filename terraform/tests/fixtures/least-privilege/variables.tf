@@ -0,0 +1,27 @@
+# Least-Privilege Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created by the test runner"
+  type        = string
+}
+
+variable "module" {
+  description = "Module to deploy (container-app, container-registry, key-vault, networking, observability)"
+  type        = string
+
+  validation {
+    condition     = contains(["container-app", "container-registry", "key-vault", "networking", "observability"], var.module)
+    error_message = "Module must be container-app, container-registry, key-vault, networking, or observability"
+  }
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,24 @@
+# Log Analytics Reuse Fixture
+# Deploys the observability module into a resource group the test keeps, so
+# the workspace can be soft-deleted (workspace_enabled = false with
+# permanently_delete_workspace = false) and then created again under the same
+# name, in the same or another region.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+  count  = var.workspace_enabled ? 1 : 0
+
+  resource_group_name = module.resource_group.name
+  location            = var.workspace_location == "" ? module.resource_group.location : var.workspace_location
+  log_analytics_name  = "log-reuse-${var.name_suffix}"
+  app_insights_name   = "appi-reuse-${var.name_suffix}"
+  tags                = var.tags
+}
@@ -0,0 +1,13 @@
+# Log Analytics Reuse Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "workspace_name" {
+  value = try(module.observability[0].log_analytics_workspace_name, null)
+}
+
+output "workspace_customer_id" {
+  value = try(module.observability[0].log_analytics_workspace_id_for_query, null)
+}
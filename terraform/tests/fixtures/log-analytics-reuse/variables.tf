@@ -0,0 +1,41 @@
+# Log Analytics Reuse Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region of the resource group"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "workspace_enabled" {
+  description = "Deploy the observability module; false deletes its workspace"
+  type        = bool
+  default     = true
+}
+
+variable "workspace_location" {
+  description = "Azure region of the workspace; empty uses the resource group's"
+  type        = string
+  default     = ""
+}
+
+variable "permanently_delete_workspace" {
+  description = "Delete the workspace permanently on destroy instead of soft-deleting it"
+  type        = bool
+  default     = true
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
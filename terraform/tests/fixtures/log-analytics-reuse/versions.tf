@@ -0,0 +1,19 @@
+terraform {
+  required_version = ">= 1.5.0"
+
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+  }
+}
+
+provider "azurerm" {
+  features {
+    log_analytics_workspace {
+      # false soft-deletes the workspace, as the root configuration does
+      permanently_delete_on_destroy = var.permanently_delete_workspace
+    }
+  }
+}
@@ -0,0 +1,18 @@
+# Log Ingestion Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "registry_login_server" {
+  value = module.container_registry.login_server
+}
+
+output "application_url" {
+  value = try(module.container_app[0].application_url, "")
+}
+
+# Workspace (customer) ID used by Log Analytics queries
+output "log_analytics_workspace_id" {
+  value = azurerm_log_analytics_workspace.this.workspace_id
+}
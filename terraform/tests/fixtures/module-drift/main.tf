@@ -0,0 +1,122 @@
+# Module Drift Fixture
+# Deploys one module, chosen by var.module, into a resource group of its own
+# for TestModuleDriftDetection to change outside terraform. The resource
+# group module is always deployed, so module = "resource-group" deploys it
+# alone. Modules that depend on others get the smallest dependencies they
+# need; drift_target_id names the resource of the chosen module the test
+# changes. Private endpoints go in the VNet given by vnet_id, the run's
+# shared VNet.
+
+data "azurerm_client_config" "current" {}
+
+locals {
+  deploy_registry   = contains(["container-registry", "private-endpoints"], var.module)
+  deploy_key_vault  = contains(["key-vault", "private-endpoints"], var.module)
+  deploy_networking = var.module == "networking"
+}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+  count  = local.deploy_registry ? 1 : 0
+
+  name                = "acrdrift${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  enable_diagnostics  = false
+  tags                = var.tags
+
+  # Private endpoints on a registry need the Premium SKU
+  sku = var.module == "private-endpoints" ? "Premium" : "Basic"
+}
+
+module "key_vault" {
+  source = "../../../modules/key-vault"
+  count  = local.deploy_key_vault ? 1 : 0
+
+  name                       = "kv-drift-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  soft_delete_retention_days = 7
+  purge_protection_enabled   = false
+  enable_diagnostics         = false
+  deployer_object_id         = data.azurerm_client_config.current.object_id
+  tags                       = var.tags
+}
+
+module "networking" {
+  source = "../../../modules/networking"
+  count  = local.deploy_networking ? 1 : 0
+
+  vnet_name           = "vnet-drift-${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  tags                = var.tags
+}
+
+module "private_endpoints" {
+  source = "../../../modules/private-endpoints"
+  count  = var.module == "private-endpoints" ? 1 : 0
+
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  environment                = "drift-${var.name_suffix}"
+  vnet_id                    = var.vnet_id
+  private_endpoint_subnet_id = var.private_endpoint_subnet_id
+  key_vault_id               = module.key_vault[0].id
+  container_registry_id      = module.container_registry[0].id
+  tags                       = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+  count  = var.module == "observability" ? 1 : 0
+
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  log_analytics_name  = "log-drift-${var.name_suffix}"
+  app_insights_name   = "appi-drift-${var.name_suffix}"
+  tags                = var.tags
+}
+
+# The container app needs a workspace for its environment; it pulls a public
+# image, so no registry
+resource "azurerm_log_analytics_workspace" "container_app" {
+  count = var.module == "container-app" ? 1 : 0
+
+  name                = "log-drift-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.module == "container-app" ? 1 : 0
+
+  name                       = "ca-drift-${var.name_suffix}"
+  environment_name           = "cae-drift-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.container_app[0].id
+
+  container_image     = "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest"
+  ingress_target_port = 80
+  min_replicas        = 0
+  max_replicas        = 1
+
+  # The hello-world image has no /health or /ready endpoints
+  liveness_probe_enabled  = false
+  readiness_probe_enabled = false
+
+  tags = var.tags
+}
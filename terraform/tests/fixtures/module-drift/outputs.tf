@@ -0,0 +1,14 @@
+# Module Drift Fixture - Outputs
+
+output "drift_target_id" {
+  description = "Resource ID of the resource of the chosen module the test changes outside terraform"
+  value = {
+    "container-app"      = one(module.container_app[*].id)
+    "container-registry" = one(module.container_registry[*].id)
+    "key-vault"          = one(module.key_vault[*].id)
+    "networking"         = one(module.networking[*].vnet_id)
+    "observability"      = one(module.observability[*].log_analytics_workspace_id)
+    "private-endpoints"  = one(module.private_endpoints[*].container_registry_private_endpoint_id)
+    "resource-group"     = module.resource_group.id
+  }[var.module]
+}
@@ -0,0 +1,45 @@
+# Module Drift Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the module"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "module" {
+  description = "Module to deploy (container-app, container-registry, key-vault, networking, observability, private-endpoints, resource-group)"
+  type        = string
+
+  validation {
+    condition     = contains(["container-app", "container-registry", "key-vault", "networking", "observability", "private-endpoints", "resource-group"], var.module)
+    error_message = "Module must be container-app, container-registry, key-vault, networking, observability, private-endpoints, or resource-group"
+  }
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "vnet_id" {
+  description = "VNet the private endpoints are put in (required for module = private-endpoints)"
+  type        = string
+  default     = null
+}
+
+variable "private_endpoint_subnet_id" {
+  description = "Subnet of vnet_id for the private endpoints (required for module = private-endpoints)"
+  type        = string
+  default     = null
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
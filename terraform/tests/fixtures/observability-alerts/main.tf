@@ -0,0 +1,46 @@
+# Observability Alerts Fixture
+# Creates the observability stack with its Resource Health alert scoped to
+# the fixture's resource group, plus app_count container apps in that group,
+# so the test can add an app and check the alert covers it unchanged.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+
+  resource_group_name  = module.resource_group.name
+  location             = module.resource_group.location
+  log_analytics_name   = "log-alr-${var.name_suffix}"
+  app_insights_name    = "appi-alr-${var.name_suffix}"
+  alert_scopes         = var.alert_scopes != null ? var.alert_scopes : [module.resource_group.id]
+  alert_resource_types = var.alert_resource_types
+  tags                 = var.tags
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.app_count
+
+  name                       = "ca-alr${count.index}-${var.name_suffix}"
+  environment_name           = "cae-alr${count.index}-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = module.observability.log_analytics_workspace_id
+
+  container_image     = "mcr.microsoft.com/azuredocs/containerapps-helloworld:latest"
+  ingress_target_port = 80
+  min_replicas        = 0
+  max_replicas        = 1
+
+  # The hello-world image has no /health or /ready endpoints
+  liveness_probe_enabled  = false
+  readiness_probe_enabled = false
+
+  tags = var.tags
+}
@@ -0,0 +1,13 @@
+# Observability Alerts Fixture - Outputs
+
+output "resource_group_id" {
+  value = module.resource_group.id
+}
+
+output "resource_health_alert_id" {
+  value = module.observability.resource_health_alert_id
+}
+
+output "container_app_ids" {
+  value = module.container_app[*].id
+}
@@ -0,0 +1,41 @@
+# Observability Alerts Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "alert_scopes" {
+  description = "Alert scopes passed to the observability module; null watches the fixture's resource group"
+  type        = list(string)
+  default     = null
+}
+
+variable "alert_resource_types" {
+  description = "Alert resource types passed to the observability module"
+  type        = list(string)
+  default     = ["Microsoft.App/containerApps"]
+}
+
+variable "app_count" {
+  description = "Number of container apps in the watched resource group"
+  type        = number
+  default     = 0
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,26 @@
+# Observability Availability Fixture
+# Deploys the observability stack with its availability test probing
+# health_check_url from test_locations, so the test can check that results
+# arrive from each probe location.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+
+  resource_group_name      = module.resource_group.name
+  location                 = module.resource_group.location
+  log_analytics_name       = "log-avl-${var.name_suffix}"
+  app_insights_name        = "appi-avl-${var.name_suffix}"
+  create_availability_test = true
+  health_check_url         = var.health_check_url
+  test_locations           = var.test_locations
+
+  tags = var.tags
+}
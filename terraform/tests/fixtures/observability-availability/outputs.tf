@@ -0,0 +1,11 @@
+# Observability Availability Fixture - Outputs
+
+output "availability_test_name" {
+  value = module.observability.availability_test_name
+}
+
+# Application Insights is workspace-based, so availability results are
+# stored in this workspace's AppAvailabilityResults table
+output "log_analytics_workspace_id" {
+  value = module.observability.log_analytics_workspace_id_for_query
+}
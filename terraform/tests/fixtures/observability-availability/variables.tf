@@ -0,0 +1,35 @@
+# Observability Availability Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "health_check_url" {
+  description = "URL the availability test probes; results are recorded whether or not it passes"
+  type        = string
+  default     = "https://azure.microsoft.com/"
+}
+
+variable "test_locations" {
+  description = "Availability test locations passed to the observability module"
+  type        = list(string)
+  default     = ["us-va-ash-azr", "emea-nl-ams-azr", "apac-sg-sin-azr"]
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,32 @@
+# Observability Ingestion Fixture
+# Deploys the observability stack alone with ingestion alerts enabled and a
+# small daily cap. The cap-breach test points the action group at the shared
+# webhook receiver and floods the workspace with synthetic traces.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+
+  resource_group_name          = module.resource_group.name
+  location                     = module.resource_group.location
+  log_analytics_name           = "log-ing-${var.name_suffix}"
+  app_insights_name            = "appi-ing-${var.name_suffix}"
+  log_analytics_daily_quota_gb = var.daily_quota_gb
+
+  # Sampled traces would be dropped before they count toward the cap
+  sampling_percentage = 100
+
+  ingestion_alerts_enabled          = true
+  ingestion_alert_threshold_percent = var.ingestion_alert_threshold_percent
+  ingestion_anomaly_factor          = var.ingestion_anomaly_factor
+  alert_webhook_receivers           = var.webhook_url == "" ? {} : { receiver = var.webhook_url }
+
+  tags = var.tags
+}
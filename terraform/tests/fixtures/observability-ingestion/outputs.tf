@@ -0,0 +1,34 @@
+# Observability Ingestion Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "log_analytics_workspace_id" {
+  value = module.observability.log_analytics_workspace_id
+}
+
+output "log_analytics_name" {
+  value = module.observability.log_analytics_workspace_name
+}
+
+output "app_insights_connection_string" {
+  value     = module.observability.app_insights_connection_string
+  sensitive = true
+}
+
+output "alert_action_group_id" {
+  value = module.observability.alert_action_group_id
+}
+
+output "daily_cap_alert_id" {
+  value = module.observability.daily_cap_alert_id
+}
+
+output "ingestion_threshold_alert_id" {
+  value = module.observability.ingestion_threshold_alert_id
+}
+
+output "ingestion_anomaly_alert_id" {
+  value = module.observability.ingestion_anomaly_alert_id
+}
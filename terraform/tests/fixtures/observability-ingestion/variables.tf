@@ -0,0 +1,47 @@
+# Observability Ingestion Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "daily_quota_gb" {
+  description = "Daily cap of the workspace in GB, or null for none. Defaults to the smallest cap Log Analytics allows"
+  type        = number
+  default     = 0.023
+}
+
+variable "ingestion_alert_threshold_percent" {
+  description = "Share of the daily cap at which the ingestion threshold alert fires"
+  type        = number
+  default     = 80
+}
+
+variable "ingestion_anomaly_factor" {
+  description = "Multiple of the average hourly ingestion at which the anomaly alert fires"
+  type        = number
+  default     = 3
+}
+
+variable "webhook_url" {
+  description = "HTTPS URL the alert action group posts to, or empty for none"
+  type        = string
+  default     = ""
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
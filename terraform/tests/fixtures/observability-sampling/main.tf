@@ -0,0 +1,24 @@
+# Observability Sampling Fixture
+# Deploys the observability stack alone with the sampling percentage under
+# test. The test sends synthetic requests straight to the Application
+# Insights ingestion endpoint and counts what reaches the workspace.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  log_analytics_name  = "log-smp-${var.name_suffix}"
+  app_insights_name   = "appi-smp-${var.name_suffix}"
+  sampling_percentage = var.sampling_percentage
+
+  tags = var.tags
+}
@@ -0,0 +1,12 @@
+# Observability Sampling Fixture - Outputs
+
+output "app_insights_connection_string" {
+  value     = module.observability.app_insights_connection_string
+  sensitive = true
+}
+
+# Application Insights is workspace-based, so ingested requests are counted
+# in this workspace's AppRequests table
+output "log_analytics_workspace_id" {
+  value = module.observability.log_analytics_workspace_id_for_query
+}
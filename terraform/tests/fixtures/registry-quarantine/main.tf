@@ -0,0 +1,39 @@
+# Registry Quarantine Fixture
+# Creates a Premium registry with the quarantine policy enabled through the
+# container-registry module, plus a registry token on the module's read-only
+# scope map. The token stands in for a regular consumer: it may pull, but not
+# quarantined images.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                      = "acrqt${var.name_suffix}"
+  resource_group_name       = module.resource_group.name
+  location                  = module.resource_group.location
+  sku                       = "Premium"
+  quarantine_policy_enabled = var.quarantine_policy_enabled
+  create_scope_maps         = true
+  enable_diagnostics        = false
+  tags                      = var.tags
+}
+
+resource "azurerm_container_registry_token" "consumer" {
+  name                    = "consumer"
+  container_registry_name = module.container_registry.name
+  resource_group_name     = module.resource_group.name
+  scope_map_id            = module.container_registry.pull_scope_map_id
+}
+
+resource "azurerm_container_registry_token_password" "consumer" {
+  container_registry_token_id = azurerm_container_registry_token.consumer.id
+
+  password1 {}
+}
@@ -0,0 +1,18 @@
+# Registry Quarantine Fixture - Outputs
+
+output "registry_name" {
+  value = module.container_registry.name
+}
+
+output "login_server" {
+  value = module.container_registry.login_server
+}
+
+output "consumer_token_name" {
+  value = azurerm_container_registry_token.consumer.name
+}
+
+output "consumer_token_password" {
+  value     = azurerm_container_registry_token_password.consumer.password1[0].value
+  sensitive = true
+}
@@ -0,0 +1,30 @@
+# Registry Supply Chain Fixture
+# Creates a registry through the container-registry module with the settings
+# that affect OCI artifacts (SKU, untagged manifest retention, content trust,
+# soft delete), so tests can push signed images, SBOMs and untagged
+# manifests, delete them and read back what the registry kept.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                 = "acrsc${var.name_suffix}"
+  resource_group_name  = module.resource_group.name
+  location             = module.resource_group.location
+  sku                  = var.sku
+  retention_enabled    = var.retention_enabled
+  retention_days       = var.retention_days
+  trust_policy_enabled = var.trust_policy_enabled
+  enable_diagnostics   = false
+  tags                 = var.tags
+
+  soft_delete_enabled        = var.soft_delete_enabled
+  soft_delete_retention_days = var.soft_delete_retention_days
+}
@@ -0,0 +1,9 @@
+# Registry Supply Chain Fixture - Outputs
+
+output "registry_name" {
+  value = module.container_registry.name
+}
+
+output "login_server" {
+  value = module.container_registry.login_server
+}
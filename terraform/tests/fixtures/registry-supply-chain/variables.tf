@@ -0,0 +1,59 @@
+# Registry Supply Chain Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "sku" {
+  description = "Registry SKU passed to the container-registry module"
+  type        = string
+  default     = "Basic"
+}
+
+variable "retention_enabled" {
+  description = "Enable the untagged manifest retention policy (Premium only)"
+  type        = bool
+  default     = false
+}
+
+variable "retention_days" {
+  description = "Days untagged manifests are kept when retention is enabled"
+  type        = number
+  default     = 7
+}
+
+variable "trust_policy_enabled" {
+  description = "Enable the content trust policy (Premium only)"
+  type        = bool
+  default     = false
+}
+
+variable "soft_delete_enabled" {
+  description = "Keep deleted tags and manifests restorable"
+  type        = bool
+  default     = false
+}
+
+variable "soft_delete_retention_days" {
+  description = "Days deleted tags and manifests stay restorable when soft delete is enabled"
+  type        = number
+  default     = 7
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
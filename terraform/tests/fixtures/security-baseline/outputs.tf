@@ -0,0 +1,17 @@
+# Security Baseline Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "container_app_url" {
+  value = module.container_app.application_url
+}
+
+output "key_vault_uri" {
+  value = module.key_vault.vault_uri
+}
+
+output "registry_login_server" {
+  value = module.container_registry.login_server
+}
@@ -0,0 +1,21 @@
+# Shared Observability Fixture
+# Deploys a resource group and Log Analytics workspace once per run, for the
+# fixtures that only need somewhere to send logs. helpers.AcquireSharedObservability
+# applies it for the first test that asks and TestMain destroys it.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-shared-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
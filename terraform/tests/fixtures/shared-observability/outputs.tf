@@ -0,0 +1,18 @@
+# Shared Observability Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "location" {
+  value = module.resource_group.location
+}
+
+output "log_analytics_workspace_id" {
+  value = azurerm_log_analytics_workspace.this.id
+}
+
+# The workspace (customer) ID log queries take
+output "log_analytics_workspace_customer_id" {
+  value = azurerm_log_analytics_workspace.this.workspace_id
+}
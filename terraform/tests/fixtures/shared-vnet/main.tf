@@ -0,0 +1,22 @@
+# Shared VNet Fixture
+# Deploys a resource group and the networking module's VNet for tests that
+# only need somewhere to put private endpoints. helpers.AcquireSharedVNet
+# applies it for the first test that asks and destroys it when the last test
+# holding it finishes.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "networking" {
+  source = "../../../modules/networking"
+
+  vnet_name           = "vnet-shared-${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  tags                = var.tags
+}
@@ -0,0 +1,21 @@
+# Shared VNet Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "location" {
+  value = module.resource_group.location
+}
+
+output "vnet_id" {
+  value = module.networking.vnet_id
+}
+
+output "vnet_name" {
+  value = module.networking.vnet_name
+}
+
+output "private_endpoint_subnet_id" {
+  value = module.networking.private_endpoint_subnet_id
+}
@@ -0,0 +1,23 @@
+# Shared VNet Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the VNet"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,114 @@
+# Suite Metrics Fixture
+# The Log Analytics custom table test results are exported to, the data
+# collection endpoint and rule `ttk report export` uploads through, and the
+# suite health workbook over the table. TestSuiteMetricsExport applies it for
+# a run of its own; a team applies it once, long-lived, and points
+# TEST_SUITE_METRICS_ENDPOINT and TEST_SUITE_METRICS_RULE_ID at its outputs.
+# The columns are in columns.json, which must match helpers.SuiteMetricsColumns.
+
+locals {
+  table_name = "TerraformTestResults_CL"
+  stream     = "Custom-TerraformTestResults"
+  columns    = jsondecode(file("${path.module}/columns.json"))
+}
+
+data "azurerm_client_config" "current" {}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-metrics-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+# azurerm cannot create custom tables, so the table is created with azapi
+resource "azapi_resource" "results_table" {
+  type      = "Microsoft.OperationalInsights/workspaces/tables@2022-10-01"
+  name      = local.table_name
+  parent_id = azurerm_log_analytics_workspace.this.id
+
+  body = jsonencode({
+    properties = {
+      plan                 = "Analytics"
+      retentionInDays      = var.retention_in_days
+      totalRetentionInDays = var.retention_in_days
+      schema = {
+        name    = local.table_name
+        columns = local.columns
+      }
+    }
+  })
+}
+
+resource "azurerm_monitor_data_collection_endpoint" "this" {
+  name                = "dce-metrics-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  tags                = var.tags
+}
+
+resource "azurerm_monitor_data_collection_rule" "this" {
+  name                        = "dcr-metrics-${var.name_suffix}"
+  location                    = module.resource_group.location
+  resource_group_name         = module.resource_group.name
+  data_collection_endpoint_id = azurerm_monitor_data_collection_endpoint.this.id
+  tags                        = var.tags
+
+  destinations {
+    log_analytics {
+      name                  = "workspace"
+      workspace_resource_id = azurerm_log_analytics_workspace.this.id
+    }
+  }
+
+  data_flow {
+    streams       = [local.stream]
+    destinations  = ["workspace"]
+    output_stream = "Custom-${local.table_name}"
+    transform_kql = "source"
+  }
+
+  stream_declaration {
+    stream_name = local.stream
+
+    dynamic "column" {
+      for_each = local.columns
+      content {
+        name = column.value.name
+        type = column.value.type
+      }
+    }
+  }
+
+  # The output stream only exists once the table does
+  depends_on = [azapi_resource.results_table]
+}
+
+# Uploads need Monitoring Metrics Publisher on the rule
+resource "azurerm_role_assignment" "publisher" {
+  for_each = toset(concat([data.azurerm_client_config.current.object_id], var.publisher_principal_ids))
+
+  scope                = azurerm_monitor_data_collection_rule.this.id
+  role_definition_name = "Monitoring Metrics Publisher"
+  principal_id         = each.value
+}
+
+resource "azurerm_application_insights_workbook" "suite_health" {
+  name                = uuidv5("url", "${var.resource_group_name}/suite-health")
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  display_name        = "Terraform test suite health (${var.name_suffix})"
+  source_id           = lower(azurerm_log_analytics_workspace.this.id)
+  data_json           = file("${path.module}/../../workbooks/suite-health.json")
+  tags                = var.tags
+}
@@ -0,0 +1,28 @@
+# Suite Metrics Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+# The workspace (customer) ID log queries take
+output "log_analytics_workspace_customer_id" {
+  value = azurerm_log_analytics_workspace.this.workspace_id
+}
+
+# What `ttk report export` takes as -endpoint
+output "logs_ingestion_endpoint" {
+  value = azurerm_monitor_data_collection_endpoint.this.logs_ingestion_endpoint
+}
+
+# What `ttk report export` takes as -rule
+output "data_collection_rule_immutable_id" {
+  value = azurerm_monitor_data_collection_rule.this.immutable_id
+}
+
+output "table_name" {
+  value = azapi_resource.results_table.name
+}
+
+output "workbook_id" {
+  value = azurerm_application_insights_workbook.suite_health.id
+}
@@ -0,0 +1,35 @@
+# Suite Metrics Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the workspace, endpoint and rule"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "retention_in_days" {
+  description = "How long test results are kept in the table"
+  type        = number
+  default     = 90
+}
+
+variable "publisher_principal_ids" {
+  description = "Object IDs allowed to upload results besides the deploying identity, e.g. the CI identity"
+  type        = list(string)
+  default     = []
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
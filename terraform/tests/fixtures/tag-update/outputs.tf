@@ -0,0 +1,5 @@
+# Tag Update Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
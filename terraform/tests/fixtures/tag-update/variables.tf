@@ -0,0 +1,23 @@
+# Tag Update Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "tags" {
+  description = "Tags passed to every module; the test changes only this"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,69 @@
+# Tracing Fixture
+# Deploys the observability stack and two echo fixture apps, a frontend and a
+# backend, that send their telemetry to the same Application Insights. Tests
+# call the frontend's /trace endpoint with the backend's as downstream, so one
+# W3C trace spans both apps.
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  log_analytics_name  = "log-trace-${var.name_suffix}"
+  app_insights_name   = "appi-trace-${var.name_suffix}"
+
+  # Every span must arrive for the trace to be complete
+  sampling_percentage = 100
+
+  tags = var.tags
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                = "acrtrace${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+locals {
+  tracing_apps = var.container_image == "" ? toset([]) : toset(["frontend", "backend"])
+}
+
+module "container_app" {
+  source   = "../../../modules/container-app"
+  for_each = local.tracing_apps
+
+  name                       = "ca-trace-${each.key}-${var.name_suffix}"
+  environment_name           = "cae-trace-${each.key}-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = module.observability.log_analytics_workspace_id
+
+  container_image = var.container_image
+  min_replicas    = 1
+  max_replicas    = 1
+
+  # Same wiring as the environments: the SDK setting, passed as a plain variable
+  environment_variables = {
+    APPLICATIONINSIGHTS_CONNECTION_STRING = module.observability.app_insights_connection_string
+  }
+
+  registry_server       = module.container_registry.login_server
+  registry_auth_mode    = "system_identity"
+  enable_acr_pull       = true
+  container_registry_id = module.container_registry.id
+
+  tags = var.tags
+}
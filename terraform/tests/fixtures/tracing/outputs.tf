@@ -0,0 +1,23 @@
+# Tracing Fixture - Outputs
+
+output "resource_group_name" {
+  value = module.resource_group.name
+}
+
+output "registry_login_server" {
+  value = module.container_registry.login_server
+}
+
+output "frontend_url" {
+  value = try(module.container_app["frontend"].application_url, "")
+}
+
+output "backend_url" {
+  value = try(module.container_app["backend"].application_url, "")
+}
+
+# Application Insights is workspace-based, so its requests and dependencies
+# are queried from this workspace's AppRequests and AppDependencies tables
+output "log_analytics_workspace_id" {
+  value = module.observability.log_analytics_workspace_id_for_query
+}
@@ -0,0 +1,99 @@
+# Webhook Receiver Fixture
+# The webhook fixture app behind public ingress, recording every delivery on
+# a storage queue with its system-assigned identity. It is deployed once per
+# run by helpers.SharedWebhookReceiver and shared by the tests that need a
+# budget alert, action group or ACR webhook to land somewhere.
+
+data "azurerm_client_config" "current" {}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.tags
+}
+
+resource "azurerm_log_analytics_workspace" "this" {
+  name                = "log-hook-${var.name_suffix}"
+  location            = module.resource_group.location
+  resource_group_name = module.resource_group.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+  tags                = var.tags
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+
+  name                = "acrhook${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+resource "azurerm_storage_account" "hooks" {
+  name                            = "sthook${var.name_suffix}"
+  resource_group_name             = module.resource_group.name
+  location                        = module.resource_group.location
+  account_tier                    = "Standard"
+  account_replication_type        = "LRS"
+  min_tls_version                 = "TLS1_2"
+  shared_access_key_enabled       = false
+  allow_nested_items_to_be_public = false
+  tags                            = var.tags
+}
+
+resource "azurerm_storage_queue" "hooks" {
+  name               = "webhooks"
+  storage_account_id = azurerm_storage_account.hooks.id
+}
+
+# The runner reads and deletes the recorded deliveries
+resource "azurerm_role_assignment" "runner" {
+  scope                = azurerm_storage_queue.hooks.resource_manager_id
+  role_definition_name = "Storage Queue Data Message Processor"
+  principal_id         = data.azurerm_client_config.current.object_id
+}
+
+module "container_app" {
+  source = "../../../modules/container-app"
+  count  = var.container_image == "" ? 0 : 1
+
+  name                       = "ca-hook-${var.name_suffix}"
+  environment_name           = "cae-hook-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.this.id
+
+  container_image = var.container_image
+  min_replicas    = 1
+  max_replicas    = 1
+
+  registry_server       = module.container_registry.login_server
+  registry_auth_mode    = "system_identity"
+  enable_acr_pull       = true
+  container_registry_id = module.container_registry.id
+
+  environment_variables = {
+    QUEUE_URL = "${azurerm_storage_account.hooks.primary_queue_endpoint}${azurerm_storage_queue.hooks.name}"
+  }
+  secrets = {
+    "webhook-token" = var.webhook_token
+  }
+  secret_environment_variables = {
+    WEBHOOK_TOKEN = "webhook-token"
+  }
+
+  tags = var.tags
+}
+
+resource "azurerm_role_assignment" "app" {
+  count = var.container_image == "" ? 0 : 1
+
+  scope                = azurerm_storage_queue.hooks.resource_manager_id
+  role_definition_name = "Storage Queue Data Message Sender"
+  principal_id         = module.container_app[0].identity_principal_id
+}
@@ -0,0 +1,17 @@
+# Webhook Receiver Fixture - Outputs
+
+output "registry_login_server" {
+  value = module.container_registry.login_server
+}
+
+output "application_url" {
+  value = try(module.container_app[0].application_url, "")
+}
+
+output "storage_account_name" {
+  value = azurerm_storage_account.hooks.name
+}
+
+output "queue_name" {
+  value = azurerm_storage_queue.hooks.name
+}
@@ -0,0 +1,37 @@
+# Webhook Receiver Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the run's receiver"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+# The app is deployed in a second apply, once the helper has published the
+# webhook fixture image to the registry created by the first one
+variable "container_image" {
+  description = "Webhook fixture image to run; empty skips the container app"
+  type        = string
+  default     = ""
+}
+
+variable "webhook_token" {
+  description = "Token senders must pass as the token query parameter"
+  type        = string
+  sensitive   = true
+}
+
+variable "tags" {
+  description = "Tags to apply to all fixture resources"
+  type        = map(string)
+  default     = {}
+}
@@ -0,0 +1,63 @@
+# What-If Fixture
+# Deploys one module into its own resource group so the test can export the
+# group's ARM template and compare what ARM What-If predicts for it with
+# what terraform plans. The resource group keeps its own tags: What-If on a
+# group deployment cannot see the group itself, so a tag change to it would
+# always look like a divergence.
+
+data "azurerm_client_config" "current" {}
+
+module "resource_group" {
+  source = "../../../modules/resource-group"
+
+  name     = var.resource_group_name
+  location = var.location
+  tags     = var.resource_group_tags
+}
+
+module "container_registry" {
+  source = "../../../modules/container-registry"
+  count  = var.module == "container-registry" ? 1 : 0
+
+  name                = "acrwi${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  sku                 = "Basic"
+  enable_diagnostics  = false
+  tags                = var.tags
+}
+
+module "key_vault" {
+  source = "../../../modules/key-vault"
+  count  = var.module == "key-vault" ? 1 : 0
+
+  name                       = "kv-wi-${var.name_suffix}"
+  resource_group_name        = module.resource_group.name
+  location                   = module.resource_group.location
+  soft_delete_retention_days = 7
+  purge_protection_enabled   = false
+  enable_diagnostics         = false
+  deployer_object_id         = data.azurerm_client_config.current.object_id
+  tags                       = var.tags
+}
+
+module "networking" {
+  source = "../../../modules/networking"
+  count  = var.module == "networking" ? 1 : 0
+
+  vnet_name           = "vnet-wi-${var.name_suffix}"
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  tags                = var.tags
+}
+
+module "observability" {
+  source = "../../../modules/observability"
+  count  = var.module == "observability" ? 1 : 0
+
+  resource_group_name = module.resource_group.name
+  location            = module.resource_group.location
+  log_analytics_name  = "log-wi-${var.name_suffix}"
+  app_insights_name   = "appi-wi-${var.name_suffix}"
+  tags                = var.tags
+}
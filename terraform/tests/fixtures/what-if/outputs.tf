@@ -0,0 +1,6 @@
+# What-If Fixture - Outputs
+
+output "resource_group_name" {
+  description = "Name of the resource group whose template is exported"
+  value       = module.resource_group.name
+}
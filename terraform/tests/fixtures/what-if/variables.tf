@@ -0,0 +1,39 @@
+# What-If Fixture - Variables
+
+variable "resource_group_name" {
+  description = "Name of the resource group created for the test"
+  type        = string
+}
+
+variable "location" {
+  description = "Azure region for all fixture resources"
+  type        = string
+  default     = "eastus2"
+}
+
+variable "module" {
+  description = "Module to deploy (container-registry, key-vault, networking, observability)"
+  type        = string
+
+  validation {
+    condition     = contains(["container-registry", "key-vault", "networking", "observability"], var.module)
+    error_message = "Module must be container-registry, key-vault, networking, or observability"
+  }
+}
+
+variable "name_suffix" {
+  description = "Unique suffix used for all resource names"
+  type        = string
+}
+
+variable "tags" {
+  description = "Tags passed to the module; the test changes only this"
+  type        = map(string)
+  default     = {}
+}
+
+variable "resource_group_tags" {
+  description = "Tags of the resource group, kept apart from the module's tags"
+  type        = map(string)
+  default     = {}
+}
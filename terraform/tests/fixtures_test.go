@@ -0,0 +1,17 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// TestFixturesContainNoSecrets scans test fixtures, module examples and
+// environment examples for committed credentials
+func TestFixturesContainNoSecrets(t *testing.T) {
+	t.Parallel()
+
+	for _, dir := range []string{"./fixtures", "../modules", "../environments"} {
+		helpers.AssertNoSecretsInDir(t, dir)
+	}
+}
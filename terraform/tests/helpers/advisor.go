@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// advisorCategories are the Advisor categories checked after apply
+var advisorCategories = []string{"Security", "Cost"}
+
+// AdvisorRecommendation is an Azure Advisor recommendation for one resource
+type AdvisorRecommendation struct {
+	Category             string `json:"category"`
+	Impact               string `json:"impact"`
+	ImpactedField        string `json:"impactedField"`
+	ImpactedValue        string `json:"impactedValue"`
+	RecommendationTypeID string `json:"recommendationTypeId"`
+	ShortDescription     struct {
+		Problem  string `json:"problem"`
+		Solution string `json:"solution"`
+	} `json:"shortDescription"`
+}
+
+// GetAdvisorRecommendationsE regenerates and lists the Advisor
+// recommendations of one category for the resources in a resource group
+func GetAdvisorRecommendationsE(t *testing.T, resourceGroupName, category string) ([]AdvisorRecommendation, error) {
+	var recommendations []AdvisorRecommendation
+	err := AzCLIJSONE(t, &recommendations, "advisor", "recommendation", "list",
+		"--resource-group", resourceGroupName, "--category", category, "--refresh")
+	return recommendations, err
+}
+
+// CheckAdvisorRecommendations turns high-impact Security and Cost
+// recommendations for a resource group into test feedback. It is opt-in:
+// TEST_ADVISOR=fail fails the test, TEST_ADVISOR=report only logs them, and
+// anything else skips the check. Recommendation type IDs listed in
+// TEST_ADVISOR_IGNORE (comma-separated) are accepted findings. Findings are
+// recorded in the advisor report either way
+func CheckAdvisorRecommendations(t *testing.T, resourceGroupName string) {
+	mode := os.Getenv("TEST_ADVISOR")
+	if mode != "fail" && mode != "report" {
+		return
+	}
+
+	ignored := map[string]bool{}
+	for _, id := range strings.Split(os.Getenv("TEST_ADVISOR_IGNORE"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ignored[strings.ToLower(id)] = true
+		}
+	}
+
+	var findings []AdvisorRecommendation
+	for _, category := range advisorCategories {
+		recommendations, err := GetAdvisorRecommendationsE(t, resourceGroupName, category)
+		if err != nil {
+			t.Logf("Could not query Advisor %s recommendations for %s: %v", category, resourceGroupName, err)
+			continue
+		}
+		for _, recommendation := range recommendations {
+			if recommendation.Impact == "High" && !ignored[strings.ToLower(recommendation.RecommendationTypeID)] {
+				findings = append(findings, recommendation)
+			}
+		}
+	}
+	RecordReport(t, "advisor", resourceGroupName, findings)
+
+	for _, finding := range findings {
+		message := "Advisor %s recommendation (%s) for %s %s: %s. %s"
+		args := []interface{}{finding.Category, finding.RecommendationTypeID, finding.ImpactedField,
+			finding.ImpactedValue, finding.ShortDescription.Problem, finding.ShortDescription.Solution}
+		if mode == "fail" {
+			t.Errorf(message, args...)
+		} else {
+			t.Logf(message, args...)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/armid"
+)
+
+// alertCondition is a leaf of an activity log alert condition: a field that
+// must equal a value, or a list of alternatives
+type alertCondition struct {
+	Field  string           `json:"field"`
+	Equals string           `json:"equals"`
+	AnyOf  []alertCondition `json:"anyOf"`
+}
+
+// ActivityLogAlert is the part of an activity log alert, as returned by
+// `az monitor activity-log alert show`, that decides what it watches
+type ActivityLogAlert struct {
+	ID        string   `json:"id"`
+	Enabled   bool     `json:"enabled"`
+	Scopes    []string `json:"scopes"`
+	Condition struct {
+		AllOf []alertCondition `json:"allOf"`
+	} `json:"condition"`
+}
+
+// GetActivityLogAlertE reads the activity log alert with the given resource ID
+func GetActivityLogAlertE(t *testing.T, alertID string) (*ActivityLogAlert, error) {
+	var alert ActivityLogAlert
+	if err := AzCLIJSONE(t, &alert, "monitor", "activity-log", "alert", "show", "--ids", alertID); err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// GetActivityLogAlert reads the activity log alert with the given resource
+// ID, failing the test on error
+func GetActivityLogAlert(t *testing.T, alertID string) *ActivityLogAlert {
+	alert, err := GetActivityLogAlertE(t, alertID)
+	if err != nil {
+		t.Fatalf("Reading activity log alert %s: %v", alertID, err)
+	}
+	return alert
+}
+
+// ResourceTypes returns the resource types the alert is restricted to,
+// lowercased and sorted. Empty means every resource type in its scopes
+func (a *ActivityLogAlert) ResourceTypes() []string {
+	var types []string
+	var collect func(conditions []alertCondition)
+	collect = func(conditions []alertCondition) {
+		for _, condition := range conditions {
+			if strings.EqualFold(condition.Field, "resourceType") {
+				types = append(types, strings.ToLower(condition.Equals))
+			}
+			collect(condition.AnyOf)
+		}
+	}
+	collect(a.Condition.AllOf)
+	sort.Strings(types)
+	return types
+}
+
+// Covers reports whether the alert watches the resource with the given ID and
+// type: the resource lies within one of its scopes and, if the alert is
+// restricted to resource types, its type is one of them. ARM IDs and types
+// are compared case-insensitively; a resourceID that is not an ARM ID is
+// never covered
+func (a *ActivityLogAlert) Covers(resourceID, resourceType string) bool {
+	id, err := armid.Parse(resourceID)
+	if err != nil {
+		return false
+	}
+	inScope := false
+	for _, scope := range a.Scopes {
+		if parsed, err := armid.Parse(scope); err == nil && id.Within(parsed) {
+			inScope = true
+			break
+		}
+	}
+	if !inScope {
+		return false
+	}
+
+	types := a.ResourceTypes()
+	if len(types) == 0 {
+		return true
+	}
+	for _, covered := range types {
+		if covered == strings.ToLower(resourceType) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduledQueryRule is the part of a log search alert rule, as returned by
+// `az monitor scheduled-query show`, that decides what it queries and whom
+// it notifies
+type ScheduledQueryRule struct {
+	ID       string   `json:"id"`
+	Enabled  bool     `json:"enabled"`
+	Scopes   []string `json:"scopes"`
+	Severity int      `json:"severity"`
+	Criteria struct {
+		AllOf []struct {
+			Query string `json:"query"`
+		} `json:"allOf"`
+	} `json:"criteria"`
+	Actions struct {
+		ActionGroups []string `json:"actionGroups"`
+	} `json:"actions"`
+}
+
+// GetScheduledQueryRuleE reads the log search alert rule with the given
+// resource ID
+func GetScheduledQueryRuleE(t *testing.T, ruleID string) (*ScheduledQueryRule, error) {
+	var rule ScheduledQueryRule
+	if err := AzCLIJSONE(t, &rule, "monitor", "scheduled-query", "show", "--ids", ruleID); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetScheduledQueryRule reads the log search alert rule with the given
+// resource ID, failing the test on error
+func GetScheduledQueryRule(t *testing.T, ruleID string) *ScheduledQueryRule {
+	rule, err := GetScheduledQueryRuleE(t, ruleID)
+	if err != nil {
+		t.Fatalf("Reading log search alert rule %s: %v", ruleID, err)
+	}
+	return rule
+}
+
+// Queries returns the queries of the rule's criteria
+func (r *ScheduledQueryRule) Queries() []string {
+	queries := make([]string, 0, len(r.Criteria.AllOf))
+	for _, criterion := range r.Criteria.AllOf {
+		queries = append(queries, criterion.Query)
+	}
+	return queries
+}
+
+// commonAlertSchemaID identifies notifications in the common alert schema
+const commonAlertSchemaID = "azureMonitorCommonAlertSchema"
+
+// CommonAlert is the essentials of an alert notification in the common
+// alert schema, which action group receivers with use_common_alert_schema get
+type CommonAlert struct {
+	AlertRule        string   `json:"alertRule"`
+	Severity         string   `json:"severity"`
+	MonitorCondition string   `json:"monitorCondition"`
+	AlertTargetIDs   []string `json:"alertTargetIDs"`
+	FiredDateTime    string   `json:"firedDateTime"`
+}
+
+// DecodeCommonAlertE reads a webhook delivery of an action group as a
+// common alert schema notification
+func DecodeCommonAlertE(payload WebhookPayload) (*CommonAlert, error) {
+	var notification struct {
+		SchemaID string `json:"schemaId"`
+		Data     struct {
+			Essentials CommonAlert `json:"essentials"`
+		} `json:"data"`
+	}
+	if err := payload.DecodeJSON(&notification); err != nil {
+		return nil, err
+	}
+	if notification.SchemaID != commonAlertSchemaID {
+		return nil, fmt.Errorf("delivery has schema %q, not the common alert schema", notification.SchemaID)
+	}
+	return &notification.Data.Essentials, nil
+}
@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resourceHealthAlert is trimmed `az monitor activity-log alert show` output
+// for an alert on container apps in one resource group
+const resourceHealthAlert = `{
+  "id": "/subscriptions/s/resourceGroups/rg-obs/providers/Microsoft.Insights/activityLogAlerts/alert-health",
+  "enabled": true,
+  "scopes": ["/subscriptions/s/resourceGroups/RG-Apps"],
+  "condition": {"allOf": [
+    {"field": "category", "equals": "ResourceHealth", "anyOf": null},
+    {"field": null, "equals": null, "anyOf": [{"field": "resourceType", "equals": "Microsoft.App/containerApps"}]},
+    {"field": null, "equals": null, "anyOf": [{"field": "properties.currentHealthStatus", "equals": "Unavailable"}]}
+  ]}
+}`
+
+func TestActivityLogAlertResourceTypes(t *testing.T) {
+	t.Parallel()
+
+	var alert ActivityLogAlert
+	assert.NoError(t, json.Unmarshal([]byte(resourceHealthAlert), &alert))
+	assert.Equal(t, []string{"microsoft.app/containerapps"}, alert.ResourceTypes())
+
+	assert.Empty(t, (&ActivityLogAlert{}).ResourceTypes())
+}
+
+func TestActivityLogAlertCovers(t *testing.T) {
+	t.Parallel()
+
+	var alert ActivityLogAlert
+	assert.NoError(t, json.Unmarshal([]byte(resourceHealthAlert), &alert))
+
+	testCases := []struct {
+		name         string
+		resourceID   string
+		resourceType string
+		covered      bool
+	}{
+		{"app in scope", "/subscriptions/s/resourceGroups/rg-apps/providers/Microsoft.App/containerApps/ca-1", "Microsoft.App/containerApps", true},
+		{"type case differs", "/subscriptions/s/resourceGroups/rg-apps/providers/Microsoft.App/containerApps/ca-2", "microsoft.app/containerapps", true},
+		{"other type in scope", "/subscriptions/s/resourceGroups/rg-apps/providers/Microsoft.KeyVault/vaults/kv", "Microsoft.KeyVault/vaults", false},
+		{"resource group with scope as prefix", "/subscriptions/s/resourceGroups/rg-apps-2/providers/Microsoft.App/containerApps/ca", "Microsoft.App/containerApps", false},
+		{"other subscription", "/subscriptions/t/resourceGroups/rg-apps/providers/Microsoft.App/containerApps/ca", "Microsoft.App/containerApps", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.covered, alert.Covers(tc.resourceID, tc.resourceType))
+		})
+	}
+}
+
+func TestScheduledQueryRuleQueries(t *testing.T) {
+	t.Parallel()
+
+	var rule ScheduledQueryRule
+	assert.NoError(t, json.Unmarshal([]byte(`{
+  "id": "/subscriptions/s/resourceGroups/rg-obs/providers/Microsoft.Insights/scheduledQueryRules/alert-cap-log",
+  "enabled": true,
+  "scopes": ["/subscriptions/s/resourceGroups/rg-obs/providers/Microsoft.OperationalInsights/workspaces/log"],
+  "severity": 1,
+  "criteria": {"allOf": [{"query": "_LogOperation | where Detail has \"OverQuota\"", "operator": "GreaterThan"}]},
+  "actions": {"actionGroups": ["/subscriptions/s/resourceGroups/rg-obs/providers/Microsoft.Insights/actionGroups/ag"]}
+}`), &rule))
+	assert.Equal(t, []string{`_LogOperation | where Detail has "OverQuota"`}, rule.Queries())
+	assert.Equal(t, 1, rule.Severity)
+	assert.Len(t, rule.Actions.ActionGroups, 1)
+
+	assert.Empty(t, (&ScheduledQueryRule{}).Queries())
+}
+
+func TestDecodeCommonAlertE(t *testing.T) {
+	t.Parallel()
+
+	alert, err := DecodeCommonAlertE(WebhookPayload{Body: `{
+  "schemaId": "azureMonitorCommonAlertSchema",
+  "data": {"essentials": {"alertRule": "alert-cap-log", "severity": "Sev1", "monitorCondition": "Fired",
+    "alertTargetIDs": ["/subscriptions/s/resourcegroups/rg-obs/providers/microsoft.operationalinsights/workspaces/log"]}}
+}`})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "alert-cap-log", alert.AlertRule)
+		assert.Equal(t, "Fired", alert.MonitorCondition)
+		assert.Len(t, alert.AlertTargetIDs, 1)
+	}
+
+	_, err = DecodeCommonAlertE(WebhookPayload{Body: `{"schemaId": "AzureMonitorMetricAlert"}`})
+	assert.EqualError(t, err, `delivery has schema "AzureMonitorMetricAlert", not the common alert schema`)
+
+	_, err = DecodeCommonAlertE(WebhookPayload{Body: "{", Truncated: true})
+	assert.Error(t, err)
+}
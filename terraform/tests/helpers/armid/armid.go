@@ -0,0 +1,293 @@
+// Package armid parses, validates and builds Azure Resource Manager resource
+// IDs, so tests compare IDs by their parts rather than by substrings.
+//
+// An ID is a scope, a subscription and optionally a resource group, followed
+// by provider segments. Each provider segment is a namespace and a chain of
+// type / name pairs, e.g. Microsoft.KeyVault/vaults/kv/secrets/app. Extension
+// resources add another provider segment to the resource they extend:
+//
+//	/subscriptions/s/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv
+//	    /providers/Microsoft.Insights/diagnosticSettings/diag
+//
+// IDs without a subscription, such as management groups, are tenant-level.
+// Like ARM, the package treats every part of an ID case-insensitively when
+// comparing, and keeps the casing it was given otherwise.
+package armid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// SubscriptionType is the resource type of a subscription ID
+	SubscriptionType = "Microsoft.Resources/subscriptions"
+	// ResourceGroupType is the resource type of a resource group ID
+	ResourceGroupType = "Microsoft.Resources/resourceGroups"
+)
+
+// Resource is a type / name pair of a provider segment, e.g. vaults / kv
+type Resource struct {
+	Type string
+	Name string
+}
+
+// Provider is a provider segment of an ID: a namespace and its resources,
+// parent first
+type Provider struct {
+	Namespace string
+	Resources []Resource
+}
+
+// ID is a parsed ARM resource ID
+type ID struct {
+	// SubscriptionID is empty for tenant-level IDs
+	SubscriptionID string
+	// ResourceGroup is empty for IDs above or outside a resource group
+	ResourceGroup string
+	// Providers is empty for subscription and resource group IDs
+	Providers []Provider
+}
+
+// ErrInvalid is wrapped by every error Parse returns
+var ErrInvalid = errors.New("invalid ARM resource ID")
+
+// invalid returns an error wrapping ErrInvalid for id
+func invalid(id, format string, args ...interface{}) error {
+	return fmt.Errorf("%w %q: %s", ErrInvalid, id, fmt.Sprintf(format, args...))
+}
+
+// Parse parses an ARM resource ID. Segment names such as "subscriptions",
+// "resourceGroups" and "providers" are matched case-insensitively, and a
+// single trailing slash is ignored
+func Parse(id string) (ID, error) {
+	if !strings.HasPrefix(id, "/") {
+		return ID{}, invalid(id, "does not start with /")
+	}
+	segments := strings.Split(strings.TrimSuffix(id[1:], "/"), "/")
+	for i, segment := range segments {
+		if segment == "" {
+			return ID{}, invalid(id, "segment %d is empty", i+1)
+		}
+	}
+
+	var parsed ID
+	if strings.EqualFold(segments[0], "subscriptions") {
+		if len(segments) < 2 {
+			return ID{}, invalid(id, "subscriptions has no subscription ID")
+		}
+		parsed.SubscriptionID = segments[1]
+		segments = segments[2:]
+
+		if len(segments) > 0 && strings.EqualFold(segments[0], "resourceGroups") {
+			if len(segments) < 2 {
+				return ID{}, invalid(id, "resourceGroups has no resource group name")
+			}
+			parsed.ResourceGroup = segments[1]
+			segments = segments[2:]
+		}
+	}
+
+	for len(segments) > 0 {
+		if !strings.EqualFold(segments[0], "providers") {
+			return ID{}, invalid(id, "expected providers, found %q", segments[0])
+		}
+		if len(segments) < 2 {
+			return ID{}, invalid(id, "providers has no namespace")
+		}
+		provider := Provider{Namespace: segments[1]}
+		segments = segments[2:]
+
+		for len(segments) > 0 && !strings.EqualFold(segments[0], "providers") {
+			if len(segments) < 2 {
+				return ID{}, invalid(id, "resource type %q has no name", segments[0])
+			}
+			provider.Resources = append(provider.Resources, Resource{Type: segments[0], Name: segments[1]})
+			segments = segments[2:]
+		}
+		if len(provider.Resources) == 0 {
+			return ID{}, invalid(id, "provider %s has no resource", provider.Namespace)
+		}
+		parsed.Providers = append(parsed.Providers, provider)
+	}
+
+	if parsed.SubscriptionID == "" && len(parsed.Providers) == 0 {
+		return ID{}, invalid(id, "names no subscription and no provider resource")
+	}
+	return parsed, nil
+}
+
+// ParseOfType parses id and checks that it is of resourceType, e.g.
+// "Microsoft.KeyVault/vaults", compared case-insensitively
+func ParseOfType(id, resourceType string) (ID, error) {
+	parsed, err := Parse(id)
+	if err != nil {
+		return ID{}, err
+	}
+	if !strings.EqualFold(parsed.ResourceType(), resourceType) {
+		return ID{}, invalid(id, "is a %s, not a %s", parsed.ResourceType(), resourceType)
+	}
+	return parsed, nil
+}
+
+// NewSubscriptionID returns the ID of a subscription
+func NewSubscriptionID(subscriptionID string) ID {
+	return ID{SubscriptionID: subscriptionID}
+}
+
+// NewResourceGroupID returns the ID of a resource group
+func NewResourceGroupID(subscriptionID, resourceGroup string) ID {
+	return ID{SubscriptionID: subscriptionID, ResourceGroup: resourceGroup}
+}
+
+// NewResourceID returns the ID of a resource in a resource group, given its
+// full type and one name per level of it: "Microsoft.KeyVault/vaults/secrets"
+// with names "kv" and "app" is .../providers/Microsoft.KeyVault/vaults/kv/secrets/app
+func NewResourceID(subscriptionID, resourceGroup, resourceType string, names ...string) (ID, error) {
+	return NewResourceGroupID(subscriptionID, resourceGroup).Extension(resourceType, names...)
+}
+
+// Extension returns the ID of a resource of resourceType under id in a new
+// provider segment, given one name per level of the type. It builds resources
+// in a resource group from its ID, as NewResourceID does, and extension
+// resources such as diagnostic settings from the resource they extend
+func (id ID) Extension(resourceType string, names ...string) (ID, error) {
+	types := strings.Split(resourceType, "/")
+	if len(types) < 2 || len(types)-1 != len(names) {
+		return ID{}, fmt.Errorf("%w: resource type %q needs %d names, got %d", ErrInvalid, resourceType, len(types)-1, len(names))
+	}
+	provider := Provider{Namespace: types[0]}
+	for i, name := range names {
+		if types[i+1] == "" || name == "" || strings.Contains(name, "/") {
+			return ID{}, fmt.Errorf("%w: %s %q is not a valid name", ErrInvalid, types[i+1], name)
+		}
+		provider.Resources = append(provider.Resources, Resource{Type: types[i+1], Name: name})
+	}
+
+	extended := id.clone()
+	extended.Providers = append(extended.Providers, provider)
+	return extended, nil
+}
+
+// Child returns the ID of a child resource of id, e.g. the secrets / app of
+// a vault. id must name a provider resource
+func (id ID) Child(resourceType, name string) (ID, error) {
+	if len(id.Providers) == 0 {
+		return ID{}, fmt.Errorf("%w: %s has no provider resource to add %s to", ErrInvalid, id, resourceType)
+	}
+	if resourceType == "" || strings.Contains(resourceType, "/") || name == "" || strings.Contains(name, "/") {
+		return ID{}, fmt.Errorf("%w: child %q / %q", ErrInvalid, resourceType, name)
+	}
+	child := id.clone()
+	last := &child.Providers[len(child.Providers)-1]
+	last.Resources = append(last.Resources, Resource{Type: resourceType, Name: name})
+	return child, nil
+}
+
+// clone returns a copy of id that shares no slices with it
+func (id ID) clone() ID {
+	cloned := ID{SubscriptionID: id.SubscriptionID, ResourceGroup: id.ResourceGroup}
+	for _, provider := range id.Providers {
+		cloned.Providers = append(cloned.Providers, Provider{
+			Namespace: provider.Namespace,
+			Resources: append([]Resource(nil), provider.Resources...),
+		})
+	}
+	return cloned
+}
+
+// String returns the ID in the canonical form ARM returns
+func (id ID) String() string {
+	var b strings.Builder
+	if id.SubscriptionID != "" {
+		b.WriteString("/subscriptions/" + id.SubscriptionID)
+		if id.ResourceGroup != "" {
+			b.WriteString("/resourceGroups/" + id.ResourceGroup)
+		}
+	}
+	for _, provider := range id.Providers {
+		b.WriteString("/providers/" + provider.Namespace)
+		for _, resource := range provider.Resources {
+			b.WriteString("/" + resource.Type + "/" + resource.Name)
+		}
+	}
+	return b.String()
+}
+
+// ResourceType returns the full type of the resource id names, e.g.
+// "Microsoft.KeyVault/vaults/secrets", or SubscriptionType and
+// ResourceGroupType for those
+func (id ID) ResourceType() string {
+	if len(id.Providers) == 0 {
+		if id.ResourceGroup != "" {
+			return ResourceGroupType
+		}
+		return SubscriptionType
+	}
+	last := id.Providers[len(id.Providers)-1]
+	types := []string{last.Namespace}
+	for _, resource := range last.Resources {
+		types = append(types, resource.Type)
+	}
+	return strings.Join(types, "/")
+}
+
+// Name returns the name of the resource id names: the last name of its
+// provider resources, or the resource group or subscription ID
+func (id ID) Name() string {
+	if len(id.Providers) > 0 {
+		resources := id.Providers[len(id.Providers)-1].Resources
+		return resources[len(resources)-1].Name
+	}
+	if id.ResourceGroup != "" {
+		return id.ResourceGroup
+	}
+	return id.SubscriptionID
+}
+
+// Parent returns the ID one level up: the parent resource of a child, the
+// resource an extension resource extends, the resource group of a top-level
+// resource or the subscription of a resource group. The second result is
+// false for subscriptions and top-level tenant resources
+func (id ID) Parent() (ID, bool) {
+	if len(id.Providers) == 0 {
+		if id.ResourceGroup == "" {
+			return ID{}, false
+		}
+		return NewSubscriptionID(id.SubscriptionID), true
+	}
+
+	parent := id.clone()
+	last := &parent.Providers[len(parent.Providers)-1]
+	last.Resources = last.Resources[:len(last.Resources)-1]
+	if len(last.Resources) == 0 {
+		parent.Providers = parent.Providers[:len(parent.Providers)-1]
+	}
+	if parent.SubscriptionID == "" && len(parent.Providers) == 0 {
+		return ID{}, false
+	}
+	return parent, true
+}
+
+// Equal reports whether id and other name the same resource, ignoring case
+func (id ID) Equal(other ID) bool {
+	return strings.EqualFold(id.String(), other.String())
+}
+
+// Within reports whether id is scope or lies under it, e.g. a resource in a
+// resource group, a child of a resource or an extension of it. Segments are
+// compared whole and case-insensitively, so rg-apps-2 is not within rg-apps
+func (id ID) Within(scope ID) bool {
+	idSegments := strings.Split(strings.ToLower(id.String()), "/")
+	scopeSegments := strings.Split(strings.ToLower(scope.String()), "/")
+	if len(scopeSegments) > len(idSegments) {
+		return false
+	}
+	for i, segment := range scopeSegments {
+		if idSegments[i] != segment {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,311 @@
+package armid
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSubscription = "00000000-0000-0000-0000-000000000000"
+	// otherTenantSubscription is a subscription of another tenant, as
+	// Lighthouse-delegated and cross-tenant resources reference
+	otherTenantSubscription = "11111111-1111-1111-1111-111111111111"
+	testGroup               = "/subscriptions/" + testSubscription + "/resourceGroups/rg-apps"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		id           string
+		expected     ID
+		resourceType string
+		resourceName string
+	}{
+		{"subscription", "/subscriptions/" + testSubscription,
+			ID{SubscriptionID: testSubscription}, SubscriptionType, testSubscription},
+		{"resource_group", testGroup,
+			ID{SubscriptionID: testSubscription, ResourceGroup: "rg-apps"}, ResourceGroupType, "rg-apps"},
+		{"resource", testGroup + "/providers/Microsoft.App/containerApps/ca-api",
+			ID{SubscriptionID: testSubscription, ResourceGroup: "rg-apps", Providers: []Provider{
+				{Namespace: "Microsoft.App", Resources: []Resource{{"containerApps", "ca-api"}}},
+			}}, "Microsoft.App/containerApps", "ca-api"},
+		{"child", testGroup + "/providers/Microsoft.KeyVault/vaults/kv/secrets/app",
+			ID{SubscriptionID: testSubscription, ResourceGroup: "rg-apps", Providers: []Provider{
+				{Namespace: "Microsoft.KeyVault", Resources: []Resource{{"vaults", "kv"}, {"secrets", "app"}}},
+			}}, "Microsoft.KeyVault/vaults/secrets", "app"},
+		{"grandchild", testGroup + "/providers/Microsoft.Network/virtualNetworks/vnet/subnets/snet/serviceAssociationLinks/legionservicelink",
+			ID{SubscriptionID: testSubscription, ResourceGroup: "rg-apps", Providers: []Provider{
+				{Namespace: "Microsoft.Network", Resources: []Resource{{"virtualNetworks", "vnet"}, {"subnets", "snet"}, {"serviceAssociationLinks", "legionservicelink"}}},
+			}}, "Microsoft.Network/virtualNetworks/subnets/serviceAssociationLinks", "legionservicelink"},
+		{"extension", testGroup + "/providers/Microsoft.KeyVault/vaults/kv/providers/Microsoft.Insights/diagnosticSettings/diag",
+			ID{SubscriptionID: testSubscription, ResourceGroup: "rg-apps", Providers: []Provider{
+				{Namespace: "Microsoft.KeyVault", Resources: []Resource{{"vaults", "kv"}}},
+				{Namespace: "Microsoft.Insights", Resources: []Resource{{"diagnosticSettings", "diag"}}},
+			}}, "Microsoft.Insights/diagnosticSettings", "diag"},
+		{"subscription_resource", "/subscriptions/" + testSubscription + "/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7",
+			ID{SubscriptionID: testSubscription, Providers: []Provider{
+				{Namespace: "Microsoft.Authorization", Resources: []Resource{{"roleDefinitions", "acdd72a7-3385-48ef-bd42-f606fba81ae7"}}},
+			}}, "Microsoft.Authorization/roleDefinitions", "acdd72a7-3385-48ef-bd42-f606fba81ae7"},
+		{"resource_group_extension", testGroup + "/providers/Microsoft.Authorization/roleAssignments/ra",
+			ID{SubscriptionID: testSubscription, ResourceGroup: "rg-apps", Providers: []Provider{
+				{Namespace: "Microsoft.Authorization", Resources: []Resource{{"roleAssignments", "ra"}}},
+			}}, "Microsoft.Authorization/roleAssignments", "ra"},
+		{"tenant", "/providers/Microsoft.Management/managementGroups/mg-platform",
+			ID{Providers: []Provider{
+				{Namespace: "Microsoft.Management", Resources: []Resource{{"managementGroups", "mg-platform"}}},
+			}}, "Microsoft.Management/managementGroups", "mg-platform"},
+		{"cross_tenant", "/subscriptions/" + otherTenantSubscription + "/resourceGroups/rg-shared/providers/Microsoft.ContainerRegistry/registries/acrshared",
+			ID{SubscriptionID: otherTenantSubscription, ResourceGroup: "rg-shared", Providers: []Provider{
+				{Namespace: "Microsoft.ContainerRegistry", Resources: []Resource{{"registries", "acrshared"}}},
+			}}, "Microsoft.ContainerRegistry/registries", "acrshared"},
+		{"lowercase_segments", "/subscriptions/" + testSubscription + "/resourcegroups/RG-Apps/PROVIDERS/microsoft.app/containerapps/ca-api",
+			ID{SubscriptionID: testSubscription, ResourceGroup: "RG-Apps", Providers: []Provider{
+				{Namespace: "microsoft.app", Resources: []Resource{{"containerapps", "ca-api"}}},
+			}}, "microsoft.app/containerapps", "ca-api"},
+		{"trailing_slash", testGroup + "/",
+			ID{SubscriptionID: testSubscription, ResourceGroup: "rg-apps"}, ResourceGroupType, "rg-apps"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := Parse(tc.id)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.expected, parsed)
+			assert.Equal(t, tc.resourceType, parsed.ResourceType())
+			assert.Equal(t, tc.resourceName, parsed.Name())
+
+			roundTripped, err := Parse(parsed.String())
+			if assert.NoError(t, err) {
+				assert.Equal(t, parsed, roundTripped, "String must round-trip through Parse")
+			}
+		})
+	}
+}
+
+func TestParseString(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := Parse("/subscriptions/s/resourcegroups/rg/providers/Microsoft.KeyVault/vaults/kv/")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/subscriptions/s/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv", parsed.String(),
+			"String uses ARM's casing of segment names and drops the trailing slash")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		id       string
+		expected string
+	}{
+		{"empty", "", "does not start with /"},
+		{"relative", "subscriptions/" + testSubscription, "does not start with /"},
+		{"root", "/", "segment 1 is empty"},
+		{"double_slash", "/subscriptions//resourceGroups/rg", "segment 2 is empty"},
+		{"no_subscription_id", "/subscriptions", "subscriptions has no subscription ID"},
+		{"no_group_name", "/subscriptions/s/resourceGroups", "resourceGroups has no resource group name"},
+		{"no_namespace", testGroup + "/providers", "providers has no namespace"},
+		{"no_resource", testGroup + "/providers/Microsoft.App", "provider Microsoft.App has no resource"},
+		{"no_name", testGroup + "/providers/Microsoft.App/containerApps", `resource type "containerApps" has no name`},
+		{"no_child_name", testGroup + "/providers/Microsoft.KeyVault/vaults/kv/secrets", `resource type "secrets" has no name`},
+		{"empty_extension", testGroup + "/providers/Microsoft.KeyVault/vaults/kv/providers/Microsoft.Insights", "provider Microsoft.Insights has no resource"},
+		{"unknown_segment", "/subscriptions/s/locations/eastus", `expected providers, found "locations"`},
+		{"not_an_id", "/ca-api", `expected providers, found "ca-api"`},
+		{"url", "https://kv.vault.azure.net/secrets/app", "does not start with /"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse(tc.id)
+			if assert.Error(t, err) {
+				assert.True(t, errors.Is(err, ErrInvalid), "errors must wrap ErrInvalid")
+				assert.Contains(t, err.Error(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestParseOfType(t *testing.T) {
+	t.Parallel()
+
+	vaultID := testGroup + "/providers/Microsoft.KeyVault/vaults/kv"
+
+	parsed, err := ParseOfType(vaultID, "microsoft.keyvault/VAULTS")
+	if assert.NoError(t, err, "types are compared case-insensitively") {
+		assert.Equal(t, "kv", parsed.Name())
+	}
+
+	_, err = ParseOfType(vaultID+"/secrets/app", "Microsoft.KeyVault/vaults")
+	assert.ErrorContains(t, err, "is a Microsoft.KeyVault/vaults/secrets, not a Microsoft.KeyVault/vaults")
+
+	_, err = ParseOfType(testGroup, ResourceGroupType)
+	assert.NoError(t, err)
+
+	_, err = ParseOfType("kv", "Microsoft.KeyVault/vaults")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "/subscriptions/"+testSubscription, NewSubscriptionID(testSubscription).String())
+	assert.Equal(t, testGroup, NewResourceGroupID(testSubscription, "rg-apps").String())
+
+	secret, err := NewResourceID(testSubscription, "rg-apps", "Microsoft.KeyVault/vaults/secrets", "kv", "app")
+	if assert.NoError(t, err) {
+		assert.Equal(t, testGroup+"/providers/Microsoft.KeyVault/vaults/kv/secrets/app", secret.String())
+	}
+
+	vault, err := NewResourceID(testSubscription, "rg-apps", "Microsoft.KeyVault/vaults", "kv")
+	if !assert.NoError(t, err) {
+		return
+	}
+	child, err := vault.Child("secrets", "app")
+	if assert.NoError(t, err) {
+		assert.True(t, child.Equal(secret), "Child builds the same ID as the full type")
+	}
+	assert.Empty(t, vault.Providers[0].Resources[1:], "Child must not change the ID it extends")
+
+	diagnostics, err := vault.Extension("Microsoft.Insights/diagnosticSettings", "diag")
+	if assert.NoError(t, err) {
+		assert.Equal(t, testGroup+"/providers/Microsoft.KeyVault/vaults/kv/providers/Microsoft.Insights/diagnosticSettings/diag", diagnostics.String())
+	}
+
+	crossTenant, err := NewResourceID(otherTenantSubscription, "rg-shared", "Microsoft.ContainerRegistry/registries", "acrshared")
+	if assert.NoError(t, err) {
+		assert.Equal(t, otherTenantSubscription, crossTenant.SubscriptionID)
+		assert.False(t, crossTenant.Within(NewSubscriptionID(testSubscription)))
+	}
+}
+
+func TestBuildInvalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		build func() (ID, error)
+	}{
+		{"no_type", func() (ID, error) { return NewResourceID("s", "rg", "Microsoft.KeyVault", "kv") }},
+		{"too_few_names", func() (ID, error) { return NewResourceID("s", "rg", "Microsoft.KeyVault/vaults/secrets", "kv") }},
+		{"too_many_names", func() (ID, error) { return NewResourceID("s", "rg", "Microsoft.KeyVault/vaults", "kv", "app") }},
+		{"empty_name", func() (ID, error) { return NewResourceID("s", "rg", "Microsoft.KeyVault/vaults", "") }},
+		{"name_with_slash", func() (ID, error) { return NewResourceID("s", "rg", "Microsoft.KeyVault/vaults", "kv/app") }},
+		{"child_of_group", func() (ID, error) { return NewResourceGroupID("s", "rg").Child("secrets", "app") }},
+		{"child_type_with_slash", func() (ID, error) {
+			vault, _ := NewResourceID("s", "rg", "Microsoft.KeyVault/vaults", "kv")
+			return vault.Child("secrets/versions", "app")
+		}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := tc.build()
+			assert.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
+
+func TestParent(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		id       string
+		expected string
+	}{
+		{"child", testGroup + "/providers/Microsoft.KeyVault/vaults/kv/secrets/app", testGroup + "/providers/Microsoft.KeyVault/vaults/kv"},
+		{"extension", testGroup + "/providers/Microsoft.KeyVault/vaults/kv/providers/Microsoft.Insights/diagnosticSettings/diag", testGroup + "/providers/Microsoft.KeyVault/vaults/kv"},
+		{"resource", testGroup + "/providers/Microsoft.KeyVault/vaults/kv", testGroup},
+		{"resource_group", testGroup, "/subscriptions/" + testSubscription},
+		{"subscription_resource", "/subscriptions/s/providers/Microsoft.Authorization/roleDefinitions/r", "/subscriptions/s"},
+		{"subscription", "/subscriptions/s", ""},
+		{"tenant", "/providers/Microsoft.Management/managementGroups/mg", ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := Parse(tc.id)
+			if !assert.NoError(t, err) {
+				return
+			}
+			parent, ok := parsed.Parent()
+			if tc.expected == "" {
+				assert.False(t, ok, "%s has no parent", tc.id)
+				return
+			}
+			if assert.True(t, ok) {
+				assert.Equal(t, tc.expected, parent.String())
+			}
+		})
+	}
+}
+
+func TestWithin(t *testing.T) {
+	t.Parallel()
+
+	app := testGroup + "/providers/Microsoft.App/containerApps/ca-api"
+	testCases := []struct {
+		name     string
+		id       string
+		scope    string
+		expected bool
+	}{
+		{"same", app, app, true},
+		{"resource_in_group", app, testGroup, true},
+		{"resource_in_subscription", app, "/subscriptions/" + testSubscription, true},
+		{"child_of_resource", app + "/revisions/ca-api--1", app, true},
+		{"extension_of_resource", app + "/providers/Microsoft.Insights/diagnosticSettings/diag", app, true},
+		{"case_differs", app, "/SUBSCRIPTIONS/" + testSubscription + "/resourcegroups/RG-APPS", true},
+		{"group_name_prefix", "/subscriptions/" + testSubscription + "/resourceGroups/rg-apps-2/providers/Microsoft.App/containerApps/ca", testGroup, false},
+		{"other_subscription", "/subscriptions/" + otherTenantSubscription + "/resourceGroups/rg-apps/providers/Microsoft.App/containerApps/ca-api", testGroup, false},
+		{"scope_below_id", testGroup, app, false},
+		{"tenant_resource", "/providers/Microsoft.Management/managementGroups/mg", "/subscriptions/" + testSubscription, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			id, err := Parse(tc.id)
+			if !assert.NoError(t, err) {
+				return
+			}
+			scope, err := Parse(tc.scope)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.expected, id.Within(scope))
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+
+	a, _ := Parse(testGroup + "/providers/Microsoft.App/containerApps/ca-api")
+	b, _ := Parse("/subscriptions/" + testSubscription + "/resourcegroups/RG-APPS/providers/microsoft.app/containerapps/CA-API")
+	c, _ := Parse(testGroup + "/providers/Microsoft.App/containerApps/ca-web")
+
+	assert.True(t, a.Equal(b), "IDs are compared case-insensitively")
+	assert.False(t, a.Equal(c))
+}
@@ -0,0 +1,142 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+// AuthMode is how the tests, and the terraform they run, sign in to Azure
+type AuthMode string
+
+// Sign-in modes, in the order the azurerm provider would pick them
+const (
+	// AuthClientSecret signs in as a service principal with a client
+	// secret (ARM_CLIENT_ID, ARM_CLIENT_SECRET)
+	AuthClientSecret AuthMode = "client-secret"
+	// AuthOIDC signs in as a workload identity with a federated token from
+	// the CI system (ARM_USE_OIDC), so the run holds no long-lived secret
+	AuthOIDC AuthMode = "oidc"
+	// AuthManagedIdentity signs in as the managed identity of a runner in
+	// Azure (ARM_USE_MSI), system-assigned or, with ARM_CLIENT_ID,
+	// user-assigned (see ManagedIdentityTokenE)
+	AuthManagedIdentity AuthMode = "managed-identity"
+	// AuthAzureCLI uses the runner's `az login`, the default
+	AuthAzureCLI AuthMode = "azure-cli"
+)
+
+var (
+	runAuthOnce sync.Once
+	runAuthMode AuthMode
+	runAuthErr  error
+	runAuthEnv  map[string]string
+)
+
+// AuthModeFromEnvE returns the sign-in mode the ARM_* variables read with
+// getenv ask for. OIDC needs ARM_CLIENT_ID, ARM_TENANT_ID and a token
+// FederatedTokenSourceE finds. OIDC and managed identity refuse
+// ARM_CLIENT_SECRET: the provider would sign in with the secret first
+func AuthModeFromEnvE(getenv func(string) string) (AuthMode, error) {
+	switch {
+	case getenv("ARM_USE_OIDC") == "true":
+		var missing []string
+		for _, name := range []string{"ARM_CLIENT_ID", "ARM_TENANT_ID"} {
+			if getenv(name) == "" {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return "", fmt.Errorf("ARM_USE_OIDC is set, but %v are not", missing)
+		}
+		if getenv("ARM_CLIENT_SECRET") != "" {
+			return "", errors.New("ARM_USE_OIDC and ARM_CLIENT_SECRET are both set; terraform would sign in with the secret, unset it")
+		}
+		if _, err := FederatedTokenSourceE(getenv); err != nil {
+			return "", fmt.Errorf("ARM_USE_OIDC is set: %w", err)
+		}
+		return AuthOIDC, nil
+	case getenv("ARM_USE_MSI") == "true":
+		if getenv("ARM_CLIENT_SECRET") != "" {
+			return "", errors.New("ARM_USE_MSI and ARM_CLIENT_SECRET are both set; terraform would sign in with the secret, unset it")
+		}
+		return AuthManagedIdentity, nil
+	case getenv("ARM_CLIENT_SECRET") != "":
+		if getenv("ARM_CLIENT_ID") == "" {
+			return "", errors.New("ARM_CLIENT_SECRET is set, but ARM_CLIENT_ID is not")
+		}
+		return AuthClientSecret, nil
+	default:
+		return AuthAzureCLI, nil
+	}
+}
+
+// authEnv returns the variables terraform.Options.EnvVars needs so terraform
+// signs in with mode and nothing else. Terraform inherits the run's
+// environment, including the token variables, so only the mode is pinned:
+// a mode that cannot sign in fails rather than falling back to the runner's
+// `az login`
+func authEnv(mode AuthMode, getenv func(string) string) map[string]string {
+	switch mode {
+	case AuthOIDC:
+		return map[string]string{
+			"ARM_USE_OIDC":  "true",
+			"ARM_USE_MSI":   "false",
+			"ARM_USE_CLI":   "false",
+			"ARM_CLIENT_ID": getenv("ARM_CLIENT_ID"),
+			"ARM_TENANT_ID": getenv("ARM_TENANT_ID"),
+		}
+	case AuthManagedIdentity:
+		env := map[string]string{"ARM_USE_MSI": "true", "ARM_USE_OIDC": "false", "ARM_USE_CLI": "false"}
+		if clientID := getenv("ARM_CLIENT_ID"); clientID != "" {
+			env["ARM_CLIENT_ID"] = clientID
+		}
+		return env
+	case AuthClientSecret:
+		return map[string]string{"ARM_USE_OIDC": "false", "ARM_USE_MSI": "false", "ARM_USE_CLI": "false"}
+	default:
+		return map[string]string{}
+	}
+}
+
+// runAuthE returns the sign-in mode of the run and the terraform variables
+// pinning it, read from the environment once. A managed identity run is
+// signed in then (see signInManagedIdentityE)
+func runAuthE() (AuthMode, map[string]string, error) {
+	runAuthOnce.Do(func() {
+		runAuthMode, runAuthErr = AuthModeFromEnvE(os.Getenv)
+		if runAuthErr == nil && runAuthMode == AuthManagedIdentity {
+			runAuthErr = signInManagedIdentityE(os.Getenv)
+		}
+		if runAuthErr == nil {
+			runAuthEnv = authEnv(runAuthMode, os.Getenv)
+		}
+	})
+	return runAuthMode, runAuthEnv, runAuthErr
+}
+
+// RunAuthMode returns the sign-in mode of the run, failing the test when the
+// ARM_* variables ask for a mode they do not configure
+func RunAuthMode(t *testing.T) AuthMode {
+	mode, _, err := runAuthE()
+	if err != nil {
+		t.Fatalf("Azure sign-in: %v", err)
+	}
+	return mode
+}
+
+// TerraformAuthEnv returns a copy of the variables that make terraform sign
+// in with the run's mode, for terraform.Options.EnvVars. Options with other
+// credentials, such as TestPrincipal.Env, replace them
+func TerraformAuthEnv(t *testing.T) map[string]string {
+	_, env, err := runAuthE()
+	if err != nil {
+		t.Fatalf("Azure sign-in: %v", err)
+	}
+	copied := make(map[string]string, len(env))
+	for name, value := range env {
+		copied[name] = value
+	}
+	return copied
+}
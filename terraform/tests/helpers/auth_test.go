@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthModeFromEnv(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		env  map[string]string
+		want AuthMode
+	}{
+		"oidc":                       {map[string]string{"ARM_USE_OIDC": "true", "ARM_CLIENT_ID": "client", "ARM_TENANT_ID": "tenant", "ARM_OIDC_TOKEN": "token"}, AuthOIDC},
+		"oidc from GitHub Actions":   {map[string]string{"ARM_USE_OIDC": "true", "ARM_CLIENT_ID": "client", "ARM_TENANT_ID": "tenant", "ACTIONS_ID_TOKEN_REQUEST_URL": "https://token.actions"}, AuthOIDC},
+		"managed identity":           {map[string]string{"ARM_USE_MSI": "true"}, AuthManagedIdentity},
+		"client secret":              {map[string]string{"ARM_CLIENT_ID": "client", "ARM_CLIENT_SECRET": "secret"}, AuthClientSecret},
+		"oidc disabled, secret wins": {map[string]string{"ARM_USE_OIDC": "false", "ARM_CLIENT_ID": "client", "ARM_CLIENT_SECRET": "secret"}, AuthClientSecret},
+		"azure cli":                  {nil, AuthAzureCLI},
+	} {
+		mode, err := AuthModeFromEnvE(getenvFrom(tc.env))
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, tc.want, mode, name)
+		}
+	}
+
+	for name, env := range map[string]map[string]string{
+		"oidc without a client": {"ARM_USE_OIDC": "true", "ARM_TENANT_ID": "tenant", "ARM_OIDC_TOKEN": "token"},
+		"oidc without a token":  {"ARM_USE_OIDC": "true", "ARM_CLIENT_ID": "client", "ARM_TENANT_ID": "tenant"},
+		"oidc with a secret":    {"ARM_USE_OIDC": "true", "ARM_CLIENT_ID": "client", "ARM_TENANT_ID": "tenant", "ARM_OIDC_TOKEN": "token", "ARM_CLIENT_SECRET": "secret"},
+		"secret without client": {"ARM_CLIENT_SECRET": "secret"},
+		"msi with a secret":     {"ARM_USE_MSI": "true", "ARM_CLIENT_ID": "client", "ARM_CLIENT_SECRET": "secret"},
+	} {
+		_, err := AuthModeFromEnvE(getenvFrom(env))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestAuthEnv(t *testing.T) {
+	t.Parallel()
+
+	getenv := getenvFrom(map[string]string{"ARM_CLIENT_ID": "client", "ARM_TENANT_ID": "tenant", "ARM_OIDC_TOKEN": "token"})
+
+	env := authEnv(AuthOIDC, getenv)
+	assert.Equal(t, "true", env["ARM_USE_OIDC"])
+	assert.Equal(t, "false", env["ARM_USE_CLI"], "Terraform should not fall back to the runner's login")
+	assert.Equal(t, "client", env["ARM_CLIENT_ID"])
+	assert.NotContains(t, env, "ARM_OIDC_TOKEN", "the token is inherited from the environment, not copied into options")
+
+	msi := authEnv(AuthManagedIdentity, getenv)
+	assert.Equal(t, "true", msi["ARM_USE_MSI"])
+	assert.Equal(t, "client", msi["ARM_CLIENT_ID"], "a user-assigned identity is named by its client ID")
+	assert.NotContains(t, authEnv(AuthManagedIdentity, getenvFrom(nil)), "ARM_CLIENT_ID", "the system-assigned identity has none")
+	assert.Equal(t, "false", authEnv(AuthClientSecret, getenv)["ARM_USE_OIDC"])
+	assert.Empty(t, authEnv(AuthAzureCLI, getenv), "the default chain needs nothing pinned")
+}
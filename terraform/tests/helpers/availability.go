@@ -0,0 +1,123 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// AvailabilityLocations maps the availability test location codes the
+// observability module accepts in test_locations to the location names
+// results carry in the Location column of AppAvailabilityResults
+var AvailabilityLocations = map[string]string{
+	"us-va-ash-azr":     "East US",
+	"us-ca-sjc-azr":     "West US",
+	"us-tx-sn1-azr":     "South Central US",
+	"us-il-ch1-azr":     "North Central US",
+	"us-fl-mia-edge":    "Central US",
+	"emea-nl-ams-azr":   "West Europe",
+	"emea-gb-db3-azr":   "North Europe",
+	"emea-ru-msa-edge":  "UK South",
+	"emea-se-sto-edge":  "UK West",
+	"emea-fr-pra-edge":  "France Central",
+	"emea-ch-zrh-edge":  "France South",
+	"apac-jp-kaw-edge":  "Japan East",
+	"apac-sg-sin-azr":   "Southeast Asia",
+	"apac-hk-hkn-azr":   "East Asia",
+	"latam-br-gru-edge": "Brazil South",
+	"emea-au-syd-edge":  "Australia East",
+}
+
+// availabilityTestNamePattern matches web test names safe to quote in KQL
+var availabilityTestNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// availabilityResultsFromRows converts the rows of the AvailabilityResultsE
+// query into result counts by location name
+func availabilityResultsFromRows(rows []map[string]interface{}) (map[string]int, error) {
+	results := map[string]int{}
+	for _, row := range rows {
+		count, err := strconv.Atoi(fmt.Sprint(row["Results"]))
+		if err != nil {
+			return nil, fmt.Errorf("reading result count: %w", err)
+		}
+		results[fmt.Sprint(row["Location"])] += count
+	}
+	return results, nil
+}
+
+// AvailabilityResultsE counts the results of the availability test named
+// testName by location name, in the Log Analytics workspace with the given
+// workspace (customer) ID
+func AvailabilityResultsE(t *testing.T, workspaceID, testName string) (map[string]int, error) {
+	if !availabilityTestNamePattern.MatchString(testName) {
+		return nil, fmt.Errorf("availability test name %q is not letters, digits and dashes", testName)
+	}
+	query := fmt.Sprintf("AppAvailabilityResults | where Name == '%s' | summarize Results = count() by Location", testName)
+	rows, err := QueryLogAnalyticsE(t, workspaceID, query)
+	if err != nil {
+		return nil, err
+	}
+	return availabilityResultsFromRows(rows)
+}
+
+// ReportingLocations returns the codes among locations that have results,
+// sorted. Results from locations that are not configured are ignored
+func ReportingLocations(locations []string, results map[string]int) []string {
+	var reporting []string
+	for _, code := range locations {
+		if name, ok := AvailabilityLocations[code]; ok && results[name] > 0 {
+			reporting = append(reporting, code)
+		}
+	}
+	sort.Strings(reporting)
+	return reporting
+}
+
+// WaitForAvailabilityLocationsE polls AvailabilityResultsE until results of
+// the availability test named testName have arrived from at least minimum of
+// locations, or until timeout. It returns the results by location name
+func WaitForAvailabilityLocationsE(t *testing.T, workspaceID, testName string, locations []string, minimum int, timeout time.Duration) (map[string]int, error) {
+	return waitForAvailabilityLocationsE(t, SystemClock, func() (map[string]int, error) {
+		return AvailabilityResultsE(t, workspaceID, testName)
+	}, testName, locations, minimum, timeout)
+}
+
+// waitForAvailabilityLocationsE is WaitForAvailabilityLocationsE reading the
+// results with count and timing the polls on clock
+func waitForAvailabilityLocationsE(t *testing.T, clock Clock, count func() (map[string]int, error), testName string, locations []string, minimum int, timeout time.Duration) (map[string]int, error) {
+	const pollInterval = time.Minute
+
+	for _, code := range locations {
+		if _, ok := AvailabilityLocations[code]; !ok {
+			return nil, fmt.Errorf("unknown availability test location %q", code)
+		}
+	}
+	if minimum > len(locations) {
+		return nil, fmt.Errorf("cannot wait for %d of %d locations", minimum, len(locations))
+	}
+
+	deadline := clock.Now().Add(timeout)
+	reported := -1
+	for {
+		results, err := count()
+		if err != nil {
+			return nil, err
+		}
+		reporting := ReportingLocations(locations, results)
+		if len(reporting) != reported {
+			t.Logf("%s has results from %d of %d locations so far: %v", testName, len(reporting), len(locations), reporting)
+			reported = len(reporting)
+		}
+		if len(reporting) >= minimum {
+			return results, nil
+		}
+		if clock.Now().After(deadline) {
+			return results, fmt.Errorf("%s has results from %d of %d locations after %s, expected at least %d: %v",
+				testName, len(reporting), len(locations), timeout, minimum, reporting)
+		}
+		clock.Sleep(pollInterval)
+	}
+}
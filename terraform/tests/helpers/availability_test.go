@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvailabilityResultsFromRows(t *testing.T) {
+	t.Parallel()
+
+	results, err := availabilityResultsFromRows([]map[string]interface{}{
+		{"Location": "East US", "Results": "4"},
+		{"Location": "West Europe", "Results": "3"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]int{"East US": 4, "West Europe": 3}, results)
+	}
+
+	_, err = availabilityResultsFromRows([]map[string]interface{}{{"Location": "East US", "Results": "many"}})
+	assert.Error(t, err)
+}
+
+func TestReportingLocations(t *testing.T) {
+	t.Parallel()
+
+	locations := []string{"us-va-ash-azr", "emea-nl-ams-azr", "apac-sg-sin-azr"}
+	results := map[string]int{
+		"West Europe": 2,
+		"East US":     1,
+		// Not configured, so it does not count
+		"Japan East": 5,
+	}
+	assert.Equal(t, []string{"emea-nl-ams-azr", "us-va-ash-azr"}, ReportingLocations(locations, results))
+	assert.Empty(t, ReportingLocations(locations, map[string]int{"Southeast Asia": 0}))
+}
+
+func TestWaitForAvailabilityLocations(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	locations := []string{"us-va-ash-azr", "emea-nl-ams-azr", "apac-sg-sin-azr"}
+
+	t.Run("arrives", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(start)
+		// One location reports first, a second one two polls later
+		polls := []map[string]int{
+			{},
+			{"East US": 1},
+			{"East US": 1},
+			{"East US": 2, "West Europe": 1},
+		}
+		poll := 0
+		results, err := waitForAvailabilityLocationsE(t, clock, func() (map[string]int, error) {
+			results := polls[len(polls)-1]
+			if poll < len(polls) {
+				results = polls[poll]
+			}
+			poll++
+			return results, nil
+		}, "appi-avl-run-health-test", locations, 2, time.Hour)
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]int{"East US": 2, "West Europe": 1}, results)
+		}
+		assert.Equal(t, 3*time.Minute, clock.Slept())
+	})
+
+	t.Run("times_out", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(start)
+		results, err := waitForAvailabilityLocationsE(t, clock, func() (map[string]int, error) {
+			return map[string]int{"East US": 3, "Japan East": 3}, nil
+		}, "appi-avl-run-health-test", locations, 2, 10*time.Minute)
+		assert.EqualError(t, err, "appi-avl-run-health-test has results from 1 of 3 locations after 10m0s, expected at least 2: [us-va-ash-azr]")
+		assert.Equal(t, map[string]int{"East US": 3, "Japan East": 3}, results)
+		assert.Equal(t, 11*time.Minute, clock.Slept())
+	})
+
+	t.Run("unknown_location", func(t *testing.T) {
+		t.Parallel()
+		_, err := waitForAvailabilityLocationsE(t, NewFakeClock(start), func() (map[string]int, error) {
+			t.Fatal("should not query with an unknown location")
+			return nil, nil
+		}, "appi-avl-run-health-test", []string{"us-va-ash-azr", "eastus"}, 2, time.Hour)
+		assert.EqualError(t, err, `unknown availability test location "eastus"`)
+	})
+}
@@ -0,0 +1,142 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/shell"
+)
+
+// AzCLIE runs an Azure CLI command and returns its stdout. Command output is
+// logged through RedactingLogger since some commands return credentials
+func AzCLIE(t *testing.T, args ...string) (string, error) {
+	return shell.RunCommandAndGetStdOutE(t, shell.Command{
+		Command: "az",
+		Args:    append(args, "--only-show-errors"),
+		Logger:  RedactingLogger,
+	})
+}
+
+// AzCLI runs an Azure CLI command and fails the test on error
+func AzCLI(t *testing.T, args ...string) string {
+	output, err := AzCLIE(t, args...)
+	if err != nil {
+		t.Fatalf("az %v failed: %v", args, err)
+	}
+	return output
+}
+
+// AzCLIJSONE runs an Azure CLI command and decodes its JSON output into value
+func AzCLIJSONE(t *testing.T, value interface{}, args ...string) error {
+	output, err := AzCLIE(t, append(args, "--output", "json")...)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(output), value); err != nil {
+		return fmt.Errorf("decoding output of az %v: %w", args, err)
+	}
+	return nil
+}
+
+// AzCLIJSON runs an Azure CLI command, decodes its JSON output into value
+// and fails the test on error
+func AzCLIJSON(t *testing.T, value interface{}, args ...string) {
+	if err := AzCLIJSONE(t, value, args...); err != nil {
+		t.Fatalf("az %v failed: %v", args, err)
+	}
+}
+
+// HasRoleAssignment reports whether principalID holds roleName at exactly scope
+func HasRoleAssignment(t *testing.T, principalID, scope, roleName string) bool {
+	var assignments []struct {
+		RoleDefinitionName string `json:"roleDefinitionName"`
+		Scope              string `json:"scope"`
+	}
+	AzCLIJSON(t, &assignments, "role", "assignment", "list",
+		"--assignee", principalID, "--scope", scope)
+
+	for _, assignment := range assignments {
+		if assignment.RoleDefinitionName == roleName && strings.EqualFold(assignment.Scope, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// LatestRevisionNameE returns the name of the latest revision of a Container App
+func LatestRevisionNameE(t *testing.T, resourceGroupName, appName string) (string, error) {
+	var revision string
+	if err := AzCLIJSONE(t, &revision, "containerapp", "show", "--resource-group", resourceGroupName,
+		"--name", appName, "--query", "properties.latestRevisionName"); err != nil {
+		return "", fmt.Errorf("reading container app %s: %w", appName, err)
+	}
+	return revision, nil
+}
+
+// ReplicaNamesE returns the names of the replicas, in any state, of the
+// latest revision of a Container App, sorted; none once it has scaled to zero
+func ReplicaNamesE(t *testing.T, resourceGroupName, appName string) ([]string, error) {
+	revision, err := LatestRevisionNameE(t, resourceGroupName, appName)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicas []struct {
+		Name string `json:"name"`
+	}
+	if err := AzCLIJSONE(t, &replicas, "containerapp", "replica", "list", "--resource-group", resourceGroupName,
+		"--name", appName, "--revision", revision); err != nil {
+		return nil, fmt.Errorf("listing replicas of %s: %w", revision, err)
+	}
+	names := make([]string, 0, len(replicas))
+	for _, replica := range replicas {
+		names = append(names, replica.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ReplicaCountE returns the number of replicas, in any state, of the latest
+// revision of a Container App; 0 once it has scaled to zero
+func ReplicaCountE(t *testing.T, resourceGroupName, appName string) (int, error) {
+	replicas, err := ReplicaNamesE(t, resourceGroupName, appName)
+	return len(replicas), err
+}
+
+// RestartRevisionE restarts the latest revision of a Container App, which
+// replaces its replicas with new ones, and returns the revision's name
+func RestartRevisionE(t *testing.T, resourceGroupName, appName string) (string, error) {
+	revision, err := LatestRevisionNameE(t, resourceGroupName, appName)
+	if err != nil {
+		return "", err
+	}
+	if _, err := AzCLIE(t, "containerapp", "revision", "restart", "--resource-group", resourceGroupName,
+		"--name", appName, "--revision", revision); err != nil {
+		return "", fmt.Errorf("restarting revision %s: %w", revision, err)
+	}
+	return revision, nil
+}
+
+// ContainerEphemeralStorageE returns the ephemeral storage Azure reports for
+// the named container of a Container App, e.g. "2Gi"
+func ContainerEphemeralStorageE(t *testing.T, resourceGroupName, appName, containerName string) (string, error) {
+	var containers []struct {
+		Name      string `json:"name"`
+		Resources struct {
+			EphemeralStorage string `json:"ephemeralStorage"`
+		} `json:"resources"`
+	}
+	if err := AzCLIJSONE(t, &containers, "containerapp", "show", "--resource-group", resourceGroupName,
+		"--name", appName, "--query", "properties.template.containers"); err != nil {
+		return "", fmt.Errorf("reading containers of %s: %w", appName, err)
+	}
+	for _, container := range containers {
+		if container.Name == containerName {
+			return container.Resources.EphemeralStorage, nil
+		}
+	}
+	return "", fmt.Errorf("container app %s has no container %s", appName, containerName)
+}
@@ -15,24 +15,63 @@ import (
 
 // TestConfig holds common configuration for tests
 type TestConfig struct {
-	SubscriptionID string
-	TenantID       string
-	Location       string
+	// SubscriptionID is where the test deploys: the run's subscription, or
+	// the one NewTestConfigIn picked
+	SubscriptionID    string
+	TenantID          string
+	Location          string
 	ResourceGroupName string
-	UniqueID       string
+	UniqueID          string
+	// AuthMode is how the run signs in to Azure, and terraform with it (see
+	// TerraformAuthEnv)
+	AuthMode AuthMode
+	// Environment is the Azure cloud the run deploys to, from
+	// ARM_ENVIRONMENT: PublicCloud, USGovernmentCloud or ChinaCloud
+	Environment Cloud
+	// Namespace keeps this runner's resources apart from other engineers'
+	// in a shared subscription (see Namespace)
+	Namespace string
 }
 
-// NewTestConfig creates a new test configuration
+// NewTestConfig creates a new test configuration. With SKIP_destroy its
+// UniqueID and Location are kept for the next run, which gets them back with
+// SKIP_apply so names match the deployment it reuses
 func NewTestConfig(t *testing.T) *TestConfig {
-	subscriptionID := azure.GetSubscriptionID(t)
+	config := newTestConfig(t)
+	keepStageValue(t, "UniqueID", &config.UniqueID)
+	keepStageValue(t, "Location", &config.Location)
+	return config
+}
+
+// newTestConfig creates a test configuration that is never kept between
+// runs, for shared fixtures deployed on behalf of a test
+func newTestConfig(t *testing.T) *TestConfig {
+	return newTestConfigIn(t, "")
+}
+
+// newTestConfigIn is newTestConfig in subscriptionID, or in the run's
+// subscription when it is empty
+func newTestConfigIn(t *testing.T, subscriptionID string) *TestConfig {
+	authMode := RunAuthMode(t)
+	cloud := RunCloud(t)
+	if subscriptionID == "" {
+		subscriptionID = azure.GetSubscriptionID(t)
+	}
 	tenantID := azure.GetTenantID(t)
 
-	return &TestConfig{
+	config := &TestConfig{
 		SubscriptionID: subscriptionID,
 		TenantID:       tenantID,
 		Location:       getEnvOrDefault("ARM_LOCATION", "eastus2"),
 		UniqueID:       strings.ToLower(random.UniqueId()),
+		AuthMode:       authMode,
+		Environment:    cloud,
+		Namespace:      Namespace(),
 	}
+
+	CaptureServiceHealthOnFailure(t, config.SubscriptionID, config.Location)
+
+	return config
 }
 
 // getEnvOrDefault gets an environment variable or returns a default value
@@ -43,9 +82,10 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
-// GenerateResourceGroupName generates a unique resource group name
+// GenerateResourceGroupName generates a unique resource group name within
+// the config's namespace
 func (c *TestConfig) GenerateResourceGroupName(prefix string) string {
-	return fmt.Sprintf("rg-%s-test-%s", prefix, c.UniqueID)
+	return fmt.Sprintf("rg-%s-test-%s-%s", prefix, c.Namespace, c.UniqueID)
 }
 
 // GenerateUniqueName generates a unique name for a resource
@@ -55,21 +95,30 @@ func (c *TestConfig) GenerateUniqueName(prefix string) string {
 
 // CleanupOptions holds options for cleanup
 type CleanupOptions struct {
-	DestroyTerraform bool
+	DestroyTerraform    bool
 	DeleteResourceGroup bool
 }
 
-// DefaultTerraformOptions returns default terraform options for testing
+// DefaultTerraformOptions returns default terraform options for testing,
+// signing terraform in the way the run is (see TerraformAuthEnv) to the
+// run's cloud and subscription. Use TestConfig.TerraformOptions for a test
+// in another subscription
 func DefaultTerraformOptions(t *testing.T, terraformDir string, vars map[string]interface{}) *terraform.Options {
+	envVars := TerraformAuthEnv(t)
+	for name, value := range terraformCloudEnv(CurrentCloud(t)) {
+		envVars[name] = value
+	}
 	return &terraform.Options{
 		TerraformDir: terraformDir,
 		Vars:         vars,
+		EnvVars:      envVars,
 		NoColor:      true,
 		Parallelism:  10,
+		Logger:       RedactingLogger,
 		RetryableTerraformErrors: map[string]string{
-			".*timeout.*":           "timeout error, retrying",
+			".*timeout.*":            "timeout error, retrying",
 			".*connection refused.*": "connection refused, retrying",
-			".*already exists.*":    "resource already exists, retrying",
+			".*already exists.*":     "resource already exists, retrying",
 		},
 		MaxRetries:         3,
 		TimeBetweenRetries: 10 * time.Second,
@@ -97,25 +146,57 @@ func GetRequiredEnvVar(t *testing.T, key string) string {
 	return value
 }
 
-// CommonTags returns common tags for test resources
-func CommonTags(testName string) map[string]string {
+// CreatedAtTag is the tag holding when a test resource was created, in
+// RFC 3339 UTC, which ttk janitor -older-than reads the age of
+const CreatedAtTag = "CreatedAt"
+
+// RunIDTag is the tag holding the run that created a test resource (see
+// RunID), which actual spend is queried by (see package cost)
+const RunIDTag = "RunID"
+
+// CommonTags returns common tags for test resources, stamped with the time
+// of clock
+func CommonTags(clock Clock, testName string) map[string]string {
 	return map[string]string{
 		"ManagedBy":   "terratest",
 		"TestName":    testName,
 		"Environment": "test",
-		CreatedAt":    time.Now().UTC().Format(time.RFC3339),
+		CreatedAtTag:  clock.Now().UTC().Format(time.RFC3339),
+		NamespaceTag:  Namespace(),
+		RunIDTag:      RunID(),
+	}
+}
+
+// ResourceAge returns how long ago, by clock, the resource with the given
+// tags was created according to its CreatedAt tag. The second result is
+// false when the tag is missing or not a time
+func ResourceAge(tags map[string]string, clock Clock) (time.Duration, bool) {
+	createdAt, err := time.Parse(time.RFC3339, tags[CreatedAtTag])
+	if err != nil {
+		return 0, false
+	}
+	return clock.Now().Sub(createdAt), true
+}
+
+// WaitForResourceDeletion waits for a resource to be deleted, checking up to
+// maxRetries times and sleeping on clock in between
+func WaitForResourceDeletion(t *testing.T, clock Clock, checkFunc func() bool, maxRetries int, sleepBetweenRetries time.Duration) {
+	if err := waitForResourceDeletionE(clock, checkFunc, maxRetries, sleepBetweenRetries); err != nil {
+		t.Fatal(err)
 	}
 }
 
-// WaitForResourceDeletion waits for a resource to be deleted
-func WaitForResourceDeletion(t *testing.T, checkFunc func() bool, maxRetries int, sleepBetweenRetries time.Duration) {
+// waitForResourceDeletionE is WaitForResourceDeletion returning an error
+func waitForResourceDeletionE(clock Clock, checkFunc func() bool, maxRetries int, sleepBetweenRetries time.Duration) error {
 	for i := 0; i < maxRetries; i++ {
 		if !checkFunc() {
-			return
+			return nil
+		}
+		if i < maxRetries-1 {
+			clock.Sleep(sleepBetweenRetries)
 		}
-		time.Sleep(sleepBetweenRetries)
 	}
-	t.Fatal("Resource was not deleted within the expected time")
+	return fmt.Errorf("resource was not deleted within %s", time.Duration(maxRetries-1)*sleepBetweenRetries)
 }
 
 // ValidateTerraformOutput validates that a terraform output exists and is not empty
@@ -138,11 +219,17 @@ const (
 	DefaultRetryCount  = 3
 )
 
-// StandardTags creates tags for test resources
+// StandardTags creates tags for test resources, including the runner's
+// namespace and the time they were created
 func StandardTags(testName string) map[string]interface{} {
-	return map[string]interface{}{
-		"Environment": "test",
-		"ManagedBy":   "terratest",
-		"TestName":    testName,
+	return standardTags(SystemClock, testName)
+}
+
+// standardTags is StandardTags stamped with the time of clock
+func standardTags(clock Clock, testName string) map[string]interface{} {
+	tags := map[string]interface{}{}
+	for key, value := range CommonTags(clock, testName) {
+		tags[key] = value
 	}
+	return tags
 }
@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandardTagsCreatedAt(t *testing.T) {
+	t.Parallel()
+
+	clock := NewFakeClock(time.Date(2026, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600)))
+	tags := standardTags(clock, "TestExample")
+	assert.Equal(t, "2026-03-01T11:30:00Z", tags[CreatedAtTag], "CreatedAt is stamped in UTC")
+	assert.Equal(t, "TestExample", tags["TestName"])
+	assert.Equal(t, "terratest", tags["ManagedBy"])
+	assert.Equal(t, Namespace(), tags[NamespaceTag])
+	assert.Equal(t, RunID(), tags[RunIDTag], "spend is tracked by run")
+}
+
+func TestResourceAge(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(created)
+	tags := CommonTags(clock, "TestExample")
+
+	clock.Advance(90 * time.Minute)
+	age, known := ResourceAge(tags, clock)
+	assert.True(t, known)
+	assert.Equal(t, 90*time.Minute, age)
+
+	_, known = ResourceAge(map[string]string{}, clock)
+	assert.False(t, known, "a resource without CreatedAt has no known age")
+	_, known = ResourceAge(map[string]string{CreatedAtTag: "2026-03-01"}, clock)
+	assert.False(t, known, "CreatedAt must be RFC 3339")
+}
+
+func TestWaitForResourceDeletion(t *testing.T) {
+	t.Parallel()
+
+	t.Run("deleted", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+		checks := 0
+		err := waitForResourceDeletionE(clock, func() bool {
+			checks++
+			return checks < 3
+		}, 10, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, checks)
+		assert.Equal(t, 2*time.Minute, clock.Slept(), "sleeps between checks only")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+		checks := 0
+		err := waitForResourceDeletionE(clock, func() bool {
+			checks++
+			return true
+		}, 5, time.Minute)
+		assert.EqualError(t, err, "resource was not deleted within 4m0s")
+		assert.Equal(t, 5, checks)
+		assert.Equal(t, 4*time.Minute, clock.Slept(), "no sleep after the last check")
+	})
+}
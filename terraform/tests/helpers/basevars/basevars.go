@@ -0,0 +1,221 @@
+// Package basevars builds the smallest variable set each module plans with,
+// so a validation case only names the variable it is about:
+//
+//	Vars: basevars.MinimalKeyVaultVars(uniqueID).With("sku_name", tc.sku)
+//
+// The builders point at resources that do not exist (rg-nonexistent), which
+// is enough for validation, since variables are checked before anything is
+// read from Azure. FindRedeclarationsE finds validation cases that still
+// spell a base set out inline.
+package basevars
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// validationResourceGroup is the resource group validation plans point
+	// at; validation fails before it is looked up
+	validationResourceGroup = "rg-nonexistent"
+	// validationLocation is the region validation plans use
+	validationLocation = "eastus2"
+	// validationWorkspaceID is a well-formed workspace ID that does not exist
+	validationWorkspaceID = "/subscriptions/test/resourceGroups/test/providers/Microsoft.OperationalInsights/workspaces/test"
+)
+
+// Vars are the variables of a terraform plan. They are assignable to
+// terraform.Options.Vars
+type Vars map[string]interface{}
+
+// With returns a copy of v with the variable name set to value
+func (v Vars) With(name string, value interface{}) Vars {
+	vars := make(Vars, len(v)+1)
+	for key, existing := range v {
+		vars[key] = existing
+	}
+	vars[name] = value
+	return vars
+}
+
+// MinimalContainerAppVars returns the variables the container-app module
+// needs to plan, named after uniqueID
+func MinimalContainerAppVars(uniqueID string) Vars {
+	return Vars{
+		"name":                       fmt.Sprintf("ca-test-%s", uniqueID),
+		"environment_name":           fmt.Sprintf("cae-test-%s", uniqueID),
+		"resource_group_name":        validationResourceGroup,
+		"location":                   validationLocation,
+		"log_analytics_workspace_id": validationWorkspaceID,
+		"container_image":            "nginx:latest",
+	}
+}
+
+// MinimalKeyVaultVars returns the variables the key-vault module needs to
+// plan, named after uniqueID
+func MinimalKeyVaultVars(uniqueID string) Vars {
+	return Vars{
+		"name":                fmt.Sprintf("kvtest%s", uniqueID),
+		"resource_group_name": validationResourceGroup,
+		"location":            validationLocation,
+	}
+}
+
+// MinimalContainerRegistryVars returns the variables the container-registry
+// module needs to plan, named after uniqueID
+func MinimalContainerRegistryVars(uniqueID string) Vars {
+	return Vars{
+		"name":                fmt.Sprintf("acrtest%s", uniqueID),
+		"resource_group_name": validationResourceGroup,
+		"location":            validationLocation,
+		"sku":                 "Basic",
+	}
+}
+
+// MinimalObservabilityVars returns the variables the observability module
+// needs to plan, named after uniqueID
+func MinimalObservabilityVars(uniqueID string) Vars {
+	return Vars{
+		"resource_group_name": validationResourceGroup,
+		"location":            validationLocation,
+		"log_analytics_name":  fmt.Sprintf("log-%s", uniqueID),
+		"app_insights_name":   fmt.Sprintf("appi-%s", uniqueID),
+	}
+}
+
+// MinimalResourceGroupVars returns the variables the resource-group module
+// needs to plan, named after uniqueID
+func MinimalResourceGroupVars(uniqueID string) Vars {
+	return Vars{
+		"name":     fmt.Sprintf("rg-test-%s", uniqueID),
+		"location": validationLocation,
+		"tags":     map[string]string{"Test": "true"},
+	}
+}
+
+// Builders are the base variable builders by module folder, under
+// terraform/modules
+var Builders = map[string]func(uniqueID string) Vars{
+	"container-app":      MinimalContainerAppVars,
+	"container-registry": MinimalContainerRegistryVars,
+	"key-vault":          MinimalKeyVaultVars,
+	"observability":      MinimalObservabilityVars,
+	"resource-group":     MinimalResourceGroupVars,
+}
+
+// FindRedeclarationsE parses the _test.go files in testsDir and returns, as
+// file:line, every validation case spelling out a module's base variables:
+// a terraform.Options literal inside a loop over test cases, planning a
+// module of Builders directly, whose Vars map literal names all of the
+// module's base variables. Tests deploying a module once, outside a loop,
+// name their real resources and are left alone
+func FindRedeclarationsE(testsDir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(testsDir, "*_test.go"))
+	if err != nil {
+		return nil, err
+	}
+	fileSet := token.NewFileSet()
+	found := map[string]bool{}
+	for _, file := range files {
+		parsed, err := parser.ParseFile(fileSet, file, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		ast.Inspect(parsed, func(node ast.Node) bool {
+			loop, ok := node.(*ast.RangeStmt)
+			if !ok {
+				return true
+			}
+			ast.Inspect(loop.Body, func(node ast.Node) bool {
+				literal, ok := node.(*ast.CompositeLit)
+				if !ok || !isTerraformOptions(literal.Type) {
+					return true
+				}
+				module, vars := optionsFields(literal)
+				build, tracked := Builders[module]
+				if tracked && vars != nil && namesAll(vars, build("")) {
+					position := fileSet.Position(literal.Pos())
+					found[fmt.Sprintf("%s:%d", filepath.Base(position.Filename), position.Line)] = true
+				}
+				return true
+			})
+			return true
+		})
+	}
+	positions := make([]string, 0, len(found))
+	for position := range found {
+		positions = append(positions, position)
+	}
+	sort.Strings(positions)
+	return positions, nil
+}
+
+// isTerraformOptions reports whether expr is the type terraform.Options
+func isTerraformOptions(expr ast.Expr) bool {
+	selector, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := selector.X.(*ast.Ident)
+	return ok && pkg.Name == "terraform" && selector.Sel.Name == "Options"
+}
+
+// optionsFields returns the module a terraform.Options literal plans, when
+// its TerraformDir is a module folder, and its Vars when they are a literal
+func optionsFields(literal *ast.CompositeLit) (string, *ast.CompositeLit) {
+	module, vars := "", (*ast.CompositeLit)(nil)
+	for _, element := range literal.Elts {
+		field, ok := element.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := field.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		switch key.Name {
+		case "TerraformDir":
+			if dir, ok := stringLiteral(field.Value); ok && strings.HasPrefix(dir, "../modules/") {
+				module = strings.TrimSuffix(strings.TrimPrefix(dir, "../modules/"), "/")
+			}
+		case "Vars":
+			vars, _ = field.Value.(*ast.CompositeLit)
+		}
+	}
+	return module, vars
+}
+
+// namesAll reports whether the map literal vars has a key for every
+// variable of base
+func namesAll(vars *ast.CompositeLit, base Vars) bool {
+	named := map[string]bool{}
+	for _, element := range vars.Elts {
+		if field, ok := element.(*ast.KeyValueExpr); ok {
+			if name, ok := stringLiteral(field.Key); ok {
+				named[name] = true
+			}
+		}
+	}
+	for name := range base {
+		if !named[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// stringLiteral returns the value of expr when it is a string literal
+func stringLiteral(expr ast.Expr) (string, bool) {
+	literal, ok := expr.(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(literal.Value)
+	return value, err == nil
+}
@@ -0,0 +1,108 @@
+package basevars
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWith(t *testing.T) {
+	t.Parallel()
+
+	base := MinimalKeyVaultVars("abc")
+	vars := base.With("sku_name", "premium")
+
+	assert.Equal(t, "premium", vars["sku_name"])
+	assert.Equal(t, "kvtestabc", vars["name"])
+	assert.NotContains(t, base, "sku_name", "With should not change the base")
+
+	overridden := base.With("name", "kv-valid-name")
+	assert.Equal(t, "kv-valid-name", overridden["name"])
+	assert.Equal(t, "kvtestabc", base["name"])
+}
+
+func TestBuildersPlanWithoutAzure(t *testing.T) {
+	t.Parallel()
+
+	for module, build := range Builders {
+		vars := build("abc")
+		assert.NotEmpty(t, vars, module)
+		if group, ok := vars["resource_group_name"]; ok {
+			assert.Equal(t, validationResourceGroup, group, "%s should point at a resource group that does not exist", module)
+		}
+		assert.Equal(t, validationLocation, vars["location"], module)
+	}
+}
+
+// redeclaringTest has a validation case spelling out the key-vault base
+// variables, cases using a builder, naming some base variables or planning a
+// fixture, and a deployment outside any loop
+const redeclaringTest = `package test
+
+func TestDeploy(t *testing.T) {
+	options := &terraform.Options{
+		TerraformDir: "../modules/key-vault",
+		Vars: map[string]interface{}{
+			"name":                kvName,
+			"resource_group_name": resourceGroupName,
+			"location":            location,
+		},
+	}
+}
+
+func TestInline(t *testing.T) {
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options := &terraform.Options{
+				TerraformDir: "../modules/key-vault",
+				Vars: map[string]interface{}{
+					"name":                "kvtest",
+					"resource_group_name": "rg-nonexistent",
+					"location":            "eastus2",
+					"sku_name":            tc.sku,
+				},
+			}
+		})
+	}
+	for _, tc := range testCases {
+		built := &terraform.Options{
+			TerraformDir: "../modules/container-app",
+			Vars:         basevars.MinimalContainerAppVars(uniqueID).With("container_cpu", tc.cpu),
+		}
+		partial := &terraform.Options{
+			TerraformDir: "../modules/observability",
+			Vars: map[string]interface{}{
+				"location": "eastus2",
+			},
+		}
+		fixture := &terraform.Options{
+			TerraformDir: "../modules/resource-group/examples/complete",
+			Vars: map[string]interface{}{
+				"name":     "rg-test",
+				"location": "eastus2",
+				"tags":     map[string]string{},
+			},
+		}
+	}
+}
+`
+
+func TestFindRedeclarationsE(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "inline_test.go"), []byte(redeclaringTest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "helper.go"), []byte(redeclaringTest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := FindRedeclarationsE(dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"inline_test.go:17"}, found,
+			"only a validation case with a module's full base set inline should be reported, and only in test files")
+	}
+}
@@ -0,0 +1,223 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/armid"
+)
+
+const (
+	// BreakGlassRole is the role the break-glass procedure grants on the
+	// vault: read secrets, nothing more
+	BreakGlassRole = "Key Vault Secrets User"
+	// BreakGlassSLA is how long the procedure may take from opening to the
+	// responder reading secrets; role assignments can take minutes to reach
+	// the Key Vault data plane
+	BreakGlassSLA = 10 * time.Minute
+)
+
+// VaultAccess is who and what may reach a vault's secrets: the firewall's
+// default action and IP rules, and the role assignments made at the vault
+type VaultAccess struct {
+	DefaultAction string   `json:"default_action"`
+	IPRules       []string `json:"ip_rules"`
+	// Assignments are "<principal ID> <role>", sorted
+	Assignments []string `json:"assignments"`
+}
+
+// VaultAccessE reads the firewall and the role assignments at exactly the
+// vault with ID vaultID; assignments inherited from above are left out
+func VaultAccessE(t *testing.T, vaultID string) (VaultAccess, error) {
+	var vault struct {
+		Properties struct {
+			NetworkACLs *struct {
+				DefaultAction string `json:"defaultAction"`
+				IPRules       []struct {
+					Value string `json:"value"`
+				} `json:"ipRules"`
+			} `json:"networkAcls"`
+		} `json:"properties"`
+	}
+	if err := AzCLIJSONE(t, &vault, "keyvault", "show", "--ids", vaultID); err != nil {
+		return VaultAccess{}, err
+	}
+	access := VaultAccess{DefaultAction: "Allow"}
+	if acls := vault.Properties.NetworkACLs; acls != nil {
+		access.DefaultAction = acls.DefaultAction
+		for _, rule := range acls.IPRules {
+			access.IPRules = append(access.IPRules, normalizeIPRule(rule.Value))
+		}
+	}
+	sort.Strings(access.IPRules)
+
+	var assignments []struct {
+		PrincipalID        string `json:"principalId"`
+		RoleDefinitionName string `json:"roleDefinitionName"`
+		Scope              string `json:"scope"`
+	}
+	if err := AzCLIJSONE(t, &assignments, "role", "assignment", "list", "--scope", vaultID); err != nil {
+		return VaultAccess{}, err
+	}
+	for _, assignment := range assignments {
+		if strings.EqualFold(strings.TrimRight(assignment.Scope, "/"), strings.TrimRight(vaultID, "/")) {
+			access.Assignments = append(access.Assignments, assignment.PrincipalID+" "+assignment.RoleDefinitionName)
+		}
+	}
+	sort.Strings(access.Assignments)
+	return access, nil
+}
+
+// normalizeIPRule writes a single address as its /32 range, the way Key
+// Vault may return either
+func normalizeIPRule(rule string) string {
+	if !strings.Contains(rule, "/") {
+		return rule + "/32"
+	}
+	return rule
+}
+
+// DiffVaultAccess lists how after differs from before, empty when they are
+// the same
+func DiffVaultAccess(before, after VaultAccess) []string {
+	var diffs []string
+	if !strings.EqualFold(before.DefaultAction, after.DefaultAction) {
+		diffs = append(diffs, fmt.Sprintf("firewall default action %s, was %s", after.DefaultAction, before.DefaultAction))
+	}
+	diffs = append(diffs, diffSets("IP rule", before.IPRules, after.IPRules)...)
+	return append(diffs, diffSets("role assignment", before.Assignments, after.Assignments)...)
+}
+
+// diffSets lists the values of kind added to and removed from before
+func diffSets(kind string, before, after []string) []string {
+	seen := map[string]bool{}
+	for _, value := range before {
+		seen[value] = true
+	}
+	var diffs []string
+	for _, value := range after {
+		if !seen[value] {
+			diffs = append(diffs, fmt.Sprintf("%s %s added", kind, value))
+		}
+		delete(seen, value)
+	}
+	var removed []string
+	for value := range seen {
+		removed = append(removed, value)
+	}
+	sort.Strings(removed)
+	for _, value := range removed {
+		diffs = append(diffs, fmt.Sprintf("%s %s removed", kind, value))
+	}
+	return diffs
+}
+
+// BreakGlass is emergency read access to a firewalled, RBAC-only vault,
+// opened by OpenBreakGlassE and reverted when it expires
+type BreakGlass struct {
+	VaultID     string
+	PrincipalID string
+	// IPRange is the firewall exception, e.g. 203.0.113.7/32
+	IPRange   string
+	OpenedAt  time.Time
+	ExpiresAt time.Time
+
+	t            *testing.T
+	vault        armid.ID
+	assignmentID string
+	ruleAdded    bool
+	timer        *time.Timer
+	revertOnce   sync.Once
+	reverted     chan struct{}
+	revertedAt   time.Time
+	revertErr    error
+}
+
+// OpenBreakGlassE runs the break-glass procedure on the vault with ID
+// vaultID: it grants principalID BreakGlassRole at the vault and adds ip to
+// the vault firewall, then reverts both after ttl, or when the test ends,
+// whichever comes first. A step that fails reverts the steps before it
+func OpenBreakGlassE(t *testing.T, vaultID, principalID, ip string, ttl time.Duration) (*BreakGlass, error) {
+	vault, err := armid.ParseOfType(vaultID, "Microsoft.KeyVault/vaults")
+	if err != nil {
+		return nil, err
+	}
+	glass := &BreakGlass{
+		VaultID:     vaultID,
+		PrincipalID: principalID,
+		IPRange:     normalizeIPRule(ip),
+		OpenedAt:    time.Now(),
+		t:           t,
+		vault:       vault,
+		reverted:    make(chan struct{}),
+	}
+	t.Cleanup(func() {
+		if err := glass.RevertE(); err != nil {
+			t.Errorf("Reverting break-glass access to %s: %v", vault.Name(), err)
+		}
+	})
+
+	// New principals take a while to replicate, so the grant is retried
+	glass.assignmentID, err = retry.DoWithRetryE(t, fmt.Sprintf("granting %s break-glass access", principalID), 6, 10*time.Second, func() (string, error) {
+		output, err := AzCLIE(t, "role", "assignment", "create", "--assignee-object-id", principalID,
+			"--assignee-principal-type", "ServicePrincipal", "--role", BreakGlassRole, "--scope", vaultID,
+			"--query", "id", "--output", "tsv")
+		return strings.TrimSpace(output), err
+	})
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("granting %s: %w", BreakGlassRole, err), glass.RevertE())
+	}
+	if _, err := AzCLIE(t, "keyvault", "network-rule", "add", "--name", vault.Name(), "--resource-group", vault.ResourceGroup,
+		"--ip-address", glass.IPRange); err != nil {
+		return nil, errors.Join(fmt.Errorf("adding firewall exception %s: %w", glass.IPRange, err), glass.RevertE())
+	}
+	glass.ruleAdded = true
+
+	glass.ExpiresAt = glass.OpenedAt.Add(ttl)
+	glass.timer = time.AfterFunc(time.Until(glass.ExpiresAt), func() {
+		glass.RevertE()
+	})
+	t.Logf("Opened break-glass access to %s for %s from %s until %s", vault.Name(), principalID, glass.IPRange,
+		glass.ExpiresAt.Format(time.RFC3339))
+	return glass, nil
+}
+
+// RevertE removes the firewall exception and the role assignment, once;
+// later calls return the first call's result. Both are attempted even when
+// one fails
+func (g *BreakGlass) RevertE() error {
+	g.revertOnce.Do(func() {
+		if g.timer != nil {
+			g.timer.Stop()
+		}
+		var errs []error
+		if g.ruleAdded {
+			if _, err := AzCLIE(g.t, "keyvault", "network-rule", "remove", "--name", g.vault.Name(), "--resource-group", g.vault.ResourceGroup,
+				"--ip-address", g.IPRange); err != nil {
+				errs = append(errs, fmt.Errorf("removing firewall exception %s: %w", g.IPRange, err))
+			}
+		}
+		if g.assignmentID != "" {
+			if _, err := AzCLIE(g.t, "role", "assignment", "delete", "--ids", g.assignmentID); err != nil {
+				errs = append(errs, fmt.Errorf("deleting role assignment %s: %w", g.assignmentID, err))
+			}
+		}
+		g.revertErr = errors.Join(errs...)
+		g.revertedAt = time.Now()
+		close(g.reverted)
+	})
+	return g.revertErr
+}
+
+// Wait blocks until the access has been reverted, by expiry or RevertE, and
+// returns when that finished and its error
+func (g *BreakGlass) Wait() (time.Time, error) {
+	<-g.reverted
+	return g.revertedAt, g.revertErr
+}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffVaultAccess(t *testing.T) {
+	t.Parallel()
+
+	before := VaultAccess{
+		DefaultAction: "Deny",
+		IPRules:       []string{"198.51.100.0/24"},
+		Assignments:   []string{"deployer Key Vault Administrator"},
+	}
+	assert.Empty(t, DiffVaultAccess(before, before))
+
+	during := VaultAccess{
+		DefaultAction: "Deny",
+		IPRules:       []string{"198.51.100.0/24", normalizeIPRule("203.0.113.7")},
+		Assignments:   []string{"deployer Key Vault Administrator", "responder Key Vault Secrets User"},
+	}
+	assert.Equal(t, []string{
+		"IP rule 203.0.113.7/32 added",
+		"role assignment responder Key Vault Secrets User added",
+	}, DiffVaultAccess(before, during))
+
+	assert.Equal(t, []string{
+		"firewall default action Allow, was Deny",
+		"IP rule 198.51.100.0/24 removed",
+		"role assignment deployer Key Vault Administrator removed",
+	}, DiffVaultAccess(before, VaultAccess{DefaultAction: "Allow"}), "a revert that removes too much is no revert either")
+}
@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+// CertificateExpiry is when a certificate a long-lived environment depends
+// on expires
+type CertificateExpiry struct {
+	// Source is where the certificate was found: an endpoint's host:port, or
+	// keyvault/<vault>/<certificate>
+	Source   string    `json:"source"`
+	Subject  string    `json:"subject,omitempty"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+// String describes the certificate and when it expires
+func (c CertificateExpiry) String() string {
+	return fmt.Sprintf("%s expires %s", c.Source, c.NotAfter.Format(time.RFC3339))
+}
+
+// ExpiresWithin reports whether the certificate has expired by now+within
+func (c CertificateExpiry) ExpiresWithin(now time.Time, within time.Duration) bool {
+	return !c.NotAfter.After(now.Add(within))
+}
+
+// ExpiringCertificates returns the certificates of certs expiring within
+// within of now, soonest first
+func ExpiringCertificates(certs []CertificateExpiry, now time.Time, within time.Duration) []CertificateExpiry {
+	var expiring []CertificateExpiry
+	for _, cert := range certs {
+		if cert.ExpiresWithin(now, within) {
+			expiring = append(expiring, cert)
+		}
+	}
+	sort.Slice(expiring, func(i, j int) bool { return expiring[i].NotAfter.Before(expiring[j].NotAfter) })
+	return expiring
+}
+
+// EndpointCertificateExpiryE reads the expiry of the leaf certificate
+// endpoint (URL, host or host:port) serves. The chain is not verified, so an
+// expired certificate is still read; AssertTLSBaseline checks chains
+func EndpointCertificateExpiryE(endpoint string) (*CertificateExpiry, error) {
+	address := tlsAddress(endpoint)
+	state, err := handshakeE(address, &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true, // #nosec G402 -- reading the expiry only
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", address, err)
+	}
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("%s served no certificate over TLS 1.2+", address)
+	}
+	leaf := state.PeerCertificates[0]
+	return &CertificateExpiry{Source: address, Subject: leaf.Subject.CommonName, NotAfter: leaf.NotAfter}, nil
+}
+
+// keyVaultCertificate is the part of `az keyvault certificate list` read
+// for expiry
+type keyVaultCertificate struct {
+	Name       string `json:"name"`
+	Attributes struct {
+		Enabled *bool      `json:"enabled"`
+		Expires *time.Time `json:"expires"`
+	} `json:"attributes"`
+}
+
+// keyVaultCertificateExpiries returns the expiries of the enabled
+// certificates of vaultName; a certificate without an expiry never expires
+func keyVaultCertificateExpiries(vaultName string, certs []keyVaultCertificate) []CertificateExpiry {
+	var expiries []CertificateExpiry
+	for _, cert := range certs {
+		if cert.Attributes.Expires == nil || (cert.Attributes.Enabled != nil && !*cert.Attributes.Enabled) {
+			continue
+		}
+		expiries = append(expiries, CertificateExpiry{
+			Source:   fmt.Sprintf("keyvault/%s/%s", vaultName, cert.Name),
+			Subject:  cert.Name,
+			NotAfter: cert.Attributes.Expires.UTC(),
+		})
+	}
+	return expiries
+}
+
+// KeyVaultCertificateExpiriesE lists when the enabled certificates of Key
+// Vault vaultName expire. The runner needs to list certificates, and to get
+// through the vault's firewall
+func KeyVaultCertificateExpiriesE(t *testing.T, vaultName string) ([]CertificateExpiry, error) {
+	var certs []keyVaultCertificate
+	if err := AzCLIJSONE(t, &certs, "keyvault", "certificate", "list", "--vault-name", vaultName); err != nil {
+		return nil, fmt.Errorf("listing certificates of %s: %w", vaultName, err)
+	}
+	return keyVaultCertificateExpiries(vaultName, certs), nil
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiringCertificates(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	certs := []CertificateExpiry{
+		{Source: "later", NotAfter: now.Add(20 * 24 * time.Hour)},
+		{Source: "fine", NotAfter: now.Add(90 * 24 * time.Hour)},
+		{Source: "expired", NotAfter: now.Add(-time.Hour)},
+		{Source: "boundary", NotAfter: now.Add(30 * 24 * time.Hour)},
+	}
+
+	var sources []string
+	for _, cert := range ExpiringCertificates(certs, now, 30*24*time.Hour) {
+		sources = append(sources, cert.Source)
+	}
+	assert.Equal(t, []string{"expired", "later", "boundary"}, sources)
+	assert.Empty(t, ExpiringCertificates(certs[1:2], now, 30*24*time.Hour))
+}
+
+func TestEndpointCertificateExpiry(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	expiry, err := EndpointCertificateExpiryE(server.URL)
+	if assert.NoError(t, err, "a self-signed certificate's expiry is still read") {
+		assert.Equal(t, server.Certificate().NotAfter, expiry.NotAfter)
+		assert.Equal(t, tlsAddress(server.URL), expiry.Source)
+	}
+}
+
+func TestKeyVaultCertificateExpiries(t *testing.T) {
+	t.Parallel()
+
+	var certs []keyVaultCertificate
+	err := json.Unmarshal([]byte(`[
+		{"name": "api-tls", "attributes": {"enabled": true, "expires": "2026-03-01T12:00:00+00:00"}},
+		{"name": "retired", "attributes": {"enabled": false, "expires": "2025-01-01T00:00:00+00:00"}},
+		{"name": "no-expiry", "attributes": {"enabled": true, "expires": null}}
+	]`), &certs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []CertificateExpiry{{
+		Source:   "keyvault/kv-dev/api-tls",
+		Subject:  "api-tls",
+		NotAfter: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}}, keyVaultCertificateExpiries("kv-dev", certs), "disabled certificates and those without an expiry are left out")
+}
@@ -0,0 +1,132 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+)
+
+// checkpointRoot holds checkpoints of every run, keyed by run ID and test name
+const checkpointRoot = ".test-data"
+
+// Checkpoint persists the progress of a long test (completed stages,
+// terraform options, outputs and created resources) so an interrupted run can
+// be resumed with RESUME_RUN_ID=<run id> without re-applying infrastructure
+type Checkpoint struct {
+	Dir      string
+	Resuming bool
+
+	cleared bool
+}
+
+// NewCheckpoint returns the checkpoint of the current test in the current run.
+// Resuming is true when RESUME_RUN_ID is set and a previous attempt of this
+// test left a checkpoint behind
+func NewCheckpoint(t *testing.T) *Checkpoint {
+	dir := filepath.Join(checkpointRoot, RunID(), WorkspaceName("", t.Name()))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("Creating checkpoint folder %s: %v", dir, err)
+	}
+
+	checkpoint := &Checkpoint{
+		Dir:      dir,
+		Resuming: os.Getenv("RESUME_RUN_ID") != "" && test_structure.IsTestDataPresent(t, test_structure.FormatTestDataPath(dir, "TerraformOptions.json")),
+	}
+	if checkpoint.Resuming {
+		t.Logf("Resuming %s from checkpoint %s", t.Name(), dir)
+	} else {
+		t.Logf("Checkpointing %s to %s (resume with RESUME_RUN_ID=%s)", t.Name(), dir, RunID())
+	}
+	return checkpoint
+}
+
+// Stage runs a named stage unless a previous attempt already completed it.
+// SKIP_<stage> environment variables from test_structure are honored too
+func (c *Checkpoint) Stage(t *testing.T, name string, stage func()) {
+	if c.StageCompleted(t, name) {
+		t.Logf("Stage %s completed in a previous attempt, skipping", name)
+		return
+	}
+
+	test_structure.RunTestStage(t, name, func() {
+		stage()
+		if !t.Failed() && !c.cleared {
+			test_structure.SaveString(t, c.Dir, "stage-"+name, "completed")
+		}
+	})
+}
+
+// StageCompleted reports whether a previous attempt completed the named stage
+func (c *Checkpoint) StageCompleted(t *testing.T, name string) bool {
+	path := test_structure.FormatTestDataPath(c.Dir, "stage-"+name+".json")
+	return test_structure.IsTestDataPresent(t, path)
+}
+
+// SaveOptions persists terraform options so a resumed attempt can reuse the state
+func (c *Checkpoint) SaveOptions(t *testing.T, options *terraform.Options) {
+	test_structure.SaveTerraformOptions(t, c.Dir, options)
+}
+
+// LoadOrSaveOptions returns the terraform options a previous attempt saved
+// when resuming, so a deploy stage that crashed mid-apply is re-run against
+// the names and state of its partial deployment. Otherwise build is called
+// and its options are saved before they are returned
+func (c *Checkpoint) LoadOrSaveOptions(t *testing.T, build func() *terraform.Options) *terraform.Options {
+	if c.Resuming {
+		t.Logf("Reusing the terraform options saved in %s", c.Dir)
+		return c.LoadOptions(t)
+	}
+
+	options := build()
+	c.SaveOptions(t, options)
+	return options
+}
+
+// LoadOptions loads persisted terraform options. The logger cannot be
+// serialized, so it is reset to RedactingLogger
+func (c *Checkpoint) LoadOptions(t *testing.T) *terraform.Options {
+	options := test_structure.LoadTerraformOptions(t, c.Dir)
+	options.Logger = RedactingLogger
+	return options
+}
+
+// SaveOutputs persists terraform outputs for the verification stages
+func (c *Checkpoint) SaveOutputs(t *testing.T, outputs map[string]interface{}) {
+	test_structure.SaveTestData(t, test_structure.FormatTestDataPath(c.Dir, "Outputs.json"), true, outputs)
+}
+
+// LoadOutputs loads persisted terraform outputs
+func (c *Checkpoint) LoadOutputs(t *testing.T) map[string]interface{} {
+	var outputs map[string]interface{}
+	test_structure.LoadTestData(t, test_structure.FormatTestDataPath(c.Dir, "Outputs.json"), &outputs)
+	return outputs
+}
+
+// RecordResource appends a resource created outside terraform (e.g. by the
+// Azure CLI) so a resumed attempt can still clean it up
+func (c *Checkpoint) RecordResource(t *testing.T, resourceID string) {
+	resources := c.Resources(t)
+	resources = append(resources, resourceID)
+	test_structure.SaveTestData(t, test_structure.FormatTestDataPath(c.Dir, "Resources.json"), true, resources)
+}
+
+// Resources returns the resources recorded with RecordResource
+func (c *Checkpoint) Resources(t *testing.T) []string {
+	path := test_structure.FormatTestDataPath(c.Dir, "Resources.json")
+	if !test_structure.IsTestDataPresent(t, path) {
+		return nil
+	}
+
+	var resources []string
+	test_structure.LoadTestData(t, path, &resources)
+	return resources
+}
+
+// Clear removes the checkpoint once the test has torn down its resources
+func (c *Checkpoint) Clear(t *testing.T) {
+	test_structure.CleanupTestDataFolder(t, c.Dir)
+	c.cleared = true
+}
@@ -0,0 +1,48 @@
+package helpers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointResumesCrashedDeploy(t *testing.T) {
+	t.Setenv("RESUME_RUN_ID", "")
+	t.Cleanup(func() { os.RemoveAll(checkpointRoot) })
+
+	attemptOptions := func(uniqueID string) func() *terraform.Options {
+		return func() *terraform.Options {
+			return &terraform.Options{
+				TerraformDir: "/tmp/fixture-" + uniqueID,
+				Vars:         map[string]interface{}{"key_vault_name": "kv-cmk-" + uniqueID},
+			}
+		}
+	}
+
+	// The first attempt saves its options, then crashes mid-apply before
+	// the deploy stage completes
+	first := NewCheckpoint(t)
+	assert.False(t, first.Resuming)
+	first.LoadOrSaveOptions(t, attemptOptions("first"))
+
+	t.Setenv("RESUME_RUN_ID", RunID())
+	resumed := NewCheckpoint(t)
+	if !assert.True(t, resumed.Resuming, "a checkpoint with saved options should be resumed") {
+		return
+	}
+	assert.False(t, resumed.StageCompleted(t, "deploy"), "the crashed deploy stage should run again")
+
+	var options *terraform.Options
+	resumed.Stage(t, "deploy", func() {
+		options = resumed.LoadOrSaveOptions(t, attemptOptions("second"))
+	})
+	if assert.NotNil(t, options) {
+		assert.Equal(t, "/tmp/fixture-first", options.TerraformDir, "the retried apply should reuse the partial deployment's state")
+		assert.Equal(t, "kv-cmk-first", options.Vars["key_vault_name"], "the retried apply should keep the names of the partial deployment")
+		assert.NotNil(t, options.Logger)
+	}
+	assert.Equal(t, "kv-cmk-first", resumed.LoadOptions(t).Vars["key_vault_name"], "resuming should not overwrite the saved options")
+	assert.True(t, resumed.StageCompleted(t, "deploy"))
+}
@@ -0,0 +1,259 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/gruntwork-io/terratest/modules/shell"
+)
+
+// federatedTokenAudience is the audience Entra ID expects on federated
+// (OIDC) tokens it exchanges
+const federatedTokenAudience = "api://AzureADTokenExchange"
+
+// errNoCIIdentity is returned when the run has no CI identity to sign in as
+var errNoCIIdentity = errors.New("no CI identity")
+
+// FederatedTokenSource gets a fresh OIDC token from the CI system. Tokens
+// are short-lived, so it is called for every sign-in
+type FederatedTokenSource func() (string, error)
+
+// CIIdentity is the workload identity the delivery pipeline deploys with. It
+// signs in with a federated token from the CI system, never a secret, and
+// is not created by the tests. Its Azure CLI session is kept apart from the
+// runner's login
+type CIIdentity struct {
+	ClientID       string
+	TenantID       string
+	SubscriptionID string
+	// ObjectID is the object ID of its service principal, used for role assignments
+	ObjectID string
+
+	federatedToken FederatedTokenSource
+	configDir      string
+}
+
+// String identifies the identity
+func (c *CIIdentity) String() string {
+	return fmt.Sprintf("CI identity %s", c.ClientID)
+}
+
+// FederatedTokenSourceE returns the OIDC token source of the CI system the
+// run is in, read with getenv from the variables the azurerm provider reads:
+// ARM_OIDC_TOKEN, ARM_OIDC_TOKEN_FILE_PATH, an Azure Pipelines service
+// connection (SYSTEM_OIDCREQUESTURI, SYSTEM_ACCESSTOKEN and
+// ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID) or GitHub Actions
+// (ACTIONS_ID_TOKEN_REQUEST_URL and ACTIONS_ID_TOKEN_REQUEST_TOKEN), in
+// that order
+func FederatedTokenSourceE(getenv func(string) string) (FederatedTokenSource, error) {
+	if token := getenv("ARM_OIDC_TOKEN"); token != "" {
+		return func() (string, error) { return token, nil }, nil
+	}
+	if path := getenv("ARM_OIDC_TOKEN_FILE_PATH"); path != "" {
+		return func() (string, error) {
+			token, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("reading OIDC token: %w", err)
+			}
+			return strings.TrimSpace(string(token)), nil
+		}, nil
+	}
+	if requestURI, connection := getenv("SYSTEM_OIDCREQUESTURI"), getenv("ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID"); requestURI != "" && connection != "" {
+		accessToken := getenv("SYSTEM_ACCESSTOKEN")
+		return func() (string, error) {
+			var token struct {
+				OIDCToken string `json:"oidcToken"`
+			}
+			query := url.Values{"api-version": {"7.1"}, "serviceConnectionId": {connection}}
+			if err := requestFederatedTokenE(http.MethodPost, requestURI, query, accessToken, &token); err != nil {
+				return "", err
+			}
+			return token.OIDCToken, nil
+		}, nil
+	}
+	if requestURL := getenv("ACTIONS_ID_TOKEN_REQUEST_URL"); requestURL != "" {
+		requestToken := getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+		return func() (string, error) {
+			var token struct {
+				Value string `json:"value"`
+			}
+			query := url.Values{"audience": {federatedTokenAudience}}
+			if err := requestFederatedTokenE(http.MethodGet, requestURL, query, requestToken, &token); err != nil {
+				return "", err
+			}
+			return token.Value, nil
+		}, nil
+	}
+	return nil, errors.New("no OIDC token: set ARM_OIDC_TOKEN or ARM_OIDC_TOKEN_FILE_PATH, or run in Azure Pipelines or GitHub Actions")
+}
+
+// requestFederatedTokenE asks a CI system's token endpoint for an OIDC token,
+// authenticated with bearer, and decodes the response into value
+func requestFederatedTokenE(method, endpoint string, query url.Values, bearer string, value interface{}) error {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("parsing OIDC token endpoint: %w", err)
+	}
+	merged := parsed.Query()
+	for key, values := range query {
+		merged[key] = values
+	}
+	parsed.RawQuery = merged.Encode()
+
+	request, err := http.NewRequest(method, parsed.String(), nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+bearer)
+	request.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("requesting OIDC token: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("OIDC token endpoint returned %d", response.StatusCode)
+	}
+	if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+		return fmt.Errorf("decoding OIDC token response: %w", err)
+	}
+	return nil
+}
+
+// CIIdentityE returns the CI identity named by TEST_CI_CLIENT_ID, in the
+// tenant and subscription of the run. It signs in with tokens from
+// FederatedTokenSourceE
+func CIIdentityE(t *testing.T, tenantID, subscriptionID string) (*CIIdentity, error) {
+	clientID := os.Getenv("TEST_CI_CLIENT_ID")
+	if clientID == "" {
+		return nil, fmt.Errorf("%w: TEST_CI_CLIENT_ID is not set", errNoCIIdentity)
+	}
+	source, err := FederatedTokenSourceE(os.Getenv)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoCIIdentity, err)
+	}
+
+	identity := &CIIdentity{ClientID: clientID, TenantID: tenantID, SubscriptionID: subscriptionID, federatedToken: source}
+	var servicePrincipal struct {
+		ID string `json:"id"`
+	}
+	if err := AzCLIJSONE(t, &servicePrincipal, "ad", "sp", "show", "--id", clientID); err != nil {
+		return nil, fmt.Errorf("reading the service principal of %s: %w", identity, err)
+	}
+	identity.ObjectID = servicePrincipal.ID
+	return identity, nil
+}
+
+// NewCIIdentity returns the CI identity of the run, skipping the test when
+// TEST_CI_CLIENT_ID is not set or the run has no OIDC token
+func NewCIIdentity(t *testing.T, tenantID, subscriptionID string) *CIIdentity {
+	identity, err := CIIdentityE(t, tenantID, subscriptionID)
+	if errors.Is(err, errNoCIIdentity) {
+		SkipWithReason(t, SkipMissingEnv, fmt.Sprintf("Set TEST_CI_CLIENT_ID to the delivery pipeline's workload identity and run with an OIDC token: %v", err))
+	}
+	if err != nil {
+		t.Fatalf("CI identity: %v", err)
+	}
+	return identity
+}
+
+// AssignRoleE grants the identity roleName at scope, as the pipeline's
+// service connection would hold it
+func (c *CIIdentity) AssignRoleE(t *testing.T, roleName, scope string) error {
+	return assignRoleE(t, c.ObjectID, c.String(), roleName, scope)
+}
+
+// AssignRole grants the identity roleName at scope and fails the test on error
+func (c *CIIdentity) AssignRole(t *testing.T, roleName, scope string) {
+	if err := c.AssignRoleE(t, roleName, scope); err != nil {
+		t.Fatalf("Assigning %s at %s to %s: %v", roleName, scope, c, err)
+	}
+}
+
+// AccessTokenE exchanges a federated token for an access token for resource
+func (c *CIIdentity) AccessTokenE(t *testing.T, resource string) (string, error) {
+	assertion, err := c.federatedToken()
+	if err != nil {
+		return "", err
+	}
+	return entraTokenE(t, c.String(), c.TenantID, resource, url.Values{
+		"client_id":             {c.ClientID},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {assertion},
+	})
+}
+
+// RegistryAuthE exchanges the identity's ARM token for an ACR refresh token,
+// as `az acr login` does, so images are pushed to loginServer as the CI
+// identity
+func (c *CIIdentity) RegistryAuthE(t *testing.T, loginServer string) (authn.Authenticator, error) {
+	accessToken, err := c.AccessTokenE(t, CurrentCloud(t).ResourceManager)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := http.PostForm(fmt.Sprintf("https://%s/oauth2/exchange", loginServer), url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {loginServer},
+		"tenant":       {c.TenantID},
+		"access_token": {accessToken},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("exchanging a token with %s: %w", loginServer, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s refused the token of %s: %d", loginServer, c, response.StatusCode)
+	}
+	var token struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("decoding the token of %s: %w", loginServer, err)
+	}
+	return &authn.Basic{Username: acrTokenUsername, Password: token.RefreshToken}, nil
+}
+
+// AzCLIE runs an Azure CLI command as the identity, like the AzureCLI task
+// of the pipeline. The first call signs in with a federated token in a
+// config folder of the test's own, set to the run's cloud, so the runner's
+// login is never switched;
+// grant roles before, since the subscription is selected then
+func (c *CIIdentity) AzCLIE(t *testing.T, args ...string) (string, error) {
+	if c.configDir == "" {
+		configDir := t.TempDir()
+		token, err := c.federatedToken()
+		if err != nil {
+			return "", err
+		}
+		if _, err := azAsE(t, configDir, "cloud", "set", "--name", CurrentCloud(t).CLIName); err != nil {
+			return "", fmt.Errorf("setting the Azure CLI of %s to its cloud: %w", c, err)
+		}
+		if _, err := azAsE(t, configDir, "login", "--service-principal", "--username", c.ClientID,
+			"--tenant", c.TenantID, "--federated-token", token, "--allow-no-subscriptions"); err != nil {
+			return "", fmt.Errorf("signing in as %s: %w", c, err)
+		}
+		if _, err := azAsE(t, configDir, "account", "set", "--subscription", c.SubscriptionID); err != nil {
+			return "", fmt.Errorf("selecting subscription %s as %s: %w", c.SubscriptionID, c, err)
+		}
+		c.configDir = configDir
+	}
+	return azAsE(t, c.configDir, args...)
+}
+
+// azAsE runs an Azure CLI command with the login kept in configDir
+func azAsE(t *testing.T, configDir string, args ...string) (string, error) {
+	return shell.RunCommandAndGetStdOutE(t, shell.Command{
+		Command: "az",
+		Args:    append(args, "--only-show-errors"),
+		Env:     map[string]string{"AZURE_CONFIG_DIR": configDir},
+		Logger:  RedactingLogger,
+	})
+}
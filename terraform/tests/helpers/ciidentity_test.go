@@ -0,0 +1,104 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getenvFrom reads variables from env, as os.Getenv would
+func getenvFrom(env map[string]string) func(string) string {
+	return func(name string) string { return env[name] }
+}
+
+func TestFederatedTokenSourceFromEnvironment(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for name, env := range map[string]map[string]string{
+		"ARM_OIDC_TOKEN":           {"ARM_OIDC_TOKEN": "from-file", "ARM_OIDC_TOKEN_FILE_PATH": "/nonexistent"},
+		"ARM_OIDC_TOKEN_FILE_PATH": {"ARM_OIDC_TOKEN_FILE_PATH": path},
+	} {
+		source, err := FederatedTokenSourceE(getenvFrom(env))
+		if assert.NoError(t, err, name) {
+			token, err := source()
+			assert.NoError(t, err, name)
+			assert.Equal(t, "from-file", token, name)
+		}
+	}
+
+	_, err := FederatedTokenSourceE(getenvFrom(nil))
+	assert.Error(t, err, "a run outside CI without a token has no CI identity")
+}
+
+func TestFederatedTokenSourceFromAzurePipelines(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer system-token" ||
+			r.URL.Query().Get("serviceConnectionId") != "connection" || r.URL.Query().Get("api-version") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"oidcToken":"from-ado"}`))
+	}))
+	defer server.Close()
+
+	source, err := FederatedTokenSourceE(getenvFrom(map[string]string{
+		"SYSTEM_OIDCREQUESTURI":                  server.URL + "/oidctoken",
+		"SYSTEM_ACCESSTOKEN":                     "system-token",
+		"ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID": "connection",
+	}))
+	if assert.NoError(t, err) {
+		token, err := source()
+		assert.NoError(t, err)
+		assert.Equal(t, "from-ado", token)
+	}
+}
+
+func TestFederatedTokenSourceFromGitHubActions(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != federatedTokenAudience ||
+			r.URL.Query().Get("existing") != "kept" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"value":"from-github"}`))
+	}))
+	defer server.Close()
+
+	source, err := FederatedTokenSourceE(getenvFrom(map[string]string{
+		"ACTIONS_ID_TOKEN_REQUEST_URL":   server.URL + "/token?existing=kept",
+		"ACTIONS_ID_TOKEN_REQUEST_TOKEN": "request-token",
+	}))
+	if assert.NoError(t, err) {
+		token, err := source()
+		assert.NoError(t, err)
+		assert.Equal(t, "from-github", token)
+	}
+
+	source, err = FederatedTokenSourceE(getenvFrom(map[string]string{
+		"ACTIONS_ID_TOKEN_REQUEST_URL":   server.URL + "/token",
+		"ACTIONS_ID_TOKEN_REQUEST_TOKEN": "wrong",
+	}))
+	if assert.NoError(t, err) {
+		_, err := source()
+		assert.ErrorContains(t, err, "401")
+	}
+}
+
+func TestCIIdentityMissing(t *testing.T) {
+	t.Setenv("TEST_CI_CLIENT_ID", "")
+
+	_, err := CIIdentityE(t, "tenant", "subscription")
+	assert.ErrorIs(t, err, errNoCIIdentity)
+}
@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits. Helpers that time out, poll or stamp
+// resources take one, so unit tests can drive them with a FakeClock instead
+// of waiting for real
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the real clock
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// FakeClock is a Clock that only moves when told to: Sleep advances it at
+// once instead of blocking. It is safe for concurrent use
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+	// slept is the total time passed to Sleep
+	slept time.Duration
+}
+
+// NewFakeClock returns a FakeClock set to now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time the clock is set to
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep advances the clock by d without blocking
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.slept += d
+}
+
+// Advance moves the clock forward by d, as time passing outside a Sleep
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Slept returns the total time passed to Sleep
+func (c *FakeClock) Slept() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slept
+}
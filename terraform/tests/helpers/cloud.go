@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	runCloudOnce sync.Once
+	runCloudErr  error
+)
+
+// useCloudE points the tests at cloud, where ARM_ENVIRONMENT alone does not:
+// terratest's Azure SDK clients read AZURE_ENVIRONMENT, which is set to the
+// cloud's SDKName, and the Azure CLI (`az rest`, its tokens) uses the cloud
+// it was set to with `az cloud set`, which must be the same. An
+// AZURE_ENVIRONMENT read with getenv naming another cloud is an error
+func useCloudE(cloud Cloud, getenv func(string) string, az func(args ...string) ([]byte, error)) error {
+	if sdkName := getenv("AZURE_ENVIRONMENT"); sdkName != "" && !strings.EqualFold(sdkName, cloud.SDKName) {
+		return fmt.Errorf("ARM_ENVIRONMENT is %s, but AZURE_ENVIRONMENT is %s: unset it, or set it to %s", cloud.Name, sdkName, cloud.SDKName)
+	}
+
+	output, err := az("cloud", "show", "--query", "name")
+	if err != nil {
+		return fmt.Errorf("reading the cloud of the Azure CLI: %w", err)
+	}
+	var cliName string
+	if err := json.Unmarshal(output, &cliName); err != nil {
+		return fmt.Errorf("decoding the cloud of the Azure CLI: %w", err)
+	}
+	if !strings.EqualFold(cliName, cloud.CLIName) {
+		return fmt.Errorf("the Azure CLI is set to %s, but ARM_ENVIRONMENT is %s: run az cloud set --name %s, then az login",
+			cliName, cloud.Name, cloud.CLIName)
+	}
+	return os.Setenv("AZURE_ENVIRONMENT", cloud.SDKName)
+}
+
+// RunCloud returns the cloud the run deploys to (CurrentCloud). The first
+// call points the Azure SDK at it and checks the Azure CLI is set to it (see
+// useCloudE), failing the test when it is not
+func RunCloud(t *testing.T) Cloud {
+	cloud := CurrentCloud(t)
+	runCloudOnce.Do(func() {
+		runCloudErr = useCloudE(cloud, os.Getenv, azJSON)
+	})
+	if runCloudErr != nil {
+		t.Fatalf("Azure cloud: %v", runCloudErr)
+	}
+	return cloud
+}
+
+// terraformCloudEnv returns the variables that point terraform's azurerm
+// provider at cloud, for terraform.Options.EnvVars
+func terraformCloudEnv(cloud Cloud) map[string]string {
+	return map[string]string{"ARM_ENVIRONMENT": cloud.Name}
+}
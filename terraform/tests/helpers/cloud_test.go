@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAzCloud answers `az cloud show` as a CLI set to cliName
+func fakeAzCloud(cliName string) func(args ...string) ([]byte, error) {
+	return func(args ...string) ([]byte, error) {
+		if strings.Join(args, " ") != "cloud show --query name" {
+			return nil, errors.New("unexpected az call")
+		}
+		return []byte(`"` + cliName + `"`), nil
+	}
+}
+
+func TestCloudsAreComplete(t *testing.T) {
+	t.Parallel()
+
+	for _, cloud := range []Cloud{PublicCloud, USGovernmentCloud, ChinaCloud} {
+		value := reflect.ValueOf(cloud)
+		for i := 0; i < value.NumField(); i++ {
+			assert.False(t, value.Field(i).IsZero(), "%s has no %s", cloud.Name, value.Type().Field(i).Name)
+		}
+		assert.True(t, strings.HasPrefix(cloud.ResourceManager, "https://") && !strings.HasSuffix(cloud.ResourceManager, "/"),
+			"%s Resource Manager endpoint should be an https origin without a trailing slash", cloud.Name)
+	}
+}
+
+// TestUseCloud sets AZURE_ENVIRONMENT, so it does not run in parallel
+func TestUseCloud(t *testing.T) {
+	t.Setenv("AZURE_ENVIRONMENT", "")
+
+	err := useCloudE(USGovernmentCloud, getenvFrom(nil), fakeAzCloud("AzureUSGovernment"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "AzureUSGovernmentCloud", os.Getenv("AZURE_ENVIRONMENT"), "terratest's SDK clients should target the cloud")
+	}
+
+	err = useCloudE(ChinaCloud, getenvFrom(nil), fakeAzCloud("AzureCloud"))
+	if assert.Error(t, err, "a CLI signed in to another cloud would send az rest calls there") {
+		assert.Contains(t, err.Error(), "az cloud set --name AzureChinaCloud")
+	}
+
+	err = useCloudE(USGovernmentCloud, getenvFrom(map[string]string{"AZURE_ENVIRONMENT": "AzurePublicCloud"}), fakeAzCloud("AzureUSGovernment"))
+	assert.Error(t, err, "AZURE_ENVIRONMENT naming another cloud than ARM_ENVIRONMENT")
+}
+
+func TestTerraformCloudEnv(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, map[string]string{"ARM_ENVIRONMENT": "china"}, terraformCloudEnv(ChinaCloud))
+}
@@ -0,0 +1,130 @@
+package helpers
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// ResourceCombination is a CPU and memory pairing a container may request,
+// and the ephemeral storage Container Apps gives a container of that size
+type ResourceCombination struct {
+	CPU    float64
+	Memory string
+	// EphemeralStorage is not configurable: it follows from the CPU
+	EphemeralStorage string
+}
+
+// ConsumptionResourceCombinations are the CPU and memory pairings of the
+// Consumption workload profile, 0.5Gi of memory and 1Gi of ephemeral storage
+// per 0.25 vCPU. The container-app module holds the memory pairings in
+// local.consumption_resource_combinations and rejects a replica whose
+// containers add up to any other pairing
+var ConsumptionResourceCombinations = []ResourceCombination{
+	{0.25, "0.5Gi", "1Gi"},
+	{0.5, "1Gi", "2Gi"},
+	{0.75, "1.5Gi", "3Gi"},
+	{1.0, "2Gi", "4Gi"},
+	{1.25, "2.5Gi", "5Gi"},
+	{1.5, "3Gi", "6Gi"},
+	{1.75, "3.5Gi", "7Gi"},
+	{2.0, "4Gi", "8Gi"},
+}
+
+// IsConsumptionCombination reports whether cpu and memory are one of the
+// ConsumptionResourceCombinations
+func IsConsumptionCombination(cpu float64, memory string) bool {
+	for _, combination := range ConsumptionResourceCombinations {
+		if combination.CPU == cpu && combination.Memory == memory {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainerResources is the CPU and memory one container of a replica requests
+type ContainerResources struct {
+	CPU    float64
+	Memory string
+}
+
+// IsConsumptionReplica reports whether containers, the main container and
+// sidecars of a replica, add up to one of the ConsumptionResourceCombinations.
+// Azure only checks the total: a container's own CPU and memory need not pair
+func IsConsumptionReplica(containers []ContainerResources) bool {
+	var cpu, memoryGi float64
+	for _, container := range containers {
+		containerMemoryGi, err := MemoryGi(container.Memory)
+		if err != nil {
+			return false
+		}
+		cpu += container.CPU
+		memoryGi += containerMemoryGi
+	}
+	return IsConsumptionCombination(cpu, strconv.FormatFloat(memoryGi, 'f', -1, 64)+"Gi")
+}
+
+// ConsumptionEphemeralStorage returns the ephemeral storage of a Consumption
+// container with cpu vCPU, e.g. "2Gi" for 0.5, and false when cpu is not one
+// of the ConsumptionResourceCombinations
+func ConsumptionEphemeralStorage(cpu float64) (string, bool) {
+	for _, combination := range ConsumptionResourceCombinations {
+		if combination.CPU == cpu {
+			return combination.EphemeralStorage, true
+		}
+	}
+	return "", false
+}
+
+// MemoryGi parses a memory size given in Gi, such as "1.5Gi"
+func MemoryGi(memory string) (float64, error) {
+	if !strings.HasSuffix(memory, "Gi") {
+		return 0, fmt.Errorf("memory %q is not given in Gi", memory)
+	}
+	return strconv.ParseFloat(strings.TrimSuffix(memory, "Gi"), 64)
+}
+
+// ModuleResourceCombinationsE reads local.consumption_resource_combinations
+// from main.tf of the container-app module in moduleDir, mapping vCPU to
+// memory in Gi, so tests can check it against ConsumptionResourceCombinations
+func ModuleResourceCombinationsE(moduleDir string) (map[float64]float64, error) {
+	file := filepath.Join(moduleDir, "main.tf")
+	parsed, diags := hclparse.NewParser().ParseHCLFile(file)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body, ok := parsed.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("%s is not native HCL syntax", file)
+	}
+
+	for _, block := range body.Blocks {
+		if block.Type != "locals" {
+			continue
+		}
+		attribute, exists := block.Body.Attributes["consumption_resource_combinations"]
+		if !exists {
+			continue
+		}
+		value, diags := attribute.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("consumption_resource_combinations in %s is not a literal: %w", file, diags)
+		}
+
+		combinations := map[float64]float64{}
+		for key, memory := range value.AsValueMap() {
+			cpu, err := strconv.ParseFloat(key, 64)
+			if err != nil {
+				return nil, fmt.Errorf("consumption_resource_combinations in %s: %w", file, err)
+			}
+			memoryGi, _ := memory.AsBigFloat().Float64()
+			combinations[cpu] = memoryGi
+		}
+		return combinations, nil
+	}
+	return nil, fmt.Errorf("%s has no local consumption_resource_combinations", file)
+}
@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsConsumptionCombination(t *testing.T) {
+	t.Parallel()
+
+	for _, combination := range ConsumptionResourceCombinations {
+		memoryGi, err := MemoryGi(combination.Memory)
+		if assert.NoError(t, err) {
+			assert.Equal(t, combination.CPU*2, memoryGi, "Consumption pairs 0.5Gi per 0.25 vCPU")
+		}
+		assert.True(t, IsConsumptionCombination(combination.CPU, combination.Memory))
+	}
+	assert.Len(t, ConsumptionResourceCombinations, 8)
+
+	assert.False(t, IsConsumptionCombination(0.25, "4Gi"))
+	assert.False(t, IsConsumptionCombination(0.5, "0.5Gi"))
+	assert.False(t, IsConsumptionCombination(0.5, "1.0Gi"), "memory should be given as the module expects it")
+	assert.False(t, IsConsumptionCombination(2.5, "5Gi"))
+}
+
+func TestIsConsumptionReplica(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, IsConsumptionReplica([]ContainerResources{{0.5, "1Gi"}}))
+	assert.True(t, IsConsumptionReplica([]ContainerResources{{0.5, "1Gi"}, {0.25, "0.5Gi"}}))
+	assert.True(t, IsConsumptionReplica([]ContainerResources{{0.75, "1Gi"}, {0.25, "1Gi"}}), "only the total needs to pair")
+	assert.True(t, IsConsumptionReplica([]ContainerResources{{1.5, "2.5Gi"}, {0.25, "1Gi"}}))
+
+	assert.False(t, IsConsumptionReplica([]ContainerResources{{0.5, "2Gi"}}))
+	assert.False(t, IsConsumptionReplica([]ContainerResources{{0.5, "1Gi"}, {0.25, "1Gi"}}))
+	assert.False(t, IsConsumptionReplica([]ContainerResources{{1.5, "3Gi"}, {1.0, "2Gi"}}), "the total is past 2 vCPU / 4Gi")
+	assert.False(t, IsConsumptionReplica([]ContainerResources{{0.25, "512Mi"}}))
+	assert.False(t, IsConsumptionReplica(nil))
+}
+
+func TestConsumptionEphemeralStorage(t *testing.T) {
+	t.Parallel()
+
+	for _, combination := range ConsumptionResourceCombinations {
+		storage, ok := ConsumptionEphemeralStorage(combination.CPU)
+		storageGi, err := MemoryGi(storage)
+		if assert.True(t, ok) && assert.NoError(t, err) {
+			assert.Equal(t, combination.CPU*4, storageGi, "Consumption gives 1Gi of ephemeral storage per 0.25 vCPU")
+		}
+	}
+
+	_, ok := ConsumptionEphemeralStorage(3)
+	assert.False(t, ok)
+}
+
+func TestMemoryGi(t *testing.T) {
+	t.Parallel()
+
+	memoryGi, err := MemoryGi("1.5Gi")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, memoryGi)
+
+	_, err = MemoryGi("512Mi")
+	assert.Error(t, err)
+	_, err = MemoryGi("Gi")
+	assert.Error(t, err)
+}
+
+func TestModuleResourceCombinations(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	mainTf := `
+locals {
+  other = "value"
+}
+
+locals {
+  consumption_resource_combinations = {
+    "0.25" = 0.5
+    "1.00" = 2
+  }
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(mainTf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	combinations, err := ModuleResourceCombinationsE(dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[float64]float64{0.25: 0.5, 1.0: 2}, combinations)
+	}
+
+	missing := t.TempDir()
+	if err := os.WriteFile(filepath.Join(missing, "main.tf"), []byte("locals {\n  other = 1\n}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ModuleResourceCombinationsE(missing)
+	assert.ErrorContains(t, err, "no local consumption_resource_combinations")
+}
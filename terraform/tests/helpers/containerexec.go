@@ -0,0 +1,226 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"golang.org/x/net/websocket"
+)
+
+// Container Apps exec protocol, as spoken by `az containerapp exec`: every
+// message starts with a type byte and a channel byte. Input is type 0 on
+// channel 0; output from the replica is type 1 on channel 1 (stdout) or 2
+// (stderr)
+const (
+	execInputType  = 0
+	execOutputType = 1
+	execStdout     = 1
+	execStderr     = 2
+)
+
+// execShell is started in the replica; the image must ship it
+const execShell = "/bin/sh"
+
+// execTimeout bounds a whole exec session, from connecting to the shell exiting
+const execTimeout = 2 * time.Minute
+
+// containerAppAPIVersion is the Microsoft.App API version used for auth tokens
+const containerAppAPIVersion = "2024-03-01"
+
+// ExecResult is the outcome of a command run inside a Container App replica
+type ExecResult struct {
+	Revision string
+	Replica  string
+	// Output is stdout and stderr of the command, interleaved as a terminal
+	// shows them, with CRLF line endings normalized
+	Output   string
+	ExitCode int
+}
+
+// execTarget is the replica and container a command runs in
+type execTarget struct {
+	appID     string
+	revision  string
+	replica   string
+	container string
+	// proxyHost serves the exec websocket for the replica's region
+	proxyHost string
+}
+
+// execTargetE picks the first running replica of the latest revision of a
+// Container App and its first container
+func execTargetE(t *testing.T, resourceGroupName, appName string) (*execTarget, error) {
+	var app struct {
+		ID       string `json:"id"`
+		Revision string `json:"revision"`
+	}
+	if err := AzCLIJSONE(t, &app, "containerapp", "show", "--resource-group", resourceGroupName, "--name", appName,
+		"--query", "{id:id, revision:properties.latestRevisionName}"); err != nil {
+		return nil, fmt.Errorf("reading container app %s: %w", appName, err)
+	}
+
+	var replicas []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			RunningState string `json:"runningState"`
+			Containers   []struct {
+				Name              string `json:"name"`
+				LogStreamEndpoint string `json:"logStreamEndpoint"`
+			} `json:"containers"`
+		} `json:"properties"`
+	}
+	if err := AzCLIJSONE(t, &replicas, "containerapp", "replica", "list", "--resource-group", resourceGroupName,
+		"--name", appName, "--revision", app.Revision); err != nil {
+		return nil, fmt.Errorf("listing replicas of %s: %w", app.Revision, err)
+	}
+
+	for _, replica := range replicas {
+		if !strings.EqualFold(replica.Properties.RunningState, "Running") || len(replica.Properties.Containers) == 0 {
+			continue
+		}
+		container := replica.Properties.Containers[0]
+		endpoint, err := url.Parse(container.LogStreamEndpoint)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("replica %s has no usable log stream endpoint %q", replica.Name, container.LogStreamEndpoint)
+		}
+		return &execTarget{
+			appID:     app.ID,
+			revision:  app.Revision,
+			replica:   replica.Name,
+			container: container.Name,
+			proxyHost: endpoint.Host,
+		}, nil
+	}
+	return nil, fmt.Errorf("revision %s of %s has no running replica", app.Revision, appName)
+}
+
+// url is the exec websocket URL that starts command in the target container
+func (e *execTarget) url(command string) string {
+	return fmt.Sprintf("wss://%s%s/revisions/%s/replicas/%s/containers/%s/exec?command=%s",
+		e.proxyHost, e.appID, e.revision, e.replica, e.container, url.QueryEscape(command))
+}
+
+// execScript wraps command so its output can be told apart from the echoed
+// input and prompts of the terminal. The markers are printed in two halves
+// so the echoed script itself never contains them, and command runs in a
+// subshell so an exit in it still reaches the end marker
+func execScript(command, id string) string {
+	return fmt.Sprintf("printf '%%s%%s\\n' __EXEC_BEGIN_ %s__; (\n%s\n)\nprintf '\\n%%s%%s%%d\\n' __EXEC_END_ %s__ $?; exit\n",
+		id, command, id)
+}
+
+// parseExecOutput extracts the output and exit code of the script built by
+// execScript from the terminal output of the session
+func parseExecOutput(terminal, id string) (string, int, error) {
+	terminal = strings.ReplaceAll(terminal, "\r\n", "\n")
+	begin := "__EXEC_BEGIN_" + id + "__\n"
+	start := strings.Index(terminal, begin)
+	if start < 0 {
+		return "", 0, errors.New("command did not start; the shell may not exist in the image")
+	}
+	output := terminal[start+len(begin):]
+
+	end := regexp.MustCompile(`\n__EXEC_END_` + regexp.QuoteMeta(id) + `__(\d+)\n`).FindStringSubmatchIndex(output)
+	if end == nil {
+		return "", 0, errors.New("command did not finish before the session closed")
+	}
+	exitCode, _ := strconv.Atoi(output[end[2]:end[3]])
+	return output[:end[0]], exitCode, nil
+}
+
+// decodeExecMessage returns the output payload of a message from the replica,
+// or ok == false for messages that carry no command output
+func decodeExecMessage(message []byte) (payload []byte, ok bool) {
+	if len(message) < 2 || message[0] != execOutputType {
+		return nil, false
+	}
+	if message[1] != execStdout && message[1] != execStderr {
+		return nil, false
+	}
+	return message[2:], true
+}
+
+// ContainerAppExecE runs command with /bin/sh inside a running replica of the
+// latest revision of a Container App, through the same exec API as
+// `az containerapp exec`, and returns its output and exit code. It lets tests
+// assert on the runtime environment (env, files, localhost) that ingress
+// probing can't see. The image must ship /bin/sh, so it does not work with
+// the shell-less fixture images
+func ContainerAppExecE(t *testing.T, resourceGroupName, appName, command string) (*ExecResult, error) {
+	target, err := execTargetE(t, resourceGroupName, appName)
+	if err != nil {
+		return nil, err
+	}
+
+	var token struct {
+		Properties struct {
+			Token string `json:"token"`
+		} `json:"properties"`
+	}
+	if err := AzCLIJSONE(t, &token, "rest", "--method", "post", "--url",
+		fmt.Sprintf("%s/getAuthToken?api-version=%s", target.appID, containerAppAPIVersion)); err != nil {
+		return nil, fmt.Errorf("getting exec token for %s: %w", appName, err)
+	}
+
+	config, err := websocket.NewConfig(target.url(execShell), "https://"+target.proxyHost)
+	if err != nil {
+		return nil, err
+	}
+	config.Header.Set("Authorization", "Bearer "+token.Properties.Token)
+
+	t.Logf("Running %q in replica %s of %s", command, target.replica, appName)
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to replica %s: %w", target.replica, err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(execTimeout)); err != nil {
+		return nil, err
+	}
+
+	id := strings.ToLower(random.UniqueId())
+	input := string([]byte{execInputType, 0}) + execScript(command, id)
+	if err := websocket.Message.Send(conn, input); err != nil {
+		return nil, fmt.Errorf("sending command to replica %s: %w", target.replica, err)
+	}
+
+	// The session ends when the shell exits and the proxy closes the socket
+	var terminal bytes.Buffer
+	for {
+		var message []byte
+		if err := websocket.Message.Receive(conn, &message); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("reading from replica %s: %w", target.replica, err)
+		}
+		if payload, ok := decodeExecMessage(message); ok {
+			terminal.Write(payload)
+		}
+	}
+
+	output, exitCode, err := parseExecOutput(terminal.String(), id)
+	if err != nil {
+		return nil, fmt.Errorf("running %q in replica %s: %w", command, target.replica, err)
+	}
+	return &ExecResult{Revision: target.revision, Replica: target.replica, Output: output, ExitCode: exitCode}, nil
+}
+
+// ContainerAppExec runs command inside a Container App replica and fails the
+// test if the session fails. A non-zero exit code is returned, not failed
+func ContainerAppExec(t *testing.T, resourceGroupName, appName, command string) *ExecResult {
+	result, err := ContainerAppExecE(t, resourceGroupName, appName, command)
+	if err != nil {
+		t.Fatalf("Exec in %s failed: %v", appName, err)
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecScript(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		command  string
+		output   string
+		exitCode int
+	}{
+		{"output", "echo hello; echo world >&2", "hello\nworld\n", 0},
+		{"no trailing newline", "printf abc", "abc", 0},
+		{"failure", "echo failing; exit 3", "failing\n", 3},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// A terminal echoes the script before running it, so the echo
+			// must not be mistaken for the markers
+			script := execScript(tc.command, "abc123")
+			shell := exec.Command("sh")
+			shell.Stdin = strings.NewReader(script)
+			stdout, err := shell.CombinedOutput()
+			if tc.exitCode == 0 {
+				assert.NoError(t, err)
+			}
+			terminal := strings.ReplaceAll(script+string(stdout), "\n", "\r\n")
+
+			output, exitCode, err := parseExecOutput(terminal, "abc123")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.output, output)
+			assert.Equal(t, tc.exitCode, exitCode)
+		})
+	}
+}
+
+func TestParseExecOutputIncomplete(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := parseExecOutput("sh: not found\r\n", "abc123")
+	assert.Error(t, err, "Missing begin marker")
+
+	_, _, err = parseExecOutput("__EXEC_BEGIN_abc123__\r\npartial", "abc123")
+	assert.Error(t, err, "Missing end marker")
+}
+
+func TestDecodeExecMessage(t *testing.T) {
+	t.Parallel()
+
+	payload, ok := decodeExecMessage([]byte{1, 1, 'o', 'k'})
+	assert.True(t, ok)
+	assert.Equal(t, "ok", string(payload))
+
+	payload, ok = decodeExecMessage([]byte{1, 2, 'e'})
+	assert.True(t, ok)
+	assert.Equal(t, "e", string(payload))
+
+	_, ok = decodeExecMessage([]byte{1, 4, '{', '}'})
+	assert.False(t, ok, "Control channel carries no output")
+	_, ok = decodeExecMessage([]byte{0})
+	assert.False(t, ok, "Short message")
+}
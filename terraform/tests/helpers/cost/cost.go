@@ -0,0 +1,256 @@
+// Package cost records what test runs actually spend, from the Azure Cost
+// Management API. Resources created with helpers.StandardTags carry the run
+// (helpers.RunIDTag) and the test (TestName) that created them, so the spend
+// of a test is the cost of everything tagged with both.
+//
+// Cost Management ingests usage hours after the fact, typically 8 to 24, so
+// the spend a test records at teardown is what was billed by then: a floor
+// that rises as the day's usage lands. QuerySpendE can be run again later
+// for the full figure.
+package cost
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+const (
+	// queryAPIVersion is the Cost Management query API version
+	queryAPIVersion = "2023-03-01"
+
+	// spendReport holds the actual spend of every tracked test
+	spendReport = "actual_spend"
+	// summaryReport holds the actual spend of every tracked suite
+	summaryReport = "cost_summary"
+
+	// testNameTag is the tag helpers.StandardTags puts the test name in
+	testNameTag = "TestName"
+)
+
+// Cost Management allows a few queries a minute per scope, which parallel
+// teardowns can exceed
+var throttledErrors = map[string]string{
+	".*(429|TooManyRequests).*": "Cost Management throttled the query, retrying",
+}
+
+// reportMu serializes recording a test's spend with summarizing the report
+var reportMu sync.Mutex
+
+// Spend is what a test spent in a run, as Cost Management knew it when
+// queried
+type Spend struct {
+	RunID    string    `json:"run_id"`
+	Test     string    `json:"test"`
+	Cost     float64   `json:"cost"`
+	Currency string    `json:"currency,omitempty"`
+	From     time.Time `json:"from"`
+	Queried  time.Time `json:"queried_at"`
+}
+
+// SuiteSpend is what the tests of a suite, a top-level test and its
+// subtests, spent in a run
+type SuiteSpend struct {
+	Cost     float64 `json:"cost"`
+	Currency string  `json:"currency,omitempty"`
+	Tests    int     `json:"tests"`
+}
+
+// queryBodyE returns the Cost Management query for the actual cost of the
+// resources tagged with runID and testName between from and to
+func queryBodyE(runID, testName string, from, to time.Time) ([]byte, error) {
+	tagFilter := func(name, value string) map[string]interface{} {
+		return map[string]interface{}{
+			"tags": map[string]interface{}{"name": name, "operator": "In", "values": []string{value}},
+		}
+	}
+	return json.Marshal(map[string]interface{}{
+		"type":      "ActualCost",
+		"timeframe": "Custom",
+		"timePeriod": map[string]string{
+			"from": from.UTC().Format(time.RFC3339),
+			"to":   to.UTC().Format(time.RFC3339),
+		},
+		"dataset": map[string]interface{}{
+			"granularity": "None",
+			"aggregation": map[string]interface{}{
+				"totalCost": map[string]string{"name": "Cost", "function": "Sum"},
+			},
+			"filter": map[string]interface{}{
+				"and": []interface{}{tagFilter(helpers.RunIDTag, runID), tagFilter(testNameTag, testName)},
+			},
+		},
+	})
+}
+
+// queryResult is the part of a Cost Management query response the spend is
+// read from: a table of rows with the named columns
+type queryResult struct {
+	Properties struct {
+		Columns []struct {
+			Name string `json:"name"`
+		} `json:"columns"`
+		Rows [][]interface{} `json:"rows"`
+	} `json:"properties"`
+}
+
+// parseSpendE adds up the Cost column of a query result. No rows is no
+// spend; rows in more than one currency cannot be added up
+func parseSpendE(result queryResult) (float64, string, error) {
+	costColumn, currencyColumn := -1, -1
+	for i, column := range result.Properties.Columns {
+		switch column.Name {
+		case "Cost", "PreTaxCost":
+			costColumn = i
+		case "Currency":
+			currencyColumn = i
+		}
+	}
+	if len(result.Properties.Rows) == 0 {
+		return 0, "", nil
+	}
+	if costColumn < 0 {
+		return 0, "", errors.New("no cost column in the query result")
+	}
+
+	total, currency := 0.0, ""
+	for _, row := range result.Properties.Rows {
+		if costColumn >= len(row) {
+			return 0, "", fmt.Errorf("row %v has no cost column", row)
+		}
+		cost, ok := row[costColumn].(float64)
+		if !ok {
+			return 0, "", fmt.Errorf("cost %v is not a number", row[costColumn])
+		}
+		total += cost
+		if currencyColumn >= 0 && currencyColumn < len(row) {
+			rowCurrency := fmt.Sprint(row[currencyColumn])
+			if currency != "" && rowCurrency != currency {
+				return 0, "", fmt.Errorf("costs in both %s and %s", currency, rowCurrency)
+			}
+			currency = rowCurrency
+		}
+	}
+	return total, currency, nil
+}
+
+// QuerySpendE asks Cost Management what the resources of testName in runID
+// cost in subscriptionID between from and to
+func QuerySpendE(t *testing.T, subscriptionID, runID, testName string, from, to time.Time) (*Spend, error) {
+	body, err := queryBodyE(runID, testName, from, to)
+	if err != nil {
+		return nil, err
+	}
+	// az rest puts the Resource Manager endpoint of its cloud before a path
+	url := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.CostManagement/query?api-version=%s",
+		subscriptionID, queryAPIVersion)
+
+	var result queryResult
+	_, err = retry.DoWithRetryableErrorsE(t, "querying the spend of "+testName, throttledErrors, 3, 30*time.Second, func() (string, error) {
+		return "", helpers.AzCLIJSONE(t, &result, "rest", "--method", "post", "--url", url, "--body", string(body))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying Cost Management: %w", err)
+	}
+	cost, currency, err := parseSpendE(result)
+	if err != nil {
+		return nil, err
+	}
+	return &Spend{RunID: runID, Test: testName, Cost: cost, Currency: currency, From: from, Queried: to}, nil
+}
+
+// Suite returns the suite of a test: the top-level test it belongs to
+func Suite(testName string) string {
+	return strings.SplitN(testName, "/", 2)[0]
+}
+
+// Summarize adds up spends by suite. A suite spending in more than one
+// currency has its currency left out, since its total mixes them
+func Summarize(spends map[string]Spend) map[string]SuiteSpend {
+	suites := map[string]SuiteSpend{}
+	currencies := map[string]map[string]bool{}
+	for _, spend := range spends {
+		name := Suite(spend.Test)
+		suite := suites[name]
+		suite.Cost += spend.Cost
+		suite.Tests++
+		suites[name] = suite
+		if spend.Currency != "" {
+			if currencies[name] == nil {
+				currencies[name] = map[string]bool{}
+			}
+			currencies[name][spend.Currency] = true
+		}
+	}
+	for name, seen := range currencies {
+		if len(seen) != 1 {
+			continue
+		}
+		suite := suites[name]
+		for currency := range seen {
+			suite.Currency = currency
+		}
+		suites[name] = suite
+	}
+	return suites
+}
+
+// recordSpendE records spend in the actual_spend report and brings the
+// cost_summary report up to date with it
+func recordSpendE(spend *Spend) error {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	if err := helpers.RecordReportE(spendReport, spend.Test, spend); err != nil {
+		return err
+	}
+	content, err := os.ReadFile(helpers.ReportPath(spendReport))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	spends := map[string]Spend{}
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &spends); err != nil {
+			return fmt.Errorf("decoding %s: %w", helpers.ReportPath(spendReport), err)
+		}
+	}
+	for suite, total := range Summarize(spends) {
+		if err := helpers.RecordReportE(summaryReport, suite, total); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TrackRun records what t actually spent once it has torn down: the cost
+// Cost Management bills to resources tagged with the run and t's name,
+// since the start of the day t started, in the actual_spend report, and its
+// suite's total in the cost_summary report. Call it after NewTestConfig;
+// its cleanup runs after t's deferred destroys. Failing to read the spend is
+// logged, not failed, so it never changes the outcome of the test
+func TrackRun(t *testing.T, cfg *helpers.TestConfig) {
+	started := time.Now().UTC().Truncate(24 * time.Hour)
+	t.Cleanup(func() {
+		if t.Skipped() {
+			return
+		}
+		spend, err := QuerySpendE(t, cfg.SubscriptionID, helpers.RunID(), t.Name(), started, time.Now().UTC())
+		if err != nil {
+			t.Logf("Could not read the actual spend of %s: %v", t.Name(), err)
+			return
+		}
+		t.Logf("%s spent %.2f %s so far (Cost Management lags usage by hours)", t.Name(), spend.Cost, spend.Currency)
+		if err := recordSpendE(spend); err != nil {
+			t.Logf("Recording the actual spend of %s: %v", t.Name(), err)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package cost
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryBodyE(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	body, err := queryBodyE("run-42", "TestVault/secrets", from, from.Add(26*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var query struct {
+		Type       string            `json:"type"`
+		TimePeriod map[string]string `json:"timePeriod"`
+		Dataset    struct {
+			Filter struct {
+				And []struct {
+					Tags struct {
+						Name   string   `json:"name"`
+						Values []string `json:"values"`
+					} `json:"tags"`
+				} `json:"and"`
+			} `json:"filter"`
+		} `json:"dataset"`
+	}
+	if assert.NoError(t, json.Unmarshal(body, &query)) {
+		assert.Equal(t, "ActualCost", query.Type)
+		assert.Equal(t, map[string]string{"from": "2026-03-01T00:00:00Z", "to": "2026-03-02T02:00:00Z"}, query.TimePeriod)
+		if assert.Len(t, query.Dataset.Filter.And, 2) {
+			assert.Equal(t, helpers.RunIDTag, query.Dataset.Filter.And[0].Tags.Name)
+			assert.Equal(t, []string{"run-42"}, query.Dataset.Filter.And[0].Tags.Values)
+			assert.Equal(t, "TestName", query.Dataset.Filter.And[1].Tags.Name)
+			assert.Equal(t, []string{"TestVault/secrets"}, query.Dataset.Filter.And[1].Tags.Values)
+		}
+	}
+}
+
+func TestParseSpendE(t *testing.T) {
+	t.Parallel()
+
+	parse := func(response string) (float64, string, error) {
+		var result queryResult
+		if err := json.Unmarshal([]byte(response), &result); err != nil {
+			t.Fatal(err)
+		}
+		return parseSpendE(result)
+	}
+
+	cost, currency, err := parse(`{"properties": {"columns": [{"name": "Cost", "type": "Number"}, {"name": "Currency", "type": "String"}],
+		"rows": [[1.25, "USD"], [0.5, "USD"]]}}`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1.75, cost)
+		assert.Equal(t, "USD", currency)
+	}
+
+	cost, currency, err = parse(`{"properties": {"columns": [{"name": "Cost"}, {"name": "Currency"}], "rows": []}}`)
+	if assert.NoError(t, err, "nothing billed yet is no spend") {
+		assert.Zero(t, cost)
+		assert.Empty(t, currency)
+	}
+
+	_, _, err = parse(`{"properties": {"columns": [{"name": "Cost"}, {"name": "Currency"}], "rows": [[1, "USD"], [1, "EUR"]]}}`)
+	assert.ErrorContains(t, err, "both USD and EUR")
+	_, _, err = parse(`{"properties": {"columns": [{"name": "Currency"}], "rows": [["USD"]]}}`)
+	assert.ErrorContains(t, err, "no cost column")
+}
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "TestVault", Suite("TestVault/secrets/rotation"))
+	assert.Equal(t, map[string]SuiteSpend{
+		"TestVault": {Cost: 3, Currency: "USD", Tests: 2},
+		"TestApp":   {Cost: 2.5, Currency: "", Tests: 2},
+		"TestIdle":  {Cost: 0, Currency: "", Tests: 1},
+	}, Summarize(map[string]Spend{
+		"TestVault":         {Test: "TestVault", Cost: 1, Currency: "USD"},
+		"TestVault/secrets": {Test: "TestVault/secrets", Cost: 2, Currency: "USD"},
+		"TestApp/eastus2":   {Test: "TestApp/eastus2", Cost: 1, Currency: "USD"},
+		"TestApp/westeu":    {Test: "TestApp/westeu", Cost: 1.5, Currency: "EUR"},
+		"TestIdle":          {Test: "TestIdle"},
+	}), "a suite's currency is left out when its tests were billed in several")
+}
+
+func TestRecordSpendE(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(helpers.ReportDir(helpers.RunID())) })
+
+	for _, spend := range []*Spend{
+		{Test: "TestVault", Cost: 1, Currency: "USD"},
+		{Test: "TestVault/secrets", Cost: 0.25, Currency: "USD"},
+	} {
+		if err := recordSpendE(spend); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content, err := os.ReadFile(helpers.ReportPath(summaryReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary map[string]SuiteSpend
+	if assert.NoError(t, json.Unmarshal(content, &summary)) {
+		assert.Equal(t, map[string]SuiteSpend{"TestVault": {Cost: 1.25, Currency: "USD", Tests: 2}}, summary)
+	}
+}
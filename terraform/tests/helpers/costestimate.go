@@ -0,0 +1,290 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// retailPricesURL is the Azure Retail Prices API, which needs no credentials
+const retailPricesURL = "https://prices.azure.com/api/retail/prices"
+
+// costEstimateReport holds the estimates checked by AssertCostBelow
+const costEstimateReport = "cost_estimates"
+
+// hoursPerMonth is the month retail prices are quoted for
+const hoursPerMonth = 730
+
+// errNoRetailPrice is returned for a meter the Retail Prices API has no
+// price for in a region
+var errNoRetailPrice = errors.New("no retail price")
+
+// costMeter is a meter a resource is billed on whatever its usage, and how
+// many of it run at once, e.g. two "Premium Registry Unit" for a Premium
+// registry with one geo-replica
+type costMeter struct {
+	Service  string
+	Meter    string
+	Quantity float64
+}
+
+// dedicatedProfileSizes are the vCPU and memory (GiB) of an instance of each
+// dedicated workload profile type
+var dedicatedProfileSizes = map[string][2]float64{
+	"D4": {4, 16}, "D8": {8, 32}, "D16": {16, 64}, "D32": {32, 128},
+	"E4": {4, 32}, "E8": {8, 64}, "E16": {16, 128}, "E32": {32, 256},
+}
+
+// costMeters returns the standing meters of a planned resource of each type
+// with a cost that accrues from creation, before any traffic. Usage-billed
+// resources (Log Analytics ingestion, Consumption apps, Key Vault
+// operations, ...) are not listed: they cost next to nothing in a test
+var costMeters = map[string]func(values map[string]interface{}) []costMeter{
+	"azurerm_container_registry": func(values map[string]interface{}) []costMeter {
+		replicas, _ := values["georeplications"].([]interface{})
+		return []costMeter{{"Container Registry", fmt.Sprintf("%s Registry Unit", values["sku"]), float64(1 + len(replicas))}}
+	},
+	"azurerm_container_app_environment": func(values map[string]interface{}) []costMeter {
+		var meters []costMeter
+		profiles, _ := values["workload_profile"].([]interface{})
+		for _, profile := range profiles {
+			profile, _ := profile.(map[string]interface{})
+			size, dedicated := dedicatedProfileSizes[fmt.Sprint(profile["workload_profile_type"])]
+			if !dedicated {
+				continue
+			}
+			if len(meters) == 0 {
+				meters = append(meters, costMeter{"Azure Container Apps", "Dedicated Plan Management", 1})
+			}
+			instances, _ := profile["minimum_count"].(float64)
+			meters = append(meters,
+				costMeter{"Azure Container Apps", "Dedicated vCPU Usage", instances * size[0]},
+				costMeter{"Azure Container Apps", "Dedicated Memory Usage", instances * size[1]})
+		}
+		return meters
+	},
+	"azurerm_firewall": func(values map[string]interface{}) []costMeter {
+		return []costMeter{{"Azure Firewall", fmt.Sprintf("%s Deployment", values["sku_tier"]), 1}}
+	},
+	"azurerm_log_analytics_cluster": func(values map[string]interface{}) []costMeter {
+		return []costMeter{{"Log Analytics", fmt.Sprintf("%v GB Commitment Tier Capacity Reservation", values["size_gb"]), 1}}
+	},
+	"azurerm_private_endpoint": func(values map[string]interface{}) []costMeter {
+		return []costMeter{{"Virtual Network", "Standard Private Endpoint", 1}}
+	},
+	"azurerm_public_ip": func(values map[string]interface{}) []costMeter {
+		return []costMeter{{"Virtual Network", fmt.Sprintf("%s IPv4 %s Public IP", values["sku"], values["allocation_method"]), 1}}
+	},
+}
+
+// CostItem is the monthly cost of one standing meter of a planned resource
+type CostItem struct {
+	Address    string  `json:"address"`
+	Meter      string  `json:"meter"`
+	Quantity   float64 `json:"quantity"`
+	MonthlyUSD float64 `json:"monthly_usd"`
+}
+
+// CostEstimate is the standing monthly cost, in USD at retail prices, of the
+// resources a plan creates or keeps
+type CostEstimate struct {
+	MonthlyUSD float64    `json:"monthly_usd"`
+	Items      []CostItem `json:"items"`
+	// Unpriced lists the meters with a standing cost that have no retail
+	// price, so are missing from MonthlyUSD
+	Unpriced []string `json:"unpriced,omitempty"`
+}
+
+// retailPrice is the price of one unit of a meter, e.g. 1.667 per "1/Day"
+type retailPrice struct {
+	Price float64
+	Unit  string
+}
+
+// retailPriceLookup finds the retail price of a meter in a region
+type retailPriceLookup func(region string, meter costMeter) (retailPrice, error)
+
+// monthlyUnits converts a retail price unit to the number of units in a month
+func monthlyUnits(unit string) (float64, error) {
+	switch unit {
+	case "1 Hour", "1/Hour":
+		return hoursPerMonth, nil
+	case "1/Day", "1 Day":
+		return hoursPerMonth / 24.0, nil
+	case "1/Month", "1 Month":
+		return 1, nil
+	}
+	return 0, fmt.Errorf("unsupported price unit %q", unit)
+}
+
+// estimatePlanCostE estimates the standing monthly cost of the resources a
+// plan (JSON from `terraform show -json`) creates or keeps, pricing their
+// meters with lookup
+func estimatePlanCostE(planJSON string, lookup retailPriceLookup) (CostEstimate, error) {
+	var plan struct {
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Mode    string `json:"mode"`
+			Type    string `json:"type"`
+			Change  struct {
+				After map[string]interface{} `json:"after"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal([]byte(planJSON), &plan); err != nil {
+		return CostEstimate{}, fmt.Errorf("decoding plan: %w", err)
+	}
+
+	estimate := CostEstimate{Items: []CostItem{}}
+	for _, change := range plan.ResourceChanges {
+		meters := costMeters[change.Type]
+		// Deleted resources have no values after the change
+		if change.Mode != "managed" || meters == nil || change.Change.After == nil {
+			continue
+		}
+		// Prices are listed by ARM region name, e.g. eastus2
+		region := normalizeRegion(fmt.Sprint(change.Change.After["location"]))
+		for _, meter := range meters(change.Change.After) {
+			if meter.Quantity == 0 {
+				continue
+			}
+			price, err := lookup(region, meter)
+			if errors.Is(err, errNoRetailPrice) {
+				estimate.Unpriced = append(estimate.Unpriced, fmt.Sprintf("%s %q in %s", change.Address, meter.Meter, region))
+				continue
+			}
+			if err != nil {
+				return CostEstimate{}, fmt.Errorf("pricing %s: %w", change.Address, err)
+			}
+			units, err := monthlyUnits(price.Unit)
+			if err != nil {
+				return CostEstimate{}, fmt.Errorf("pricing %s %q: %w", change.Address, meter.Meter, err)
+			}
+			monthly := math.Round(price.Price*units*meter.Quantity*100) / 100
+			estimate.Items = append(estimate.Items, CostItem{
+				Address:    change.Address,
+				Meter:      meter.Meter,
+				Quantity:   meter.Quantity,
+				MonthlyUSD: monthly,
+			})
+			estimate.MonthlyUSD += monthly
+		}
+	}
+	estimate.MonthlyUSD = math.Round(estimate.MonthlyUSD*100) / 100
+	sort.SliceStable(estimate.Items, func(i, j int) bool { return estimate.Items[i].MonthlyUSD > estimate.Items[j].MonthlyUSD })
+	return estimate, nil
+}
+
+// retailPrices caches the prices looked up by this run, by region and meter
+var retailPrices sync.Map
+
+// retailPriceE looks up the pay-as-you-go USD price of a meter in the Azure
+// Retail Prices API. When several products carry the meter, the highest
+// price is taken, so the estimate errs on the expensive side
+func retailPriceE(region string, meter costMeter) (retailPrice, error) {
+	key := strings.Join([]string{region, meter.Service, meter.Meter}, "|")
+	if cached, ok := retailPrices.Load(key); ok {
+		return cached.(retailPrice), nil
+	}
+
+	filter := fmt.Sprintf("serviceName eq '%s' and armRegionName eq '%s' and meterName eq '%s' and priceType eq 'Consumption'",
+		meter.Service, region, meter.Meter)
+	next := retailPricesURL + "?currencyCode='USD'&$filter=" + url.QueryEscape(filter)
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var found *retailPrice
+	for next != "" {
+		response, err := client.Get(next)
+		if err != nil {
+			return retailPrice{}, err
+		}
+		var page struct {
+			Items []struct {
+				RetailPrice      float64 `json:"retailPrice"`
+				UnitOfMeasure    string  `json:"unitOfMeasure"`
+				TierMinimumUnits float64 `json:"tierMinimumUnits"`
+			} `json:"Items"`
+			NextPageLink string `json:"NextPageLink"`
+		}
+		err = json.NewDecoder(response.Body).Decode(&page)
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return retailPrice{}, fmt.Errorf("retail prices API returned %s", response.Status)
+		}
+		if err != nil {
+			return retailPrice{}, fmt.Errorf("decoding retail prices: %w", err)
+		}
+		for _, item := range page.Items {
+			// Higher tiers are volume discounts on usage
+			if item.TierMinimumUnits != 0 {
+				continue
+			}
+			if found == nil || item.RetailPrice > found.Price {
+				found = &retailPrice{Price: item.RetailPrice, Unit: item.UnitOfMeasure}
+			}
+		}
+		next = page.NextPageLink
+	}
+	if found == nil {
+		return retailPrice{}, errNoRetailPrice
+	}
+	retailPrices.Store(key, *found)
+	return *found, nil
+}
+
+// EstimatePlanCostE plans options (see CachedPlanE) and estimates the
+// standing monthly cost of the planned resources at Azure retail prices.
+// Only resources that bill from creation are priced, such as registries,
+// dedicated workload profiles and firewalls; usage-billed resources are not.
+// options is left as it was, so call it before UseIsolatedWorkspace
+func EstimatePlanCostE(t *testing.T, options *terraform.Options) (CostEstimate, error) {
+	// CachedPlanE moves the options it plans onto a copy of the module
+	planOptions := *options
+	planOptions.PlanFilePath = ""
+	planJSON, err := CachedPlanE(t, &planOptions)
+	if err != nil {
+		return CostEstimate{}, err
+	}
+	return estimatePlanCostE(planJSON, retailPriceE)
+}
+
+// EstimatePlanCost is EstimatePlanCostE failing the test on error
+func EstimatePlanCost(t *testing.T, options *terraform.Options) CostEstimate {
+	estimate, err := EstimatePlanCostE(t, options)
+	if err != nil {
+		t.Fatalf("Estimating the cost of %s: %v", options.TerraformDir, err)
+	}
+	return estimate
+}
+
+// AssertCostBelow records estimate in the cost_estimates report and stops
+// the test when it is over limitUSD a month, so a fixture that became more
+// expensive than intended fails before it is applied. Meters without a
+// retail price are logged, as they are missing from the estimate
+func AssertCostBelow(t *testing.T, estimate CostEstimate, limitUSD float64) {
+	RecordReport(t, costEstimateReport, t.Name(), estimate)
+	for _, unpriced := range estimate.Unpriced {
+		t.Logf("No retail price for %s; it is not in the estimate", unpriced)
+	}
+	if estimate.MonthlyUSD <= limitUSD {
+		t.Logf("Estimated standing cost is $%.2f a month, within $%.2f", estimate.MonthlyUSD, limitUSD)
+		return
+	}
+
+	lines := make([]string, 0, len(estimate.Items))
+	for _, item := range estimate.Items {
+		lines = append(lines, fmt.Sprintf("$%.2f %s (%v x %s)", item.MonthlyUSD, item.Address, item.Quantity, item.Meter))
+	}
+	t.Fatalf("Estimated standing cost is $%.2f a month, over the $%.2f limit:\n  %s",
+		estimate.MonthlyUSD, limitUSD, strings.Join(lines, "\n  "))
+}
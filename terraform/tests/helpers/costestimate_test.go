@@ -0,0 +1,112 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// costPlanJSON plans a Premium registry with a geo-replica, a firewall with
+// its public IP, a dedicated D4 workload profile next to Consumption, and a
+// Basic registry being deleted
+const costPlanJSON = `{
+  "resource_changes": [
+    {
+      "address": "module.registry.azurerm_container_registry.this",
+      "mode": "managed",
+      "type": "azurerm_container_registry",
+      "change": {"after": {"location": "East US 2", "sku": "Premium", "georeplications": [{"location": "westus2"}]}}
+    },
+    {
+      "address": "azurerm_firewall.egress[0]",
+      "mode": "managed",
+      "type": "azurerm_firewall",
+      "change": {"after": {"location": "eastus2", "sku_tier": "Standard"}}
+    },
+    {
+      "address": "azurerm_public_ip.firewall[0]",
+      "mode": "managed",
+      "type": "azurerm_public_ip",
+      "change": {"after": {"location": "eastus2", "sku": "Standard", "allocation_method": "Static"}}
+    },
+    {
+      "address": "azurerm_container_app_environment.this",
+      "mode": "managed",
+      "type": "azurerm_container_app_environment",
+      "change": {"after": {"location": "eastus2", "workload_profile": [
+        {"name": "Consumption", "workload_profile_type": "Consumption"},
+        {"name": "d4", "workload_profile_type": "D4", "minimum_count": 1}
+      ]}}
+    },
+    {
+      "address": "azurerm_container_registry.old",
+      "mode": "managed",
+      "type": "azurerm_container_registry",
+      "change": {"after": null}
+    },
+    {
+      "address": "azurerm_log_analytics_workspace.this",
+      "mode": "managed",
+      "type": "azurerm_log_analytics_workspace",
+      "change": {"after": {"location": "eastus2", "sku": "PerGB2018"}}
+    }
+  ]
+}`
+
+func TestEstimatePlanCost(t *testing.T) {
+	t.Parallel()
+
+	prices := map[string]retailPrice{
+		"Premium Registry Unit":          {1.667, "1/Day"},
+		"Standard Deployment":            {1.25, "1 Hour"},
+		"Standard IPv4 Static Public IP": {0.005, "1 Hour"},
+		"Dedicated Plan Management":      {0.1, "1 Hour"},
+		"Dedicated vCPU Usage":           {0.0571, "1 Hour"},
+	}
+	var regions []string
+	estimate, err := estimatePlanCostE(costPlanJSON, func(region string, meter costMeter) (retailPrice, error) {
+		regions = append(regions, region)
+		price, ok := prices[meter.Meter]
+		if !ok {
+			return retailPrice{}, errNoRetailPrice
+		}
+		return price, nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []CostItem{
+		{Address: "azurerm_firewall.egress[0]", Meter: "Standard Deployment", Quantity: 1, MonthlyUSD: 912.5},
+		{Address: "azurerm_container_app_environment.this", Meter: "Dedicated vCPU Usage", Quantity: 4, MonthlyUSD: 166.73},
+		{Address: "module.registry.azurerm_container_registry.this", Meter: "Premium Registry Unit", Quantity: 2, MonthlyUSD: 101.41},
+		{Address: "azurerm_container_app_environment.this", Meter: "Dedicated Plan Management", Quantity: 1, MonthlyUSD: 73},
+		{Address: "azurerm_public_ip.firewall[0]", Meter: "Standard IPv4 Static Public IP", Quantity: 1, MonthlyUSD: 3.65},
+	}, estimate.Items)
+	assert.Equal(t, 1257.29, estimate.MonthlyUSD)
+	assert.Equal(t, []string{`azurerm_container_app_environment.this "Dedicated Memory Usage" in eastus2`}, estimate.Unpriced)
+	for _, region := range regions {
+		assert.Equal(t, "eastus2", region)
+	}
+
+	_, err = estimatePlanCostE(costPlanJSON, func(string, costMeter) (retailPrice, error) {
+		return retailPrice{1, "1 GB"}, nil
+	})
+	assert.EqualError(t, err, `pricing module.registry.azurerm_container_registry.this "Premium Registry Unit": unsupported price unit "1 GB"`)
+
+	_, err = estimatePlanCostE("not json", nil)
+	assert.Error(t, err)
+}
+
+func TestMonthlyUnits(t *testing.T) {
+	t.Parallel()
+
+	for unit, expected := range map[string]float64{"1 Hour": 730, "1/Day": 730 / 24.0, "1/Month": 1} {
+		units, err := monthlyUnits(unit)
+		if assert.NoError(t, err, unit) {
+			assert.Equal(t, expected, units, unit)
+		}
+	}
+	_, err := monthlyUnits("10K")
+	assert.Error(t, err)
+}
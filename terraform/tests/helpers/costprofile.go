@@ -0,0 +1,173 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// costProfileDir holds the golden cost profiles, one file per module
+const costProfileDir = "testdata/cost-profiles"
+
+// freeResourceTypes carry no charge of their own: they configure, connect
+// or authorize resources that are billed elsewhere
+var freeResourceTypes = map[string]bool{
+	"azurerm_container_registry_scope_map":          true,
+	"azurerm_key_vault_access_policy":               true,
+	"azurerm_key_vault_secret":                      true,
+	"azurerm_monitor_diagnostic_setting":            true,
+	"azurerm_private_dns_zone_virtual_network_link": true,
+	"azurerm_resource_group":                        true,
+	"azurerm_role_assignment":                       true,
+	"azurerm_subnet":                                true,
+	"azurerm_user_assigned_identity":                true,
+	"azurerm_virtual_network":                       true,
+}
+
+// skuAttributes lists the attributes that select the price tier of a
+// billable resource type. Types not listed are recorded without a tier
+var skuAttributes = map[string][]string{
+	"azurerm_container_app_environment": {"workload_profile"},
+	"azurerm_container_registry":        {"sku"},
+	"azurerm_key_vault":                 {"sku_name"},
+	"azurerm_log_analytics_cluster":     {"size_gb"},
+	"azurerm_log_analytics_workspace":   {"sku"},
+}
+
+// CostProfile counts billable resources by type and price tier, e.g.
+// {"azurerm_container_registry sku=Basic": 1}
+type CostProfile map[string]int
+
+// stateModule is a module of `terraform show -json` state output
+type stateModule struct {
+	Resources []struct {
+		Mode   string                 `json:"mode"`
+		Type   string                 `json:"type"`
+		Values map[string]interface{} `json:"values"`
+	} `json:"resources"`
+	ChildModules []stateModule `json:"child_modules"`
+}
+
+// collect adds the billable managed resources of m and its children to profile
+func (m stateModule) collect(profile CostProfile) {
+	for _, resource := range m.Resources {
+		// Only azurerm resources bill; terraform_data, random_* etc. don't
+		if resource.Mode != "managed" || !strings.HasPrefix(resource.Type, "azurerm_") || freeResourceTypes[resource.Type] {
+			continue
+		}
+
+		key := resource.Type
+		for _, attribute := range skuAttributes[resource.Type] {
+			key += fmt.Sprintf(" %s=%s", attribute, costAttributeValue(resource.Values[attribute]))
+		}
+		profile[key]++
+	}
+	for _, child := range m.ChildModules {
+		child.collect(profile)
+	}
+}
+
+// costAttributeValue renders a tier attribute; nested blocks are rendered as
+// compact JSON so a changed workload profile still changes the key
+func costAttributeValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "none"
+	case string, bool, float64:
+		return fmt.Sprint(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// CostProfileFromStateE builds the cost profile of the state in options.TerraformDir
+func CostProfileFromStateE(t *testing.T, options *terraform.Options) (CostProfile, error) {
+	output, err := terraform.ShowE(t, quietOptions(options))
+	if err != nil {
+		return nil, err
+	}
+
+	var state struct {
+		Values *struct {
+			RootModule stateModule `json:"root_module"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal([]byte(output), &state); err != nil {
+		return nil, fmt.Errorf("decoding state: %w", err)
+	}
+
+	profile := CostProfile{}
+	if state.Values != nil {
+		state.Values.RootModule.collect(profile)
+	}
+	return profile, nil
+}
+
+// CostProfileRegressions returns the entries of actual that are missing from
+// golden or exceed its count, i.e. billable resources a change introduced
+func CostProfileRegressions(golden, actual CostProfile) []string {
+	var regressions []string
+	for key, count := range actual {
+		if count > golden[key] {
+			regressions = append(regressions, fmt.Sprintf("%s (%d, golden %d)", key, count, golden[key]))
+		}
+	}
+	sort.Strings(regressions)
+	return regressions
+}
+
+// AssertCostProfile compares the billable resources in the applied state
+// with testdata/cost-profiles/<name>.json and fails on new or additional
+// ones. Set UPDATE_COST_PROFILES=true to rewrite the golden file instead
+func AssertCostProfile(t *testing.T, options *terraform.Options, name string) {
+	actual, err := CostProfileFromStateE(t, options)
+	if err != nil {
+		t.Fatalf("Building cost profile for %s: %v", name, err)
+	}
+	// Kept per run so `ttk report diff` can compare runs
+	RecordReport(t, costProfileReport, name, actual)
+
+	path := filepath.Join(costProfileDir, name+".json")
+	if os.Getenv("UPDATE_COST_PROFILES") == "true" {
+		content, err := json.MarshalIndent(actual, "", "  ")
+		if err != nil {
+			t.Fatalf("Encoding cost profile for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, append(content, '\n'), 0o600); err != nil {
+			t.Fatalf("Writing %s: %v", path, err)
+		}
+		t.Logf("Updated cost profile %s", path)
+		return
+	}
+
+	golden := CostProfile{}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("No cost profile at %s; run with UPDATE_COST_PROFILES=true to create it", path)
+	}
+	if err != nil {
+		t.Fatalf("Reading %s: %v", path, err)
+	}
+	if err := json.Unmarshal(content, &golden); err != nil {
+		t.Fatalf("Decoding %s: %v", path, err)
+	}
+
+	if regressions := CostProfileRegressions(golden, actual); len(regressions) > 0 {
+		t.Errorf("%s deploys billable resources not in %s:\n  %s\n"+
+			"If this is intended, rerun with UPDATE_COST_PROFILES=true and commit the golden file",
+			name, path, strings.Join(regressions, "\n  "))
+	}
+	if removed := CostProfileRegressions(actual, golden); len(removed) > 0 {
+		t.Logf("%s no longer deploys some resources in %s, consider updating it:\n  %s",
+			name, path, strings.Join(removed, "\n  "))
+	}
+}
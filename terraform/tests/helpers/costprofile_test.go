@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testStateModule = `{
+  "resources": [
+    {"mode": "managed", "type": "azurerm_container_registry", "values": {"sku": "Premium"}},
+    {"mode": "managed", "type": "azurerm_monitor_diagnostic_setting", "values": {}},
+    {"mode": "data", "type": "azurerm_client_config", "values": {}},
+    {"mode": "managed", "type": "terraform_data", "values": {}}
+  ],
+  "child_modules": [
+    {
+      "resources": [
+        {"mode": "managed", "type": "azurerm_private_endpoint", "values": {}},
+        {"mode": "managed", "type": "azurerm_private_endpoint", "values": {}},
+        {"mode": "managed", "type": "azurerm_log_analytics_workspace", "values": {"sku": "PerGB2018"}}
+      ]
+    }
+  ]
+}`
+
+func TestCostProfileCollect(t *testing.T) {
+	t.Parallel()
+
+	var module stateModule
+	assert.NoError(t, json.Unmarshal([]byte(testStateModule), &module))
+
+	profile := CostProfile{}
+	module.collect(profile)
+	assert.Equal(t, CostProfile{
+		"azurerm_container_registry sku=Premium":        1,
+		"azurerm_log_analytics_workspace sku=PerGB2018": 1,
+		"azurerm_private_endpoint":                      2,
+	}, profile)
+}
+
+func TestCostProfileRegressions(t *testing.T) {
+	t.Parallel()
+
+	golden := CostProfile{
+		"azurerm_container_registry sku=Basic": 1,
+		"azurerm_private_endpoint":             1,
+	}
+	actual := CostProfile{
+		"azurerm_container_registry sku=Premium": 1,
+		"azurerm_private_endpoint":               2,
+	}
+
+	assert.Equal(t, []string{
+		"azurerm_container_registry sku=Premium (1, golden 0)",
+		"azurerm_private_endpoint (2, golden 1)",
+	}, CostProfileRegressions(golden, actual), "SKU changes and extra resources are regressions")
+	assert.Empty(t, CostProfileRegressions(actual, actual))
+	assert.Empty(t, CostProfileRegressions(golden, CostProfile{}), "Removing resources is not a regression")
+}
@@ -0,0 +1,178 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// deprecationsFile holds the accepted terraform warnings of every module and
+// environment, keyed by their path in the terraform root
+const deprecationsFile = "testdata/deprecations.json"
+
+// validateOutput is the output of `terraform validate -json`
+type validateOutput struct {
+	Diagnostics []struct {
+		Severity string `json:"severity"`
+		Summary  string `json:"summary"`
+		Detail   string `json:"detail"`
+		Range    *struct {
+			Filename string `json:"filename"`
+		} `json:"range"`
+	} `json:"diagnostics"`
+}
+
+// TerraformWarningsE validates the module in dir and returns its warnings,
+// such as deprecated arguments, resources or provider notices, sorted and
+// deduplicated. Each warning reads "<file>: <summary>: <first detail line>"
+// with the file relative to the terraform root and without line numbers, so
+// unrelated edits do not change it. The module is initialized without a
+// backend through the plan cache (see CachedInitE)
+func TerraformWarningsE(t *testing.T, dir string) ([]string, error) {
+	relative, err := terraformRootRelE(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &terraform.Options{
+		TerraformDir: dir,
+		NoColor:      true,
+		Logger:       RedactingLogger,
+		// Environments declare a backend that validation does not need
+		EnvVars: map[string]string{"TF_CLI_ARGS_init": "-backend=false"},
+	}
+	if err := CachedInitE(t, options); err != nil {
+		return nil, err
+	}
+	output, err := terraform.RunTerraformCommandAndGetStdoutE(t, quietOptions(options), "validate", "-json", "-no-color")
+	if err != nil {
+		return nil, fmt.Errorf("validating %s: %w", relative, err)
+	}
+	return parseValidateWarnings(output, filepath.ToSlash(relative))
+}
+
+// parseValidateWarnings returns the warnings of `terraform validate -json`
+// output for the module at relative, formatted as TerraformWarningsE does
+func parseValidateWarnings(output, relative string) ([]string, error) {
+	var result validateOutput
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return nil, fmt.Errorf("decoding validate output: %w", err)
+	}
+
+	seen := map[string]bool{}
+	warnings := []string{}
+	for _, diagnostic := range result.Diagnostics {
+		if diagnostic.Severity != "warning" {
+			continue
+		}
+		file := relative
+		if diagnostic.Range != nil {
+			file = path.Join(relative, filepath.ToSlash(diagnostic.Range.Filename))
+		}
+		warning := fmt.Sprintf("%s: %s", file, diagnostic.Summary)
+		if detail, _, _ := strings.Cut(strings.TrimSpace(diagnostic.Detail), "\n"); detail != "" {
+			warning += ": " + detail
+		}
+		if !seen[warning] {
+			seen[warning] = true
+			warnings = append(warnings, warning)
+		}
+	}
+	sort.Strings(warnings)
+	return warnings, nil
+}
+
+// NewWarnings returns the entries of actual that are not in accepted
+func NewWarnings(accepted, actual []string) []string {
+	known := map[string]bool{}
+	for _, warning := range accepted {
+		known[warning] = true
+	}
+	var added []string
+	for _, warning := range actual {
+		if !known[warning] {
+			added = append(added, warning)
+		}
+	}
+	return added
+}
+
+// readDeprecationsE reads the accepted warnings of every module; a missing
+// file accepts none
+func readDeprecationsE() (map[string][]string, error) {
+	accepted := map[string][]string{}
+	content, err := os.ReadFile(deprecationsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return accepted, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &accepted); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", deprecationsFile, err)
+	}
+	return accepted, nil
+}
+
+// AssertNoNewWarnings compares the terraform warnings of each module in dirs
+// with the accepted ones in testdata/deprecations.json and fails on any new
+// warning, so provider upgrades cannot pile up deprecations unnoticed.
+// Warnings that went away are logged. Set UPDATE_DEPRECATIONS=true to
+// rewrite the file with the current warnings instead
+func AssertNoNewWarnings(t *testing.T, dirs []string) {
+	actual := map[string][]string{}
+	for _, dir := range dirs {
+		relative, err := terraformRootRelE(dir)
+		if err != nil {
+			t.Fatalf("Locating %s: %v", dir, err)
+		}
+		warnings, err := TerraformWarningsE(t, dir)
+		if err != nil {
+			t.Fatalf("Collecting warnings of %s: %v", dir, err)
+		}
+		actual[filepath.ToSlash(relative)] = warnings
+	}
+
+	if os.Getenv("UPDATE_DEPRECATIONS") == "true" {
+		content, err := json.MarshalIndent(actual, "", "  ")
+		if err != nil {
+			t.Fatalf("Encoding warnings: %v", err)
+		}
+		if err := os.WriteFile(deprecationsFile, append(content, '\n'), 0o600); err != nil {
+			t.Fatalf("Writing %s: %v", deprecationsFile, err)
+		}
+		t.Logf("Updated accepted warnings %s", deprecationsFile)
+		return
+	}
+
+	accepted, err := readDeprecationsE()
+	if err != nil {
+		t.Fatalf("Reading %s: %v", deprecationsFile, err)
+	}
+
+	modules := make([]string, 0, len(actual))
+	for module := range actual {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		if added := NewWarnings(accepted[module], actual[module]); len(added) > 0 {
+			t.Errorf("%s has terraform warnings not accepted in %s:\n  %s\n"+
+				"Fix them, or rerun with UPDATE_DEPRECATIONS=true and commit the file to accept them",
+				module, deprecationsFile, strings.Join(added, "\n  "))
+		}
+		if gone := NewWarnings(actual[module], accepted[module]); len(gone) > 0 {
+			t.Logf("%s no longer has some warnings accepted in %s, consider updating it:\n  %s",
+				module, deprecationsFile, strings.Join(gone, "\n  "))
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testValidateOutput = `{
+  "format_version": "1.0",
+  "valid": true,
+  "error_count": 0,
+  "warning_count": 3,
+  "diagnostics": [
+    {
+      "severity": "warning",
+      "summary": "Argument is deprecated",
+      "detail": "The property 'enable_rbac_authorization' has been deprecated in favour of 'rbac_authorization_enabled'.\nIt will be removed in v5.0 of the AzureRM Provider.",
+      "range": {"filename": "main.tf", "start": {"line": 12}}
+    },
+    {
+      "severity": "warning",
+      "summary": "Argument is deprecated",
+      "detail": "The property 'enable_rbac_authorization' has been deprecated in favour of 'rbac_authorization_enabled'.\nIt will be removed in v5.0 of the AzureRM Provider.",
+      "range": {"filename": "main.tf", "start": {"line": 40}}
+    },
+    {
+      "severity": "warning",
+      "summary": "Version constraints inside provider configuration blocks are deprecated",
+      "detail": ""
+    },
+    {
+      "severity": "error",
+      "summary": "Unsupported argument",
+      "range": {"filename": "main.tf", "start": {"line": 3}}
+    }
+  ]
+}`
+
+func TestParseValidateWarnings(t *testing.T) {
+	t.Parallel()
+
+	warnings, err := parseValidateWarnings(testValidateOutput, "modules/key-vault")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"modules/key-vault/main.tf: Argument is deprecated: The property 'enable_rbac_authorization' has been deprecated in favour of 'rbac_authorization_enabled'.",
+		"modules/key-vault: Version constraints inside provider configuration blocks are deprecated",
+	}, warnings, "warnings should be deduplicated across lines, without errors")
+
+	warnings, err = parseValidateWarnings(`{"valid": true, "diagnostics": []}`, "modules/key-vault")
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	_, err = parseValidateWarnings("Error: not json", "modules/key-vault")
+	assert.Error(t, err)
+}
+
+func TestParseValidateWarningsModuleFiles(t *testing.T) {
+	t.Parallel()
+
+	output := `{"valid": true, "diagnostics": [{"severity": "warning", "summary": "Deprecated", "detail": "d", "range": {"filename": "../../modules/container-app/main.tf"}}]}`
+	warnings, err := parseValidateWarnings(output, "environments/dev")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"modules/container-app/main.tf: Deprecated: d"}, warnings, "files of called modules should be relative to the terraform root")
+}
+
+func TestNewWarnings(t *testing.T) {
+	t.Parallel()
+
+	accepted := []string{"a", "b"}
+	assert.Empty(t, NewWarnings(accepted, []string{"a"}))
+	assert.Equal(t, []string{"c"}, NewWarnings(accepted, []string{"a", "c"}))
+	assert.Equal(t, []string{"a"}, NewWarnings(nil, []string{"a"}))
+}
@@ -0,0 +1,301 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// DriftedResource is a resource whose real infrastructure no longer matches
+// the state, as a refresh-only plan reports it
+type DriftedResource struct {
+	Address string `json:"address"`
+	// Deleted is true when the resource is gone from Azure
+	Deleted bool `json:"deleted,omitempty"`
+	// Managed are the drifted attributes the configuration sets, which the
+	// next apply puts back
+	Managed []string `json:"managed,omitempty"`
+	// Unmanaged are the drifted attributes left to the provider or Azure,
+	// such as computed values and defaults
+	Unmanaged []string `json:"unmanaged,omitempty"`
+}
+
+// ManagedDrift reports whether the drift touches what the configuration
+// manages: the resource was deleted or a configured attribute changed
+func (r DriftedResource) ManagedDrift() bool {
+	return r.Deleted || len(r.Managed) > 0
+}
+
+func (r DriftedResource) String() string {
+	switch {
+	case r.Deleted:
+		return fmt.Sprintf("%s: deleted outside terraform", r.Address)
+	case len(r.Managed) > 0:
+		return fmt.Sprintf("%s: %s changed outside terraform", r.Address, strings.Join(r.Managed, ", "))
+	default:
+		return fmt.Sprintf("%s: only unmanaged attributes changed (%s)", r.Address, strings.Join(r.Unmanaged, ", "))
+	}
+}
+
+// DriftReport is the drift found in a long-lived environment
+type DriftReport struct {
+	Environment string `json:"environment"`
+	CheckedAt   string `json:"checked_at"`
+	// Resources are the drifted resources, sorted by address
+	Resources []DriftedResource `json:"resources"`
+}
+
+// ManagedDrift returns the resources of the report with managed drift
+func (r DriftReport) ManagedDrift() []DriftedResource {
+	var managed []DriftedResource
+	for _, resource := range r.Resources {
+		if resource.ManagedDrift() {
+			managed = append(managed, resource)
+		}
+	}
+	return managed
+}
+
+// planModuleConfig is a module in the configuration section of
+// `terraform show -json` plan output. Resource addresses are relative to it
+type planModuleConfig struct {
+	Resources []struct {
+		Address     string                     `json:"address"`
+		Mode        string                     `json:"mode"`
+		Expressions map[string]json.RawMessage `json:"expressions"`
+	} `json:"resources"`
+	Variables map[string]struct {
+		Sensitive bool `json:"sensitive"`
+	} `json:"variables"`
+	ModuleCalls map[string]struct {
+		// Expressions are the arguments of the call, by variable name
+		Expressions map[string]json.RawMessage `json:"expressions"`
+		Module      planModuleConfig           `json:"module"`
+	} `json:"module_calls"`
+}
+
+// configuredAttributes adds the attributes every managed resource of module
+// sets to configured, keyed by the resource address without instance keys
+func (m planModuleConfig) configuredAttributes(prefix string, configured map[string]map[string]bool) {
+	for _, resource := range m.Resources {
+		if resource.Mode != "managed" {
+			continue
+		}
+		attributes := map[string]bool{}
+		for attribute := range resource.Expressions {
+			attributes[attribute] = true
+		}
+		configured[prefix+resource.Address] = attributes
+	}
+	for name, call := range m.ModuleCalls {
+		call.Module.configuredAttributes(prefix+"module."+name+".", configured)
+	}
+}
+
+// configAddress strips the instance keys from a resource address, so
+// module.app["a"].azurerm_container_app.this[0] becomes
+// module.app.azurerm_container_app.this, as the configuration names it
+func configAddress(address string) string {
+	var stripped strings.Builder
+	depth := 0
+	quoted := false
+	for i := 0; i < len(address); i++ {
+		c := address[i]
+		switch {
+		case quoted && c == '\\':
+			i++
+		case quoted:
+			quoted = c != '"'
+		case depth > 0 && c == '"':
+			quoted = true
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0:
+			stripped.WriteByte(c)
+		}
+	}
+	return stripped.String()
+}
+
+// ResourceDriftE returns the drifted resources of a plan (JSON from
+// `terraform show -json`), refresh-only or not, sorted by address. An attribute is managed
+// when the configuration of the resource sets it; anything else the provider
+// or Azure fills in is unmanaged and does not make the next apply change it
+func ResourceDriftE(planJSON string) ([]DriftedResource, error) {
+	var plan struct {
+		ResourceDrift []struct {
+			planResourceChange
+			Mode string `json:"mode"`
+		} `json:"resource_drift"`
+		Configuration struct {
+			RootModule planModuleConfig `json:"root_module"`
+		} `json:"configuration"`
+	}
+	if err := json.Unmarshal([]byte(planJSON), &plan); err != nil {
+		return nil, fmt.Errorf("decoding plan: %w", err)
+	}
+
+	configured := map[string]map[string]bool{}
+	plan.Configuration.RootModule.configuredAttributes("", configured)
+
+	drifted := []DriftedResource{}
+	for _, drift := range plan.ResourceDrift {
+		if drift.Mode != "managed" {
+			continue
+		}
+		resource := DriftedResource{Address: drift.Address}
+		if strings.Join(drift.Change.Actions, ",") == "delete" {
+			resource.Deleted = true
+			drifted = append(drifted, resource)
+			continue
+		}
+		attributes := configured[configAddress(drift.Address)]
+		for _, attribute := range drift.changedAttributes(nil) {
+			if attributes[attribute] {
+				resource.Managed = append(resource.Managed, attribute)
+			} else {
+				resource.Unmanaged = append(resource.Unmanaged, attribute)
+			}
+		}
+		if len(resource.Managed) > 0 || len(resource.Unmanaged) > 0 {
+			drifted = append(drifted, resource)
+		}
+	}
+	sort.Slice(drifted, func(i, j int) bool { return drifted[i].Address < drifted[j].Address })
+	return drifted, nil
+}
+
+// DriftReconciliationViolationsE returns every managed drift in drifted that
+// a plan (JSON from `terraform show -json`) does not put back: a deleted
+// resource must be created again, and a changed one updated or replaced on
+// the attributes that drifted
+func DriftReconciliationViolationsE(planJSON string, drifted []DriftedResource) ([]string, error) {
+	changes, err := planResourceChangesE(planJSON)
+	if err != nil {
+		return nil, err
+	}
+	byAddress := map[string]planResourceChange{}
+	for _, change := range changes {
+		byAddress[change.Address] = change
+	}
+
+	var violations []string
+	for _, resource := range drifted {
+		if !resource.ManagedDrift() {
+			continue
+		}
+		change, planned := byAddress[resource.Address]
+		actions := strings.Join(change.Change.Actions, ",")
+		switch {
+		case !planned || actions == "no-op" || actions == "read":
+			violations = append(violations, fmt.Sprintf("%s: drifted but the plan leaves it", resource.Address))
+		case resource.Deleted:
+			if !strings.Contains(actions, "create") {
+				violations = append(violations, fmt.Sprintf("%s: deleted but the plan does not create it (plans [%s])", resource.Address, actions))
+			}
+		case actions == "update":
+			reverted := map[string]bool{}
+			for _, attribute := range change.changedAttributes(nil) {
+				reverted[attribute] = true
+			}
+			for _, attribute := range resource.Managed {
+				if !reverted[attribute] {
+					violations = append(violations, fmt.Sprintf("%s: %s drifted but the plan does not put it back", resource.Address, attribute))
+				}
+			}
+		}
+	}
+	return violations, nil
+}
+
+// driftPlanFile is the plan MutateAndDetectDriftE makes after each step
+const driftPlanFile = "drift.tfplan"
+
+// planJSONE plans options to a plan file in dir and returns the plan as
+// JSON, without writing it to the test log
+func planJSONE(t *testing.T, options *terraform.Options, dir string) (string, error) {
+	plan := *options
+	plan.PlanFilePath = filepath.Join(dir, driftPlanFile)
+	if _, err := terraform.PlanE(t, &plan); err != nil {
+		return "", err
+	}
+	return terraform.ShowE(t, quietOptions(&plan))
+}
+
+// MutateAndDetectDriftE runs mutate against a deployed configuration to
+// change its resources outside terraform, then checks terraform notices and
+// undoes it: the next plan must report managed drift and put every drifted
+// resource back, and once applied, a further plan must find neither drift
+// nor changes. It returns the managed drift the first plan reported, so the
+// test can check it names what mutate changed. options is not modified
+func MutateAndDetectDriftE(t *testing.T, options *terraform.Options, mutate func() error) ([]DriftedResource, error) {
+	if err := mutate(); err != nil {
+		return nil, fmt.Errorf("changing resources outside terraform: %w", err)
+	}
+
+	dir := t.TempDir()
+	planJSON, err := planJSONE(t, options, dir)
+	if err != nil {
+		return nil, fmt.Errorf("planning after the change: %w", err)
+	}
+	drifted, err := ResourceDriftE(planJSON)
+	if err != nil {
+		return nil, err
+	}
+	var managed []DriftedResource
+	for _, resource := range drifted {
+		if resource.ManagedDrift() {
+			managed = append(managed, resource)
+		}
+	}
+	if len(managed) == 0 {
+		return nil, fmt.Errorf("the plan found no managed drift after the change (unmanaged: %v)", drifted)
+	}
+	violations, err := DriftReconciliationViolationsE(planJSON, managed)
+	if err != nil {
+		return nil, err
+	}
+	if len(violations) > 0 {
+		return managed, errors.New(strings.Join(violations, "; "))
+	}
+
+	if _, err := terraform.ApplyE(t, options); err != nil {
+		return managed, fmt.Errorf("reconciling the drift: %w", err)
+	}
+	planJSON, err = planJSONE(t, options, dir)
+	if err != nil {
+		return managed, fmt.Errorf("planning after reconciling: %w", err)
+	}
+	if violations, err = IdempotencyViolationsE(planJSON); err != nil {
+		return managed, err
+	}
+	if drifted, err = ResourceDriftE(planJSON); err != nil {
+		return managed, err
+	}
+	for _, resource := range drifted {
+		if resource.ManagedDrift() {
+			violations = append(violations, resource.String()+" after reconciling")
+		}
+	}
+	if len(violations) > 0 {
+		return managed, fmt.Errorf("the drift was not reconciled: %s", strings.Join(violations, "; "))
+	}
+	return managed, nil
+}
+
+// MutateAndDetectDrift is MutateAndDetectDriftE, failing the test on error
+func MutateAndDetectDrift(t *testing.T, options *terraform.Options, mutate func() error) []DriftedResource {
+	drifted, err := MutateAndDetectDriftE(t, options, mutate)
+	if err != nil {
+		t.Fatalf("Drift of %s: %v", options.TerraformDir, err)
+	}
+	return drifted
+}
@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigAddress(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "azurerm_resource_group.this", configAddress("azurerm_resource_group.this"))
+	assert.Equal(t, "module.app.azurerm_container_app.this", configAddress(`module.app["a.b"].azurerm_container_app.this[0]`))
+	assert.Equal(t, "module.kv.azurerm_key_vault_secret.this", configAddress(`module.kv[1].azurerm_key_vault_secret.this["x]\"y"]`))
+}
+
+func TestResourceDrift(t *testing.T) {
+	t.Parallel()
+
+	plan := `{
+		"resource_drift": [
+			{"address": "module.registry.azurerm_container_registry.this", "mode": "managed", "change": {"actions": ["update"],
+				"before": {"sku": "Premium", "admin_enabled": false, "tags": {"a": "1"}, "tags_all": {"a": "1"}},
+				"after": {"sku": "Premium", "admin_enabled": true, "tags": {"a": "1"}, "tags_all": {"a": "1", "b": "2"}}}},
+			{"address": "module.apps[\"api\"].azurerm_container_app.this", "mode": "managed", "change": {"actions": ["update"],
+				"before": {"latest_revision_name": "r1"},
+				"after": {"latest_revision_name": "r2"}}},
+			{"address": "azurerm_resource_group.this", "mode": "managed", "change": {"actions": ["delete"],
+				"before": {"name": "rg"}}},
+			{"address": "data.azurerm_client_config.current", "mode": "data", "change": {"actions": ["update"],
+				"before": {"object_id": "a"}, "after": {"object_id": "b"}}},
+			{"address": "azurerm_log_analytics_workspace.this", "mode": "managed", "change": {"actions": ["update"],
+				"before": {"retention_in_days": 30}, "after": {"retention_in_days": 30}}}
+		],
+		"configuration": {"root_module": {
+			"resources": [
+				{"address": "azurerm_resource_group.this", "mode": "managed", "expressions": {"name": {}}},
+				{"address": "azurerm_log_analytics_workspace.this", "mode": "managed", "expressions": {"retention_in_days": {}}}
+			],
+			"module_calls": {
+				"registry": {"module": {"resources": [
+					{"address": "azurerm_container_registry.this", "mode": "managed",
+						"expressions": {"sku": {}, "admin_enabled": {}, "tags": {}}}
+				]}},
+				"apps": {"module": {"resources": [
+					{"address": "azurerm_container_app.this", "mode": "managed", "expressions": {"name": {}}}
+				]}}
+			}
+		}}
+	}`
+
+	drifted, err := ResourceDriftE(plan)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []DriftedResource{
+		{Address: "azurerm_resource_group.this", Deleted: true},
+		{Address: `module.apps["api"].azurerm_container_app.this`, Unmanaged: []string{"latest_revision_name"}},
+		{Address: "module.registry.azurerm_container_registry.this", Managed: []string{"admin_enabled"}, Unmanaged: []string{"tags_all"}},
+	}, drifted)
+
+	report := DriftReport{Environment: "dev", Resources: drifted}
+	managed := report.ManagedDrift()
+	if assert.Len(t, managed, 2) {
+		assert.Equal(t, "azurerm_resource_group.this: deleted outside terraform", managed[0].String())
+		assert.Equal(t, "module.registry.azurerm_container_registry.this: admin_enabled changed outside terraform", managed[1].String())
+	}
+	assert.Equal(t, `module.apps["api"].azurerm_container_app.this: only unmanaged attributes changed (latest_revision_name)`, drifted[1].String())
+}
+
+func TestResourceDriftNone(t *testing.T) {
+	t.Parallel()
+
+	drifted, err := ResourceDriftE(`{"configuration": {"root_module": {}}}`)
+	if assert.NoError(t, err) {
+		assert.Empty(t, drifted)
+	}
+
+	_, err = ResourceDriftE("not json")
+	assert.Error(t, err)
+}
+
+func TestDriftReconciliationViolations(t *testing.T) {
+	t.Parallel()
+
+	drifted := []DriftedResource{
+		{Address: "azurerm_resource_group.this", Managed: []string{"tags"}},
+		{Address: "module.registry.azurerm_container_registry.this", Managed: []string{"admin_enabled", "tags"}},
+		{Address: "module.vault.azurerm_key_vault.this", Deleted: true},
+		{Address: "module.network.azurerm_virtual_network.this", Managed: []string{"tags"}},
+		{Address: "module.logs.azurerm_log_analytics_workspace.this", Deleted: true},
+		{Address: "module.apps.azurerm_container_app.this", Unmanaged: []string{"latest_revision_name"}},
+	}
+	violations, err := DriftReconciliationViolationsE(`{"resource_changes": [
+		{"address": "azurerm_resource_group.this", "change": {"actions": ["update"],
+			"before": {"tags": {"Drift": "out-of-band"}}, "after": {"tags": {}}}},
+		{"address": "module.registry.azurerm_container_registry.this", "change": {"actions": ["update"],
+			"before": {"admin_enabled": true, "tags": {}}, "after": {"admin_enabled": false, "tags": {}}}},
+		{"address": "module.vault.azurerm_key_vault.this", "change": {"actions": ["create"]}},
+		{"address": "module.network.azurerm_virtual_network.this", "change": {"actions": ["no-op"]}},
+		{"address": "module.apps.azurerm_container_app.this", "change": {"actions": ["no-op"]}}
+	]}`, drifted)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"module.registry.azurerm_container_registry.this: tags drifted but the plan does not put it back",
+		"module.network.azurerm_virtual_network.this: drifted but the plan leaves it",
+		"module.logs.azurerm_log_analytics_workspace.this: drifted but the plan leaves it",
+	}, violations, "unmanaged drift needs no change")
+
+	violations, err = DriftReconciliationViolationsE(`{"resource_changes": [
+		{"address": "azurerm_resource_group.this", "change": {"actions": ["delete", "create"]}}
+	]}`, drifted[:1])
+	assert.NoError(t, err)
+	assert.Empty(t, violations, "a replacement puts every attribute back")
+
+	_, err = DriftReconciliationViolationsE("not json", drifted)
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// ipv6ProbeTarget is a public IPv6 address used to check whether the runner
+// has an IPv6 route. Connecting a UDP socket sends no packets
+const ipv6ProbeTarget = "[2001:4860:4860::8888]:53"
+
+// DualStackProbe is the IPv4 / IPv6 capability of an ingress endpoint as seen
+// from the test runner
+type DualStackProbe struct {
+	Region        string   `json:"region"`
+	FQDN          string   `json:"fqdn"`
+	IPv4Addresses []string `json:"ipv4_addresses"`
+	IPv6Addresses []string `json:"ipv6_addresses"`
+	IPv4Reachable bool     `json:"ipv4_reachable"`
+	IPv6Reachable bool     `json:"ipv6_reachable"`
+	// RunnerHasIPv6 is false when the runner itself can't reach IPv6
+	// addresses, in which case IPv6Reachable says nothing about the endpoint
+	RunnerHasIPv6 bool   `json:"runner_has_ipv6"`
+	IPv4Error     string `json:"ipv4_error,omitempty"`
+	IPv6Error     string `json:"ipv6_error,omitempty"`
+	CheckedAt     string `json:"checked_at"`
+}
+
+// AdvertisesIPv6 reports whether the endpoint publishes AAAA records
+func (p DualStackProbe) AdvertisesIPv6() bool {
+	return len(p.IPv6Addresses) > 0
+}
+
+// RunnerHasIPv6 reports whether the test runner has a route to the IPv6 internet
+func RunnerHasIPv6() bool {
+	conn, err := net.Dial("udp6", ipv6ProbeTarget)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// ProbeDualStack resolves the host of applicationURL per address family and
+// sends an HTTPS request over each family that has addresses
+func ProbeDualStack(t *testing.T, region, applicationURL string) DualStackProbe {
+	parsed, err := url.Parse(applicationURL)
+	if err != nil {
+		t.Fatalf("Parsing %s: %v", applicationURL, err)
+	}
+
+	probe := DualStackProbe{
+		Region:        region,
+		FQDN:          parsed.Hostname(),
+		RunnerHasIPv6: RunnerHasIPv6(),
+		CheckedAt:     time.Now().UTC().Format(time.RFC3339),
+	}
+	probe.IPv4Addresses = lookupFamily(probe.FQDN, "ip4")
+	probe.IPv6Addresses = lookupFamily(probe.FQDN, "ip6")
+
+	if len(probe.IPv4Addresses) > 0 {
+		probe.IPv4Reachable, probe.IPv4Error = getOverFamily(applicationURL, "tcp4")
+	}
+	if probe.AdvertisesIPv6() && probe.RunnerHasIPv6 {
+		probe.IPv6Reachable, probe.IPv6Error = getOverFamily(applicationURL, "tcp6")
+	}
+
+	t.Logf("Dual-stack probe of %s in %s: IPv4 %v (reachable %t), IPv6 %v (reachable %t, runner IPv6 %t)",
+		probe.FQDN, region, probe.IPv4Addresses, probe.IPv4Reachable,
+		probe.IPv6Addresses, probe.IPv6Reachable, probe.RunnerHasIPv6)
+	return probe
+}
+
+// lookupFamily returns the addresses of host for one family ("ip4" or "ip6")
+func lookupFamily(host, family string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, family, host)
+	if err != nil {
+		return nil
+	}
+	addresses := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addresses = append(addresses, ip.String())
+	}
+	return addresses
+}
+
+// getOverFamily sends a GET to rawURL with connections forced to one network
+// ("tcp4" or "tcp6"). Any HTTP response counts as reachable
+func getOverFamily(rawURL, network string) (bool, string) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, address string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+
+	response, err := client.Get(rawURL)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusInternalServerError {
+		return false, fmt.Sprintf("HTTP %d", response.StatusCode)
+	}
+	return true, ""
+}
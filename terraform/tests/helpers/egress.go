@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// firewallDeniedStatus is the status Azure Firewall answers plain HTTP
+// requests with when no application rule allows them; denied HTTPS
+// connections are reset instead
+const firewallDeniedStatus = 470
+
+// runnerIPService returns the caller's public IPv4 address as plain text
+var runnerIPService = "https://api.ipify.org"
+
+// EgressProbe is the outcome of an outbound request sent from inside a
+// Container App by the echo fixture's /egress endpoint
+type EgressProbe struct {
+	URL string `json:"url"`
+	// Status is the HTTP status the app received, 0 if none arrived
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Reached reports whether the request got an answer from the destination,
+// whatever its status, rather than being refused on the way
+func (p EgressProbe) Reached() bool {
+	return p.Status != 0 && p.Status != firewallDeniedStatus
+}
+
+// ProbeEgressE asks the echo fixture app at applicationURL to send a GET to
+// target and returns what the app saw
+func ProbeEgressE(t *testing.T, applicationURL, target string) (*EgressProbe, error) {
+	endpoint := fmt.Sprintf("%s/egress?url=%s", strings.TrimRight(applicationURL, "/"), url.QueryEscape(target))
+	client := &http.Client{Timeout: 30 * time.Second}
+	response, err := client.Get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", endpoint, response.StatusCode)
+	}
+
+	var probe EgressProbe
+	if err := json.NewDecoder(response.Body).Decode(&probe); err != nil {
+		return nil, fmt.Errorf("decoding egress probe: %w", err)
+	}
+	return &probe, nil
+}
+
+// RunnerPublicIPE returns the public IPv4 address the test runner's traffic
+// comes from, for fixtures that must route or allow it explicitly
+func RunnerPublicIPE(t *testing.T) (string, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	response, err := client.Get(runnerIPService)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %d", runnerIPService, response.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 64))
+	if err != nil {
+		return "", err
+	}
+	address := strings.TrimSpace(string(body))
+	if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("%s returned %q, not an IPv4 address", runnerIPService, address)
+	}
+	return address, nil
+}
@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEgressProbeReached(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		probe   EgressProbe
+		reached bool
+	}{
+		{"ok", EgressProbe{Status: http.StatusOK}, true},
+		{"unauthorized", EgressProbe{Status: http.StatusUnauthorized}, true},
+		{"reset", EgressProbe{Error: "read: connection reset by peer"}, false},
+		{"firewall_denied_http", EgressProbe{Status: firewallDeniedStatus}, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.reached, tc.probe.Reached())
+		})
+	}
+}
+
+func TestProbeEgressE(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/egress", r.URL.Path)
+		fmt.Fprintf(w, `{"url":%q,"status":0,"error":"connection reset"}`, r.URL.Query().Get("url"))
+	}))
+	defer server.Close()
+
+	probe, err := ProbeEgressE(t, server.URL+"/", "https://example.com/?a=b&c=d")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://example.com/?a=b&c=d", probe.URL)
+		assert.False(t, probe.Reached())
+		assert.Equal(t, "connection reset", probe.Error)
+	}
+}
+
+func TestRunnerPublicIPE(t *testing.T) {
+	answer := "203.0.113.7\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, answer)
+	}))
+	defer server.Close()
+
+	original := runnerIPService
+	runnerIPService = server.URL
+	defer func() { runnerIPService = original }()
+
+	address, err := RunnerPublicIPE(t)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "203.0.113.7", address)
+	}
+
+	answer = "<html>rate limited</html>"
+	_, err = RunnerPublicIPE(t)
+	assert.Error(t, err)
+
+	answer = "2001:db8::1"
+	_, err = RunnerPublicIPE(t)
+	assert.Error(t, err, "IPv6 addresses cannot be routed by the fixture")
+}
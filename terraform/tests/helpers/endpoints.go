@@ -0,0 +1,238 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Cloud holds the endpoints and DNS suffixes of the services of an Azure
+// cloud
+type Cloud struct {
+	// Name is the ARM_ENVIRONMENT value of the cloud, as the azurerm
+	// provider reads it
+	Name string
+	// SDKName is the AZURE_ENVIRONMENT value of the cloud, as terratest's
+	// Azure SDK clients read it
+	SDKName string
+	// CLIName is the name of the cloud in `az cloud set`
+	CLIName string
+	// ResourceManager is the Azure Resource Manager endpoint, also the
+	// resource ARM tokens are issued for
+	ResourceManager string
+	// ActiveDirectory is the Entra ID authority tokens are requested from
+	ActiveDirectory string
+	// KeyVaultResource is the resource Key Vault data-plane tokens are
+	// issued for
+	KeyVaultResource string
+	// MonitorResource is the resource Logs Ingestion API tokens are issued
+	// for
+	MonitorResource string
+	// KeyVaultSuffix follows the vault name in a vault URI
+	KeyVaultSuffix string
+	// RegistrySuffix follows the registry name in an ACR login server
+	RegistrySuffix string
+	// IngestionSuffixes are the domains Application Insights ingestion
+	// endpoints live under, regional first
+	IngestionSuffixes []string
+	// LogAnalyticsSuffix follows the workspace ID in the Log Analytics
+	// data collector endpoint
+	LogAnalyticsSuffix string
+}
+
+// Azure clouds by ARM_ENVIRONMENT name
+var (
+	PublicCloud = Cloud{
+		Name:               "public",
+		SDKName:            "AzurePublicCloud",
+		CLIName:            "AzureCloud",
+		ResourceManager:    "https://management.azure.com",
+		ActiveDirectory:    "https://login.microsoftonline.com",
+		KeyVaultResource:   "https://vault.azure.net",
+		MonitorResource:    "https://monitor.azure.com",
+		KeyVaultSuffix:     "vault.azure.net",
+		RegistrySuffix:     "azurecr.io",
+		IngestionSuffixes:  []string{"applicationinsights.azure.com", "services.visualstudio.com"},
+		LogAnalyticsSuffix: "ods.opinsights.azure.com",
+	}
+	USGovernmentCloud = Cloud{
+		Name:               "usgovernment",
+		SDKName:            "AzureUSGovernmentCloud",
+		CLIName:            "AzureUSGovernment",
+		ResourceManager:    "https://management.usgovcloudapi.net",
+		ActiveDirectory:    "https://login.microsoftonline.us",
+		KeyVaultResource:   "https://vault.usgovcloudapi.net",
+		MonitorResource:    "https://monitor.azure.us",
+		KeyVaultSuffix:     "vault.usgovcloudapi.net",
+		RegistrySuffix:     "azurecr.us",
+		IngestionSuffixes:  []string{"applicationinsights.us"},
+		LogAnalyticsSuffix: "ods.opinsights.azure.us",
+	}
+	ChinaCloud = Cloud{
+		Name:               "china",
+		SDKName:            "AzureChinaCloud",
+		CLIName:            "AzureChinaCloud",
+		ResourceManager:    "https://management.chinacloudapi.cn",
+		ActiveDirectory:    "https://login.chinacloudapi.cn",
+		KeyVaultResource:   "https://vault.azure.cn",
+		MonitorResource:    "https://monitor.azure.cn",
+		KeyVaultSuffix:     "vault.azure.cn",
+		RegistrySuffix:     "azurecr.cn",
+		IngestionSuffixes:  []string{"applicationinsights.azure.cn"},
+		LogAnalyticsSuffix: "ods.opinsights.azure.cn",
+	}
+)
+
+// CloudByName returns the cloud with the given ARM_ENVIRONMENT name; an empty
+// name is the public cloud, as it is for the azurerm provider
+func CloudByName(name string) (Cloud, error) {
+	switch strings.ToLower(name) {
+	case "", PublicCloud.Name:
+		return PublicCloud, nil
+	case USGovernmentCloud.Name:
+		return USGovernmentCloud, nil
+	case ChinaCloud.Name:
+		return ChinaCloud, nil
+	}
+	return Cloud{}, fmt.Errorf("unknown Azure cloud %q: use public, usgovernment or china", name)
+}
+
+// CurrentCloud returns the cloud the tests deploy to, from ARM_ENVIRONMENT,
+// failing the test when it names no known cloud
+func CurrentCloud(t *testing.T) Cloud {
+	cloud, err := CloudByName(os.Getenv("ARM_ENVIRONMENT"))
+	if err != nil {
+		t.Fatalf("ARM_ENVIRONMENT: %v", err)
+	}
+	return cloud
+}
+
+// parseEndpointE parses an https endpoint that must be a bare origin: no
+// credentials, port, query or fragment, and no path beyond "/"
+func parseEndpointE(kind, endpoint string) (*url.URL, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q is not a URL: %w", kind, endpoint, err)
+	}
+	switch {
+	case parsed.Scheme != "https":
+		return nil, fmt.Errorf("%s %q does not use https", kind, endpoint)
+	case parsed.User != nil:
+		return nil, fmt.Errorf("%s %q holds credentials", kind, parsed.Redacted())
+	case parsed.Port() != "":
+		return nil, fmt.Errorf("%s %q has a port", kind, endpoint)
+	case parsed.RawQuery != "" || parsed.Fragment != "":
+		return nil, fmt.Errorf("%s %q has a query or fragment", kind, endpoint)
+	case parsed.Path != "" && parsed.Path != "/":
+		return nil, fmt.Errorf("%s %q has a path", kind, endpoint)
+	}
+	return parsed, nil
+}
+
+// ownedHost checks that host is exactly <owner>.<suffix>, compared
+// case-insensitively, so the endpoint belongs to the named resource and not
+// to one whose name merely contains it
+func ownedHost(kind, host, owner, suffix string) error {
+	want := strings.ToLower(owner + "." + suffix)
+	if strings.ToLower(host) != want {
+		return fmt.Errorf("%s host %q is not %q", kind, host, want)
+	}
+	return nil
+}
+
+// ValidateKeyVaultURI checks that uri is the vault URI of the vault named
+// vaultName in cloud: https://<vault>.<KeyVaultSuffix>/
+func ValidateKeyVaultURI(uri, vaultName string, cloud Cloud) error {
+	parsed, err := parseEndpointE("vault URI", uri)
+	if err != nil {
+		return err
+	}
+	return ownedHost("vault URI", parsed.Host, vaultName, cloud.KeyVaultSuffix)
+}
+
+// ValidateRegistryLoginServer checks that loginServer, a host name without
+// scheme as ACR reports it, is the login server of the registry named
+// registryName in cloud: <registry>.<RegistrySuffix>
+func ValidateRegistryLoginServer(loginServer, registryName string, cloud Cloud) error {
+	if strings.Contains(loginServer, "/") || strings.Contains(loginServer, ":") {
+		return fmt.Errorf("login server %q is not a bare host name", loginServer)
+	}
+	return ownedHost("login server", loginServer, registryName, cloud.RegistrySuffix)
+}
+
+// ValidateAppInsightsConnectionString checks an Application Insights
+// connection string: it has the given instrumentation key, or any when
+// instrumentationKey is empty, and an https ingestion endpoint under one of
+// the cloud's ingestion domains. Errors never quote the key or the string,
+// which are secrets
+func ValidateAppInsightsConnectionString(connectionString, instrumentationKey string, cloud Cloud) error {
+	settings := connectionStringSettings(connectionString)
+	key := settings["instrumentationkey"]
+	switch {
+	case key == "":
+		return fmt.Errorf("connection string has no InstrumentationKey")
+	case instrumentationKey != "" && !strings.EqualFold(key, instrumentationKey):
+		return fmt.Errorf("connection string has another resource's InstrumentationKey")
+	}
+
+	endpoint := settings["ingestionendpoint"]
+	if endpoint == "" {
+		return fmt.Errorf("connection string has no IngestionEndpoint")
+	}
+	parsed, err := parseEndpointE("ingestion endpoint", endpoint)
+	if err != nil {
+		return err
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, suffix := range cloud.IngestionSuffixes {
+		if strings.HasSuffix(host, "."+suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("ingestion endpoint host %q is not under %s", host, strings.Join(cloud.IngestionSuffixes, " or "))
+}
+
+// ValidateLogAnalyticsEndpoint checks that endpoint is the data collector
+// endpoint of the workspace with the given workspace (customer) ID in cloud:
+// https://<workspace ID>.<LogAnalyticsSuffix>/
+func ValidateLogAnalyticsEndpoint(endpoint, workspaceID string, cloud Cloud) error {
+	parsed, err := parseEndpointE("Log Analytics endpoint", endpoint)
+	if err != nil {
+		return err
+	}
+	return ownedHost("Log Analytics endpoint", parsed.Host, workspaceID, cloud.LogAnalyticsSuffix)
+}
+
+// AssertKeyVaultURI fails the test unless uri is the vault URI of vaultName
+// in the current cloud (see ValidateKeyVaultURI)
+func AssertKeyVaultURI(t *testing.T, uri, vaultName string) bool {
+	if err := ValidateKeyVaultURI(uri, vaultName, CurrentCloud(t)); err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	return true
+}
+
+// AssertRegistryLoginServer fails the test unless loginServer is the login
+// server of registryName in the current cloud (see
+// ValidateRegistryLoginServer)
+func AssertRegistryLoginServer(t *testing.T, loginServer, registryName string) bool {
+	if err := ValidateRegistryLoginServer(loginServer, registryName, CurrentCloud(t)); err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	return true
+}
+
+// AssertAppInsightsConnectionString fails the test unless connectionString
+// is a connection string of the current cloud with instrumentationKey, or
+// any key when empty (see ValidateAppInsightsConnectionString)
+func AssertAppInsightsConnectionString(t *testing.T, connectionString, instrumentationKey string) bool {
+	if err := ValidateAppInsightsConnectionString(connectionString, instrumentationKey, CurrentCloud(t)); err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	return true
+}
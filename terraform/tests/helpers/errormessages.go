@@ -0,0 +1,224 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// errorMessageCatalog holds the reviewed error message of every validation
+// and condition, per module
+const errorMessageCatalog = "testdata/error-messages.json"
+
+// conditionBlocks are the blocks that carry an error_message, by the block
+// they are nested in
+var conditionBlocks = map[string][]string{
+	"variable":  {"validation"},
+	"resource":  {"precondition", "postcondition"},
+	"data":      {"precondition", "postcondition"},
+	"output":    {"precondition"},
+	"check":     {"assert"},
+	"lifecycle": {"precondition", "postcondition"},
+}
+
+// errorMessageKeyWords is how many leading words of a message its catalog
+// key holds
+const errorMessageKeyWords = 6
+
+// ModuleErrorMessagesE returns the error_message source of every variable
+// validation, precondition, postcondition and check assertion in the module
+// in dir, keyed by the block declaring it and the first words of the
+// message, e.g. "variable.container_cpu.validation: CPU must be 0.25, 0.5, 0.75,"
+// or "azurerm_container_app.this.precondition: Sidecar container names must
+// differ from".
+// Keys do not depend on the order of the blocks, so adding a condition does
+// not renumber the others. Messages are the text between the quotes,
+// interpolations included, exactly as written
+func ModuleErrorMessagesE(dir string) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	parser := hclparse.NewParser()
+	messages := map[string]string{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		parsed, diags := parser.ParseHCL(content, file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil, fmt.Errorf("%s is not native HCL syntax", file)
+		}
+
+		for _, block := range body.Blocks {
+			var address string
+			switch block.Type {
+			case "variable", "output", "check":
+				address = block.Type + "." + strings.Join(block.Labels, ".")
+			case "resource":
+				address = strings.Join(block.Labels, ".")
+			case "data":
+				address = "data." + strings.Join(block.Labels, ".")
+			default:
+				continue
+			}
+			if err := collectErrorMessages(content, address, block, messages); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+		}
+	}
+	return messages, nil
+}
+
+// collectErrorMessages adds the error messages of the condition blocks in
+// block, and in its lifecycle block, to messages under address
+func collectErrorMessages(content []byte, address string, block *hclsyntax.Block, messages map[string]string) error {
+	counts := map[string]int{}
+	for _, nested := range block.Body.Blocks {
+		if nested.Type == "lifecycle" {
+			if err := collectErrorMessages(content, address, nested, messages); err != nil {
+				return err
+			}
+			continue
+		}
+		if !containsString(conditionBlocks[block.Type], nested.Type) {
+			continue
+		}
+
+		position := fmt.Sprintf("%s.%s[%d]", address, nested.Type, counts[nested.Type])
+		counts[nested.Type]++
+		attribute, exists := nested.Body.Attributes["error_message"]
+		if !exists {
+			return fmt.Errorf("%s has no error_message", position)
+		}
+		source := string(attribute.Expr.Range().SliceBytes(content))
+		if len(source) >= 2 && strings.HasPrefix(source, `"`) && strings.HasSuffix(source, `"`) {
+			source = source[1 : len(source)-1]
+		}
+		key := fmt.Sprintf("%s.%s: %s", address, nested.Type, errorMessagePrefix(source))
+		if _, exists := messages[key]; exists {
+			return fmt.Errorf("%s starts like another message of %s.%s; reword one so their first %d words differ",
+				position, address, nested.Type, errorMessageKeyWords)
+		}
+		messages[key] = source
+	}
+	return nil
+}
+
+// errorMessagePrefix returns the first errorMessageKeyWords words of the
+// message source, skipping the markers of a heredoc
+func errorMessagePrefix(source string) string {
+	words := strings.Fields(source)
+	if len(words) > 1 && strings.HasPrefix(words[0], "<<") {
+		words = words[1 : len(words)-1]
+	}
+	if len(words) > errorMessageKeyWords {
+		words = words[:errorMessageKeyWords]
+	}
+	return strings.Join(words, " ")
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorMessageChanges describes how actual differs from the catalog: added,
+// removed and reworded messages, sorted
+func ErrorMessageChanges(catalog, actual map[string]string) []string {
+	var changes []string
+	for key, message := range actual {
+		reviewed, exists := catalog[key]
+		switch {
+		case !exists:
+			changes = append(changes, fmt.Sprintf("%s: added %q", key, message))
+		case reviewed != message:
+			changes = append(changes, fmt.Sprintf("%s: changed from %q to %q", key, reviewed, message))
+		}
+	}
+	for key, reviewed := range catalog {
+		if _, exists := actual[key]; !exists {
+			changes = append(changes, fmt.Sprintf("%s: removed %q", key, reviewed))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// AssertErrorMessageCatalog compares the error messages of each module in
+// dirs, keyed by module directory name, with testdata/error-messages.json
+// and fails on any added, removed or reworded message: callers match on
+// this text, so it changes only with review. Set UPDATE_ERROR_MESSAGES=true
+// to rewrite the catalog instead
+func AssertErrorMessageCatalog(t *testing.T, dirs []string) {
+	actual := map[string]map[string]string{}
+	for _, dir := range dirs {
+		messages, err := ModuleErrorMessagesE(dir)
+		if err != nil {
+			t.Fatalf("Reading error messages of %s: %v", dir, err)
+		}
+		actual[filepath.Base(dir)] = messages
+	}
+
+	if os.Getenv("UPDATE_ERROR_MESSAGES") == "true" {
+		content, err := json.MarshalIndent(actual, "", "  ")
+		if err != nil {
+			t.Fatalf("Encoding error messages: %v", err)
+		}
+		if err := os.WriteFile(errorMessageCatalog, append(content, '\n'), 0o600); err != nil {
+			t.Fatalf("Writing %s: %v", errorMessageCatalog, err)
+		}
+		t.Logf("Updated error message catalog %s", errorMessageCatalog)
+		return
+	}
+
+	catalog := map[string]map[string]string{}
+	content, err := os.ReadFile(errorMessageCatalog)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("No error message catalog at %s; run with UPDATE_ERROR_MESSAGES=true to create it", errorMessageCatalog)
+	}
+	if err != nil {
+		t.Fatalf("Reading %s: %v", errorMessageCatalog, err)
+	}
+	if err := json.Unmarshal(content, &catalog); err != nil {
+		t.Fatalf("Decoding %s: %v", errorMessageCatalog, err)
+	}
+
+	modules := make([]string, 0, len(actual))
+	for module := range actual {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		if changes := ErrorMessageChanges(catalog[module], actual[module]); len(changes) > 0 {
+			t.Errorf("%s error messages differ from %s:\n  %s\n"+
+				"Automation may match on these messages. If the change is intended, rerun with UPDATE_ERROR_MESSAGES=true and commit the catalog",
+				module, errorMessageCatalog, strings.Join(changes, "\n  "))
+		}
+	}
+	for module := range catalog {
+		if _, exists := actual[module]; !exists {
+			t.Errorf("%s lists module %s, which no longer exists", errorMessageCatalog, module)
+		}
+	}
+}
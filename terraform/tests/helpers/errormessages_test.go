@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConditionModule = `
+variable "sku" {
+  type = string
+
+  validation {
+    condition     = contains(["Basic", "Premium"], var.sku)
+    error_message = "SKU must be Basic or Premium"
+  }
+
+  validation {
+    condition     = var.sku != ""
+    error_message = "SKU must not be empty (got \"${var.sku}\")"
+  }
+}
+
+resource "azurerm_container_registry" "this" {
+  sku = var.sku
+
+  lifecycle {
+    precondition {
+      condition     = var.sku == "Premium"
+      error_message = <<-EOT
+        Premium is required.
+      EOT
+    }
+  }
+}
+
+data "azurerm_client_config" "current" {
+  lifecycle {
+    postcondition {
+      condition     = self.tenant_id != ""
+      error_message = "No tenant"
+    }
+  }
+}
+
+output "id" {
+  value = azurerm_container_registry.this.id
+
+  precondition {
+    condition     = true
+    error_message = "Never"
+  }
+}
+`
+
+func TestModuleErrorMessages(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(testConditionModule), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := ModuleErrorMessagesE(dir)
+	if err != nil {
+		t.Fatalf("Reading error messages: %v", err)
+	}
+	assert.Equal(t, map[string]string{
+		"variable.sku.validation: SKU must be Basic or Premium":              "SKU must be Basic or Premium",
+		"variable.sku.validation: SKU must not be empty (got":                `SKU must not be empty (got \"${var.sku}\")`,
+		"azurerm_container_registry.this.precondition: Premium is required.": "<<-EOT\n        Premium is required.\n      EOT",
+		"data.azurerm_client_config.current.postcondition: No tenant":        "No tenant",
+		"output.id.precondition: Never":                                      "Never",
+	}, messages)
+}
+
+func TestModuleErrorMessagesMissing(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	module := "variable \"sku\" {\n  validation {\n    condition = true\n  }\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "variables.tf"), []byte(module), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ModuleErrorMessagesE(dir)
+	assert.ErrorContains(t, err, "variable.sku.validation[0] has no error_message")
+}
+
+func TestModuleErrorMessagesAlike(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	module := `variable "sku" {
+  validation {
+    condition     = var.sku != ""
+    error_message = "SKU must be set to a paid tier"
+  }
+
+  validation {
+    condition     = var.sku != "Free"
+    error_message = "SKU must be set to a supported tier"
+  }
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "variables.tf"), []byte(module), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ModuleErrorMessagesE(dir)
+	assert.ErrorContains(t, err, "variable.sku.validation[1] starts like another message of variable.sku.validation")
+}
+
+func TestErrorMessageChanges(t *testing.T) {
+	t.Parallel()
+
+	catalog := map[string]string{"a": "A", "b": "B", "c": "C"}
+	assert.Empty(t, ErrorMessageChanges(catalog, map[string]string{"a": "A", "b": "B", "c": "C"}))
+	assert.Equal(t, []string{
+		`b: changed from "B" to "B2"`,
+		`c: removed "C"`,
+		`d: added "D"`,
+	}, ErrorMessageChanges(catalog, map[string]string{"a": "A", "b": "B2", "d": "D"}))
+}
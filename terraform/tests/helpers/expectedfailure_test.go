@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyExpectedFailures(t *testing.T) {
+	t.Parallel()
+
+	const issue = "https://github.com/pollinate/risk-scoring-api/issues/12"
+	tests := map[string]TestOutcome{
+		"TestKnown":              {Status: "fail", DurationSeconds: 30},
+		"TestStale":              {Status: "fail", DurationSeconds: 20},
+		"TestTable":              {Status: "fail", DurationSeconds: 9},
+		"TestTable/known":        {Status: "fail", DurationSeconds: 4},
+		"TestTable/fine":         {Status: "pass", DurationSeconds: 5},
+		"TestNested":             {Status: "fail"},
+		"TestNested/group":       {Status: "fail"},
+		"TestNested/group/known": {Status: "fail"},
+		"TestMixed":              {Status: "fail"},
+		"TestMixed/known":        {Status: "fail"},
+		"TestMixed/broken":       {Status: "fail"},
+		"TestUnmarked":           {Status: "fail"},
+		"TestNotFailing":         {Status: "skip"},
+	}
+	applyExpectedFailures(tests, map[string]expectedFailure{
+		"TestKnown":              {Issue: issue, Outcome: ExpectedFailureFailed},
+		"TestStale":              {Issue: issue, Outcome: ExpectedFailurePassed},
+		"TestTable/known":        {Issue: issue, Outcome: ExpectedFailureFailed},
+		"TestNested/group/known": {Issue: issue, Outcome: ExpectedFailureFailed},
+		"TestMixed/known":        {Issue: issue, Outcome: ExpectedFailureFailed},
+		"TestNotFailing":         {Issue: issue, Outcome: ExpectedFailureFailed},
+	})
+
+	expected := TestOutcome{Status: StatusExpectedFailure, Issue: issue}
+	assert.Equal(t, TestOutcome{Status: StatusExpectedFailure, DurationSeconds: 30, Issue: issue}, tests["TestKnown"])
+	assert.Equal(t, "fail", tests["TestStale"].Status, "a marked test that passed was failed for the stale mark")
+	assert.Equal(t, StatusExpectedFailure, tests["TestTable/known"].Status)
+	assert.Equal(t, TestOutcome{Status: StatusExpectedFailure, DurationSeconds: 9, Issue: issue}, tests["TestTable"],
+		"a parent failing only through expected failures failed as expected")
+	assert.Equal(t, expected, tests["TestNested/group"])
+	assert.Equal(t, expected, tests["TestNested"])
+	assert.Equal(t, "fail", tests["TestMixed"].Status, "a parent with an unexpected failure still fails")
+	assert.Equal(t, "fail", tests["TestUnmarked"].Status)
+	assert.Equal(t, "skip", tests["TestNotFailing"].Status)
+}
+
+func TestExpectedFailureSkipped(t *testing.T) {
+	var name string
+	t.Run("skipped", func(t *testing.T) {
+		name = t.Name()
+		ExpectedFailure(t, "https://github.com/pollinate/risk-scoring-api/issues/12")
+		t.Skip("a skipped test neither fails nor passes")
+	})
+
+	recorded := map[string]expectedFailure{}
+	if err := readReportFileE(ReportDir(RunID()), expectedFailureReport, &recorded); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, recorded, name, "a skipped test records nothing")
+}
@@ -0,0 +1,205 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+)
+
+// principalPrefix starts the display name of every test principal, so
+// leftovers of killed runs can be found and swept
+const principalPrefix = "terratest"
+
+// principalSecretLifetime bounds how long a leaked test credential stays valid
+const principalSecretLifetime = 24 * time.Hour
+
+// TestPrincipal is a short-lived Entra ID app registration and service
+// principal created for one test. ClientSecret is never logged
+type TestPrincipal struct {
+	DisplayName string
+	// ClientID is the application (client) ID
+	ClientID string
+	// ObjectID is the object ID of the service principal, used for role assignments
+	ObjectID     string
+	TenantID     string
+	ClientSecret string
+}
+
+// String identifies the principal without its secret
+func (p *TestPrincipal) String() string {
+	return fmt.Sprintf("%s (%s)", p.DisplayName, p.ClientID)
+}
+
+// PrincipalDisplayName derives the display name of a test principal from the
+// run ID, test name and role, e.g. "terratest-abc123-testkeyvaultrbac-reader"
+func PrincipalDisplayName(runID, testName, role string) string {
+	return WorkspaceName(principalPrefix+"-"+runID, testName+"-"+role)
+}
+
+// NewTestPrincipalE creates an app registration, its service principal and a
+// client secret that expires after a day. The caller deletes it with
+// DeleteTestPrincipalE
+func NewTestPrincipalE(t *testing.T, role string) (*TestPrincipal, error) {
+	principal := &TestPrincipal{DisplayName: PrincipalDisplayName(RunID(), t.Name(), role)}
+
+	var app struct {
+		AppID string `json:"appId"`
+	}
+	if err := AzCLIJSONE(t, &app, "ad", "app", "create", "--display-name", principal.DisplayName,
+		"--sign-in-audience", "AzureADMyOrg"); err != nil {
+		return nil, fmt.Errorf("creating app registration %s: %w", principal.DisplayName, err)
+	}
+	principal.ClientID = app.AppID
+
+	var servicePrincipal struct {
+		ID string `json:"id"`
+	}
+	if err := AzCLIJSONE(t, &servicePrincipal, "ad", "sp", "create", "--id", app.AppID); err != nil {
+		return principal, fmt.Errorf("creating service principal for %s: %w", principal, err)
+	}
+	principal.ObjectID = servicePrincipal.ID
+
+	var credential struct {
+		Password string `json:"password"`
+		Tenant   string `json:"tenant"`
+	}
+	endDate := time.Now().UTC().Add(principalSecretLifetime).Format(time.RFC3339)
+	if err := AzCLIJSONE(t, &credential, "ad", "app", "credential", "reset", "--id", app.AppID,
+		"--display-name", "terratest", "--end-date", endDate); err != nil {
+		return principal, fmt.Errorf("creating client secret for %s: %w", principal, err)
+	}
+	principal.ClientSecret = credential.Password
+	principal.TenantID = credential.Tenant
+	return principal, nil
+}
+
+// DeleteTestPrincipalE deletes the app registration, which also deletes its
+// service principal and credentials
+func DeleteTestPrincipalE(t *testing.T, principal *TestPrincipal) error {
+	if principal.ClientID == "" {
+		return nil
+	}
+	_, err := AzCLIE(t, "ad", "app", "delete", "--id", principal.ClientID)
+	return err
+}
+
+// IsDirectoryPermissionError reports whether err is Entra ID refusing the
+// runner's identity, as opposed to a failure of the request itself
+func IsDirectoryPermissionError(err error) bool {
+	return strings.Contains(err.Error(), "Insufficient privileges") || strings.Contains(err.Error(), "Authorization_RequestDenied")
+}
+
+// NewTestPrincipal creates a principal for tests that need an identity other
+// than the runner's, e.g. the "denied" side of an RBAC check, and deletes it
+// when the test ends. role names the principal's part in the test. Tests are
+// skipped when the runner is not allowed to create app registrations
+// (Application.ReadWrite.OwnedBy or the Application Developer role)
+func NewTestPrincipal(t *testing.T, role string) *TestPrincipal {
+	principal, err := NewTestPrincipalE(t, role)
+	if principal != nil {
+		t.Cleanup(func() {
+			if err := DeleteTestPrincipalE(t, principal); err != nil {
+				t.Logf("Could not delete test principal %s, delete it manually: %v", principal, err)
+			}
+		})
+	}
+	if err != nil {
+		if IsDirectoryPermissionError(err) {
+			SkipWithReason(t, SkipPermissions, fmt.Sprintf("Runner identity cannot create app registrations: %v", err))
+		}
+		t.Fatalf("Creating test principal: %v", err)
+	}
+	t.Logf("Created test principal %s", principal)
+	return principal
+}
+
+// AssignRoleE grants the principal roleName at scope. The principal type is
+// given explicitly so the assignment does not wait for Entra ID replication
+func (p *TestPrincipal) AssignRoleE(t *testing.T, roleName, scope string) error {
+	return assignRoleE(t, p.ObjectID, p.String(), roleName, scope)
+}
+
+// AssignRole grants the principal roleName at scope and fails the test on error
+func (p *TestPrincipal) AssignRole(t *testing.T, roleName, scope string) {
+	if err := p.AssignRoleE(t, roleName, scope); err != nil {
+		t.Fatalf("Assigning %s at %s to %s: %v", roleName, scope, p, err)
+	}
+}
+
+// assignRoleE grants the service principal objectID, described by who,
+// roleName at scope. New principals take a while to replicate, so failures
+// are retried
+func assignRoleE(t *testing.T, objectID, who, roleName, scope string) error {
+	_, err := retry.DoWithRetryE(t, fmt.Sprintf("assigning %s to %s", roleName, who), 6, 10*time.Second, func() (string, error) {
+		return AzCLIE(t, "role", "assignment", "create", "--assignee-object-id", objectID,
+			"--assignee-principal-type", "ServicePrincipal", "--role", roleName, "--scope", scope)
+	})
+	return err
+}
+
+// AccessTokenE gets an access token for resource (e.g.
+// "https://vault.azure.net") with the principal's client secret, without
+// touching the runner's Azure CLI login. New secrets can take a minute to
+// become usable, so failed requests are retried
+func (p *TestPrincipal) AccessTokenE(t *testing.T, resource string) (string, error) {
+	return entraTokenE(t, p.String(), p.TenantID, resource, url.Values{
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+	})
+}
+
+// entraTokenE requests a client credentials token for resource from the
+// tenant's Entra ID endpoint in the run's cloud, authenticating with
+// credentials (a client secret or assertion). who describes the identity in
+// errors
+func entraTokenE(t *testing.T, who, tenantID, resource string, credentials url.Values) (string, error) {
+	endpoint := fmt.Sprintf("%s/%s/oauth2/v2.0/token", CurrentCloud(t).ActiveDirectory, url.PathEscape(tenantID))
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {strings.TrimRight(resource, "/") + "/.default"},
+	}
+	for key, values := range credentials {
+		form[key] = values
+	}
+
+	return retry.DoWithRetryE(t, fmt.Sprintf("getting a token for %s as %s", resource, who), 12, 10*time.Second, func() (string, error) {
+		response, err := http.PostForm(endpoint, form)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+
+		var token struct {
+			AccessToken      string `json:"access_token"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+			return "", fmt.Errorf("decoding token response: %w", err)
+		}
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("token request returned %d: %s", response.StatusCode, token.ErrorDescription)
+		}
+		return token.AccessToken, nil
+	})
+}
+
+// Env returns the ARM_* variables that make terraform authenticate as the
+// principal, for terraform.Options.EnvVars, whatever mode the run signs in
+// with
+func (p *TestPrincipal) Env(subscriptionID string) map[string]string {
+	return map[string]string{
+		"ARM_CLIENT_ID":       p.ClientID,
+		"ARM_CLIENT_SECRET":   p.ClientSecret,
+		"ARM_TENANT_ID":       p.TenantID,
+		"ARM_SUBSCRIPTION_ID": subscriptionID,
+		"ARM_USE_CLI":         "false",
+		"ARM_USE_OIDC":        "false",
+		"ARM_USE_MSI":         "false",
+	}
+}
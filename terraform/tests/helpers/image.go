@@ -0,0 +1,245 @@
+package helpers
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/gruntwork-io/terratest/modules/shell"
+)
+
+const (
+	// fixtureAppsDir holds the Go sources of the fixture apps, relative to tests/
+	fixtureAppsDir   = "fixtures/apps"
+	fixtureImageOS   = "linux"
+	fixtureImageArch = "amd64"
+	// acrTokenUsername is the fixed username for ACR access tokens
+	acrTokenUsername = "00000000-0000-0000-0000-000000000000"
+)
+
+// FixtureImage is a fixture app image published to a registry
+type FixtureImage struct {
+	// Reference is <login server>/fixtures/<app>:<source hash>
+	Reference string
+	Digest    string
+	// Built is false when an image for the same sources was already present
+	Built bool
+}
+
+// SourceHash returns a content hash of the files under paths. File names
+// are part of the hash, so renames and deletions change it too. Paths that
+// don't exist are skipped
+func SourceHash(paths ...string) (string, error) {
+	var files []string
+	for _, root := range paths {
+		if _, err := os.Stat(root); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	sort.Strings(files)
+
+	hash := sha256.New()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.ToSlash(file), len(content))
+		hash.Write(content)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// BuildFixtureImageE compiles fixtures/apps/<app> and publishes it to the
+// registry at loginServer without a Docker daemon. The tag is a hash of the
+// app sources and go.mod/go.sum, so unchanged apps are not rebuilt
+func BuildFixtureImageE(t *testing.T, app, loginServer string) (*FixtureImage, error) {
+	auth, err := acrAuthenticatorE(t, loginServer)
+	if err != nil {
+		return nil, err
+	}
+	return PublishFixtureImageE(t, app, loginServer, auth)
+}
+
+// PublishFixtureImageE is BuildFixtureImageE pushing with auth instead of the
+// runner's Azure CLI login, e.g. as a CI identity
+func PublishFixtureImageE(t *testing.T, app, loginServer string, auth authn.Authenticator) (*FixtureImage, error) {
+	appDir := filepath.Join(fixtureAppsDir, app)
+	sourceHash, err := SourceHash(appDir, "go.mod", "go.sum")
+	if err != nil {
+		return nil, fmt.Errorf("hashing sources of %s: %w", app, err)
+	}
+	// Platform is part of the key so a GOARCH change can't reuse an old tag
+	tag := fmt.Sprintf("%s-%s", fixtureImageArch, sourceHash[:16])
+
+	ref, err := name.NewTag(fmt.Sprintf("%s/fixtures/%s:%s", loginServer, app, tag))
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := remote.Head(ref, remote.WithAuth(auth))
+	if err == nil {
+		t.Logf("Image %s is up to date, skipping build", ref)
+		return &FixtureImage{Reference: ref.String(), Digest: existing.Digest.String()}, nil
+	}
+	var transportErr *transport.Error
+	if !errors.As(err, &transportErr) || transportErr.StatusCode != http.StatusNotFound {
+		return nil, fmt.Errorf("checking for %s: %w", ref, err)
+	}
+
+	binary, err := buildFixtureBinaryE(t, appDir)
+	if err != nil {
+		return nil, err
+	}
+	image, err := fixtureImageE(binary, sourceHash)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Logf("Publishing %s", ref)
+	if err := remote.Write(ref, image, remote.WithAuth(auth)); err != nil {
+		return nil, fmt.Errorf("publishing %s: %w", ref, err)
+	}
+	digest, err := image.Digest()
+	if err != nil {
+		return nil, err
+	}
+	return &FixtureImage{Reference: ref.String(), Digest: digest.String(), Built: true}, nil
+}
+
+// BuildFixtureImage builds and publishes a fixture app, failing the test on error
+func BuildFixtureImage(t *testing.T, app, loginServer string) *FixtureImage {
+	image, err := BuildFixtureImageE(t, app, loginServer)
+	if err != nil {
+		t.Fatalf("Building fixture image %s: %v", app, err)
+	}
+	return image
+}
+
+// TagImageE pushes the image at reference as repository:tag in the same
+// registry, as the pipeline tags a build with its version and latest, and
+// returns the new reference
+func TagImageE(reference, repository, tag string, auth authn.Authenticator) (string, error) {
+	source, err := name.ParseReference(reference)
+	if err != nil {
+		return "", err
+	}
+	target, err := name.NewTag(fmt.Sprintf("%s/%s:%s", source.Context().RegistryStr(), repository, tag))
+	if err != nil {
+		return "", err
+	}
+	image, err := remote.Image(source, remote.WithAuth(auth))
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", source, err)
+	}
+	if err := remote.Write(target, image, remote.WithAuth(auth)); err != nil {
+		return "", fmt.Errorf("publishing %s: %w", target, err)
+	}
+	return target.String(), nil
+}
+
+// buildFixtureBinaryE cross-compiles a static binary of the app in appDir
+func buildFixtureBinaryE(t *testing.T, appDir string) ([]byte, error) {
+	output := filepath.Join(t.TempDir(), "app")
+	err := shell.RunCommandE(t, shell.Command{
+		Command: "go",
+		Args:    []string{"build", "-trimpath", "-ldflags", "-s -w", "-o", output, "./" + filepath.ToSlash(appDir)},
+		Env: map[string]string{
+			"CGO_ENABLED": "0",
+			"GOOS":        fixtureImageOS,
+			"GOARCH":      fixtureImageArch,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("compiling %s: %w", appDir, err)
+	}
+	return os.ReadFile(output)
+}
+
+// fixtureImageE wraps a static binary into a single-layer image with the
+// binary as entrypoint. Timestamps are left at zero so the digest only
+// depends on the binary
+func fixtureImageE(binary []byte, sourceHash string) (v1.Image, error) {
+	var layerTar bytes.Buffer
+	writer := tar.NewWriter(&layerTar)
+	if err := writer.WriteHeader(&tar.Header{
+		Name:     "app",
+		Mode:     0o755,
+		Size:     int64(len(binary)),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(binary); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(layerTar.Bytes())), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	image, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		return nil, err
+	}
+
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	configFile = configFile.DeepCopy()
+	configFile.OS = fixtureImageOS
+	configFile.Architecture = fixtureImageArch
+	configFile.Config.Entrypoint = []string{"/app"}
+	configFile.Config.Labels = map[string]string{"source-hash": sourceHash}
+	return mutate.ConfigFile(image, configFile)
+}
+
+// acrAuthenticatorE exchanges the Azure CLI login for an ACR access token
+func acrAuthenticatorE(t *testing.T, loginServer string) (authn.Authenticator, error) {
+	registryName := strings.SplitN(loginServer, ".", 2)[0]
+
+	var token struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := AzCLIJSONE(t, &token, "acr", "login", "--name", registryName, "--expose-token"); err != nil {
+		return nil, fmt.Errorf("getting access token for %s: %w", registryName, err)
+	}
+	return &authn.Basic{Username: acrTokenUsername, Password: token.AccessToken}, nil
+}
@@ -0,0 +1,56 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceHash(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o600))
+
+	first, err := SourceHash(dir, filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+	second, err := SourceHash(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second, "Hash should be stable and ignore missing paths")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o600))
+	changed, err := SourceHash(dir)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, changed, "Hash should change with file content")
+
+	assert.NoError(t, os.Rename(filepath.Join(dir, "main.go"), filepath.Join(dir, "app.go")))
+	renamed, err := SourceHash(dir)
+	assert.NoError(t, err)
+	assert.NotEqual(t, changed, renamed, "Hash should change when a file is renamed")
+}
+
+func TestFixtureImage(t *testing.T) {
+	t.Parallel()
+
+	image, err := fixtureImageE([]byte("binary"), "abc123")
+	assert.NoError(t, err)
+
+	configFile, err := image.ConfigFile()
+	assert.NoError(t, err)
+	assert.Equal(t, fixtureImageOS, configFile.OS)
+	assert.Equal(t, fixtureImageArch, configFile.Architecture)
+	assert.Equal(t, []string{"/app"}, configFile.Config.Entrypoint)
+	assert.Equal(t, "abc123", configFile.Config.Labels["source-hash"])
+
+	layers, err := image.Layers()
+	assert.NoError(t, err)
+	assert.Len(t, layers, 1, "Image should have a single layer with the binary")
+
+	again, err := fixtureImageE([]byte("binary"), "abc123")
+	assert.NoError(t, err)
+	digest, _ := image.Digest()
+	againDigest, _ := again.Digest()
+	assert.Equal(t, digest, againDigest, "Same binary should produce the same digest")
+}
@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// syntheticTraceSize is the message length of each synthetic trace, just
+	// under the 32768 characters Application Insights keeps of a message
+	syntheticTraceSize = 32000
+
+	// ingestionBatchSize is how many traces SendSyntheticTracesE posts at
+	// once, about 800 KB a request
+	ingestionBatchSize = 25
+)
+
+// syntheticTrace is a trace telemetry item in the ingestion API schema
+type syntheticTrace struct {
+	Name string            `json:"name"`
+	Time string            `json:"time"`
+	IKey string            `json:"iKey"`
+	Tags map[string]string `json:"tags"`
+	Data struct {
+		BaseType string                 `json:"baseType"`
+		BaseData map[string]interface{} `json:"baseData"`
+	} `json:"data"`
+}
+
+// syntheticTraces returns count trace items for role with messages of
+// syntheticTraceSize characters
+func syntheticTraces(instrumentationKey, role string, count int, now time.Time) []syntheticTrace {
+	items := make([]syntheticTrace, count)
+	for i := range items {
+		// A random prefix keeps the messages distinct
+		prefix := randomHex(8) + " "
+		items[i] = syntheticTrace{
+			Name: "Microsoft.ApplicationInsights.Message",
+			Time: now.UTC().Format(time.RFC3339Nano),
+			IKey: instrumentationKey,
+			Tags: map[string]string{
+				"ai.operation.id": randomHex(16),
+				"ai.cloud.role":   role,
+			},
+		}
+		items[i].Data.BaseType = "MessageData"
+		items[i].Data.BaseData = map[string]interface{}{
+			"ver":           2,
+			"message":       prefix + strings.Repeat("x", syntheticTraceSize-len(prefix)),
+			"severityLevel": "Information",
+		}
+	}
+	return items
+}
+
+// SyntheticTraceCount is how many synthetic traces carry at least
+// totalBytes of messages
+func SyntheticTraceCount(totalBytes int) int {
+	return (totalBytes + syntheticTraceSize - 1) / syntheticTraceSize
+}
+
+// SendSyntheticTracesE sends traces with large messages under the cloud role
+// role to the Application Insights resource of connectionString until at
+// least totalBytes of messages are sent, and returns how many it sent.
+// Application Insights is workspace-based, so they count toward the daily
+// cap of its workspace; keep sampling off or they are dropped before that
+func SendSyntheticTracesE(connectionString, role string, totalBytes int) (int, error) {
+	endpoint, instrumentationKey, err := trackEndpointE(connectionString)
+	if err != nil {
+		return 0, err
+	}
+
+	count := SyntheticTraceCount(totalBytes)
+	client := &http.Client{Timeout: 60 * time.Second}
+	for start := 0; start < count; start += ingestionBatchSize {
+		end := start + ingestionBatchSize
+		if end > count {
+			end = count
+		}
+		// Built per batch, so a large flood is never held in memory at once
+		batch := syntheticTraces(instrumentationKey, role, end-start, time.Now())
+		if err := postTelemetryE(client, endpoint, batch); err != nil {
+			return start, fmt.Errorf("traces %d-%d: %w", start, end-1, err)
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,66 @@
+package helpers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyntheticTraceCount(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, SyntheticTraceCount(0))
+	assert.Equal(t, 1, SyntheticTraceCount(1))
+	assert.Equal(t, 1, SyntheticTraceCount(syntheticTraceSize))
+	assert.Equal(t, 2, SyntheticTraceCount(syntheticTraceSize+1))
+}
+
+func TestSendSyntheticTraces(t *testing.T) {
+	t.Parallel()
+
+	var batches []int
+	messages := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/track", r.URL.Path)
+		var items []syntheticTrace
+		if assert.NoError(t, json.NewDecoder(r.Body).Decode(&items)) {
+			batches = append(batches, len(items))
+			for _, item := range items {
+				assert.Equal(t, "key", item.IKey)
+				assert.Equal(t, "ing-run", item.Tags["ai.cloud.role"])
+				assert.Equal(t, "MessageData", item.Data.BaseType)
+				message, _ := item.Data.BaseData["message"].(string)
+				assert.Len(t, message, syntheticTraceSize)
+				messages[message] = true
+			}
+		}
+	}))
+	defer server.Close()
+
+	connectionString := "InstrumentationKey=key;IngestionEndpoint=" + server.URL + "/"
+	sent, err := SendSyntheticTracesE(connectionString, "ing-run", 30*syntheticTraceSize+1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 31, sent)
+	}
+	assert.Equal(t, []int{25, 6}, batches)
+	assert.Len(t, messages, 31, "every message should be distinct")
+
+	_, err = SendSyntheticTracesE("IngestionEndpoint="+server.URL, "ing-run", 1)
+	assert.EqualError(t, err, "connection string has no InstrumentationKey")
+}
+
+func TestSendSyntheticTracesRejected(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sent, err := SendSyntheticTracesE("InstrumentationKey=key;IngestionEndpoint="+server.URL, "ing-run", 2*syntheticTraceSize)
+	assert.EqualError(t, err, "traces 0-1: ingestion endpoint returned 400")
+	assert.Equal(t, 0, sent)
+}
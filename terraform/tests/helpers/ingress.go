@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"time"
+)
+
+const (
+	// IngressRequestTimeout is how long Container Apps HTTP ingress waits for
+	// a response before answering 504; apps cannot change it
+	IngressRequestTimeout = 240 * time.Second
+
+	// ReplicaHeader names the replica that served a response of the echo
+	// fixture app
+	ReplicaHeader = "X-Echo-Replica"
+)
+
+// SlowResponseProbe is how the ingress handled a request the echo fixture
+// app answers late
+type SlowResponseProbe struct {
+	// Delay is how long the app waited before answering
+	Delay time.Duration `json:"delay"`
+	// Elapsed is how long the client waited for the response or error
+	Elapsed time.Duration `json:"elapsed"`
+	// Status is the response status, or 0 if no response arrived
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Answered reports whether the app's own answer came through
+func (p SlowResponseProbe) Answered() bool {
+	return p.Status == http.StatusOK
+}
+
+// CutAt reports whether the request was cut off instead of answered, within
+// tolerance of bound
+func (p SlowResponseProbe) CutAt(bound, tolerance time.Duration) bool {
+	return !p.Answered() && p.Elapsed >= bound-tolerance && p.Elapsed <= bound+tolerance
+}
+
+// ProbeSlowResponse asks the /slow endpoint of the echo fixture app at
+// applicationURL to answer after delay and times what the client gets. The
+// client waits two minutes past delay, so a cut is the ingress's doing
+func ProbeSlowResponse(applicationURL string, delay time.Duration) SlowResponseProbe {
+	probe := SlowResponseProbe{Delay: delay}
+	client := &http.Client{Timeout: delay + 2*time.Minute}
+	endpoint := fmt.Sprintf("%s/slow?seconds=%d", applicationURL, int(delay.Seconds()))
+
+	started := time.Now()
+	response, err := client.Get(endpoint)
+	if err == nil {
+		probe.Status = response.StatusCode
+		_, err = io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+	}
+	probe.Elapsed = time.Since(started)
+	if err != nil {
+		probe.Error = err.Error()
+	}
+	return probe
+}
+
+// StickyReplicasE sends requests GETs to url from one client that keeps the
+// cookies it is given, and counts the responses per replica named in
+// ReplicaHeader. With session affinity every response comes from one replica
+func StickyReplicasE(url string, requests int) (map[string]int, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Jar: jar, Timeout: 30 * time.Second}
+
+	replicas := map[string]int{}
+	for i := 0; i < requests; i++ {
+		response, err := client.Get(url)
+		if err != nil {
+			return replicas, err
+		}
+		_, err = io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+		if err != nil {
+			return replicas, err
+		}
+		if response.StatusCode != http.StatusOK {
+			return replicas, fmt.Errorf("%s returned %d", url, response.StatusCode)
+		}
+		replica := response.Header.Get(ReplicaHeader)
+		if replica == "" {
+			return replicas, fmt.Errorf("%s did not name its replica in %s", url, ReplicaHeader)
+		}
+		replicas[replica]++
+	}
+	return replicas, nil
+}
@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowResponseProbeCutAt(t *testing.T) {
+	t.Parallel()
+
+	bound, tolerance := 240*time.Second, 15*time.Second
+	testCases := []struct {
+		name  string
+		probe SlowResponseProbe
+		cut   bool
+	}{
+		{"gateway_timeout_at_bound", SlowResponseProbe{Status: http.StatusGatewayTimeout, Elapsed: 241 * time.Second}, true},
+		{"closed_at_bound", SlowResponseProbe{Error: "EOF", Elapsed: 230 * time.Second}, true},
+		{"answered", SlowResponseProbe{Status: http.StatusOK, Elapsed: 241 * time.Second}, false},
+		{"cut_early", SlowResponseProbe{Status: http.StatusGatewayTimeout, Elapsed: 60 * time.Second}, false},
+		{"cut_late", SlowResponseProbe{Status: http.StatusGatewayTimeout, Elapsed: 300 * time.Second}, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.cut, tc.probe.CutAt(bound, tolerance))
+		})
+	}
+}
+
+func TestProbeSlowResponse(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/slow", r.URL.Path)
+		assert.Equal(t, "1", r.URL.Query().Get("seconds"))
+		time.Sleep(50 * time.Millisecond)
+		http.Error(w, "upstream request timeout", http.StatusGatewayTimeout)
+	}))
+	defer server.Close()
+
+	probe := ProbeSlowResponse(server.URL, time.Second)
+	assert.Equal(t, http.StatusGatewayTimeout, probe.Status)
+	assert.False(t, probe.Answered())
+	assert.GreaterOrEqual(t, probe.Elapsed, 50*time.Millisecond)
+	assert.Empty(t, probe.Error)
+}
+
+func TestStickyReplicasE(t *testing.T) {
+	t.Parallel()
+
+	// Answers from alternating replicas unless the affinity cookie names one
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		replica := fmt.Sprintf("replica-%d", requests%2)
+		if cookie, err := r.Cookie("affinity"); err == nil {
+			replica = cookie.Value
+		} else {
+			http.SetCookie(w, &http.Cookie{Name: "affinity", Value: replica, Path: "/"})
+		}
+		w.Header().Set(ReplicaHeader, replica)
+	}))
+	defer server.Close()
+
+	replicas, err := StickyReplicasE(server.URL, 5)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]int{"replica-1": 5}, replicas)
+	}
+}
+
+func TestStickyReplicasEMissingHeader(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	_, err := StickyReplicasE(server.URL, 1)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ReplicaHeader)
+	}
+}
@@ -0,0 +1,182 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// InterruptMode is how InterruptApplyE stops an apply midway
+type InterruptMode string
+
+const (
+	// InterruptGracefully sends SIGINT, as cancelling a CI job or pressing
+	// Ctrl-C does: terraform starts nothing new, waits for operations in
+	// flight and saves what it created
+	InterruptGracefully InterruptMode = "interrupt"
+
+	// InterruptKill kills the process, as a runner losing its VM does:
+	// operations in flight carry on in Azure but never reach state
+	InterruptKill InterruptMode = "kill"
+
+	// interruptGracePeriod is how long a gracefully interrupted terraform may
+	// take to wind down before it is killed
+	interruptGracePeriod = 15 * time.Minute
+
+	// killWaitDelay bounds the wait for output of a killed terraform
+	killWaitDelay = 30 * time.Second
+)
+
+var (
+	// createdPattern matches the line terraform logs as a resource is created
+	createdPattern = regexp.MustCompile(`^(\S+): Creation complete after `)
+
+	// lockIDPattern matches the lock ID in terraform's state lock error
+	lockIDPattern = regexp.MustCompile(`(?m)^\s*ID:\s+(\S+)\s*$`)
+)
+
+// InterruptedApply is what happened to an apply stopped midway
+type InterruptedApply struct {
+	Mode InterruptMode `json:"mode"`
+	// Created lists the resources terraform reported created, in order
+	Created []string `json:"created"`
+	// Finished is true when the apply completed before it could be stopped
+	Finished bool          `json:"finished"`
+	Elapsed  time.Duration `json:"elapsed"`
+	// Error is how the stopped terraform process exited
+	Error string `json:"error,omitempty"`
+}
+
+// createdAddress returns the address of the resource a line of apply output
+// reports created, or ""
+func createdAddress(line string) string {
+	if match := createdPattern.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// staleLockID returns the ID of the lock in a state lock error, or ""
+func staleLockID(output string) string {
+	if !strings.Contains(output, "Error acquiring the state lock") {
+		return ""
+	}
+	if match := lockIDPattern.FindStringSubmatch(output); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// InterruptApplyE inits options and starts an apply, then stops it the way
+// mode says once afterCreated resources are created, leaving partial state
+// behind. The apply failing on its own is an error; finishing before
+// afterCreated resources is not, and shows in the result
+func InterruptApplyE(t *testing.T, options *terraform.Options, mode InterruptMode, afterCreated int) (InterruptedApply, error) {
+	result := InterruptedApply{Mode: mode, Created: []string{}}
+	if _, err := terraform.InitE(t, options); err != nil {
+		return result, err
+	}
+
+	binary := options.TerraformBinary
+	if binary == "" {
+		binary = terraform.DefaultExecutable
+	}
+	args := terraform.FormatArgs(options, "apply", "-input=false", "-auto-approve")
+	if !options.NoColor {
+		args = append(args, "-no-color")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Dir = options.TerraformDir
+	cmd.Env = os.Environ()
+	for key, value := range options.EnvVars {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.WaitDelay = killWaitDelay
+	if mode == InterruptGracefully {
+		cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+		cmd.WaitDelay = interruptGracePeriod
+	}
+
+	// Output goes through a pipe of our own so Wait, bounded by WaitDelay,
+	// does not hang on plugins of a killed terraform holding it open
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			t.Log(line)
+			if address := createdAddress(line); address != "" {
+				result.Created = append(result.Created, address)
+				if len(result.Created) == afterCreated {
+					t.Logf("Stopping apply (%s) after %d resources were created", mode, afterCreated)
+					cancel()
+				}
+			}
+		}
+		// Keep terraform from blocking on a full pipe if a line was too long
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		writer.Close()
+		<-scanned
+		return result, fmt.Errorf("starting terraform apply: %w", err)
+	}
+	err := cmd.Wait()
+	writer.Close()
+	<-scanned
+	result.Elapsed = time.Since(started)
+
+	switch {
+	case err == nil:
+		// Nothing was left to stop, or terraform finished as it was stopped
+		result.Finished = true
+	case ctx.Err() == nil:
+		return result, fmt.Errorf("terraform apply failed before it was stopped: %w\n%s", err, stderr.String())
+	default:
+		result.Error = strings.TrimSpace(err.Error() + "\n" + stderr.String())
+	}
+	return result, nil
+}
+
+// DestroyAfterInterruptE destroys what an interrupted apply left in state.
+// A killed terraform can leave its state lock behind (a blob lease on the
+// shared backend); since the process holding it is known to be gone, the
+// lock is forced open once and the destroy retried
+func DestroyAfterInterruptE(t *testing.T, options *terraform.Options) (string, error) {
+	output, err := terraform.DestroyE(t, options)
+	if err == nil {
+		return output, nil
+	}
+	lockID := staleLockID(output + "\n" + err.Error())
+	if lockID == "" {
+		return output, err
+	}
+
+	t.Logf("Forcing open state lock %s left by the killed apply", lockID)
+	if _, unlockErr := terraform.RunTerraformCommandE(t, options, "force-unlock", "-force", lockID); unlockErr != nil {
+		return output, fmt.Errorf("unlocking state lock %s: %w (destroy failed with: %v)", lockID, unlockErr, err)
+	}
+	return terraform.DestroyE(t, options)
+}
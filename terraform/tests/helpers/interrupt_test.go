@@ -0,0 +1,103 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTerraform writes a script standing in for terraform: init succeeds and
+// apply runs applyScript
+func fakeTerraform(t *testing.T, applyScript string) *terraform.Options {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "terraform")
+	script := "#!/bin/sh\n[ \"$1\" = init ] && exit 0\n" + applyScript
+	if err := os.WriteFile(binary, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return &terraform.Options{TerraformDir: dir, TerraformBinary: binary, NoColor: true}
+}
+
+func TestCreatedAddress(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "module.key_vault.azurerm_key_vault.this",
+		createdAddress("module.key_vault.azurerm_key_vault.this: Creation complete after 2m1s [id=/subscriptions/0000]"))
+	assert.Equal(t, `azurerm_key_vault_secret.secrets["app"]`,
+		createdAddress(`  azurerm_key_vault_secret.secrets["app"]: Creation complete after 3s`))
+	assert.Empty(t, createdAddress("module.key_vault.azurerm_key_vault.this: Still creating... [10s elapsed]"))
+	assert.Empty(t, createdAddress("Apply complete! Resources: 3 added, 0 changed, 0 destroyed."))
+}
+
+func TestStaleLockID(t *testing.T) {
+	t.Parallel()
+
+	output := `Error: Error acquiring the state lock
+
+Error message: state blob is already locked
+Lock Info:
+  ID:        8d5bd2a5-3ac2-7c3b-a0c5-7e4a3d2c1b00
+  Path:      tfstate/terratest/tag-update.tfstate
+  Operation: OperationTypeApply
+`
+	assert.Equal(t, "8d5bd2a5-3ac2-7c3b-a0c5-7e4a3d2c1b00", staleLockID(output))
+	assert.Empty(t, staleLockID("Error: creating Resource Group\n  ID: /subscriptions/0000"))
+}
+
+func TestInterruptApplyEGracefully(t *testing.T) {
+	t.Parallel()
+
+	options := fakeTerraform(t, `trap 'kill $!; echo "Interrupt received."; echo "b: Creation complete after 1s"; exit 1' INT
+echo "a: Creation complete after 1s"
+sleep 30 &
+wait
+echo "c: Creation complete after 30s"
+`)
+	result, err := InterruptApplyE(t, options, InterruptGracefully, 1)
+	if assert.NoError(t, err) {
+		assert.False(t, result.Finished)
+		assert.Equal(t, []string{"a", "b"}, result.Created, "resources in flight should still be reported")
+		assert.NotEmpty(t, result.Error)
+	}
+}
+
+func TestInterruptApplyEKill(t *testing.T) {
+	t.Parallel()
+
+	options := fakeTerraform(t, `echo "a: Creation complete after 1s"
+echo "b: Creation complete after 1s"
+exec sleep 30
+`)
+	result, err := InterruptApplyE(t, options, InterruptKill, 2)
+	if assert.NoError(t, err) {
+		assert.False(t, result.Finished)
+		assert.Equal(t, []string{"a", "b"}, result.Created)
+		assert.Less(t, result.Elapsed.Seconds(), 20.0, "the apply should be killed, not waited for")
+	}
+}
+
+func TestInterruptApplyEFinished(t *testing.T) {
+	t.Parallel()
+
+	options := fakeTerraform(t, `echo "a: Creation complete after 1s"
+echo "Apply complete! Resources: 1 added, 0 changed, 0 destroyed."
+`)
+	result, err := InterruptApplyE(t, options, InterruptKill, 2)
+	if assert.NoError(t, err) {
+		assert.True(t, result.Finished)
+		assert.Equal(t, []string{"a"}, result.Created)
+	}
+}
+
+func TestInterruptApplyEFailed(t *testing.T) {
+	t.Parallel()
+
+	options := fakeTerraform(t, "echo 'Error: creating Resource Group' >&2\nexit 1\n")
+	_, err := InterruptApplyE(t, options, InterruptGracefully, 1)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "creating Resource Group")
+	}
+}
@@ -0,0 +1,62 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// taggedResource is the part of `az resource list` and `az keyvault
+// list-deleted` output the leak check reads
+type taggedResource struct {
+	ID         string            `json:"id"`
+	Tags       map[string]string `json:"tags"`
+	Properties struct {
+		Tags map[string]string `json:"tags"`
+	} `json:"properties"`
+}
+
+// testResourceIDs returns the sorted IDs of resources tagged with testName.
+// Deleted vaults keep their tags under properties
+func testResourceIDs(resources []taggedResource, testName string) []string {
+	ids := []string{}
+	for _, resource := range resources {
+		if resource.Tags["TestName"] == testName || resource.Properties.Tags["TestName"] == testName {
+			ids = append(ids, resource.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// LeakedResourcesE returns what a test left behind in subscriptionID after
+// its destroy: resourceGroupName if it still exists, any resource tagged
+// with testName wherever it lives, and soft-deleted key vaults tagged with
+// testName, which hold on to their names until purged
+func LeakedResourcesE(t *testing.T, subscriptionID, resourceGroupName, testName string) ([]string, error) {
+	leaks := []string{}
+
+	exists, err := AzCLIE(t, "group", "exists", "--name", resourceGroupName, "--subscription", subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("checking resource group %s: %w", resourceGroupName, err)
+	}
+	if strings.TrimSpace(exists) == "true" {
+		leaks = append(leaks, "resource group "+resourceGroupName)
+	}
+
+	var resources []taggedResource
+	if err := AzCLIJSONE(t, &resources, "resource", "list", "--tag", "TestName="+testName, "--subscription", subscriptionID); err != nil {
+		return nil, fmt.Errorf("listing resources of %s: %w", testName, err)
+	}
+	leaks = append(leaks, testResourceIDs(resources, testName)...)
+
+	var deletedVaults []taggedResource
+	if err := AzCLIJSONE(t, &deletedVaults, "keyvault", "list-deleted", "--resource-type", "vault", "--subscription", subscriptionID); err != nil {
+		return nil, fmt.Errorf("listing deleted key vaults: %w", err)
+	}
+	for _, id := range testResourceIDs(deletedVaults, testName) {
+		leaks = append(leaks, "deleted key vault "+id)
+	}
+	return leaks, nil
+}
@@ -0,0 +1,28 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestResourceIDs(t *testing.T) {
+	t.Parallel()
+
+	resources := []taggedResource{
+		{ID: "/subscriptions/0000/vaults/kv-b", Tags: map[string]string{"TestName": "TestA/kill"}},
+		{ID: "/subscriptions/0000/workspaces/log-a", Tags: map[string]string{"TestName": "TestA/kill"}},
+		{ID: "/subscriptions/0000/workspaces/log-other", Tags: map[string]string{"TestName": "TestB"}},
+		{ID: "/subscriptions/0000/untagged"},
+	}
+	deleted := taggedResource{ID: "/subscriptions/0000/deletedVaults/kv-c"}
+	deleted.Properties.Tags = map[string]string{"TestName": "TestA/kill"}
+	resources = append(resources, deleted)
+
+	assert.Equal(t, []string{
+		"/subscriptions/0000/deletedVaults/kv-c",
+		"/subscriptions/0000/vaults/kv-b",
+		"/subscriptions/0000/workspaces/log-a",
+	}, testResourceIDs(resources, "TestA/kill"))
+	assert.Empty(t, testResourceIDs(resources, "TestC"))
+}
@@ -0,0 +1,90 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// QueryLogAnalyticsE runs a KQL query against the workspace with the given
+// workspace (customer) ID and returns one map per row, keyed by column name
+func QueryLogAnalyticsE(t *testing.T, workspaceID, query string) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	err := AzCLIJSONE(t, &rows, "monitor", "log-analytics", "query",
+		"--workspace", workspaceID, "--analytics-query", query)
+	return rows, err
+}
+
+// QueryLogAnalytics runs a KQL query and fails the test on error
+func QueryLogAnalytics(t *testing.T, workspaceID, query string) []map[string]interface{} {
+	rows, err := QueryLogAnalyticsE(t, workspaceID, query)
+	if err != nil {
+		t.Fatalf("Querying Log Analytics workspace %s: %v", workspaceID, err)
+	}
+	return rows
+}
+
+// softDeletedWorkspacePattern matches what Azure says about a soft-deleted
+// workspace standing in the way of a new one with its name
+var softDeletedWorkspacePattern = regexp.MustCompile(`(?i)soft[- ]?delet|deleted state|recently deleted|recover`)
+
+// DeletedWorkspace is a soft-deleted Log Analytics workspace. Deleted
+// workspaces keep their name, data and customer ID for 14 days and come back
+// when a workspace of the same name is created in their resource group
+type DeletedWorkspace struct {
+	Name       string `json:"name"`
+	Location   string `json:"location"`
+	CustomerID string `json:"customerId"`
+}
+
+// DeletedWorkspacesE lists the soft-deleted workspaces in a resource group
+func DeletedWorkspacesE(t *testing.T, resourceGroupName string) ([]DeletedWorkspace, error) {
+	var workspaces []DeletedWorkspace
+	err := AzCLIJSONE(t, &workspaces, "monitor", "log-analytics", "workspace", "list-deleted-workspaces",
+		"--resource-group", resourceGroupName)
+	return workspaces, err
+}
+
+// PurgeDeletedWorkspaceE permanently deletes a soft-deleted workspace so its
+// name can be used afresh. Azure only force-deletes live workspaces, so it is
+// recovered first
+func PurgeDeletedWorkspaceE(t *testing.T, resourceGroupName, name string) error {
+	if _, err := AzCLIE(t, "monitor", "log-analytics", "workspace", "recover",
+		"--resource-group", resourceGroupName, "--workspace-name", name); err != nil {
+		return fmt.Errorf("recovering workspace %s to purge it: %w", name, err)
+	}
+	if _, err := AzCLIE(t, "monitor", "log-analytics", "workspace", "delete", "--force", "true", "--yes",
+		"--resource-group", resourceGroupName, "--workspace-name", name); err != nil {
+		return fmt.Errorf("purging workspace %s: %w", name, err)
+	}
+	return nil
+}
+
+// PurgeDeletedWorkspaces purges every soft-deleted workspace in a resource
+// group, logging failures. Call it before destroying a fixture that
+// soft-deletes workspaces so none is left holding its name
+func PurgeDeletedWorkspaces(t *testing.T, resourceGroupName string) {
+	workspaces, err := DeletedWorkspacesE(t, resourceGroupName)
+	if err != nil {
+		t.Logf("Listing deleted workspaces in %s: %v", resourceGroupName, err)
+		return
+	}
+	for _, workspace := range workspaces {
+		if err := PurgeDeletedWorkspaceE(t, resourceGroupName, workspace.Name); err != nil {
+			t.Logf("%v", err)
+		}
+	}
+}
+
+// IsActionableWorkspaceReuseError reports whether an error creating
+// workspace name tells the reader what is in the way: it names the workspace
+// and says a soft-deleted one holds the name, so recovering or purging it is
+// the obvious next step
+func IsActionableWorkspaceReuseError(err error, name string) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return strings.Contains(message, name) && softDeletedWorkspacePattern.MatchString(message)
+}
@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsActionableWorkspaceReuseError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		err        error
+		actionable bool
+	}{
+		{"soft_deleted", errors.New(`creating Workspace "log-reuse-abc123": Workspace log-reuse-abc123 is in soft-delete state, recover or purge it`), true},
+		{"deleted_state", errors.New(`unexpected status 409: workspace 'log-reuse-abc123' is in deleted state`), true},
+		{"other_workspace", errors.New(`workspace 'log-other' is in deleted state`), false},
+		{"unrelated", errors.New(`creating Workspace "log-reuse-abc123": unexpected status 500`), false},
+		{"no_error", nil, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.actionable, IsActionableWorkspaceReuseError(tc.err, "log-reuse-abc123"))
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// imdsTokenEndpoint is the Azure Instance Metadata Service token endpoint
+// of a VM or VM scale set, the default the azurerm provider uses for
+// ARM_USE_MSI
+const imdsTokenEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// managedIdentityRequestE builds the token request for resource on the
+// runner's managed identity, read with getenv: the App Service, Container
+// Apps and Functions endpoint (IDENTITY_ENDPOINT and IDENTITY_HEADER) when
+// the runner is a container there, else ARM_MSI_ENDPOINT or IMDS. The
+// identity is user-assigned when ARM_CLIENT_ID names it, else system-assigned
+func managedIdentityRequestE(getenv func(string) string, resource string) (*http.Request, error) {
+	query := url.Values{"resource": {resource}}
+	if clientID := getenv("ARM_CLIENT_ID"); clientID != "" {
+		query.Set("client_id", clientID)
+	}
+
+	endpoint, header, value := getenv("IDENTITY_ENDPOINT"), "X-IDENTITY-HEADER", getenv("IDENTITY_HEADER")
+	if endpoint != "" && value != "" {
+		query.Set("api-version", "2019-08-01")
+	} else {
+		endpoint, header, value = getenv("ARM_MSI_ENDPOINT"), "Metadata", "true"
+		if endpoint == "" {
+			endpoint = imdsTokenEndpoint
+		}
+		query.Set("api-version", "2018-02-01")
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing managed identity endpoint: %w", err)
+	}
+	parsed.RawQuery = query.Encode()
+	request, err := http.NewRequest(http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set(header, value)
+	return request, nil
+}
+
+// ManagedIdentityTokenE gets an access token for resource as the runner's
+// managed identity (see managedIdentityRequestE for the endpoints and
+// variables)
+func ManagedIdentityTokenE(getenv func(string) string, resource string) (string, error) {
+	request, err := managedIdentityRequestE(getenv, resource)
+	if err != nil {
+		return "", err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("requesting a managed identity token, is the runner in Azure? %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("managed identity endpoint %s returned %d", request.URL.Host, response.StatusCode)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decoding managed identity token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("managed identity endpoint returned no token")
+	}
+	return token.AccessToken, nil
+}
+
+// sdkManagedIdentityEnv returns the variables that make terratest's Azure
+// SDK clients (azure.GetKeyVault, azure.GetContainerRegistry...) sign in as
+// the runner's managed identity. Its authorizer reads the environment when
+// AZURE_CLIENT_ID and AZURE_TENANT_ID are both set, even empty, and without
+// AZURE_CLIENT_SECRET falls back to managed identity; an empty client ID is
+// the system-assigned identity
+func sdkManagedIdentityEnv(getenv func(string) string) map[string]string {
+	return map[string]string{
+		"AZURE_CLIENT_ID": getenv("ARM_CLIENT_ID"),
+		"AZURE_TENANT_ID": getenv("ARM_TENANT_ID"),
+	}
+}
+
+// signInManagedIdentityE signs the run in as the runner's managed identity:
+// it checks a token can be had, points terratest's SDK clients at the
+// identity and signs the Azure CLI in with `az login --identity`, in a
+// config folder of the run's own, set to the cloud in ARM_ENVIRONMENT, so
+// the runner's login is never switched
+func signInManagedIdentityE(getenv func(string) string) error {
+	if getenv("AZURE_CLIENT_SECRET") != "" {
+		return errors.New("ARM_USE_MSI is set, but so is AZURE_CLIENT_SECRET; the Azure SDK would sign in with the secret, unset it")
+	}
+	cloud, err := CloudByName(getenv("ARM_ENVIRONMENT"))
+	if err != nil {
+		return err
+	}
+	if _, err := ManagedIdentityTokenE(getenv, cloud.ResourceManager); err != nil {
+		return err
+	}
+	for name, value := range sdkManagedIdentityEnv(getenv) {
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+
+	configDir, err := os.MkdirTemp("", "terratest-az-msi-")
+	if err != nil {
+		return err
+	}
+	if err := os.Setenv("AZURE_CONFIG_DIR", configDir); err != nil {
+		return err
+	}
+	if _, err := azJSON("cloud", "set", "--name", cloud.CLIName); err != nil {
+		return fmt.Errorf("setting the Azure CLI to %s: %w", cloud.CLIName, err)
+	}
+	login := []string{"login", "--identity", "--allow-no-subscriptions"}
+	if clientID := getenv("ARM_CLIENT_ID"); clientID != "" {
+		login = append(login, "--username", clientID)
+	}
+	if _, err := azJSON(login...); err != nil {
+		return fmt.Errorf("signing the Azure CLI in as the managed identity: %w", err)
+	}
+	if subscriptionID := getenv("ARM_SUBSCRIPTION_ID"); subscriptionID != "" {
+		if _, err := azJSON("account", "set", "--subscription", subscriptionID); err != nil {
+			return fmt.Errorf("selecting subscription %s as the managed identity: %w", subscriptionID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagedIdentityTokenFromIMDS(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != PublicCloud.ResourceManager ||
+			r.URL.Query().Get("client_id") != "user-assigned" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"from-imds"}`))
+	}))
+	defer server.Close()
+
+	token, err := ManagedIdentityTokenE(getenvFrom(map[string]string{
+		"ARM_MSI_ENDPOINT": server.URL + "/metadata/identity/oauth2/token",
+		"ARM_CLIENT_ID":    "user-assigned",
+	}), PublicCloud.ResourceManager)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "from-imds", token)
+	}
+
+	_, err = ManagedIdentityTokenE(getenvFrom(map[string]string{"ARM_MSI_ENDPOINT": server.URL}), PublicCloud.ResourceManager)
+	assert.Error(t, err, "the endpoint refuses a request for an identity it does not have")
+}
+
+func TestManagedIdentityTokenFromAppService(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-IDENTITY-HEADER") != "secret-header" || r.URL.Query().Get("api-version") != "2019-08-01" ||
+			r.URL.Query().Has("client_id") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"from-container-apps"}`))
+	}))
+	defer server.Close()
+
+	token, err := ManagedIdentityTokenE(getenvFrom(map[string]string{
+		"IDENTITY_ENDPOINT": server.URL,
+		"IDENTITY_HEADER":   "secret-header",
+		"ARM_MSI_ENDPOINT":  "http://127.0.0.1:1/unused",
+	}), PublicCloud.ResourceManager)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "from-container-apps", token)
+	}
+}
+
+func TestManagedIdentityRequestDefaultsToIMDS(t *testing.T) {
+	t.Parallel()
+
+	request, err := managedIdentityRequestE(getenvFrom(nil), PublicCloud.ResourceManager)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "169.254.169.254", request.URL.Host)
+		assert.Equal(t, "true", request.Header.Get("Metadata"))
+		assert.False(t, request.URL.Query().Has("client_id"), "the system-assigned identity is asked for without a client ID")
+	}
+}
+
+func TestSDKManagedIdentityEnv(t *testing.T) {
+	t.Parallel()
+
+	env := sdkManagedIdentityEnv(getenvFrom(map[string]string{"ARM_TENANT_ID": "tenant"}))
+	assert.Equal(t, map[string]string{"AZURE_CLIENT_ID": "", "AZURE_TENANT_ID": "tenant"}, env,
+		"both must be set, the client ID empty for the system-assigned identity")
+}
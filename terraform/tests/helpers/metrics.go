@@ -0,0 +1,229 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Container Apps platform metrics the behavioral tests read
+const (
+	// ContainerAppRequestsMetric counts requests reaching the app, read as a
+	// Total
+	ContainerAppRequestsMetric = "Requests"
+	// ContainerAppReplicasMetric is the app's replica count, read as a
+	// Maximum
+	ContainerAppReplicasMetric = "Replicas"
+	// ContainerAppCPUMetric is the app's CPU usage in nanocores, read as an
+	// Average
+	ContainerAppCPUMetric = "UsageNanoCores"
+)
+
+// Azure Monitor publishes platform metrics a few minutes after the fact, so
+// assertions poll for them
+const (
+	metricInterval     = "PT1M"
+	metricPollInterval = 30 * time.Second
+	// DefaultMetricTimeout is how long the metric assertions wait for
+	// Azure Monitor to catch up
+	DefaultMetricTimeout = 10 * time.Minute
+)
+
+// MetricAggregation is how Azure Monitor aggregates a metric over each
+// minute of the window
+type MetricAggregation string
+
+// Aggregations of the metrics API
+const (
+	AggregationAverage MetricAggregation = "Average"
+	AggregationTotal   MetricAggregation = "Total"
+	AggregationMaximum MetricAggregation = "Maximum"
+	AggregationMinimum MetricAggregation = "Minimum"
+	AggregationCount   MetricAggregation = "Count"
+)
+
+// MetricPoint is the aggregated value of a metric over one minute
+type MetricPoint struct {
+	TimeStamp time.Time
+	Value     float64
+}
+
+// Metric is a metric of a resource over a window, aggregated per minute.
+// Minutes without data have no point
+type Metric struct {
+	Name        string
+	Unit        string
+	Aggregation MetricAggregation
+	Points      []MetricPoint
+}
+
+// Value reduces the points to one value the way their aggregation adds up:
+// the sum of totals and counts, the highest maximum, the lowest minimum and
+// the mean of averages. A metric without points is 0
+func (m *Metric) Value() float64 {
+	if len(m.Points) == 0 {
+		return 0
+	}
+	value := m.Points[0].Value
+	for _, point := range m.Points[1:] {
+		switch m.Aggregation {
+		case AggregationMaximum:
+			value = math.Max(value, point.Value)
+		case AggregationMinimum:
+			value = math.Min(value, point.Value)
+		default:
+			value += point.Value
+		}
+	}
+	if m.Aggregation == AggregationAverage {
+		value /= float64(len(m.Points))
+	}
+	return value
+}
+
+// String prints the metric's aggregation, name, value and unit
+func (m *Metric) String() string {
+	return fmt.Sprintf("%s %s of %g %s", m.Aggregation, m.Name, m.Value(), m.Unit)
+}
+
+// metricsResponse is the part of `az monitor metrics list` output, the
+// metrics API response, the helpers read
+type metricsResponse struct {
+	Value []struct {
+		Name struct {
+			Value string `json:"value"`
+		} `json:"name"`
+		Unit       string `json:"unit"`
+		Timeseries []struct {
+			Data []struct {
+				TimeStamp time.Time `json:"timeStamp"`
+				Average   *float64  `json:"average"`
+				Total     *float64  `json:"total"`
+				Maximum   *float64  `json:"maximum"`
+				Minimum   *float64  `json:"minimum"`
+				Count     *float64  `json:"count"`
+			} `json:"data"`
+		} `json:"timeseries"`
+	} `json:"value"`
+}
+
+// parseMetricE returns metricName from a metrics API response with the
+// points that have a value for aggregation. Without a dimension filter there
+// is one time series
+func parseMetricE(response metricsResponse, metricName string, aggregation MetricAggregation) (*Metric, error) {
+	for _, value := range response.Value {
+		if !strings.EqualFold(value.Name.Value, metricName) {
+			continue
+		}
+		metric := &Metric{Name: value.Name.Value, Unit: value.Unit, Aggregation: aggregation}
+		for _, series := range value.Timeseries {
+			for _, data := range series.Data {
+				var point *float64
+				switch aggregation {
+				case AggregationAverage:
+					point = data.Average
+				case AggregationTotal:
+					point = data.Total
+				case AggregationMaximum:
+					point = data.Maximum
+				case AggregationMinimum:
+					point = data.Minimum
+				case AggregationCount:
+					point = data.Count
+				default:
+					return nil, fmt.Errorf("unknown aggregation %q", aggregation)
+				}
+				if point != nil {
+					metric.Points = append(metric.Points, MetricPoint{TimeStamp: data.TimeStamp, Value: *point})
+				}
+			}
+		}
+		return metric, nil
+	}
+	return nil, fmt.Errorf("metric %s not in the response", metricName)
+}
+
+// GetMetricE reads metricName of the resource with resourceID over the last
+// window from Azure Monitor, aggregated per minute with aggregation
+func GetMetricE(t *testing.T, resourceID, metricName string, window time.Duration, aggregation MetricAggregation) (*Metric, error) {
+	end := time.Now().UTC()
+	var response metricsResponse
+	if err := AzCLIJSONE(t, &response, "monitor", "metrics", "list", "--resource", resourceID,
+		"--metric", metricName, "--aggregation", string(aggregation), "--interval", metricInterval,
+		"--start-time", end.Add(-window).Format(time.RFC3339), "--end-time", end.Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("reading %s of %s: %w", metricName, resourceID, err)
+	}
+	return parseMetricE(response, metricName, aggregation)
+}
+
+// GetMetric is GetMetricE failing the test on error
+func GetMetric(t *testing.T, resourceID, metricName string, window time.Duration, aggregation MetricAggregation) *Metric {
+	metric, err := GetMetricE(t, resourceID, metricName, window, aggregation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metric
+}
+
+// waitForMetricE polls get on clock until the metric's Value reaches
+// minimum or timeout passes, returning the metric read last
+func waitForMetricE(clock Clock, get func() (*Metric, error), minimum float64, timeout time.Duration) (*Metric, error) {
+	deadline := clock.Now().Add(timeout)
+	for {
+		metric, err := get()
+		if err != nil {
+			return nil, err
+		}
+		if metric.Value() >= minimum {
+			return metric, nil
+		}
+		if clock.Now().After(deadline) {
+			return metric, fmt.Errorf("%s after %s, expected at least %g", metric, timeout, minimum)
+		}
+		clock.Sleep(metricPollInterval)
+	}
+}
+
+// WaitForMetricE waits up to timeout for metricName of resourceID over the
+// last window, reduced with Metric.Value, to reach minimum
+func WaitForMetricE(t *testing.T, resourceID, metricName string, window time.Duration, aggregation MetricAggregation, minimum float64, timeout time.Duration) (*Metric, error) {
+	return waitForMetricE(SystemClock, func() (*Metric, error) {
+		return GetMetricE(t, resourceID, metricName, window, aggregation)
+	}, minimum, timeout)
+}
+
+// AssertMetricAtLeast fails the test unless metricName of resourceID over
+// the last window reaches minimum within DefaultMetricTimeout, and returns
+// the metric read last
+func AssertMetricAtLeast(t *testing.T, resourceID, metricName string, window time.Duration, aggregation MetricAggregation, minimum float64) *Metric {
+	metric, err := WaitForMetricE(t, resourceID, metricName, window, aggregation, minimum, DefaultMetricTimeout)
+	if err != nil {
+		t.Errorf("Azure Monitor %s of %s: %v", metricName, resourceID, err)
+	}
+	return metric
+}
+
+// AssertContainerAppRequests fails the test unless Azure Monitor counted at
+// least minimum requests to the container app over the last window
+func AssertContainerAppRequests(t *testing.T, appID string, window time.Duration, minimum int) *Metric {
+	return AssertMetricAtLeast(t, appID, ContainerAppRequestsMetric, window, AggregationTotal, float64(minimum))
+}
+
+// AssertContainerAppReplicas fails the test unless Azure Monitor saw the
+// container app run at least minimum replicas at some point of the last
+// window
+func AssertContainerAppReplicas(t *testing.T, appID string, window time.Duration, minimum int) *Metric {
+	return AssertMetricAtLeast(t, appID, ContainerAppReplicasMetric, window, AggregationMaximum, float64(minimum))
+}
+
+// AssertContainerAppCPUUsage fails the test unless Azure Monitor saw the
+// container app use CPU over the last window
+func AssertContainerAppCPUUsage(t *testing.T, appID string, window time.Duration) *Metric {
+	metric, err := WaitForMetricE(t, appID, ContainerAppCPUMetric, window, AggregationAverage, math.SmallestNonzeroFloat64, DefaultMetricTimeout)
+	if err != nil {
+		t.Errorf("Azure Monitor CPU usage of %s: %v", appID, err)
+	}
+	return metric
+}
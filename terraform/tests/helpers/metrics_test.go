@@ -0,0 +1,114 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sampleMetricsResponse is `az monitor metrics list` output for three
+// minutes of Requests, one of them without data
+const sampleMetricsResponse = `{
+  "cost": 0,
+  "interval": "PT1M",
+  "value": [{
+    "id": "/subscriptions/0000/resourceGroups/rg/providers/Microsoft.App/containerApps/ca/providers/Microsoft.Insights/metrics/Requests",
+    "name": {"localizedValue": "Requests", "value": "Requests"},
+    "unit": "Count",
+    "timeseries": [{
+      "data": [
+        {"timeStamp": "2026-03-01T12:00:00Z", "total": 12.0},
+        {"timeStamp": "2026-03-01T12:01:00Z"},
+        {"timeStamp": "2026-03-01T12:02:00Z", "total": 8.0, "maximum": 3.0}
+      ],
+      "metadatavalues": []
+    }]
+  }]
+}`
+
+func TestParseMetricE(t *testing.T) {
+	t.Parallel()
+
+	var response metricsResponse
+	if err := json.Unmarshal([]byte(sampleMetricsResponse), &response); err != nil {
+		t.Fatal(err)
+	}
+	metric, err := parseMetricE(response, "requests", AggregationTotal)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Requests", metric.Name)
+		assert.Equal(t, "Count", metric.Unit)
+		assert.Equal(t, []MetricPoint{
+			{TimeStamp: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Value: 12},
+			{TimeStamp: time.Date(2026, 3, 1, 12, 2, 0, 0, time.UTC), Value: 8},
+		}, metric.Points, "minutes without data should have no point")
+		assert.Equal(t, float64(20), metric.Value())
+	}
+
+	metric, err = parseMetricE(response, "Requests", AggregationMaximum)
+	if assert.NoError(t, err) {
+		assert.Len(t, metric.Points, 1, "only minutes with the aggregation count")
+	}
+
+	_, err = parseMetricE(response, "Replicas", AggregationMaximum)
+	assert.ErrorContains(t, err, "Replicas not in the response")
+	_, err = parseMetricE(response, "Requests", "P95")
+	assert.ErrorContains(t, err, "unknown aggregation")
+}
+
+func TestMetricValue(t *testing.T) {
+	t.Parallel()
+
+	points := []MetricPoint{{Value: 2}, {Value: 6}, {Value: 1}}
+	for aggregation, expected := range map[MetricAggregation]float64{
+		AggregationTotal:   9,
+		AggregationCount:   9,
+		AggregationMaximum: 6,
+		AggregationMinimum: 1,
+		AggregationAverage: 3,
+	} {
+		metric := &Metric{Aggregation: aggregation, Points: points}
+		assert.Equal(t, expected, metric.Value(), "%s", aggregation)
+	}
+	assert.Zero(t, (&Metric{Aggregation: AggregationMaximum}).Value(), "a metric without data is 0")
+}
+
+func TestWaitForMetricE(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("published late", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		reads := 0
+		metric, err := waitForMetricE(clock, func() (*Metric, error) {
+			reads++
+			if reads < 3 {
+				return &Metric{Name: "Requests", Aggregation: AggregationTotal}, nil
+			}
+			return &Metric{Name: "Requests", Aggregation: AggregationTotal, Points: []MetricPoint{{Value: 15}, {Value: 5}}}, nil
+		}, 20, 10*time.Minute)
+		if assert.NoError(t, err) {
+			assert.Equal(t, float64(20), metric.Value())
+			assert.Equal(t, time.Minute, clock.Slept())
+		}
+	})
+
+	t.Run("never reached", func(t *testing.T) {
+		metric, err := waitForMetricE(NewFakeClock(start), func() (*Metric, error) {
+			return &Metric{Name: "Replicas", Unit: "Count", Aggregation: AggregationMaximum, Points: []MetricPoint{{Value: 1}}}, nil
+		}, 3, 2*time.Minute)
+		assert.ErrorContains(t, err, "Maximum Replicas of 1 Count after 2m0s, expected at least 3")
+		if assert.NotNil(t, metric, "the last read should be returned") {
+			assert.Equal(t, float64(1), metric.Value())
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		failed := errors.New("ResourceNotFound")
+		_, err := waitForMetricE(NewFakeClock(start), func() (*Metric, error) { return nil, failed }, 1, time.Minute)
+		assert.ErrorIs(t, err, failed)
+	})
+}
@@ -0,0 +1,352 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// moduleGraphDir holds the expected module graph of each environment
+const moduleGraphDir = "testdata/module-graphs"
+
+// ModuleGraph maps each module, by source directory name, to the modules it
+// depends on in a composition and how: "input = output" for every output
+// wired into one of its inputs, or "depends_on" for an explicit dependency
+type ModuleGraph map[string]map[string][]string
+
+// moduleRef is a reference to an output of a module call; output is empty
+// when the expression refers to the whole module
+type moduleRef struct {
+	call, output string
+}
+
+// composition is the parsed root module of an environment
+type composition struct {
+	locals map[string]hcl.Expression
+	// sources maps module call names to their source directory name
+	sources map[string]string
+	calls   map[string]*hclsyntax.Body
+}
+
+// parseCompositionE reads the module calls and locals of the root module in dir
+func parseCompositionE(dir string) (*composition, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	parser := hclparse.NewParser()
+	c := &composition{locals: map[string]hcl.Expression{}, sources: map[string]string{}, calls: map[string]*hclsyntax.Body{}}
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil, fmt.Errorf("%s is not native HCL syntax", file)
+		}
+
+		for _, block := range body.Blocks {
+			switch {
+			case block.Type == "locals":
+				for name, attribute := range block.Body.Attributes {
+					c.locals[name] = attribute.Expr
+				}
+			case block.Type == "module" && len(block.Labels) == 1:
+				source, exists := block.Body.Attributes["source"]
+				if !exists {
+					return nil, fmt.Errorf("module %s in %s has no source", block.Labels[0], file)
+				}
+				value, diags := source.Expr.Value(nil)
+				if diags.HasErrors() {
+					return nil, fmt.Errorf("source of module %s in %s is not a literal: %w", block.Labels[0], file, diags)
+				}
+				c.sources[block.Labels[0]] = path.Base(value.AsString())
+				c.calls[block.Labels[0]] = block.Body
+			}
+		}
+	}
+	return c, nil
+}
+
+// traversalModuleRef returns the module call and output a traversal refers to
+func traversalModuleRef(traversal hcl.Traversal) (moduleRef, bool) {
+	if traversal.RootName() != "module" || len(traversal) < 2 {
+		return moduleRef{}, false
+	}
+	call, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return moduleRef{}, false
+	}
+	ref := moduleRef{call: call.Name}
+	// Skip count / for_each indexes: module.networking[0].vnet_id
+	for _, step := range traversal[2:] {
+		if attr, ok := step.(hcl.TraverseAttr); ok {
+			ref.output = attr.Name
+			break
+		}
+	}
+	return ref, true
+}
+
+// moduleRefs returns the module outputs expr depends on, following locals
+func (c *composition) moduleRefs(expr hcl.Expression, seenLocals map[string]bool) map[moduleRef]bool {
+	refs := map[moduleRef]bool{}
+	syntaxExpr, ok := expr.(hclsyntax.Expression)
+	if !ok {
+		return refs
+	}
+
+	hclsyntax.VisitAll(syntaxExpr, func(node hclsyntax.Node) hcl.Diagnostics {
+		switch n := node.(type) {
+		case *hclsyntax.ScopeTraversalExpr:
+			if ref, ok := traversalModuleRef(n.Traversal); ok {
+				refs[ref] = true
+			}
+			if n.Traversal.RootName() == "local" && len(n.Traversal) > 1 {
+				if attr, ok := n.Traversal[1].(hcl.TraverseAttr); ok && !seenLocals[attr.Name] {
+					if local, exists := c.locals[attr.Name]; exists {
+						seenLocals[attr.Name] = true
+						for ref := range c.moduleRefs(local, seenLocals) {
+							refs[ref] = true
+						}
+					}
+				}
+			}
+		case *hclsyntax.SplatExpr:
+			// module.networking[*].subnet_id: the output is in the splat's Each
+			source, isTraversal := n.Source.(*hclsyntax.ScopeTraversalExpr)
+			each, isRelative := n.Each.(*hclsyntax.RelativeTraversalExpr)
+			if isTraversal && isRelative && len(each.Traversal) > 0 {
+				if ref, ok := traversalModuleRef(source.Traversal); ok {
+					if attr, ok := each.Traversal[0].(hcl.TraverseAttr); ok {
+						ref.output = attr.Name
+					}
+					refs[ref] = true
+				}
+			}
+		}
+		return nil
+	})
+
+	// A whole-module reference adds nothing when a specific output is known
+	for ref := range refs {
+		if ref.output == "" {
+			for other := range refs {
+				if other.call == ref.call && other.output != "" {
+					delete(refs, ref)
+					break
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// ModuleGraphFromDirE builds the module graph of the root module in dir from
+// its module calls: which outputs feed which inputs, and depends_on
+func ModuleGraphFromDirE(dir string) (ModuleGraph, error) {
+	c, err := parseCompositionE(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := ModuleGraph{}
+	addWire := func(consumer, producerCall, wire string) error {
+		producer, exists := c.sources[producerCall]
+		if !exists {
+			return fmt.Errorf("module %s refers to unknown module %s", consumer, producerCall)
+		}
+		if graph[consumer] == nil {
+			graph[consumer] = map[string][]string{}
+		}
+		for _, existing := range graph[consumer][producer] {
+			if existing == wire {
+				return nil
+			}
+		}
+		graph[consumer][producer] = append(graph[consumer][producer], wire)
+		return nil
+	}
+
+	for call, body := range c.calls {
+		consumer := c.sources[call]
+		if graph[consumer] == nil {
+			graph[consumer] = map[string][]string{}
+		}
+		for input, attribute := range body.Attributes {
+			for ref := range c.moduleRefs(attribute.Expr, map[string]bool{}) {
+				wire := "depends_on"
+				if input != "depends_on" {
+					output := ref.output
+					if output == "" {
+						output = "*"
+					}
+					wire = fmt.Sprintf("%s = %s", input, output)
+				}
+				if err := addWire(consumer, ref.call, wire); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, producers := range graph {
+		for _, wires := range producers {
+			sort.Strings(wires)
+		}
+	}
+	return graph, nil
+}
+
+// Layers orders the modules of the graph into layers: each module only
+// depends on modules in earlier layers. It returns an error naming the
+// modules on a cycle if there is one
+func (g ModuleGraph) Layers() ([][]string, error) {
+	remaining := map[string]bool{}
+	for module, producers := range g {
+		remaining[module] = true
+		for producer := range producers {
+			remaining[producer] = true
+		}
+	}
+
+	var layers [][]string
+	placed := map[string]bool{}
+	for len(remaining) > 0 {
+		var layer []string
+		for module := range remaining {
+			ready := true
+			for producer := range g[module] {
+				if !placed[producer] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				layer = append(layer, module)
+			}
+		}
+		if len(layer) == 0 {
+			var cycle []string
+			for module := range remaining {
+				cycle = append(cycle, module)
+			}
+			sort.Strings(cycle)
+			return layers, fmt.Errorf("module dependencies form a cycle among %s", strings.Join(cycle, ", "))
+		}
+
+		sort.Strings(layer)
+		for _, module := range layer {
+			placed[module] = true
+			delete(remaining, module)
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// ModuleGraphChanges compares a graph with the expected one and describes
+// every difference, new module dependencies first
+func ModuleGraphChanges(expected, actual ModuleGraph) []string {
+	var added, other []string
+	modules := map[string]bool{}
+	for module := range expected {
+		modules[module] = true
+	}
+	for module := range actual {
+		modules[module] = true
+	}
+
+	for module := range modules {
+		producers := map[string]bool{}
+		for producer := range expected[module] {
+			producers[producer] = true
+		}
+		for producer := range actual[module] {
+			producers[producer] = true
+		}
+
+		for producer := range producers {
+			expectedWires, wasDependency := expected[module][producer]
+			actualWires, isDependency := actual[module][producer]
+			switch {
+			case !wasDependency:
+				added = append(added, fmt.Sprintf("%s now depends on %s (%s)", module, producer, strings.Join(actualWires, ", ")))
+			case !isDependency:
+				other = append(other, fmt.Sprintf("%s no longer depends on %s", module, producer))
+			case !reflect.DeepEqual(expectedWires, actualWires):
+				other = append(other, fmt.Sprintf("%s wiring from %s changed: [%s] -> [%s]", module, producer,
+					strings.Join(expectedWires, ", "), strings.Join(actualWires, ", ")))
+			}
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(other)
+	return append(added, other...)
+}
+
+// AssertModuleGraph builds the module graph of the composition in dir,
+// asserts that it is acyclic and compares it with
+// testdata/module-graphs/<name>.json, failing when a module gains, loses or
+// rewires a dependency. Set UPDATE_MODULE_GRAPHS=true to rewrite the expected
+// graph instead
+func AssertModuleGraph(t *testing.T, dir, name string) {
+	actual, err := ModuleGraphFromDirE(dir)
+	if err != nil {
+		t.Fatalf("Building module graph of %s: %v", dir, err)
+	}
+
+	layers, err := actual.Layers()
+	if err != nil {
+		t.Errorf("%s: %v", dir, err)
+	} else {
+		for i, layer := range layers {
+			t.Logf("%s layer %d: %s", name, i, strings.Join(layer, ", "))
+		}
+	}
+
+	path := filepath.Join(moduleGraphDir, name+".json")
+	if os.Getenv("UPDATE_MODULE_GRAPHS") == "true" {
+		content, err := json.MarshalIndent(actual, "", "  ")
+		if err != nil {
+			t.Fatalf("Encoding module graph of %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, append(content, '\n'), 0o600); err != nil {
+			t.Fatalf("Writing %s: %v", path, err)
+		}
+		t.Logf("Updated module graph %s", path)
+		return
+	}
+
+	expected := ModuleGraph{}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("No module graph at %s; run with UPDATE_MODULE_GRAPHS=true to create it", path)
+	}
+	if err != nil {
+		t.Fatalf("Reading %s: %v", path, err)
+	}
+	if err := json.Unmarshal(content, &expected); err != nil {
+		t.Fatalf("Decoding %s: %v", path, err)
+	}
+
+	if changes := ModuleGraphChanges(expected, actual); len(changes) > 0 {
+		t.Errorf("Module dependencies of %s differ from %s:\n  %s\n"+
+			"If this is intended, rerun with UPDATE_MODULE_GRAPHS=true and commit the expected graph",
+			dir, path, strings.Join(changes, "\n  "))
+	}
+}
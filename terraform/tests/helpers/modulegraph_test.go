@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testComposition = `
+locals {
+  image = "${module.registry.login_server}/app:latest"
+}
+
+module "rg" {
+  source = "../../modules/resource-group"
+  name   = "rg-test"
+}
+
+module "registry" {
+  source              = "../../modules/container-registry"
+  resource_group_name = module.rg.name
+}
+
+module "network" {
+  count               = 1
+  source              = "../../modules/networking"
+  resource_group_name = module.rg.name
+}
+
+module "app" {
+  source                   = "../../modules/container-app"
+  resource_group_name      = module.rg.name
+  container_image          = local.image
+  infrastructure_subnet_id = one(module.network[*].container_app_subnet_id)
+  depends_on               = [module.registry]
+}
+`
+
+func TestModuleGraphFromDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(testComposition), 0o600))
+
+	graph, err := ModuleGraphFromDirE(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, ModuleGraph{
+		"resource-group":     {},
+		"container-registry": {"resource-group": {"resource_group_name = name"}},
+		"networking":         {"resource-group": {"resource_group_name = name"}},
+		"container-app": {
+			"resource-group":     {"resource_group_name = name"},
+			"container-registry": {"container_image = login_server", "depends_on"},
+			"networking":         {"infrastructure_subnet_id = container_app_subnet_id"},
+		},
+	}, graph)
+
+	layers, err := graph.Layers()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"resource-group"},
+		{"container-registry", "networking"},
+		{"container-app"},
+	}, layers)
+}
+
+func TestModuleGraphLayersCycle(t *testing.T) {
+	t.Parallel()
+
+	graph := ModuleGraph{
+		"resource-group": {},
+		"key-vault":      {"resource-group": {"location = location"}, "container-app": {"x = y"}},
+		"container-app":  {"key-vault": {"key_vault_id = id"}},
+	}
+	_, err := graph.Layers()
+	assert.EqualError(t, err, "module dependencies form a cycle among container-app, key-vault")
+}
+
+func TestModuleGraphChanges(t *testing.T) {
+	t.Parallel()
+
+	expected := ModuleGraph{
+		"container-app": {"key-vault": {"key_vault_id = id"}, "observability": {"log_analytics_workspace_id = log_analytics_workspace_id"}},
+		"key-vault":     {},
+	}
+	actual := ModuleGraph{
+		"container-app": {"key-vault": {"key_vault_id = id", "secret_uri = vault_uri"}, "networking": {"infrastructure_subnet_id = container_app_subnet_id"}},
+		"key-vault":     {},
+	}
+
+	assert.Empty(t, ModuleGraphChanges(expected, expected))
+	assert.Equal(t, []string{
+		"container-app now depends on networking (infrastructure_subnet_id = container_app_subnet_id)",
+		"container-app no longer depends on observability",
+		"container-app wiring from key-vault changed: [key_vault_id = id] -> [key_vault_id = id, secret_uri = vault_uri]",
+	}, ModuleGraphChanges(expected, actual))
+}
@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var (
+	// releaseTagPattern matches a semantic version tag, e.g. v1.4.0
+	releaseTagPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+	// commitPattern matches a full git commit SHA
+	commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	// exactVersionPattern matches a registry version constraint allowing one
+	// version only, e.g. "1.4.0" or "= 1.4.0"
+	exactVersionPattern = regexp.MustCompile(`^=?\s*v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+	// registrySourcePattern matches a module registry address,
+	// [<host>/]<namespace>/<name>/<provider>, with an optional //subdirectory
+	registrySourcePattern = regexp.MustCompile(`^([a-z0-9.-]+\.[a-z]+/)?[A-Za-z0-9_-]+/[A-Za-z0-9_-]+/[A-Za-z0-9_-]+(//.*)?$`)
+	// gitHostPrefixes are the shorthand git sources terraform recognizes
+	gitHostPrefixes = []string{"github.com/", "bitbucket.org/", "git@"}
+)
+
+// ModuleSource is a module call of a configuration and where it comes from
+type ModuleSource struct {
+	// File and Line locate the module block
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Call string `json:"call"`
+	// Source is the call's source, Version its version constraint, if any
+	Source  string `json:"source"`
+	Version string `json:"version,omitempty"`
+}
+
+// String locates the call
+func (m ModuleSource) String() string {
+	return fmt.Sprintf("%s:%d module %q (%s)", m.File, m.Line, m.Call, m.Source)
+}
+
+// PinningError returns why the call's module is not pinned to one release,
+// or nil. Local paths are part of the repository and pinned by its commit. A
+// git source needs a ref that is a release tag or a commit, not a branch or
+// the default branch; a registry source needs a version allowing exactly one
+// release. Any other remote source, such as an HTTP archive or a bucket, is
+// refused, since its content can change under the same address
+func (m ModuleSource) PinningError() error {
+	source := m.Source
+	switch {
+	case strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../"):
+		return nil
+	case isGitSource(source):
+		ref, err := gitRef(source)
+		if err != nil {
+			return err
+		}
+		switch {
+		case ref == "":
+			return fmt.Errorf("git source %q has no ref and follows the default branch; add ?ref=<release tag>", source)
+		case !releaseTagPattern.MatchString(ref) && !commitPattern.MatchString(ref):
+			return fmt.Errorf("git source %q is pinned to %q, which is not a release tag or a full commit SHA", source, ref)
+		}
+		return nil
+	case registrySourcePattern.MatchString(source):
+		switch {
+		case m.Version == "":
+			return fmt.Errorf("registry source %q has no version and takes the latest release", source)
+		case !exactVersionPattern.MatchString(strings.TrimSpace(m.Version)):
+			return fmt.Errorf("registry source %q has version %q, which allows more than one release; use an exact version", source, m.Version)
+		}
+		return nil
+	}
+	return fmt.Errorf("source %q is neither local, git nor registry, and cannot be pinned; vendor it or publish it with a release tag", source)
+}
+
+// isGitSource reports whether source is fetched with git
+func isGitSource(source string) bool {
+	if strings.HasPrefix(source, "git::") {
+		return true
+	}
+	for _, prefix := range gitHostPrefixes {
+		if strings.HasPrefix(source, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// gitRef returns the ref query argument of a git source, empty without one
+func gitRef(source string) (string, error) {
+	question := strings.Index(source, "?")
+	if question < 0 {
+		return "", nil
+	}
+	query, err := url.ParseQuery(source[question+1:])
+	if err != nil {
+		return "", fmt.Errorf("git source %q has a malformed query: %w", source, err)
+	}
+	return query.Get("ref"), nil
+}
+
+// FindModuleSourcesE returns every module call in the .tf files under root,
+// skipping .terraform folders, by file and line. A source or version that is
+// not a literal string is an error, as it is for terraform
+func FindModuleSourcesE(root string) ([]ModuleSource, error) {
+	parser := hclparse.NewParser()
+	var sources []ModuleSource
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".terraform" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".tf" {
+			return nil
+		}
+
+		parsed, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return diags
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			return fmt.Errorf("%s is not native HCL syntax", path)
+		}
+		for _, block := range body.Blocks {
+			if block.Type != "module" || len(block.Labels) != 1 {
+				continue
+			}
+			call := ModuleSource{File: path, Line: block.DefRange().Start.Line, Call: block.Labels[0]}
+			for name, target := range map[string]*string{"source": &call.Source, "version": &call.Version} {
+				attribute, exists := block.Body.Attributes[name]
+				if !exists {
+					continue
+				}
+				value, diags := attribute.Expr.Value(nil)
+				if diags.HasErrors() || !value.Type().Equals(cty.String) {
+					return fmt.Errorf("%s: %s of module %s is not a literal string", path, name, call.Call)
+				}
+				*target = value.AsString()
+			}
+			if call.Source == "" {
+				return fmt.Errorf("%s: module %s has no source", path, call.Call)
+			}
+			sources = append(sources, call)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].File != sources[j].File {
+			return sources[i].File < sources[j].File
+		}
+		return sources[i].Line < sources[j].Line
+	})
+	return sources, nil
+}
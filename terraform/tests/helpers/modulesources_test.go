@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleSourcePinningError(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		source, version string
+		pinned          bool
+	}{
+		"local":                {source: "../../modules/networking", pinned: true},
+		"git tag":              {source: "git::https://github.com/pollinate/modules.git//networking?ref=v1.4.0", pinned: true},
+		"github shorthand sha": {source: "github.com/pollinate/modules?ref=0123456789abcdef0123456789abcdef01234567", pinned: true},
+		"git branch":           {source: "git::https://github.com/pollinate/modules.git?ref=main"},
+		"git without ref":      {source: "git@github.com:pollinate/modules.git"},
+		"short sha":            {source: "github.com/pollinate/modules?ref=0123456"},
+		"registry exact":       {source: "Azure/naming/azurerm", version: "0.4.1", pinned: true},
+		"registry equals":      {source: "app.terraform.io/pollinate/network/azurerm//subnet", version: "= 2.0.0", pinned: true},
+		"registry pessimistic": {source: "Azure/naming/azurerm", version: "~> 0.4"},
+		"registry range":       {source: "Azure/naming/azurerm", version: ">= 0.4.0, < 1.0.0"},
+		"registry without":     {source: "Azure/naming/azurerm"},
+		"http archive":         {source: "https://example.com/modules/networking.zip"},
+		"bucket":               {source: "s3::https://s3.amazonaws.com/modules/networking.zip"},
+	}
+	for name, c := range cases {
+		err := ModuleSource{Source: c.source, Version: c.version}.PinningError()
+		assert.Equal(t, c.pinned, err == nil, "%s: %v", name, err)
+	}
+}
+
+func TestFindModuleSources(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	write := func(path, content string) {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("environments/dev/main.tf", `
+module "naming" {
+  source  = "Azure/naming/azurerm"
+  version = "~> 0.4"
+}
+
+module "network" {
+  source = "../../modules/networking"
+}
+`)
+	write("environments/dev/.terraform/modules/naming/main.tf", `module "ignored" { source = "github.com/acme/x" }`)
+
+	sources, err := FindModuleSourcesE(root)
+	if !assert.NoError(t, err) || !assert.Len(t, sources, 2, "modules downloaded into .terraform are not audited") {
+		return
+	}
+	assert.Equal(t, ModuleSource{
+		File: filepath.Join(root, "environments/dev/main.tf"), Line: 2, Call: "naming",
+		Source: "Azure/naming/azurerm", Version: "~> 0.4",
+	}, sources[0])
+	assert.Equal(t, "network", sources[1].Call)
+
+	write("environments/prod/main.tf", `
+variable "ref" {}
+module "network" { source = "github.com/acme/x?ref=${var.ref}" }
+`)
+	_, err = FindModuleSourcesE(root)
+	assert.Error(t, err, "a source that is not a literal cannot be audited")
+}
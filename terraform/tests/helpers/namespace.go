@@ -0,0 +1,56 @@
+package helpers
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// NamespaceTag is the tag holding the namespace of test resources, which
+	// ttk inventory and ttk janitor filter on
+	NamespaceTag = "TestNamespace"
+
+	// maxNamespaceLength keeps namespaced resource group names well inside
+	// Azure's limits
+	maxNamespaceLength = 12
+
+	// defaultNamespace is used when neither the environment nor CI names one
+	defaultNamespace = "local"
+)
+
+// nonNamespaceChars are dropped from namespaces, which go into names
+var nonNamespaceChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// NormalizeNamespace lowercases value and keeps its first 12 letters and
+// digits, so it fits resource names; "" when nothing is left
+func NormalizeNamespace(value string) string {
+	namespace := nonNamespaceChars.ReplaceAllString(strings.ToLower(value), "")
+	if len(namespace) > maxNamespaceLength {
+		namespace = namespace[:maxNamespaceLength]
+	}
+	return namespace
+}
+
+// Namespace returns the namespace the resources of this runner are named and
+// tagged with, so engineers sharing a subscription keep apart: TEST_NAMESPACE
+// if set, else the CI job (GITHUB_JOB or Azure Pipelines' AGENT_JOBNAME)
+// prefixed with "ci", else the user (USER or USERNAME)
+func Namespace() string {
+	if namespace := NormalizeNamespace(os.Getenv("TEST_NAMESPACE")); namespace != "" {
+		return namespace
+	}
+	for _, key := range []string{"GITHUB_JOB", "AGENT_JOBNAME"} {
+		if job := os.Getenv(key); job != "" {
+			if namespace := NormalizeNamespace("ci" + job); namespace != "" {
+				return namespace
+			}
+		}
+	}
+	for _, key := range []string{"USER", "USERNAME"} {
+		if namespace := NormalizeNamespace(os.Getenv(key)); namespace != "" {
+			return namespace
+		}
+	}
+	return defaultNamespace
+}
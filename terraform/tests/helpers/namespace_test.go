@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeNamespace(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "janedoe", NormalizeNamespace("Jane.Doe"))
+	assert.Equal(t, "cinightlyapp", NormalizeNamespace("ci-nightly-apply-tests"))
+	assert.Equal(t, "", NormalizeNamespace("__"))
+}
+
+func TestNamespace(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{"explicit", map[string]string{"TEST_NAMESPACE": "Team-A", "GITHUB_JOB": "terratest", "USER": "jane"}, "teama"},
+		{"github job", map[string]string{"GITHUB_JOB": "terratest", "USER": "runner"}, "citerratest"},
+		{"azure pipelines job", map[string]string{"AGENT_JOBNAME": "Nightly", "USER": "vsts"}, "cinightly"},
+		{"user", map[string]string{"USER": "jane.doe"}, "janedoe"},
+		{"windows user", map[string]string{"USERNAME": "JDoe"}, "jdoe"},
+		{"nobody", map[string]string{}, "local"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, key := range []string{"TEST_NAMESPACE", "GITHUB_JOB", "AGENT_JOBNAME", "USER", "USERNAME"} {
+				t.Setenv(key, tc.env[key])
+			}
+			assert.Equal(t, tc.expected, Namespace())
+		})
+	}
+}
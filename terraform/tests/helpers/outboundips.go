@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"testing"
+)
+
+// OutboundIPsE returns the outbound IP addresses Azure reports for a
+// Container App, read from ARM rather than terraform state. They are the
+// egress addresses of its environment, shared by every app in it
+func OutboundIPsE(t *testing.T, resourceGroupName, appName string) ([]string, error) {
+	var ips []string
+	if err := AzCLIJSONE(t, &ips, "containerapp", "show", "--resource-group", resourceGroupName,
+		"--name", appName, "--query", "properties.outboundIpAddresses"); err != nil {
+		return nil, fmt.Errorf("reading outbound IPs of %s: %w", appName, err)
+	}
+	return ips, nil
+}
+
+// NormalizeIPsE returns ips in canonical form, sorted and without
+// duplicates, so lists from terraform and ARM compare as sets. Anything that
+// is not an IP address is an error: a firewall rule cannot be built from it
+func NormalizeIPsE(ips []string) ([]string, error) {
+	seen := map[string]bool{}
+	normalized := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return nil, fmt.Errorf("%q is not an IP address", ip)
+		}
+		if canonical := parsed.String(); !seen[canonical] {
+			seen[canonical] = true
+			normalized = append(normalized, canonical)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// DiffIPs returns the addresses of after that are not in before (added) and
+// those of before no longer in after (removed). Both must be normalized
+func DiffIPs(before, after []string) (added, removed []string) {
+	inBefore, inAfter := map[string]bool{}, map[string]bool{}
+	for _, ip := range before {
+		inBefore[ip] = true
+	}
+	for _, ip := range after {
+		inAfter[ip] = true
+		if !inBefore[ip] {
+			added = append(added, ip)
+		}
+	}
+	for _, ip := range before {
+		if !inAfter[ip] {
+			removed = append(removed, ip)
+		}
+	}
+	return added, removed
+}
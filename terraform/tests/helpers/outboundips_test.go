@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeIPs(t *testing.T) {
+	t.Parallel()
+
+	normalized, err := NormalizeIPsE([]string{"20.1.2.3", "4.150.0.9", "20.1.2.3", "2001:0db8::0001"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"20.1.2.3", "2001:db8::1", "4.150.0.9"}, normalized)
+	}
+
+	_, err = NormalizeIPsE([]string{"20.1.2.3", "20.1.2.0/24"})
+	assert.Error(t, err, "a range is not an address a firewall rule can be built from")
+
+	normalized, err = NormalizeIPsE(nil)
+	if assert.NoError(t, err) {
+		assert.Empty(t, normalized)
+	}
+}
+
+func TestDiffIPs(t *testing.T) {
+	t.Parallel()
+
+	added, removed := DiffIPs([]string{"20.1.2.3", "20.1.2.4"}, []string{"20.1.2.4", "20.1.2.5"})
+	assert.Equal(t, []string{"20.1.2.5"}, added)
+	assert.Equal(t, []string{"20.1.2.3"}, removed)
+
+	added, removed = DiffIPs([]string{"20.1.2.3"}, []string{"20.1.2.3"})
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+}
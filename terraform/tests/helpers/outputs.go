@@ -0,0 +1,242 @@
+package helpers
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// unknownValueMarkers are what an unknown value looks like when it leaks
+// into state or output: the placeholder the legacy SDK stores for unknown
+// attributes and the text plans render for them
+var unknownValueMarkers = []string{
+	"74D93920-ED26-11E3-AC10-0800200C9A66",
+	"(known after apply)",
+}
+
+// outputBlocksE returns the output blocks declared in the .tf files of dir
+func outputBlocksE(dir string) ([]*hclsyntax.Block, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	var outputs []*hclsyntax.Block
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil, fmt.Errorf("%s is not native HCL syntax", file)
+		}
+		for _, block := range body.Blocks {
+			if block.Type == "output" && len(block.Labels) == 1 {
+				outputs = append(outputs, block)
+			}
+		}
+	}
+	return outputs, nil
+}
+
+// OptionalOutputsE returns the outputs declared in the .tf files of dir that
+// may legitimately be empty: those whose value is a conditional with a null
+// or empty branch, such as `var.enabled ? x.id : null`, or a try() falling
+// back to one, such as `try(x[0].id, "")`
+func OptionalOutputsE(dir string) (map[string]bool, error) {
+	outputs, err := outputBlocksE(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	optional := map[string]bool{}
+	for _, block := range outputs {
+		if value, exists := block.Body.Attributes["value"]; exists && mayBeEmpty(value.Expr) {
+			optional[block.Labels[0]] = true
+		}
+	}
+	return optional, nil
+}
+
+// SensitiveOutputsE returns the outputs declared in the .tf files of dir with
+// `sensitive = true`
+func SensitiveOutputsE(dir string) (map[string]bool, error) {
+	outputs, err := outputBlocksE(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sensitive := map[string]bool{}
+	for _, block := range outputs {
+		attribute, exists := block.Body.Attributes["sensitive"]
+		if !exists {
+			continue
+		}
+		value, diags := attribute.Expr.Value(&hcl.EvalContext{})
+		if !diags.HasErrors() && value.Type() == cty.Bool && value.IsKnown() && !value.IsNull() && value.True() {
+			sensitive[block.Labels[0]] = true
+		}
+	}
+	return sensitive, nil
+}
+
+// mayBeEmpty reports whether expr is a conditional or try() that can
+// evaluate to an empty literal
+func mayBeEmpty(expr hclsyntax.Expression) bool {
+	switch expr := expr.(type) {
+	case *hclsyntax.ConditionalExpr:
+		return isEmptyLiteral(expr.TrueResult) || isEmptyLiteral(expr.FalseResult)
+	case *hclsyntax.FunctionCallExpr:
+		return expr.Name == "try" && len(expr.Args) > 0 && isEmptyLiteral(expr.Args[len(expr.Args)-1])
+	}
+	return false
+}
+
+// isEmptyLiteral reports whether expr is null, "", [] or {}
+func isEmptyLiteral(expr hclsyntax.Expression) bool {
+	if len(expr.Variables()) > 0 {
+		return false
+	}
+	value, diags := expr.Value(&hcl.EvalContext{})
+	if diags.HasErrors() || !value.IsKnown() {
+		return false
+	}
+	if value.IsNull() {
+		return true
+	}
+	switch {
+	case value.Type() == cty.String:
+		return value.AsString() == ""
+	case value.Type().IsTupleType(), value.Type().IsObjectType(), value.Type().IsListType(), value.Type().IsMapType():
+		return value.LengthInt() == 0
+	}
+	return false
+}
+
+// OutputProblems checks output values as `terraform output -json` returns
+// them after an apply. Outputs not in optional must not be null or empty,
+// and no output may contain an unknown value marker at any depth. Problems
+// are returned sorted by output name
+func OutputProblems(values map[string]interface{}, optional map[string]bool) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		value := values[name]
+		if !optional[name] {
+			if empty, what := isEmptyOutput(value); empty {
+				problems = append(problems, fmt.Sprintf("output %s is %s", name, what))
+			}
+		}
+		problems = append(problems, unknownLeaks(name, value)...)
+	}
+	return problems
+}
+
+// isEmptyOutput reports whether value is null, "", [] or {}, and which
+func isEmptyOutput(value interface{}) (bool, string) {
+	switch value := value.(type) {
+	case nil:
+		return true, "null"
+	case string:
+		return strings.TrimSpace(value) == "", "an empty string"
+	case []interface{}:
+		return len(value) == 0, "an empty list"
+	case map[string]interface{}:
+		return len(value) == 0, "an empty map"
+	}
+	return false, ""
+}
+
+// unknownLeaks returns a problem for every string under path holding an
+// unknown value marker
+func unknownLeaks(path string, value interface{}) []string {
+	var problems []string
+	switch value := value.(type) {
+	case string:
+		for _, marker := range unknownValueMarkers {
+			if strings.Contains(value, marker) {
+				problems = append(problems, fmt.Sprintf("output %s holds an unknown value (%s)", path, marker))
+			}
+		}
+	case []interface{}:
+		for i, element := range value {
+			problems = append(problems, unknownLeaks(fmt.Sprintf("%s[%d]", path, i), element)...)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			problems = append(problems, unknownLeaks(path+"."+key, value[key])...)
+		}
+	}
+	return problems
+}
+
+// AssertOutputsUsable fails the test when an output of the configuration
+// applied with options is null, empty or holds an unknown value, which
+// downstream consumers would silently get. Outputs declared as conditional
+// on a feature (see OptionalOutputsE) may be empty. Outputs are also checked
+// against the committed module output schemas (see OutputSchemaProblemsE)
+func AssertOutputsUsable(t *testing.T, options *terraform.Options) {
+	values, err := terraform.OutputAllE(t, options)
+	if err != nil {
+		t.Errorf("Reading outputs of %s: %v", options.TerraformDir, err)
+		return
+	}
+	optional, err := OptionalOutputsE(options.TerraformDir)
+	if err != nil {
+		t.Errorf("Reading output declarations of %s: %v", options.TerraformDir, err)
+		return
+	}
+	for _, problem := range OutputProblems(values, optional) {
+		t.Errorf("%s: %s", options.TerraformDir, problem)
+	}
+	schemaProblems, err := OutputSchemaProblemsE(options.TerraformDir, values)
+	if err != nil {
+		t.Errorf("Checking outputs of %s against their schema: %v", options.TerraformDir, err)
+		return
+	}
+	for _, problem := range schemaProblems {
+		t.Errorf("%s: %s", options.TerraformDir, problem)
+	}
+}
+
+// InitAndApply runs terraform init and apply like terraform.InitAndApply,
+// then checks the outputs with AssertOutputsUsable. Both run as the apply
+// stage, with init as a stage of its own, so SKIP_apply reuses what a
+// previous run kept with SKIP_destroy (see Destroy) and returns ""
+func InitAndApply(t *testing.T, options *terraform.Options) string {
+	var output string
+	applyStage(t, options, func() {
+		initStage(t, options)
+		output = terraform.Apply(t, options)
+		AssertOutputsUsable(t, options)
+	})
+	return output
+}
+
+// Apply runs terraform apply like terraform.Apply, then checks the outputs
+// with AssertOutputsUsable
+func Apply(t *testing.T, options *terraform.Options) string {
+	output := terraform.Apply(t, options)
+	AssertOutputsUsable(t, options)
+	return output
+}
@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionalOutputsE(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	outputs := `
+output "id" {
+  value = azurerm_container_registry.this.id
+}
+
+output "admin_username" {
+  value = var.admin_enabled ? azurerm_container_registry.this.admin_username : null
+}
+
+output "disabled_first" {
+  value = var.enabled ? "" : azurerm_container_registry.this.name
+}
+
+output "scope_map_id" {
+  value = try(azurerm_container_registry_scope_map.pull[0].id, null)
+}
+
+output "dns_servers" {
+  value = try(azurerm_virtual_network_dns_servers.this[0].dns_servers, [])
+}
+
+output "fallback_name" {
+  value = try(module.app[0].name, "default")
+}
+
+output "either_name" {
+  value = var.primary ? module.a.name : module.b.name
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "outputs.tf"), []byte(outputs), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	optional, err := OptionalOutputsE(dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]bool{
+			"admin_username": true,
+			"disabled_first": true,
+			"scope_map_id":   true,
+			"dns_servers":    true,
+		}, optional)
+	}
+}
+
+func TestSensitiveOutputsE(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	outputs := `
+output "connection_string" {
+  value     = azurerm_application_insights.this.connection_string
+  sensitive = true
+}
+
+output "app_id" {
+  value     = azurerm_application_insights.this.app_id
+  sensitive = false
+}
+
+output "id" {
+  value = azurerm_application_insights.this.id
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "outputs.tf"), []byte(outputs), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sensitive, err := SensitiveOutputsE(dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]bool{"connection_string": true}, sensitive)
+	}
+}
+
+func TestOutputProblems(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]interface{}{
+		"id":             "/subscriptions/0000/resourceGroups/rg",
+		"admin_username": nil,
+		"name":           nil,
+		"url":            "  ",
+		"ips":            []interface{}{},
+		"tags":           map[string]interface{}{},
+		"enabled":        false,
+		"count":          float64(0),
+		"fqdn":           "74D93920-ED26-11E3-AC10-0800200C9A66",
+		"nested": map[string]interface{}{
+			"addresses": []interface{}{"10.0.0.4", "(known after apply)"},
+		},
+	}
+	optional := map[string]bool{"admin_username": true}
+
+	assert.Equal(t, []string{
+		"output fqdn holds an unknown value (74D93920-ED26-11E3-AC10-0800200C9A66)",
+		"output ips is an empty list",
+		"output name is null",
+		"output nested.addresses[1] holds an unknown value ((known after apply))",
+		"output tags is an empty map",
+		"output url is an empty string",
+	}, OutputProblems(values, optional))
+}
+
+func TestOutputProblemsOptionalStillChecksLeaks(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]interface{}{"url": "https://(known after apply)"}
+	assert.Len(t, OutputProblems(values, map[string]bool{"url": true}), 1)
+}
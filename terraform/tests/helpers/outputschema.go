@@ -0,0 +1,296 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// outputSchemaDir holds the JSON Schema of every module's outputs, one file
+// per module, describing `terraform output -json` values as an object
+const outputSchemaDir = "testdata/output-schemas"
+
+// OutputSchemaPath returns the committed output schema of the named module
+func OutputSchemaPath(module string) string {
+	return filepath.Join(outputSchemaDir, module+".json")
+}
+
+// moduleOutput is an output of a module, by module directory name
+type moduleOutput struct {
+	Module string
+	Output string
+}
+
+// moduleOfDir returns the module name of dir when dir is a module of the
+// terraform tree, or a copy of one made by CopyTerraformDirToTemp
+func moduleOfDir(dir string) (string, bool) {
+	clean := filepath.Clean(dir)
+	if filepath.Base(filepath.Dir(clean)) != "modules" {
+		return "", false
+	}
+	return filepath.Base(clean), true
+}
+
+// moduleSourcesE returns the local modules called in the .tf files of dir,
+// by module call name
+func moduleSourcesE(dir string) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	sources := map[string]string{}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil, fmt.Errorf("%s is not native HCL syntax", file)
+		}
+		for _, block := range body.Blocks {
+			if block.Type != "module" || len(block.Labels) != 1 {
+				continue
+			}
+			attribute, exists := block.Body.Attributes["source"]
+			if !exists {
+				continue
+			}
+			value, diags := attribute.Expr.Value(&hcl.EvalContext{})
+			if diags.HasErrors() || !value.Type().Equals(cty.String) || value.IsNull() {
+				continue
+			}
+			source := value.AsString()
+			if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
+				if module, ok := moduleOfDir(filepath.Join(dir, source)); ok {
+					sources[block.Labels[0]] = module
+				}
+			}
+		}
+	}
+	return sources, nil
+}
+
+// ForwardedModuleOutputsE returns the outputs declared in the .tf files of
+// dir that pass an output of a local module through unchanged, such as
+// `value = module.container_app.name` or `module.app[0].name`
+func ForwardedModuleOutputsE(dir string) (map[string]moduleOutput, error) {
+	outputs, err := outputBlocksE(dir)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := moduleSourcesE(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	forwarded := map[string]moduleOutput{}
+	for _, block := range outputs {
+		attribute, exists := block.Body.Attributes["value"]
+		if !exists {
+			continue
+		}
+		traversal, ok := attribute.Expr.(*hclsyntax.ScopeTraversalExpr)
+		if !ok || traversal.Traversal.RootName() != "module" {
+			continue
+		}
+		// module.<call>, an optional instance key, then the output
+		var names []string
+		for _, step := range traversal.Traversal[1:] {
+			switch step := step.(type) {
+			case hcl.TraverseAttr:
+				names = append(names, step.Name)
+			case hcl.TraverseIndex:
+				if len(names) != 1 {
+					names = nil
+				}
+			}
+		}
+		if len(names) != 2 {
+			continue
+		}
+		if module, ok := sources[names[0]]; ok {
+			forwarded[block.Labels[0]] = moduleOutput{Module: module, Output: names[1]}
+		}
+	}
+	return forwarded, nil
+}
+
+// compileOutputSchemaE compiles the output schema of module in schemaDir, or
+// the part of it at pointer, e.g. "/properties/name"
+func compileOutputSchemaE(schemaDir, module, pointer string) (*jsonschema.Schema, error) {
+	path, err := filepath.Abs(filepath.Join(schemaDir, module+".json"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no output schema for module %s: %w", module, err)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	if pointer == "" {
+		return compiler.Compile(path)
+	}
+	return compiler.Compile(path + "#" + pointer)
+}
+
+// schemaViolations returns the failed assertions of a validation error as
+// "<prefix><instance location>: <message>", sorted
+func schemaViolations(prefix string, err error) []string {
+	var validation *jsonschema.ValidationError
+	if !errors.As(err, &validation) {
+		return []string{fmt.Sprintf("%s: %v", prefix, err)}
+	}
+
+	var violations []string
+	var leaves func(*jsonschema.ValidationError)
+	leaves = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			location := strings.ReplaceAll(strings.TrimPrefix(ve.InstanceLocation, "/"), "/", ".")
+			if location != "" {
+				location = "." + location
+			}
+			violations = append(violations, fmt.Sprintf("%s%s: %s", prefix, location, ve.Message))
+		}
+		for _, cause := range ve.Causes {
+			leaves(cause)
+		}
+	}
+	leaves(validation)
+	sort.Strings(violations)
+	return violations
+}
+
+// outputSchemaProblemsE checks output values of the configuration in
+// terraformDir against the schemas in schemaDir: all of them against their
+// module's schema when terraformDir is a module, and those forwarding a
+// module output unchanged against that output's schema otherwise
+func outputSchemaProblemsE(schemaDir, terraformDir string, values map[string]interface{}) ([]string, error) {
+	if module, ok := moduleOfDir(terraformDir); ok {
+		schema, err := compileOutputSchemaE(schemaDir, module, "")
+		if err != nil {
+			return nil, err
+		}
+		if err := schema.Validate(values); err != nil {
+			return schemaViolations("outputs", err), nil
+		}
+		return nil, nil
+	}
+
+	forwarded, err := ForwardedModuleOutputsE(terraformDir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(forwarded))
+	for name := range forwarded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		value, exists := values[name]
+		if !exists {
+			continue
+		}
+		source := forwarded[name]
+		schema, err := compileOutputSchemaE(schemaDir, source.Module, "/properties/"+source.Output)
+		if err != nil {
+			return nil, fmt.Errorf("output %s from %s.%s: %w", name, source.Module, source.Output, err)
+		}
+		if err := schema.Validate(value); err != nil {
+			problems = append(problems, schemaViolations("output "+name, err)...)
+		}
+	}
+	return problems, nil
+}
+
+// OutputSchemaProblemsE checks output values of the configuration applied
+// from terraformDir, as `terraform output -json` returns them, against the
+// committed module output schemas (see OutputSchemaPath)
+func OutputSchemaProblemsE(terraformDir string, values map[string]interface{}) ([]string, error) {
+	return outputSchemaProblemsE(outputSchemaDir, terraformDir, values)
+}
+
+// outputSchemaDeclarationProblemsE checks that the output schema of the
+// module in moduleDir compiles and describes exactly the outputs the module
+// declares, each of them required
+func outputSchemaDeclarationProblemsE(schemaDir, moduleDir string) ([]string, error) {
+	module, ok := moduleOfDir(moduleDir)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a module directory", moduleDir)
+	}
+	if _, err := compileOutputSchemaE(schemaDir, module, ""); err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filepath.Join(schemaDir, module+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var schema struct {
+		Required             []string                   `json:"required"`
+		Properties           map[string]json.RawMessage `json:"properties"`
+		AdditionalProperties *bool                      `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		return nil, fmt.Errorf("parsing output schema of %s: %w", module, err)
+	}
+
+	outputs, err := outputBlocksE(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+	declared := map[string]bool{}
+	for _, block := range outputs {
+		declared[block.Labels[0]] = true
+	}
+	required := map[string]bool{}
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	var problems []string
+	if schema.AdditionalProperties == nil || *schema.AdditionalProperties {
+		problems = append(problems, "schema allows outputs the module does not declare: set additionalProperties to false")
+	}
+	for name := range declared {
+		if _, exists := schema.Properties[name]; !exists {
+			problems = append(problems, fmt.Sprintf("output %s has no schema", name))
+		}
+		if !required[name] {
+			problems = append(problems, fmt.Sprintf("output %s is not required by the schema", name))
+		}
+	}
+	for name := range schema.Properties {
+		if !declared[name] {
+			problems = append(problems, fmt.Sprintf("schema describes output %s, which the module does not declare", name))
+		}
+	}
+	for name := range required {
+		if !declared[name] {
+			problems = append(problems, fmt.Sprintf("schema requires output %s, which the module does not declare", name))
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// OutputSchemaDeclarationProblemsE checks that the committed output schema
+// of the module in moduleDir compiles and matches the outputs it declares,
+// so an output cannot be added, renamed or removed without its schema
+func OutputSchemaDeclarationProblemsE(moduleDir string) ([]string, error) {
+	return outputSchemaDeclarationProblemsE(outputSchemaDir, moduleDir)
+}
@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testOutputSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["id", "url"],
+  "additionalProperties": false,
+  "properties": {
+    "id": { "type": "string", "pattern": "^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\\.App/containerApps/[^/]+$" },
+    "url": { "type": ["string", "null"], "format": "uri" }
+  }
+}`
+
+const testAppID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.App/containerApps/app"
+
+// writeOutputSchemaTree lays out a schema dir, a module "app" declaring the
+// outputs of testOutputSchema and a fixture forwarding them
+func writeOutputSchemaTree(t *testing.T) (schemaDir, moduleDir, fixtureDir string) {
+	root := t.TempDir()
+	schemaDir = filepath.Join(root, "schemas")
+	moduleDir = filepath.Join(root, "modules", "app")
+	fixtureDir = filepath.Join(root, "tests", "fixtures", "app")
+	files := map[string]string{
+		filepath.Join(schemaDir, "app.json"): testOutputSchema,
+		filepath.Join(moduleDir, "outputs.tf"): `output "id" {
+  value = azurerm_container_app.this.id
+}
+
+output "url" {
+  value = null
+}
+`,
+		filepath.Join(fixtureDir, "main.tf"): `module "app" {
+  source = "../../../modules/app"
+}
+
+module "other" {
+  source = "Azure/naming/azurerm"
+}
+`,
+		filepath.Join(fixtureDir, "outputs.tf"): `output "app_id" {
+  value = module.app.id
+}
+
+output "app_url" {
+  value = module.app[0].url
+}
+
+output "upper_id" {
+  value = upper(module.app.id)
+}
+
+output "other_name" {
+  value = module.other.name
+}
+`,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return schemaDir, moduleDir, fixtureDir
+}
+
+func TestForwardedModuleOutputsE(t *testing.T) {
+	t.Parallel()
+
+	_, _, fixtureDir := writeOutputSchemaTree(t)
+	forwarded, err := ForwardedModuleOutputsE(fixtureDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]moduleOutput{
+		"app_id":  {Module: "app", Output: "id"},
+		"app_url": {Module: "app", Output: "url"},
+	}, forwarded)
+}
+
+func TestOutputSchemaProblemsE(t *testing.T) {
+	t.Parallel()
+
+	schemaDir, moduleDir, fixtureDir := writeOutputSchemaTree(t)
+
+	testCases := []struct {
+		name     string
+		dir      string
+		values   map[string]interface{}
+		expected []string
+	}{
+		{"module_valid", moduleDir, map[string]interface{}{"id": testAppID, "url": nil}, nil},
+		{"module_bad_id", moduleDir, map[string]interface{}{"id": "app", "url": "https://app.example.com"},
+			[]string{`outputs.id: does not match pattern '^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\\.App/containerApps/[^/]+$'`}},
+		{"module_missing_output", moduleDir, map[string]interface{}{"id": testAppID},
+			[]string{"outputs: missing properties: 'url'"}},
+		{"module_extra_output", moduleDir, map[string]interface{}{"id": testAppID, "url": nil, "name": "app"},
+			[]string{"outputs: additionalProperties 'name' not allowed"}},
+		{"fixture_valid", fixtureDir, map[string]interface{}{"app_id": testAppID, "app_url": "https://app.example.com", "upper_id": "X"}, nil},
+		{"fixture_bad_url", fixtureDir, map[string]interface{}{"app_id": testAppID, "app_url": 42},
+			[]string{"output app_url: expected string or null, but got number"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			problems, err := outputSchemaProblemsE(schemaDir, tc.dir, tc.values)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, problems)
+			}
+		})
+	}
+}
+
+func TestOutputSchemaProblemsMissingSchema(t *testing.T) {
+	t.Parallel()
+
+	_, moduleDir, _ := writeOutputSchemaTree(t)
+	_, err := outputSchemaProblemsE(t.TempDir(), moduleDir, map[string]interface{}{})
+	assert.ErrorContains(t, err, "no output schema for module app")
+}
+
+func TestOutputSchemaDeclarationProblemsE(t *testing.T) {
+	t.Parallel()
+
+	schemaDir, moduleDir, _ := writeOutputSchemaTree(t)
+	problems, err := outputSchemaDeclarationProblemsE(schemaDir, moduleDir)
+	if assert.NoError(t, err) {
+		assert.Empty(t, problems)
+	}
+
+	renamed := `output "id" {
+  value = azurerm_container_app.this.id
+}
+
+output "application_url" {
+  value = null
+}
+`
+	if err := os.WriteFile(filepath.Join(moduleDir, "outputs.tf"), []byte(renamed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	problems, err = outputSchemaDeclarationProblemsE(schemaDir, moduleDir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"output application_url has no schema",
+			"output application_url is not required by the schema",
+			"schema describes output url, which the module does not declare",
+			"schema requires output url, which the module does not declare",
+		}, problems)
+	}
+}
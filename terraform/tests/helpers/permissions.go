@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// requiredPermissionsHeading starts the section of a module README that lists
+// the roles terraform needs to deploy the module
+const requiredPermissionsHeading = "## Required Permissions"
+
+// RequiredRole is one row of a module's Required Permissions table
+type RequiredRole struct {
+	Role string
+	// Scope is where the role is granted, e.g. "Resource group"
+	Scope string
+	// Reason says which resources or inputs need the role
+	Reason string
+}
+
+// DocumentedPermissionsE reads the Required Permissions table from the
+// README of the module in moduleDir
+func DocumentedPermissionsE(moduleDir string) ([]RequiredRole, error) {
+	readme := filepath.Join(moduleDir, "README.md")
+	file, err := os.Open(readme)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var roles []RequiredRole
+	inSection, rowsSeen := false, 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "## ") {
+			if inSection {
+				break
+			}
+			inSection = line == requiredPermissionsHeading
+			continue
+		}
+		if !inSection || !strings.HasPrefix(line, "|") {
+			continue
+		}
+
+		// The header and separator rows come first
+		if rowsSeen++; rowsSeen <= 2 {
+			continue
+		}
+		cells := strings.Split(strings.Trim(line, "|"), "|")
+		if len(cells) != 3 {
+			return nil, fmt.Errorf("%s: permission row %q should have role, scope and reason", readme, line)
+		}
+		roles = append(roles, RequiredRole{
+			Role:   strings.Trim(strings.TrimSpace(cells[0]), "`"),
+			Scope:  strings.TrimSpace(cells[1]),
+			Reason: strings.TrimSpace(cells[2]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("%s has no %q table", readme, requiredPermissionsHeading)
+	}
+	return roles, nil
+}
+
+// MissingPermission is an action Azure refused to the identity running terraform
+type MissingPermission struct {
+	Action string
+	Scope  string
+}
+
+func (p MissingPermission) String() string {
+	if p.Scope == "" {
+		return p.Action
+	}
+	return fmt.Sprintf("%s on %s", p.Action, p.Scope)
+}
+
+// RoleManagement reports whether the action grants or revokes access, which
+// Contributor cannot do: it needs Owner, User Access Administrator or Role
+// Based Access Control Administrator
+func (p MissingPermission) RoleManagement() bool {
+	action := strings.ToLower(p.Action)
+	return strings.HasPrefix(action, "microsoft.authorization/") &&
+		(strings.HasSuffix(action, "/write") || strings.HasSuffix(action, "/delete"))
+}
+
+// authorizationFailure matches AuthorizationFailed and LinkedAuthorizationFailed
+// messages from Azure Resource Manager
+var authorizationFailure = regexp.MustCompile(
+	`does not have (?:authorization|permission) to perform action(?:\(s\))? '([^']+)'(?: (?:over|on) (?:the linked )?scope(?:\(s\))? '([^']+)')?`)
+
+// MissingPermissions returns the actions Azure refused in terraform output,
+// sorted and without duplicates
+func MissingPermissions(output string) []MissingPermission {
+	seen := map[MissingPermission]bool{}
+	var missing []MissingPermission
+	for _, match := range authorizationFailure.FindAllStringSubmatch(output, -1) {
+		permission := MissingPermission{Action: match[1], Scope: match[2]}
+		if !seen[permission] {
+			seen[permission] = true
+			missing = append(missing, permission)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].String() < missing[j].String() })
+	return missing
+}
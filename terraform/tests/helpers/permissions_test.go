@@ -0,0 +1,56 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentedPermissions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	readme := "# Module\n\n## Required Permissions\n\nIntro.\n\n" +
+		"| Role | Scope | Needed for |\n| ---- | ----- | ---------- |\n" +
+		"| Contributor | Resource group | All resources |\n" +
+		"| `Role Based Access Control Administrator` | Resource group | `deployer_object_id` |\n\n" +
+		"## Inputs\n\n| Name | Description | Type |\n| --- | --- | --- |\n| name | Name | `string` |\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte(readme), 0o600))
+
+	roles, err := DocumentedPermissionsE(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []RequiredRole{
+		{Role: "Contributor", Scope: "Resource group", Reason: "All resources"},
+		{Role: "Role Based Access Control Administrator", Scope: "Resource group", Reason: "`deployer_object_id`"},
+	}, roles)
+
+	_, err = DocumentedPermissionsE(t.TempDir())
+	assert.Error(t, err, "A missing README is an error")
+}
+
+func TestMissingPermissions(t *testing.T) {
+	t.Parallel()
+
+	output := `Error: creating Role Assignment: unexpected status 403 (403 Forbidden) with error: AuthorizationFailed: ` +
+		`The client '00000000-0000-0000-0000-000000000001' with object id '00000000-0000-0000-0000-000000000001' does not have ` +
+		`authorization to perform action 'Microsoft.Authorization/roleAssignments/write' over scope ` +
+		`'/subscriptions/sub/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv' or the scope is invalid.
+Error: LinkedAuthorizationFailed: The client has permission to perform action 'Microsoft.App/managedEnvironments/write' on scope ` +
+		`'/subscriptions/sub/resourceGroups/rg'; however, it does not have permission to perform action(s) ` +
+		`'Microsoft.Network/virtualNetworks/subnets/join/action' on the linked scope(s) '/subscriptions/sub/resourceGroups/net' ` +
+		`(respectively) or the linked scope(s) are invalid.
+Error: AuthorizationFailed: does not have authorization to perform action 'Microsoft.Authorization/roleAssignments/write' over scope ` +
+		`'/subscriptions/sub/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv' or the scope is invalid.`
+
+	missing := MissingPermissions(output)
+	assert.Equal(t, []MissingPermission{
+		{Action: "Microsoft.Authorization/roleAssignments/write", Scope: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv"},
+		{Action: "Microsoft.Network/virtualNetworks/subnets/join/action", Scope: "/subscriptions/sub/resourceGroups/net"},
+	}, missing)
+	assert.True(t, missing[0].RoleManagement())
+	assert.False(t, missing[1].RoleManagement())
+	assert.False(t, MissingPermission{Action: "Microsoft.Authorization/roleAssignments/read"}.RoleManagement())
+	assert.Empty(t, MissingPermissions("Error: timeout while waiting for state"))
+}
@@ -0,0 +1,149 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/shell"
+	"gopkg.in/yaml.v3"
+)
+
+// DeliveryPipelineFile is the app delivery pipeline, relative to the tests
+// folder
+const DeliveryPipelineFile = "../../pipelines/azure-pipelines-app.yml"
+
+// branchCondition matches the terms of a template condition on the source
+// branch, e.g. eq(variables['Build.SourceBranch'], 'refs/heads/dev')
+var branchCondition = regexp.MustCompile(`(eq|ne)\(variables\['Build\.SourceBranch'\],\s*'([^']*)'\)`)
+
+// DeliveryPipeline is what a build of one branch gets from the delivery
+// pipeline's definition
+type DeliveryPipeline struct {
+	Branch string
+	// Variables are the pipeline variables, conditional ones included
+	Variables map[string]string
+	// Stages are the names of the pipeline's stages, in order
+	Stages []string
+}
+
+// pipelineDefinition is the part of an Azure Pipelines definition
+// LoadDeliveryPipelineE reads. Variables are a list of name / value pairs
+// and template conditions holding more of them
+type pipelineDefinition struct {
+	Variables []map[string]yaml.Node `yaml:"variables"`
+	Stages    []struct {
+		Stage string `yaml:"stage"`
+	} `yaml:"stages"`
+}
+
+// pipelineVariable is a name / value pair of pipeline variables
+type pipelineVariable struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// LoadDeliveryPipelineE reads the pipeline definition at path for a build
+// of branch, e.g. "refs/heads/dev". Variables under a template condition
+// count when the condition holds for the branch; conditions on anything but
+// the source branch are refused
+func LoadDeliveryPipelineE(path, branch string) (*DeliveryPipeline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var definition pipelineDefinition
+	if err := yaml.Unmarshal(content, &definition); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	pipeline := &DeliveryPipeline{Branch: branch, Variables: map[string]string{}}
+	for _, entry := range definition.Variables {
+		if _, named := entry["name"]; named {
+			var variable pipelineVariable
+			if err := decodeYAMLMap(entry, &variable); err != nil {
+				return nil, fmt.Errorf("variables of %s: %w", path, err)
+			}
+			pipeline.Variables[variable.Name] = variable.Value
+			continue
+		}
+		for condition, node := range entry {
+			holds, err := branchConditionHolds(condition, branch)
+			if err != nil {
+				return nil, fmt.Errorf("variables of %s: %w", path, err)
+			}
+			if !holds {
+				continue
+			}
+			var variables []pipelineVariable
+			if err := node.Decode(&variables); err != nil {
+				return nil, fmt.Errorf("variables of %s under %s: %w", path, condition, err)
+			}
+			for _, variable := range variables {
+				pipeline.Variables[variable.Name] = variable.Value
+			}
+		}
+	}
+	for _, stage := range definition.Stages {
+		pipeline.Stages = append(pipeline.Stages, stage.Stage)
+	}
+	return pipeline, nil
+}
+
+// decodeYAMLMap decodes a map of YAML nodes into value
+func decodeYAMLMap(entry map[string]yaml.Node, value interface{}) error {
+	content, err := yaml.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(content, value)
+}
+
+// branchConditionHolds evaluates a ${{ if ... }} template condition made of
+// eq / ne tests on the source branch, all of which must hold, as with and()
+func branchConditionHolds(condition, branch string) (bool, error) {
+	expression := strings.TrimSpace(condition)
+	if !strings.HasPrefix(expression, "${{ if ") || !strings.HasSuffix(expression, "}}") {
+		return false, fmt.Errorf("%q is not a template condition", condition)
+	}
+	expression = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(expression, "${{ if "), "}}"))
+
+	// Left of a single test or tests joined by and() is only its punctuation
+	terms := branchCondition.FindAllStringSubmatch(expression, -1)
+	rest := branchCondition.ReplaceAllString(expression, "")
+	if len(terms) > 1 {
+		rest = strings.TrimSuffix(strings.TrimPrefix(rest, "and("), ")")
+	}
+	if len(terms) == 0 || strings.Trim(rest, ", ") != "" {
+		return false, fmt.Errorf("condition %q is not a test of Build.SourceBranch", condition)
+	}
+	for _, term := range terms {
+		if (term[1] == "eq") != (term[2] == branch) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ImageVersionE returns the version the pipeline's SetVersion step tags
+// images with: git describe of the checkout, or v0.0.0-<commit> when that
+// fails
+func ImageVersionE(t *testing.T) (string, error) {
+	version, err := shell.RunCommandAndGetStdOutE(t, shell.Command{
+		Command: "git",
+		Args:    []string{"describe", "--tags", "--always", "--dirty"},
+	})
+	if err == nil {
+		return strings.TrimSpace(version), nil
+	}
+	commit, err := shell.RunCommandAndGetStdOutE(t, shell.Command{
+		Command: "git",
+		Args:    []string{"rev-parse", "--short", "HEAD"},
+	})
+	if err != nil {
+		return "", fmt.Errorf("reading the commit to version images with: %w", err)
+	}
+	return "v0.0.0-" + strings.TrimSpace(commit), nil
+}
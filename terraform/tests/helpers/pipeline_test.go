@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// deliveryPipelineFromHelpers is DeliveryPipelineFile from the helpers folder
+const deliveryPipelineFromHelpers = "../" + DeliveryPipelineFile
+
+func TestLoadDeliveryPipeline(t *testing.T) {
+	t.Parallel()
+
+	for branch, want := range map[string]map[string]string{
+		"refs/heads/dev":          {"environmentName": "dev", "containerAppName": "ca-finrisk-dev", "containerRegistry": "acrfinriskdev.azurecr.io"},
+		"refs/heads/main":         {"environmentName": "prod", "containerAppName": "ca-finrisk-prod", "containerRegistry": "acrfinriskprod.azurecr.io"},
+		"refs/heads/feature/docs": {"environmentName": "dev", "containerAppName": "ca-finrisk-dev", "resourceGroupName": "rg-finrisk-dev"},
+	} {
+		pipeline, err := LoadDeliveryPipelineE(deliveryPipelineFromHelpers, branch)
+		if !assert.NoError(t, err, branch) {
+			continue
+		}
+		assert.Equal(t, "applicant-validator", pipeline.Variables["imageName"], branch)
+		for name, value := range want {
+			assert.Equal(t, value, pipeline.Variables[name], "%s of %s", name, branch)
+		}
+		assert.Equal(t, []string{"Build", "Deploy", "Verify"}, pipeline.Stages, branch)
+	}
+}
+
+func TestLoadDeliveryPipelineRefusesOtherConditions(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "pipeline.yml")
+	definition := "variables:\n  - ${{ if eq(variables['Build.Reason'], 'PullRequest') }}:\n    - name: environmentName\n      value: 'pr'\n"
+	if err := os.WriteFile(path, []byte(definition), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadDeliveryPipelineE(path, "refs/heads/dev")
+	assert.ErrorContains(t, err, "not a test of Build.SourceBranch")
+}
+
+func TestBranchConditionHolds(t *testing.T) {
+	t.Parallel()
+
+	both := "${{ if and(ne(variables['Build.SourceBranch'], 'refs/heads/main'), ne(variables['Build.SourceBranch'], 'refs/heads/dev')) }}"
+	for branch, want := range map[string]bool{"refs/heads/main": false, "refs/heads/dev": false, "refs/pull/7/merge": true} {
+		holds, err := branchConditionHolds(both, branch)
+		if assert.NoError(t, err) {
+			assert.Equal(t, want, holds, branch)
+		}
+	}
+
+	_, err := branchConditionHolds("${{ if or(eq(variables['Build.SourceBranch'], 'refs/heads/main'), eq(variables['Build.SourceBranch'], 'refs/heads/dev')) }}", "refs/heads/dev")
+	assert.Error(t, err, "or() is not supported")
+}
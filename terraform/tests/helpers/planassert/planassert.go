@@ -0,0 +1,266 @@
+// Package planassert checks saved Terraform plans through their JSON
+// representation (`terraform show -json`), so validation tests assert on
+// what a plan would do rather than on strings in its output.
+//
+// Resources are named by their full address, e.g.
+// module.container_app.azurerm_container_app.this. Attributes are named by
+// a dotted path into the resource's planned values, with list indexes as
+// path elements:
+//
+//	template.0.container.0.cpu
+//
+// Values compare as they are in the plan JSON: numbers are float64 and
+// blocks are lists of maps, so expected values are normalized through JSON
+// first and 0.5 and float32(0.5) or []string and []interface{} compare equal.
+package planassert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+// ErrUnknown is returned for attributes only known after apply
+var ErrUnknown = errors.New("known after apply")
+
+// Plan is a parsed plan
+type Plan struct {
+	*terraform.PlanStruct
+}
+
+// ParseE parses plan JSON from `terraform show -json`, such as the plans
+// helpers.CachedPlanE returns
+func ParseE(planJSON string) (*Plan, error) {
+	plan, err := terraform.ParsePlanJSON(planJSON)
+	if err != nil {
+		return nil, fmt.Errorf("parsing plan: %w", err)
+	}
+	return &Plan{plan}, nil
+}
+
+// Parse parses plan JSON and fails the test on error
+func Parse(t *testing.T, planJSON string) *Plan {
+	plan, err := ParseE(planJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+// ShowE runs `terraform show -json` on the plan saved at options.PlanFilePath
+// and parses it. The plan JSON is not logged
+func ShowE(t *testing.T, options *terraform.Options) (*Plan, error) {
+	if options.PlanFilePath == "" {
+		return nil, errors.New("options have no PlanFilePath to show")
+	}
+	quiet := *options
+	quiet.Logger = logger.Discard
+	planJSON, err := terraform.ShowE(t, &quiet)
+	if err != nil {
+		return nil, err
+	}
+	return ParseE(planJSON)
+}
+
+// Show runs `terraform show -json` on the saved plan and fails the test on error
+func Show(t *testing.T, options *terraform.Options) *Plan {
+	plan, err := ShowE(t, options)
+	if err != nil {
+		t.Fatalf("Showing plan %s: %v", options.PlanFilePath, err)
+	}
+	return plan
+}
+
+// Planned reports whether the resource at address exists once the plan is
+// applied, whether it is created, updated or left as it is
+func (p *Plan) Planned(address string) bool {
+	_, ok := p.ResourcePlannedValuesMap[address]
+	return ok
+}
+
+// Actions returns the actions the plan takes on the resource at address,
+// e.g. [create] or [delete create] for a replacement; nil when it has none
+func (p *Plan) Actions(address string) []string {
+	change, ok := p.ResourceChangesMap[address]
+	if !ok || change.Change == nil {
+		return nil
+	}
+	actions := make([]string, 0, len(change.Change.Actions))
+	for _, action := range change.Change.Actions {
+		actions = append(actions, string(action))
+	}
+	return actions
+}
+
+// Destroys returns the addresses of the resources the plan deletes,
+// replacements included, sorted
+func (p *Plan) Destroys() []string {
+	var destroyed []string
+	for address, change := range p.ResourceChangesMap {
+		if change.Change != nil && (change.Change.Actions.Delete() || change.Change.Actions.Replace()) {
+			destroyed = append(destroyed, address)
+		}
+	}
+	sort.Strings(destroyed)
+	return destroyed
+}
+
+// AttributeE returns the planned value at path of the resource at address.
+// It returns ErrUnknown when the value is only known after apply
+func (p *Plan) AttributeE(address, path string) (interface{}, error) {
+	resource, ok := p.ResourcePlannedValuesMap[address]
+	if !ok {
+		return nil, fmt.Errorf("plan has no %s", address)
+	}
+	if change, ok := p.ResourceChangesMap[address]; ok && change.Change != nil {
+		if unknownAt(change.Change.AfterUnknown, path) {
+			return nil, fmt.Errorf("%s of %s is %w", path, address, ErrUnknown)
+		}
+	}
+	value, err := lookup(resource.AttributeValues, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s of %s: %w", path, address, err)
+	}
+	return value, nil
+}
+
+// lookup walks a dotted path into decoded JSON
+func lookup(value interface{}, path string) (interface{}, error) {
+	walked := []string{}
+	for _, element := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			next, ok := node[element]
+			if !ok {
+				return nil, fmt.Errorf("no %q in %s", element, describePath(walked))
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(element)
+			if err != nil {
+				return nil, fmt.Errorf("%s is a list, %q is not an index", describePath(walked), element)
+			}
+			if index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("%s has %d elements, no index %d", describePath(walked), len(node), index)
+			}
+			value = node[index]
+		default:
+			return nil, fmt.Errorf("%s is %v, not a block, map or list", describePath(walked), node)
+		}
+		walked = append(walked, element)
+	}
+	return value, nil
+}
+
+// unknownAt reports whether after_unknown marks path, or a block or list
+// holding it, as known after apply
+func unknownAt(afterUnknown interface{}, path string) bool {
+	value := afterUnknown
+	for _, element := range strings.Split(path, ".") {
+		if value == true {
+			return true
+		}
+		next, err := lookup(value, element)
+		if err != nil {
+			return false
+		}
+		value = next
+	}
+	return value == true
+}
+
+// describePath names a partly walked path in errors
+func describePath(walked []string) string {
+	if len(walked) == 0 {
+		return "the resource"
+	}
+	return strings.Join(walked, ".")
+}
+
+// normalize converts value to what the same value decodes to from JSON
+func normalize(value interface{}) (interface{}, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(content, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// AssertResourcePlanned asserts the resource at address exists once the plan
+// is applied
+func AssertResourcePlanned(t assert.TestingT, plan *Plan, address string, msgAndArgs ...interface{}) bool {
+	if plan.Planned(address) {
+		return true
+	}
+	return assert.Fail(t, fmt.Sprintf("Plan should contain %s", address), msgAndArgs...)
+}
+
+// AssertResourceNotPlanned asserts the resource at address does not exist
+// once the plan is applied, e.g. a resource a variable turns off
+func AssertResourceNotPlanned(t assert.TestingT, plan *Plan, address string, msgAndArgs ...interface{}) bool {
+	if !plan.Planned(address) {
+		return true
+	}
+	return assert.Fail(t, fmt.Sprintf("Plan should not contain %s", address), msgAndArgs...)
+}
+
+// AssertAttributeEquals asserts the planned value at path of the resource at
+// address equals expected. Values only known after apply never do
+func AssertAttributeEquals(t assert.TestingT, plan *Plan, address, path string, expected interface{}, msgAndArgs ...interface{}) bool {
+	actual, err := plan.AttributeE(address, path)
+	if err != nil {
+		return assert.Fail(t, err.Error(), msgAndArgs...)
+	}
+	normalized, err := normalize(expected)
+	if err != nil {
+		return assert.Fail(t, fmt.Sprintf("Expected value %#v is not JSON: %v", expected, err), msgAndArgs...)
+	}
+	if reflect.DeepEqual(normalized, actual) {
+		return true
+	}
+	return assert.Fail(t, fmt.Sprintf("%s of %s is not as expected\nexpected: %#v\nactual  : %#v", path, address, normalized, actual), msgAndArgs...)
+}
+
+// AssertAttributeLen asserts the planned list, block list or map at path of
+// the resource at address has length elements
+func AssertAttributeLen(t assert.TestingT, plan *Plan, address, path string, length int, msgAndArgs ...interface{}) bool {
+	actual, err := plan.AttributeE(address, path)
+	if err != nil {
+		return assert.Fail(t, err.Error(), msgAndArgs...)
+	}
+	value := reflect.ValueOf(actual)
+	if actual == nil || (value.Kind() != reflect.Slice && value.Kind() != reflect.Map) {
+		return assert.Fail(t, fmt.Sprintf("%s of %s is %#v, not a list or map", path, address, actual), msgAndArgs...)
+	}
+	if value.Len() == length {
+		return true
+	}
+	return assert.Fail(t, fmt.Sprintf("%s of %s should have %d elements, has %d", path, address, length, value.Len()), msgAndArgs...)
+}
+
+// AssertNoDestroys asserts the plan deletes nothing, replacements included,
+// and names every resource it would
+func AssertNoDestroys(t assert.TestingT, plan *Plan, msgAndArgs ...interface{}) bool {
+	destroyed := plan.Destroys()
+	if len(destroyed) == 0 {
+		return true
+	}
+	described := make([]string, 0, len(destroyed))
+	for _, address := range destroyed {
+		described = append(described, fmt.Sprintf("%s [%s]", address, strings.Join(plan.Actions(address), ", ")))
+	}
+	return assert.Fail(t, "Plan destroys resources:\n  "+strings.Join(described, "\n  "), msgAndArgs...)
+}
@@ -0,0 +1,130 @@
+package planassert
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const appAddress = "module.container_app.azurerm_container_app.this"
+
+// testPlan creates an app with one container and an identity only known
+// after apply, replaces a revision and deletes a registry
+const testPlan = `{
+  "format_version": "1.2",
+  "planned_values": {
+    "root_module": {
+      "child_modules": [{
+        "address": "module.container_app",
+        "resources": [{
+          "address": "module.container_app.azurerm_container_app.this",
+          "mode": "managed", "type": "azurerm_container_app", "name": "this",
+          "values": {
+            "name": "ca-plan",
+            "revision_mode": "Single",
+            "tags": {"Environment": "test"},
+            "template": [{"container": [{"cpu": 0.5, "memory": "1Gi"}], "max_replicas": 10}],
+            "identity": [{"type": "SystemAssigned"}]
+          }
+        }, {
+          "address": "module.container_app.terraform_data.revision",
+          "mode": "managed", "type": "terraform_data", "name": "revision",
+          "values": {}
+        }]
+      }]
+    }
+  },
+  "resource_changes": [{
+    "address": "module.container_app.azurerm_container_app.this",
+    "mode": "managed", "type": "azurerm_container_app", "name": "this",
+    "change": {"actions": ["create"], "before": null, "after": {},
+      "after_unknown": {"id": true, "identity": [{"principal_id": true}], "template": [{"container": [{}]}]}}
+  }, {
+    "address": "module.container_app.terraform_data.revision",
+    "mode": "managed", "type": "terraform_data", "name": "revision",
+    "change": {"actions": ["delete", "create"], "before": {}, "after": {}, "after_unknown": {}}
+  }, {
+    "address": "azurerm_container_registry.old",
+    "mode": "managed", "type": "azurerm_container_registry", "name": "old",
+    "change": {"actions": ["delete"], "before": {}, "after": null, "after_unknown": {}}
+  }]
+}`
+
+// recorder records assertion failures instead of failing the test
+type recorder struct {
+	failures []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAttribute(t *testing.T) {
+	t.Parallel()
+
+	plan := Parse(t, testPlan)
+	value, err := plan.AttributeE(appAddress, "template.0.container.0.cpu")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0.5, value)
+	}
+
+	_, err = plan.AttributeE(appAddress, "identity.0.principal_id")
+	assert.True(t, errors.Is(err, ErrUnknown), "principal_id is known after apply: %v", err)
+	_, err = plan.AttributeE(appAddress, "template.1.max_replicas")
+	assert.ErrorContains(t, err, "template has 1 elements, no index 1")
+	_, err = plan.AttributeE(appAddress, "template.max_replicas")
+	assert.ErrorContains(t, err, "not an index")
+	_, err = plan.AttributeE("azurerm_container_app.this", "name")
+	assert.ErrorContains(t, err, "plan has no azurerm_container_app.this", "addresses are full addresses")
+}
+
+func TestUnknownAt(t *testing.T) {
+	t.Parallel()
+
+	afterUnknown := map[string]interface{}{"id": true, "secret": []interface{}{true}, "ingress": []interface{}{map[string]interface{}{"fqdn": true}}}
+	assert.True(t, unknownAt(afterUnknown, "id"))
+	assert.True(t, unknownAt(afterUnknown, "secret.0.name"), "values in an unknown block are unknown")
+	assert.True(t, unknownAt(afterUnknown, "ingress.0.fqdn"))
+	assert.False(t, unknownAt(afterUnknown, "ingress.0.target_port"))
+	assert.False(t, unknownAt(nil, "name"))
+}
+
+func TestAssertions(t *testing.T) {
+	t.Parallel()
+
+	plan := Parse(t, testPlan)
+	passing := &recorder{}
+	assert.True(t, AssertResourcePlanned(passing, plan, appAddress))
+	assert.True(t, AssertResourceNotPlanned(passing, plan, "azurerm_container_registry.old"))
+	assert.True(t, AssertAttributeEquals(passing, plan, appAddress, "template.0.container.0.cpu", float32(0.5)))
+	assert.True(t, AssertAttributeEquals(passing, plan, appAddress, "template.0.max_replicas", 10), "ints compare with JSON numbers")
+	assert.True(t, AssertAttributeEquals(passing, plan, appAddress, "tags", map[string]string{"Environment": "test"}))
+	assert.True(t, AssertAttributeLen(passing, plan, appAddress, "template.0.container", 1))
+	assert.Empty(t, passing.failures)
+
+	failing := &recorder{}
+	assert.False(t, AssertResourcePlanned(failing, plan, "azurerm_container_registry.old"))
+	assert.False(t, AssertAttributeEquals(failing, plan, appAddress, "revision_mode", "Multiple"))
+	assert.False(t, AssertAttributeEquals(failing, plan, appAddress, "identity.0.principal_id", ""))
+	assert.False(t, AssertAttributeLen(failing, plan, appAddress, "name", 1))
+	assert.Len(t, failing.failures, 4)
+}
+
+func TestAssertNoDestroys(t *testing.T) {
+	t.Parallel()
+
+	plan := Parse(t, testPlan)
+	assert.Equal(t, []string{"azurerm_container_registry.old", "module.container_app.terraform_data.revision"}, plan.Destroys(),
+		"replacements count as destroys")
+
+	failing := &recorder{}
+	assert.False(t, AssertNoDestroys(failing, plan))
+	if assert.Len(t, failing.failures, 1) {
+		assert.Contains(t, failing.failures[0], "module.container_app.terraform_data.revision [delete, create]")
+	}
+
+	created := Parse(t, `{"format_version": "1.2", "resource_changes": [{"address": "a.b", "change": {"actions": ["create"]}}]}`)
+	assert.True(t, AssertNoDestroys(t, created))
+}
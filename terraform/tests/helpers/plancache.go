@@ -0,0 +1,363 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// planCacheReadyFile marks a cached init folder as complete and records the
+// initialized module folder inside it
+const planCacheReadyFile = "ready"
+
+// cacheablePlanFailure matches plan failures that depend only on the module
+// and its inputs (variable validations and preconditions), so they can be
+// cached like a successful plan
+var cacheablePlanFailure = regexp.MustCompile(`Invalid value for (input )?variable|Resource precondition failed`)
+
+var (
+	// planCacheLocks holds a mutex per cache key, so tests needing the same
+	// init or plan wait for the first one instead of repeating it
+	planCacheLocks sync.Map
+
+	terraformVersionOnce sync.Once
+	terraformVersion     string
+	terraformVersionErr  error
+)
+
+// cachedPlan is a plan stored in the cache: its JSON, or the error of a plan
+// that failed validation
+type cachedPlan struct {
+	Plan  string `json:"plan,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// PlanCacheDir returns the folder of the init and plan cache. When
+// TEST_PLAN_CACHE_DIR is set the cache lives there and is reused by later
+// runs on the same runner; otherwise it belongs to the current run
+func PlanCacheDir() string {
+	if dir := os.Getenv("TEST_PLAN_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "terratest-plan-cache", RunID())
+}
+
+// ModuleHashE hashes the terraform files (*.tf, *.tf.json and the lock file)
+// of the module in dir and of every local module it calls, recursively.
+// Copies of an unchanged module hash the same wherever they are
+func ModuleHashE(dir string) (string, error) {
+	moduleHash := sha256.New()
+	if err := hashModule(moduleHash, dir, map[string]bool{}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(moduleHash.Sum(nil)), nil
+}
+
+// hashModule writes the terraform files of the module in dir, then the
+// modules it calls, to moduleHash. seen holds the modules already written
+func hashModule(moduleHash hash.Hash, dir string, seen map[string]bool) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if seen[dir] {
+		return nil
+	}
+	seen[dir] = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	parser := hclparse.NewParser()
+	var sources []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json") || name == ".terraform.lock.hcl") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(moduleHash, "file %s %d\n", name, len(content))
+		moduleHash.Write(content)
+
+		if strings.HasSuffix(name, ".tf") {
+			fileSources, err := localModuleSourcesE(parser, filepath.Join(dir, name), content)
+			if err != nil {
+				return err
+			}
+			sources = append(sources, fileSources...)
+		}
+	}
+
+	for _, source := range sources {
+		fmt.Fprintf(moduleHash, "module %s\n", source)
+		if err := hashModule(moduleHash, filepath.Join(dir, source), seen); err != nil {
+			return fmt.Errorf("hashing module %s called from %s: %w", source, dir, err)
+		}
+	}
+	return nil
+}
+
+// localModuleSourcesE returns the local sources ("./..." or "../...") of the
+// module calls in a terraform file
+func localModuleSourcesE(parser *hclparse.Parser, file string, content []byte) ([]string, error) {
+	parsed, diags := parser.ParseHCL(content, file)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body, ok := parsed.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("%s is not native HCL syntax", file)
+	}
+
+	var sources []string
+	for _, block := range body.Blocks {
+		if block.Type != "module" || len(block.Labels) != 1 {
+			continue
+		}
+		source, exists := block.Body.Attributes["source"]
+		if !exists {
+			return nil, fmt.Errorf("module %s in %s has no source", block.Labels[0], file)
+		}
+		value, diags := source.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("source of module %s in %s is not a literal: %w", block.Labels[0], file, diags)
+		}
+		if local := value.AsString(); strings.HasPrefix(local, "./") || strings.HasPrefix(local, "../") {
+			sources = append(sources, local)
+		}
+	}
+	return sources, nil
+}
+
+// planCacheLock returns the mutex of a cache key
+func planCacheLock(key string) *sync.Mutex {
+	lock, _ := planCacheLocks.LoadOrStore(key, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// cacheKey hashes the JSON encoding of parts into a cache key
+func cacheKey(parts ...interface{}) (string, error) {
+	encoded, err := json.Marshal(parts)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// initCacheKeyE keys the init of the module in options.TerraformDir by its
+// place in the terraform root, its hash, extra init arguments passed through
+// TF_CLI_ARGS_init and the terraform version
+func initCacheKeyE(t *testing.T, options *terraform.Options) (string, error) {
+	relative, err := terraformRootRelE(options.TerraformDir)
+	if err != nil {
+		return "", err
+	}
+	moduleHash, err := ModuleHashE(options.TerraformDir)
+	if err != nil {
+		return "", err
+	}
+	terraformVersionOnce.Do(func() {
+		terraformVersion, terraformVersionErr = terraform.RunTerraformCommandAndGetStdoutE(t, quietOptions(options), "version")
+	})
+	if terraformVersionErr != nil {
+		return "", fmt.Errorf("reading terraform version: %w", terraformVersionErr)
+	}
+	return cacheKey("init", filepath.ToSlash(relative), moduleHash, options.EnvVars["TF_CLI_ARGS_init"], terraformVersion)
+}
+
+// cachedInitDirE returns the initialized copy of the module in
+// options.TerraformDir stored under key, initializing it on first use
+func cachedInitDirE(t *testing.T, options *terraform.Options, key string) (string, error) {
+	lock := planCacheLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	entry := filepath.Join(PlanCacheDir(), "init", key)
+	ready := filepath.Join(entry, planCacheReadyFile)
+	if moduleDir, err := os.ReadFile(ready); err == nil {
+		return string(moduleDir), nil
+	}
+
+	relative, err := terraformRootRelE(options.TerraformDir)
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.Abs("..")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(entry, 0o700); err != nil {
+		return "", err
+	}
+	tree, err := files.CopyTerraformFolderToDest(root, entry, "tree")
+	if err != nil {
+		return "", err
+	}
+
+	initOptions := *options
+	initOptions.TerraformDir = filepath.Join(tree, relative)
+	if _, err := terraform.InitE(t, &initOptions); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(ready, []byte(initOptions.TerraformDir), 0o600); err != nil {
+		return "", err
+	}
+	return initOptions.TerraformDir, nil
+}
+
+// CachedInitE points options at a fresh copy of its module that reuses an
+// init of the same module content from the plan cache (see PlanCacheDir)
+// instead of running terraform init again. The copy links the cached
+// .terraform folder, so it suits plans only; with a backend configured the
+// module is initialized normally
+func CachedInitE(t *testing.T, options *terraform.Options) error {
+	if options.BackendConfig != nil {
+		_, err := terraform.InitE(t, options)
+		return err
+	}
+
+	key, err := initCacheKeyE(t, options)
+	if err != nil {
+		return err
+	}
+	cachedDir, err := cachedInitDirE(t, options, key)
+	if err != nil {
+		return fmt.Errorf("initializing %s: %w", options.TerraformDir, err)
+	}
+
+	options.TerraformDir = CopyTerraformDirToTemp(t, options.TerraformDir)
+	if err := os.Symlink(filepath.Join(cachedDir, ".terraform"), filepath.Join(options.TerraformDir, ".terraform")); err != nil {
+		return err
+	}
+	lockFile := filepath.Join(options.TerraformDir, ".terraform.lock.hcl")
+	if _, err := os.Stat(lockFile); errors.Is(err, os.ErrNotExist) {
+		content, err := os.ReadFile(filepath.Join(cachedDir, ".terraform.lock.hcl"))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(lockFile, content, 0o600); err != nil {
+			return err
+		}
+	}
+	t.Logf("Reusing cached init of %s for %s", cachedDir, t.Name())
+	return nil
+}
+
+// planCacheKeyE keys a plan by the init of its module and everything else
+// that goes into it: variables, var files, environment and subscription
+func planCacheKeyE(t *testing.T, options *terraform.Options) (string, error) {
+	initKey, err := initCacheKeyE(t, options)
+	if err != nil {
+		return "", err
+	}
+	varFiles := map[string]string{}
+	for _, varFile := range options.VarFiles {
+		content, err := os.ReadFile(filepath.Join(options.TerraformDir, varFile))
+		if err != nil {
+			return "", err
+		}
+		varFiles[varFile] = string(content)
+	}
+	return cacheKey("plan", initKey, options.Vars, varFiles, options.EnvVars, os.Getenv("ARM_SUBSCRIPTION_ID"))
+}
+
+// CachedPlanE plans options and returns the plan JSON (`terraform show
+// -json`). A plan of the same module content with the same inputs is taken
+// from the plan cache (see PlanCacheDir), including plans that failed a
+// variable validation or precondition, which is what validation tests
+// expect; other failures are not cached. Uncached plans reuse a cached
+// init (see CachedInitE). Because a cached plan does not look at Azure
+// again, use it for plans that do not depend on deployed resources. Every
+// plan returned is checked with AssertNoPlanLeaks
+func CachedPlanE(t *testing.T, options *terraform.Options) (string, error) {
+	key, err := planCacheKeyE(t, options)
+	if err != nil {
+		return "", err
+	}
+	lock := planCacheLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	path := filepath.Join(PlanCacheDir(), "plan", key+".json")
+	if content, err := os.ReadFile(path); err == nil {
+		var cached cachedPlan
+		if err := json.Unmarshal(content, &cached); err == nil {
+			t.Logf("Reusing cached plan %s for %s", path, t.Name())
+			if cached.Error != "" {
+				return "", errors.New(cached.Error)
+			}
+			AssertNoPlanLeaks(t, cached.Plan)
+			return cached.Plan, nil
+		}
+	}
+
+	if err := CachedInitE(t, options); err != nil {
+		return "", err
+	}
+	if options.PlanFilePath == "" {
+		options.PlanFilePath = filepath.Join(options.TerraformDir, "cached.tfplan")
+	}
+
+	var cached cachedPlan
+	if _, err := terraform.PlanE(t, options); err != nil {
+		if !cacheablePlanFailure.MatchString(err.Error()) {
+			return "", err
+		}
+		cached.Error = err.Error()
+	} else if cached.Plan, err = terraform.ShowE(t, quietOptions(options)); err != nil {
+		return "", err
+	}
+
+	if err := writeCachedPlan(path, cached); err != nil {
+		t.Logf("Could not cache plan at %s: %v", path, err)
+	}
+	if cached.Error != "" {
+		return "", errors.New(cached.Error)
+	}
+	AssertNoPlanLeaks(t, cached.Plan)
+	return cached.Plan, nil
+}
+
+// writeCachedPlan stores a plan atomically, so concurrent runs sharing
+// TEST_PLAN_CACHE_DIR never read a partial file
+func writeCachedPlan(path string, cached cachedPlan) error {
+	content, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeModuleTree writes files, keyed by path relative to root, under root
+func writeModuleTree(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestModuleHash(t *testing.T) {
+	t.Parallel()
+
+	tree := map[string]string{
+		"fixtures/app/main.tf":    "module \"app\" {\n  source = \"../../modules/app\"\n}\n\nmodule \"remote\" {\n  source = \"Azure/naming/azurerm\"\n}\n",
+		"fixtures/app/README.md":  "Fixture",
+		"modules/app/main.tf":     "variable \"name\" {}\n",
+		"modules/app/versions.tf": "terraform {}\n",
+		"modules/other/main.tf":   "variable \"other\" {}\n",
+	}
+	hashOf := func(t *testing.T, changes map[string]string) string {
+		root := t.TempDir()
+		writeModuleTree(t, root, tree)
+		writeModuleTree(t, root, changes)
+		hash, err := ModuleHashE(filepath.Join(root, "fixtures", "app"))
+		if err != nil {
+			t.Fatalf("Hashing module: %v", err)
+		}
+		return hash
+	}
+
+	original := hashOf(t, nil)
+	assert.Equal(t, original, hashOf(t, nil), "copies of a module should hash the same")
+	assert.Equal(t, original, hashOf(t, map[string]string{"fixtures/app/README.md": "Changed"}), "non-terraform files should not count")
+	assert.Equal(t, original, hashOf(t, map[string]string{"modules/other/main.tf": "variable \"changed\" {}\n"}), "modules not called should not count")
+	assert.NotEqual(t, original, hashOf(t, map[string]string{"fixtures/app/main.tf": tree["fixtures/app/main.tf"] + "\n# changed\n"}))
+	assert.NotEqual(t, original, hashOf(t, map[string]string{"modules/app/main.tf": "variable \"renamed\" {}\n"}), "called modules should count")
+	assert.NotEqual(t, original, hashOf(t, map[string]string{"modules/app/outputs.tf": "output \"id\" {\n  value = 1\n}\n"}))
+}
+
+func TestModuleHashErrors(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeModuleTree(t, root, map[string]string{
+		"missing/main.tf": "module \"gone\" {\n  source = \"../gone\"\n}\n",
+		"dynamic/main.tf": "module \"app\" {\n  source = var.source\n}\n",
+	})
+
+	_, err := ModuleHashE(filepath.Join(root, "missing"))
+	assert.Error(t, err, "a missing local module should fail")
+	_, err = ModuleHashE(filepath.Join(root, "dynamic"))
+	assert.Error(t, err, "a non-literal source should fail")
+}
+
+func TestCacheKey(t *testing.T) {
+	t.Parallel()
+
+	key, err := cacheKey("plan", map[string]interface{}{"a": 1, "b": []string{"x"}})
+	assert.NoError(t, err)
+	same, err := cacheKey("plan", map[string]interface{}{"b": []string{"x"}, "a": 1})
+	assert.NoError(t, err)
+	other, err := cacheKey("plan", map[string]interface{}{"a": 2, "b": []string{"x"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, key, same, "variable order should not matter")
+	assert.NotEqual(t, key, other)
+}
+
+func TestCachedPlanRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "plan", "key.json")
+	assert.NoError(t, writeCachedPlan(path, cachedPlan{Error: "Error: Invalid value for variable"}))
+	content, err := os.ReadFile(path)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"error": "Error: Invalid value for variable"}`, string(content))
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if assert.NoError(t, err) {
+		assert.Len(t, entries, 1, "no temporary files should be left behind")
+	}
+	assert.True(t, cacheablePlanFailure.MatchString("Error: Invalid value for variable"))
+	assert.True(t, cacheablePlanFailure.MatchString("Error: Resource precondition failed"))
+	assert.False(t, cacheablePlanFailure.MatchString("Error: building AzureRM Client: obtain subscription"))
+}
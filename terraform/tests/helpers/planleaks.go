@@ -0,0 +1,234 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// minLeakLength is the shortest sensitive value looked for in plaintext.
+// Shorter values, such as a sensitive flag set to "true", would match
+// ordinary attribute values
+const minLeakLength = 8
+
+// PlanLeak is a secret that a plan shows in plaintext in a resource attribute
+// Terraform does not treat as sensitive, e.g. a password copied into a tag.
+// It never holds the secret itself, so it is safe to log
+type PlanLeak struct {
+	// Address is the address of the resource
+	Address string
+	// Attribute is the path of the attribute within the resource, e.g.
+	// tags["Owner"] or site_config[0].app_command_line
+	Attribute string
+	// Source is why the value is secret: the value of var.<name> for a
+	// sensitive root variable, or the secret rule it matches
+	Source string
+}
+
+func (l PlanLeak) String() string {
+	return fmt.Sprintf("%s %s holds %s in plaintext", l.Address, l.Attribute, l.Source)
+}
+
+// unmarkedSecretVariables are module variables that hold secrets but cannot
+// be marked sensitive because the module iterates over them with for_each
+// (see the secrets variables of the container-app and key-vault modules)
+var unmarkedSecretVariables = map[string]bool{
+	"secrets": true,
+}
+
+// sensitiveRootVariables adds to sensitive the root variables whose values
+// reach a sensitive variable of module, given the root variables each
+// variable of module is set from (rootOf). A module variable set from an
+// expression counts as set from every root variable the expression refers to
+func sensitiveRootVariables(module planModuleConfig, rootOf map[string][]string, sensitive map[string]bool) {
+	for name, variable := range module.Variables {
+		if variable.Sensitive || unmarkedSecretVariables[name] {
+			for _, root := range rootOf[name] {
+				sensitive[root] = true
+			}
+		}
+	}
+	for _, call := range module.ModuleCalls {
+		calledRootOf := map[string][]string{}
+		for argument, raw := range call.Expressions {
+			// Only expressions can refer to variables
+			var expression struct {
+				References []string `json:"references"`
+			}
+			if err := json.Unmarshal(raw, &expression); err != nil {
+				continue
+			}
+			for _, reference := range expression.References {
+				// var.settings.password is also listed as var.settings
+				if name := strings.TrimPrefix(reference, "var."); name != reference && !strings.Contains(name, ".") {
+					calledRootOf[argument] = append(calledRootOf[argument], rootOf[name]...)
+				}
+			}
+		}
+		sensitiveRootVariables(call.Module, calledRootOf, sensitive)
+	}
+}
+
+// stringLeaves calls visit for every string in value, a decoded JSON value,
+// with its attribute path below prefix and whether sensitivity (the matching
+// part of before_sensitive or after_sensitive) marks it sensitive. Paths
+// read as in Terraform: tags["Owner"], site_config[0].always_on
+func stringLeaves(prefix string, value, sensitivity interface{}, visit func(path, value string, sensitive bool)) {
+	if marked, ok := sensitivity.(bool); ok && marked {
+		stringLeaves(prefix, value, nil, func(path, value string, _ bool) { visit(path, value, true) })
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		visit(prefix, v, false)
+	case map[string]interface{}:
+		nested, _ := sensitivity.(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			stringLeaves(keyPath(prefix, key), v[key], nested[key], visit)
+		}
+	case []interface{}:
+		nested, _ := sensitivity.([]interface{})
+		for i, item := range v {
+			var itemSensitivity interface{}
+			if i < len(nested) {
+				itemSensitivity = nested[i]
+			}
+			stringLeaves(fmt.Sprintf("%s[%d]", prefix, i), item, itemSensitivity, visit)
+		}
+	}
+}
+
+// blockAttributePattern matches the attribute names of resources and blocks
+var blockAttributePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// keyPath appends key to prefix: resource attributes are bare, attributes of
+// blocks (which plan JSON lists, so they follow an index) are dotted, and map
+// keys are quoted
+func keyPath(prefix, key string) string {
+	switch {
+	case prefix == "":
+		return key
+	case strings.HasSuffix(prefix, "]") && !strings.HasSuffix(prefix, `"]`) && blockAttributePattern.MatchString(key):
+		return prefix + "." + key
+	}
+	return prefix + "[" + strconv.Quote(key) + "]"
+}
+
+// PlanLeaksE returns the secrets the plan (JSON from `terraform show -json`)
+// shows in plaintext in planned resource values: the values of sensitive
+// root variables, including root variables passed to a sensitive variable of
+// a module, and values matching the secret rules of
+// ScanForSecrets. Attributes Terraform marks sensitive are skipped, so this
+// finds modules that interpolate secrets into fields such as tags or names
+func PlanLeaksE(planJSON string) ([]PlanLeak, error) {
+	var plan struct {
+		Variables map[string]struct {
+			Value interface{} `json:"value"`
+		} `json:"variables"`
+		Configuration struct {
+			RootModule planModuleConfig `json:"root_module"`
+		} `json:"configuration"`
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Change  struct {
+				After          interface{} `json:"after"`
+				AfterSensitive interface{} `json:"after_sensitive"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal([]byte(planJSON), &plan); err != nil {
+		return nil, fmt.Errorf("decoding plan: %w", err)
+	}
+
+	rootOf := map[string][]string{}
+	for name := range plan.Configuration.RootModule.Variables {
+		rootOf[name] = []string{name}
+	}
+	sensitiveVariables := map[string]bool{}
+	sensitiveRootVariables(plan.Configuration.RootModule, rootOf, sensitiveVariables)
+
+	// Sensitive values, with the variable each came from, in variable order
+	type secret struct{ value, source string }
+	var names []string
+	for name := range sensitiveVariables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var secrets []secret
+	for _, name := range names {
+		stringLeaves("", plan.Variables[name].Value, nil, func(_, value string, _ bool) {
+			if len(value) >= minLeakLength {
+				secrets = append(secrets, secret{value: value, source: "var." + name})
+			}
+		})
+	}
+
+	var leaks []PlanLeak
+	for _, change := range plan.ResourceChanges {
+		stringLeaves("", change.Change.After, change.Change.AfterSensitive, func(path, value string, sensitive bool) {
+			if sensitive {
+				return
+			}
+			leak := PlanLeak{Address: change.Address, Attribute: path}
+			for _, secret := range secrets {
+				if strings.Contains(value, secret.value) {
+					leak.Source = "the value of " + secret.source
+					break
+				}
+			}
+			if leak.Source == "" {
+				if findings := ScanForSecrets([]byte(value)); len(findings) > 0 {
+					leak.Source = "a " + findings[0].Rule
+				}
+			}
+			if leak.Source != "" {
+				leaks = append(leaks, leak)
+			}
+		})
+	}
+	return leaks, nil
+}
+
+// AssertNoPlanLeaks fails the test for every secret the plan shows in
+// plaintext (see PlanLeaksE), naming the resource attribute but not the value
+func AssertNoPlanLeaks(t *testing.T, planJSON string) bool {
+	leaks, err := PlanLeaksE(planJSON)
+	if err != nil {
+		t.Errorf("Checking plan for leaked secrets: %v", err)
+		return false
+	}
+	for _, leak := range leaks {
+		t.Errorf("Plan leaks a secret: %s", leak)
+	}
+	return len(leaks) == 0
+}
+
+// InitAndPlanAndShow runs terraform init, plan and show like
+// terraform.InitAndPlanAndShow, then checks the plan with AssertNoPlanLeaks
+func InitAndPlanAndShow(t *testing.T, options *terraform.Options) string {
+	planJSON := terraform.InitAndPlanAndShow(t, options)
+	AssertNoPlanLeaks(t, planJSON)
+	return planJSON
+}
+
+// InitAndPlanAndShowWithStruct is InitAndPlanAndShow returning the parsed plan
+func InitAndPlanAndShowWithStruct(t *testing.T, options *terraform.Options) *terraform.PlanStruct {
+	planJSON := InitAndPlanAndShow(t, options)
+	plan, err := terraform.ParsePlanJSON(planJSON)
+	if err != nil {
+		t.Fatalf("Parsing plan: %v", err)
+	}
+	return plan
+}
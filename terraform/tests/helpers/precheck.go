@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+)
+
+// Failure categories of endpoint tests, recorded in the failures report
+const (
+	// FailureInfrastructureNotReady means the endpoint never resolved or
+	// accepted connections, so its behavior was not tested
+	FailureInfrastructureNotReady = "infrastructure not ready"
+	// FailureWrongBehavior means the endpoint was reachable and an
+	// assertion about what it returned failed
+	FailureWrongBehavior = "wrong behavior"
+)
+
+// Fresh Container App FQDNs usually resolve within a few minutes; the budget
+// is per stage, so DNS and TCP together wait up to twice this
+const (
+	precheckRetries  = 30
+	precheckInterval = 10 * time.Second
+	precheckTimeout  = 10 * time.Second
+)
+
+// EndpointPrecheck is what the precheck observed for an endpoint
+type EndpointPrecheck struct {
+	Endpoint  string   `json:"endpoint"`
+	Address   string   `json:"address"`
+	Addresses []string `json:"addresses,omitempty"`
+	// Stage is the check that failed: "dns" or "tcp". Empty when ready
+	Stage string `json:"stage,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// endpointFailure is an entry of the failures report
+type endpointFailure struct {
+	Category string `json:"category"`
+	Endpoint string `json:"endpoint"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// endpointAddress turns a URL, host or host:port into host:port, defaulting
+// to port 80 for http URLs and 443 otherwise
+func endpointAddress(endpoint string) string {
+	if parsed, err := url.Parse(endpoint); err == nil && parsed.Scheme == "http" && parsed.Port() == "" {
+		return net.JoinHostPort(parsed.Hostname(), "80")
+	}
+	return tlsAddress(endpoint)
+}
+
+// CheckEndpointReadyE waits, with bounded retries per stage, until the host
+// of endpoint resolves and its port accepts TCP connections. The returned
+// precheck says which stage failed, if any
+func CheckEndpointReadyE(t *testing.T, endpoint string, maxRetries int, interval time.Duration) (*EndpointPrecheck, error) {
+	address := endpointAddress(endpoint)
+	host, _, _ := net.SplitHostPort(address)
+	precheck := &EndpointPrecheck{Endpoint: endpoint, Address: address}
+
+	_, err := retry.DoWithRetryE(t, fmt.Sprintf("resolving %s", host), maxRetries, interval, func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), precheckTimeout)
+		defer cancel()
+		addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return "", err
+		}
+		precheck.Addresses = addresses
+		return "", nil
+	})
+	if err != nil {
+		precheck.Stage, precheck.Error = "dns", err.Error()
+		return precheck, fmt.Errorf("%s does not resolve: %w", host, err)
+	}
+
+	_, err = retry.DoWithRetryE(t, fmt.Sprintf("connecting to %s", address), maxRetries, interval, func() (string, error) {
+		conn, err := net.DialTimeout("tcp", address, precheckTimeout)
+		if err != nil {
+			return "", err
+		}
+		return "", conn.Close()
+	})
+	if err != nil {
+		precheck.Stage, precheck.Error = "tcp", err.Error()
+		return precheck, fmt.Errorf("%s does not accept connections: %w", address, err)
+	}
+	return precheck, nil
+}
+
+// RequireEndpointReady runs before behavioral assertions on a freshly
+// deployed endpoint. It waits for DNS propagation and TCP reachability and
+// stops the test as "infrastructure not ready" if either never happens, so a
+// slow FQDN is not reported as a broken module. Once the endpoint is ready,
+// any later failure of the test is recorded as "wrong behavior". Both
+// categories go to the failures report
+func RequireEndpointReady(t *testing.T, endpoint string) *EndpointPrecheck {
+	precheck, err := CheckEndpointReadyE(t, endpoint, precheckRetries, precheckInterval)
+	if err != nil {
+		recordEndpointFailure(t, FailureInfrastructureNotReady, endpoint, err.Error())
+		t.Fatalf("[%s] %v", FailureInfrastructureNotReady, err)
+	}
+
+	t.Cleanup(func() {
+		if t.Failed() {
+			recordEndpointFailure(t, FailureWrongBehavior, endpoint, "")
+		}
+	})
+	return precheck
+}
+
+// recordEndpointFailure records the category of a failed endpoint test
+func recordEndpointFailure(t *testing.T, category, endpoint, detail string) {
+	failure := endpointFailure{Category: category, Endpoint: endpoint, Detail: detail}
+	if err := RecordReportE("failures", t.Name(), failure); err != nil {
+		t.Logf("Recording %s failure of %s: %v", category, endpoint, err)
+	}
+}
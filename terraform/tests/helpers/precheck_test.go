@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointAddress(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "ca.azurecontainerapps.io:443", endpointAddress("https://ca.azurecontainerapps.io/health"))
+	assert.Equal(t, "ca.azurecontainerapps.io:80", endpointAddress("http://ca.azurecontainerapps.io/"))
+	assert.Equal(t, "localhost:8080", endpointAddress("http://localhost:8080"))
+	assert.Equal(t, "example.azurecr.io:443", endpointAddress("example.azurecr.io"))
+}
+
+func TestCheckEndpointReady(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	precheck, err := CheckEndpointReadyE(t, server.URL, 1, time.Millisecond)
+	assert.NoError(t, err)
+	assert.Empty(t, precheck.Stage)
+	assert.Contains(t, precheck.Addresses, "127.0.0.1")
+
+	// A port that was just released refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closedAddress := listener.Addr().String()
+	listener.Close()
+
+	precheck, err = CheckEndpointReadyE(t, "http://"+closedAddress, 2, time.Millisecond)
+	assert.Error(t, err)
+	assert.Equal(t, "tcp", precheck.Stage, "Refused connections fail the TCP stage")
+
+	precheck, err = CheckEndpointReadyE(t, "https://precheck.invalid", 1, time.Millisecond)
+	assert.Error(t, err)
+	assert.Equal(t, "dns", precheck.Stage, "Unresolvable hosts fail the DNS stage")
+}
@@ -0,0 +1,208 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyVaultAPIVersion is the data-plane API version ReadSecretE calls
+const keyVaultAPIVersion = "7.4"
+
+// ProbeCall is one call a Probe made
+type ProbeCall struct {
+	Start   time.Time     `json:"start"`
+	Latency time.Duration `json:"latency"`
+	// Phase is the phase the probe was in when the call started
+	Phase string `json:"phase,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Failed reports whether the call returned an error
+func (c ProbeCall) Failed() bool {
+	return c.Error != ""
+}
+
+// Probe calls a function over and over in the background, recording how
+// long each call took and whether it failed, while the test changes what
+// the function depends on
+type Probe struct {
+	mu    sync.Mutex
+	calls []ProbeCall
+	phase string
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// StartProbe calls call every interval, starting at once, until Stop. A
+// call that takes longer than interval delays the next one rather than
+// overlapping it
+func StartProbe(interval time.Duration, phase string, call func() error) *Probe {
+	p := &Probe{phase: phase, stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.mu.Lock()
+			record := ProbeCall{Start: time.Now(), Phase: p.phase}
+			p.mu.Unlock()
+			err := call()
+			record.Latency = time.Since(record.Start)
+			if err != nil {
+				record.Error = err.Error()
+			}
+			p.mu.Lock()
+			p.calls = append(p.calls, record)
+			p.mu.Unlock()
+
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return p
+}
+
+// SetPhase labels the calls started from now on with phase
+func (p *Probe) SetPhase(phase string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.phase = phase
+}
+
+// Stop waits for the call in flight and returns every call made, in order
+func (p *Probe) Stop() []ProbeCall {
+	close(p.stop)
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ProbeCall(nil), p.calls...)
+}
+
+// ErrorWindow is a run of consecutive failed probe calls
+type ErrorWindow struct {
+	// Start is when the first failed call started and End when the next
+	// successful one did, or when the last failed call ended if none
+	// succeeded after it
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Failures int       `json:"failures"`
+	// Phases are the phases the failed calls started in, in order
+	Phases    []string `json:"phases"`
+	LastError string   `json:"last_error"`
+	// Recovered is false when the probe never succeeded again
+	Recovered bool `json:"recovered"`
+}
+
+// Duration is how long the window lasted
+func (w ErrorWindow) Duration() time.Duration {
+	return w.End.Sub(w.Start)
+}
+
+func (w ErrorWindow) String() string {
+	state := "recovered"
+	if !w.Recovered {
+		state = "never recovered"
+	}
+	return fmt.Sprintf("%d failures over %s during %s, %s (last: %s)",
+		w.Failures, w.Duration().Round(time.Second), strings.Join(w.Phases, ", "), state, w.LastError)
+}
+
+// ErrorWindows returns the runs of failed calls in calls, in order
+func ErrorWindows(calls []ProbeCall) []ErrorWindow {
+	var windows []ErrorWindow
+	var open *ErrorWindow
+	for _, call := range calls {
+		if !call.Failed() {
+			if open != nil {
+				open.End = call.Start
+				open.Recovered = true
+				windows = append(windows, *open)
+				open = nil
+			}
+			continue
+		}
+		if open == nil {
+			open = &ErrorWindow{Start: call.Start}
+		}
+		open.Failures++
+		open.End = call.Start.Add(call.Latency)
+		open.LastError = call.Error
+		if len(open.Phases) == 0 || open.Phases[len(open.Phases)-1] != call.Phase {
+			open.Phases = append(open.Phases, call.Phase)
+		}
+	}
+	if open != nil {
+		windows = append(windows, *open)
+	}
+	return windows
+}
+
+// LatencyPercentile returns the latency under which percentile (0 to 100)
+// of the successful calls in calls finished, 0 if none succeeded
+func LatencyPercentile(calls []ProbeCall, percentile float64) time.Duration {
+	var latencies []time.Duration
+	for _, call := range calls {
+		if !call.Failed() {
+			latencies = append(latencies, call.Latency)
+		}
+	}
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	index := int(float64(len(latencies))*percentile/100+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(latencies) {
+		index = len(latencies) - 1
+	}
+	return latencies[index]
+}
+
+// ReadSecretE reads the current version of secret name from the vault at
+// vaultURI on the data plane with a bearer token for Cloud.KeyVaultResource.
+// The value is discarded; a refused read returns the status and Key Vault's
+// error code, e.g. ForbiddenByFirewall
+func ReadSecretE(client *http.Client, vaultURI, name, token string) error {
+	url := fmt.Sprintf("%s/secrets/%s?api-version=%s", strings.TrimRight(vaultURI, "/"), name, keyVaultAPIVersion)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusOK {
+		_, err := io.Copy(io.Discard, response.Body)
+		return err
+	}
+
+	var failure struct {
+		Error struct {
+			Code       string `json:"code"`
+			InnerError struct {
+				Code string `json:"code"`
+			} `json:"innererror"`
+		} `json:"error"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+	_ = json.Unmarshal(body, &failure)
+	code := failure.Error.Code
+	if inner := failure.Error.InnerError.Code; inner != "" {
+		code = inner
+	}
+	return fmt.Errorf("reading secret %s: %d %s", name, response.StatusCode, code)
+}
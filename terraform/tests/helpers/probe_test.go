@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorWindows(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	calls := []ProbeCall{
+		{Start: at(0), Latency: time.Second, Phase: "allow"},
+		{Start: at(2), Latency: time.Second, Phase: "allow", Error: "reading secret probe: 403 ForbiddenByFirewall"},
+		{Start: at(4), Latency: time.Second, Phase: "deny", Error: "reading secret probe: 403 ForbiddenByFirewall"},
+		{Start: at(6), Latency: time.Second, Phase: "deny"},
+		{Start: at(8), Latency: time.Second, Phase: "deny"},
+		{Start: at(10), Latency: 3 * time.Second, Phase: "allow", Error: "timeout"},
+	}
+	windows := ErrorWindows(calls)
+	if assert.Len(t, windows, 2) {
+		assert.Equal(t, ErrorWindow{Start: at(2), End: at(6), Failures: 2, Phases: []string{"allow", "deny"},
+			LastError: "reading secret probe: 403 ForbiddenByFirewall", Recovered: true}, windows[0])
+		assert.Equal(t, 4*time.Second, windows[0].Duration(), "a window lasts until the next successful call")
+		assert.False(t, windows[1].Recovered, "failing to the end is a lockout")
+		assert.Equal(t, 3*time.Second, windows[1].Duration())
+		assert.Equal(t, "1 failures over 3s during allow, never recovered (last: timeout)", windows[1].String())
+	}
+	assert.Empty(t, ErrorWindows(calls[3:5]))
+}
+
+func TestLatencyPercentile(t *testing.T) {
+	t.Parallel()
+
+	var calls []ProbeCall
+	for i := 10; i >= 1; i-- {
+		calls = append(calls, ProbeCall{Latency: time.Duration(i) * 100 * time.Millisecond})
+	}
+	calls = append(calls, ProbeCall{Latency: time.Minute, Error: "timeout"})
+	assert.Equal(t, 500*time.Millisecond, LatencyPercentile(calls, 50))
+	assert.Equal(t, time.Second, LatencyPercentile(calls, 95), "failed calls are left out")
+	assert.Equal(t, 100*time.Millisecond, LatencyPercentile(calls, 0))
+	assert.Zero(t, LatencyPercentile(calls[10:], 95))
+}
+
+func TestProbe(t *testing.T) {
+	t.Parallel()
+
+	var count int32
+	probe := StartProbe(time.Millisecond, "allow", func() error {
+		if atomic.AddInt32(&count, 1)%2 == 0 {
+			return errors.New("refused")
+		}
+		return nil
+	})
+	time.Sleep(20 * time.Millisecond)
+	probe.SetPhase("deny")
+	time.Sleep(20 * time.Millisecond)
+	calls := probe.Stop()
+
+	if assert.GreaterOrEqual(t, len(calls), 4) {
+		assert.Equal(t, "allow", calls[0].Phase)
+		assert.Equal(t, "deny", calls[len(calls)-1].Phase)
+		assert.False(t, calls[0].Failed())
+		assert.Equal(t, "refused", calls[1].Error)
+	}
+	assert.Equal(t, int(atomic.LoadInt32(&count)), len(calls), "every call is recorded")
+}
+
+func TestReadSecretE(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, keyVaultAPIVersion, r.URL.Query().Get("api-version"))
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, `{"error":{"code":"Unauthorized"}}`, http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/secrets/probe":
+			w.Write([]byte(`{"value":"s3cret"}`))
+		default:
+			http.Error(w, `{"error":{"code":"Forbidden","innererror":{"code":"ForbiddenByFirewall"}}}`, http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	assert.NoError(t, ReadSecretE(server.Client(), server.URL+"/", "probe", "token"))
+	err := ReadSecretE(server.Client(), server.URL, "other", "token")
+	if assert.Error(t, err) {
+		assert.Equal(t, "reading secret other: 403 ForbiddenByFirewall", err.Error(), "the firewall's reason should be in the error")
+		assert.NotContains(t, err.Error(), "s3cret")
+	}
+	assert.ErrorContains(t, ReadSecretE(server.Client(), server.URL, "probe", "expired"), "401 Unauthorized")
+}
@@ -0,0 +1,275 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// providerWindowsFile holds the version window allowed for each provider the
+// modules require, by source address
+const providerWindowsFile = "testdata/provider-windows.json"
+
+// defaultRegistryHost is the host of provider sources written without one
+const defaultRegistryHost = "registry.terraform.io/"
+
+// ProviderRequirement is an entry of a required_providers block
+type ProviderRequirement struct {
+	// File and Line locate the entry
+	File string `json:"file"`
+	Line int    `json:"line"`
+	// Name is the local name, e.g. azurerm
+	Name string `json:"name"`
+	// Source is the source address without the default registry host, e.g.
+	// hashicorp/azurerm
+	Source string `json:"source"`
+	// Version is the version constraint, empty when there is none
+	Version string `json:"version,omitempty"`
+}
+
+func (r ProviderRequirement) String() string {
+	return fmt.Sprintf("%s:%d %s (%s %q)", r.File, r.Line, r.Name, r.Source, r.Version)
+}
+
+// ProviderWindow is the range of versions modules may allow for a provider
+type ProviderWindow struct {
+	// Min is the oldest version a module may allow
+	Min string `json:"min"`
+	// Max is the newest release the modules are verified against. A module
+	// must allow it, and no release of a later major version
+	Max string `json:"max"`
+}
+
+// ReadProviderWindowsE reads the allowed window of each provider source
+func ReadProviderWindowsE() (map[string]ProviderWindow, error) {
+	content, err := os.ReadFile(providerWindowsFile)
+	if err != nil {
+		return nil, err
+	}
+	var windows map[string]ProviderWindow
+	if err := json.Unmarshal(content, &windows); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", providerWindowsFile, err)
+	}
+	for source, window := range windows {
+		oldest, err := parseConstraintVersion(window.Min)
+		if err != nil {
+			return nil, fmt.Errorf("%s: min of %s: %w", providerWindowsFile, source, err)
+		}
+		newest, err := parseConstraintVersion(window.Max)
+		if err != nil {
+			return nil, fmt.Errorf("%s: max of %s: %w", providerWindowsFile, source, err)
+		}
+		if newest.Less(oldest) {
+			return nil, fmt.Errorf("%s: max %s of %s is older than its min %s", providerWindowsFile, window.Max, source, window.Min)
+		}
+	}
+	return windows, nil
+}
+
+// FindProviderRequirementsE returns the required_providers entries of the
+// terraform files in dir, by file and line. The legacy form, a bare version
+// constraint, gets the hashicorp namespace terraform gives it
+func FindProviderRequirementsE(dir string) ([]ProviderRequirement, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	parser := hclparse.NewParser()
+	var requirements []ProviderRequirement
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil, fmt.Errorf("%s is not native HCL syntax", file)
+		}
+		for _, block := range body.Blocks {
+			if block.Type != "terraform" {
+				continue
+			}
+			for _, nested := range block.Body.Blocks {
+				if nested.Type != "required_providers" {
+					continue
+				}
+				for name, attribute := range nested.Body.Attributes {
+					requirement := ProviderRequirement{File: file, Line: attribute.SrcRange.Start.Line, Name: name, Source: "hashicorp/" + name}
+					if err := readProviderRequirement(attribute, &requirement); err != nil {
+						return nil, fmt.Errorf("%s: %w", requirement, err)
+					}
+					requirements = append(requirements, requirement)
+				}
+			}
+		}
+	}
+	sort.Slice(requirements, func(i, j int) bool {
+		if requirements[i].File != requirements[j].File {
+			return requirements[i].File < requirements[j].File
+		}
+		return requirements[i].Line < requirements[j].Line
+	})
+	return requirements, nil
+}
+
+// readProviderRequirement fills in the source and version of requirement
+// from its required_providers attribute
+func readProviderRequirement(attribute *hclsyntax.Attribute, requirement *ProviderRequirement) error {
+	value, diags := attribute.Expr.Value(nil)
+	if diags.HasErrors() {
+		return fmt.Errorf("not a literal: %w", diags)
+	}
+	if value.Type().Equals(cty.String) {
+		requirement.Version = value.AsString()
+		return nil
+	}
+	if !value.Type().IsObjectType() {
+		return fmt.Errorf("neither an object nor a version constraint")
+	}
+	for name, target := range map[string]*string{"source": &requirement.Source, "version": &requirement.Version} {
+		if !value.Type().HasAttribute(name) {
+			continue
+		}
+		attributeValue := value.GetAttr(name)
+		if !attributeValue.Type().Equals(cty.String) || attributeValue.IsNull() {
+			return fmt.Errorf("%s is not a string", name)
+		}
+		*target = attributeValue.AsString()
+	}
+	requirement.Source = strings.TrimPrefix(strings.ToLower(requirement.Source), defaultRegistryHost)
+	return nil
+}
+
+// versionBound is one end of the range a version constraint allows
+type versionBound struct {
+	version   Semver
+	inclusive bool
+}
+
+// versionRange is what a version constraint allows: the versions between
+// lower and upper, either of which may be open, but those excluded
+type versionRange struct {
+	lower, upper *versionBound
+	excluded     []Semver
+}
+
+// parseConstraintVersion parses the version of a constraint, where the minor
+// and patch numbers may be left out, as in "~> 4.0"
+func parseConstraintVersion(version string) (Semver, error) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	for strings.Count(version, ".") < 2 {
+		version += ".0"
+	}
+	return ParseSemver(version)
+}
+
+// parseVersionConstraintE parses a terraform version constraint, such as
+// "~> 4.0" or ">= 4.10, < 5.0", into the range it allows. Pre-release
+// versions are refused
+func parseVersionConstraintE(constraint string) (versionRange, error) {
+	var allowed versionRange
+	if strings.TrimSpace(constraint) == "" {
+		return allowed, fmt.Errorf("no version constraint")
+	}
+	for _, clause := range strings.Split(constraint, ",") {
+		clause = strings.TrimSpace(clause)
+		operator := strings.TrimRight(clause, "0123456789.v ")
+		version, err := parseConstraintVersion(strings.TrimPrefix(clause, operator))
+		if err != nil {
+			return allowed, fmt.Errorf("clause %q: %w", clause, err)
+		}
+		switch strings.TrimSpace(operator) {
+		case "", "=":
+			allowed.raiseLower(versionBound{version, true})
+			allowed.lowerUpper(versionBound{version, true})
+		case "!=":
+			allowed.excluded = append(allowed.excluded, version)
+		case ">":
+			allowed.raiseLower(versionBound{version, false})
+		case ">=":
+			allowed.raiseLower(versionBound{version, true})
+		case "<":
+			allowed.lowerUpper(versionBound{version, false})
+		case "<=":
+			allowed.lowerUpper(versionBound{version, true})
+		case "~>":
+			// Only the rightmost number written may grow
+			next := Semver{version[0] + 1, 0, 0}
+			if segments := strings.Count(strings.TrimPrefix(clause, operator), "."); segments == 2 {
+				next = Semver{version[0], version[1] + 1, 0}
+			}
+			allowed.raiseLower(versionBound{version, true})
+			allowed.lowerUpper(versionBound{next, false})
+		default:
+			return allowed, fmt.Errorf("clause %q has an unknown operator", clause)
+		}
+	}
+	return allowed, nil
+}
+
+// raiseLower narrows the range to versions above bound
+func (r *versionRange) raiseLower(bound versionBound) {
+	if r.lower == nil || r.lower.version.Less(bound.version) || (r.lower.version == bound.version && !bound.inclusive) {
+		r.lower = &bound
+	}
+}
+
+// lowerUpper narrows the range to versions below bound
+func (r *versionRange) lowerUpper(bound versionBound) {
+	if r.upper == nil || bound.version.Less(r.upper.version) || (r.upper.version == bound.version && !bound.inclusive) {
+		r.upper = &bound
+	}
+}
+
+// allows reports whether version is in the range
+func (r versionRange) allows(version Semver) bool {
+	if r.lower != nil && (version.Less(r.lower.version) || (version == r.lower.version && !r.lower.inclusive)) {
+		return false
+	}
+	if r.upper != nil && (r.upper.version.Less(version) || (version == r.upper.version && !r.upper.inclusive)) {
+		return false
+	}
+	for _, excluded := range r.excluded {
+		if version == excluded {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckConstraint returns why constraint does not fall within the window, or
+// nil. The constraint must allow Max, allow nothing older than Min, and
+// allow nothing of a major version after Max's, which could break the
+// modules on a fresh init
+func (w ProviderWindow) CheckConstraint(constraint string) error {
+	allowed, err := parseVersionConstraintE(constraint)
+	if err != nil {
+		return err
+	}
+	oldest, err := parseConstraintVersion(w.Min)
+	if err != nil {
+		return err
+	}
+	newest, err := parseConstraintVersion(w.Max)
+	if err != nil {
+		return err
+	}
+
+	nextMajor := Semver{newest[0] + 1, 0, 0}
+	switch {
+	case allowed.lower == nil || allowed.lower.version.Less(oldest):
+		return fmt.Errorf("%q allows versions older than %s", constraint, w.Min)
+	case allowed.upper == nil || nextMajor.Less(allowed.upper.version) || (allowed.upper.version == nextMajor && allowed.upper.inclusive):
+		return fmt.Errorf("%q allows major version %d or later, past %s", constraint, nextMajor[0], w.Max)
+	case !allowed.allows(newest):
+		return fmt.Errorf("%q does not allow %s, the newest verified release", constraint, w.Max)
+	}
+	return nil
+}
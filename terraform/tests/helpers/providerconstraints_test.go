@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindProviderRequirements(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	versions := `terraform {
+  required_version = ">= 1.5.0"
+
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "~> 4.0"
+    }
+    azapi = {
+      source = "registry.terraform.io/Azure/azapi"
+    }
+    random = "~> 3.6"
+  }
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "versions.tf"), []byte(versions), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte("terraform {\n  required_providers {\n    time = {\n      source = local.source\n    }\n  }\n}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := FindProviderRequirementsE(dir)
+	assert.ErrorContains(t, err, "not a literal")
+
+	if err := os.Remove(filepath.Join(dir, "main.tf")); err != nil {
+		t.Fatal(err)
+	}
+	requirements, err := FindProviderRequirementsE(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	file := filepath.Join(dir, "versions.tf")
+	assert.Equal(t, []ProviderRequirement{
+		{File: file, Line: 5, Name: "azurerm", Source: "hashicorp/azurerm", Version: "~> 4.0"},
+		{File: file, Line: 9, Name: "azapi", Source: "azure/azapi"},
+		{File: file, Line: 12, Name: "random", Source: "hashicorp/random", Version: "~> 3.6"},
+	}, requirements)
+}
+
+func TestProviderWindowCheckConstraint(t *testing.T) {
+	t.Parallel()
+
+	window := ProviderWindow{Min: "4.0.0", Max: "4.60.0"}
+	for _, constraint := range []string{"~> 4.0", ">= 4.10, < 5.0", "~> 4.60.0", "= 4.60.0", ">= 4.0.0, <= 5.0.0, != 5.0.0, < 5.0.0", ">= 4.1, < 4.61, != 4.59.0"} {
+		assert.NoError(t, window.CheckConstraint(constraint), constraint)
+	}
+
+	for constraint, expected := range map[string]string{
+		"":                 "no version constraint",
+		"~> 3.100":         "allows versions older than 4.0.0",
+		">= 3.0, < 5.0":    "allows versions older than 4.0.0",
+		"< 5.0":            "allows versions older than 4.0.0",
+		">= 4.0":           "allows major version 5 or later",
+		">= 4.0, <= 5.0.0": "allows major version 5 or later",
+		"~> 4.59.0":        "does not allow 4.60.0",
+		">= 4.0, != 4.60":  "allows major version 5 or later",
+		"~> 4.0, != 4.60":  "does not allow 4.60.0",
+		"^4.0":             "unknown operator",
+		"~> 5.0.0-beta1":   "unknown operator",
+	} {
+		assert.ErrorContains(t, window.CheckConstraint(constraint), expected, constraint)
+	}
+}
+
+func TestVersionRangeAllows(t *testing.T) {
+	t.Parallel()
+
+	allowed, err := parseVersionConstraintE("~> 4.10.1, > 4.10.2")
+	if !assert.NoError(t, err) {
+		return
+	}
+	for version, expected := range map[Semver]bool{
+		{4, 10, 1}: false,
+		{4, 10, 2}: false,
+		{4, 10, 3}: true,
+		{4, 10, 9}: true,
+		{4, 11, 0}: false,
+	} {
+		assert.Equal(t, expected, allowed.allows(version), version.String())
+	}
+}
@@ -0,0 +1,206 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// providerUpgradeInputDir holds the plan inputs of each module for the
+// provider upgrade dry run, one JSON var file per module
+const providerUpgradeInputDir = "testdata/provider-upgrade"
+
+// providerOverrideFile is written next to every module that requires
+// azurerm. Terraform merges *_override.tf files over the module's own
+// configuration, so the candidate version replaces its constraint
+const providerOverrideFile = "azurerm_upgrade_override.tf"
+
+const providerOverrideConfig = `terraform {
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = "%s"
+    }
+  }
+}
+`
+
+// requiresAzurerm matches a required_providers entry for hashicorp/azurerm
+var requiresAzurerm = regexp.MustCompile(`source\s*=\s*"hashicorp/azurerm"`)
+
+// ProviderUpgradeResult compares the plan of a module under the azurerm
+// version its constraints select with the plan under a candidate version
+type ProviderUpgradeResult struct {
+	Module           string `json:"module"`
+	CandidateVersion string `json:"candidate_version"`
+	// BaselineError and CandidateError are the init or plan failures
+	BaselineError  string `json:"baseline_error,omitempty"`
+	CandidateError string `json:"candidate_error,omitempty"`
+	// Differences lists resources and attributes planned differently
+	Differences []string `json:"differences,omitempty"`
+}
+
+// Breaks reports whether the candidate fails a plan that works today
+func (r *ProviderUpgradeResult) Breaks() bool {
+	return r.BaselineError == "" && r.CandidateError != ""
+}
+
+// overrideProviderVersionE pins azurerm to version in every module under
+// root that requires it
+func overrideProviderVersionE(root, version string) error {
+	overridden := map[string]bool{}
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && entry.Name() == ".terraform" {
+			return filepath.SkipDir
+		}
+		dir := filepath.Dir(path)
+		if entry.IsDir() || filepath.Ext(path) != ".tf" || overridden[dir] {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !requiresAzurerm.Match(content) {
+			return nil
+		}
+		overridden[dir] = true
+		return os.WriteFile(filepath.Join(dir, providerOverrideFile), []byte(fmt.Sprintf(providerOverrideConfig, version)), 0o600)
+	})
+}
+
+// planModuleWithProviderE plans a copy of the module with its dry run inputs,
+// overriding the azurerm version when version is not empty
+func planModuleWithProviderE(t *testing.T, module, version string) (*terraform.PlanStruct, error) {
+	inputs, err := filepath.Abs(filepath.Join(providerUpgradeInputDir, module+".tfvars.json"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(inputs); err != nil {
+		return nil, fmt.Errorf("no dry run inputs for %s: %w", module, err)
+	}
+
+	moduleDir := CopyModuleToTemp(t, module)
+	if version != "" {
+		// Local modules the module calls live next to it
+		if err := overrideProviderVersionE(filepath.Dir(moduleDir), version); err != nil {
+			return nil, fmt.Errorf("overriding azurerm version: %w", err)
+		}
+	}
+
+	options := DefaultTerraformOptions(t, moduleDir, nil)
+	options.VarFiles = []string{inputs}
+	options.PlanFilePath = filepath.Join(moduleDir, "upgrade.tfplan")
+	// The copied lock file pins the current release
+	options.Upgrade = version != ""
+
+	if _, err := terraform.InitE(t, options); err != nil {
+		return nil, err
+	}
+	if _, err := terraform.PlanE(t, options); err != nil {
+		return nil, err
+	}
+	planJSON, err := terraform.ShowE(t, quietOptions(options))
+	if err != nil {
+		return nil, err
+	}
+	AssertNoPlanLeaks(t, planJSON)
+	return terraform.ParsePlanJSON(planJSON)
+}
+
+// PlanWithProviderVersionE plans the module with its dry run inputs after
+// `terraform init -upgrade` with azurerm pinned to version
+func PlanWithProviderVersionE(t *testing.T, module, version string) (*terraform.PlanStruct, error) {
+	return planModuleWithProviderE(t, module, version)
+}
+
+// PlanDifferences lists what candidate plans differently from baseline:
+// resources only one of them plans and attributes with different known
+// values, as "<address>: ..." sorted by address
+func PlanDifferences(baseline, candidate *terraform.PlanStruct) []string {
+	addresses := map[string]bool{}
+	for address := range baseline.ResourcePlannedValuesMap {
+		addresses[address] = true
+	}
+	for address := range candidate.ResourcePlannedValuesMap {
+		addresses[address] = true
+	}
+
+	var differences []string
+	for address := range addresses {
+		before, inBaseline := baseline.ResourcePlannedValuesMap[address]
+		after, inCandidate := candidate.ResourcePlannedValuesMap[address]
+		switch {
+		case !inCandidate:
+			differences = append(differences, fmt.Sprintf("%s: no longer planned", address))
+		case !inBaseline:
+			differences = append(differences, fmt.Sprintf("%s: newly planned", address))
+		default:
+			attributes := map[string]bool{}
+			for name := range before.AttributeValues {
+				attributes[name] = true
+			}
+			for name := range after.AttributeValues {
+				attributes[name] = true
+			}
+			for name := range attributes {
+				beforeValue, afterValue := before.AttributeValues[name], after.AttributeValues[name]
+				if !reflect.DeepEqual(beforeValue, afterValue) {
+					differences = append(differences, fmt.Sprintf("%s: %s %s -> %s", address, name, planValueString(beforeValue), planValueString(afterValue)))
+				}
+			}
+		}
+	}
+	sort.Strings(differences)
+	return differences
+}
+
+// planValueString renders a planned attribute value as compact JSON
+func planValueString(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// firstLines keeps the first lines of a terraform error for the report
+func firstLines(message string, count int) string {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	if len(lines) > count {
+		lines = append(lines[:count], "...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DryRunProviderUpgrade plans the module under the azurerm version its
+// constraints select and under candidateVersion (a version constraint such
+// as "4.30.0" or "5.0.0-beta1") and returns the errors and plan differences
+func DryRunProviderUpgrade(t *testing.T, module, candidateVersion string) *ProviderUpgradeResult {
+	result := &ProviderUpgradeResult{Module: module, CandidateVersion: candidateVersion}
+
+	baseline, err := planModuleWithProviderE(t, module, "")
+	if err != nil {
+		result.BaselineError = firstLines(err.Error(), 20)
+	}
+	candidate, err := planModuleWithProviderE(t, module, candidateVersion)
+	if err != nil {
+		result.CandidateError = firstLines(err.Error(), 20)
+	}
+	if baseline != nil && candidate != nil {
+		result.Differences = PlanDifferences(baseline, candidate)
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverrideProviderVersion(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeModuleTree(t, root, map[string]string{
+		"app/versions.tf":            "terraform {\n  required_providers {\n    azurerm = {\n      source  = \"hashicorp/azurerm\"\n      version = \"~> 4.0\"\n    }\n  }\n}\n",
+		"app/main.tf":                "resource \"azurerm_resource_group\" \"this\" {}\n",
+		"random/versions.tf":         "terraform {\n  required_providers {\n    random = {\n      source = \"hashicorp/random\"\n    }\n  }\n}\n",
+		"app/.terraform/versions.tf": "source = \"hashicorp/azurerm\"\n",
+	})
+
+	if err := overrideProviderVersionE(root, "5.0.0-beta1"); err != nil {
+		t.Fatalf("Overriding provider version: %v", err)
+	}
+
+	override, err := os.ReadFile(filepath.Join(root, "app", providerOverrideFile))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(override), `version = "5.0.0-beta1"`)
+	}
+	assert.NoFileExists(t, filepath.Join(root, "random", providerOverrideFile), "modules without azurerm should not be overridden")
+	assert.NoFileExists(t, filepath.Join(root, "app", ".terraform", providerOverrideFile), ".terraform should be skipped")
+}
+
+// plannedResources builds a plan with the given planned attribute values
+func plannedResources(resources map[string]map[string]interface{}) *terraform.PlanStruct {
+	plan := &terraform.PlanStruct{ResourcePlannedValuesMap: map[string]*tfjson.StateResource{}}
+	for address, attributes := range resources {
+		plan.ResourcePlannedValuesMap[address] = &tfjson.StateResource{Address: address, AttributeValues: attributes}
+	}
+	return plan
+}
+
+func TestPlanDifferences(t *testing.T) {
+	t.Parallel()
+
+	baseline := plannedResources(map[string]map[string]interface{}{
+		"azurerm_key_vault.this":       {"sku_name": "standard", "enable_rbac_authorization": true},
+		"azurerm_resource_group.this":  {"name": "rg-upgrade"},
+		"azurerm_key_vault_secret.old": {"name": "old"},
+	})
+	candidate := plannedResources(map[string]map[string]interface{}{
+		"azurerm_key_vault.this":       {"sku_name": "standard", "rbac_authorization_enabled": true},
+		"azurerm_resource_group.this":  {"name": "rg-upgrade"},
+		"azurerm_key_vault_secret.new": {"name": "new"},
+	})
+
+	assert.Equal(t, []string{
+		"azurerm_key_vault.this: enable_rbac_authorization true -> null",
+		"azurerm_key_vault.this: rbac_authorization_enabled null -> true",
+		"azurerm_key_vault_secret.new: newly planned",
+		"azurerm_key_vault_secret.old: no longer planned",
+	}, PlanDifferences(baseline, candidate))
+	assert.Empty(t, PlanDifferences(baseline, baseline))
+}
+
+func TestProviderUpgradeResultBreaks(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, (&ProviderUpgradeResult{}).Breaks())
+	assert.True(t, (&ProviderUpgradeResult{CandidateError: "Unsupported argument"}).Breaks())
+	assert.False(t, (&ProviderUpgradeResult{BaselineError: "x", CandidateError: "x"}).Breaks(), "a plan that already fails is not broken by the upgrade")
+}
+
+func TestFirstLines(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "a\nb\n...", firstLines("\na\nb\nc\n", 2))
+	assert.Equal(t, "a", firstLines("a", 2))
+}
@@ -0,0 +1,202 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+const (
+	// QuarantineStateQuarantined is the state of new images in a registry
+	// with the quarantine policy
+	QuarantineStateQuarantined = "Quarantined"
+	// QuarantineStatePassed releases an image to regular pulls
+	QuarantineStatePassed = "Passed"
+)
+
+// ManifestQuarantine is the quarantine state of a manifest in ACR
+type ManifestQuarantine struct {
+	Digest string `json:"digest"`
+	// State is empty for images pushed without the quarantine policy
+	State   string `json:"quarantineState"`
+	Details string `json:"quarantineDetails"`
+}
+
+// manifestAttributes is the response of the ACR manifest attributes API
+type manifestAttributes struct {
+	Manifest ManifestQuarantine `json:"manifest"`
+}
+
+// ManifestQuarantineE returns the quarantine state of the image at
+// reference, which must be pinned by digest, as the runner's Azure CLI
+// identity sees it
+func ManifestQuarantineE(t *testing.T, reference string) (*ManifestQuarantine, error) {
+	digest, refreshToken, err := quarantineTargetE(t, reference)
+	if err != nil {
+		return nil, err
+	}
+	var attributes manifestAttributes
+	err = manifestAttributesRequestE(&http.Client{Timeout: 30 * time.Second}, "https://"+digest.RegistryStr(),
+		refreshToken, http.MethodGet, digest, nil, &attributes)
+	if err != nil {
+		return nil, err
+	}
+	return &attributes.Manifest, nil
+}
+
+// ReleaseQuarantineE marks the image at reference, pinned by digest, as
+// passed, the way a scanner does once the image is clean. details is stored
+// as the manifest's quarantine details, usually the scan report. Needs
+// AcrQuarantineWriter, which Owner and Contributor include
+func ReleaseQuarantineE(t *testing.T, reference string, details interface{}) error {
+	digest, refreshToken, err := quarantineTargetE(t, reference)
+	if err != nil {
+		return err
+	}
+	encodedDetails, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{
+		"quarantineState":   QuarantineStatePassed,
+		"quarantineDetails": string(encodedDetails),
+	})
+	if err != nil {
+		return err
+	}
+	return manifestAttributesRequestE(&http.Client{Timeout: 30 * time.Second}, "https://"+digest.RegistryStr(),
+		refreshToken, http.MethodPatch, digest, body, nil)
+}
+
+// quarantineTargetE parses reference and gets an ACR refresh token for its
+// registry
+func quarantineTargetE(t *testing.T, reference string) (name.Digest, string, error) {
+	digest, err := name.NewDigest(reference)
+	if err != nil {
+		return name.Digest{}, "", fmt.Errorf("%s is not pinned by digest: %w", reference, err)
+	}
+	auth, err := acrAuthenticatorE(t, digest.RegistryStr())
+	if err != nil {
+		return name.Digest{}, "", err
+	}
+	credentials, err := auth.Authorization()
+	if err != nil {
+		return name.Digest{}, "", err
+	}
+	return digest, credentials.Password, nil
+}
+
+// manifestAttributesRequestE calls the ACR manifest attributes API at
+// baseURL for digest with an access token exchanged from refreshToken. The
+// response, if any, is decoded into result
+func manifestAttributesRequestE(client *http.Client, baseURL, refreshToken, method string, digest name.Digest, body []byte, result interface{}) error {
+	repository := digest.RepositoryStr()
+	accessToken, err := acrAccessTokenE(client, baseURL, digest.RegistryStr(), refreshToken,
+		fmt.Sprintf("repository:%s:metadata_read,metadata_write", repository))
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/acr/v1/%s/_manifests/%s", baseURL, repository, digest.DigestStr())
+	request, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("%s %s returned %d: %s", method, endpoint, response.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("decoding manifest attributes of %s: %w", digest, err)
+	}
+	return nil
+}
+
+// acrAccessTokenE exchanges an ACR refresh token for an access token
+// limited to scope
+func acrAccessTokenE(client *http.Client, baseURL, service, refreshToken, scope string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"service":       {service},
+		"scope":         {scope},
+		"refresh_token": {refreshToken},
+	}
+	response, err := client.PostForm(baseURL+"/oauth2/token", form)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("exchanging ACR refresh token for %s returned %d", scope, response.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decoding ACR access token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("ACR returned no access token for %s", scope)
+	}
+	return token.AccessToken, nil
+}
+
+// RegistryDigestE returns the digest of the image at reference, as the
+// runner's Azure CLI identity sees it
+func RegistryDigestE(t *testing.T, reference string) (string, error) {
+	ref, err := name.ParseReference(reference)
+	if err != nil {
+		return "", err
+	}
+	auth, err := acrAuthenticatorE(t, ref.Context().RegistryStr())
+	if err != nil {
+		return "", err
+	}
+	descriptor, err := remote.Head(ref, remote.WithAuth(auth))
+	if err != nil {
+		return "", fmt.Errorf("reading digest of %s: %w", reference, err)
+	}
+	return descriptor.Digest.String(), nil
+}
+
+// PullManifestWithTokenE fetches the manifest of the image at reference with
+// registry token credentials, as a consumer without quarantine rights would
+func PullManifestWithTokenE(reference, username, password string) error {
+	ref, err := name.ParseReference(reference)
+	if err != nil {
+		return err
+	}
+	_, err = remote.Get(ref, remote.WithAuth(&authn.Basic{Username: username, Password: password}))
+	return err
+}
+
+// ImportImageE imports source into the registry as target
+// (repository:tag) with `az acr import`, which applies the registry's
+// quarantine policy like a push does
+func ImportImageE(t *testing.T, registryName, source, target string) error {
+	_, err := AzCLIE(t, "acr", "import", "--name", registryName, "--source", source, "--image", target)
+	return err
+}
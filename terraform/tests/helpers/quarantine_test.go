@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// fakeQuarantineRegistry serves the ACR token exchange and manifest
+// attributes API for one manifest
+func fakeQuarantineRegistry(t *testing.T, state *ManifestQuarantine) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/oauth2/token":
+			assert.NoError(t, r.ParseForm())
+			if r.Form.Get("refresh_token") != "refresh" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "repository:fixtures/echo:metadata_read,metadata_write", r.Form.Get("scope"))
+			fmt.Fprint(w, `{"access_token": "access"}`)
+		case r.URL.Path == "/acr/v1/fixtures/echo/_manifests/"+sampleDigest:
+			assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+			if r.Method == http.MethodPatch {
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(state))
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(manifestAttributes{Manifest: *state}))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestManifestAttributesRequestE(t *testing.T) {
+	t.Parallel()
+
+	state := &ManifestQuarantine{Digest: sampleDigest, State: QuarantineStateQuarantined}
+	server := fakeQuarantineRegistry(t, state)
+	defer server.Close()
+
+	digest, err := name.NewDigest(strings.TrimPrefix(server.URL, "http://") + "/fixtures/echo@" + sampleDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var attributes manifestAttributes
+	if assert.NoError(t, manifestAttributesRequestE(server.Client(), server.URL, "refresh", http.MethodGet, digest, nil, &attributes)) {
+		assert.Equal(t, QuarantineStateQuarantined, attributes.Manifest.State)
+	}
+
+	release := []byte(`{"quarantineState": "Passed", "quarantineDetails": "{\"vulnerabilities\":0}"}`)
+	if assert.NoError(t, manifestAttributesRequestE(server.Client(), server.URL, "refresh", http.MethodPatch, digest, release, nil)) {
+		assert.Equal(t, QuarantineStatePassed, state.State)
+		assert.Equal(t, `{"vulnerabilities":0}`, state.Details)
+	}
+
+	err = manifestAttributesRequestE(server.Client(), server.URL, "expired", http.MethodGet, digest, nil, &attributes)
+	assert.ErrorContains(t, err, "returned 401")
+}
@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+)
+
+// replicaPollInterval is how often WaitForReplicaCountE reads the replicas;
+// KEDA itself polls scalers every 30 seconds
+const replicaPollInterval = 30 * time.Second
+
+// QueueReplicas returns the replica count a queue scale rule settles on at
+// depth messages with queueLength messages per replica: one replica per
+// queueLength messages, rounded up, within minReplicas and maxReplicas
+func QueueReplicas(depth, queueLength, minReplicas, maxReplicas int) int {
+	replicas := 0
+	if queueLength > 0 {
+		replicas = (depth + queueLength - 1) / queueLength
+	}
+	if replicas < minReplicas {
+		replicas = minReplicas
+	}
+	if replicas > maxReplicas {
+		replicas = maxReplicas
+	}
+	return replicas
+}
+
+// PutQueueMessagesE adds count messages to a Storage Queue, authenticating
+// with the account connection string the way a queue scaler does
+func PutQueueMessagesE(t *testing.T, connectionString, queue string, count int) error {
+	for i := 0; i < count; i++ {
+		if _, err := AzCLIE(t, "storage", "message", "put", "--connection-string", connectionString,
+			"--queue-name", queue, "--content", fmt.Sprintf("message-%d", i)); err != nil {
+			return fmt.Errorf("putting message %d on %s: %w", i, queue, err)
+		}
+	}
+	return nil
+}
+
+// ClearQueueE deletes every message of a Storage Queue
+func ClearQueueE(t *testing.T, connectionString, queue string) error {
+	if _, err := AzCLIE(t, "storage", "message", "clear", "--connection-string", connectionString,
+		"--queue-name", queue); err != nil {
+		return fmt.Errorf("clearing %s: %w", queue, err)
+	}
+	return nil
+}
+
+// WaitForReplicaCountE waits up to timeout for the latest revision of a
+// Container App to run exactly want replicas and returns how long that took
+func WaitForReplicaCountE(t *testing.T, resourceGroupName, appName string, want int, timeout time.Duration) (time.Duration, error) {
+	started := time.Now()
+	attempts := int(timeout/replicaPollInterval) + 1
+	_, err := retry.DoWithRetryE(t, fmt.Sprintf("waiting for %s to run %d replicas", appName, want), attempts, replicaPollInterval, func() (string, error) {
+		replicas, err := ReplicaCountE(t, resourceGroupName, appName)
+		if err != nil {
+			return "", err
+		}
+		if replicas != want {
+			return "", fmt.Errorf("%s runs %d replicas, want %d", appName, replicas, want)
+		}
+		return "", nil
+	})
+	return time.Since(started), err
+}
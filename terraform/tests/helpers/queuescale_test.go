@@ -0,0 +1,33 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueReplicas(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		depth    int
+		expected int
+	}{
+		{"empty", 0, 0},
+		{"partial", 1, 1},
+		{"exact", 10, 2},
+		{"rounds_up", 11, 3},
+		{"capped", 100, 3},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, QueueReplicas(tc.depth, 5, 0, 3))
+		})
+	}
+
+	assert.Equal(t, 1, QueueReplicas(0, 5, 1, 3), "min_replicas applies to an empty queue")
+}
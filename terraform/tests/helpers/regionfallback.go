@@ -0,0 +1,148 @@
+package helpers
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// defaultAllowedLocations are the resource-group module's approved regions,
+// in the order tests fall back through them
+const defaultAllowedLocations = "eastus2,westus2,centralus,eastus"
+
+// capacityErrorPatterns match apply errors caused by a region being out of
+// capacity or not offering a SKU, which another region can serve
+var capacityErrorPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`SkuNotAvailable`),
+	regexp.MustCompile(`AllocationFailed`),
+	regexp.MustCompile(`Overconstrained(Zonal)?AllocationRequest`),
+	regexp.MustCompile(`LocationNotAvailableForResourceType`),
+	regexp.MustCompile(`RegionDoesNotAllowProvisioning`),
+	regexp.MustCompile(`(?i)(insufficient|out of|not enough) capacity`),
+	regexp.MustCompile(`(?i)capacity (is )?(not available|unavailable|constrained)`),
+	regexp.MustCompile(`(?i)experiencing high demand`),
+}
+
+// regionFallback is the region_fallback report entry of a test that moved
+type regionFallback struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Error is the capacity error that made the test move
+	Error string `json:"error"`
+	// Succeeded is false when the test failed in the fallback region too
+	Succeeded bool `json:"succeeded"`
+}
+
+// IsCapacityError reports whether an apply error means the region has no
+// capacity for, or does not offer, a requested resource or SKU
+func IsCapacityError(err error) bool {
+	return err != nil && capacityErrorLine(err.Error()) != ""
+}
+
+// capacityErrorLine returns the first line of output matching a capacity
+// error, or "" if none does
+func capacityErrorLine(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		for _, pattern := range capacityErrorPatterns {
+			if pattern.MatchString(line) {
+				return strings.TrimSpace(line)
+			}
+		}
+	}
+	return ""
+}
+
+// AllowedLocations returns the regions tests may deploy to, from
+// ARM_ALLOWED_LOCATIONS (comma-separated) or the resource-group module's
+// approved regions
+func AllowedLocations() []string {
+	var locations []string
+	for _, location := range strings.Split(getEnvOrDefault("ARM_ALLOWED_LOCATIONS", defaultAllowedLocations), ",") {
+		if location = strings.ToLower(strings.TrimSpace(location)); location != "" {
+			locations = append(locations, location)
+		}
+	}
+	return locations
+}
+
+// nextLocation returns the region after current in allowed, wrapping
+// around, or false when allowed has no other region
+func nextLocation(allowed []string, current string) (string, bool) {
+	for i, location := range allowed {
+		if normalizeRegion(location) != normalizeRegion(current) {
+			continue
+		}
+		next := allowed[(i+1)%len(allowed)]
+		return next, normalizeRegion(next) != normalizeRegion(current)
+	}
+	// A region outside the list falls back to the start of the list
+	if len(allowed) > 0 {
+		return allowed[0], true
+	}
+	return "", false
+}
+
+// DeployWithRegionFallback runs deploy, which applies options and may apply
+// it more than once. When deploy fails on a capacity error, the test moves
+// once to the next allowed region: what was created is destroyed, config
+// gets the new Location and a new UniqueID, options.Vars is rebuilt with
+// vars and deploy runs again. The move is recorded in the region_fallback
+// report. vars must build the fixture variables from config, so the new
+// region and names are used. Other errors fail the test. Once deployed, the
+// outputs are checked with AssertOutputsUsable. All of it runs as the apply
+// stage, which SKIP_apply skips like InitAndApply does
+func DeployWithRegionFallback(t *testing.T, config *TestConfig, options *terraform.Options, vars func() map[string]interface{}, deploy func() error) {
+	applyStage(t, options, func() {
+		deployWithRegionFallback(t, config, options, vars, deploy)
+	})
+}
+
+// deployWithRegionFallback is the apply stage of DeployWithRegionFallback
+func deployWithRegionFallback(t *testing.T, config *TestConfig, options *terraform.Options, vars func() map[string]interface{}, deploy func() error) {
+	err := deploy()
+	if err == nil {
+		AssertOutputsUsable(t, options)
+		return
+	}
+	if !IsCapacityError(err) {
+		t.Fatalf("Deploying in %s: %v", config.Location, err)
+	}
+	next, found := nextLocation(AllowedLocations(), config.Location)
+	if !found {
+		t.Fatalf("Deploying in %s hit a capacity error and no other region is allowed: %v", config.Location, err)
+	}
+
+	fallback := regionFallback{From: config.Location, To: next, Error: capacityErrorLine(err.Error())}
+	t.Logf("%s is out of capacity (%s), retrying in %s", fallback.From, fallback.Error, fallback.To)
+	if _, destroyErr := terraform.DestroyE(t, options); destroyErr != nil {
+		RecordReport(t, "region_fallback", t.Name(), fallback)
+		t.Fatalf("Destroying what was created in %s before moving to %s: %v", fallback.From, fallback.To, destroyErr)
+	}
+
+	config.Location = next
+	config.UniqueID = strings.ToLower(random.UniqueId())
+	options.Vars = vars()
+	CaptureServiceHealthOnFailure(t, config.SubscriptionID, config.Location)
+
+	err = deploy()
+	fallback.Succeeded = err == nil
+	RecordReport(t, "region_fallback", t.Name(), fallback)
+	if err != nil {
+		t.Fatalf("Deploying in %s after falling back from %s: %v", fallback.To, fallback.From, err)
+	}
+	AssertOutputsUsable(t, options)
+}
+
+// InitAndApplyWithRegionFallback runs terraform init and apply, moving the
+// test to the next allowed region on a capacity error (see
+// DeployWithRegionFallback)
+func InitAndApplyWithRegionFallback(t *testing.T, config *TestConfig, options *terraform.Options, vars func() map[string]interface{}) {
+	DeployWithRegionFallback(t, config, options, vars, func() error {
+		initStage(t, options)
+		_, err := terraform.ApplyE(t, options)
+		return err
+	})
+}
@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsCapacityError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		output   string
+		capacity bool
+	}{
+		{"sku", "Error: creating Registry: Code=\"SkuNotAvailable\" Message=\"The requested size is not available\"", true},
+		{"zonal allocation", "polling after CreateOrUpdate: Code=\"ZonalAllocationFailed\"", true},
+		{"resource type", "Code=\"LocationNotAvailableForResourceType\" Message=\"The provided location 'eastus2' is not available\"", true},
+		{"managed environment", "Error: creating Managed Environment: the region has insufficient capacity for this request", true},
+		{"quota", "Code=\"QuotaExceeded\" Message=\"Operation could not be completed as it results in exceeding approved quota\"", false},
+		{"conflict", "Error: a resource with the ID \"/subscriptions/x/resourceGroups/rg\" already exists", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.capacity, IsCapacityError(errors.New("\n│ "+tc.output+"\n")))
+		})
+	}
+
+	assert.False(t, IsCapacityError(nil))
+	assert.Equal(t, "│ Code=\"SkuNotAvailable\"", capacityErrorLine("Error: apply failed\n│ Code=\"SkuNotAvailable\"\n"))
+}
+
+func TestNextLocation(t *testing.T) {
+	t.Parallel()
+
+	allowed := []string{"eastus2", "westus2", "centralus"}
+
+	next, found := nextLocation(allowed, "eastus2")
+	assert.True(t, found)
+	assert.Equal(t, "westus2", next)
+
+	next, _ = nextLocation(allowed, "centralus")
+	assert.Equal(t, "eastus2", next, "the list wraps around")
+
+	next, _ = nextLocation(allowed, "East US 2")
+	assert.Equal(t, "westus2", next, "display names match")
+
+	next, found = nextLocation(allowed, "swedencentral")
+	assert.True(t, found)
+	assert.Equal(t, "eastus2", next, "a region outside the list falls back to the first allowed one")
+
+	_, found = nextLocation([]string{"eastus2"}, "eastus2")
+	assert.False(t, found, "no other region to fall back to")
+}
+
+func TestAllowedLocations(t *testing.T) {
+	t.Setenv("ARM_ALLOWED_LOCATIONS", " WestUS2, ,eastus2 ")
+	assert.Equal(t, []string{"westus2", "eastus2"}, AllowedLocations())
+
+	t.Setenv("ARM_ALLOWED_LOCATIONS", "")
+	assert.Equal(t, []string{"eastus2", "westus2", "centralus", "eastus"}, AllowedLocations())
+}
@@ -0,0 +1,295 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// RegionCatalogFile records which resource types, availability zones and
+// SKUs each region offers; `ttk regions update` regenerates it
+const RegionCatalogFile = "testdata/regions.json"
+
+// Resource types the modules and fixtures deploy, tracked in the catalog
+const (
+	ResourceTypeContainerAppEnvironment = "Microsoft.App/managedEnvironments"
+	ResourceTypeContainerApp            = "Microsoft.App/containerApps"
+	ResourceTypeContainerRegistry       = "Microsoft.ContainerRegistry/registries"
+	ResourceTypeKeyVault                = "Microsoft.KeyVault/vaults"
+	ResourceTypeLogAnalyticsWorkspace   = "Microsoft.OperationalInsights/workspaces"
+	ResourceTypeApplicationInsights     = "Microsoft.Insights/components"
+	ResourceTypeFirewall                = "Microsoft.Network/azureFirewalls"
+	ResourceTypePrivateEndpoint         = "Microsoft.Network/privateEndpoints"
+)
+
+// CatalogResourceTypes are the resource types `ttk regions update` records
+var CatalogResourceTypes = []string{
+	ResourceTypeContainerAppEnvironment,
+	ResourceTypeContainerApp,
+	ResourceTypeContainerRegistry,
+	ResourceTypeKeyVault,
+	ResourceTypeLogAnalyticsWorkspace,
+	ResourceTypeApplicationInsights,
+	ResourceTypeFirewall,
+	ResourceTypePrivateEndpoint,
+}
+
+var (
+	regionCatalogOnce sync.Once
+	regionCatalog     *RegionCatalog
+	regionCatalogErr  error
+)
+
+// RegionCatalog is what each region offers, keyed by normalized region name
+// such as "eastus2"
+type RegionCatalog struct {
+	// GeneratedAt is when the catalog was last refreshed, RFC 3339
+	GeneratedAt string                         `json:"generated_at"`
+	Regions     map[string]*RegionCapabilities `json:"regions"`
+}
+
+// RegionCapabilities are the tracked resource types and SKUs of one region
+type RegionCapabilities struct {
+	ResourceTypes []string `json:"resource_types"`
+	// ZonalResourceTypes support availability zones in the region
+	ZonalResourceTypes []string `json:"zonal_resource_types,omitempty"`
+	// SKUs by resource type, e.g. the Container Apps workload profile types
+	SKUs map[string][]string `json:"skus,omitempty"`
+}
+
+// ResourceProvider is a resource provider as `az provider show` returns it
+// from the Resource Manager providers API
+type ResourceProvider struct {
+	Namespace     string                 `json:"namespace"`
+	ResourceTypes []ProviderResourceType `json:"resourceTypes"`
+}
+
+// ProviderResourceType is a resource type of a provider, with the regions
+// offering it as display names ("East US 2") and its zones per region
+type ProviderResourceType struct {
+	ResourceType string   `json:"resourceType"`
+	Locations    []string `json:"locations"`
+	ZoneMappings []struct {
+		Location string   `json:"location"`
+		Zones    []string `json:"zones"`
+	} `json:"zoneMappings"`
+}
+
+// RegionRequirement is something a test needs from the region it deploys to:
+// a resource type, optionally with availability zones or a given SKU
+type RegionRequirement struct {
+	ResourceType string
+	Zonal        bool
+	SKU          string
+}
+
+// NeedsResourceType requires a resource type in the region
+func NeedsResourceType(resourceType string) RegionRequirement {
+	return RegionRequirement{ResourceType: resourceType}
+}
+
+// NeedsZones requires a resource type with availability zones in the region
+func NeedsZones(resourceType string) RegionRequirement {
+	return RegionRequirement{ResourceType: resourceType, Zonal: true}
+}
+
+// NeedsSKU requires a SKU of a resource type in the region
+func NeedsSKU(resourceType, sku string) RegionRequirement {
+	return RegionRequirement{ResourceType: resourceType, SKU: sku}
+}
+
+func (r RegionRequirement) String() string {
+	switch {
+	case r.Zonal:
+		return r.ResourceType + " with availability zones"
+	case r.SKU != "":
+		return fmt.Sprintf("%s SKU %s", r.ResourceType, r.SKU)
+	}
+	return r.ResourceType
+}
+
+// BuildRegionCatalog builds the catalog of resourceTypes from providers.
+// regions limits it to those regions; empty keeps every region offering one
+// of resourceTypes. skus is added as is, by region then resource type
+func BuildRegionCatalog(providers []ResourceProvider, resourceTypes, regions []string, skus map[string]map[string][]string) *RegionCatalog {
+	catalog := &RegionCatalog{Regions: map[string]*RegionCapabilities{}}
+	wanted := map[string]bool{}
+	for _, region := range regions {
+		wanted[normalizeRegion(region)] = true
+	}
+	region := func(name string) *RegionCapabilities {
+		name = normalizeRegion(name)
+		if len(wanted) > 0 && !wanted[name] {
+			return nil
+		}
+		if catalog.Regions[name] == nil {
+			catalog.Regions[name] = &RegionCapabilities{ResourceTypes: []string{}}
+		}
+		return catalog.Regions[name]
+	}
+
+	for _, provider := range providers {
+		for _, providerType := range provider.ResourceTypes {
+			// Providers are not consistent about casing; keep the one of
+			// resourceTypes
+			resourceType, tracked := findFold(resourceTypes, provider.Namespace+"/"+providerType.ResourceType)
+			if !tracked {
+				continue
+			}
+			for _, location := range providerType.Locations {
+				if capabilities := region(location); capabilities != nil {
+					capabilities.ResourceTypes = append(capabilities.ResourceTypes, resourceType)
+				}
+			}
+			for _, mapping := range providerType.ZoneMappings {
+				if len(mapping.Zones) == 0 {
+					continue
+				}
+				if capabilities := region(mapping.Location); capabilities != nil {
+					capabilities.ZonalResourceTypes = append(capabilities.ZonalResourceTypes, resourceType)
+				}
+			}
+		}
+	}
+
+	for name, bySKU := range skus {
+		capabilities := region(name)
+		if capabilities == nil {
+			continue
+		}
+		for resourceType, names := range bySKU {
+			if capabilities.SKUs == nil {
+				capabilities.SKUs = map[string][]string{}
+			}
+			capabilities.SKUs[resourceType] = append(capabilities.SKUs[resourceType], names...)
+		}
+	}
+
+	for _, capabilities := range catalog.Regions {
+		capabilities.ResourceTypes = sortedUnique(capabilities.ResourceTypes)
+		capabilities.ZonalResourceTypes = sortedUnique(capabilities.ZonalResourceTypes)
+		for resourceType, names := range capabilities.SKUs {
+			capabilities.SKUs[resourceType] = sortedUnique(names)
+		}
+	}
+	return catalog
+}
+
+// findFold returns the entry of values equal to value, ignoring case
+func findFold(values []string, value string) (string, bool) {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// containsFold reports whether values contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	_, found := findFold(values, value)
+	return found
+}
+
+// sortedUnique sorts values and drops duplicates; nil stays nil
+func sortedUnique(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	sort.Strings(values)
+	unique := values[:0]
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// Missing returns the requirements region does not meet, as skip reasons.
+// known is false when the catalog does not list the region, in which case
+// nothing is known to be missing
+func (c *RegionCatalog) Missing(region string, requirements ...RegionRequirement) (missing []string, known bool) {
+	capabilities, known := c.Regions[normalizeRegion(region)]
+	if !known {
+		return nil, false
+	}
+	for _, requirement := range requirements {
+		switch {
+		case !containsFold(capabilities.ResourceTypes, requirement.ResourceType):
+		case requirement.Zonal && !containsFold(capabilities.ZonalResourceTypes, requirement.ResourceType):
+		case requirement.SKU != "" && !containsFold(capabilities.SKUs[requirement.ResourceType], requirement.SKU):
+		default:
+			continue
+		}
+		missing = append(missing, fmt.Sprintf("%s does not offer %s", normalizeRegion(region), requirement))
+	}
+	return missing, true
+}
+
+// ReadRegionCatalogE reads a catalog written by `ttk regions update`
+func ReadRegionCatalogE(path string) (*RegionCatalog, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var catalog RegionCatalog
+	if err := json.Unmarshal(content, &catalog); err != nil {
+		return nil, fmt.Errorf("decoding region catalog %s: %w", path, err)
+	}
+	return &catalog, nil
+}
+
+// LoadRegionCatalog returns the catalog in RegionCatalogFile, read once per
+// run, failing the test when it cannot be read. Without the file no region
+// is known
+func LoadRegionCatalog(t *testing.T) *RegionCatalog {
+	regionCatalogOnce.Do(func() {
+		regionCatalog, regionCatalogErr = ReadRegionCatalogE(RegionCatalogFile)
+		if errors.Is(regionCatalogErr, fs.ErrNotExist) {
+			regionCatalog, regionCatalogErr = &RegionCatalog{Regions: map[string]*RegionCapabilities{}}, nil
+		}
+	})
+	if regionCatalogErr != nil {
+		t.Fatalf("Reading the region catalog: %v", regionCatalogErr)
+	}
+	return regionCatalog
+}
+
+// SkipUnlessRegionSupports skips the test when the region catalog shows that
+// region lacks one of requirements, naming what is missing, instead of
+// letting the deployment fail. A region missing from the catalog is assumed
+// to support everything
+func SkipUnlessRegionSupports(t *testing.T, region string, requirements ...RegionRequirement) {
+	missing, known := LoadRegionCatalog(t).Missing(region, requirements...)
+	if !known {
+		t.Logf("%s is not in %s, assuming it offers what the test needs; refresh it with `ttk regions update`", region, RegionCatalogFile)
+		return
+	}
+	if len(missing) > 0 {
+		SkipWithReason(t, SkipRegionCapability, fmt.Sprintf("%s (per %s)", strings.Join(missing, "; "), RegionCatalogFile))
+	}
+}
+
+// RunRegionMatrix runs test once per region as a parallel subtest named
+// after the region. Regions that the catalog shows lacking one of
+// requirements are skipped with the reason rather than deployed to
+func RunRegionMatrix(t *testing.T, regions []string, requirements []RegionRequirement, test func(t *testing.T, region string)) {
+	for _, region := range regions {
+		region := strings.TrimSpace(region)
+		if region == "" {
+			continue
+		}
+		t.Run(region, func(t *testing.T) {
+			t.Parallel()
+			SkipUnlessRegionSupports(t, region, requirements...)
+			test(t, region)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testProvidersJSON = `[
+  {"namespace": "Microsoft.App", "resourceTypes": [
+    {"resourceType": "managedEnvironments", "locations": ["East US 2", "West US 2"],
+     "zoneMappings": [{"location": "East US 2", "zones": ["1", "2", "3"]}, {"location": "West US 2", "zones": []}]},
+    {"resourceType": "containerApps", "locations": ["East US 2", "West US 2"]},
+    {"resourceType": "jobs", "locations": ["East US 2"]}
+  ]},
+  {"namespace": "Microsoft.Network", "resourceTypes": [
+    {"resourceType": "azureFirewalls", "locations": ["East US 2", "Central US"]}
+  ]}
+]`
+
+func TestBuildRegionCatalog(t *testing.T) {
+	t.Parallel()
+
+	var providers []ResourceProvider
+	if err := json.Unmarshal([]byte(testProvidersJSON), &providers); err != nil {
+		t.Fatal(err)
+	}
+	skus := map[string]map[string][]string{
+		"eastus2": {ResourceTypeContainerAppEnvironment: {"D4", "Consumption", "D4"}},
+		"uksouth": {ResourceTypeContainerAppEnvironment: {"D4"}},
+	}
+
+	catalog := BuildRegionCatalog(providers, CatalogResourceTypes, []string{"eastus2", "West US 2"}, skus)
+	assert.Len(t, catalog.Regions, 2, "regions outside the list are left out")
+	if eastus2 := catalog.Regions["eastus2"]; assert.NotNil(t, eastus2) {
+		assert.Equal(t, []string{ResourceTypeContainerApp, ResourceTypeContainerAppEnvironment, ResourceTypeFirewall}, eastus2.ResourceTypes)
+		assert.Equal(t, []string{ResourceTypeContainerAppEnvironment}, eastus2.ZonalResourceTypes)
+		assert.Equal(t, []string{"Consumption", "D4"}, eastus2.SKUs[ResourceTypeContainerAppEnvironment])
+	}
+	if westus2 := catalog.Regions["westus2"]; assert.NotNil(t, westus2) {
+		assert.Empty(t, westus2.ZonalResourceTypes, "a zone mapping without zones is not zonal")
+	}
+
+	all := BuildRegionCatalog(providers, CatalogResourceTypes, nil, nil)
+	assert.Contains(t, all.Regions, "centralus")
+}
+
+func TestRegionCatalogMissing(t *testing.T) {
+	t.Parallel()
+
+	catalog := &RegionCatalog{Regions: map[string]*RegionCapabilities{
+		"westus2": {
+			ResourceTypes: []string{ResourceTypeContainerApp, ResourceTypeContainerAppEnvironment},
+			SKUs:          map[string][]string{ResourceTypeContainerAppEnvironment: {"Consumption", "D4"}},
+		},
+	}}
+
+	missing, known := catalog.Missing("West US 2",
+		NeedsResourceType(ResourceTypeContainerAppEnvironment),
+		NeedsZones(ResourceTypeContainerAppEnvironment),
+		NeedsSKU(ResourceTypeContainerAppEnvironment, "d4"),
+		NeedsSKU(ResourceTypeContainerAppEnvironment, "E4"),
+		NeedsResourceType(ResourceTypeFirewall))
+	assert.True(t, known)
+	assert.Equal(t, []string{
+		"westus2 does not offer Microsoft.App/managedEnvironments with availability zones",
+		"westus2 does not offer Microsoft.App/managedEnvironments SKU E4",
+		"westus2 does not offer Microsoft.Network/azureFirewalls",
+	}, missing)
+
+	missing, known = catalog.Missing("eastus2", NeedsResourceType(ResourceTypeFirewall))
+	assert.False(t, known, "regions outside the catalog are unknown")
+	assert.Empty(t, missing)
+}
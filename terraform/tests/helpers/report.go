@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// reportRoot holds findings recorded by tests, next to the runner's logs
+const reportRoot = "logs/reports"
+
+// reportMu serializes read-modify-write of report files across parallel tests
+var reportMu sync.Mutex
+
+// ReportDir returns the folder holding the reports of the given run
+func ReportDir(runID string) string {
+	return filepath.Join(reportRoot, runID)
+}
+
+// ReportPath returns the file of the named report for the current run
+func ReportPath(name string) string {
+	return filepath.Join(ReportDir(RunID()), name+".json")
+}
+
+// RecordReportE stores value under key in the named report of the current
+// run. Reports are JSON objects, so recording the same key again replaces it
+func RecordReportE(name, key string, value interface{}) error {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	path := ReportPath(name)
+	entries := map[string]json.RawMessage{}
+	content, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(content, &entries); err != nil {
+			return fmt.Errorf("decoding report %s: %w", path, err)
+		}
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	entries[key] = encoded
+
+	content, err = json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o600)
+}
+
+// RecordReport stores value under key in the named report, failing the test on error
+func RecordReport(t *testing.T, name, key string, value interface{}) {
+	if err := RecordReportE(name, key, value); err != nil {
+		t.Fatalf("Recording %s in report %s: %v", key, name, err)
+	}
+}
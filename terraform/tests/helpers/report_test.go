@@ -0,0 +1,29 @@
+package helpers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReport(t *testing.T) {
+	name := "report-test-" + WorkspaceName("", t.Name())
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(ReportPath(name))) })
+
+	RecordReport(t, name, "eastus2", map[string]bool{"ipv6": false})
+	RecordReport(t, name, "westeurope", map[string]bool{"ipv6": true})
+	RecordReport(t, name, "eastus2", map[string]bool{"ipv6": true})
+
+	content, err := os.ReadFile(ReportPath(name))
+	assert.NoError(t, err)
+
+	var report map[string]map[string]bool
+	assert.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, map[string]map[string]bool{
+		"eastus2":    {"ipv6": true},
+		"westeurope": {"ipv6": true},
+	}, report, "Entries should merge and later records replace earlier ones")
+}
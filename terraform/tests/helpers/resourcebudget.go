@@ -0,0 +1,190 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// ResourceBudgetFile holds the resource count ranges of every module
+// configuration, by module and configuration
+const ResourceBudgetFile = "testdata/resource-budgets.json"
+
+// DefaultConfiguration plans a module alone with its dry run inputs in
+// testdata/provider-upgrade, leaving every optional input at its default.
+// Other configurations are the module's examples of that name
+const DefaultConfiguration = "default"
+
+// ResourceBudget is the range of managed resources a module configuration
+// may plan, inclusive
+type ResourceBudget struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// Contains reports whether count is within the budget
+func (b ResourceBudget) Contains(count int) bool {
+	return count >= b.Min && count <= b.Max
+}
+
+// ResourceBudgets are budgets by module, then configuration
+type ResourceBudgets map[string]map[string]ResourceBudget
+
+// LoadResourceBudgetsE reads the budgets at path; a missing file has none
+func LoadResourceBudgetsE(path string) (ResourceBudgets, error) {
+	budgets := ResourceBudgets{}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return budgets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &budgets); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return budgets, nil
+}
+
+// PlannedResourceCounts counts the managed resources plan leaves in place
+// or creates, by resource type. Data sources and deletions do not count
+func PlannedResourceCounts(plan *terraform.PlanStruct) map[string]int {
+	counts := map[string]int{}
+	for _, change := range plan.ResourceChangesMap {
+		if change.Mode != "managed" || change.Change == nil {
+			continue
+		}
+		if actions := change.Change.Actions; len(actions) == 1 && actions[0] == "delete" {
+			continue
+		}
+		counts[change.Type]++
+	}
+	return counts
+}
+
+// TotalResources adds up counts
+func TotalResources(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+// FormatResourceCounts lists counts as "type: n", sorted by type
+func FormatResourceCounts(counts map[string]int) string {
+	types := make([]string, 0, len(counts))
+	for resourceType := range counts {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+	lines := make([]string, 0, len(types))
+	for _, resourceType := range types {
+		lines = append(lines, fmt.Sprintf("%s: %d", resourceType, counts[resourceType]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ModuleConfigurations returns the configurations of module to budget:
+// DefaultConfiguration, then the module's examples, sorted
+func ModuleConfigurations(module string) ([]string, error) {
+	examples, err := filepath.Glob(filepath.Join("..", "modules", module, "examples", "*", "main.tf"))
+	if err != nil {
+		return nil, err
+	}
+	configurations := []string{DefaultConfiguration}
+	for _, example := range examples {
+		configurations = append(configurations, filepath.Base(filepath.Dir(example)))
+	}
+	sort.Strings(configurations[1:])
+	return configurations, nil
+}
+
+// PlanModuleConfigurationE plans a configuration of module (see
+// ModuleConfigurations) in a temp copy and returns the plan
+func PlanModuleConfigurationE(t *testing.T, module, configuration string) (*terraform.PlanStruct, error) {
+	if configuration == DefaultConfiguration {
+		return planModuleWithProviderE(t, module, "")
+	}
+
+	exampleDir := CopyTerraformDirToTemp(t, filepath.Join("..", "modules", module, "examples", configuration))
+	options := DefaultTerraformOptions(t, exampleDir, nil)
+	options.PlanFilePath = filepath.Join(exampleDir, "budget.tfplan")
+	if _, err := terraform.InitE(t, options); err != nil {
+		return nil, err
+	}
+	if _, err := terraform.PlanE(t, options); err != nil {
+		return nil, err
+	}
+	planJSON, err := terraform.ShowE(t, quietOptions(options))
+	if err != nil {
+		return nil, err
+	}
+	AssertNoPlanLeaks(t, planJSON)
+	return terraform.ParsePlanJSON(planJSON)
+}
+
+// AssertResourceBudget fails the test when the total of counts, planned by
+// configuration of module, is outside its budget in ResourceBudgetFile,
+// listing the planned resources by type. Set UPDATE_RESOURCE_BUDGETS=true to
+// record the count instead: a budget that already contains it is kept, any
+// other becomes exactly the count
+func AssertResourceBudget(t *testing.T, module, configuration string, counts map[string]int) {
+	total := TotalResources(counts)
+	if os.Getenv("UPDATE_RESOURCE_BUDGETS") == "true" {
+		if err := updateResourceBudgetE(ResourceBudgetFile, module, configuration, total); err != nil {
+			t.Fatalf("Updating %s: %v", ResourceBudgetFile, err)
+		}
+		t.Logf("Recorded %d resources for %s (%s) in %s", total, module, configuration, ResourceBudgetFile)
+		return
+	}
+
+	budgets, err := LoadResourceBudgetsE(ResourceBudgetFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget, ok := budgets[module][configuration]
+	if !ok {
+		t.Fatalf("%s has no budget for %s (%s); run with UPDATE_RESOURCE_BUDGETS=true to record it", ResourceBudgetFile, module, configuration)
+	}
+	if !budget.Contains(total) {
+		t.Errorf("%s (%s) plans %d resources, outside its budget of %d-%d in %s:\n%s\n"+
+			"If this is intended, rerun with UPDATE_RESOURCE_BUDGETS=true and commit the budget",
+			module, configuration, total, budget.Min, budget.Max, ResourceBudgetFile, FormatResourceCounts(counts))
+	}
+}
+
+// resourceBudgetsMu serializes updates of the budget file by parallel tests
+var resourceBudgetsMu sync.Mutex
+
+// updateResourceBudgetE records total as the budget of module's
+// configuration in the file at path, keeping a budget that contains it
+func updateResourceBudgetE(path, module, configuration string, total int) error {
+	resourceBudgetsMu.Lock()
+	defer resourceBudgetsMu.Unlock()
+
+	budgets, err := LoadResourceBudgetsE(path)
+	if err != nil {
+		return err
+	}
+	if budgets[module] == nil {
+		budgets[module] = map[string]ResourceBudget{}
+	}
+	if !budgets[module][configuration].Contains(total) {
+		budgets[module][configuration] = ResourceBudget{Min: total, Max: total}
+	}
+	content, err := json.MarshalIndent(budgets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o600)
+}
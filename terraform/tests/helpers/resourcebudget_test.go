@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlannedResourceCounts(t *testing.T) {
+	t.Parallel()
+
+	plan, err := terraform.ParsePlanJSON(`{"format_version": "1.2", "resource_changes": [
+		{"address": "module.kv.azurerm_key_vault.this", "mode": "managed", "type": "azurerm_key_vault", "change": {"actions": ["create"]}},
+		{"address": "module.kv.azurerm_monitor_diagnostic_setting.secret[\"a\"]", "mode": "managed", "type": "azurerm_monitor_diagnostic_setting", "change": {"actions": ["create"]}},
+		{"address": "module.kv.azurerm_monitor_diagnostic_setting.secret[\"b\"]", "mode": "managed", "type": "azurerm_monitor_diagnostic_setting", "change": {"actions": ["no-op"]}},
+		{"address": "module.kv.azurerm_role_assignment.old", "mode": "managed", "type": "azurerm_role_assignment", "change": {"actions": ["delete"]}},
+		{"address": "module.kv.azurerm_role_assignment.this", "mode": "managed", "type": "azurerm_role_assignment", "change": {"actions": ["delete", "create"]}},
+		{"address": "data.azurerm_client_config.current", "mode": "data", "type": "azurerm_client_config", "change": {"actions": ["read"]}}
+	]}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	counts := PlannedResourceCounts(plan)
+	assert.Equal(t, map[string]int{
+		"azurerm_key_vault":                  1,
+		"azurerm_monitor_diagnostic_setting": 2,
+		"azurerm_role_assignment":            1,
+	}, counts, "data sources and deletions do not count; replacements count once")
+	assert.Equal(t, 4, TotalResources(counts))
+	assert.Equal(t, "azurerm_key_vault: 1\nazurerm_monitor_diagnostic_setting: 2\nazurerm_role_assignment: 1", FormatResourceCounts(counts))
+}
+
+func TestResourceBudgetContains(t *testing.T) {
+	t.Parallel()
+
+	budget := ResourceBudget{Min: 2, Max: 4}
+	assert.True(t, budget.Contains(2))
+	assert.True(t, budget.Contains(4))
+	assert.False(t, budget.Contains(5), "a new resource over the budget")
+	assert.False(t, budget.Contains(1))
+}
+
+func TestUpdateResourceBudgetE(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "budgets.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"key-vault": {"default": {"min": 2, "max": 3}}}`), 0o600))
+
+	assert.NoError(t, updateResourceBudgetE(path, "key-vault", "default", 3))
+	assert.NoError(t, updateResourceBudgetE(path, "key-vault", "complete", 5))
+	assert.NoError(t, updateResourceBudgetE(path, "networking", "default", 3))
+	budgets, err := LoadResourceBudgetsE(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, ResourceBudgets{
+			"key-vault": {
+				"default":  {Min: 2, Max: 3},
+				"complete": {Min: 5, Max: 5},
+			},
+			"networking": {"default": {Min: 3, Max: 3}},
+		}, budgets, "a range that holds the count is kept")
+	}
+
+	assert.NoError(t, updateResourceBudgetE(path, "key-vault", "default", 4))
+	budgets, err = LoadResourceBudgetsE(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, ResourceBudget{Min: 4, Max: 4}, budgets["key-vault"]["default"])
+	}
+
+	budgets, err = LoadResourceBudgetsE(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NoError(t, err)
+	assert.Empty(t, budgets)
+}
@@ -0,0 +1,132 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// Resource Graph is indexed from ARM notifications and can lag a delete by a
+// few minutes, so the destroy check polls before calling a resource left over
+const (
+	residualResourcesTimeout  = 5 * time.Minute
+	residualResourcesInterval = 15 * time.Second
+)
+
+// graphQueryResult is the part of `az graph query` output the destroy check
+// reads
+type graphQueryResult struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// residualResourcesQuery returns the Resource Graph query for what is left
+// of resourceGroupName in subscriptionID: the resources in it and, with
+// includeGroup, the group itself. Resource Graph lowercases group names, so
+// they are compared case-insensitively
+func residualResourcesQuery(subscriptionID, resourceGroupName string, includeGroup bool) string {
+	query := fmt.Sprintf("resources\n| where subscriptionId =~ %q and resourceGroup =~ %q\n| project id",
+		subscriptionID, resourceGroupName)
+	if includeGroup {
+		query += fmt.Sprintf("\n| union (resourcecontainers\n"+
+			"| where type =~ \"microsoft.resources/subscriptions/resourcegroups\" and subscriptionId =~ %q and name =~ %q\n"+
+			"| project id)", subscriptionID, resourceGroupName)
+	}
+	return query
+}
+
+// residualResourceIDs returns the sorted IDs of a Resource Graph result
+func residualResourceIDs(result graphQueryResult) []string {
+	ids := []string{}
+	for _, row := range result.Data {
+		ids = append(ids, row.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ResidualResourcesE returns the IDs of the resources Resource Graph still
+// lists in resourceGroupName, and of the group itself with includeGroup
+func ResidualResourcesE(t *testing.T, subscriptionID, resourceGroupName string, includeGroup bool) ([]string, error) {
+	var result graphQueryResult
+	if err := AzCLIJSONE(t, &result, "graph", "query",
+		"--graph-query", residualResourcesQuery(subscriptionID, resourceGroupName, includeGroup),
+		"--subscriptions", subscriptionID, "--first", "1000"); err != nil {
+		return nil, fmt.Errorf("querying Resource Graph for %s: %w", resourceGroupName, err)
+	}
+	return residualResourceIDs(result), nil
+}
+
+// waitForNoResidualResourcesE polls residual on clock until it returns
+// nothing or timeout passes, returning what was still there last
+func waitForNoResidualResourcesE(clock Clock, residual func() ([]string, error), timeout, interval time.Duration) ([]string, error) {
+	deadline := clock.Now().Add(timeout)
+	for {
+		ids, err := residual()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, nil
+		}
+		if clock.Now().After(deadline) {
+			return ids, fmt.Errorf("%d resources still listed after %s", len(ids), timeout)
+		}
+		clock.Sleep(interval)
+	}
+}
+
+// CheckLeftoversAfterDestroy fails the test if Resource Graph still lists
+// the resource group of options, or anything in it, once the test ends. It
+// is registered with t.Cleanup, so it runs after a deferred Destroy, and
+// after a Destroy registered with t.Cleanup later on. The group's name is
+// read then because DeployWithRegionFallback gives the group a new one when
+// it falls back to another region. It is skipped with SKIP_destroy, which
+// keeps the deployment on purpose
+func CheckLeftoversAfterDestroy(t *testing.T, config *TestConfig, options *terraform.Options) {
+	CheckResourceGroupFuncLeftoversAfterDestroy(t, config.SubscriptionID, func() string {
+		return options.Vars["resource_group_name"].(string)
+	})
+}
+
+// CheckGroupLeftoversAfterDestroy is CheckLeftoversAfterDestroy for a
+// resource group named up front, by tests without a TestConfig or whose
+// group is not a variable of their terraform options
+func CheckGroupLeftoversAfterDestroy(t *testing.T, subscriptionID, resourceGroupName string) {
+	CheckResourceGroupFuncLeftoversAfterDestroy(t, subscriptionID, func() string { return resourceGroupName })
+}
+
+// CheckResourceGroupFuncLeftoversAfterDestroy is CheckLeftoversAfterDestroy
+// for a resource group whose name resourceGroupName derives when the check
+// runs, such as the group a composition names after its variables
+func CheckResourceGroupFuncLeftoversAfterDestroy(t *testing.T, subscriptionID string, resourceGroupName func() string) {
+	t.Cleanup(func() {
+		assertNoResidualResources(t, subscriptionID, resourceGroupName(), true)
+	})
+}
+
+// AssertResourceGroupEmpty checks like CheckGroupLeftoversAfterDestroy that
+// nothing is left in a group the test did not create with Terraform, which
+// outlives the destroy, so the group itself is not reported. Defer it before
+// helpers.Destroy so it runs after it
+func AssertResourceGroupEmpty(t *testing.T, subscriptionID, resourceGroupName string) {
+	assertNoResidualResources(t, subscriptionID, resourceGroupName, false)
+}
+
+func assertNoResidualResources(t *testing.T, subscriptionID, resourceGroupName string, includeGroup bool) {
+	if StageSkipped("destroy") {
+		t.Logf("SKIP_destroy is set, not checking %s is gone", resourceGroupName)
+		return
+	}
+	ids, err := waitForNoResidualResourcesE(SystemClock, func() ([]string, error) {
+		return ResidualResourcesE(t, subscriptionID, resourceGroupName, includeGroup)
+	}, residualResourcesTimeout, residualResourcesInterval)
+	if err != nil {
+		t.Errorf("Destroy left resources behind in %s: %v\n%s", resourceGroupName, err, strings.Join(ids, "\n"))
+	}
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResidualResourcesQuery(t *testing.T) {
+	t.Parallel()
+
+	query := residualResourcesQuery("0000-sub", "rg-app-test-ci-abc", true)
+	assert.Contains(t, query, `subscriptionId =~ "0000-sub" and resourceGroup =~ "rg-app-test-ci-abc"`)
+	assert.Contains(t, query, "union (resourcecontainers", "the group itself should count as left over")
+	assert.Contains(t, query, `name =~ "rg-app-test-ci-abc"`)
+
+	assert.NotContains(t, residualResourcesQuery("0000-sub", "rg-app-test-ci-abc", false), "resourcecontainers",
+		"a group that outlives the destroy is not a leftover")
+}
+
+func TestResidualResourceIDs(t *testing.T) {
+	t.Parallel()
+
+	var result graphQueryResult
+	if assert.NoError(t, json.Unmarshal([]byte(`{"count": 2, "data": [{"id": "/b"}, {"id": "/a"}], "skip_token": null}`), &result)) {
+		assert.Equal(t, []string{"/a", "/b"}, residualResourceIDs(result))
+	}
+	assert.Empty(t, residualResourceIDs(graphQueryResult{}))
+}
+
+func TestWaitForNoResidualResourcesE(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("gone after indexing lag", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		polls := 0
+		ids, err := waitForNoResidualResourcesE(clock, func() ([]string, error) {
+			polls++
+			if polls < 3 {
+				return []string{"/vault"}, nil
+			}
+			return []string{}, nil
+		}, 5*time.Minute, 15*time.Second)
+		if assert.NoError(t, err) {
+			assert.Empty(t, ids)
+			assert.Equal(t, 30*time.Second, clock.Slept())
+		}
+	})
+
+	t.Run("left over", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		ids, err := waitForNoResidualResourcesE(clock, func() ([]string, error) {
+			return []string{"/rg", "/vault"}, nil
+		}, time.Minute, 15*time.Second)
+		assert.ErrorContains(t, err, "2 resources still listed after 1m0s")
+		assert.Equal(t, []string{"/rg", "/vault"}, ids, "what is left should be reported")
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		failed := errors.New("graph extension not installed")
+		_, err := waitForNoResidualResourcesE(NewFakeClock(start), func() ([]string, error) {
+			return nil, failed
+		}, time.Minute, 15*time.Second)
+		assert.ErrorIs(t, err, failed)
+	})
+}
@@ -0,0 +1,88 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// RegistryManifest is a manifest of a repository from
+// `az acr manifest list-metadata`
+type RegistryManifest struct {
+	Digest         string    `json:"digest"`
+	Tags           []string  `json:"tags"`
+	LastUpdateTime time.Time `json:"lastUpdateTime"`
+	// ChangeableAttributes holds the locks set on the manifest
+	ChangeableAttributes struct {
+		DeleteEnabled bool `json:"deleteEnabled"`
+	} `json:"changeableAttributes"`
+}
+
+// RegistryManifestsE lists the manifests of repository in registryName
+func RegistryManifestsE(t *testing.T, registryName, repository string) ([]RegistryManifest, error) {
+	var manifests []RegistryManifest
+	if err := AzCLIJSONE(t, &manifests, "acr", "manifest", "list-metadata",
+		"--registry", registryName, "--name", repository); err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// RetentionDryRun returns the digests the untagged manifest retention policy
+// of a registry deletes at now when it keeps them for days, sorted: untagged
+// manifests last updated at least days ago and not locked against deletion
+func RetentionDryRun(manifests []RegistryManifest, days int, now time.Time) []string {
+	cutoff := now.Add(-time.Duration(days) * 24 * time.Hour)
+	purged := []string{}
+	for _, manifest := range manifests {
+		if len(manifest.Tags) > 0 || !manifest.ChangeableAttributes.DeleteEnabled {
+			continue
+		}
+		if !manifest.LastUpdateTime.After(cutoff) {
+			purged = append(purged, manifest.Digest)
+		}
+	}
+	sort.Strings(purged)
+	return purged
+}
+
+// PushRandomImageE pushes a small image with random content to reference,
+// either a tag or a bare repository. A bare repository gets the image
+// untagged, by digest. It returns the digest of the image
+func PushRandomImageE(t *testing.T, reference string) (string, error) {
+	image, err := random.Image(1024, 1)
+	if err != nil {
+		return "", err
+	}
+	digest, err := image.Digest()
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := pushReferenceE(reference, digest.String())
+	if err != nil {
+		return "", err
+	}
+	auth, err := acrAuthenticatorE(t, ref.Context().RegistryStr())
+	if err != nil {
+		return "", err
+	}
+	if err := remote.Write(ref, image, remote.WithAuth(auth)); err != nil {
+		return "", fmt.Errorf("pushing %s: %w", ref, err)
+	}
+	return digest.String(), nil
+}
+
+// pushReferenceE returns where PushRandomImageE pushes the image with digest:
+// reference itself for a tag, by digest for a bare repository
+func pushReferenceE(reference, digest string) (name.Reference, error) {
+	if repository, err := name.NewRepository(reference); err == nil {
+		return repository.Digest(digest), nil
+	}
+	return name.NewTag(reference)
+}
@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionDryRun(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	manifest := func(digest string, updated time.Time, deleteEnabled bool, tags ...string) RegistryManifest {
+		m := RegistryManifest{Digest: digest, Tags: tags, LastUpdateTime: updated}
+		m.ChangeableAttributes.DeleteEnabled = deleteEnabled
+		return m
+	}
+	manifests := []RegistryManifest{
+		manifest("sha256:d", now.Add(-time.Minute), true),
+		manifest("sha256:a", now.Add(-72*time.Hour), true, "keep"),
+		manifest("sha256:c", now.Add(-72*time.Hour), true),
+		manifest("sha256:b", now.Add(-72*time.Hour), false),
+	}
+
+	assert.Equal(t, []string{"sha256:c", "sha256:d"}, RetentionDryRun(manifests, 0, now),
+		"with no retention every untagged manifest that is not locked goes")
+	assert.Equal(t, []string{"sha256:c"}, RetentionDryRun(manifests, 2, now))
+	assert.Equal(t, []string{}, RetentionDryRun(manifests, 7, now))
+}
+
+func TestPushReference(t *testing.T) {
+	t.Parallel()
+
+	ref, err := pushReferenceE("acrtest.azurecr.io/retention", sampleDigest)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "acrtest.azurecr.io/retention@"+sampleDigest, ref.String())
+	}
+	ref, err = pushReferenceE("acrtest.azurecr.io/retention:keep", sampleDigest)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "acrtest.azurecr.io/retention:keep", ref.String())
+	}
+	_, err = pushReferenceE("acrtest.azurecr.io/Retention:keep", sampleDigest)
+	assert.Error(t, err)
+}
@@ -0,0 +1,24 @@
+package helpers
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+)
+
+var (
+	runIDOnce sync.Once
+	runID     string
+)
+
+// RunID identifies the current test run. RESUME_RUN_ID continues an earlier
+// run (see Checkpoint); otherwise it is read from TEST_RUN_ID (e.g. a CI
+// build ID) so several processes can share it, and generated as a last resort
+func RunID() string {
+	runIDOnce.Do(func() {
+		id := getEnvOrDefault("RESUME_RUN_ID", getEnvOrDefault("TEST_RUN_ID", random.UniqueId()))
+		runID = strings.ToLower(id)
+	})
+	return runID
+}
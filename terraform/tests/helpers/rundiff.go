@@ -0,0 +1,349 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// costProfileReport is the report AssertCostProfile records each module's
+// actual cost profile in
+const costProfileReport = "cost_profiles"
+
+// RunSummary is what is kept of a test run to compare it with another: the
+// outcome of every test and the cost profiles recorded during the run
+type RunSummary struct {
+	RunID        string                 `json:"run_id"`
+	Tests        map[string]TestOutcome `json:"tests"`
+	CostProfiles map[string]CostProfile `json:"cost_profiles,omitempty"`
+}
+
+// TestOutcome is the result of one test or subtest: pass, fail or skip, or
+// xfail for a failure marked with ExpectedFailure
+type TestOutcome struct {
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Issue tracks the failure of an xfail test
+	Issue string `json:"issue,omitempty"`
+	// SkipCategory is why a test skipped with SkipWithReason did not run
+	SkipCategory SkipCategory `json:"skip_category,omitempty"`
+}
+
+// goTestEvent is one line of `go test -json` output
+type goTestEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"`
+}
+
+// ParseGoTestJSONE reads `go test -json` output and returns the outcome of
+// every test and subtest that finished. Lines that are not JSON events, such
+// as build errors, are skipped
+func ParseGoTestJSONE(r io.Reader) (map[string]TestOutcome, error) {
+	outcomes := map[string]TestOutcome{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var event goTestEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			continue
+		}
+		if event.Test == "" {
+			continue
+		}
+		switch event.Action {
+		case "pass", "fail", "skip":
+			outcomes[event.Test] = TestOutcome{Status: event.Action, DurationSeconds: event.Elapsed}
+		}
+	}
+	return outcomes, scanner.Err()
+}
+
+// BuildRunSummaryE summarizes a run from its `go test -json` output and its
+// report folder (see ReportDir); a folder without cost profiles, expected
+// failures or skips is fine. Failures marked with ExpectedFailure become
+// "xfail", and skips recorded by SkipWithReason get their category
+func BuildRunSummaryE(goTestJSON io.Reader, reportDir string) (*RunSummary, error) {
+	tests, err := ParseGoTestJSONE(goTestJSON)
+	if err != nil {
+		return nil, fmt.Errorf("reading go test output: %w", err)
+	}
+	summary := &RunSummary{RunID: filepath.Base(reportDir), Tests: tests}
+
+	if err := readReportFileE(reportDir, costProfileReport, &summary.CostProfiles); err != nil {
+		return nil, err
+	}
+	expected := map[string]expectedFailure{}
+	if err := readReportFileE(reportDir, expectedFailureReport, &expected); err != nil {
+		return nil, err
+	}
+	applyExpectedFailures(summary.Tests, expected)
+	skips := map[string]skippedTest{}
+	if err := readReportFileE(reportDir, skipReport, &skips); err != nil {
+		return nil, err
+	}
+	applySkipCategories(summary.Tests, skips)
+	return summary, nil
+}
+
+// readReportFileE decodes the named report of reportDir into value, leaving
+// value alone when the run did not write the report
+func readReportFileE(reportDir, name string, value interface{}) error {
+	path := filepath.Join(reportDir, name+".json")
+	content, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	if err := json.Unmarshal(content, value); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadRunSummaryE reads a summary written by `ttk report summary`
+func ReadRunSummaryE(path string) (*RunSummary, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var summary RunSummary
+	if err := json.Unmarshal(content, &summary); err != nil {
+		return nil, fmt.Errorf("decoding run summary %s: %w", path, err)
+	}
+	return &summary, nil
+}
+
+// RunDiffOptions tune what counts as a duration regression
+type RunDiffOptions struct {
+	// DurationThreshold is the relative increase that counts, e.g. 0.25
+	DurationThreshold float64
+	// MinDuration ignores tests faster than this in both runs, whose
+	// timings are mostly noise
+	MinDuration time.Duration
+}
+
+// DefaultRunDiffOptions flag tests that got 25% slower and take at least 30s
+var DefaultRunDiffOptions = RunDiffOptions{DurationThreshold: 0.25, MinDuration: 30 * time.Second}
+
+// DurationRegression is a test that got slower than the threshold allows
+type DurationRegression struct {
+	Test          string  `json:"test"`
+	BeforeSeconds float64 `json:"before_seconds"`
+	AfterSeconds  float64 `json:"after_seconds"`
+	// Increase is relative, 0.5 for 50% slower
+	Increase float64 `json:"increase"`
+}
+
+// CostDelta is a change in the count of a billable resource in a cost profile
+type CostDelta struct {
+	Profile  string `json:"profile"`
+	Resource string `json:"resource"`
+	Before   int    `json:"before"`
+	After    int    `json:"after"`
+}
+
+// ExpectedFailureOutcome is a test that failed as expected and the issue
+// tracking its failure
+type ExpectedFailureOutcome struct {
+	Test  string `json:"test"`
+	Issue string `json:"issue"`
+}
+
+// RunDiff is what changed between two runs, sorted for stable output
+type RunDiff struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+	// NewlyFailing failed in After but not in Before, including new tests
+	NewlyFailing []string `json:"newly_failing"`
+	// Fixed failed in Before, expectedly or not, and passed in After
+	Fixed []string `json:"fixed"`
+	// ExpectedFailures failed in After as marked with ExpectedFailure
+	ExpectedFailures    []ExpectedFailureOutcome `json:"expected_failures"`
+	DurationRegressions []DurationRegression     `json:"duration_regressions"`
+	CostDeltas          []CostDelta              `json:"cost_deltas"`
+	// NewResourceTypes are resource types in After's cost profiles only
+	NewResourceTypes []string `json:"new_resource_types"`
+}
+
+// DiffRuns compares after with before
+func DiffRuns(before, after *RunSummary, options RunDiffOptions) *RunDiff {
+	diff := &RunDiff{
+		Before:              before.RunID,
+		After:               after.RunID,
+		NewlyFailing:        []string{},
+		Fixed:               []string{},
+		ExpectedFailures:    []ExpectedFailureOutcome{},
+		DurationRegressions: []DurationRegression{},
+		CostDeltas:          []CostDelta{},
+		NewResourceTypes:    []string{},
+	}
+
+	for test, outcome := range after.Tests {
+		previous, existed := before.Tests[test]
+		switch {
+		case outcome.Status == "fail" && (!existed || previous.Status != "fail"):
+			diff.NewlyFailing = append(diff.NewlyFailing, test)
+		case outcome.Status == "pass" && existed && (previous.Status == "fail" || previous.Status == StatusExpectedFailure):
+			diff.Fixed = append(diff.Fixed, test)
+		case outcome.Status == StatusExpectedFailure:
+			diff.ExpectedFailures = append(diff.ExpectedFailures, ExpectedFailureOutcome{Test: test, Issue: outcome.Issue})
+		}
+
+		// Only passing runs of a test have comparable durations
+		if !existed || outcome.Status != "pass" || previous.Status != "pass" || previous.DurationSeconds <= 0 {
+			continue
+		}
+		if outcome.DurationSeconds < options.MinDuration.Seconds() && previous.DurationSeconds < options.MinDuration.Seconds() {
+			continue
+		}
+		if increase := outcome.DurationSeconds/previous.DurationSeconds - 1; increase > options.DurationThreshold {
+			diff.DurationRegressions = append(diff.DurationRegressions, DurationRegression{
+				Test:          test,
+				BeforeSeconds: previous.DurationSeconds,
+				AfterSeconds:  outcome.DurationSeconds,
+				Increase:      increase,
+			})
+		}
+	}
+	sort.Strings(diff.NewlyFailing)
+	sort.Strings(diff.Fixed)
+	sort.Slice(diff.ExpectedFailures, func(i, j int) bool {
+		return diff.ExpectedFailures[i].Test < diff.ExpectedFailures[j].Test
+	})
+	sort.Slice(diff.DurationRegressions, func(i, j int) bool {
+		return diff.DurationRegressions[i].Test < diff.DurationRegressions[j].Test
+	})
+
+	// Profiles only recorded in one run (tests that did not get to apply)
+	// are not changes in cost
+	beforeTypes := map[string]bool{}
+	for _, profile := range before.CostProfiles {
+		for resource := range profile {
+			beforeTypes[resourceType(resource)] = true
+		}
+	}
+	newTypes := map[string]bool{}
+	for name, profile := range after.CostProfiles {
+		for resource := range profile {
+			if !beforeTypes[resourceType(resource)] {
+				newTypes[resourceType(resource)] = true
+			}
+		}
+		previous, existed := before.CostProfiles[name]
+		if !existed {
+			continue
+		}
+		resources := map[string]bool{}
+		for resource := range profile {
+			resources[resource] = true
+		}
+		for resource := range previous {
+			resources[resource] = true
+		}
+		for resource := range resources {
+			if profile[resource] != previous[resource] {
+				diff.CostDeltas = append(diff.CostDeltas, CostDelta{
+					Profile: name, Resource: resource, Before: previous[resource], After: profile[resource],
+				})
+			}
+		}
+	}
+	sort.Slice(diff.CostDeltas, func(i, j int) bool {
+		if diff.CostDeltas[i].Profile != diff.CostDeltas[j].Profile {
+			return diff.CostDeltas[i].Profile < diff.CostDeltas[j].Profile
+		}
+		return diff.CostDeltas[i].Resource < diff.CostDeltas[j].Resource
+	})
+	for resource := range newTypes {
+		diff.NewResourceTypes = append(diff.NewResourceTypes, resource)
+	}
+	sort.Strings(diff.NewResourceTypes)
+	return diff
+}
+
+// resourceType is the resource type of a cost profile entry, e.g.
+// azurerm_key_vault for "azurerm_key_vault sku_name=standard"
+func resourceType(entry string) string {
+	resource, _, _ := strings.Cut(entry, " ")
+	return resource
+}
+
+// Regressed reports whether After is worse than Before: new failures,
+// slower tests, more billable resources or new resource types
+func (d *RunDiff) Regressed() bool {
+	if len(d.NewlyFailing) > 0 || len(d.DurationRegressions) > 0 || len(d.NewResourceTypes) > 0 {
+		return true
+	}
+	for _, delta := range d.CostDeltas {
+		if delta.After > delta.Before {
+			return true
+		}
+	}
+	return false
+}
+
+// Markdown renders the diff as a pull request comment
+func (d *RunDiff) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Test run %s compared with %s\n\n", d.After, d.Before)
+	if d.Regressed() {
+		b.WriteString("**Regressions found.**\n")
+	} else {
+		b.WriteString("No regressions.\n")
+	}
+
+	if len(d.NewlyFailing) > 0 {
+		b.WriteString("\n### Newly failing tests\n\n")
+		for _, test := range d.NewlyFailing {
+			fmt.Fprintf(&b, "- `%s`\n", test)
+		}
+	}
+	if len(d.DurationRegressions) > 0 {
+		b.WriteString("\n### Slower tests\n\n| Test | Before | After | Change |\n| --- | ---: | ---: | ---: |\n")
+		for _, regression := range d.DurationRegressions {
+			fmt.Fprintf(&b, "| `%s` | %.0fs | %.0fs | +%.0f%% |\n",
+				regression.Test, regression.BeforeSeconds, regression.AfterSeconds, regression.Increase*100)
+		}
+	}
+	if len(d.CostDeltas) > 0 {
+		b.WriteString("\n### Billable resource changes\n\n| Profile | Resource | Before | After |\n| --- | --- | ---: | ---: |\n")
+		for _, delta := range d.CostDeltas {
+			fmt.Fprintf(&b, "| %s | `%s` | %d | %d |\n", delta.Profile, delta.Resource, delta.Before, delta.After)
+		}
+	}
+	if len(d.NewResourceTypes) > 0 {
+		b.WriteString("\n### New resource types\n\n")
+		for _, resource := range d.NewResourceTypes {
+			fmt.Fprintf(&b, "- `%s`\n", resource)
+		}
+	}
+	if len(d.ExpectedFailures) > 0 {
+		b.WriteString("\n### Expected failures\n\n")
+		for _, failure := range d.ExpectedFailures {
+			fmt.Fprintf(&b, "- `%s`, tracked at %s\n", failure.Test, failure.Issue)
+		}
+	}
+	if len(d.Fixed) > 0 {
+		b.WriteString("\n### Fixed tests\n\n")
+		for _, test := range d.Fixed {
+			fmt.Fprintf(&b, "- `%s`\n", test)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,193 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleGoTestJSON = `{"Action":"run","Package":"example/tests","Test":"TestVault"}
+{"Action":"output","Package":"example/tests","Test":"TestVault","Output":"=== RUN   TestVault\n"}
+{"Action":"pass","Package":"example/tests","Test":"TestVault/secrets","Elapsed":12.5}
+{"Action":"pass","Package":"example/tests","Test":"TestVault","Elapsed":80.1}
+# example/tests [build output]
+{"Action":"skip","Package":"example/tests","Test":"TestSlow","Elapsed":0}
+{"Action":"fail","Package":"example/tests","Test":"TestApp","Elapsed":300}
+{"Action":"fail","Package":"example/tests","Elapsed":400}
+`
+
+func TestParseGoTestJSONE(t *testing.T) {
+	t.Parallel()
+
+	outcomes, err := ParseGoTestJSONE(strings.NewReader(sampleGoTestJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]TestOutcome{
+		"TestVault/secrets": {Status: "pass", DurationSeconds: 12.5},
+		"TestVault":         {Status: "pass", DurationSeconds: 80.1},
+		"TestSlow":          {Status: "skip"},
+		"TestApp":           {Status: "fail", DurationSeconds: 300},
+	}, outcomes)
+}
+
+func TestBuildRunSummaryE(t *testing.T) {
+	t.Parallel()
+
+	reportDir := filepath.Join(t.TempDir(), "run-42")
+	summary, err := BuildRunSummaryE(strings.NewReader(sampleGoTestJSON), reportDir)
+	if err != nil {
+		t.Fatalf("A run without reports should be summarized: %v", err)
+	}
+	assert.Equal(t, "run-42", summary.RunID)
+	assert.Empty(t, summary.CostProfiles)
+
+	if err := os.MkdirAll(reportDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	profiles := `{"key-vault": {"azurerm_key_vault sku_name=standard": 1}}`
+	if err := os.WriteFile(filepath.Join(reportDir, "cost_profiles.json"), []byte(profiles), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	summary, err = BuildRunSummaryE(strings.NewReader(sampleGoTestJSON), reportDir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, CostProfile{"azurerm_key_vault sku_name=standard": 1}, summary.CostProfiles["key-vault"])
+	}
+}
+
+func TestDiffRuns(t *testing.T) {
+	t.Parallel()
+
+	before := &RunSummary{
+		RunID: "main",
+		Tests: map[string]TestOutcome{
+			"TestStable":  {Status: "pass", DurationSeconds: 100},
+			"TestSlower":  {Status: "pass", DurationSeconds: 100},
+			"TestFast":    {Status: "pass", DurationSeconds: 2},
+			"TestBroken":  {Status: "pass", DurationSeconds: 60},
+			"TestFixed":   {Status: "fail", DurationSeconds: 60},
+			"TestFlaking": {Status: "fail", DurationSeconds: 60},
+		},
+		CostProfiles: map[string]CostProfile{
+			"app": {"azurerm_container_app_environment": 1, "azurerm_log_analytics_workspace sku=PerGB2018": 1},
+		},
+	}
+	after := &RunSummary{
+		RunID: "pr-7",
+		Tests: map[string]TestOutcome{
+			"TestStable":  {Status: "pass", DurationSeconds: 110},
+			"TestSlower":  {Status: "pass", DurationSeconds: 150},
+			"TestFast":    {Status: "pass", DurationSeconds: 6},
+			"TestBroken":  {Status: "fail", DurationSeconds: 20},
+			"TestFixed":   {Status: "pass", DurationSeconds: 60},
+			"TestFlaking": {Status: "fail", DurationSeconds: 60},
+			"TestNew":     {Status: "fail", DurationSeconds: 1},
+		},
+		CostProfiles: map[string]CostProfile{
+			"app":      {"azurerm_container_app_environment": 2, "azurerm_firewall sku_tier=Standard": 1},
+			"registry": {"azurerm_container_registry sku=Premium": 1},
+		},
+	}
+
+	diff := DiffRuns(before, after, DefaultRunDiffOptions)
+	assert.Equal(t, []string{"TestBroken", "TestNew"}, diff.NewlyFailing, "failures already in before are not new")
+	assert.Equal(t, []string{"TestFixed"}, diff.Fixed)
+	assert.Equal(t, []DurationRegression{
+		{Test: "TestSlower", BeforeSeconds: 100, AfterSeconds: 150, Increase: 0.5},
+	}, diff.DurationRegressions, "TestFast is below the minimum duration")
+	assert.Equal(t, []CostDelta{
+		{Profile: "app", Resource: "azurerm_container_app_environment", Before: 1, After: 2},
+		{Profile: "app", Resource: "azurerm_firewall sku_tier=Standard", Before: 0, After: 1},
+		{Profile: "app", Resource: "azurerm_log_analytics_workspace sku=PerGB2018", Before: 1, After: 0},
+	}, diff.CostDeltas, "profiles missing from before are not deltas")
+	assert.Equal(t, []string{"azurerm_container_registry", "azurerm_firewall"}, diff.NewResourceTypes)
+	assert.True(t, diff.Regressed())
+
+	markdown := diff.Markdown()
+	assert.Contains(t, markdown, "**Regressions found.**")
+	assert.Contains(t, markdown, "- `TestNew`")
+	assert.Contains(t, markdown, "| `TestSlower` | 100s | 150s | +50% |")
+	assert.Contains(t, markdown, "| app | `azurerm_container_app_environment` | 1 | 2 |")
+	assert.Contains(t, markdown, "### Fixed tests")
+
+	strict := DiffRuns(before, after, RunDiffOptions{DurationThreshold: 0.05, MinDuration: time.Second})
+	assert.Len(t, strict.DurationRegressions, 3)
+}
+
+func TestDiffRunsWithoutRegressions(t *testing.T) {
+	t.Parallel()
+
+	run := &RunSummary{
+		RunID:        "main",
+		Tests:        map[string]TestOutcome{"TestVault": {Status: "pass", DurationSeconds: 90}},
+		CostProfiles: map[string]CostProfile{"vault": {"azurerm_key_vault sku_name=standard": 1}},
+	}
+	diff := DiffRuns(run, run, DefaultRunDiffOptions)
+	assert.False(t, diff.Regressed())
+	assert.Equal(t, "## Test run main compared with main\n\nNo regressions.\n", diff.Markdown())
+
+	removed := DiffRuns(run, &RunSummary{RunID: "pr", Tests: run.Tests, CostProfiles: map[string]CostProfile{"vault": {}}}, DefaultRunDiffOptions)
+	assert.Len(t, removed.CostDeltas, 1)
+	assert.False(t, removed.Regressed(), "fewer billable resources is not a regression")
+}
+
+func TestBuildRunSummaryExpectedFailures(t *testing.T) {
+	t.Parallel()
+
+	reportDir := filepath.Join(t.TempDir(), "run-43")
+	if err := os.MkdirAll(reportDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"TestApp": {"issue": "https://github.com/pollinate/risk-scoring-api/issues/12", "outcome": "failed"}}`
+	if err := os.WriteFile(filepath.Join(reportDir, "expected_failures.json"), []byte(expected), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := BuildRunSummaryE(strings.NewReader(sampleGoTestJSON), reportDir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, TestOutcome{Status: StatusExpectedFailure, DurationSeconds: 300, Issue: "https://github.com/pollinate/risk-scoring-api/issues/12"},
+			summary.Tests["TestApp"])
+		assert.Equal(t, "pass", summary.Tests["TestVault"].Status)
+	}
+}
+
+func TestDiffRunsExpectedFailures(t *testing.T) {
+	t.Parallel()
+
+	const issue = "https://github.com/pollinate/risk-scoring-api/issues/12"
+	before := &RunSummary{
+		RunID: "main",
+		Tests: map[string]TestOutcome{
+			"TestKnown":    {Status: StatusExpectedFailure, Issue: issue},
+			"TestStale":    {Status: StatusExpectedFailure, Issue: issue},
+			"TestUnmarked": {Status: StatusExpectedFailure, Issue: issue},
+			"TestTracked":  {Status: "fail"},
+		},
+	}
+	after := &RunSummary{
+		RunID: "pr-8",
+		Tests: map[string]TestOutcome{
+			"TestKnown":    {Status: StatusExpectedFailure, Issue: issue},
+			"TestStale":    {Status: "fail"},
+			"TestUnmarked": {Status: "pass"},
+			"TestTracked":  {Status: StatusExpectedFailure, Issue: issue},
+		},
+	}
+
+	diff := DiffRuns(before, after, DefaultRunDiffOptions)
+	assert.Equal(t, []string{"TestStale"}, diff.NewlyFailing, "a stale expected failure fails the run")
+	assert.Equal(t, []string{"TestUnmarked"}, diff.Fixed)
+	assert.Equal(t, []ExpectedFailureOutcome{
+		{Test: "TestKnown", Issue: issue},
+		{Test: "TestTracked", Issue: issue},
+	}, diff.ExpectedFailures)
+	assert.True(t, diff.Regressed())
+	assert.Contains(t, diff.Markdown(), "### Expected failures\n\n- `TestKnown`, tracked at "+issue+"\n")
+
+	delete(after.Tests, "TestStale")
+	assert.False(t, DiffRuns(before, after, DefaultRunDiffOptions).Regressed(), "expected failures are not regressions")
+}
@@ -0,0 +1,255 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// samplingBatchSize is how many requests SendSyntheticRequestsE posts at once
+	samplingBatchSize = 250
+
+	// samplingSigmas is how far from the expected count, in standard
+	// deviations, an ingested count may fall. Five keeps false failures
+	// below one in a million runs
+	samplingSigmas = 5
+
+	// defaultIngestionEndpoint is used by connection strings without one
+	defaultIngestionEndpoint = "https://dc.services.visualstudio.com"
+)
+
+// samplingRolePattern keeps the role SampledRequestsE puts into KQL safe
+var samplingRolePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// SampledRequests is what reached the workspace of the synthetic requests
+// sent under one role
+type SampledRequests struct {
+	// Ingested is the number of request rows stored
+	Ingested int `json:"ingested"`
+	// ItemCounts counts the rows by their ItemCount, the number of requests
+	// each row stands for after sampling
+	ItemCounts map[int]int `json:"item_counts"`
+}
+
+// connectionStringSettings returns the settings of an Application Insights
+// connection string, keyed by lowercased name
+func connectionStringSettings(connectionString string) map[string]string {
+	settings := map[string]string{}
+	for _, part := range strings.Split(connectionString, ";") {
+		if key, value, found := strings.Cut(part, "="); found {
+			settings[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return settings
+}
+
+// syntheticRequest is a request telemetry item in the ingestion API schema
+type syntheticRequest struct {
+	Name string            `json:"name"`
+	Time string            `json:"time"`
+	IKey string            `json:"iKey"`
+	Tags map[string]string `json:"tags"`
+	Data struct {
+		BaseType string                 `json:"baseType"`
+		BaseData map[string]interface{} `json:"baseData"`
+	} `json:"data"`
+}
+
+// syntheticRequests returns count request items for role, each in an
+// operation of its own so ingestion sampling decides on each independently
+func syntheticRequests(instrumentationKey, role string, count int, now time.Time) []syntheticRequest {
+	items := make([]syntheticRequest, count)
+	for i := range items {
+		items[i] = syntheticRequest{
+			Name: "Microsoft.ApplicationInsights.Request",
+			Time: now.UTC().Format(time.RFC3339Nano),
+			IKey: instrumentationKey,
+			Tags: map[string]string{
+				"ai.operation.id": randomHex(16),
+				"ai.cloud.role":   role,
+			},
+		}
+		items[i].Data.BaseType = "RequestData"
+		items[i].Data.BaseData = map[string]interface{}{
+			"ver":          2,
+			"id":           randomHex(8),
+			"name":         "GET /sampling",
+			"duration":     "0.00:00:00.0100000",
+			"responseCode": "200",
+			"success":      true,
+		}
+	}
+	return items
+}
+
+// trackEndpointE returns the track endpoint and instrumentation key of an
+// Application Insights connection string
+func trackEndpointE(connectionString string) (endpoint, instrumentationKey string, err error) {
+	settings := connectionStringSettings(connectionString)
+	instrumentationKey = settings["instrumentationkey"]
+	if instrumentationKey == "" {
+		return "", "", fmt.Errorf("connection string has no InstrumentationKey")
+	}
+	endpoint = settings["ingestionendpoint"]
+	if endpoint == "" {
+		endpoint = defaultIngestionEndpoint
+	}
+	return strings.TrimRight(endpoint, "/") + "/v2/track", instrumentationKey, nil
+}
+
+// postTelemetryE posts a batch of telemetry items to a track endpoint
+func postTelemetryE(client *http.Client, endpoint string, batch interface{}) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	response, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	// The endpoint answers 200 only when it accepted every item
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("ingestion endpoint returned %d", response.StatusCode)
+	}
+	return nil
+}
+
+// SendSyntheticRequestsE sends count request items under the cloud role
+// role to the Application Insights resource of connectionString. They carry
+// no sample rate, so only the resource's ingestion sampling applies
+func SendSyntheticRequestsE(connectionString, role string, count int) error {
+	endpoint, instrumentationKey, err := trackEndpointE(connectionString)
+	if err != nil {
+		return err
+	}
+
+	items := syntheticRequests(instrumentationKey, role, count, time.Now())
+	client := &http.Client{Timeout: 30 * time.Second}
+	for start := 0; start < len(items); start += samplingBatchSize {
+		end := start + samplingBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		if err := postTelemetryE(client, endpoint, items[start:end]); err != nil {
+			return fmt.Errorf("items %d-%d: %w", start, end-1, err)
+		}
+	}
+	return nil
+}
+
+// sampledRequestsFromRows converts the rows of the SampledRequestsE query
+func sampledRequestsFromRows(rows []map[string]interface{}) (*SampledRequests, error) {
+	sampled := &SampledRequests{ItemCounts: map[int]int{}}
+	for _, row := range rows {
+		itemCount, err := strconv.Atoi(fmt.Sprint(row["ItemCount"]))
+		if err != nil {
+			return nil, fmt.Errorf("reading ItemCount: %w", err)
+		}
+		rowCount, err := strconv.Atoi(fmt.Sprint(row["Rows"]))
+		if err != nil {
+			return nil, fmt.Errorf("reading row count: %w", err)
+		}
+		sampled.ItemCounts[itemCount] += rowCount
+		sampled.Ingested += rowCount
+	}
+	return sampled, nil
+}
+
+// SampledRequestsE counts the requests of role stored in the Log Analytics
+// workspace with the given workspace (customer) ID
+func SampledRequestsE(t *testing.T, workspaceID, role string) (*SampledRequests, error) {
+	if !samplingRolePattern.MatchString(role) {
+		return nil, fmt.Errorf("role %q is not lowercase letters, digits and dashes", role)
+	}
+	query := fmt.Sprintf("AppRequests | where AppRoleName == '%s' | summarize Rows = count() by ItemCount", role)
+	rows, err := QueryLogAnalyticsE(t, workspaceID, query)
+	if err != nil {
+		return nil, err
+	}
+	return sampledRequestsFromRows(rows)
+}
+
+// WaitForSampledRequestsE polls SampledRequestsE until requests of role have
+// arrived and their count held still for settle, or until timeout.
+// Ingestion delivers in batches, so the count rises in steps
+func WaitForSampledRequestsE(t *testing.T, workspaceID, role string, settle, timeout time.Duration) (*SampledRequests, error) {
+	return waitForSampledRequestsE(t, SystemClock, func() (*SampledRequests, error) {
+		return SampledRequestsE(t, workspaceID, role)
+	}, role, settle, timeout)
+}
+
+// waitForSampledRequestsE is WaitForSampledRequestsE reading the counts with
+// count and timing the polls on clock
+func waitForSampledRequestsE(t *testing.T, clock Clock, count func() (*SampledRequests, error), role string, settle, timeout time.Duration) (*SampledRequests, error) {
+	const pollInterval = 30 * time.Second
+
+	deadline := clock.Now().Add(timeout)
+	var last *SampledRequests
+	changed := clock.Now()
+	for {
+		sampled, err := count()
+		if err != nil {
+			return nil, err
+		}
+		if last == nil || sampled.Ingested != last.Ingested {
+			t.Logf("%d requests of %s ingested so far", sampled.Ingested, role)
+			last, changed = sampled, clock.Now()
+		}
+		if last.Ingested > 0 && clock.Now().Sub(changed) >= settle {
+			return last, nil
+		}
+		if clock.Now().After(deadline) {
+			return last, fmt.Errorf("requests of %s still arriving after %s (%d so far)", role, timeout, last.Ingested)
+		}
+		clock.Sleep(pollInterval)
+	}
+}
+
+// SamplingBounds returns the range of ingested counts consistent with
+// sampling sent requests at percentage, within samplingSigmas standard
+// deviations of the binomial distribution
+func SamplingBounds(sent int, percentage float64) (int, int) {
+	p := percentage / 100
+	mean := float64(sent) * p
+	deviation := samplingSigmas * math.Sqrt(float64(sent)*p*(1-p))
+	low := int(math.Max(0, math.Ceil(mean-deviation)))
+	high := int(math.Min(float64(sent), math.Floor(mean+deviation)))
+	return low, high
+}
+
+// SamplingViolations returns how sampled, the result of sending sent
+// requests, disagrees with ingestion sampling at percentage: an ingested
+// count outside SamplingBounds, or rows not standing for 100/percentage
+// requests each. Sampling that is set but ignored shows as both
+func SamplingViolations(sent int, percentage float64, sampled *SampledRequests) []string {
+	var violations []string
+	low, high := SamplingBounds(sent, percentage)
+	if sampled.Ingested < low || sampled.Ingested > high {
+		violations = append(violations, fmt.Sprintf("ingested %d of %d requests, expected %d-%d with %g%% sampling",
+			sampled.Ingested, sent, low, high, percentage))
+	}
+
+	expected := int(math.Round(100 / percentage))
+	var itemCounts []int
+	for itemCount := range sampled.ItemCounts {
+		itemCounts = append(itemCounts, itemCount)
+	}
+	sort.Ints(itemCounts)
+	for _, itemCount := range itemCounts {
+		if itemCount != expected {
+			violations = append(violations, fmt.Sprintf("%d rows stand for %d requests each, expected %d with %g%% sampling",
+				sampled.ItemCounts[itemCount], itemCount, expected, percentage))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendSyntheticRequests(t *testing.T) {
+	t.Parallel()
+
+	var batches []int
+	operations := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/track", r.URL.Path)
+		var items []syntheticRequest
+		if assert.NoError(t, json.NewDecoder(r.Body).Decode(&items)) {
+			batches = append(batches, len(items))
+			for _, item := range items {
+				assert.Equal(t, "key", item.IKey)
+				assert.Equal(t, "smp-run", item.Tags["ai.cloud.role"])
+				assert.Equal(t, "RequestData", item.Data.BaseType)
+				operations[item.Tags["ai.operation.id"]] = true
+			}
+		}
+	}))
+	defer server.Close()
+
+	connectionString := "InstrumentationKey=key;IngestionEndpoint=" + server.URL + "/;LiveEndpoint=https://live.invalid/"
+	assert.NoError(t, SendSyntheticRequestsE(connectionString, "smp-run", 600))
+	assert.Equal(t, []int{250, 250, 100}, batches)
+	assert.Len(t, operations, 600, "every request should be an operation of its own")
+
+	assert.EqualError(t, SendSyntheticRequestsE("IngestionEndpoint="+server.URL, "smp-run", 1),
+		"connection string has no InstrumentationKey")
+}
+
+func TestSyntheticRequestTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC)
+	items := syntheticRequests("key", "smp-run", 2, now)
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, "2026-03-01T08:30:00Z", items[0].Time)
+		assert.NotEqual(t, items[0].Tags["ai.operation.id"], items[1].Tags["ai.operation.id"])
+	}
+}
+
+func TestSampledRequestsFromRows(t *testing.T) {
+	t.Parallel()
+
+	sampled, err := sampledRequestsFromRows([]map[string]interface{}{
+		{"ItemCount": "10", "Rows": "190"},
+		{"ItemCount": "1", "Rows": "3"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, &SampledRequests{Ingested: 193, ItemCounts: map[int]int{10: 190, 1: 3}}, sampled)
+	}
+
+	_, err = sampledRequestsFromRows([]map[string]interface{}{{"Rows": "1"}})
+	assert.Error(t, err)
+}
+
+func TestSamplingViolations(t *testing.T) {
+	t.Parallel()
+
+	low, high := SamplingBounds(2000, 10)
+	assert.Equal(t, 133, low)
+	assert.Equal(t, 267, high)
+	low, high = SamplingBounds(10, 100)
+	assert.Equal(t, 10, low)
+	assert.Equal(t, 10, high)
+
+	assert.Empty(t, SamplingViolations(2000, 10, &SampledRequests{Ingested: 207, ItemCounts: map[int]int{10: 207}}))
+	assert.Equal(t, []string{
+		"ingested 2000 of 2000 requests, expected 133-267 with 10% sampling",
+		"2000 rows stand for 1 requests each, expected 10 with 10% sampling",
+	}, SamplingViolations(2000, 10, &SampledRequests{Ingested: 2000, ItemCounts: map[int]int{1: 2000}}),
+		"sampling set but ignored")
+	assert.Equal(t, []string{
+		"ingested 400 of 2000 requests, expected 133-267 with 10% sampling",
+		"400 rows stand for 5 requests each, expected 10 with 10% sampling",
+	}, SamplingViolations(2000, 10, &SampledRequests{Ingested: 400, ItemCounts: map[int]int{5: 400}}),
+		"sampled at another rate")
+}
+
+func TestWaitForSampledRequests(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("settles", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(start)
+		// Batches arrive over the first two minutes, then the count holds
+		counts := []int{0, 0, 120, 190, 193}
+		polls := 0
+		sampled, err := waitForSampledRequestsE(t, clock, func() (*SampledRequests, error) {
+			ingested := counts[len(counts)-1]
+			if polls < len(counts) {
+				ingested = counts[polls]
+			}
+			polls++
+			return &SampledRequests{Ingested: ingested}, nil
+		}, "smp-run", 5*time.Minute, time.Hour)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 193, sampled.Ingested)
+		}
+		assert.Equal(t, 7*time.Minute, clock.Slept(), "193 arrives after two minutes, then holds for the five minute settle")
+	})
+
+	t.Run("times_out", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(start)
+		polls := 0
+		sampled, err := waitForSampledRequestsE(t, clock, func() (*SampledRequests, error) {
+			polls++
+			return &SampledRequests{Ingested: polls}, nil
+		}, "smp-run", 5*time.Minute, 10*time.Minute)
+		assert.EqualError(t, err, "requests of smp-run still arriving after 10m0s (22 so far)")
+		if assert.NotNil(t, sampled) {
+			assert.Equal(t, 22, sampled.Ingested)
+		}
+	})
+}
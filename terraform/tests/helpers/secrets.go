@@ -0,0 +1,183 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// secretRule is a credential pattern. When valueGroup is non-zero only that
+// submatch is the secret (e.g. the value of a password assignment)
+type secretRule struct {
+	name       string
+	pattern    *regexp.Regexp
+	valueGroup int
+}
+
+// secretRules are the credential patterns checked before anything is written or logged
+var secretRules = []secretRule{
+	{
+		name:    "private-key",
+		pattern: regexp.MustCompile(`-----BEGIN (?:RSA |EC |OPENSSH |ENCRYPTED )?PRIVATE KEY-----`),
+	},
+	{
+		name:    "storage-account-key",
+		pattern: regexp.MustCompile(`AccountKey=[A-Za-z0-9+/]{40,}={0,2}`),
+	},
+	{
+		name:    "sas-signature",
+		pattern: regexp.MustCompile(`[?&]sig=[A-Za-z0-9%+/=]{20,}`),
+	},
+	{
+		name:    "entra-client-secret",
+		pattern: regexp.MustCompile(`\b[A-Za-z0-9_~.-]{3}\dQ~[A-Za-z0-9_~.-]{31,34}\b`),
+	},
+	{
+		name:    "jwt",
+		pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`),
+	},
+	{
+		// Matches HCL, tfvars JSON and plan JSON: admin_password = "...", "client_secret": "..."
+		name:       "credential-assignment",
+		pattern:    regexp.MustCompile(`(?i)"?[a-z0-9_]*(?:password|passwd|client_secret|access_key|primary_key|secondary_key|shared_key)"?\s*[:=]\s*"([^"\s$]{8,})"`),
+		valueGroup: 1,
+	},
+}
+
+// SecretFinding is a credential-like match in scanned content
+type SecretFinding struct {
+	Rule string
+	Line int
+}
+
+func (f SecretFinding) String() string {
+	return fmt.Sprintf("line %d: %s", f.Line, f.Rule)
+}
+
+// ScanForSecrets returns every credential-like match in content. Findings
+// never include the matched value so they are safe to log
+func ScanForSecrets(content []byte) []SecretFinding {
+	var findings []SecretFinding
+	for lineNumber, line := range strings.Split(string(content), "\n") {
+		for _, rule := range secretRules {
+			if rule.pattern.MatchString(line) {
+				findings = append(findings, SecretFinding{Rule: rule.name, Line: lineNumber + 1})
+			}
+		}
+	}
+	return findings
+}
+
+// RedactSecrets replaces credential-like values in text with a placeholder
+func RedactSecrets(text string) string {
+	for _, rule := range secretRules {
+		placeholder := fmt.Sprintf("[REDACTED:%s]", rule.name)
+		if rule.valueGroup == 0 {
+			text = rule.pattern.ReplaceAllString(text, placeholder)
+			continue
+		}
+
+		group := rule.valueGroup
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			indexes := rule.pattern.FindStringSubmatchIndex(match)
+			return match[:indexes[2*group]] + placeholder + match[indexes[2*group+1]:]
+		})
+	}
+	return text
+}
+
+// ScanFileForSecretsE scans a single file for credential-like content
+func ScanFileForSecretsE(path string) ([]SecretFinding, error) {
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	return ScanForSecrets(content), nil
+}
+
+// scannedExtensions are the file types checked by AssertNoSecretsInDir
+var scannedExtensions = map[string]bool{
+	".tf":      true,
+	".tfvars":  true,
+	".json":    true,
+	".hcl":     true,
+	".yaml":    true,
+	".yml":     true,
+	".tfplan":  true,
+	".example": true,
+}
+
+// AssertNoSecretsInDir fails the test for every fixture file under dir that
+// contains credential-like content. .terraform directories are skipped
+func AssertNoSecretsInDir(t *testing.T, dir string) {
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".terraform" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !scannedExtensions[filepath.Ext(path)] {
+			return nil
+		}
+
+		findings, err := ScanFileForSecretsE(path)
+		if err != nil {
+			return err
+		}
+		for _, finding := range findings {
+			t.Errorf("%s %s", path, finding)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scanning %s for secrets: %v", dir, err)
+	}
+}
+
+// WriteTFVarsFile writes vars as a JSON tfvars file readable only by the
+// current user. It refuses to write credential-like values; pass those via
+// environment variables (TF_VAR_*) instead
+func WriteTFVarsFile(t *testing.T, path string, vars map[string]interface{}) {
+	content, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		t.Fatalf("Encoding tfvars for %s: %v", path, err)
+	}
+
+	if findings := ScanForSecrets(content); len(findings) > 0 {
+		t.Fatalf("Refusing to write %s: tfvars contain credentials %v", path, findings)
+	}
+
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("Writing %s: %v", path, err)
+	}
+}
+
+// redactingLogger logs through the terratest logger after redacting secrets
+type redactingLogger struct{}
+
+func (redactingLogger) Logf(t terratesting.TestingT, format string, args ...interface{}) {
+	message := RedactSecrets(fmt.Sprintf(format, args...))
+	if t != nil {
+		logToTestFile(t.Name(), message)
+	}
+	if !quietTestLogs() {
+		logger.Terratest.Logf(t, "%s", message)
+	}
+}
+
+// RedactingLogger is a terratest logger that strips credentials from terraform
+// commands and output before they reach the test log. Every line also goes
+// to the test's own log file (see TestLogPath), and only there with
+// TEST_LOGS_QUIET=true
+var RedactingLogger = logger.New(redactingLogger{})
@@ -0,0 +1,293 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// moduleInterfaceDir holds the interface of every module at its last
+// release, one file per module
+const moduleInterfaceDir = "testdata/module-interfaces"
+
+// changelogVersionPattern matches the release headings of a module's
+// CHANGELOG.md, e.g. "## [1.2.0] - 2026-10-18"
+var changelogVersionPattern = regexp.MustCompile(`^## \[([^\]]+)\]`)
+
+// typePunctuationSpacing matches the spacing around punctuation of a type
+// constraint, which does not change the type
+var typePunctuationSpacing = regexp.MustCompile(`\s*([(){}\[\],=])\s*`)
+
+// semverPattern matches MAJOR.MINOR.PATCH versions
+var semverPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)$`)
+
+// Semver is a MAJOR.MINOR.PATCH module version
+type Semver [3]int
+
+// ParseSemver parses a MAJOR.MINOR.PATCH version such as "1.2.0"
+func ParseSemver(version string) (Semver, error) {
+	match := semverPattern.FindStringSubmatch(version)
+	if match == nil {
+		return Semver{}, fmt.Errorf("%q is not a MAJOR.MINOR.PATCH version", version)
+	}
+	var parsed Semver
+	for i := range parsed {
+		parsed[i], _ = strconv.Atoi(match[i+1])
+	}
+	return parsed, nil
+}
+
+func (v Semver) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// Less reports whether v is an earlier version than other
+func (v Semver) Less(other Semver) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+	return false
+}
+
+// InterfaceVariable is an input variable as callers of a module see it
+type InterfaceVariable struct {
+	// Type is the type constraint as written, on one line without spacing
+	// around punctuation, e.g. object({name=string size=number}); "any"
+	// when there is none
+	Type string `json:"type"`
+	// Required is true when the variable has no default
+	Required bool `json:"required"`
+}
+
+// InterfaceOutput is an output as callers of a module see it
+type InterfaceOutput struct {
+	Sensitive bool `json:"sensitive"`
+}
+
+// ModuleInterface is the version and the variables and outputs of a module
+type ModuleInterface struct {
+	Version   string                       `json:"version"`
+	Variables map[string]InterfaceVariable `json:"variables"`
+	Outputs   map[string]InterfaceOutput   `json:"outputs"`
+}
+
+// ModuleVersionE returns the version of the module in dir: the first
+// release heading of its CHANGELOG.md, skipping [Unreleased]
+func ModuleVersionE(dir string) (string, error) {
+	path := filepath.Join(dir, "CHANGELOG.md")
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		match := changelogVersionPattern.FindStringSubmatch(scanner.Text())
+		if match == nil || strings.EqualFold(match[1], "Unreleased") {
+			continue
+		}
+		if _, err := ParseSemver(match[1]); err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		return match[1], nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s has no release heading such as \"## [1.0.0]\"", path)
+}
+
+// ModuleInterfaceE reads the version (see ModuleVersionE), variables and
+// outputs of the module in dir
+func ModuleInterfaceE(dir string) (ModuleInterface, error) {
+	version, err := ModuleVersionE(dir)
+	if err != nil {
+		return ModuleInterface{}, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return ModuleInterface{}, err
+	}
+
+	moduleInterface := ModuleInterface{
+		Version:   version,
+		Variables: map[string]InterfaceVariable{},
+		Outputs:   map[string]InterfaceOutput{},
+	}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return ModuleInterface{}, err
+		}
+		parsed, diags := parser.ParseHCL(content, file)
+		if diags.HasErrors() {
+			return ModuleInterface{}, diags
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			return ModuleInterface{}, fmt.Errorf("%s is not native HCL syntax", file)
+		}
+
+		for _, block := range body.Blocks {
+			if len(block.Labels) != 1 {
+				continue
+			}
+			switch block.Type {
+			case "variable":
+				variable := InterfaceVariable{Type: "any"}
+				if attribute, exists := block.Body.Attributes["type"]; exists {
+					source := strings.Join(strings.Fields(string(attribute.Expr.Range().SliceBytes(content))), " ")
+					variable.Type = typePunctuationSpacing.ReplaceAllString(source, "$1")
+				}
+				_, hasDefault := block.Body.Attributes["default"]
+				variable.Required = !hasDefault
+				moduleInterface.Variables[block.Labels[0]] = variable
+			case "output":
+				var output InterfaceOutput
+				if attribute, exists := block.Body.Attributes["sensitive"]; exists {
+					output.Sensitive = string(attribute.Expr.Range().SliceBytes(content)) == "true"
+				}
+				moduleInterface.Outputs[block.Labels[0]] = output
+			}
+		}
+	}
+	return moduleInterface, nil
+}
+
+// InterfaceChanges compares the interface of a module with its previous
+// release. Breaking changes break existing callers: removed or re-typed
+// variables, new required variables, removed outputs and outputs that became
+// sensitive. Additions are new optional variables and new outputs. Both are
+// sorted
+func InterfaceChanges(previous, current ModuleInterface) (breaking, additions []string) {
+	for name, variable := range current.Variables {
+		released, exists := previous.Variables[name]
+		switch {
+		case !exists && variable.Required:
+			breaking = append(breaking, fmt.Sprintf("variable %s: added as required", name))
+		case !exists:
+			additions = append(additions, fmt.Sprintf("variable %s: added", name))
+		case released.Type != variable.Type:
+			breaking = append(breaking, fmt.Sprintf("variable %s: type changed from %s to %s", name, released.Type, variable.Type))
+		case !released.Required && variable.Required:
+			breaking = append(breaking, fmt.Sprintf("variable %s: default removed", name))
+		}
+	}
+	for name := range previous.Variables {
+		if _, exists := current.Variables[name]; !exists {
+			breaking = append(breaking, fmt.Sprintf("variable %s: removed", name))
+		}
+	}
+	for name, output := range current.Outputs {
+		released, exists := previous.Outputs[name]
+		switch {
+		case !exists:
+			additions = append(additions, fmt.Sprintf("output %s: added", name))
+		case !released.Sensitive && output.Sensitive:
+			breaking = append(breaking, fmt.Sprintf("output %s: became sensitive", name))
+		}
+	}
+	for name := range previous.Outputs {
+		if _, exists := current.Outputs[name]; !exists {
+			breaking = append(breaking, fmt.Sprintf("output %s: removed", name))
+		}
+	}
+	sort.Strings(breaking)
+	sort.Strings(additions)
+	return breaking, additions
+}
+
+// SemverProblems returns what is wrong with the version of current given
+// its changes since previous: a version that went backwards, or breaking
+// changes without a major version bump
+func SemverProblems(previous, current ModuleInterface) ([]string, error) {
+	released, err := ParseSemver(previous.Version)
+	if err != nil {
+		return nil, fmt.Errorf("released version: %w", err)
+	}
+	version, err := ParseSemver(current.Version)
+	if err != nil {
+		return nil, err
+	}
+	if version.Less(released) {
+		return []string{fmt.Sprintf("version %s is older than the released %s", version, released)}, nil
+	}
+
+	breaking, _ := InterfaceChanges(previous, current)
+	if len(breaking) == 0 || version[0] > released[0] {
+		return nil, nil
+	}
+	problems := make([]string, 0, len(breaking))
+	for _, change := range breaking {
+		problems = append(problems, fmt.Sprintf("%s, a breaking change, but version %s keeps major version %d of %s",
+			change, version, released[0], released))
+	}
+	return problems, nil
+}
+
+// AssertModuleSemver compares the interface of the module in dir with its
+// last release in testdata/module-interfaces/<name>.json and fails when it
+// has breaking changes without a major version bump in its CHANGELOG.md.
+// Set UPDATE_MODULE_INTERFACES=true when releasing to record the current
+// interface as the release instead
+func AssertModuleSemver(t *testing.T, dir, name string) {
+	current, err := ModuleInterfaceE(dir)
+	if err != nil {
+		t.Fatalf("Reading interface of %s: %v", dir, err)
+	}
+
+	path := filepath.Join(moduleInterfaceDir, name+".json")
+	if os.Getenv("UPDATE_MODULE_INTERFACES") == "true" {
+		content, err := json.MarshalIndent(current, "", "  ")
+		if err != nil {
+			t.Fatalf("Encoding interface of %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, append(content, '\n'), 0o600); err != nil {
+			t.Fatalf("Writing %s: %v", path, err)
+		}
+		t.Logf("Recorded %s %s as released in %s", name, current.Version, path)
+		return
+	}
+
+	var previous ModuleInterface
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("No released interface at %s; run with UPDATE_MODULE_INTERFACES=true to record it", path)
+	}
+	if err != nil {
+		t.Fatalf("Reading %s: %v", path, err)
+	}
+	if err := json.Unmarshal(content, &previous); err != nil {
+		t.Fatalf("Decoding %s: %v", path, err)
+	}
+
+	problems, err := SemverProblems(previous, current)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("%s %s is not compatible with the release in %s:\n  %s\n"+
+			"Add a new major version to %s, or keep the interface compatible",
+			name, current.Version, path, strings.Join(problems, "\n  "), filepath.Join(dir, "CHANGELOG.md"))
+	}
+	if _, additions := InterfaceChanges(previous, current); len(additions) > 0 {
+		t.Logf("%s %s adds to release %s, which needs at least a minor version:\n  %s",
+			name, current.Version, previous.Version, strings.Join(additions, "\n  "))
+	}
+}
@@ -0,0 +1,164 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSemver(t *testing.T) {
+	t.Parallel()
+
+	version, err := ParseSemver("1.12.0")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Semver{1, 12, 0}, version)
+		assert.Equal(t, "1.12.0", version.String())
+	}
+	assert.True(t, Semver{1, 9, 3}.Less(Semver{1, 10, 0}))
+	assert.False(t, Semver{2, 0, 0}.Less(Semver{1, 10, 0}))
+	assert.False(t, Semver{1, 0, 0}.Less(Semver{1, 0, 0}))
+
+	for _, invalid := range []string{"1.0", "v1.0.0", "1.0.0-rc.1", "01.0.0"} {
+		_, err := ParseSemver(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestModuleInterface(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"CHANGELOG.md": "# Changelog\n\n## [Unreleased]\n\n## [1.3.0] - 2026-10-18\n\n## [1.2.0] - 2026-09-01\n",
+		"variables.tf": `
+variable "name" {
+  type = string
+}
+
+variable "tags" {
+  type    = map( string )
+  default = {}
+}
+
+variable "settings" {
+  default = null
+}
+`,
+		"outputs.tf": `
+output "id" {
+  value = "id"
+}
+
+output "password" {
+  value     = "secret"
+  sensitive = true
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moduleInterface, err := ModuleInterfaceE(dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, ModuleInterface{
+			Version: "1.3.0",
+			Variables: map[string]InterfaceVariable{
+				"name":     {Type: "string", Required: true},
+				"tags":     {Type: "map(string)"},
+				"settings": {Type: "any"},
+			},
+			Outputs: map[string]InterfaceOutput{
+				"id":       {},
+				"password": {Sensitive: true},
+			},
+		}, moduleInterface)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "CHANGELOG.md"), []byte("# Changelog\n\n## [Unreleased]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ModuleVersionE(dir)
+	assert.Error(t, err, "a changelog without a release has no version")
+}
+
+func TestSemverProblems(t *testing.T) {
+	t.Parallel()
+
+	released := ModuleInterface{
+		Version: "1.2.0",
+		Variables: map[string]InterfaceVariable{
+			"name":     {Type: "string", Required: true},
+			"sku":      {Type: "string"},
+			"tags":     {Type: "map(string)"},
+			"capacity": {Type: "number"},
+		},
+		Outputs: map[string]InterfaceOutput{"id": {}, "login_server": {}, "password": {Sensitive: true}},
+	}
+	additive := ModuleInterface{
+		Version: "1.2.0",
+		Variables: map[string]InterfaceVariable{
+			"name":     {Type: "string", Required: true},
+			"sku":      {Type: "string"},
+			"tags":     {Type: "map(string)"},
+			"capacity": {Type: "number"},
+			"zones":    {Type: "list(string)"},
+		},
+		Outputs: map[string]InterfaceOutput{"id": {}, "login_server": {}, "password": {Sensitive: true}, "name": {}},
+	}
+	breaking := ModuleInterface{
+		Version: "1.3.0",
+		Variables: map[string]InterfaceVariable{
+			"name":     {Type: "string", Required: true},
+			"sku":      {Type: "string", Required: true},
+			"tags":     {Type: "map(any)"},
+			"location": {Type: "string", Required: true},
+		},
+		Outputs: map[string]InterfaceOutput{"id": {}, "login_server": {Sensitive: true}},
+	}
+
+	breakingChanges, additions := InterfaceChanges(released, additive)
+	assert.Empty(t, breakingChanges)
+	assert.Equal(t, []string{"output name: added", "variable zones: added"}, additions)
+
+	breakingChanges, additions = InterfaceChanges(released, breaking)
+	assert.Equal(t, []string{
+		"output login_server: became sensitive",
+		"output password: removed",
+		"variable capacity: removed",
+		"variable location: added as required",
+		"variable sku: default removed",
+		"variable tags: type changed from map(string) to map(any)",
+	}, breakingChanges)
+	assert.Empty(t, additions)
+
+	problems, err := SemverProblems(released, additive)
+	if assert.NoError(t, err) {
+		assert.Empty(t, problems, "additions need no major version")
+	}
+
+	problems, err = SemverProblems(released, breaking)
+	if assert.NoError(t, err) && assert.Len(t, problems, 6) {
+		assert.Equal(t, "output login_server: became sensitive, a breaking change, but version 1.3.0 keeps major version 1 of 1.2.0", problems[0])
+	}
+
+	breaking.Version = "2.0.0"
+	problems, err = SemverProblems(released, breaking)
+	if assert.NoError(t, err) {
+		assert.Empty(t, problems, "a major version may break the interface")
+	}
+
+	additive.Version = "1.1.9"
+	problems, err = SemverProblems(released, additive)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"version 1.1.9 is older than the released 1.2.0"}, problems)
+	}
+
+	additive.Version = "next"
+	_, err = SemverProblems(released, additive)
+	assert.Error(t, err)
+}
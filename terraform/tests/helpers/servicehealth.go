@@ -0,0 +1,139 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// serviceHealthAPIVersion is the Microsoft.ResourceHealth API version used for event queries
+const serviceHealthAPIVersion = "2022-10-01"
+
+// serviceNamesByResourceType maps ARM resource types to the service names
+// used in Service Health impact data
+var serviceNamesByResourceType = map[string]string{
+	"microsoft.resources/resourcegroups":       "Azure Resource Manager",
+	"microsoft.containerregistry/registries":   "Container Registry",
+	"microsoft.keyvault/vaults":                "Key Vault",
+	"microsoft.app/containerapps":              "Azure Container Apps",
+	"microsoft.app/managedenvironments":        "Azure Container Apps",
+	"microsoft.operationalinsights/workspaces": "Log Analytics",
+	"microsoft.operationalinsights/clusters":   "Log Analytics",
+	"microsoft.insights/components":            "Application Insights",
+	"microsoft.network/virtualnetworks":        "Virtual Network",
+	"microsoft.network/privateendpoints":       "Private Link",
+}
+
+// ServiceHealthEvent is an active Service Health event (outage, advisory or maintenance)
+type ServiceHealthEvent struct {
+	TrackingID string
+	EventType  string
+	Title      string
+	Services   []string
+	StartTime  string
+}
+
+type serviceHealthEventList struct {
+	Value []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			EventType       string `json:"eventType"`
+			Status          string `json:"status"`
+			Title           string `json:"title"`
+			ImpactStartTime string `json:"impactStartTime"`
+			Impact          []struct {
+				ImpactedService string `json:"impactedService"`
+				ImpactedRegions []struct {
+					ImpactedRegion string `json:"impactedRegion"`
+				} `json:"impactedRegions"`
+			} `json:"impact"`
+		} `json:"properties"`
+	} `json:"value"`
+}
+
+// GetActiveServiceHealthEventsE returns active Service Health events affecting
+// location. When resourceTypes is empty, events for every service are returned
+func GetActiveServiceHealthEventsE(t *testing.T, subscriptionID, location string, resourceTypes ...string) ([]ServiceHealthEvent, error) {
+	query := url.Values{}
+	query.Set("api-version", serviceHealthAPIVersion)
+	query.Set("$filter", "properties/status eq 'Active'")
+	// az rest puts the Resource Manager endpoint of its cloud before a path
+	requestURL := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.ResourceHealth/events?%s",
+		subscriptionID, query.Encode())
+
+	var events serviceHealthEventList
+	if err := AzCLIJSONE(t, &events, "rest", "--method", "get", "--url", requestURL); err != nil {
+		return nil, err
+	}
+
+	services := map[string]bool{}
+	for _, resourceType := range resourceTypes {
+		if name, ok := serviceNamesByResourceType[strings.ToLower(resourceType)]; ok {
+			services[name] = true
+		}
+	}
+
+	var active []ServiceHealthEvent
+	for _, event := range events.Value {
+		if event.Properties.Status != "Active" {
+			continue
+		}
+
+		var impacted []string
+		for _, impact := range event.Properties.Impact {
+			if len(services) > 0 && !services[impact.ImpactedService] {
+				continue
+			}
+			for _, region := range impact.ImpactedRegions {
+				if normalizeRegion(region.ImpactedRegion) == normalizeRegion(location) {
+					impacted = append(impacted, impact.ImpactedService)
+					break
+				}
+			}
+		}
+
+		if len(impacted) > 0 {
+			active = append(active, ServiceHealthEvent{
+				TrackingID: event.Name,
+				EventType:  event.Properties.EventType,
+				Title:      event.Properties.Title,
+				Services:   impacted,
+				StartTime:  event.Properties.ImpactStartTime,
+			})
+		}
+	}
+	return active, nil
+}
+
+// CaptureServiceHealthOnFailure registers a cleanup that, if the test failed,
+// logs any active Service Health events for location and resourceTypes so
+// regional Azure incidents are visible next to the failure
+func CaptureServiceHealthOnFailure(t *testing.T, subscriptionID, location string, resourceTypes ...string) {
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+
+		events, err := GetActiveServiceHealthEventsE(t, subscriptionID, location, resourceTypes...)
+		if err != nil {
+			t.Logf("Could not query Azure Service Health for %s: %v", location, err)
+			return
+		}
+		if len(events) == 0 {
+			t.Logf("No active Azure Service Health events for %s", location)
+			return
+		}
+
+		t.Logf("%d active Azure Service Health event(s) for %s - this failure may be an Azure incident:", len(events), location)
+		for _, event := range events {
+			t.Logf("  [%s] %s (%s) since %s, services: %s",
+				event.EventType, event.Title, event.TrackingID, event.StartTime, strings.Join(event.Services, ", "))
+		}
+	})
+}
+
+// normalizeRegion converts display names ("East US 2") and ARM names ("eastus2") to one form
+func normalizeRegion(region string) string {
+	return strings.ToLower(strings.ReplaceAll(region, " ", ""))
+}
@@ -0,0 +1,222 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// sharedFixture is a fixture deployed by the first test that needs it and
+// destroyed after the last: by DestroySharedFixturesE once every test ran,
+// or, for reference-counted fixtures, as soon as the last test holding it
+// finishes
+type sharedFixture struct {
+	name    string
+	options *terraform.Options
+	// workspace is the shared backend workspace, or "" for local state
+	workspace string
+}
+
+// sharedLifetime is how long a shared fixture is kept once deployed
+type sharedLifetime int
+
+const (
+	// untilRunEnds fixtures are kept for the whole run and destroyed by
+	// TestMain
+	untilRunEnds sharedLifetime = iota
+	// untilLastRelease fixtures are destroyed when the last test holding
+	// them finishes, and deployed again if a later test asks
+	untilLastRelease
+)
+
+// sharedDeployment is a deployment of a shared fixture, or the error
+// deploying it failed with
+type sharedDeployment struct {
+	once     sync.Once
+	lifetime sharedLifetime
+	value    interface{}
+	err      error
+}
+
+var (
+	sharedFixturesMu sync.Mutex
+	sharedFixtures   []sharedFixture
+	// sharedDeployments holds the deployment of each fixture acquireSharedE
+	// handed out, by name
+	sharedDeployments = map[string]*sharedDeployment{}
+	// sharedReferences counts the tests holding each shared fixture, by name
+	sharedReferences = map[string]int{}
+)
+
+// acquireSharedE returns the run's deployment of the named shared fixture,
+// calling deploy on first use, and holds a reference to it until t and its
+// subtests finish. Later tests reuse the deployment, or get the error of the
+// first one. deploy must register the fixture with registerSharedFixture
+// under the same name
+func acquireSharedE(t *testing.T, name string, deploy func(t *testing.T) (interface{}, error)) (interface{}, error) {
+	return acquireE(t, name, untilRunEnds, deploy)
+}
+
+// acquireRefCountedE is acquireSharedE for a fixture destroyed as soon as
+// the last test holding it finishes rather than at the end of the run. The
+// reference is taken before deploying, so a deployment that fails or stops
+// the test is still destroyed, and never while another test waits on it.
+// deploy must name what it creates after t, not the run alone: a later test
+// may deploy the fixture again while the previous deployment is destroyed
+func acquireRefCountedE(t *testing.T, name string, deploy func(t *testing.T) (interface{}, error)) (interface{}, error) {
+	return acquireE(t, name, untilLastRelease, deploy)
+}
+
+// acquireE returns the current deployment of the named shared fixture with
+// the given lifetime, deploying it if there is none
+func acquireE(t *testing.T, name string, lifetime sharedLifetime, deploy func(t *testing.T) (interface{}, error)) (interface{}, error) {
+	sharedFixturesMu.Lock()
+	deployment, exists := sharedDeployments[name]
+	if !exists {
+		deployment = &sharedDeployment{lifetime: lifetime}
+		sharedDeployments[name] = deployment
+	}
+	if deployment.lifetime == untilLastRelease {
+		sharedReferences[name]++
+		t.Cleanup(func() { releaseShared(t, name, deployment) })
+	}
+	sharedFixturesMu.Unlock()
+
+	deployment.once.Do(func() {
+		// Stays set if the deployment stops the deploying test
+		deployment.err = fmt.Errorf("deploying the shared %s stopped %s; see its log", name, t.Name())
+		deployment.value, deployment.err = deploy(t)
+	})
+	if deployment.err != nil {
+		return nil, deployment.err
+	}
+
+	if deployment.lifetime == untilRunEnds {
+		sharedFixturesMu.Lock()
+		sharedReferences[name]++
+		sharedFixturesMu.Unlock()
+		t.Cleanup(func() {
+			sharedFixturesMu.Lock()
+			defer sharedFixturesMu.Unlock()
+			sharedReferences[name]--
+		})
+	}
+	return deployment.value, nil
+}
+
+// releaseShared drops t's reference to a reference-counted deployment and,
+// when it was the last, destroys it. The deployment is forgotten first, so a
+// test asking meanwhile deploys a new one instead of getting one being
+// destroyed. A destroy that fails is left registered for TestMain to retry
+// and report. With SKIP_destroy the deployment is kept for later tests
+func releaseShared(t *testing.T, name string, deployment *sharedDeployment) {
+	sharedFixturesMu.Lock()
+	sharedReferences[name]--
+	if sharedReferences[name] > 0 || StageSkipped("destroy") || sharedDeployments[name] != deployment {
+		sharedFixturesMu.Unlock()
+		return
+	}
+	delete(sharedDeployments, name)
+	var released, kept []sharedFixture
+	for _, fixture := range sharedFixtures {
+		if fixture.name == name {
+			released = append(released, fixture)
+		} else {
+			kept = append(kept, fixture)
+		}
+	}
+	sharedFixtures = kept
+	sharedFixturesMu.Unlock()
+
+	for _, fixture := range released {
+		t.Logf("%s was the last test holding the shared %s, destroying it", t.Name(), name)
+		if err := destroySharedFixtureE(t, fixture); err != nil {
+			t.Logf("Destroying the shared %s: %v; TestMain retries", name, err)
+			registerSharedFixture(fixture.name, fixture.options, fixture.workspace)
+		}
+	}
+}
+
+// SharedFixtureReferences returns how many running tests hold the named
+// shared fixture
+func SharedFixtureReferences(name string) int {
+	sharedFixturesMu.Lock()
+	defer sharedFixturesMu.Unlock()
+	return sharedReferences[name]
+}
+
+// registerSharedFixture queues a fixture for destruction at the end of the
+// run. It is called before the first apply, so a partial deployment is
+// destroyed too
+func registerSharedFixture(name string, options *terraform.Options, workspace string) {
+	sharedFixturesMu.Lock()
+	defer sharedFixturesMu.Unlock()
+	sharedFixtures = append(sharedFixtures, sharedFixture{name: name, options: options, workspace: workspace})
+}
+
+// DestroySharedFixturesE destroys the fixtures shared by the run's tests.
+// TestMain calls it once m.Run returns, since no single test outlives the
+// others. A fixture still held by a test is kept rather than pulled from
+// under it; it and a fixture that fails to destroy are left to `ttk janitor`
+func DestroySharedFixturesE() error {
+	sharedFixturesMu.Lock()
+	defer sharedFixturesMu.Unlock()
+
+	var errs []error
+	var held []sharedFixture
+	for _, fixture := range sharedFixtures {
+		if references := sharedReferences[fixture.name]; references > 0 {
+			errs = append(errs, fmt.Errorf("keeping shared %s: %d tests still hold it", fixture.name, references))
+			held = append(held, fixture)
+			continue
+		}
+		delete(sharedDeployments, fixture.name)
+		if err := destroySharedFixtureE(&runT{name: "shared/" + fixture.name}, fixture); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	sharedFixtures = held
+	return errors.Join(errs...)
+}
+
+// destroySharedFixtureE destroys a shared fixture and deletes its backend
+// workspace. A workspace that cannot be deleted is reported and kept, since
+// its state is empty
+func destroySharedFixtureE(t terratesting.TestingT, fixture sharedFixture) error {
+	if _, err := terraform.DestroyE(t, fixture.options); err != nil {
+		return fmt.Errorf("destroying shared %s: %w", fixture.name, err)
+	}
+	if fixture.workspace != "" {
+		if _, err := terraform.WorkspaceDeleteE(t, fixture.options, fixture.workspace); err != nil {
+			fmt.Fprintf(os.Stderr, "Keeping workspace %s in the shared backend: %v\n", fixture.workspace, err)
+		}
+	}
+	return nil
+}
+
+// runT stands in for *testing.T in terratest calls made outside any test,
+// such as from TestMain. Errors are printed; FailNow and Fatal do not stop
+// the caller, so callers must use the E variants
+type runT struct {
+	name string
+}
+
+func (r *runT) Fail()        {}
+func (r *runT) FailNow()     {}
+func (r *runT) Name() string { return r.name }
+
+func (r *runT) Error(args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "%s: %s", r.name, fmt.Sprintln(args...))
+}
+
+func (r *runT) Errorf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", r.name, fmt.Sprintf(format, args...))
+}
+
+func (r *runT) Fatal(args ...interface{})                 { r.Error(args...) }
+func (r *runT) Fatalf(format string, args ...interface{}) { r.Errorf(format, args...) }
@@ -0,0 +1,153 @@
+package helpers
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireShared(t *testing.T) {
+	t.Parallel()
+
+	var deploys int32
+	deploy := func(t *testing.T) (interface{}, error) {
+		atomic.AddInt32(&deploys, 1)
+		return "deployment", nil
+	}
+
+	t.Run("tests", func(t *testing.T) {
+		for _, name := range []string{"first", "second", "third"} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				value, err := acquireSharedE(t, "pool-test", deploy)
+				if assert.NoError(t, err) {
+					assert.Equal(t, "deployment", value)
+				}
+				assert.GreaterOrEqual(t, SharedFixtureReferences("pool-test"), 1, "the test should hold a reference")
+			})
+		}
+	})
+
+	assert.Equal(t, int32(1), deploys, "the fixture should be deployed once")
+	assert.Zero(t, SharedFixtureReferences("pool-test"), "finished tests should release their references")
+}
+
+func TestAcquireSharedError(t *testing.T) {
+	t.Parallel()
+
+	var deploys int32
+	deploy := func(t *testing.T) (interface{}, error) {
+		atomic.AddInt32(&deploys, 1)
+		return nil, errors.New("no capacity")
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := acquireSharedE(t, "pool-error-test", deploy)
+		assert.EqualError(t, err, "no capacity")
+	}
+	assert.Equal(t, int32(1), deploys, "a failed deployment should not be retried")
+	assert.Zero(t, SharedFixtureReferences("pool-error-test"), "failed acquisitions hold no reference")
+}
+
+func TestAcquireRefCounted(t *testing.T) {
+	t.Parallel()
+
+	var deploys int32
+	deploy := func(t *testing.T) (interface{}, error) {
+		atomic.AddInt32(&deploys, 1)
+		assert.Equal(t, 1, SharedFixtureReferences("pool-rc-test"), "the deploying test should hold a reference while it deploys")
+		return "deployment", nil
+	}
+
+	t.Run("tests", func(t *testing.T) {
+		// The parent holds the fixture until its subtests finish, however
+		// they are scheduled
+		if _, err := acquireRefCountedE(t, "pool-rc-test", deploy); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"first", "second", "third"} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				value, err := acquireRefCountedE(t, "pool-rc-test", deploy)
+				if assert.NoError(t, err) {
+					assert.Equal(t, "deployment", value)
+				}
+			})
+		}
+	})
+
+	assert.Equal(t, int32(1), deploys, "tests holding the fixture together should share one deployment")
+	assert.Zero(t, SharedFixtureReferences("pool-rc-test"), "finished tests should release their references")
+	sharedFixturesMu.Lock()
+	_, kept := sharedDeployments["pool-rc-test"]
+	sharedFixturesMu.Unlock()
+	assert.False(t, kept, "the last release should forget the deployment")
+
+	t.Run("later", func(t *testing.T) {
+		_, err := acquireRefCountedE(t, "pool-rc-test", deploy)
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, int32(2), deploys, "a test after the last release should deploy again")
+}
+
+func TestAcquireRefCountedError(t *testing.T) {
+	t.Parallel()
+
+	var deploys int32
+	deploy := func(t *testing.T) (interface{}, error) {
+		atomic.AddInt32(&deploys, 1)
+		return nil, errors.New("no capacity")
+	}
+
+	t.Run("failed", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := acquireRefCountedE(t, "pool-rc-error-test", deploy)
+			assert.EqualError(t, err, "no capacity")
+		}
+		assert.Equal(t, 2, SharedFixtureReferences("pool-rc-error-test"),
+			"failed acquisitions hold their reference, so a partial deployment is destroyed once the test ends")
+	})
+
+	assert.Equal(t, int32(1), deploys, "a failed deployment should not be retried while it is held")
+	assert.Zero(t, SharedFixtureReferences("pool-rc-error-test"))
+
+	t.Run("retry", func(t *testing.T) {
+		_, err := acquireRefCountedE(t, "pool-rc-error-test", deploy)
+		assert.Error(t, err)
+	})
+	assert.Equal(t, int32(2), deploys, "a failed deployment should be retried once released")
+}
+
+// TestDestroySharedFixturesKeepsHeld is not parallel, since destroying
+// acts on every shared fixture of the package
+func TestDestroySharedFixturesKeepsHeld(t *testing.T) {
+	t.Cleanup(func() {
+		sharedFixturesMu.Lock()
+		defer sharedFixturesMu.Unlock()
+		sharedFixtures = nil
+		delete(sharedDeployments, "pool-held-test")
+	})
+
+	t.Run("holder", func(t *testing.T) {
+		_, err := acquireSharedE(t, "pool-held-test", func(t *testing.T) (interface{}, error) {
+			registerSharedFixture("pool-held-test", &terraform.Options{TerraformDir: t.TempDir()}, "")
+			return "deployment", nil
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.EqualError(t, DestroySharedFixturesE(), "keeping shared pool-held-test: 1 tests still hold it")
+	})
+
+	assert.Zero(t, SharedFixtureReferences("pool-held-test"))
+	sharedFixturesMu.Lock()
+	defer sharedFixturesMu.Unlock()
+	if assert.Len(t, sharedFixtures, 1, "the held fixture should stay registered") {
+		assert.Equal(t, "pool-held-test", sharedFixtures[0].name)
+	}
+}
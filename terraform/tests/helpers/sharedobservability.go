@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// sharedObservabilityFixture is the fixture AcquireSharedObservability deploys
+const sharedObservabilityFixture = "./fixtures/shared-observability"
+
+// SharedObservability is the run's shared resource group and Log Analytics
+// workspace
+type SharedObservability struct {
+	ResourceGroupName string
+	Location          string
+	// WorkspaceID is the ARM ID of the workspace, as fixtures' and modules'
+	// log_analytics_workspace_id take it
+	WorkspaceID string
+	// WorkspaceCustomerID is the workspace ID log queries take
+	WorkspaceCustomerID string
+}
+
+// AcquireSharedObservabilityE returns the run's shared resource group and
+// Log Analytics workspace, deploying them for the first test that asks. The
+// test holds a reference until it finishes, and TestMain destroys them once
+// every test has (see DestroySharedFixturesE). Tests must not change or
+// delete what they get: every test of the run sees the same workspace
+func AcquireSharedObservabilityE(t *testing.T) (*SharedObservability, error) {
+	shared, err := acquireSharedE(t, "observability", func(t *testing.T) (interface{}, error) {
+		return deploySharedObservabilityE(t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shared.(*SharedObservability), nil
+}
+
+// AcquireSharedObservability returns the run's shared resource group and Log
+// Analytics workspace and fails the test when they could not be deployed
+func AcquireSharedObservability(t *testing.T) *SharedObservability {
+	shared, err := AcquireSharedObservabilityE(t)
+	if err != nil {
+		t.Fatalf("Shared observability: %v", err)
+	}
+	return shared
+}
+
+// deploySharedObservabilityE applies the shared observability fixture in a
+// workspace of the run, registered for destruction before the apply
+func deploySharedObservabilityE(t *testing.T) (*SharedObservability, error) {
+	config := newTestConfig(t)
+	tags := StandardTags("shared/observability")
+	options := DefaultTerraformOptions(t, sharedObservabilityFixture, map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("shared"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"tags":                tags,
+	})
+	workspace := WorkspaceName(RunID(), "shared-observability")
+	if !useWorkspace(t, options, workspace) {
+		workspace = ""
+	}
+	registerSharedFixture("observability", options, workspace)
+
+	if _, err := terraform.InitAndApplyE(t, options); err != nil {
+		return nil, err
+	}
+	outputs, err := terraform.OutputAllE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return &SharedObservability{
+		ResourceGroupName:   fmt.Sprint(outputs["resource_group_name"]),
+		Location:            fmt.Sprint(outputs["location"]),
+		WorkspaceID:         fmt.Sprint(outputs["log_analytics_workspace_id"]),
+		WorkspaceCustomerID: fmt.Sprint(outputs["log_analytics_workspace_customer_id"]),
+	}, nil
+}
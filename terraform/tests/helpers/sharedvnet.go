@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// sharedVNetFixture is the fixture AcquireSharedVNet deploys
+const sharedVNetFixture = "./fixtures/shared-vnet"
+
+// SharedVNet is a VNet shared by the tests holding it
+type SharedVNet struct {
+	ResourceGroupName string
+	Location          string
+	VNetID            string
+	VNetName          string
+	// PrivateEndpointSubnetID is the subnet tests put private endpoints in
+	PrivateEndpointSubnetID string
+}
+
+// AcquireSharedVNetE returns the shared VNet, deploying it if no running
+// test holds one. The test holds a reference until it finishes, and the last
+// test to finish destroys the VNet. Tests must not change or delete what
+// they get, and must remove what they added to it, such as private
+// endpoints, before they finish
+func AcquireSharedVNetE(t *testing.T) (*SharedVNet, error) {
+	shared, err := acquireRefCountedE(t, "vnet", func(t *testing.T) (interface{}, error) {
+		return deploySharedVNetE(t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shared.(*SharedVNet), nil
+}
+
+// AcquireSharedVNet returns the shared VNet and fails the test when it could
+// not be deployed
+func AcquireSharedVNet(t *testing.T) *SharedVNet {
+	shared, err := AcquireSharedVNetE(t)
+	if err != nil {
+		t.Fatalf("Shared VNet: %v", err)
+	}
+	return shared
+}
+
+// deploySharedVNetE applies the shared VNet fixture in a workspace of its
+// own, registered for destruction before the apply
+func deploySharedVNetE(t *testing.T) (*SharedVNet, error) {
+	config := newTestConfig(t)
+	options := DefaultTerraformOptions(t, sharedVNetFixture, map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("vnet"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"tags":                StandardTags("shared/vnet"),
+	})
+	workspace := WorkspaceName(RunID(), "shared-vnet-"+config.UniqueID)
+	if !useWorkspace(t, options, workspace) {
+		workspace = ""
+	}
+	registerSharedFixture("vnet", options, workspace)
+
+	if _, err := terraform.InitAndApplyE(t, options); err != nil {
+		return nil, err
+	}
+	outputs, err := terraform.OutputAllE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return &SharedVNet{
+		ResourceGroupName:       fmt.Sprint(outputs["resource_group_name"]),
+		Location:                fmt.Sprint(outputs["location"]),
+		VNetID:                  fmt.Sprint(outputs["vnet_id"]),
+		VNetName:                fmt.Sprint(outputs["vnet_name"]),
+		PrivateEndpointSubnetID: fmt.Sprint(outputs["private_endpoint_subnet_id"]),
+	}, nil
+}
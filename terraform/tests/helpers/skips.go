@@ -0,0 +1,142 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// skipReport holds the category and reason of every test skipped with
+// SkipWithReason
+const skipReport = "skips"
+
+// skipSummaryReport holds the tests of a run skipped with SkipWithReason by
+// category, written from TestMain by RecordSkipSummaryE
+const skipSummaryReport = "skip_summary"
+
+// SkipCategory is why a test did not run, so a run's skips can be told apart
+// from each other and counted
+type SkipCategory string
+
+// Categories of skips
+const (
+	// SkipQuota means the subscription lacks the quota the test deploys
+	SkipQuota SkipCategory = "quota"
+	// SkipRegionCapability means the region lacks a service or SKU the test
+	// needs (see SkipUnlessRegionSupports)
+	SkipRegionCapability SkipCategory = "region-capability"
+	// SkipBudget means the test costs more than the run is allowed to spend
+	SkipBudget SkipCategory = "budget"
+	// SkipMissingEnv means the test is opt-in or needs a setting the run
+	// does not have
+	SkipMissingEnv SkipCategory = "missing-env"
+	// SkipPermissions means the runner's identity is not allowed to do
+	// something the test needs, such as creating app registrations
+	SkipPermissions SkipCategory = "permissions"
+	// SkipShortMode means the test deploys to Azure and the run is -short
+	SkipShortMode SkipCategory = "short-mode"
+)
+
+// SkipCategories lists every SkipCategory in the order summaries print them
+var SkipCategories = []SkipCategory{SkipShortMode, SkipMissingEnv, SkipPermissions, SkipRegionCapability, SkipQuota, SkipBudget}
+
+// skippedTest is an entry of the skips report
+type skippedTest struct {
+	Category SkipCategory `json:"category"`
+	Reason   string       `json:"reason"`
+}
+
+// knownSkipCategory reports whether category is one of SkipCategories
+func knownSkipCategory(category SkipCategory) bool {
+	for _, known := range SkipCategories {
+		if category == known {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipWithReason skips t with msg, recording category and msg in the skips
+// report so the run's summary counts the skip instead of losing it among
+// the passes. Call it instead of t.Skip
+func SkipWithReason(t *testing.T, category SkipCategory, msg string) {
+	t.Helper()
+	if !knownSkipCategory(category) {
+		t.Fatalf("Unknown skip category %q; use one of %v", category, SkipCategories)
+	}
+	if err := RecordReportE(skipReport, t.Name(), skippedTest{Category: category, Reason: msg}); err != nil {
+		t.Logf("Recording the skip of %s: %v", t.Name(), err)
+	}
+	t.Skipf("[%s] %s", category, msg)
+}
+
+// SkipSummary lists the tests skipped with SkipWithReason by category,
+// sorted by name
+type SkipSummary map[SkipCategory][]string
+
+// summarizeSkips groups the entries of a skips report by category
+func summarizeSkips(skips map[string]skippedTest) SkipSummary {
+	summary := SkipSummary{}
+	for test, skip := range skips {
+		summary[skip.Category] = append(summary[skip.Category], test)
+	}
+	for _, tests := range summary {
+		sort.Strings(tests)
+	}
+	return summary
+}
+
+// Total is the number of skipped tests
+func (s SkipSummary) Total() int {
+	total := 0
+	for _, tests := range s {
+		total += len(tests)
+	}
+	return total
+}
+
+// String counts the skipped tests per category, e.g. "12 tests skipped:
+// 9 short-mode, 3 missing-env"
+func (s SkipSummary) String() string {
+	if s.Total() == 0 {
+		return "no tests skipped with a reason"
+	}
+	var counts []string
+	for _, category := range SkipCategories {
+		if tests := s[category]; len(tests) > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", len(tests), category))
+		}
+	}
+	return fmt.Sprintf("%d tests skipped: %s", s.Total(), strings.Join(counts, ", "))
+}
+
+// RecordSkipSummaryE summarizes the skips report of the current run and
+// records the summary in the skip_summary report. Call it from TestMain once
+// the tests have run
+func RecordSkipSummaryE() (SkipSummary, error) {
+	skips := map[string]skippedTest{}
+	if err := readReportFileE(ReportDir(RunID()), skipReport, &skips); err != nil {
+		return nil, err
+	}
+	summary := summarizeSkips(skips)
+	for category, tests := range summary {
+		if err := RecordReportE(skipSummaryReport, string(category), tests); err != nil {
+			return nil, err
+		}
+	}
+	return summary, nil
+}
+
+// applySkipCategories sets the skip category of the tests recorded in the
+// skips report
+func applySkipCategories(tests map[string]TestOutcome, skips map[string]skippedTest) {
+	for test, skip := range skips {
+		outcome, found := tests[test]
+		if !found || outcome.Status != "skip" {
+			continue
+		}
+		outcome.SkipCategory = skip.Category
+		tests[test] = outcome
+	}
+}
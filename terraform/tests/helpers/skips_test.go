@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeSkips(t *testing.T) {
+	t.Parallel()
+
+	summary := summarizeSkips(map[string]skippedTest{
+		"TestVault":        {Category: SkipShortMode, Reason: "Skipping slow test in short mode"},
+		"TestApp":          {Category: SkipShortMode, Reason: "Skipping slow test in short mode"},
+		"TestColdStart":    {Category: SkipMissingEnv, Reason: "Set TEST_COLD_START=true"},
+		"TestMatrix/qatar": {Category: SkipRegionCapability, Reason: "no Container Apps"},
+	})
+	assert.Equal(t, SkipSummary{
+		SkipShortMode:        {"TestApp", "TestVault"},
+		SkipMissingEnv:       {"TestColdStart"},
+		SkipRegionCapability: {"TestMatrix/qatar"},
+	}, summary)
+	assert.Equal(t, 4, summary.Total())
+	assert.Equal(t, "4 tests skipped: 2 short-mode, 1 missing-env, 1 region-capability", summary.String())
+
+	assert.Equal(t, "no tests skipped with a reason", SkipSummary{}.String())
+}
+
+func TestSkipCategoriesAreKnown(t *testing.T) {
+	t.Parallel()
+
+	for _, category := range []SkipCategory{SkipQuota, SkipRegionCapability, SkipBudget, SkipMissingEnv, SkipPermissions, SkipShortMode} {
+		assert.True(t, knownSkipCategory(category), "%s should be summarized", category)
+	}
+	assert.False(t, knownSkipCategory("flaky"))
+}
+
+func TestRecordSkipSummaryE(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(ReportDir(RunID())) })
+
+	assert.NoError(t, RecordReportE(skipReport, "TestVault", skippedTest{Category: SkipShortMode, Reason: "short"}))
+	assert.NoError(t, RecordReportE(skipReport, "TestCMK", skippedTest{Category: SkipBudget, Reason: "100 GB/day"}))
+
+	summary, err := RecordSkipSummaryE()
+	if assert.NoError(t, err) {
+		assert.Equal(t, SkipSummary{SkipShortMode: {"TestVault"}, SkipBudget: {"TestCMK"}}, summary)
+	}
+	recorded := SkipSummary{}
+	if assert.NoError(t, readReportFileE(ReportDir(RunID()), skipSummaryReport, &recorded)) {
+		assert.Equal(t, summary, recorded)
+	}
+}
+
+func TestBuildRunSummarySkipCategories(t *testing.T) {
+	t.Parallel()
+
+	reportDir := filepath.Join(t.TempDir(), "run-44")
+	if err := os.MkdirAll(reportDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	skips := `{"TestSlow": {"category": "short-mode", "reason": "Skipping slow test in short mode"},
+		"TestVault": {"category": "missing-env", "reason": "recorded, then the test ran anyway"}}`
+	if err := os.WriteFile(filepath.Join(reportDir, "skips.json"), []byte(skips), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := BuildRunSummaryE(strings.NewReader(sampleGoTestJSON), reportDir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, TestOutcome{Status: "skip", SkipCategory: SkipShortMode}, summary.Tests["TestSlow"])
+		assert.Empty(t, summary.Tests["TestVault"].SkipCategory, "only skipped tests have a skip category")
+	}
+}
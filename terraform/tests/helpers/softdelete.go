@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// SoftDeletePolicy is a registry's soft delete policy from
+// `az acr config soft-delete show`
+type SoftDeletePolicy struct {
+	Status        string `json:"status"`
+	RetentionDays int    `json:"retentionDays"`
+}
+
+// Enabled reports whether deleted artifacts are kept
+func (p SoftDeletePolicy) Enabled() bool {
+	return strings.EqualFold(p.Status, "enabled")
+}
+
+// DeletedTag is a soft-deleted tag of a repository from
+// `az acr manifest list-deleted-tags`
+type DeletedTag struct {
+	Tag    string `json:"tag"`
+	Digest string `json:"digest"`
+}
+
+// SoftDeletePolicyE returns the soft delete policy of registryName
+func SoftDeletePolicyE(t *testing.T, registryName string) (*SoftDeletePolicy, error) {
+	var policy SoftDeletePolicy
+	if err := AzCLIJSONE(t, &policy, "acr", "config", "soft-delete", "show", "--registry", registryName); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// DeleteImageE deletes image (repository:tag) from registryName with its
+// manifest and every other tag of it, as `az acr repository delete` does
+func DeleteImageE(t *testing.T, registryName, image string) error {
+	_, err := AzCLIE(t, "acr", "repository", "delete", "--name", registryName, "--image", image, "--yes")
+	return err
+}
+
+// DeletedTagsE lists the soft-deleted tags of repository in registryName
+// that can still be restored
+func DeletedTagsE(t *testing.T, registryName, repository string) ([]DeletedTag, error) {
+	var tags []DeletedTag
+	if err := AzCLIJSONE(t, &tags, "acr", "manifest", "list-deleted-tags",
+		"--registry", registryName, "--name", repository); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// RestoreTagE restores the soft-deleted image (repository:tag) of
+// registryName to the manifest with digest; a tag deleted more than once
+// has a deleted manifest per deletion
+func RestoreTagE(t *testing.T, registryName, image, digest string) error {
+	_, err := AzCLIE(t, "acr", "manifest", "restore", "--registry", registryName, "--name", image, "--digest", digest)
+	return err
+}
+
+// DeletedDigests returns the digests tag was deleted from, sorted and
+// without duplicates
+func DeletedDigests(deleted []DeletedTag, tag string) []string {
+	seen := map[string]bool{}
+	digests := []string{}
+	for _, entry := range deleted {
+		if entry.Tag == tag && !seen[entry.Digest] {
+			seen[entry.Digest] = true
+			digests = append(digests, entry.Digest)
+		}
+	}
+	sort.Strings(digests)
+	return digests
+}
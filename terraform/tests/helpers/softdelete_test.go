@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoftDeletePolicyEnabled(t *testing.T) {
+	t.Parallel()
+
+	var policy SoftDeletePolicy
+	if assert.NoError(t, json.Unmarshal([]byte(`{"retentionDays": 7, "status": "enabled"}`), &policy)) {
+		assert.True(t, policy.Enabled())
+		assert.Equal(t, 7, policy.RetentionDays)
+	}
+	assert.False(t, SoftDeletePolicy{Status: "disabled"}.Enabled())
+	assert.False(t, SoftDeletePolicy{}.Enabled(), "a registry without the policy keeps nothing")
+}
+
+func TestDeletedDigests(t *testing.T) {
+	t.Parallel()
+
+	deleted := []DeletedTag{
+		{Tag: "v2", Digest: "sha256:bbb"},
+		{Tag: "v1", Digest: "sha256:aaa"},
+		{Tag: "v2", Digest: "sha256:aaa"},
+		{Tag: "v2", Digest: "sha256:bbb"},
+	}
+	assert.Equal(t, []string{"sha256:aaa", "sha256:bbb"}, DeletedDigests(deleted, "v2"), "each deletion of a tag is restorable")
+	assert.Equal(t, []string{"sha256:aaa"}, DeletedDigests(deleted, "v1"))
+	assert.Empty(t, DeletedDigests(deleted, "latest"))
+}
@@ -0,0 +1,345 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// StackOutputRef names an output of another module of the same stack
+type StackOutputRef struct {
+	Module string
+	Output string
+}
+
+// StackOutput refers to output of module, for wiring into StackModule.Inputs
+func StackOutput(module, output string) StackOutputRef {
+	return StackOutputRef{Module: module, Output: output}
+}
+
+// StackModule is one module of a Stack, applied as its own root with its
+// own state
+type StackModule struct {
+	// Name identifies the module within the stack, e.g. "vault"
+	Name string
+	// Module is the folder under terraform/modules, e.g. "key-vault"
+	Module string
+	// Vars are the module's literal inputs
+	Vars map[string]interface{}
+	// Inputs are inputs taken from outputs of other modules of the stack,
+	// by input name. They decide the order modules are applied in
+	Inputs map[string]StackOutputRef
+	// Verify, if set, runs as a subtest named Name once the whole stack is
+	// applied. It reads outputs of any module of the stack, so checks
+	// across modules belong to the module that consumes the other
+	Verify func(t *testing.T, stack *Stack)
+}
+
+// Stack composes modules declaratively: tests declare the modules, how
+// outputs feed inputs and what to verify, and Deploy applies them level by
+// level, plumbs outputs into inputs, runs the checks and registers a
+// teardown that destroys the levels in reverse
+type Stack struct {
+	modules map[string]StackModule
+	levels  [][]string
+
+	mu      sync.Mutex
+	options map[string]*terraform.Options
+	outputs map[string]map[string]interface{}
+	// workspaces are the shared backend workspaces, by module, when state
+	// is not local
+	workspaces map[string]string
+	// started are the modules whose apply began, which teardown destroys
+	started map[string]bool
+}
+
+// NewStack checks the wiring of modules and orders them. It fails on
+// duplicate names, inputs from unknown modules and dependency cycles
+func NewStack(modules ...StackModule) (*Stack, error) {
+	levels, err := stackLevels(modules)
+	if err != nil {
+		return nil, err
+	}
+	stack := &Stack{
+		modules:    map[string]StackModule{},
+		levels:     levels,
+		options:    map[string]*terraform.Options{},
+		outputs:    map[string]map[string]interface{}{},
+		workspaces: map[string]string{},
+		started:    map[string]bool{},
+	}
+	for _, module := range modules {
+		stack.modules[module.Name] = module
+	}
+	return stack, nil
+}
+
+// Levels returns the module names in apply order: every module of a level
+// only takes inputs from earlier levels, so a level is applied in parallel
+func (s *Stack) Levels() [][]string {
+	return s.levels
+}
+
+// stackLevels sorts modules into levels by their Inputs. Names within a
+// level are sorted so the order is stable
+func stackLevels(modules []StackModule) ([][]string, error) {
+	dependencies := map[string]map[string]bool{}
+	for _, module := range modules {
+		if module.Name == "" || module.Module == "" {
+			return nil, fmt.Errorf("stack module %q needs both Name and Module", module.Name)
+		}
+		if _, exists := dependencies[module.Name]; exists {
+			return nil, fmt.Errorf("stack module %s is declared twice", module.Name)
+		}
+		dependencies[module.Name] = map[string]bool{}
+	}
+	for _, module := range modules {
+		for input, ref := range module.Inputs {
+			if _, exists := dependencies[ref.Module]; !exists {
+				return nil, fmt.Errorf("%s.%s takes an output of %s, which is not in the stack", module.Name, input, ref.Module)
+			}
+			if ref.Module == module.Name {
+				return nil, fmt.Errorf("%s.%s takes an output of its own module", module.Name, input)
+			}
+			dependencies[module.Name][ref.Module] = true
+		}
+	}
+
+	var levels [][]string
+	placed := map[string]bool{}
+	for len(placed) < len(dependencies) {
+		var level []string
+		for name, needs := range dependencies {
+			if placed[name] {
+				continue
+			}
+			ready := true
+			for dependency := range needs {
+				ready = ready && placed[dependency]
+			}
+			if ready {
+				level = append(level, name)
+			}
+		}
+		if len(level) == 0 {
+			var cycle []string
+			for name := range dependencies {
+				if !placed[name] {
+					cycle = append(cycle, name)
+				}
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("stack modules depend on each other in a cycle: %s", strings.Join(cycle, ", "))
+		}
+		sort.Strings(level)
+		for _, name := range level {
+			placed[name] = true
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// stackVars merges the literal vars of module with its wired inputs,
+// read from the outputs of the modules applied so far
+func stackVars(module StackModule, outputs map[string]map[string]interface{}) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for name, value := range module.Vars {
+		vars[name] = value
+	}
+	for input, ref := range module.Inputs {
+		value, exists := outputs[ref.Module][ref.Output]
+		if !exists {
+			return nil, fmt.Errorf("%s.%s takes output %s of %s, which has no such output", module.Name, input, ref.Output, ref.Module)
+		}
+		vars[input] = value
+	}
+	return vars, nil
+}
+
+// Output returns an output of a deployed module of the stack, or nil
+func (s *Stack) Output(module, output string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.outputs[module][output]
+}
+
+// OutputString returns an output of a deployed module of the stack as a
+// string, or "" if it is missing
+func (s *Stack) OutputString(module, output string) string {
+	if value := s.Output(module, output); value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+// Deploy applies the stack and runs the Verify of every module that has
+// one, failing the test if an apply fails
+func (s *Stack) Deploy(t *testing.T) {
+	if err := s.DeployE(t); err != nil {
+		t.Fatalf("Deploying stack: %v", err)
+	}
+	s.Verify(t)
+}
+
+// DeployE applies the stack level by level. The teardown is registered
+// first with t.Cleanup, so whatever part of the stack got applied is
+// destroyed in reverse order even when an apply fails. The outputs of every
+// module are checked with OutputProblems and OutputSchemaProblemsE
+func (s *Stack) DeployE(t *testing.T) error {
+	// Copies and workspaces are set up up front: they stop the test on
+	// failure, which only works outside the goroutines applying a level
+	for _, level := range s.levels {
+		for _, name := range level {
+			s.prepare(t, name)
+		}
+	}
+	t.Cleanup(func() {
+		if err := s.DestroyE(t); err != nil {
+			t.Errorf("Tearing down stack: %v", err)
+		}
+	})
+
+	for _, level := range s.levels {
+		errs := make([]error, len(level))
+		var wg sync.WaitGroup
+		for i, name := range level {
+			wg.Add(1)
+			go func(i int, name string) {
+				defer wg.Done()
+				errs[i] = s.apply(t, name)
+			}(i, name)
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare copies the module to a temp folder as a root module on its own
+// workspace
+func (s *Stack) prepare(t *testing.T, name string) {
+	module := s.modules[name]
+	options := DefaultTerraformOptions(t, filepath.Join("..", "modules", module.Module), nil)
+	workspace := WorkspaceName(RunID(), t.Name()+"-"+name)
+	if !useWorkspace(t, options, workspace) {
+		workspace = ""
+	}
+	providerFile := filepath.Join(options.TerraformDir, "provider_test.tf")
+	if err := os.WriteFile(providerFile, []byte(testProviderConfig), 0o600); err != nil {
+		t.Fatalf("Writing %s: %v", providerFile, err)
+	}
+
+	s.options[name] = options
+	s.workspaces[name] = workspace
+}
+
+// apply wires the inputs of a module, applies it and records its outputs
+func (s *Stack) apply(t *testing.T, name string) error {
+	module := s.modules[name]
+	s.mu.Lock()
+	vars, err := stackVars(module, s.outputs)
+	options := s.options[name]
+	if err == nil {
+		options.Vars = vars
+		s.started[name] = true
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if _, err := terraform.InitAndApplyE(t, options); err != nil {
+		return fmt.Errorf("applying %s (%s): %w", name, module.Module, err)
+	}
+	outputs, err := terraform.OutputAllE(t, options)
+	if err != nil {
+		return fmt.Errorf("reading outputs of %s: %w", name, err)
+	}
+	optional, err := OptionalOutputsE(options.TerraformDir)
+	if err != nil {
+		return fmt.Errorf("reading output declarations of %s: %w", name, err)
+	}
+	for _, problem := range OutputProblems(outputs, optional) {
+		t.Errorf("%s: %s", name, problem)
+	}
+	schemaProblems, err := OutputSchemaProblemsE(options.TerraformDir, outputs)
+	if err != nil {
+		return fmt.Errorf("checking outputs of %s against their schema: %w", name, err)
+	}
+	for _, problem := range schemaProblems {
+		t.Errorf("%s: %s", name, problem)
+	}
+
+	s.mu.Lock()
+	s.outputs[name] = outputs
+	s.mu.Unlock()
+	return nil
+}
+
+// Verify runs the Verify of every module as a subtest, in apply order
+func (s *Stack) Verify(t *testing.T) {
+	for _, level := range s.levels {
+		for _, name := range level {
+			module := s.modules[name]
+			if module.Verify == nil {
+				continue
+			}
+			t.Run(name, func(t *testing.T) {
+				module.Verify(t, s)
+			})
+		}
+	}
+}
+
+// DestroyE destroys the modules whose apply began, in reverse level order.
+// A failed destroy does not stop the others; their errors are returned
+// together. Workspaces of destroyed modules are deleted
+func (s *Stack) DestroyE(t *testing.T) error {
+	var errs []error
+	for i := len(s.levels) - 1; i >= 0; i-- {
+		level := s.levels[i]
+		levelErrs := make([]error, len(level))
+		var wg sync.WaitGroup
+		for j, name := range level {
+			s.mu.Lock()
+			started := s.started[name]
+			delete(s.started, name)
+			s.mu.Unlock()
+			if !started {
+				continue
+			}
+			wg.Add(1)
+			go func(j int, name string) {
+				defer wg.Done()
+				levelErrs[j] = s.destroy(t, name)
+			}(j, name)
+		}
+		wg.Wait()
+		errs = append(errs, levelErrs...)
+	}
+	return errors.Join(errs...)
+}
+
+// destroy destroys a module and deletes its workspace
+func (s *Stack) destroy(t *testing.T, name string) error {
+	options := s.options[name]
+	if _, err := terraform.DestroyE(t, options); err != nil {
+		return fmt.Errorf("destroying %s: %w", name, err)
+	}
+	if workspace := s.workspaces[name]; workspace != "" {
+		if _, err := terraform.WorkspaceDeleteE(t, options, workspace); err != nil {
+			t.Logf("Keeping workspace %s in the shared backend: %v", workspace, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStackLevels(t *testing.T) {
+	t.Parallel()
+
+	stack, err := NewStack(
+		StackModule{Name: "app", Module: "container-app", Inputs: map[string]StackOutputRef{
+			"resource_group_name":        StackOutput("rg", "name"),
+			"log_analytics_workspace_id": StackOutput("obs", "log_analytics_workspace_id"),
+			"key_vault_id":               StackOutput("vault", "id"),
+		}},
+		StackModule{Name: "vault", Module: "key-vault", Inputs: map[string]StackOutputRef{
+			"resource_group_name": StackOutput("rg", "name"),
+		}},
+		StackModule{Name: "rg", Module: "resource-group"},
+		StackModule{Name: "obs", Module: "observability", Inputs: map[string]StackOutputRef{
+			"resource_group_name": StackOutput("rg", "name"),
+		}},
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, [][]string{{"rg"}, {"obs", "vault"}, {"app"}}, stack.Levels())
+	}
+}
+
+func TestNewStackRejectsBadWiring(t *testing.T) {
+	t.Parallel()
+
+	rg := StackModule{Name: "rg", Module: "resource-group"}
+	testCases := []struct {
+		name    string
+		modules []StackModule
+		message string
+	}{
+		{"missing_module", []StackModule{{Name: "rg"}}, "needs both Name and Module"},
+		{"duplicate", []StackModule{rg, rg}, "declared twice"},
+		{"unknown_module", []StackModule{
+			{Name: "vault", Module: "key-vault", Inputs: map[string]StackOutputRef{"resource_group_name": StackOutput("rg", "name")}},
+		}, "vault.resource_group_name takes an output of rg, which is not in the stack"},
+		{"self", []StackModule{
+			{Name: "rg", Module: "resource-group", Inputs: map[string]StackOutputRef{"name": StackOutput("rg", "name")}},
+		}, "its own module"},
+		{"cycle", []StackModule{
+			rg,
+			{Name: "a", Module: "networking", Inputs: map[string]StackOutputRef{"x": StackOutput("b", "y")}},
+			{Name: "b", Module: "networking", Inputs: map[string]StackOutputRef{"y": StackOutput("a", "x")}},
+		}, "cycle: a, b"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewStack(tc.modules...)
+			assert.ErrorContains(t, err, tc.message)
+		})
+	}
+}
+
+func TestStackVars(t *testing.T) {
+	t.Parallel()
+
+	module := StackModule{
+		Name:   "vault",
+		Module: "key-vault",
+		Vars:   map[string]interface{}{"name": "kv-a", "resource_group_name": "overridden"},
+		Inputs: map[string]StackOutputRef{"resource_group_name": StackOutput("rg", "name")},
+	}
+	outputs := map[string]map[string]interface{}{"rg": {"name": "rg-a", "id": "/subscriptions/0000/resourceGroups/rg-a"}}
+
+	vars, err := stackVars(module, outputs)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{"name": "kv-a", "resource_group_name": "rg-a"}, vars, "wired inputs win over literal vars")
+	}
+	assert.Equal(t, "overridden", module.Vars["resource_group_name"], "the declaration is not modified")
+
+	module.Inputs["location"] = StackOutput("rg", "location")
+	_, err = stackVars(module, outputs)
+	assert.ErrorContains(t, err, "vault.location takes output location of rg, which has no such output")
+}
+
+func TestStackVerifyAndEmptyTeardown(t *testing.T) {
+	stack, err := NewStack(
+		StackModule{Name: "rg", Module: "resource-group"},
+		StackModule{Name: "vault", Module: "key-vault", Inputs: map[string]StackOutputRef{"resource_group_name": StackOutput("rg", "name")},
+			Verify: func(t *testing.T, stack *Stack) {
+				assert.Equal(t, "kv-a", stack.OutputString("vault", "name"))
+				assert.Equal(t, "rg-a", stack.OutputString("rg", "name"))
+				assert.Empty(t, stack.OutputString("rg", "missing"))
+			}},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	stack.outputs["rg"] = map[string]interface{}{"name": "rg-a"}
+	stack.outputs["vault"] = map[string]interface{}{"name": "kv-a"}
+	stack.Verify(t)
+
+	assert.NoError(t, stack.DestroyE(t), "nothing was applied, so there is nothing to destroy")
+}
@@ -0,0 +1,141 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+)
+
+// stageRoot holds what tests keep with SKIP_destroy, keyed by test name, so
+// later runs can reuse it with SKIP_apply
+const stageRoot = ".test-data/stages"
+
+// StageSkipped reports whether the named stage (init, apply, validate or
+// destroy) is skipped with SKIP_<stage>, as test_structure.RunTestStage does
+func StageSkipped(stage string) bool {
+	return os.Getenv("SKIP_"+stage) != ""
+}
+
+// stageDir is where the current test keeps its deployment between runs. It
+// leaves out the run ID so the next run finds it
+func stageDir(t *testing.T) string {
+	return filepath.Join(stageRoot, WorkspaceName("", t.Name()))
+}
+
+// keptOptionsDir is where the options of the fixture in terraformDir are kept;
+// fixtures copied to temp keep their folder name, so it is the same before and
+// after UseIsolatedWorkspace
+func keptOptionsDir(t *testing.T, terraformDir string) string {
+	return filepath.Join(stageDir(t), filepath.Base(terraformDir))
+}
+
+// keepStageValue keeps *value for the next run when SKIP_destroy is set, read
+// once the test ends so later changes such as a region fallback are kept too.
+// When SKIP_apply is set, *value is replaced by what the previous run kept
+func keepStageValue(t *testing.T, name string, value *string) {
+	path := test_structure.FormatTestDataPath(stageDir(t), name+".json")
+	if StageSkipped("apply") && test_structure.IsTestDataPresent(t, path) {
+		test_structure.LoadTestData(t, path, value)
+	}
+	if StageSkipped("destroy") {
+		t.Cleanup(func() {
+			test_structure.SaveTestData(t, path, true, *value)
+		})
+	}
+}
+
+// StageUniqueID returns a unique ID for the names a test creates, the one a
+// previous run kept with SKIP_destroy when SKIP_apply is set. Tests with a
+// TestConfig use its UniqueID, which is kept the same way
+func StageUniqueID(t *testing.T) string {
+	id := strings.ToLower(random.UniqueId())
+	keepStageValue(t, "UniqueID", &id)
+	return id
+}
+
+// restoreKeptOptionsE replaces options with those a previous run kept for the
+// same fixture, so the test works on that run's deployment. The logger cannot
+// be serialized, so it is reset to RedactingLogger. Returns false when
+// nothing was kept
+func restoreKeptOptionsE(t *testing.T, options *terraform.Options) bool {
+	dir := keptOptionsDir(t, options.TerraformDir)
+	if !test_structure.IsTestDataPresent(t, test_structure.FormatTestDataPath(dir, "TerraformOptions.json")) {
+		return false
+	}
+
+	kept := test_structure.LoadTerraformOptions(t, dir)
+	if _, err := os.Stat(kept.TerraformDir); err != nil {
+		t.Fatalf("The deployment kept in %s used %s, which is gone; run %s again without SKIP_apply: %v", dir, kept.TerraformDir, t.Name(), err)
+	}
+	kept.Logger = RedactingLogger
+	*options = *kept
+	t.Logf("SKIP_apply is set, reusing the deployment kept in %s", dir)
+	return true
+}
+
+// restoreKeptOptions is restoreKeptOptionsE failing the test when nothing
+// was kept, since there would be nothing to validate
+func restoreKeptOptions(t *testing.T, options *terraform.Options) {
+	if !restoreKeptOptionsE(t, options) {
+		t.Fatalf("SKIP_apply is set but no previous run kept %s's %s deployment; run it once with SKIP_destroy=true",
+			t.Name(), filepath.Base(options.TerraformDir))
+	}
+}
+
+// keepOptions saves options once the test ends when SKIP_destroy is set, so
+// later runs can reuse the deployment with SKIP_apply
+func keepOptions(t *testing.T, options *terraform.Options) {
+	if !StageSkipped("destroy") {
+		return
+	}
+	dir := keptOptionsDir(t, options.TerraformDir)
+	t.Cleanup(func() {
+		test_structure.SaveTerraformOptions(t, dir, options)
+		t.Logf("SKIP_destroy is set, keeping the deployment of %s; reuse it with SKIP_apply=true", dir)
+	})
+}
+
+// applyStage runs apply as the apply stage. With SKIP_apply, options are
+// replaced by those a previous run kept with SKIP_destroy instead
+func applyStage(t *testing.T, options *terraform.Options, apply func()) {
+	if StageSkipped("apply") {
+		restoreKeptOptions(t, options)
+	}
+	test_structure.RunTestStage(t, "apply", apply)
+	keepOptions(t, options)
+}
+
+// initStage runs terraform init as the init stage, which SKIP_init skips for
+// fixtures a previous run already initialized
+func initStage(t *testing.T, options *terraform.Options) {
+	test_structure.RunTestStage(t, "init", func() {
+		terraform.Init(t, options)
+	})
+}
+
+// StartValidation marks where a test starts validating what it deployed,
+// the validate stage: with SKIP_validate the test skips from there, after
+// running its deferred destroy. Tests that track phases get it by starting
+// the verify phase
+func StartValidation(t *testing.T) {
+	if StageSkipped("validate") {
+		t.Skip("SKIP_validate is set, skipping validation")
+	}
+}
+
+// Destroy runs terraform destroy as the destroy stage. SKIP_destroy keeps the
+// deployment for later runs to reuse with SKIP_apply; once destroyed, what a
+// previous run kept of it is removed
+func Destroy(t *testing.T, options *terraform.Options) {
+	test_structure.RunTestStage(t, "destroy", func() {
+		terraform.Destroy(t, options)
+		if err := os.RemoveAll(keptOptionsDir(t, options.TerraformDir)); err != nil {
+			t.Logf("Removing the kept options of %s: %v", options.TerraformDir, err)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStageSkipped(t *testing.T) {
+	t.Setenv("SKIP_apply", "true")
+	t.Setenv("SKIP_destroy", "")
+
+	assert.True(t, StageSkipped("apply"))
+	assert.False(t, StageSkipped("destroy"))
+	assert.False(t, StageSkipped("validate"))
+}
+
+func TestKeepStageValue(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(stageRoot)) })
+	t.Setenv("SKIP_apply", "")
+	t.Setenv("SKIP_destroy", "true")
+
+	t.Run("keep", func(t *testing.T) {
+		id := "first"
+		keepStageValue(t, "UniqueID", &id)
+		// Kept as it is when the test ends, not when it is registered
+		id = "fallback"
+	})
+
+	var kept string
+	test_structure.LoadTestData(t, test_structure.FormatTestDataPath(
+		filepath.Join(stageRoot, WorkspaceName("", t.Name()+"/keep")), "UniqueID.json"), &kept)
+	assert.Equal(t, "fallback", kept)
+
+	t.Setenv("SKIP_apply", "true")
+	t.Setenv("SKIP_destroy", "")
+	test_structure.SaveTestData(t, test_structure.FormatTestDataPath(stageDir(t), "UniqueID.json"), true, "reused")
+	id := "new"
+	keepStageValue(t, "UniqueID", &id)
+	assert.Equal(t, "reused", id)
+}
+
+func TestRestoreKeptOptions(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(stageRoot)) })
+
+	options := &terraform.Options{TerraformDir: "./fixtures/container-app-public"}
+	assert.False(t, restoreKeptOptionsE(t, options), "nothing was kept yet")
+
+	copied := filepath.Join(t.TempDir(), "terraform", "tests", "fixtures", "container-app-public")
+	if err := os.MkdirAll(copied, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	test_structure.SaveTerraformOptions(t, keptOptionsDir(t, copied), &terraform.Options{
+		TerraformDir: copied,
+		Vars:         map[string]interface{}{"name_suffix": "kept"},
+	})
+
+	if assert.True(t, restoreKeptOptionsE(t, options)) {
+		assert.Equal(t, copied, options.TerraformDir)
+		assert.Equal(t, "kept", options.Vars["name_suffix"])
+		assert.NotNil(t, options.Logger, "the logger should be reset")
+	}
+}
@@ -0,0 +1,308 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// stateContains reports whether address (a resource, resource instance or
+// module) covers the resource instance entry from `terraform state list`
+func stateContains(entry, address string) bool {
+	return entry == address || strings.HasPrefix(entry, address+".") || strings.HasPrefix(entry, address+"[")
+}
+
+// checkStateRemove returns an error unless every address covers something in
+// state and at least one resource instance is left behind
+func checkStateRemove(state, addresses []string) error {
+	if len(addresses) == 0 {
+		return fmt.Errorf("no addresses to remove")
+	}
+
+	removed := map[string]bool{}
+	for _, address := range addresses {
+		found := false
+		for _, entry := range state {
+			if stateContains(entry, address) {
+				removed[entry] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s is not in state", address)
+		}
+	}
+	if len(removed) == len(state) {
+		return fmt.Errorf("removing %s would empty the state; destroy instead", strings.Join(addresses, ", "))
+	}
+	return nil
+}
+
+// checkStateMove returns an error unless from is in state and to is free
+func checkStateMove(state []string, from, to string) error {
+	fromFound := false
+	for _, entry := range state {
+		if stateContains(entry, to) {
+			return fmt.Errorf("%s is already in state", to)
+		}
+		fromFound = fromFound || stateContains(entry, from)
+	}
+	if !fromFound {
+		return fmt.Errorf("%s is not in state", from)
+	}
+	return nil
+}
+
+// checkSurgeryAllowed refuses state surgery on a root module inside the
+// repository, whose state could belong to a developer or another test.
+// Fixtures must be copied with UseIsolatedWorkspace or CopyModuleToTemp first
+func checkSurgeryAllowed(options *terraform.Options) error {
+	root, err := filepath.Abs("..")
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(options.TerraformDir)
+	if err != nil {
+		return err
+	}
+	if relative, err := filepath.Rel(root, dir); err == nil && !strings.HasPrefix(relative, "..") {
+		return fmt.Errorf("%s is inside the repository; use UseIsolatedWorkspace or CopyModuleToTemp before state surgery", options.TerraformDir)
+	}
+	return nil
+}
+
+// StateListE returns the resource instance addresses in state
+func StateListE(t *testing.T, options *terraform.Options) ([]string, error) {
+	output, err := terraform.RunTerraformCommandAndGetStdoutE(t, quietOptions(options), "state", "list")
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			addresses = append(addresses, line)
+		}
+	}
+	return addresses, nil
+}
+
+// backupStateE pulls the current state into a file next to the root module
+// so a test that went wrong can be inspected or restored by hand
+func backupStateE(t *testing.T, options *terraform.Options) (string, error) {
+	state, err := terraform.RunTerraformCommandAndGetStdoutE(t, quietOptions(options), "state", "pull")
+	if err != nil {
+		return "", err
+	}
+	backup, err := os.CreateTemp(options.TerraformDir, "state-backup-*.tfstate")
+	if err != nil {
+		return "", err
+	}
+	defer backup.Close()
+	if _, err := backup.WriteString(state); err != nil {
+		return "", err
+	}
+	return backup.Name(), nil
+}
+
+// prepareSurgeryE runs the guard rails shared by state operations and
+// returns the state before the change
+func prepareSurgeryE(t *testing.T, options *terraform.Options, operation string) ([]string, error) {
+	if err := checkSurgeryAllowed(options); err != nil {
+		return nil, err
+	}
+	state, err := StateListE(t, options)
+	if err != nil {
+		return nil, fmt.Errorf("listing state before %s: %w", operation, err)
+	}
+	backup, err := backupStateE(t, options)
+	if err != nil {
+		return nil, fmt.Errorf("backing up state before %s: %w", operation, err)
+	}
+	t.Logf("State surgery (%s) on %s; previous state saved to %s", operation, options.TerraformDir, backup)
+	return state, nil
+}
+
+// StateRemoveE removes addresses from state without destroying them, e.g. to
+// simulate a resource terraform lost track of. Every address must be in
+// state, and removing everything is refused
+func StateRemoveE(t *testing.T, options *terraform.Options, addresses ...string) error {
+	state, err := prepareSurgeryE(t, options, "state rm")
+	if err != nil {
+		return err
+	}
+	if err := checkStateRemove(state, addresses); err != nil {
+		return err
+	}
+
+	t.Logf("Removing from state: %s", strings.Join(addresses, ", "))
+	_, err = terraform.RunTerraformCommandE(t, options, append([]string{"state", "rm"}, addresses...)...)
+	return err
+}
+
+// StateRemove removes addresses from state and fails the test on error
+func StateRemove(t *testing.T, options *terraform.Options, addresses ...string) {
+	if err := StateRemoveE(t, options, addresses...); err != nil {
+		t.Fatalf("Removing %v from state: %v", addresses, err)
+	}
+}
+
+// StateMoveE moves a resource or module to a new address in state, e.g. to
+// check that a moved block or renamed module plans no changes. from must be
+// in state and to must not be
+func StateMoveE(t *testing.T, options *terraform.Options, from, to string) error {
+	state, err := prepareSurgeryE(t, options, "state mv")
+	if err != nil {
+		return err
+	}
+	if err := checkStateMove(state, from, to); err != nil {
+		return err
+	}
+
+	t.Logf("Moving in state: %s -> %s", from, to)
+	_, err = terraform.RunTerraformCommandE(t, options, "state", "mv", from, to)
+	return err
+}
+
+// StateMove moves an address in state and fails the test on error
+func StateMove(t *testing.T, options *terraform.Options, from, to string) {
+	if err := StateMoveE(t, options, from, to); err != nil {
+		t.Fatalf("Moving %s to %s in state: %v", from, to, err)
+	}
+}
+
+// targetCheckPlanFile is the full plan made after a targeted operation
+const targetCheckPlanFile = "after-target.tfplan"
+
+// targetViolations returns the changes of a full plan, made after a targeted
+// operation, showing that the operation left the stack inconsistent: any
+// update, replacement or deletion, and any change at all to resources covered
+// by applied, the targets of an apply. Creates of other resources are what a
+// targeted operation leaves for a later apply, e.g. after a targeted destroy
+func targetViolations(changes []planResourceChange, applied []string) []string {
+	var violations []string
+	for _, change := range changes {
+		actions := strings.Join(change.Change.Actions, ",")
+		if actions == "no-op" || actions == "read" {
+			continue
+		}
+		if actions != "create" {
+			violations = append(violations, fmt.Sprintf("%s would be changed (%s)", change.Address, actions))
+			continue
+		}
+		for _, target := range applied {
+			if stateContains(change.Address, target) {
+				violations = append(violations, fmt.Sprintf("%s is still missing after applying %s", change.Address, target))
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// checkAfterTargetE plans options in full, without targets, and returns an
+// error listing the targetViolations of the plan. Creates it leaves are logged
+func checkAfterTargetE(t *testing.T, options *terraform.Options, applied []string) error {
+	full := *options
+	full.Targets = nil
+	full.PlanFilePath = filepath.Join(options.TerraformDir, targetCheckPlanFile)
+	if _, err := terraform.PlanE(t, &full); err != nil {
+		return fmt.Errorf("full plan after targeting: %w", err)
+	}
+	planJSON, err := terraform.ShowE(t, &full)
+	if err != nil {
+		return fmt.Errorf("full plan after targeting: %w", err)
+	}
+	changes, err := planResourceChangesE(planJSON)
+	if err != nil {
+		return err
+	}
+
+	if violations := targetViolations(changes, applied); len(violations) > 0 {
+		return fmt.Errorf("targeting left the stack inconsistent:\n  %s", strings.Join(violations, "\n  "))
+	}
+	for _, change := range changes {
+		if strings.Join(change.Change.Actions, ",") == "create" {
+			t.Logf("Left for a full apply: %s", change.Address)
+		}
+	}
+	return nil
+}
+
+// warnTargeting logs why a targeted operation is unusual, so the log of a
+// test that goes wrong afterwards says where to look first
+func warnTargeting(t *testing.T, operation string, options *terraform.Options, targets []string) {
+	t.Logf("WARNING: %s of only %s on %s. Terraform skips everything else, "+
+		"so a full plan follows to check the stack is still consistent",
+		operation, strings.Join(targets, ", "), options.TerraformDir)
+}
+
+// ApplyTargetE applies only targets and their dependencies, e.g. to recreate
+// a child resource removed out of band without touching the rest. A full
+// plan follows and must show nothing left to do for targets and no update,
+// replacement or deletion anywhere; only creates of other resources are
+// allowed. options is not modified
+func ApplyTargetE(t *testing.T, options *terraform.Options, targets ...string) (string, error) {
+	if len(targets) == 0 {
+		return "", fmt.Errorf("no targets to apply")
+	}
+	if err := checkSurgeryAllowed(options); err != nil {
+		return "", err
+	}
+
+	targeted := *options
+	targeted.Targets = targets
+	warnTargeting(t, "Targeted apply", options, targets)
+	output, err := terraform.ApplyE(t, &targeted)
+	if err != nil {
+		return output, err
+	}
+	return output, checkAfterTargetE(t, options, targets)
+}
+
+// ApplyTarget applies only targets and fails the test on error
+func ApplyTarget(t *testing.T, options *terraform.Options, targets ...string) string {
+	output, err := ApplyTargetE(t, options, targets...)
+	if err != nil {
+		t.Fatalf("Targeted apply of %v: %v", targets, err)
+	}
+	return output
+}
+
+// DestroyTargetE destroys only targets and the resources that depend on
+// them, e.g. to recreate the container app of a stack while keeping its
+// environment. Every target must be in state, and destroying everything is
+// refused. A full plan follows and may only create resources again; an
+// update, replacement or deletion means the stack was left inconsistent.
+// options is not modified
+func DestroyTargetE(t *testing.T, options *terraform.Options, targets ...string) (string, error) {
+	state, err := prepareSurgeryE(t, options, "destroy -target")
+	if err != nil {
+		return "", err
+	}
+	if err := checkStateRemove(state, targets); err != nil {
+		return "", err
+	}
+
+	targeted := *options
+	targeted.Targets = targets
+	warnTargeting(t, "Targeted destroy", options, targets)
+	output, err := terraform.DestroyE(t, &targeted)
+	if err != nil {
+		return output, err
+	}
+	return output, checkAfterTargetE(t, options, nil)
+}
+
+// DestroyTarget destroys only targets and fails the test on error
+func DestroyTarget(t *testing.T, options *terraform.Options, targets ...string) string {
+	output, err := DestroyTargetE(t, options, targets...)
+	if err != nil {
+		t.Fatalf("Targeted destroy of %v: %v", targets, err)
+	}
+	return output
+}
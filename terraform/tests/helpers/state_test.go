@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+var surgeryState = []string{
+	"data.azurerm_client_config.current",
+	"module.key_vault.azurerm_key_vault.this",
+	`module.key_vault.azurerm_key_vault_secret.secrets["app"]`,
+	`module.key_vault.azurerm_key_vault_secret.secrets["db"]`,
+	"module.resource_group.azurerm_resource_group.this",
+}
+
+func TestCheckStateRemove(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, checkStateRemove(surgeryState, []string{`module.key_vault.azurerm_key_vault_secret.secrets["app"]`}))
+	assert.NoError(t, checkStateRemove(surgeryState, []string{"module.key_vault.azurerm_key_vault_secret.secrets"}),
+		"A resource covers all its instances")
+	assert.NoError(t, checkStateRemove(surgeryState, []string{"module.key_vault"}), "A module covers its resources")
+
+	assert.Error(t, checkStateRemove(surgeryState, nil))
+	assert.Error(t, checkStateRemove(surgeryState, []string{"module.key_vault.azurerm_key_vault_secret.other"}))
+	assert.Error(t, checkStateRemove(surgeryState, []string{"module.key"}), "Prefixes of names are not modules")
+	assert.Error(t, checkStateRemove(surgeryState, []string{"data.azurerm_client_config.current", "module.key_vault", "module.resource_group"}),
+		"Emptying the state is refused")
+}
+
+func TestCheckStateMove(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, checkStateMove(surgeryState, "module.key_vault", "module.vault"))
+	assert.Error(t, checkStateMove(surgeryState, "module.vault", "module.key_vault"))
+	assert.Error(t, checkStateMove(surgeryState, "module.key_vault", "module.resource_group"), "Destination in use")
+}
+
+func TestCheckSurgeryAllowed(t *testing.T) {
+	t.Parallel()
+
+	// Unit tests run in helpers/, so the repository root they check is tests/
+	assert.Error(t, checkSurgeryAllowed(&terraform.Options{TerraformDir: "../fixtures/tag-update"}))
+	assert.Error(t, checkSurgeryAllowed(&terraform.Options{TerraformDir: "."}))
+	assert.NoError(t, checkSurgeryAllowed(&terraform.Options{TerraformDir: t.TempDir()}))
+}
+
+func TestTargetViolations(t *testing.T) {
+	t.Parallel()
+
+	changes, err := planResourceChangesE(`{"resource_changes": [
+  {"address": "data.azurerm_client_config.current", "change": {"actions": ["read"]}},
+  {"address": "module.resource_group.azurerm_resource_group.this", "change": {"actions": ["no-op"]}},
+  {"address": "module.container_app.azurerm_container_app.this", "change": {"actions": ["create"]}},
+  {"address": "module.container_app.azurerm_role_assignment.acr_pull[0]", "change": {"actions": ["create"]}}
+]}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, targetViolations(changes, nil), "creates are left for a later apply")
+	assert.Equal(t, []string{"module.container_app.azurerm_container_app.this is still missing after applying module.container_app"},
+		targetViolations(changes[:3], []string{"module.container_app"}))
+	assert.Empty(t, targetViolations(changes, []string{"module.other"}))
+
+	changes, err = planResourceChangesE(`{"resource_changes": [
+  {"address": "module.container_app.azurerm_container_app_environment.this", "change": {"actions": ["delete", "create"]}},
+  {"address": "module.resource_group.azurerm_resource_group.this", "change": {"actions": ["update"]}}
+]}`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"module.container_app.azurerm_container_app_environment.this would be changed (delete,create)",
+			"module.resource_group.azurerm_resource_group.this would be changed (update)",
+		}, targetViolations(changes, nil))
+	}
+}
@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// SandboxSubscription names the subscription destructive and expensive
+// tests run in, away from the one shared with everyday runs (see
+// NewTestConfigIn)
+const SandboxSubscription = "sandbox"
+
+// subscriptionIDPattern matches a subscription ID, a GUID
+var subscriptionIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// subscriptionEnvVar returns the variable holding the ID of the subscription
+// named name, e.g. ARM_SUBSCRIPTION_ID_SANDBOX
+func subscriptionEnvVar(name string) string {
+	return "ARM_SUBSCRIPTION_ID_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// subscriptionIDE returns the ID of the subscription named name, read with
+// getenv, or an empty string when its variable is not set
+func subscriptionIDE(getenv func(string) string, name string) (string, error) {
+	variable := subscriptionEnvVar(name)
+	subscriptionID := strings.TrimSpace(getenv(variable))
+	if subscriptionID != "" && !subscriptionIDPattern.MatchString(subscriptionID) {
+		return "", fmt.Errorf("%s is %q, which is not a subscription ID", variable, subscriptionID)
+	}
+	return subscriptionID, nil
+}
+
+// NewTestConfigIn is NewTestConfig for a test that runs in the subscription
+// named name, such as SandboxSubscription, whose ID is in
+// ARM_SUBSCRIPTION_ID_<NAME>. Without it the test runs in the run's own
+// subscription, as it would with NewTestConfig. The run's identity must be
+// able to deploy to the subscription; the test fails early when it cannot
+// see it. Pass the config's SubscriptionID to terratest's azure helpers and
+// build terraform options with TerraformOptions
+func NewTestConfigIn(t *testing.T, name string) *TestConfig {
+	subscriptionID, err := subscriptionIDE(os.Getenv, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscriptionID == "" {
+		t.Logf("%s is not set, running in the run's subscription", subscriptionEnvVar(name))
+	} else if _, err := AzCLIE(t, "account", "show", "--subscription", subscriptionID, "--query", "id", "--output", "tsv"); err != nil {
+		t.Fatalf("The run's identity cannot see the %s subscription %s from %s: %v", name, subscriptionID, subscriptionEnvVar(name), err)
+	}
+
+	config := newTestConfigIn(t, subscriptionID)
+	keepStageValue(t, "UniqueID", &config.UniqueID)
+	keepStageValue(t, "Location", &config.Location)
+	return config
+}
+
+// TerraformOptions is DefaultTerraformOptions deploying to the config's
+// subscription (see useSubscriptionE)
+func (c *TestConfig) TerraformOptions(t *testing.T, terraformDir string, vars map[string]interface{}) *terraform.Options {
+	options := DefaultTerraformOptions(t, terraformDir, vars)
+	if err := useSubscriptionE(options, c.SubscriptionID); err != nil {
+		t.Fatalf("Targeting subscription %s: %v", c.SubscriptionID, err)
+	}
+	return options
+}
+
+// useSubscriptionE points the azurerm provider of options at subscriptionID
+// through ARM_SUBSCRIPTION_ID, and sets the configuration's subscription_id
+// variable when it declares one and it is not already set, as the
+// environments do
+func useSubscriptionE(options *terraform.Options, subscriptionID string) error {
+	if options.EnvVars == nil {
+		options.EnvVars = map[string]string{}
+	}
+	options.EnvVars["ARM_SUBSCRIPTION_ID"] = subscriptionID
+
+	declared, err := declaresVariableE(options.TerraformDir, "subscription_id")
+	if err != nil {
+		return err
+	}
+	if declared {
+		if options.Vars == nil {
+			options.Vars = map[string]interface{}{}
+		}
+		if _, set := options.Vars["subscription_id"]; !set {
+			options.Vars["subscription_id"] = subscriptionID
+		}
+	}
+	return nil
+}
+
+// declaresVariableE reports whether the configuration in dir declares the
+// input variable name
+func declaresVariableE(dir, name string) (bool, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return false, err
+	}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return false, diags
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			return false, fmt.Errorf("%s is not native HCL syntax", file)
+		}
+		for _, block := range body.Blocks {
+			if block.Type == "variable" && len(block.Labels) == 1 && block.Labels[0] == name {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
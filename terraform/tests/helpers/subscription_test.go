@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSandboxSubscriptionID = "00000000-0000-0000-0000-00000000abcd"
+
+func TestSubscriptionID(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "ARM_SUBSCRIPTION_ID_LOAD_TEST", subscriptionEnvVar("load-test"))
+
+	subscriptionID, err := subscriptionIDE(getenvFrom(map[string]string{"ARM_SUBSCRIPTION_ID_SANDBOX": testSandboxSubscriptionID}), SandboxSubscription)
+	if assert.NoError(t, err) {
+		assert.Equal(t, testSandboxSubscriptionID, subscriptionID)
+	}
+
+	subscriptionID, err = subscriptionIDE(getenvFrom(nil), SandboxSubscription)
+	if assert.NoError(t, err) {
+		assert.Empty(t, subscriptionID, "without the variable the test stays in the run's subscription")
+	}
+
+	_, err = subscriptionIDE(getenvFrom(map[string]string{"ARM_SUBSCRIPTION_ID_SANDBOX": "sandbox-sub"}), SandboxSubscription)
+	assert.Error(t, err, "a subscription name rather than its ID")
+}
+
+func TestUseSubscription(t *testing.T) {
+	t.Parallel()
+
+	fixture := t.TempDir()
+	environment := t.TempDir()
+	if err := os.WriteFile(filepath.Join(environment, "variables.tf"), []byte(`variable "subscription_id" {
+  type    = string
+  default = null
+}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	options := &terraform.Options{TerraformDir: fixture, EnvVars: map[string]string{"ARM_USE_CLI": "true"}}
+	if assert.NoError(t, useSubscriptionE(options, testSandboxSubscriptionID)) {
+		assert.Equal(t, map[string]string{"ARM_USE_CLI": "true", "ARM_SUBSCRIPTION_ID": testSandboxSubscriptionID}, options.EnvVars)
+		assert.Empty(t, options.Vars, "a configuration without subscription_id would reject the variable")
+	}
+
+	options = &terraform.Options{TerraformDir: environment}
+	if assert.NoError(t, useSubscriptionE(options, testSandboxSubscriptionID)) {
+		assert.Equal(t, testSandboxSubscriptionID, options.Vars["subscription_id"])
+	}
+
+	options = &terraform.Options{TerraformDir: environment, Vars: map[string]interface{}{"subscription_id": "chosen-by-the-test"}}
+	if assert.NoError(t, useSubscriptionE(options, testSandboxSubscriptionID)) {
+		assert.Equal(t, "chosen-by-the-test", options.Vars["subscription_id"])
+	}
+}
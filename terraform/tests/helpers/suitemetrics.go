@@ -0,0 +1,177 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SuiteMetricsTable is the custom Log Analytics table test results are
+// exported to, and SuiteMetricsStream the data collection rule stream that
+// feeds it (see fixtures/suite-metrics)
+const (
+	SuiteMetricsTable  = "TerraformTestResults_CL"
+	SuiteMetricsStream = "Custom-TerraformTestResults"
+)
+
+// logsIngestionAPIVersion is the Logs Ingestion API version uploads use
+const logsIngestionAPIVersion = "2023-01-01"
+
+// maxIngestionBatchBytes keeps each upload under the API's 1 MB limit
+const maxIngestionBatchBytes = 900 * 1024
+
+// SuiteMetricsColumn is a column of the custom table and of the stream
+type SuiteMetricsColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// SuiteMetricsColumns are the columns of SuiteMetricsTable, in the order of
+// SuiteMetricRecord. fixtures/suite-metrics/columns.json must list the same
+var SuiteMetricsColumns = []SuiteMetricsColumn{
+	{Name: "TimeGenerated", Type: "datetime"},
+	{Name: "RunId", Type: "string"},
+	{Name: "Namespace", Type: "string"},
+	{Name: "Test", Type: "string"},
+	{Name: "Status", Type: "string"},
+	{Name: "DurationSeconds", Type: "real"},
+	{Name: "Issue", Type: "string"},
+}
+
+// SuiteMetricRecord is one test's outcome in a run, as a row of
+// SuiteMetricsTable
+type SuiteMetricRecord struct {
+	TimeGenerated   string  `json:"TimeGenerated"`
+	RunID           string  `json:"RunId"`
+	Namespace       string  `json:"Namespace"`
+	Test            string  `json:"Test"`
+	Status          string  `json:"Status"`
+	DurationSeconds float64 `json:"DurationSeconds"`
+	Issue           string  `json:"Issue"`
+}
+
+// SuiteMetricRecords returns a row per test of summary, sorted by test name,
+// all generated at the time the run finished
+func SuiteMetricRecords(summary *RunSummary, namespace string, finished time.Time) []SuiteMetricRecord {
+	tests := make([]string, 0, len(summary.Tests))
+	for name := range summary.Tests {
+		tests = append(tests, name)
+	}
+	sort.Strings(tests)
+
+	generated := finished.UTC().Format(time.RFC3339)
+	records := make([]SuiteMetricRecord, 0, len(tests))
+	for _, name := range tests {
+		outcome := summary.Tests[name]
+		records = append(records, SuiteMetricRecord{
+			TimeGenerated:   generated,
+			RunID:           summary.RunID,
+			Namespace:       namespace,
+			Test:            name,
+			Status:          outcome.Status,
+			DurationSeconds: outcome.DurationSeconds,
+			Issue:           outcome.Issue,
+		})
+	}
+	return records
+}
+
+// AccessTokenSource returns a bearer token for the Logs Ingestion API
+type AccessTokenSource func() (string, error)
+
+// LogsIngestionClient uploads records to a data collection rule's stream
+// through the Logs Ingestion API. The identity behind Token needs the
+// Monitoring Metrics Publisher role on the rule
+type LogsIngestionClient struct {
+	// Endpoint is the logs ingestion endpoint of the data collection
+	// endpoint, e.g. https://dce-x.eastus2-1.ingest.monitor.azure.com
+	Endpoint string
+	// RuleID is the immutable ID of the data collection rule (dcr-...)
+	RuleID string
+	// Stream is the rule's stream, SuiteMetricsStream when empty
+	Stream string
+	Token  AccessTokenSource
+	// HTTPClient is http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// UploadE posts records in batches under the API's size limit and returns
+// how many batches were sent. A rejected batch stops the upload; batches
+// before it stay ingested
+func (c *LogsIngestionClient) UploadE(records []SuiteMetricRecord) (int, error) {
+	if c.Endpoint == "" || c.RuleID == "" {
+		return 0, errors.New("logs ingestion needs a data collection endpoint and rule ID")
+	}
+	batches, err := ingestionBatchesE(records, maxIngestionBatchBytes)
+	if err != nil {
+		return 0, err
+	}
+	token, err := c.Token()
+	if err != nil {
+		return 0, fmt.Errorf("getting a Logs Ingestion API token: %w", err)
+	}
+
+	stream := c.Stream
+	if stream == "" {
+		stream = SuiteMetricsStream
+	}
+	endpoint := fmt.Sprintf("%s/dataCollectionRules/%s/streams/%s?api-version=%s",
+		strings.TrimRight(c.Endpoint, "/"), url.PathEscape(c.RuleID), url.PathEscape(stream), logsIngestionAPIVersion)
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	for i, batch := range batches {
+		request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(batch))
+		if err != nil {
+			return i, err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+		request.Header.Set("Content-Type", "application/json")
+		response, err := client.Do(request)
+		if err != nil {
+			return i, fmt.Errorf("uploading batch %d of %d: %w", i+1, len(batches), err)
+		}
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		response.Body.Close()
+		if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+			return i, fmt.Errorf("uploading batch %d of %d: %d %s", i+1, len(batches), response.StatusCode, strings.TrimSpace(string(body)))
+		}
+	}
+	return len(batches), nil
+}
+
+// ingestionBatchesE encodes records as JSON arrays of at most limit bytes
+func ingestionBatchesE(records []SuiteMetricRecord, limit int) ([][]byte, error) {
+	var batches [][]byte
+	current := []byte{'['}
+	for _, record := range records {
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		if len(encoded)+2 > limit {
+			return nil, fmt.Errorf("record of %s is over the %d byte batch limit", record.Test, limit)
+		}
+		if len(current) > 1 && len(current)+len(encoded)+2 > limit {
+			batches = append(batches, append(current, ']'))
+			current = []byte{'['}
+		}
+		if len(current) > 1 {
+			current = append(current, ',')
+		}
+		current = append(current, encoded...)
+	}
+	if len(current) > 1 {
+		batches = append(batches, append(current, ']'))
+	}
+	return batches, nil
+}
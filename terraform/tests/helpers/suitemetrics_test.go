@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuiteMetricRecords(t *testing.T) {
+	t.Parallel()
+
+	summary := &RunSummary{RunID: "run-42", Tests: map[string]TestOutcome{
+		"TestVault": {Status: "pass", DurationSeconds: 80.1},
+		"TestApp":   {Status: StatusExpectedFailure, DurationSeconds: 300, Issue: "#12"},
+	}}
+	finished := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, []SuiteMetricRecord{
+		{TimeGenerated: "2026-03-01T11:00:00Z", RunID: "run-42", Namespace: "ci", Test: "TestApp", Status: StatusExpectedFailure, DurationSeconds: 300, Issue: "#12"},
+		{TimeGenerated: "2026-03-01T11:00:00Z", RunID: "run-42", Namespace: "ci", Test: "TestVault", Status: "pass", DurationSeconds: 80.1},
+	}, SuiteMetricRecords(summary, "ci", finished))
+}
+
+func TestSuiteMetricsColumnsMatchRecord(t *testing.T) {
+	t.Parallel()
+
+	record := reflect.TypeOf(SuiteMetricRecord{})
+	if !assert.Equal(t, record.NumField(), len(SuiteMetricsColumns)) {
+		return
+	}
+	for i, column := range SuiteMetricsColumns {
+		assert.Equal(t, column.Name, record.Field(i).Tag.Get("json"), "column %d", i)
+	}
+}
+
+func TestIngestionBatchesE(t *testing.T) {
+	t.Parallel()
+
+	records := []SuiteMetricRecord{{Test: "TestA"}, {Test: "TestB"}, {Test: "TestC"}}
+	one, err := json.Marshal(records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Room for two records and their separators, not three
+	batches, err := ingestionBatchesE(records, 2*len(one)+3)
+	if assert.NoError(t, err) && assert.Len(t, batches, 2) {
+		var first, second []SuiteMetricRecord
+		assert.NoError(t, json.Unmarshal(batches[0], &first))
+		assert.NoError(t, json.Unmarshal(batches[1], &second))
+		assert.Equal(t, records, append(first, second...))
+	}
+
+	batches, err = ingestionBatchesE(nil, 100)
+	assert.NoError(t, err)
+	assert.Empty(t, batches, "nothing to upload is no request")
+
+	_, err = ingestionBatchesE(records, len(one))
+	assert.ErrorContains(t, err, "over the")
+}
+
+func TestLogsIngestionClientUploadE(t *testing.T) {
+	t.Parallel()
+
+	var uploaded []SuiteMetricRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/dataCollectionRules/dcr-0123/streams/"+SuiteMetricsStream, r.URL.Path)
+		assert.Equal(t, logsIngestionAPIVersion, r.URL.Query().Get("api-version"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		var records []SuiteMetricRecord
+		assert.NoError(t, json.Unmarshal(body, &records))
+		uploaded = append(uploaded, records...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	records := []SuiteMetricRecord{{RunID: "run-42", Test: "TestApp", Status: "pass"}}
+	client := &LogsIngestionClient{
+		Endpoint: server.URL + "/",
+		RuleID:   "dcr-0123",
+		Token:    func() (string, error) { return "token", nil },
+	}
+	batches, err := client.UploadE(records)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, batches)
+		assert.Equal(t, records, uploaded)
+	}
+}
+
+func TestLogsIngestionClientUploadErrors(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":"InvalidStream"}}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	records := []SuiteMetricRecord{{Test: "TestApp"}}
+	token := func() (string, error) { return "token", nil }
+
+	_, err := (&LogsIngestionClient{Endpoint: server.URL, RuleID: "dcr-0123", Token: token}).UploadE(records)
+	assert.ErrorContains(t, err, "400")
+	assert.ErrorContains(t, err, "InvalidStream", "the API's reason should be in the error")
+
+	_, err = (&LogsIngestionClient{Endpoint: server.URL, Token: token}).UploadE(records)
+	assert.ErrorContains(t, err, "rule ID")
+
+	noToken := errors.New("not logged in")
+	_, err = (&LogsIngestionClient{Endpoint: server.URL, RuleID: "dcr-0123", Token: func() (string, error) { return "", noToken }}).UploadE(records)
+	assert.ErrorIs(t, err, noToken)
+}
@@ -0,0 +1,241 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/gruntwork-io/terratest/modules/shell"
+)
+
+const (
+	// NotationSignatureType is the artifact type of Notary Project signatures
+	NotationSignatureType = "application/vnd.cncf.notary.signature"
+	// SPDXSBOMType is the artifact type used for SPDX JSON SBOMs
+	SPDXSBOMType = "application/spdx+json"
+	// notationKeyName names the self-signed test key and its trust store
+	notationKeyName = "terratest"
+)
+
+// notationTrustPolicy trusts every signature made with the test key
+const notationTrustPolicy = `{
+  "version": "1.0",
+  "trustPolicies": [
+    {
+      "name": "terratest",
+      "registryScopes": ["*"],
+      "signatureVerification": {"level": "strict"},
+      "trustStore": "ca:` + notationKeyName + `",
+      "trustedIdentities": ["*"]
+    }
+  ]
+}
+`
+
+// SupplyChainTools runs notation and oras against a registry with their own
+// configuration, so signing keys and trust policies never touch the runner's
+type SupplyChainTools struct {
+	username string
+	password string
+	// configDir is XDG_CONFIG_HOME for notation
+	configDir string
+}
+
+// NewSupplyChainToolsE creates a self-signed notation test key trusted by a
+// test-only trust policy. Call LoginE before using a registry
+func NewSupplyChainToolsE(t *testing.T) (*SupplyChainTools, error) {
+	tools := &SupplyChainTools{configDir: t.TempDir()}
+	if _, err := tools.notationE(t, "cert", "generate-test", "--default", notationKeyName); err != nil {
+		return nil, fmt.Errorf("generating notation test key: %w", err)
+	}
+	policy := filepath.Join(tools.configDir, "notation", "trustpolicy.json")
+	if err := os.WriteFile(policy, []byte(notationTrustPolicy), 0o600); err != nil {
+		return nil, fmt.Errorf("writing %s: %w", policy, err)
+	}
+	return tools, nil
+}
+
+// NewSupplyChainTools prepares notation and oras and skips the test when
+// either tool is missing, so it can be called before anything is deployed
+func NewSupplyChainTools(t *testing.T) *SupplyChainTools {
+	for _, tool := range []string{"notation", "oras"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			SkipWithReason(t, SkipMissingEnv, fmt.Sprintf("%s is not installed: %v", tool, err))
+		}
+	}
+
+	tools, err := NewSupplyChainToolsE(t)
+	if err != nil {
+		t.Fatalf("Preparing supply chain tools: %v", err)
+	}
+	return tools
+}
+
+// LoginE gets credentials for the registry at loginServer for the runner's
+// Azure CLI identity
+func (s *SupplyChainTools) LoginE(t *testing.T, loginServer string) error {
+	auth, err := acrAuthenticatorE(t, loginServer)
+	if err != nil {
+		return err
+	}
+	credentials, err := auth.Authorization()
+	if err != nil {
+		return err
+	}
+	s.username, s.password = credentials.Username, credentials.Password
+	return nil
+}
+
+// notationE runs notation with the tools' own configuration directory
+func (s *SupplyChainTools) notationE(t *testing.T, args ...string) (string, error) {
+	return shell.RunCommandAndGetStdOutE(t, shell.Command{
+		Command: "notation",
+		Args:    args,
+		Env:     map[string]string{"XDG_CONFIG_HOME": s.configDir},
+		Logger:  RedactingLogger,
+	})
+}
+
+// orasE runs oras with the registry credentials
+func (s *SupplyChainTools) orasE(t *testing.T, args ...string) (string, error) {
+	return shell.RunCommandAndGetStdOutE(t, shell.Command{
+		Command: "oras",
+		Args:    append(args, "--username", s.username, "--password", s.password),
+		Logger:  RedactingLogger,
+	})
+}
+
+// SignE signs the image at reference, which must be pinned by digest, with
+// the test key
+func (s *SupplyChainTools) SignE(t *testing.T, reference string) error {
+	_, err := s.notationE(t, "sign", "--key", notationKeyName,
+		"--username", s.username, "--password", s.password, reference)
+	return err
+}
+
+// VerifyE verifies the signature of the image at reference against the test
+// trust policy
+func (s *SupplyChainTools) VerifyE(t *testing.T, reference string) error {
+	_, err := s.notationE(t, "verify", "--username", s.username, "--password", s.password, reference)
+	return err
+}
+
+// AttachSBOME attaches sbom to the image at reference as an SPDX artifact
+func (s *SupplyChainTools) AttachSBOME(t *testing.T, reference string, sbom []byte) error {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sbom.spdx.json"), sbom, 0o600); err != nil {
+		return err
+	}
+	// oras stores files by the path given, so attach from inside dir
+	_, err := shell.RunCommandAndGetStdOutE(t, shell.Command{
+		Command:    "oras",
+		Args:       []string{"attach", "--artifact-type", SPDXSBOMType, reference, "sbom.spdx.json:" + SPDXSBOMType, "--username", s.username, "--password", s.password},
+		WorkingDir: dir,
+		Logger:     RedactingLogger,
+	})
+	return err
+}
+
+// orasDiscovery is the JSON output of oras discover. oras 1.2 lists
+// referrers under "manifests", later versions under "referrers"
+type orasDiscovery struct {
+	Manifests []orasReferrer `json:"manifests"`
+	Referrers []orasReferrer `json:"referrers"`
+}
+
+type orasReferrer struct {
+	Digest       string `json:"digest"`
+	ArtifactType string `json:"artifactType"`
+}
+
+// DiscoverE lists the digests of artifacts of artifactType attached to the
+// image at reference, as oras sees them
+func (s *SupplyChainTools) DiscoverE(t *testing.T, reference, artifactType string) ([]string, error) {
+	output, err := s.orasE(t, "discover", "--format", "json", "--artifact-type", artifactType, reference)
+	if err != nil {
+		return nil, err
+	}
+	return parseOrasDiscovery(output, artifactType)
+}
+
+// parseOrasDiscovery returns the sorted digests of artifactType in the output
+// of oras discover
+func parseOrasDiscovery(output, artifactType string) ([]string, error) {
+	var discovery orasDiscovery
+	if err := json.Unmarshal([]byte(output), &discovery); err != nil {
+		return nil, fmt.Errorf("decoding oras discover output: %w", err)
+	}
+	var digests []string
+	for _, referrer := range append(discovery.Manifests, discovery.Referrers...) {
+		if referrer.ArtifactType == artifactType {
+			digests = append(digests, referrer.Digest)
+		}
+	}
+	sort.Strings(digests)
+	return digests, nil
+}
+
+// PullArtifactE pulls the artifact at reference and returns the content of
+// its only file
+func (s *SupplyChainTools) PullArtifactE(t *testing.T, reference string) ([]byte, error) {
+	dir := t.TempDir()
+	if _, err := s.orasE(t, "pull", "--output", dir, reference); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("artifact %s has %d files, expected 1", reference, len(entries))
+	}
+	return os.ReadFile(filepath.Join(dir, entries[0].Name()))
+}
+
+// ReferrerTypesE returns the artifact types of every manifest referring to
+// the image at reference through the registry's OCI referrers API, without
+// going through notation or oras
+func ReferrerTypesE(t *testing.T, reference string) (map[string]int, error) {
+	digest, err := name.NewDigest(reference)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := acrAuthenticatorE(t, digest.RegistryStr())
+	if err != nil {
+		return nil, err
+	}
+	index, err := remote.Referrers(digest, remote.WithAuth(auth))
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers of %s: %w", reference, err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	types := map[string]int{}
+	for _, descriptor := range manifest.Manifests {
+		types[descriptor.ArtifactType]++
+	}
+	return types, nil
+}
+
+// DigestReference pins an image reference to digest, e.g. for notation, which
+// refuses to sign tags
+func DigestReference(reference, digest string) string {
+	repository := reference
+	if at := strings.Index(repository, "@"); at >= 0 {
+		repository = repository[:at]
+	}
+	if colon := strings.LastIndex(repository, ":"); colon > strings.LastIndex(repository, "/") {
+		repository = repository[:colon]
+	}
+	return repository + "@" + digest
+}
@@ -0,0 +1,39 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestReference(t *testing.T) {
+	t.Parallel()
+
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	assert.Equal(t, "acr.azurecr.io/fixtures/echo@"+digest, DigestReference("acr.azurecr.io/fixtures/echo:amd64-abc", digest))
+	assert.Equal(t, "acr.azurecr.io/fixtures/echo@"+digest, DigestReference("acr.azurecr.io/fixtures/echo", digest))
+	assert.Equal(t, "localhost:5000/echo@"+digest, DigestReference("localhost:5000/echo", digest),
+		"A registry port is not a tag")
+	assert.Equal(t, "acr.azurecr.io/echo@"+digest, DigestReference("acr.azurecr.io/echo@sha256:other", digest))
+}
+
+func TestParseOrasDiscovery(t *testing.T) {
+	t.Parallel()
+
+	// oras 1.2 lists referrers under "manifests"
+	digests, err := parseOrasDiscovery(`{"manifests":[
+		{"digest":"sha256:b","artifactType":"application/spdx+json"},
+		{"digest":"sha256:c","artifactType":"application/vnd.cncf.notary.signature"},
+		{"digest":"sha256:a","artifactType":"application/spdx+json"}]}`, SPDXSBOMType)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sha256:a", "sha256:b"}, digests)
+
+	// Later versions use "referrers"
+	digests, err = parseOrasDiscovery(`{"referrers":[{"digest":"sha256:c","artifactType":"application/vnd.cncf.notary.signature"}]}`,
+		NotationSignatureType)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sha256:c"}, digests)
+
+	_, err = parseOrasDiscovery("Discovered 0 artifacts", SPDXSBOMType)
+	assert.Error(t, err)
+}
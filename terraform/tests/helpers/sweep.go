@@ -0,0 +1,121 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSweepPrefix is the name prefix of the resource groups tests create
+// (see TestConfig.GenerateResourceGroupName)
+const DefaultSweepPrefix = "rg-"
+
+// testGroupMarker is in the name of every resource group that
+// GenerateResourceGroupName makes, between the prefix and the namespace
+const testGroupMarker = "-test-"
+
+// StaleResourceGroup is a test resource group older than a sweep's maximum age
+type StaleResourceGroup struct {
+	Name      string `json:"name"`
+	Location  string `json:"location"`
+	CreatedAt string `json:"created_at"`
+	// Age is how long ago the group was created, when it was found; JSON
+	// readers have CreatedAt
+	Age time.Duration `json:"-"`
+}
+
+// sweepAz runs an Azure CLI command outside a test and returns its stdout
+type sweepAz func(args ...string) ([]byte, error)
+
+// azJSON runs the installed Azure CLI with JSON output
+func azJSON(args ...string) ([]byte, error) {
+	output, err := exec.Command("az", append(args, "--only-show-errors", "--output", "json")...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("az %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return output, err
+}
+
+// StaleResourceGroupsE lists the test resource groups of a subscription that
+// are at least maxAge old: groups named <prefix>*-test-*, as
+// GenerateResourceGroupName names them, whose CreatedAt tag is maxAge or more
+// in the past. Groups without a CreatedAt tag have no known age and are never
+// stale, since they may belong to a running test
+func StaleResourceGroupsE(subscriptionID, prefix string, maxAge time.Duration) ([]StaleResourceGroup, error) {
+	return staleResourceGroupsE(azJSON, SystemClock, subscriptionID, prefix, maxAge)
+}
+
+// SweepStaleResources deletes the test resource groups StaleResourceGroupsE
+// finds, without waiting for the deletions, and returns them. Failed test
+// runs leak their resource groups, and this cleans up after them whatever
+// namespace they ran in
+func SweepStaleResources(subscriptionID, prefix string, maxAge time.Duration) ([]StaleResourceGroup, error) {
+	return sweepStaleResourcesE(azJSON, SystemClock, subscriptionID, prefix, maxAge)
+}
+
+// staleResourceGroupsE is StaleResourceGroupsE running az and telling the
+// age on clock
+func staleResourceGroupsE(az sweepAz, clock Clock, subscriptionID, prefix string, maxAge time.Duration) ([]StaleResourceGroup, error) {
+	switch {
+	case subscriptionID == "":
+		return nil, errors.New("no subscription ID to sweep")
+	case prefix == "":
+		return nil, errors.New("sweeping needs a resource group name prefix")
+	case maxAge <= 0:
+		return nil, fmt.Errorf("maximum age %s must be positive, or running tests would be swept", maxAge)
+	}
+
+	output, err := az("group", "list", "--subscription", subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	var listed []struct {
+		Name     string            `json:"name"`
+		Location string            `json:"location"`
+		Tags     map[string]string `json:"tags"`
+	}
+	if err := json.Unmarshal(output, &listed); err != nil {
+		return nil, fmt.Errorf("decoding resource groups: %w", err)
+	}
+
+	stale := []StaleResourceGroup{}
+	for _, group := range listed {
+		if !strings.HasPrefix(group.Name, prefix) || !strings.Contains(group.Name[len(prefix):], testGroupMarker) {
+			continue
+		}
+		age, known := ResourceAge(group.Tags, clock)
+		if !known || age < maxAge {
+			continue
+		}
+		stale = append(stale, StaleResourceGroup{
+			Name:      group.Name,
+			Location:  group.Location,
+			CreatedAt: group.Tags[CreatedAtTag],
+			Age:       age,
+		})
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Name < stale[j].Name })
+	return stale, nil
+}
+
+// sweepStaleResourcesE is SweepStaleResources running az and telling the age
+// on clock. It returns the groups deleted before any failure
+func sweepStaleResourcesE(az sweepAz, clock Clock, subscriptionID, prefix string, maxAge time.Duration) ([]StaleResourceGroup, error) {
+	stale, err := staleResourceGroupsE(az, clock, subscriptionID, prefix, maxAge)
+	if err != nil {
+		return nil, err
+	}
+	deleted := []StaleResourceGroup{}
+	for _, group := range stale {
+		if _, err := az("group", "delete", "--subscription", subscriptionID, "--name", group.Name, "--yes", "--no-wait"); err != nil {
+			return deleted, fmt.Errorf("deleting %s: %w", group.Name, err)
+		}
+		deleted = append(deleted, group)
+	}
+	return deleted, nil
+}
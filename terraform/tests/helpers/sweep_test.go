@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sweepGroupList = `[
+  {"name": "rg-ca-https-test-jane-abc123", "location": "eastus2", "tags": {"CreatedAt": "2026-02-27T12:00:00Z"}},
+  {"name": "rg-kv-test-bob-def456", "location": "westus2", "tags": {"CreatedAt": "2026-02-28T12:00:00Z"}},
+  {"name": "rg-obs-test-ci-ghi789", "location": "eastus2", "tags": {"CreatedAt": "2026-03-01T11:00:00Z"}},
+  {"name": "rg-acr-test-untagged", "location": "eastus2", "tags": null},
+  {"name": "rg-terraform-state", "location": "eastus2", "tags": {"CreatedAt": "2025-01-01T00:00:00Z"}},
+  {"name": "rg-test-platform", "location": "eastus2", "tags": {"CreatedAt": "2025-01-01T00:00:00Z"}},
+  {"name": "prod-api-test-01", "location": "eastus2", "tags": {"CreatedAt": "2025-01-01T00:00:00Z"}}
+]`
+
+func TestStaleResourceGroups(t *testing.T) {
+	t.Parallel()
+
+	var deleted []string
+	az := func(args ...string) ([]byte, error) {
+		switch args[0] + " " + args[1] {
+		case "group list":
+			assert.Equal(t, []string{"group", "list", "--subscription", "sub"}, args)
+			return []byte(sweepGroupList), nil
+		case "group delete":
+			deleted = append(deleted, args[5])
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unexpected az %s", strings.Join(args, " "))
+	}
+	names := func(groups []StaleResourceGroup) []string {
+		var names []string
+		for _, group := range groups {
+			names = append(names, group.Name)
+		}
+		return names
+	}
+
+	clock := NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	stale, err := staleResourceGroupsE(az, clock, "sub", DefaultSweepPrefix, 24*time.Hour)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"rg-ca-https-test-jane-abc123", "rg-kv-test-bob-def456"}, names(stale),
+			"only test groups at least a day old; untagged, young and non-test groups stay")
+		assert.Equal(t, 48*time.Hour, stale[0].Age)
+		assert.Equal(t, "2026-02-28T12:00:00Z", stale[1].CreatedAt)
+	}
+	assert.Empty(t, deleted, "listing deletes nothing")
+
+	swept, err := sweepStaleResourcesE(az, clock, "sub", DefaultSweepPrefix, 36*time.Hour)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"rg-ca-https-test-jane-abc123"}, names(swept))
+		assert.Equal(t, []string{"rg-ca-https-test-jane-abc123"}, deleted)
+	}
+
+	stale, err = staleResourceGroupsE(az, clock, "sub", "rg-kv", time.Hour)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"rg-kv-test-bob-def456"}, names(stale))
+	}
+}
+
+func TestSweepStaleResourcesErrors(t *testing.T) {
+	t.Parallel()
+
+	clock := NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	unused := func(args ...string) ([]byte, error) {
+		t.Errorf("unexpected az %s", strings.Join(args, " "))
+		return nil, nil
+	}
+	_, err := sweepStaleResourcesE(unused, clock, "", DefaultSweepPrefix, time.Hour)
+	assert.EqualError(t, err, "no subscription ID to sweep")
+	_, err = sweepStaleResourcesE(unused, clock, "sub", "", time.Hour)
+	assert.EqualError(t, err, "sweeping needs a resource group name prefix")
+	_, err = sweepStaleResourcesE(unused, clock, "sub", DefaultSweepPrefix, 0)
+	assert.EqualError(t, err, "maximum age 0s must be positive, or running tests would be swept")
+
+	// A failed delete reports the groups already deleted
+	az := func(args ...string) ([]byte, error) {
+		if args[1] == "list" {
+			return []byte(sweepGroupList), nil
+		}
+		if args[5] == "rg-kv-test-bob-def456" {
+			return nil, errors.New("locked")
+		}
+		return nil, nil
+	}
+	swept, err := sweepStaleResourcesE(az, clock, "sub", DefaultSweepPrefix, 24*time.Hour)
+	assert.EqualError(t, err, "deleting rg-kv-test-bob-def456: locked")
+	if assert.Len(t, swept, 1) {
+		assert.Equal(t, "rg-ca-https-test-jane-abc123", swept[0].Name)
+	}
+}
@@ -0,0 +1,242 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// testProviderConfig is written next to a copied module so it can be planned
+// and applied as a root module
+const testProviderConfig = `provider "azurerm" {
+  features {}
+}
+`
+
+// CopyModuleToTemp copies the terraform tree to a temp folder and adds an
+// azurerm provider block to the named module, returning the module path.
+// Modules do not configure providers themselves, so this lets tests apply a
+// module directly and read its outputs exactly as a caller would see them
+func CopyModuleToTemp(t *testing.T, moduleName string) string {
+	moduleDir := CopyTerraformDirToTemp(t, filepath.Join("..", "modules", moduleName))
+
+	providerFile := filepath.Join(moduleDir, "provider_test.tf")
+	if err := os.WriteFile(providerFile, []byte(testProviderConfig), 0o600); err != nil {
+		t.Fatalf("Writing %s: %v", providerFile, err)
+	}
+	return moduleDir
+}
+
+// quietOptions returns a copy of options that does not log terraform output
+func quietOptions(options *terraform.Options) *terraform.Options {
+	quiet := *options
+	quiet.Logger = logger.Discard
+	return &quiet
+}
+
+// outputMetadata is a single entry of `terraform output -json`
+type outputMetadata struct {
+	Sensitive bool            `json:"sensitive"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// OutputIsSensitive reports whether the named root module output is marked sensitive
+func OutputIsSensitive(t *testing.T, options *terraform.Options, name string) bool {
+	output, err := terraform.RunTerraformCommandAndGetStdoutE(t, quietOptions(options), "output", "-no-color", "-json")
+	if err != nil {
+		t.Fatalf("Reading outputs: %v", err)
+	}
+
+	var outputs map[string]outputMetadata
+	if err := json.Unmarshal([]byte(output), &outputs); err != nil {
+		t.Fatalf("Decoding outputs: %v", err)
+	}
+
+	metadata, exists := outputs[name]
+	if !exists {
+		t.Fatalf("Output %s does not exist", name)
+	}
+	return metadata.Sensitive
+}
+
+// SensitiveOutput reads a string output without writing its value to the test log
+func SensitiveOutput(t *testing.T, options *terraform.Options, name string) string {
+	value, err := terraform.OutputE(t, quietOptions(options), name)
+	if err != nil {
+		t.Fatalf("Reading output %s: %v", name, err)
+	}
+	return value
+}
+
+// tagAttributes are the attributes a tag-only change may touch
+var tagAttributes = map[string]bool{
+	"tags":     true,
+	"tags_all": true,
+}
+
+// planResourceChange is an entry of resource_changes in `terraform show -json` plan output
+type planResourceChange struct {
+	Address string `json:"address"`
+	Change  struct {
+		Actions      []string               `json:"actions"`
+		Before       map[string]interface{} `json:"before"`
+		After        map[string]interface{} `json:"after"`
+		AfterUnknown map[string]interface{} `json:"after_unknown"`
+	} `json:"change"`
+}
+
+// changedAttributes returns the attributes change differs on, sorted,
+// leaving out ignored ones. Attributes that become unknown count as changed
+func (c planResourceChange) changedAttributes(ignored map[string]bool) []string {
+	var changed []string
+	seen := map[string]bool{}
+	for _, values := range []map[string]interface{}{c.Change.Before, c.Change.After, c.Change.AfterUnknown} {
+		for attribute := range values {
+			if seen[attribute] || ignored[attribute] {
+				continue
+			}
+			seen[attribute] = true
+			unknown, _ := c.Change.AfterUnknown[attribute].(bool)
+			if unknown || !reflect.DeepEqual(c.Change.Before[attribute], c.Change.After[attribute]) {
+				changed = append(changed, attribute)
+			}
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// nonTagChanges returns the attributes other than tags that change differs on
+func (c planResourceChange) nonTagChanges() []string {
+	return c.changedAttributes(tagAttributes)
+}
+
+// planResourceChangesE decodes resource_changes from `terraform show -json` plan output
+func planResourceChangesE(planJSON string) ([]planResourceChange, error) {
+	var plan struct {
+		ResourceChanges []planResourceChange `json:"resource_changes"`
+	}
+	if err := json.Unmarshal([]byte(planJSON), &plan); err != nil {
+		return nil, fmt.Errorf("decoding plan: %w", err)
+	}
+	return plan.ResourceChanges, nil
+}
+
+// PlannedChangeE returns the actions a plan (JSON from `terraform show -json`)
+// takes on the resource at address and the attributes it changes, sorted
+func PlannedChangeE(planJSON, address string) (actions []string, attributes []string, err error) {
+	changes, err := planResourceChangesE(planJSON)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, change := range changes {
+		if change.Address == address {
+			return change.Change.Actions, change.changedAttributes(nil), nil
+		}
+	}
+	return nil, nil, fmt.Errorf("plan has no change for %s", address)
+}
+
+// TagChangeViolationsE returns every resource change in a plan (JSON from
+// `terraform show -json`) that is more than an in-place tag update
+func TagChangeViolationsE(planJSON string) ([]string, error) {
+	changes, err := planResourceChangesE(planJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []string
+	updates := 0
+	for _, change := range changes {
+		actions := strings.Join(change.Change.Actions, ",")
+		switch actions {
+		case "no-op", "read":
+		case "update":
+			updates++
+			if attributes := change.nonTagChanges(); len(attributes) > 0 {
+				violations = append(violations, fmt.Sprintf("%s: changing tags also updates %s",
+					change.Address, strings.Join(attributes, ", ")))
+			}
+		default:
+			violations = append(violations, fmt.Sprintf("%s: changing tags plans [%s], expected an in-place update or no-op",
+				change.Address, actions))
+		}
+	}
+	if updates == 0 {
+		violations = append(violations, "plan updates no resources; the tag change did not reach any resource")
+	}
+	return violations, nil
+}
+
+// AssertTagChangesOnly asserts that a plan (JSON from `terraform show -json`)
+// only updates tags in place: no creates, deletes or replacements, and no
+// update touching anything but tag attributes. Tag-update tests use it to
+// catch modules that wire tags into names or other arguments
+func AssertTagChangesOnly(t *testing.T, planJSON string) {
+	violations, err := TagChangeViolationsE(planJSON)
+	if err != nil {
+		t.Fatalf("Checking tag-only plan: %v", err)
+	}
+	for _, violation := range violations {
+		t.Error(violation)
+	}
+}
+
+// idempotencyPlanFile is the plan AssertIdempotent makes after an apply
+const idempotencyPlanFile = "idempotency.tfplan"
+
+// IdempotencyViolationsE returns every resource change in a plan (JSON from
+// `terraform show -json`) made right after an apply. An idempotent module
+// plans nothing then, so any create, update, replacement or deletion is one
+func IdempotencyViolationsE(planJSON string) ([]string, error) {
+	changes, err := planResourceChangesE(planJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []string
+	for _, change := range changes {
+		actions := strings.Join(change.Change.Actions, ",")
+		switch actions {
+		case "no-op", "read":
+		case "update":
+			violations = append(violations, fmt.Sprintf("%s: updated again after apply: %s",
+				change.Address, strings.Join(change.changedAttributes(nil), ", ")))
+		default:
+			violations = append(violations, fmt.Sprintf("%s: plans [%s] again after apply", change.Address, actions))
+		}
+	}
+	return violations, nil
+}
+
+// AssertIdempotent plans options again right after an apply and fails the
+// test for every resource the plan would change, naming the attributes of
+// updates. Apply tests call it to catch modules whose arguments never settle,
+// e.g. values Azure normalizes or defaults the module does not set. options
+// is not modified; the plan is saved outside its folder
+func AssertIdempotent(t *testing.T, options *terraform.Options) {
+	replan := *options
+	replan.PlanFilePath = filepath.Join(t.TempDir(), idempotencyPlanFile)
+	if _, err := terraform.PlanE(t, &replan); err != nil {
+		t.Fatalf("Planning %s again after apply: %v", options.TerraformDir, err)
+	}
+	planJSON, err := terraform.ShowE(t, quietOptions(&replan))
+	if err != nil {
+		t.Fatalf("Showing the plan of %s after apply: %v", options.TerraformDir, err)
+	}
+	violations, err := IdempotencyViolationsE(planJSON)
+	if err != nil {
+		t.Fatalf("Checking the plan of %s after apply: %v", options.TerraformDir, err)
+	}
+	for _, violation := range violations {
+		t.Error(violation)
+	}
+}
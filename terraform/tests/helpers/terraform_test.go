@@ -0,0 +1,112 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagChangeViolations(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		plan       string
+		violations []string
+	}{
+		{
+			name: "tags only",
+			plan: `{"resource_changes": [
+				{"address": "azurerm_resource_group.this", "change": {"actions": ["update"],
+					"before": {"name": "rg", "tags": {"a": "1"}},
+					"after": {"name": "rg", "tags": {"a": "2"}},
+					"after_unknown": {"tags": {}}}},
+				{"address": "data.azurerm_client_config.current", "change": {"actions": ["read"]}},
+				{"address": "azurerm_role_assignment.this", "change": {"actions": ["no-op"]}}
+			]}`,
+		},
+		{
+			name: "other attribute updated",
+			plan: `{"resource_changes": [
+				{"address": "azurerm_key_vault.this", "change": {"actions": ["update"],
+					"before": {"sku_name": "standard", "tags": {"a": "1"}},
+					"after": {"sku_name": "premium", "tags": {"a": "2"}}}}
+			]}`,
+			violations: []string{"azurerm_key_vault.this: changing tags also updates sku_name"},
+		},
+		{
+			name: "attribute becomes unknown",
+			plan: `{"resource_changes": [
+				{"address": "azurerm_container_app.this", "change": {"actions": ["update"],
+					"before": {"latest_revision_name": "r1", "tags": {}},
+					"after": {"tags": {"a": "2"}},
+					"after_unknown": {"latest_revision_name": true}}}
+			]}`,
+			violations: []string{"azurerm_container_app.this: changing tags also updates latest_revision_name"},
+		},
+		{
+			name: "replacement",
+			plan: `{"resource_changes": [
+				{"address": "azurerm_container_registry.this", "change": {"actions": ["delete", "create"]}}
+			]}`,
+			violations: []string{
+				"azurerm_container_registry.this: changing tags plans [delete,create], expected an in-place update or no-op",
+				"plan updates no resources; the tag change did not reach any resource",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			violations, err := TagChangeViolationsE(tc.plan)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.violations, violations)
+		})
+	}
+}
+
+func TestPlannedChange(t *testing.T) {
+	t.Parallel()
+
+	plan := `{"resource_changes": [
+		{"address": "azurerm_key_vault_secret.secrets[\"app\"]", "change": {"actions": ["update"],
+			"before": {"content_type": "text/plain", "version": "v1", "tags": {}},
+			"after": {"content_type": "application/json", "version": "v1", "tags": {"a": "1"}},
+			"after_unknown": {"tags": {}}}}
+	]}`
+
+	actions, attributes, err := PlannedChangeE(plan, `azurerm_key_vault_secret.secrets["app"]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"update"}, actions)
+	assert.Equal(t, []string{"content_type", "tags"}, attributes)
+
+	_, _, err = PlannedChangeE(plan, "azurerm_key_vault.this")
+	assert.Error(t, err)
+}
+
+func TestIdempotencyViolations(t *testing.T) {
+	t.Parallel()
+
+	violations, err := IdempotencyViolationsE(`{"resource_changes": [
+		{"address": "azurerm_resource_group.this", "change": {"actions": ["no-op"]}},
+		{"address": "data.azurerm_client_config.current", "change": {"actions": ["read"]}},
+		{"address": "azurerm_key_vault.this", "change": {"actions": ["update"],
+			"before": {"name": "kv", "network_acls": [{"bypass": "azureservices"}]},
+			"after": {"name": "kv", "network_acls": [{"bypass": "AzureServices"}]}}},
+		{"address": "azurerm_role_assignment.this", "change": {"actions": ["delete", "create"]}},
+		{"address": "azurerm_monitor_diagnostic_setting.acr", "change": {"actions": ["create"]}}
+	]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"azurerm_key_vault.this: updated again after apply: network_acls",
+		"azurerm_role_assignment.this: plans [delete,create] again after apply",
+		"azurerm_monitor_diagnostic_setting.acr: plans [create] again after apply",
+	}, violations)
+
+	violations, err = IdempotencyViolationsE(`{"resource_changes": []}`)
+	assert.NoError(t, err)
+	assert.Empty(t, violations, "a plan without changes is idempotent")
+}
@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// testLogRoot holds a log per test, in a folder named after the test, so the
+// output of parallel tests can be read one test at a time
+const testLogRoot = "test-logs"
+
+// testLogFile is the file of a test's log folder holding its entries
+const testLogFile = "log.jsonl"
+
+// commandPattern matches the line terratest logs before running a command
+var commandPattern = regexp.MustCompile(`^Running command (\S+) with args \[(.*)\]$`)
+
+// TestLogEntry is one line of a test's log: a line terratest or terraform
+// logged, with the stage of the test (see TrackPhases) and the command it
+// came from
+type TestLogEntry struct {
+	Time  time.Time `json:"time"`
+	RunID string    `json:"run_id"`
+	Test  string    `json:"test"`
+	Stage string    `json:"stage,omitempty"`
+	// Command is the command that was running, e.g. "terraform apply
+	// -input=false -auto-approve"
+	Command string `json:"command,omitempty"`
+	// DurationSeconds is how long Command had been running
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	Message         string  `json:"message"`
+}
+
+// runningCommand is the command a test last started
+type runningCommand struct {
+	command string
+	started time.Time
+}
+
+// testLogState is what the log entries of every test are built from
+type testLogState struct {
+	mu       sync.Mutex
+	stages   map[string]string
+	commands map[string]runningCommand
+	// writeMu serializes appends to the log files, so a test's entries are
+	// in the order they were logged
+	writeMu sync.Mutex
+}
+
+var testLogs = &testLogState{stages: map[string]string{}, commands: map[string]runningCommand{}}
+
+// testLogWarning reports once that test logs cannot be written, rather than
+// on every line
+var testLogWarning sync.Once
+
+// quietTestLogs reports whether terraform and CLI output is kept out of
+// stdout, leaving it to the test log files
+func quietTestLogs() bool {
+	return os.Getenv("TEST_LOGS_QUIET") == "true"
+}
+
+// TestLogPath returns the log file of the named test
+func TestLogPath(testName string) string {
+	return filepath.Join(testLogRoot, testName, testLogFile)
+}
+
+// setStage sets the stage the entries of test are logged under; an empty
+// stage clears it
+func (s *testLogState) setStage(test, stage string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stage == "" {
+		delete(s.stages, test)
+		return
+	}
+	s.stages[test] = stage
+}
+
+// entry builds the log entry of message, logged by test at now. A subtest
+// without a stage of its own is in the stage of its parent
+func (s *testLogState) entry(test, message string, now time.Time) TestLogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if match := commandPattern.FindStringSubmatch(message); match != nil {
+		s.commands[test] = runningCommand{command: strings.TrimSpace(match[1] + " " + match[2]), started: now}
+	}
+	entry := TestLogEntry{Time: now, RunID: RunID(), Test: test, Message: message}
+	if running, ok := s.commands[test]; ok {
+		entry.Command = running.command
+		entry.DurationSeconds = now.Sub(running.started).Seconds()
+	}
+	for name := test; name != ""; {
+		if stage, ok := s.stages[name]; ok {
+			entry.Stage = stage
+			break
+		}
+		slash := strings.LastIndex(name, "/")
+		if slash < 0 {
+			break
+		}
+		name = name[:slash]
+	}
+	return entry
+}
+
+// appendTestLogE appends entry to the log of its test under root
+func appendTestLogE(root string, entry TestLogEntry) error {
+	path := filepath.Join(root, entry.Test, testLogFile)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// logToTestFile writes message, already redacted, to the log of test
+func logToTestFile(test, message string) {
+	testLogs.writeMu.Lock()
+	defer testLogs.writeMu.Unlock()
+	entry := testLogs.entry(test, message, time.Now())
+	if err := appendTestLogE(testLogRoot, entry); err != nil {
+		testLogWarning.Do(func() {
+			fmt.Fprintf(os.Stderr, "Could not write test logs under %s: %v\n", testLogRoot, err)
+		})
+	}
+}
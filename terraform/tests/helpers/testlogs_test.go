@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestLogEntries(t *testing.T) {
+	t.Parallel()
+
+	logs := &testLogState{stages: map[string]string{}, commands: map[string]runningCommand{}}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	entry := logs.entry("TestVault", "Setting up", start)
+	assert.Empty(t, entry.Stage, "nothing is tracked before the first phase")
+	assert.Empty(t, entry.Command)
+	assert.Equal(t, RunID(), entry.RunID)
+
+	logs.setStage("TestVault", "apply")
+	entry = logs.entry("TestVault", "Running command terraform with args [apply -input=false -auto-approve]", start)
+	assert.Equal(t, "apply", entry.Stage)
+	assert.Equal(t, "terraform apply -input=false -auto-approve", entry.Command)
+	assert.Zero(t, entry.DurationSeconds)
+
+	entry = logs.entry("TestVault", "Apply complete! Resources: 3 added, 0 changed, 0 destroyed.", start.Add(90*time.Second))
+	assert.Equal(t, "terraform apply -input=false -auto-approve", entry.Command, "output lines belong to the command running")
+	assert.Equal(t, 90.0, entry.DurationSeconds)
+
+	entry = logs.entry("TestVault/secrets", "Running command az with args [keyvault secret show]", start)
+	assert.Equal(t, "apply", entry.Stage, "a subtest without phases is in its parent's stage")
+	assert.Equal(t, "az keyvault secret show", entry.Command)
+	assert.Equal(t, "terraform apply -input=false -auto-approve", logs.entry("TestVault", "", start).Command,
+		"each test has its own command")
+
+	logs.setStage("TestVault", "")
+	assert.Empty(t, logs.entry("TestVault", "done", start).Stage)
+	assert.Empty(t, logs.entry("TestApp", "other test", start).Stage)
+}
+
+func TestAppendTestLogE(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	entries := []TestLogEntry{
+		{Test: "TestVault/secrets", Stage: "verify", Message: "first"},
+		{Test: "TestVault/secrets", Stage: "verify", Command: "az keyvault secret show", DurationSeconds: 1.5, Message: "second"},
+	}
+	for _, entry := range entries {
+		if err := appendTestLogE(root, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(filepath.Join(root, "TestVault", "secrets", testLogFile))
+	if err != nil {
+		t.Fatalf("A subtest's log should be under its parent's folder: %v", err)
+	}
+	defer file.Close()
+	var logged []TestLogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry TestLogEntry
+		if assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "each line should be a JSON entry") {
+			logged = append(logged, entry)
+		}
+	}
+	assert.Equal(t, entries, logged)
+}
@@ -0,0 +1,268 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+const (
+	// tftestReport is the report the result of every module's native tests
+	// is recorded in
+	tftestReport = "tftest"
+
+	// minTerraformTestVersion is the first terraform with mock providers,
+	// which the module tests use to plan without Azure credentials
+	minTerraformTestVersion = "1.7.0"
+)
+
+// TFTestRun is the result of one run block of a native test file
+type TFTestRun struct {
+	// File is relative to the module, e.g. "tests/key_vault.tftest.hcl"
+	File string `json:"file"`
+	Run  string `json:"run"`
+	// Status is pass, fail, error or skip
+	Status string `json:"status"`
+	// Diagnostics are the errors terraform reported for the run, such as
+	// failed assertions
+	Diagnostics []string `json:"diagnostics,omitempty"`
+}
+
+// TFTestResult is the result of `terraform test` in one module
+type TFTestResult struct {
+	Module  string      `json:"module"`
+	Status  string      `json:"status"`
+	Passed  int         `json:"passed"`
+	Failed  int         `json:"failed"`
+	Errored int         `json:"errored"`
+	Skipped int         `json:"skipped"`
+	Runs    []TFTestRun `json:"runs"`
+	// Diagnostics are errors not tied to a run, such as a test file that
+	// does not parse
+	Diagnostics []string `json:"diagnostics,omitempty"`
+}
+
+// terraformTestEvent is one line of `terraform test -json` output
+type terraformTestEvent struct {
+	Type     string `json:"type"`
+	TestFile string `json:"@testfile"`
+	TestRun  string `json:"@testrun"`
+	Run      *struct {
+		Path     string `json:"path"`
+		Run      string `json:"run"`
+		Progress string `json:"progress"`
+		Status   string `json:"status"`
+	} `json:"test_run"`
+	Diagnostic *struct {
+		Severity string `json:"severity"`
+		Summary  string `json:"summary"`
+		Detail   string `json:"detail"`
+	} `json:"diagnostic"`
+	Summary *struct {
+		Status  string `json:"status"`
+		Passed  int    `json:"passed"`
+		Failed  int    `json:"failed"`
+		Errored int    `json:"errored"`
+		Skipped int    `json:"skipped"`
+	} `json:"test_summary"`
+}
+
+// TerraformTestFilesE returns the native test files (*.tftest.hcl) terraform
+// test runs for the module in dir, from the module folder and its tests
+// folder, relative to dir and sorted
+func TerraformTestFilesE(dir string) ([]string, error) {
+	var testFiles []string
+	for _, pattern := range []string{"*.tftest.hcl", filepath.Join("tests", "*.tftest.hcl")} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			relative, err := filepath.Rel(dir, match)
+			if err != nil {
+				return nil, err
+			}
+			testFiles = append(testFiles, filepath.ToSlash(relative))
+		}
+	}
+	sort.Strings(testFiles)
+	return testFiles, nil
+}
+
+// parseTerraformTestJSON reads `terraform test -json` output into the result
+// of module. Lines that are not JSON events are skipped
+func parseTerraformTestJSON(output, module string) (*TFTestResult, error) {
+	result := &TFTestResult{Module: module, Runs: []TFTestRun{}}
+	runs := map[string]int{}
+	runIndex := func(file, run string) int {
+		key := file + "\x00" + run
+		if index, exists := runs[key]; exists {
+			return index
+		}
+		runs[key] = len(result.Runs)
+		result.Runs = append(result.Runs, TFTestRun{File: filepath.ToSlash(file), Run: run})
+		return runs[key]
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var event terraformTestEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			continue
+		}
+
+		switch {
+		case event.Type == "test_run" && event.Run != nil:
+			// Terraform 1.7 reports progress before a run completes; only
+			// the final event has a status worth keeping
+			if event.Run.Status == "" || (event.Run.Progress != "" && event.Run.Progress != "complete") {
+				continue
+			}
+			result.Runs[runIndex(event.Run.Path, event.Run.Run)].Status = event.Run.Status
+		case event.Type == "diagnostic" && event.Diagnostic != nil:
+			if event.Diagnostic.Severity != "error" {
+				continue
+			}
+			message := event.Diagnostic.Summary
+			if detail := strings.TrimSpace(event.Diagnostic.Detail); detail != "" {
+				message += ": " + detail
+			}
+			if event.TestRun == "" {
+				result.Diagnostics = append(result.Diagnostics, message)
+				continue
+			}
+			index := runIndex(event.TestFile, event.TestRun)
+			result.Runs[index].Diagnostics = append(result.Runs[index].Diagnostics, message)
+		case event.Type == "test_summary" && event.Summary != nil:
+			result.Status = event.Summary.Status
+			result.Passed, result.Failed = event.Summary.Passed, event.Summary.Failed
+			result.Errored, result.Skipped = event.Summary.Errored, event.Summary.Skipped
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if result.Status == "" {
+		return nil, fmt.Errorf("terraform test output for %s has no summary", module)
+	}
+	return result, nil
+}
+
+// terraformVersionAtLeast reports whether the dotted version is at least
+// minimum, comparing major and minor only
+func terraformVersionAtLeast(version, minimum string) bool {
+	var major, minor, minMajor, minMinor int
+	fmt.Sscanf(strings.TrimPrefix(version, "v"), "%d.%d", &major, &minor)
+	fmt.Sscanf(minimum, "%d.%d", &minMajor, &minMinor)
+	return major > minMajor || (major == minMajor && minor >= minMinor)
+}
+
+// RunTerraformTestsE runs the native tests of the module in dir with
+// `terraform test` and returns their result, including failed runs; the
+// error is for tests that could not run at all. The module is initialized
+// without a backend through the plan cache (see CachedInitE) and tested in
+// its copy, so nothing is written next to the module
+func RunTerraformTestsE(t *testing.T, dir string) (*TFTestResult, error) {
+	relative, err := terraformRootRelE(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &terraform.Options{
+		TerraformDir:    dir,
+		TerraformBinary: terraform.DefaultExecutable,
+		NoColor:         true,
+		Logger:          RedactingLogger,
+		EnvVars:         map[string]string{"TF_CLI_ARGS_init": "-backend=false"},
+	}
+	if err := CachedInitE(t, options); err != nil {
+		return nil, err
+	}
+
+	// terraform test exits non-zero when a run fails, which the result
+	// reports, so the output is read either way
+	output, runErr := shell.RunCommandAndGetStdOutE(t, shell.Command{
+		Command:    options.TerraformBinary,
+		Args:       []string{"test", "-json", "-no-color"},
+		WorkingDir: options.TerraformDir,
+		Env:        options.EnvVars,
+		Logger:     RedactingLogger,
+	})
+	result, err := parseTerraformTestJSON(output, filepath.ToSlash(relative))
+	if err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("testing %s: %w", relative, runErr)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// AssertTerraformTests runs the native tests of the module in dir and
+// reports every run block as a subtest, under its file, so failures show up
+// in go test output and run summaries like any other test. The module's
+// result is recorded in the tftest report under its path. Skips when
+// terraform is older than the mock providers the tests need
+func AssertTerraformTests(t *testing.T, dir string) {
+	version, err := terraform.RunTerraformCommandAndGetStdoutE(t, &terraform.Options{Logger: RedactingLogger}, "version", "-json")
+	if err != nil {
+		t.Fatalf("Reading terraform version: %v", err)
+	}
+	var parsed struct {
+		TerraformVersion string `json:"terraform_version"`
+	}
+	if err := json.Unmarshal([]byte(version), &parsed); err != nil {
+		t.Fatalf("Decoding terraform version: %v", err)
+	}
+	if !terraformVersionAtLeast(parsed.TerraformVersion, minTerraformTestVersion) {
+		t.Skipf("terraform test with mock providers needs terraform >= %s, found %s", minTerraformTestVersion, parsed.TerraformVersion)
+	}
+
+	result, err := RunTerraformTestsE(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RecordReport(t, tftestReport, result.Module, result)
+	for _, diagnostic := range result.Diagnostics {
+		t.Errorf("%s: %s", result.Module, diagnostic)
+	}
+
+	files := map[string][]TFTestRun{}
+	var order []string
+	for _, run := range result.Runs {
+		if _, exists := files[run.File]; !exists {
+			order = append(order, run.File)
+		}
+		files[run.File] = append(files[run.File], run)
+	}
+	for _, file := range order {
+		runs := files[file]
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".tftest.hcl"), func(t *testing.T) {
+			for _, run := range runs {
+				run := run
+				t.Run(run.Run, func(t *testing.T) {
+					switch run.Status {
+					case "pass":
+					case "skip", "pending":
+						t.Skipf("terraform skipped run %q in %s", run.Run, run.File)
+					default:
+						t.Errorf("Run %q in %s/%s ended in %s:\n%s", run.Run, result.Module, run.File, run.Status,
+							strings.Join(run.Diagnostics, "\n"))
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTerraformTestOutput = `{"@level":"info","@message":"Terraform 1.7.5","type":"version","terraform":"1.7.5","ui":"1.2"}
+{"@level":"info","@message":"Found 1 file and 3 run blocks","type":"test_abstract","test_abstract":{"tests/key_vault.tftest.hcl":["secure_defaults","network_acls","rejects_short_retention"]}}
+{"@level":"info","@message":"tests/key_vault.tftest.hcl... in progress","@testfile":"tests/key_vault.tftest.hcl","type":"test_file","test_file":{"path":"tests/key_vault.tftest.hcl","progress":"starting"}}
+{"@level":"info","@message":"  \"secure_defaults\"... in progress","@testfile":"tests/key_vault.tftest.hcl","@testrun":"secure_defaults","type":"test_run","test_run":{"path":"tests/key_vault.tftest.hcl","run":"secure_defaults","progress":"starting","elapsed":0}}
+{"@level":"info","@message":"  \"secure_defaults\"... pass","@testfile":"tests/key_vault.tftest.hcl","@testrun":"secure_defaults","type":"test_run","test_run":{"path":"tests/key_vault.tftest.hcl","run":"secure_defaults","progress":"complete","status":"pass"}}
+{"@level":"error","@message":"Error: Test assertion failed","@testfile":"tests/key_vault.tftest.hcl","@testrun":"network_acls","type":"diagnostic","diagnostic":{"severity":"error","summary":"Test assertion failed","detail":"Network ACLs should deny by default and let trusted Azure services through"}}
+{"@level":"info","@message":"  \"network_acls\"... fail","@testfile":"tests/key_vault.tftest.hcl","@testrun":"network_acls","type":"test_run","test_run":{"path":"tests/key_vault.tftest.hcl","run":"network_acls","progress":"complete","status":"fail"}}
+{"@level":"info","@message":"  \"rejects_short_retention\"... skip","@testfile":"tests/key_vault.tftest.hcl","@testrun":"rejects_short_retention","type":"test_run","test_run":{"path":"tests/key_vault.tftest.hcl","run":"rejects_short_retention","status":"skip"}}
+{"@level":"warn","@message":"Warning: Argument is deprecated","type":"diagnostic","diagnostic":{"severity":"warning","summary":"Argument is deprecated"}}
+{"@level":"info","@message":"Failure! 1 passed, 1 failed, 1 skipped.","type":"test_summary","test_summary":{"status":"fail","passed":1,"failed":1,"errored":0,"skipped":1}}
+`
+
+func TestParseTerraformTestJSON(t *testing.T) {
+	t.Parallel()
+
+	result, err := parseTerraformTestJSON(testTerraformTestOutput, "modules/key-vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "fail", result.Status)
+	assert.Equal(t, []int{1, 1, 0, 1}, []int{result.Passed, result.Failed, result.Errored, result.Skipped})
+	assert.Empty(t, result.Diagnostics, "warnings are not errors")
+	assert.Equal(t, []TFTestRun{
+		{File: "tests/key_vault.tftest.hcl", Run: "secure_defaults", Status: "pass"},
+		{File: "tests/key_vault.tftest.hcl", Run: "network_acls", Status: "fail", Diagnostics: []string{
+			"Test assertion failed: Network ACLs should deny by default and let trusted Azure services through",
+		}},
+		{File: "tests/key_vault.tftest.hcl", Run: "rejects_short_retention", Status: "skip"},
+	}, result.Runs)
+
+	_, err = parseTerraformTestJSON("Error: Failed to load plugin schemas\n", "modules/key-vault")
+	assert.ErrorContains(t, err, "no summary")
+}
+
+func TestTerraformTestFilesE(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, file := range []string{"main.tf", "basic.tftest.hcl", "tests/validation.tftest.hcl", "tests/fixture.tf", "examples/basic/main.tf"} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testFiles, err := TerraformTestFilesE(dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"basic.tftest.hcl", "tests/validation.tftest.hcl"}, testFiles)
+	}
+}
+
+func TestTerraformVersionAtLeast(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, terraformVersionAtLeast("1.7.0", minTerraformTestVersion))
+	assert.True(t, terraformVersionAtLeast("1.10.2", minTerraformTestVersion))
+	assert.True(t, terraformVersionAtLeast("2.0.0", minTerraformTestVersion))
+	assert.False(t, terraformVersionAtLeast("1.6.6", minTerraformTestVersion))
+	assert.False(t, terraformVersionAtLeast("", minTerraformTestVersion))
+}
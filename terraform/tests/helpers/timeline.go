@@ -0,0 +1,297 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// timelineReport is the report phases are recorded in; timeline.html is
+// rendered next to it
+const timelineReport = "timeline"
+
+// criticalPathSlack is how long after one span ends the next one on the
+// critical path may start; covers bookkeeping between phases and tests
+const criticalPathSlack = 5 * time.Second
+
+// TimelineSpan is one phase of one test in the run timeline
+type TimelineSpan struct {
+	Test  string    `json:"test"`
+	Phase string    `json:"phase"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Duration is how long the span took
+func (s TimelineSpan) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// id identifies the span within a run
+func (s TimelineSpan) id() string {
+	return fmt.Sprintf("%s/%s@%d", s.Test, s.Phase, s.Start.UnixNano())
+}
+
+// Phases records the phases (apply, verify, destroy, ...) of a test in the
+// run timeline. Each phase lasts until the next one starts or the test ends
+type Phases struct {
+	t       *testing.T
+	mu      sync.Mutex
+	current *TimelineSpan
+	count   int
+}
+
+// TrackPhases starts recording phases of t. Typical use, with the destroy
+// phase deferred after the destroy itself so it runs first:
+//
+//	phases := helpers.TrackPhases(t)
+//	defer helpers.Destroy(t, terraformOptions)
+//	defer phases.Start("destroy")
+//	phases.Start("apply")
+//	helpers.InitAndApply(t, terraformOptions)
+//	phases.Start("verify")
+func TrackPhases(t *testing.T) *Phases {
+	p := &Phases{t: t}
+	t.Cleanup(func() {
+		p.end(time.Now())
+		testLogs.setStage(t.Name(), "")
+	})
+	return p
+}
+
+// Start ends the current phase, if any, and starts the named one. Starting
+// the verify phase starts the validate stage (see StartValidation). Lines
+// the test logs from then on are in the phase's stage of its log file (see
+// TestLogEntry)
+func (p *Phases) Start(phase string) {
+	now := time.Now()
+	p.end(now)
+	if phase == "verify" {
+		StartValidation(p.t)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = &TimelineSpan{Test: p.t.Name(), Phase: phase, Start: now}
+	testLogs.setStage(p.t.Name(), phase)
+}
+
+// end records the current phase as ending at the given time. Timeline
+// failures are logged, not failed: they must not change the outcome of the
+// test being timed
+func (p *Phases) end(at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == nil {
+		return
+	}
+
+	span := *p.current
+	span.End = at
+	p.current = nil
+	p.count++
+
+	key := fmt.Sprintf("%s/%02d-%s", span.Test, p.count, span.Phase)
+	if err := RecordReportE(timelineReport, key, span); err != nil {
+		p.t.Logf("Recording phase %s in the timeline: %v", span.Phase, err)
+		return
+	}
+	if err := WriteTimelineHTMLE(); err != nil {
+		p.t.Logf("Rendering the timeline: %v", err)
+	}
+}
+
+// CriticalPath returns the chain of spans that gated the wall-clock time of
+// the run, in order: it starts from the span that ended last and repeatedly
+// steps back to the span that ended last before the current one started,
+// whichever test it belongs to. A gap of more than criticalPathSlack ends
+// the chain
+func CriticalPath(spans []TimelineSpan) []TimelineSpan {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	last := spans[0]
+	for _, span := range spans[1:] {
+		if span.End.After(last.End) {
+			last = span
+		}
+	}
+
+	path := []TimelineSpan{last}
+	for {
+		current := path[len(path)-1]
+		var previous *TimelineSpan
+		for i := range spans {
+			span := spans[i]
+			if !span.Start.Before(current.Start) || span.End.After(current.Start.Add(criticalPathSlack)) {
+				continue
+			}
+			if previous == nil || span.End.After(previous.End) {
+				previous = &spans[i]
+			}
+		}
+		if previous == nil || current.Start.Sub(previous.End) > criticalPathSlack {
+			break
+		}
+		path = append(path, *previous)
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// readTimelineE loads the spans recorded in the timeline report of the run,
+// ordered by start time. Callers hold reportMu
+func readTimelineE() ([]TimelineSpan, error) {
+	content, err := os.ReadFile(ReportPath(timelineReport))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries map[string]TimelineSpan
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("decoding timeline: %w", err)
+	}
+	spans := make([]TimelineSpan, 0, len(entries))
+	for _, span := range entries {
+		spans = append(spans, span)
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].Start.Equal(spans[j].Start) {
+			return spans[i].Test < spans[j].Test
+		}
+		return spans[i].Start.Before(spans[j].Start)
+	})
+	return spans, nil
+}
+
+// timelineBar is a span positioned on the chart, in percent of the run
+type timelineBar struct {
+	TimelineSpan
+	Left, Width float64
+	Critical    bool
+}
+
+// timelineRow is one test on the chart
+type timelineRow struct {
+	Test string
+	Bars []timelineBar
+}
+
+var timelineTemplate = template.Must(template.New("timeline").Funcs(template.FuncMap{
+	"seconds": func(d time.Duration) time.Duration { return d.Round(time.Second) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Test timeline {{.RunID}}</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; margin: 20px; }
+.row { display: flex; align-items: center; height: 22px; }
+.name { width: 320px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.track { position: relative; flex: 1; height: 16px; background: #f3f3f3; }
+.bar { position: absolute; height: 100%; min-width: 1px; }
+.apply { background: #4e79a7; } .verify { background: #59a14f; }
+.destroy { background: #f28e2b; } .other { background: #9c9c9c; }
+.critical { outline: 2px solid #d62728; z-index: 1; }
+table { border-collapse: collapse; margin-top: 16px; }
+td, th { border: 1px solid #ddd; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Test timeline {{.RunID}}</h1>
+<p>Wall clock {{.Total}}; critical path (outlined in red) {{.CriticalTotal}}.</p>
+{{range .Rows}}<div class="row"><div class="name" title="{{.Test}}">{{.Test}}</div><div class="track">
+{{range .Bars}}<div class="bar {{.Class}}{{if .Critical}} critical{{end}}" style="left: {{printf "%.3f" .Left}}%; width: {{printf "%.3f" .Width}}%" title="{{.Phase}}: {{seconds .Duration}}"></div>
+{{end}}</div></div>
+{{end}}
+<h2>Critical path</h2>
+<table>
+<tr><th>Test</th><th>Phase</th><th>Start</th><th>Duration</th></tr>
+{{range .CriticalPath}}<tr><td>{{.Test}}</td><td>{{.Phase}}</td><td>{{.Start.Format "15:04:05"}}</td><td>{{seconds .Duration}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// Class is the CSS class of the bar's phase
+func (b timelineBar) Class() string {
+	switch b.Phase {
+	case "apply", "verify", "destroy":
+		return b.Phase
+	default:
+		return "other"
+	}
+}
+
+// WriteTimelineHTMLE renders the timeline report of the run as a Gantt chart
+// in timeline.html, with the critical path highlighted
+func WriteTimelineHTMLE() error {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	spans, err := readTimelineE()
+	if err != nil || len(spans) == 0 {
+		return err
+	}
+
+	start, end := spans[0].Start, spans[0].End
+	for _, span := range spans {
+		if span.End.After(end) {
+			end = span.End
+		}
+	}
+	total := end.Sub(start)
+	if total <= 0 {
+		total = time.Millisecond
+	}
+
+	critical := CriticalPath(spans)
+	onPath := map[string]bool{}
+	var criticalTotal time.Duration
+	for _, span := range critical {
+		onPath[span.id()] = true
+		criticalTotal += span.Duration()
+	}
+
+	var rows []*timelineRow
+	rowIndex := map[string]*timelineRow{}
+	for _, span := range spans {
+		row, exists := rowIndex[span.Test]
+		if !exists {
+			row = &timelineRow{Test: span.Test}
+			rowIndex[span.Test] = row
+			rows = append(rows, row)
+		}
+		row.Bars = append(row.Bars, timelineBar{
+			TimelineSpan: span,
+			Left:         100 * float64(span.Start.Sub(start)) / float64(total),
+			Width:        100 * float64(span.Duration()) / float64(total),
+			Critical:     onPath[span.id()],
+		})
+	}
+
+	path := filepath.Join(filepath.Dir(ReportPath(timelineReport)), timelineReport+".html")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return timelineTemplate.Execute(file, map[string]interface{}{
+		"RunID":         RunID(),
+		"Total":         total.Round(time.Second),
+		"CriticalTotal": criticalTotal.Round(time.Second),
+		"Rows":          rows,
+		"CriticalPath":  critical,
+	})
+}
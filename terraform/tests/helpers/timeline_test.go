@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCriticalPath(t *testing.T) {
+	t.Parallel()
+
+	at := func(minutes int) time.Time {
+		return time.Date(2026, 1, 1, 0, minutes, 0, 0, time.UTC)
+	}
+	span := func(test, phase string, start, end int) TimelineSpan {
+		return TimelineSpan{Test: test, Phase: phase, Start: at(start), End: at(end)}
+	}
+
+	// TestB only starts once TestA frees a parallel slot, so TestA's apply
+	// and TestB's phases gate the run; TestC runs alongside and does not
+	spans := []TimelineSpan{
+		span("TestA", "apply", 0, 10),
+		span("TestA", "verify", 10, 12),
+		span("TestB", "apply", 12, 30),
+		span("TestB", "destroy", 30, 40),
+		span("TestC", "apply", 0, 5),
+		span("TestC", "verify", 5, 25),
+	}
+
+	assert.Equal(t, []TimelineSpan{spans[0], spans[1], spans[2], spans[3]}, CriticalPath(spans))
+	assert.Empty(t, CriticalPath(nil))
+
+	// A gap longer than the slack ends the chain
+	gapped := []TimelineSpan{span("TestA", "apply", 0, 10), span("TestB", "apply", 20, 30)}
+	assert.Equal(t, gapped[1:], CriticalPath(gapped))
+}
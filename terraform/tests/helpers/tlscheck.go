@@ -0,0 +1,231 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// legacyTLSVersions must be refused by every public endpoint
+var legacyTLSVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11}
+
+// minCertificateValidity is how long a served certificate must stay valid
+const minCertificateValidity = 14 * 24 * time.Hour
+
+// TLSCheckResult is what a public endpoint negotiated with the test runner
+type TLSCheckResult struct {
+	Address string `json:"address"`
+	// Versions maps a protocol name ("TLS 1.0") to whether it was accepted
+	Versions map[string]bool `json:"versions"`
+	// WeakCiphersAccepted lists RC4 / 3DES suites the endpoint agreed to
+	WeakCiphersAccepted []string `json:"weak_ciphers_accepted"`
+	// NegotiatedCipher is the suite chosen for a default TLS 1.2 handshake
+	NegotiatedCipher string    `json:"negotiated_cipher"`
+	ChainError       string    `json:"chain_error,omitempty"`
+	NotAfter         time.Time `json:"not_after"`
+}
+
+// tlsAddress turns a URL, host or host:port into host:port, defaulting to 443
+func tlsAddress(endpoint string) string {
+	if parsed, err := url.Parse(endpoint); err == nil && parsed.Host != "" {
+		endpoint = parsed.Host
+	}
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		return net.JoinHostPort(endpoint, "443")
+	}
+	return endpoint
+}
+
+// weakCipherSuites returns the RC4 and 3DES suites Go can still offer
+func weakCipherSuites() []*tls.CipherSuite {
+	var suites []*tls.CipherSuite
+	for _, suite := range tls.InsecureCipherSuites() {
+		if strings.Contains(suite.Name, "RC4") || strings.Contains(suite.Name, "3DES") {
+			suites = append(suites, suite)
+		}
+	}
+	return suites
+}
+
+// handshakeE performs a TLS handshake with config against address. A refused
+// handshake returns (nil, nil); only connection failures return an error
+func handshakeE(address string, config *tls.Config) (*tls.ConnectionState, error) {
+	host, _, _ := net.SplitHostPort(address)
+	config.ServerName = host
+
+	rawConn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer rawConn.Close()
+	if err := rawConn.SetDeadline(time.Now().Add(15 * time.Second)); err != nil {
+		return nil, err
+	}
+
+	conn := tls.Client(rawConn, config)
+	if err := conn.Handshake(); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, err
+		}
+		return nil, nil
+	}
+	state := conn.ConnectionState()
+	return &state, nil
+}
+
+// CheckTLSE probes the TLS configuration of endpoint (URL, host or host:port)
+func CheckTLSE(endpoint string) (*TLSCheckResult, error) {
+	address := tlsAddress(endpoint)
+	result := &TLSCheckResult{Address: address, Versions: map[string]bool{}}
+
+	// Legacy protocols are offered on their own so the server can't upgrade;
+	// certificate checks are left to the default handshake below
+	for _, version := range append(legacyTLSVersions, tls.VersionTLS12, tls.VersionTLS13) {
+		state, err := handshakeE(address, &tls.Config{
+			MinVersion:         version,
+			MaxVersion:         version,
+			InsecureSkipVerify: true, // #nosec G402 -- probing protocol support only
+		})
+		if err != nil {
+			return nil, fmt.Errorf("probing %s on %s: %w", tls.VersionName(version), address, err)
+		}
+		result.Versions[tls.VersionName(version)] = state != nil
+	}
+
+	for _, suite := range weakCipherSuites() {
+		state, err := handshakeE(address, &tls.Config{
+			MinVersion:         tls.VersionTLS10,
+			MaxVersion:         tls.VersionTLS12,
+			CipherSuites:       []uint16{suite.ID},
+			InsecureSkipVerify: true, // #nosec G402 -- probing cipher support only
+		})
+		if err != nil {
+			return nil, fmt.Errorf("probing %s on %s: %w", suite.Name, address, err)
+		}
+		if state != nil {
+			result.WeakCiphersAccepted = append(result.WeakCiphersAccepted, suite.Name)
+		}
+	}
+
+	// Default handshake verifies the chain against the system roots and the host name
+	host, _, _ := net.SplitHostPort(address)
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", address, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		MaxVersion: tls.VersionTLS12,
+		ServerName: host,
+	})
+	if err != nil {
+		result.ChainError = err.Error()
+		return result, nil
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	result.NegotiatedCipher = tls.CipherSuiteName(state.CipherSuite)
+	if len(state.PeerCertificates) > 0 {
+		result.NotAfter = state.PeerCertificates[0].NotAfter
+	}
+	return result, nil
+}
+
+// AssertTLSBaseline asserts that endpoint refuses TLS 1.0/1.1 and RC4/3DES
+// suites, accepts TLS 1.2 with a forward-secret AEAD suite, and serves a
+// certificate chain that is valid for its host name and not about to expire
+func AssertTLSBaseline(t *testing.T, endpoint string) *TLSCheckResult {
+	result, err := CheckTLSE(endpoint)
+	if err != nil {
+		t.Fatalf("Checking TLS of %s: %v", endpoint, err)
+	}
+
+	for _, version := range legacyTLSVersions {
+		name := tls.VersionName(version)
+		assert.False(t, result.Versions[name], "%s should refuse %s", result.Address, name)
+	}
+	assert.True(t, result.Versions[tls.VersionName(tls.VersionTLS12)], "%s should accept TLS 1.2", result.Address)
+	assert.Empty(t, result.WeakCiphersAccepted, "%s should refuse RC4 and 3DES cipher suites", result.Address)
+
+	if assert.Empty(t, result.ChainError, "%s should serve a valid certificate chain", result.Address) {
+		assert.True(t, strings.HasPrefix(result.NegotiatedCipher, "TLS_ECDHE_") &&
+			(strings.Contains(result.NegotiatedCipher, "_GCM_") || strings.Contains(result.NegotiatedCipher, "CHACHA20")),
+			"%s should prefer a forward-secret AEAD suite, negotiated %s", result.Address, result.NegotiatedCipher)
+		assert.True(t, time.Until(result.NotAfter) > minCertificateValidity,
+			"%s certificate expires %s", result.Address, result.NotAfter.Format(time.RFC3339))
+	}
+	return result
+}
+
+// PlainHTTPResult is how an endpoint answered a plain HTTP request
+type PlainHTTPResult struct {
+	URL string `json:"url"`
+	// Refused is set when the connection was refused or reset
+	Refused    bool   `json:"refused"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Location   string `json:"location,omitempty"`
+}
+
+// RedirectsToHTTPS reports whether the endpoint answered with a redirect to
+// an https:// URL
+func (r *PlainHTTPResult) RedirectsToHTTPS() bool {
+	if r.StatusCode < 300 || r.StatusCode > 399 {
+		return false
+	}
+	location, err := url.Parse(r.Location)
+	return err == nil && location.Scheme == "https"
+}
+
+// EnforcesHTTPS reports whether the endpoint refused plain HTTP or
+// redirected it to HTTPS instead of serving it
+func (r *PlainHTTPResult) EnforcesHTTPS() bool {
+	return r.Refused || r.RedirectsToHTTPS()
+}
+
+// plainHTTPURL turns a URL or host into the http:// URL of its root, keeping
+// an explicit port
+func plainHTTPURL(endpoint string) string {
+	host := endpoint
+	if parsed, err := url.Parse(endpoint); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+	return (&url.URL{Scheme: "http", Host: host, Path: "/"}).String()
+}
+
+// CheckPlainHTTPE sends a plain HTTP GET to the root of endpoint (URL or
+// host) without following redirects. A refused or reset connection is a
+// result, not an error; other failures such as timeouts return an error
+func CheckPlainHTTPE(endpoint string) (*PlainHTTPResult, error) {
+	result := &PlainHTTPResult{URL: plainHTTPURL(endpoint)}
+	client := &http.Client{
+		Timeout: 15 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	response, err := client.Get(result.URL)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, fmt.Errorf("requesting %s: %w", result.URL, err)
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+			result.Refused = true
+			return result, nil
+		}
+		return nil, fmt.Errorf("requesting %s: %w", result.URL, err)
+	}
+	defer response.Body.Close()
+
+	result.StatusCode = response.StatusCode
+	result.Location = response.Header.Get("Location")
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSAddress(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "example.azurecr.io:443", tlsAddress("example.azurecr.io"))
+	assert.Equal(t, "kv.vault.azure.net:443", tlsAddress("https://kv.vault.azure.net/"))
+	assert.Equal(t, "localhost:8443", tlsAddress("https://localhost:8443/path"))
+	assert.Equal(t, "127.0.0.1:8443", tlsAddress("127.0.0.1:8443"))
+}
+
+func TestCheckTLS(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		minVersion   uint16
+		acceptsTLS10 bool
+	}{
+		{"modern", tls.VersionTLS12, false},
+		{"legacy", tls.VersionTLS10, true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewUnstartedServer(http.NotFoundHandler())
+			server.TLS = &tls.Config{MinVersion: tc.minVersion} // #nosec G402 -- legacy server under test
+			server.StartTLS()
+			defer server.Close()
+
+			result, err := CheckTLSE(server.URL)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.acceptsTLS10, result.Versions["TLS 1.0"], "TLS 1.0 accepted")
+			assert.True(t, result.Versions["TLS 1.2"], "TLS 1.2 accepted")
+			assert.True(t, result.Versions["TLS 1.3"], "TLS 1.3 accepted")
+			assert.NotEmpty(t, result.ChainError, "Self-signed test certificate should fail chain verification")
+		})
+	}
+}
+
+func TestPlainHTTPURL(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "http://ca-app.eastus2.azurecontainerapps.io/", plainHTTPURL("https://ca-app.eastus2.azurecontainerapps.io"))
+	assert.Equal(t, "http://ca-app.eastus2.azurecontainerapps.io/", plainHTTPURL("ca-app.eastus2.azurecontainerapps.io"))
+	assert.Equal(t, "http://127.0.0.1:8080/", plainHTTPURL("http://127.0.0.1:8080/health"))
+}
+
+func TestCheckPlainHTTP(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		status   int
+		enforces bool
+	}{
+		{"redirect_to_https", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), http.StatusMovedPermanently)
+		}, http.StatusMovedPermanently, true},
+		{"redirect_to_http", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://"+r.Host+"/other", http.StatusFound)
+		}, http.StatusFound, false},
+		{"served", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}, http.StatusOK, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(tc.handler)
+			defer server.Close()
+
+			result, err := CheckPlainHTTPE(server.URL)
+			if assert.NoError(t, err) {
+				assert.False(t, result.Refused)
+				assert.Equal(t, tc.status, result.StatusCode)
+				assert.Equal(t, tc.enforces, result.EnforcesHTTPS())
+			}
+		})
+	}
+
+	t.Run("refused", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.NotFoundHandler())
+		endpoint := server.URL
+		server.Close()
+
+		result, err := CheckPlainHTTPE(endpoint)
+		if assert.NoError(t, err) {
+			assert.True(t, result.Refused)
+			assert.True(t, result.EnforcesHTTPS(), "refusing plain HTTP enforces HTTPS")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+// traceIDPattern matches a W3C trace ID, which Application Insights uses as
+// the operation ID
+var traceIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Traceparent is a W3C trace context: the trace and the caller's span
+type Traceparent struct {
+	TraceID  string
+	ParentID string
+}
+
+// NewTraceparent starts a sampled trace with random trace and span IDs
+func NewTraceparent() Traceparent {
+	return Traceparent{TraceID: randomHex(16), ParentID: randomHex(8)}
+}
+
+// String renders the traceparent header value
+func (p Traceparent) String() string {
+	return fmt.Sprintf("00-%s-%s-01", p.TraceID, p.ParentID)
+}
+
+func randomHex(size int) string {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %v", err))
+	}
+	return hex.EncodeToString(value)
+}
+
+// TraceSpan is a request or dependency recorded by Application Insights
+type TraceSpan struct {
+	// Table is AppRequests or AppDependencies
+	Table       string
+	ID          string
+	ParentID    string
+	OperationID string
+	Role        string
+	Name        string
+	ResultCode  string
+}
+
+// traceSpansFromRows converts the rows of the TraceSpansE query
+func traceSpansFromRows(rows []map[string]interface{}) []TraceSpan {
+	column := func(row map[string]interface{}, name string) string {
+		if value, ok := row[name]; ok && value != nil {
+			return fmt.Sprint(value)
+		}
+		return ""
+	}
+
+	spans := make([]TraceSpan, 0, len(rows))
+	for _, row := range rows {
+		spans = append(spans, TraceSpan{
+			Table:       column(row, "SourceTable"),
+			ID:          column(row, "Id"),
+			ParentID:    column(row, "ParentId"),
+			OperationID: column(row, "OperationId"),
+			Role:        column(row, "AppRoleName"),
+			Name:        column(row, "Name"),
+			ResultCode:  column(row, "ResultCode"),
+		})
+	}
+	return spans
+}
+
+// TraceSpansE returns the requests and dependencies of the operation with
+// the given ID (the W3C trace ID) from the Log Analytics workspace backing a
+// workspace-based Application Insights resource
+func TraceSpansE(t *testing.T, workspaceID, operationID string) ([]TraceSpan, error) {
+	if !traceIDPattern.MatchString(operationID) {
+		return nil, fmt.Errorf("operation ID %q is not a W3C trace ID", operationID)
+	}
+	query := fmt.Sprintf(`union withsource=SourceTable AppRequests, AppDependencies
+| where OperationId == "%s"
+| project SourceTable, Id, ParentId, OperationId, AppRoleName, Name, ResultCode`, operationID)
+
+	rows, err := QueryLogAnalyticsE(t, workspaceID, query)
+	if err != nil {
+		return nil, err
+	}
+	return traceSpansFromRows(rows), nil
+}
+
+// FindSpan returns the span of table with the given ID, or nil
+func FindSpan(spans []TraceSpan, table, id string) *TraceSpan {
+	for i := range spans {
+		if spans[i].Table == table && spans[i].ID == id {
+			return &spans[i]
+		}
+	}
+	return nil
+}
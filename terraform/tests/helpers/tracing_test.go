@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTraceparent(t *testing.T) {
+	t.Parallel()
+
+	first, second := NewTraceparent(), NewTraceparent()
+	assert.Regexp(t, regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`), first.String())
+	assert.NotEqual(t, first.TraceID, second.TraceID)
+	assert.NotEqual(t, first.ParentID, second.ParentID)
+}
+
+func TestTraceSpansFromRows(t *testing.T) {
+	t.Parallel()
+
+	spans := traceSpansFromRows([]map[string]interface{}{
+		{
+			"SourceTable": "AppRequests", "Id": "b7ad6b7169203331", "ParentId": "00f067aa0ba902b7",
+			"OperationId": "0af7651916cd43dd8448eb211c80319c", "AppRoleName": "ca-frontend",
+			"Name": "GET /trace", "ResultCode": "200",
+		},
+		{"SourceTable": "AppDependencies", "Id": "53995c3f42cd8ad8", "ParentId": nil},
+	})
+
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, TraceSpan{
+			Table:       "AppRequests",
+			ID:          "b7ad6b7169203331",
+			ParentID:    "00f067aa0ba902b7",
+			OperationID: "0af7651916cd43dd8448eb211c80319c",
+			Role:        "ca-frontend",
+			Name:        "GET /trace",
+			ResultCode:  "200",
+		}, spans[0])
+		assert.Empty(t, spans[1].ParentID)
+	}
+
+	assert.Equal(t, &spans[1], FindSpan(spans, "AppDependencies", "53995c3f42cd8ad8"))
+	assert.Nil(t, FindSpan(spans, "AppRequests", "53995c3f42cd8ad8"))
+}
+
+func TestTraceSpansRejectsInvalidOperationID(t *testing.T) {
+	t.Parallel()
+
+	_, err := TraceSpansE(t, "workspace", `x" or true or "`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,289 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+const (
+	// webhookReceiverFixture is the fixture SharedWebhookReceiver deploys
+	webhookReceiverFixture = "./fixtures/webhook-receiver"
+	// webhookQueueBatch is the most messages the queue hands out at once
+	webhookQueueBatch = 32
+	// webhookPollInterval is how often WaitForWebhookE reads the queue
+	webhookPollInterval = 15 * time.Second
+)
+
+// WebhookReceiver is the run's webhook fixture app and the storage queue it
+// records deliveries on
+type WebhookReceiver struct {
+	URL               string
+	ResourceGroupName string
+	// RegistryName is the receiver's own registry, which tests can point
+	// ACR webhooks at
+	RegistryName   string
+	StorageAccount string
+	Queue          string
+	// token keeps stray internet traffic off the queue; it is no credential,
+	// since the receiver only records what it is sent
+	token string
+}
+
+// HookURL is the address to give a sender: a budget, action group or ACR
+// webhook. source names the deliveries, so each test should use its own
+func (r *WebhookReceiver) HookURL(source string) string {
+	return fmt.Sprintf("%s/hooks/%s?%s", r.URL, url.PathEscape(source), url.Values{"token": {r.token}}.Encode())
+}
+
+// WebhookPayload is a delivery recorded by the receiver
+type WebhookPayload struct {
+	Source      string            `json:"source"`
+	ReceivedAt  time.Time         `json:"received_at"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`
+	// Truncated is set when the body did not fit in a queue message
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// DecodeJSON decodes the body of a JSON delivery into value
+func (p WebhookPayload) DecodeJSON(value interface{}) error {
+	if p.Truncated {
+		return fmt.Errorf("%s delivery of %s was truncated", p.Source, p.ReceivedAt.Format(time.RFC3339))
+	}
+	return json.Unmarshal([]byte(p.Body), value)
+}
+
+// decodeWebhookMessage reads a queue message written by the receiver:
+// base64-encoded JSON, or plain JSON when the CLI already decoded it
+func decodeWebhookMessage(content string) (WebhookPayload, error) {
+	var payload WebhookPayload
+	raw := []byte(content)
+	if decoded, err := base64.StdEncoding.DecodeString(content); err == nil {
+		raw = decoded
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return payload, fmt.Errorf("decoding webhook message: %w", err)
+	}
+	if payload.Source == "" {
+		return payload, fmt.Errorf("webhook message has no source")
+	}
+	return payload, nil
+}
+
+// webhookInbox holds deliveries read off the queue by source. Every test of
+// the run reads the same queue, so whichever reads it keeps the other tests'
+// deliveries for them
+var (
+	webhookInboxMu sync.Mutex
+	webhookInbox   = map[string][]WebhookPayload{}
+)
+
+// SharedWebhookReceiverE returns the run's webhook receiver, deploying it on
+// first use. Later tests reuse it, or get the first deployment's error; it
+// is destroyed by DestroySharedFixturesE at the end of the run
+func SharedWebhookReceiverE(t *testing.T) (*WebhookReceiver, error) {
+	receiver, err := acquireSharedE(t, "webhook-receiver", func(t *testing.T) (interface{}, error) {
+		return deployWebhookReceiverE(t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return receiver.(*WebhookReceiver), nil
+}
+
+// SharedWebhookReceiver returns the run's webhook receiver and fails the
+// test when it could not be deployed
+func SharedWebhookReceiver(t *testing.T) *WebhookReceiver {
+	receiver, err := SharedWebhookReceiverE(t)
+	if err != nil {
+		t.Fatalf("Webhook receiver: %v", err)
+	}
+	return receiver
+}
+
+// deployWebhookReceiverE applies the receiver fixture in two steps, like the
+// other echo fixtures, and waits until a delivery reaches the queue: the app's
+// role on the queue takes a few minutes to apply
+func deployWebhookReceiverE(t *testing.T) (*WebhookReceiver, error) {
+	config := newTestConfig(t)
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	tags := StandardTags("shared/webhook-receiver")
+	options := DefaultTerraformOptions(t, webhookReceiverFixture, map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("hook"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"webhook_token":       hex.EncodeToString(token),
+		"tags":                tags,
+	})
+	workspace := WorkspaceName(RunID(), "shared-webhook-receiver")
+	if !useWorkspace(t, options, workspace) {
+		workspace = ""
+	}
+	registerSharedFixture("webhook-receiver", options, workspace)
+
+	if _, err := terraform.InitAndApplyE(t, options); err != nil {
+		return nil, err
+	}
+	loginServer := terraform.Output(t, options, "registry_login_server")
+	image, err := BuildFixtureImageE(t, "webhook", loginServer)
+	if err != nil {
+		return nil, err
+	}
+	options.Vars["container_image"] = image.Reference
+	if _, err := terraform.ApplyE(t, options); err != nil {
+		return nil, err
+	}
+	outputs, err := terraform.OutputAllE(t, options)
+	if err != nil {
+		return nil, err
+	}
+
+	receiver := &WebhookReceiver{
+		URL:               fmt.Sprint(outputs["application_url"]),
+		ResourceGroupName: options.Vars["resource_group_name"].(string),
+		RegistryName:      strings.SplitN(loginServer, ".", 2)[0],
+		StorageAccount:    fmt.Sprint(outputs["storage_account_name"]),
+		Queue:             fmt.Sprint(outputs["queue_name"]),
+		token:             hex.EncodeToString(token),
+	}
+	if _, err := CheckEndpointReadyE(t, receiver.URL, precheckRetries, precheckInterval); err != nil {
+		return nil, err
+	}
+
+	source := WorkspaceName("ready", RunID())
+	_, err = retry.DoWithRetryE(t, "delivering a test webhook", 20, webhookPollInterval, func() (string, error) {
+		return "", PostWebhookE(receiver.HookURL(source), map[string]string{"run_id": RunID()})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("the receiver does not record deliveries: %w", err)
+	}
+	if _, err := WaitForWebhookE(t, receiver, source, nil, 5*time.Minute); err != nil {
+		return nil, err
+	}
+	return receiver, nil
+}
+
+// PostWebhookE sends body as JSON to a hook URL, the way a sender would
+func PostWebhookE(hookURL string, body interface{}) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	response, err := client.Post(hookURL, "application/json", bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("receiver answered %d", response.StatusCode)
+	}
+	return nil
+}
+
+// queueMessage is the part of `az storage message get` output the drain reads
+type queueMessage struct {
+	ID         string `json:"id"`
+	PopReceipt string `json:"popReceipt"`
+	Content    string `json:"content"`
+}
+
+// drainWebhookQueueE moves every message on the receiver's queue into the
+// inbox and deletes it from the queue
+func drainWebhookQueueE(t *testing.T, receiver *WebhookReceiver) error {
+	webhookInboxMu.Lock()
+	defer webhookInboxMu.Unlock()
+
+	queue := []string{"--account-name", receiver.StorageAccount, "--queue-name", receiver.Queue, "--auth-mode", "login"}
+	for {
+		var messages []queueMessage
+		if err := AzCLIJSONE(t, &messages, append([]string{"storage", "message", "get",
+			"--num-messages", fmt.Sprint(webhookQueueBatch), "--visibility-timeout", "120"}, queue...)...); err != nil {
+			return fmt.Errorf("reading webhook queue: %w", err)
+		}
+		for _, message := range messages {
+			payload, err := decodeWebhookMessage(message.Content)
+			if err != nil {
+				t.Logf("Dropping queue message %s: %v", message.ID, err)
+			} else {
+				webhookInbox[payload.Source] = append(webhookInbox[payload.Source], payload)
+			}
+			if _, err := AzCLIE(t, append([]string{"storage", "message", "delete",
+				"--id", message.ID, "--pop-receipt", message.PopReceipt}, queue...)...); err != nil {
+				return fmt.Errorf("deleting queue message %s: %w", message.ID, err)
+			}
+		}
+		if len(messages) < webhookQueueBatch {
+			return nil
+		}
+	}
+}
+
+// ReceivedWebhooksE returns every delivery for source recorded so far,
+// oldest first
+func ReceivedWebhooksE(t *testing.T, receiver *WebhookReceiver, source string) ([]WebhookPayload, error) {
+	if err := drainWebhookQueueE(t, receiver); err != nil {
+		return nil, err
+	}
+	webhookInboxMu.Lock()
+	defer webhookInboxMu.Unlock()
+	return sortedWebhooks(webhookInbox[source]), nil
+}
+
+// sortedWebhooks returns a copy of payloads in delivery order
+func sortedWebhooks(payloads []WebhookPayload) []WebhookPayload {
+	sorted := append([]WebhookPayload{}, payloads...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ReceivedAt.Before(sorted[j].ReceivedAt) })
+	return sorted
+}
+
+// firstWebhook returns the oldest payload match accepts; a nil match
+// accepts any
+func firstWebhook(payloads []WebhookPayload, match func(WebhookPayload) bool) (WebhookPayload, bool) {
+	for _, payload := range sortedWebhooks(payloads) {
+		if match == nil || match(payload) {
+			return payload, true
+		}
+	}
+	return WebhookPayload{}, false
+}
+
+// WaitForWebhookE waits up to timeout for a delivery for source that match
+// accepts (any, when match is nil) and returns the oldest one. Budget alerts
+// and action groups can take several minutes to fire, so give them a
+// generous timeout
+func WaitForWebhookE(t *testing.T, receiver *WebhookReceiver, source string, match func(WebhookPayload) bool, timeout time.Duration) (WebhookPayload, error) {
+	var found WebhookPayload
+	attempts := int(timeout/webhookPollInterval) + 1
+	_, err := retry.DoWithRetryE(t, fmt.Sprintf("waiting for a %s webhook", source), attempts, webhookPollInterval, func() (string, error) {
+		payloads, err := ReceivedWebhooksE(t, receiver, source)
+		if err != nil {
+			return "", err
+		}
+		payload, ok := firstWebhook(payloads, match)
+		if !ok {
+			return "", fmt.Errorf("%d %s deliveries, none matching", len(payloads), source)
+		}
+		found = payload
+		return "", nil
+	})
+	return found, err
+}
@@ -0,0 +1,96 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookReceiverHookURL(t *testing.T) {
+	t.Parallel()
+
+	receiver := &WebhookReceiver{URL: "https://ca-hook.example.io", token: "a&b"}
+	assert.Equal(t, "https://ca-hook.example.io/hooks/budget-abc?token=a%26b", receiver.HookURL("budget-abc"))
+}
+
+func TestDecodeWebhookMessage(t *testing.T) {
+	t.Parallel()
+
+	message := `{"source":"budget-abc","received_at":"2026-10-18T10:00:00Z","headers":{"User-Agent":"Azure-Notifications"},"body":"{\"schemaId\":\"AIP Budget Notification\"}"}`
+
+	for name, content := range map[string]string{
+		"base64": base64.StdEncoding.EncodeToString([]byte(message)),
+		"plain":  message,
+	} {
+		payload, err := decodeWebhookMessage(content)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, "budget-abc", payload.Source, name)
+			assert.Equal(t, "Azure-Notifications", payload.Headers["User-Agent"], name)
+
+			var body struct {
+				SchemaID string `json:"schemaId"`
+			}
+			if assert.NoError(t, payload.DecodeJSON(&body), name) {
+				assert.Equal(t, "AIP Budget Notification", body.SchemaID, name)
+			}
+		}
+	}
+
+	_, err := decodeWebhookMessage("not a delivery")
+	assert.Error(t, err)
+	_, err = decodeWebhookMessage(`{"body":"{}"}`)
+	assert.Error(t, err, "a message without source cannot be routed to a test")
+}
+
+func TestWebhookPayloadDecodeJSONTruncated(t *testing.T) {
+	t.Parallel()
+
+	payload := WebhookPayload{Source: "acr", Body: `{"action":`, Truncated: true}
+	var body map[string]interface{}
+	assert.ErrorContains(t, payload.DecodeJSON(&body), "truncated")
+}
+
+func TestFirstWebhook(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	payloads := []WebhookPayload{
+		{Source: "acr", ReceivedAt: start.Add(2 * time.Minute), Body: "push"},
+		{Source: "acr", ReceivedAt: start, Body: "ping"},
+		{Source: "acr", ReceivedAt: start.Add(time.Minute), Body: "push"},
+	}
+
+	first, ok := firstWebhook(payloads, nil)
+	if assert.True(t, ok) {
+		assert.Equal(t, "ping", first.Body)
+	}
+	push, ok := firstWebhook(payloads, func(p WebhookPayload) bool { return p.Body == "push" })
+	if assert.True(t, ok) {
+		assert.Equal(t, start.Add(time.Minute), push.ReceivedAt)
+	}
+	_, ok = firstWebhook(payloads, func(p WebhookPayload) bool { return p.Body == "delete" })
+	assert.False(t, ok)
+	assert.Equal(t, "push", payloads[0].Body, "the caller's slice keeps its order")
+}
+
+func TestPostWebhookE(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if r.URL.Query().Get("token") != "x" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	assert.NoError(t, PostWebhookE(server.URL+"/hooks/test?token=x", map[string]string{"a": "b"}))
+	assert.ErrorContains(t, PostWebhookE(server.URL+"/hooks/test?token=y", map[string]string{"a": "b"}), "401")
+}
@@ -0,0 +1,293 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/armid"
+)
+
+// WhatIfChange is an entry of `az deployment group what-if` output: what
+// Resource Manager would do to one resource if the template were deployed
+type WhatIfChange struct {
+	ResourceID string `json:"resourceId"`
+	// ChangeType is Create, Delete, Modify, NoChange, Deploy, Ignore or
+	// Unsupported
+	ChangeType string               `json:"changeType"`
+	Delta      []WhatIfPropertyDiff `json:"delta"`
+	// UnsupportedReason explains an Unsupported change type
+	UnsupportedReason string `json:"unsupportedReason,omitempty"`
+}
+
+// WhatIfPropertyDiff is a property change predicted by What-If
+type WhatIfPropertyDiff struct {
+	Path string `json:"path"`
+	// PropertyChangeType is Create, Delete, Modify, Array or NoEffect
+	PropertyChangeType string               `json:"propertyChangeType"`
+	Children           []WhatIfPropertyDiff `json:"children"`
+}
+
+// WhatIfDivergence is a resource terraform and Resource Manager disagree on
+type WhatIfDivergence struct {
+	ResourceID string `json:"resource_id"`
+	// Terraform is what the plan does: none, update, replace, delete, or
+	// unmanaged for resources only Resource Manager knows about
+	Terraform string `json:"terraform"`
+	// ARM is what What-If predicts: none, create, modify or delete, or
+	// absent when the exported template left the resource out
+	ARM string `json:"arm"`
+	// Properties are the paths What-If predicts to change
+	Properties []string `json:"properties,omitempty"`
+}
+
+func (d WhatIfDivergence) String() string {
+	message := fmt.Sprintf("%s: terraform %s, ARM What-If %s", d.ResourceID, d.Terraform, d.ARM)
+	if len(d.Properties) > 0 {
+		message += " (" + strings.Join(d.Properties, ", ") + ")"
+	}
+	return message
+}
+
+// EffectivePaths returns the property paths a change really alters, leaving
+// out NoEffect entries: read-only or normalized properties What-If lists but
+// Resource Manager would not change
+func (c WhatIfChange) EffectivePaths() []string {
+	var paths []string
+	var walk func(prefix string, diffs []WhatIfPropertyDiff)
+	walk = func(prefix string, diffs []WhatIfPropertyDiff) {
+		for _, diff := range diffs {
+			path := diff.Path
+			if prefix != "" {
+				path = prefix + "." + diff.Path
+			}
+			switch diff.PropertyChangeType {
+			case "NoEffect":
+			case "Array", "Modify":
+				if len(diff.Children) > 0 {
+					walk(path, diff.Children)
+					continue
+				}
+				paths = append(paths, path)
+			default:
+				paths = append(paths, path)
+			}
+		}
+	}
+	walk("", c.Delta)
+	sort.Strings(paths)
+	return paths
+}
+
+// armAction reduces a What-If change to none, create, modify or delete, or ""
+// when What-If cannot tell (Ignore, Unsupported)
+func (c WhatIfChange) armAction() string {
+	switch c.ChangeType {
+	case "NoChange", "Deploy":
+		return "none"
+	case "Modify":
+		if len(c.EffectivePaths()) == 0 {
+			return "none"
+		}
+		return "modify"
+	case "Create":
+		return "create"
+	case "Delete":
+		return "delete"
+	}
+	return ""
+}
+
+// TemplateResourceID returns the ID of a resource of an exported resource
+// group template, e.g. Microsoft.KeyVault/vaults/secrets "kv/app" becomes
+// .../providers/Microsoft.KeyVault/vaults/kv/secrets/app, or "" when the name
+// is a template expression
+func TemplateResourceID(subscriptionID, resourceGroupName, resourceType, name string) string {
+	if strings.HasPrefix(name, "[") {
+		return ""
+	}
+	id, err := armid.NewResourceID(subscriptionID, resourceGroupName, resourceType, strings.Split(name, "/")...)
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
+
+// SetTemplateTagsE sets the tags of every resource of an exported template
+// whose ID is in tags, mirroring an in-place tag update terraform plans.
+// Returns the number of resources changed
+func SetTemplateTagsE(template map[string]interface{}, subscriptionID, resourceGroupName string, tags map[string]map[string]interface{}) (int, error) {
+	resources, ok := template["resources"].([]interface{})
+	if !ok {
+		return 0, fmt.Errorf("template has no resources")
+	}
+
+	wanted := map[string]map[string]interface{}{}
+	for id, resourceTags := range tags {
+		wanted[strings.ToLower(id)] = resourceTags
+	}
+
+	changed := 0
+	for _, entry := range resources {
+		resource, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resourceType, _ := resource["type"].(string)
+		name, _ := resource["name"].(string)
+		id := TemplateResourceID(subscriptionID, resourceGroupName, resourceType, name)
+		if resourceTags, exists := wanted[strings.ToLower(id)]; exists {
+			resource["tags"] = resourceTags
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// PlannedTagsE returns the tags after an in-place update, by Azure resource
+// ID, of every resource a plan (JSON from `terraform show -json`) updates
+func PlannedTagsE(planJSON string) (map[string]map[string]interface{}, error) {
+	changes, err := planResourceChangesE(planJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]map[string]interface{}{}
+	for _, change := range changes {
+		if strings.Join(change.Change.Actions, ",") != "update" {
+			continue
+		}
+		id, _ := change.Change.Before["id"].(string)
+		after, ok := change.Change.After["tags"].(map[string]interface{})
+		if id != "" && ok {
+			tags[id] = after
+		}
+	}
+	return tags, nil
+}
+
+// terraformAction reduces plan actions to none, update, replace or delete,
+// or "" for actions What-If has nothing to compare with
+func terraformAction(actions []string) string {
+	switch strings.Join(actions, ",") {
+	case "no-op":
+		return "none"
+	case "update":
+		return "update"
+	case "delete,create", "create,delete":
+		return "replace"
+	case "delete":
+		return "delete"
+	}
+	return ""
+}
+
+// CompareWhatIfE matches the resources of a plan (JSON from `terraform show
+// -json`) against What-If changes by Azure resource ID and returns those
+// they disagree on: terraform changes a resource What-If leaves alone or the
+// other way round, What-If creates a resource terraform already manages,
+// What-If changes a resource terraform does not manage, or terraform changes
+// a resource the template lacks. Resources What-If cannot predict (Ignore,
+// Unsupported) are skipped
+func CompareWhatIfE(planJSON string, whatIf []WhatIfChange) ([]WhatIfDivergence, error) {
+	changes, err := planResourceChangesE(planJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// Azure resource IDs are case-insensitive; planned is keyed by the
+	// lowercased ID and keeps terraform's spelling for reporting
+	type plannedChange struct{ id, action string }
+	planned := map[string]plannedChange{}
+	for _, change := range changes {
+		id, _ := change.Change.Before["id"].(string)
+		if action := terraformAction(change.Change.Actions); id != "" && action != "" {
+			planned[strings.ToLower(id)] = plannedChange{id, action}
+		}
+	}
+
+	divergences := []WhatIfDivergence{}
+	for _, change := range whatIf {
+		id := strings.ToLower(change.ResourceID)
+		plan, managed := planned[id]
+		delete(planned, id)
+		terraform := plan.action
+		arm := change.armAction()
+		if arm == "" {
+			continue
+		}
+		if !managed {
+			terraform = "unmanaged"
+		}
+
+		agree := false
+		switch arm {
+		case "none":
+			agree = !managed || terraform == "none"
+		case "modify":
+			agree = terraform == "update" || terraform == "replace"
+		case "delete":
+			agree = terraform == "delete"
+		}
+		// A create never agrees: every exported resource exists, so What-If
+		// and terraform disagree on its identity
+		if !agree {
+			divergences = append(divergences, WhatIfDivergence{
+				ResourceID: change.ResourceID,
+				Terraform:  terraform,
+				ARM:        arm,
+				Properties: change.EffectivePaths(),
+			})
+		}
+	}
+
+	// Resources terraform changes that the export left out, so What-If
+	// could not predict anything for them
+	for _, plan := range planned {
+		if plan.action != "none" {
+			divergences = append(divergences, WhatIfDivergence{ResourceID: plan.id, Terraform: plan.action, ARM: "absent"})
+		}
+	}
+	sort.Slice(divergences, func(i, j int) bool {
+		return strings.ToLower(divergences[i].ResourceID) < strings.ToLower(divergences[j].ResourceID)
+	})
+	return divergences, nil
+}
+
+// ExportResourceGroupTemplateE exports the ARM template of everything in a
+// resource group, with literal names and values instead of parameters
+func ExportResourceGroupTemplateE(t *testing.T, resourceGroupName string) (map[string]interface{}, error) {
+	var template map[string]interface{}
+	if err := AzCLIJSONE(t, &template, "group", "export", "--name", resourceGroupName,
+		"--skip-all-params", "--include-comments", "false"); err != nil {
+		return nil, fmt.Errorf("exporting template of %s: %w", resourceGroupName, err)
+	}
+	return template, nil
+}
+
+// WhatIfE runs ARM What-If for deploying template to a resource group in
+// Incremental mode and returns the predicted changes
+func WhatIfE(t *testing.T, resourceGroupName string, template map[string]interface{}) ([]WhatIfChange, error) {
+	content, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(t.TempDir(), "template.json")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Changes []WhatIfChange `json:"changes"`
+	}
+	if err := AzCLIJSONE(t, &result, "deployment", "group", "what-if", "--resource-group", resourceGroupName,
+		"--template-file", path, "--mode", "Incremental", "--result-format", "FullResourcePayloads",
+		"--no-pretty-print"); err != nil {
+		return nil, fmt.Errorf("running What-If in %s: %w", resourceGroupName, err)
+	}
+	return result.Changes, nil
+}
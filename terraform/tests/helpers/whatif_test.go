@@ -0,0 +1,112 @@
+package helpers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const whatIfTestGroup = "/subscriptions/0000/resourceGroups/rg-whatif/providers/"
+
+func TestTemplateResourceID(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, whatIfTestGroup+"Microsoft.KeyVault/vaults/kv-a",
+		TemplateResourceID("0000", "rg-whatif", "Microsoft.KeyVault/vaults", "kv-a"))
+	assert.Equal(t, whatIfTestGroup+"Microsoft.KeyVault/vaults/kv-a/secrets/app",
+		TemplateResourceID("0000", "rg-whatif", "Microsoft.KeyVault/vaults/secrets", "kv-a/app"))
+	assert.Empty(t, TemplateResourceID("0000", "rg-whatif", "Microsoft.KeyVault/vaults/secrets", "[concat(parameters('vault'), '/app')]"))
+	assert.Empty(t, TemplateResourceID("0000", "rg-whatif", "Microsoft.KeyVault/vaults/secrets", "kv-a"))
+}
+
+func TestSetTemplateTagsE(t *testing.T) {
+	t.Parallel()
+
+	template := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{"resources": [
+		{"type": "Microsoft.KeyVault/vaults", "name": "kv-a", "tags": {"Environment": "test"}},
+		{"type": "Microsoft.KeyVault/vaults/secrets", "name": "kv-a/app"}
+	]}`), &template); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := SetTemplateTagsE(template, "0000", "rg-whatif", map[string]map[string]interface{}{
+		whatIfTestGroup + "microsoft.keyvault/vaults/KV-A": {"Environment": "retagged"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, changed)
+		resources := template["resources"].([]interface{})
+		assert.Equal(t, map[string]interface{}{"Environment": "retagged"}, resources[0].(map[string]interface{})["tags"])
+		assert.NotContains(t, resources[1].(map[string]interface{}), "tags")
+	}
+}
+
+func TestPlannedTagsE(t *testing.T) {
+	t.Parallel()
+
+	planJSON := `{"resource_changes": [
+		{"address": "module.key_vault.azurerm_key_vault.this", "change": {"actions": ["update"],
+			"before": {"id": "` + whatIfTestGroup + `Microsoft.KeyVault/vaults/kv-a", "tags": {"Environment": "test"}},
+			"after": {"id": "` + whatIfTestGroup + `Microsoft.KeyVault/vaults/kv-a", "tags": {"Environment": "retagged"}}}},
+		{"address": "module.key_vault.azurerm_role_assignment.deployer", "change": {"actions": ["no-op"],
+			"before": {"id": "/role"}, "after": {"id": "/role"}}}
+	]}`
+	tags, err := PlannedTagsE(planJSON)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]map[string]interface{}{
+			whatIfTestGroup + "Microsoft.KeyVault/vaults/kv-a": {"Environment": "retagged"},
+		}, tags)
+	}
+}
+
+func TestWhatIfChangeEffectivePaths(t *testing.T) {
+	t.Parallel()
+
+	change := WhatIfChange{ChangeType: "Modify", Delta: []WhatIfPropertyDiff{
+		{Path: "properties.provisioningState", PropertyChangeType: "NoEffect"},
+		{Path: "tags", PropertyChangeType: "Modify", Children: []WhatIfPropertyDiff{
+			{Path: "Environment", PropertyChangeType: "Modify"},
+			{Path: "CostCenter", PropertyChangeType: "Create"},
+		}},
+	}}
+	assert.Equal(t, []string{"tags.CostCenter", "tags.Environment"}, change.EffectivePaths())
+
+	noise := WhatIfChange{ChangeType: "Modify", Delta: []WhatIfPropertyDiff{
+		{Path: "properties.provisioningState", PropertyChangeType: "NoEffect"},
+	}}
+	assert.Equal(t, "none", noise.armAction())
+}
+
+func TestCompareWhatIfE(t *testing.T) {
+	t.Parallel()
+
+	vault := whatIfTestGroup + "Microsoft.KeyVault/vaults/kv-a"
+	registry := whatIfTestGroup + "Microsoft.ContainerRegistry/registries/acra"
+	workspace := whatIfTestGroup + "Microsoft.OperationalInsights/workspaces/log-a"
+	diagnostics := whatIfTestGroup + "Microsoft.KeyVault/vaults/kv-a/providers/Microsoft.Insights/diagnosticSettings/diag"
+	planJSON := `{"resource_changes": [
+		{"address": "a", "change": {"actions": ["update"], "before": {"id": "` + vault + `"}}},
+		{"address": "b", "change": {"actions": ["update"], "before": {"id": "` + registry + `"}}},
+		{"address": "c", "change": {"actions": ["no-op"], "before": {"id": "` + workspace + `"}}},
+		{"address": "d", "change": {"actions": ["update"], "before": {"id": "` + diagnostics + `"}}},
+		{"address": "e", "change": {"actions": ["create"], "before": null}}
+	]}`
+	tagChange := []WhatIfPropertyDiff{{Path: "tags.Environment", PropertyChangeType: "Modify"}}
+	whatIf := []WhatIfChange{
+		{ResourceID: vault, ChangeType: "Modify", Delta: tagChange},
+		{ResourceID: registry, ChangeType: "NoChange"},
+		{ResourceID: workspace, ChangeType: "Modify", Delta: tagChange},
+		{ResourceID: whatIfTestGroup + "Microsoft.Network/networkWatchers/nw", ChangeType: "NoChange"},
+		{ResourceID: whatIfTestGroup + "Microsoft.Web/sites/unsupported", ChangeType: "Unsupported"},
+	}
+
+	divergences, err := CompareWhatIfE(planJSON, whatIf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []WhatIfDivergence{
+			{ResourceID: registry, Terraform: "update", ARM: "none"},
+			{ResourceID: diagnostics, Terraform: "update", ARM: "absent"},
+			{ResourceID: workspace, Terraform: "none", ARM: "modify", Properties: []string{"tags.Environment"}},
+		}, divergences)
+	}
+}
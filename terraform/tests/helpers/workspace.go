@@ -0,0 +1,208 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// maxWorkspaceNameLength keeps workspace state keys well inside blob name limits
+const maxWorkspaceNameLength = 90
+
+// sharedBackendConfig is written next to a copied root module so its state
+// lives in the shared test backend
+const sharedBackendConfig = `terraform {
+  backend "azurerm" {}
+}
+`
+
+// localBackendOverride moves the state of a copied root module that declares
+// a backend of its own, such as an environment, to a local file. Terraform
+// merges *_override.tf files over the module's own
+const localBackendOverride = `terraform {
+  backend "local" {}
+}
+`
+
+var invalidWorkspaceChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// WorkspaceName derives a terraform workspace name from the run ID and test
+// name, e.g. "abc123-testkeyvaultbasic-subtest"
+func WorkspaceName(runID, testName string) string {
+	name := strings.ToLower(runID + "-" + testName)
+	name = strings.Trim(invalidWorkspaceChars.ReplaceAllString(name, "-"), "-")
+	if len(name) > maxWorkspaceNameLength {
+		name = strings.TrimRight(name[:maxWorkspaceNameLength], "-")
+	}
+	return name
+}
+
+// SharedBackendConfig returns the azurerm backend settings for the shared
+// test state storage, or nil if TEST_BACKEND_STORAGE_ACCOUNT is not set
+func SharedBackendConfig(stateKey string) map[string]interface{} {
+	storageAccount := os.Getenv("TEST_BACKEND_STORAGE_ACCOUNT")
+	if storageAccount == "" {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"resource_group_name":  getEnvOrDefault("TEST_BACKEND_RESOURCE_GROUP", "rg-terraform-state"),
+		"storage_account_name": storageAccount,
+		"container_name":       getEnvOrDefault("TEST_BACKEND_CONTAINER", "tfstate"),
+		"key":                  stateKey,
+		"use_azuread_auth":     true,
+	}
+}
+
+// CopyTerraformDirToTemp copies the terraform tree to a temp folder and
+// returns the copy of terraformDir, so relative module sources keep working
+func CopyTerraformDirToTemp(t *testing.T, terraformDir string) string {
+	relative, err := terraformRootRelE(terraformDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return test_structure.CopyTerraformFolderToTemp(t, "..", relative)
+}
+
+// terraformRootRelE returns the path of terraformDir relative to the
+// terraform root, which every copy made by CopyTerraformDirToTemp keeps
+func terraformRootRelE(terraformDir string) (string, error) {
+	root, err := filepath.Abs("..")
+	if err != nil {
+		return "", fmt.Errorf("resolving terraform root: %w", err)
+	}
+	dir, err := filepath.Abs(terraformDir)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", terraformDir, err)
+	}
+	relative, err := filepath.Rel(root, dir)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return "", fmt.Errorf("%s is outside the terraform root %s", terraformDir, root)
+	}
+	return relative, nil
+}
+
+// UseIsolatedWorkspace gives options state that no other test can touch.
+// The fixture is copied to a temp folder and, when TEST_BACKEND_STORAGE_ACCOUNT
+// is set, moved onto the shared test backend in a workspace dedicated to this
+// run and test. The workspace is deleted after the test once its state is
+// empty; if destroy failed it is kept for manual cleanup. With SKIP_destroy
+// the workspace is kept for later runs, and with SKIP_apply options move
+// back onto the copy and workspace a previous run kept (see Destroy).
+// Returns the workspace name, or "" when state is local
+func UseIsolatedWorkspace(t *testing.T, options *terraform.Options) string {
+	if StageSkipped("apply") {
+		restoreKeptOptions(t, options)
+		return selectedWorkspace(options)
+	}
+
+	workspace := WorkspaceName(RunID(), t.Name())
+	if !useWorkspace(t, options, workspace) {
+		t.Logf("TEST_BACKEND_STORAGE_ACCOUNT not set, %s uses local state", t.Name())
+		return ""
+	}
+	if StageSkipped("destroy") {
+		return workspace
+	}
+
+	t.Cleanup(func() {
+		if _, err := terraform.WorkspaceDeleteE(t, options, workspace); err != nil {
+			t.Logf("Keeping workspace %s in the shared backend: %v", workspace, err)
+		}
+	})
+	return workspace
+}
+
+// useWorkspace copies the fixture of options to a temp folder and, when
+// TEST_BACKEND_STORAGE_ACCOUNT is set, moves its state onto the shared test
+// backend in workspace. Root modules that declare an azurerm backend, such
+// as environments, keep theirs. Returns false when state stays local
+func useWorkspace(t *testing.T, options *terraform.Options, workspace string) bool {
+	stateKey := fmt.Sprintf("terratest/%s.tfstate", filepath.Base(options.TerraformDir))
+	options.TerraformDir = CopyTerraformDirToTemp(t, options.TerraformDir)
+	declared, err := declaredBackendE(options.TerraformDir)
+	if err != nil {
+		t.Fatalf("Reading the backend of %s: %v", options.TerraformDir, err)
+	}
+
+	backendConfig := SharedBackendConfig(stateKey)
+	if backendConfig == nil {
+		if declared != "" {
+			overrideFile := filepath.Join(options.TerraformDir, "backend_override.tf")
+			if err := os.WriteFile(overrideFile, []byte(localBackendOverride), 0o600); err != nil {
+				t.Fatalf("Writing %s: %v", overrideFile, err)
+			}
+		}
+		return false
+	}
+
+	if declared != "azurerm" {
+		// Any other backend is overridden
+		backendFile := filepath.Join(options.TerraformDir, "backend_test.tf")
+		if declared != "" {
+			backendFile = filepath.Join(options.TerraformDir, "backend_override.tf")
+		}
+		if err := os.WriteFile(backendFile, []byte(sharedBackendConfig), 0o600); err != nil {
+			t.Fatalf("Writing %s: %v", backendFile, err)
+		}
+	}
+	options.BackendConfig = backendConfig
+	options.Reconfigure = true
+
+	terraform.Init(t, options)
+	terraform.WorkspaceSelectOrNew(t, options, workspace)
+	return true
+}
+
+// selectedWorkspace returns the workspace terraform last selected in the
+// folder of options, or "" when state is local
+func selectedWorkspace(options *terraform.Options) string {
+	if options.BackendConfig == nil {
+		return ""
+	}
+	selected, err := os.ReadFile(filepath.Join(options.TerraformDir, ".terraform", "environment"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(selected))
+}
+
+// declaredBackendE returns the type of the backend the .tf files of dir
+// declare, e.g. "azurerm", or "" when they declare none
+func declaredBackendE(dir string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return "", err
+	}
+
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return "", diags
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			return "", fmt.Errorf("%s is not native HCL syntax", file)
+		}
+		for _, block := range body.Blocks {
+			if block.Type != "terraform" {
+				continue
+			}
+			for _, nested := range block.Body.Blocks {
+				if nested.Type == "backend" && len(nested.Labels) == 1 {
+					return nested.Labels[0], nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
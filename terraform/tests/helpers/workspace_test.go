@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkspaceName(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		runID    string
+		testName string
+		expected string
+	}{
+		{"simple", "abc123", "TestKeyVaultBasic", "abc123-testkeyvaultbasic"},
+		{"subtest", "abc123", "TestContainerAppRegistryAuthMatrix/system_identity", "abc123-testcontainerappregistryauthmatrix-system-identity"},
+		{"collapses_invalid_runs", "CI_42", "TestA/#01", "ci-42-testa-01"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, WorkspaceName(tc.runID, tc.testName))
+		})
+	}
+}
+
+func TestWorkspaceNameTruncates(t *testing.T) {
+	t.Parallel()
+
+	name := WorkspaceName("run", "Test"+strings.Repeat("x", 200))
+
+	assert.LessOrEqual(t, len(name), maxWorkspaceNameLength)
+	assert.False(t, strings.HasSuffix(name, "-"), "Truncated name should not end with a hyphen")
+}
+
+func TestDeclaredBackend(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"environment", "terraform {\n  backend \"azurerm\" {\n    # key = \"finrisk-dev.tfstate\"\n  }\n}\n", "azurerm"},
+		{"fixture", "terraform {\n  required_version = \">= 1.5\"\n}\n", ""},
+		{"commented", "# terraform {\n#   backend \"azurerm\" {}\n# }\n", ""},
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "backend.tf"), []byte(tc.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		backend, err := declaredBackendE(dir)
+		if assert.NoError(t, err, tc.name) {
+			assert.Equal(t, tc.expected, backend, tc.name)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// interruptAfterCreated is how many resources the tag-update fixture has
+// created when its apply is stopped: the resource group and the first
+// resources in it, while slower ones such as the Container App environment
+// are still in flight
+const interruptAfterCreated = 3
+
+// interruptedApplyReport is the interrupted_apply report entry of one mode
+type interruptedApplyReport struct {
+	Apply        helpers.InterruptedApply `json:"apply"`
+	DestroyError string                   `json:"destroy_error,omitempty"`
+	Leaks        []string                 `json:"leaks"`
+}
+
+// TestDestroyAfterInterruptedApply stops an apply of every module midway,
+// both the way a cancelled CI job does and the way a lost runner does, then
+// destroys from the partial state. The destroy must succeed and leave
+// nothing behind in Azure: this is the cleanup path of every test whose
+// apply does not finish. A killed apply can leave resources terraform no
+// longer knows about, so the test runs in the sandbox subscription when
+// ARM_SUBSCRIPTION_ID_SANDBOX is set
+func TestDestroyAfterInterruptedApply(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	for _, mode := range []helpers.InterruptMode{helpers.InterruptGracefully, helpers.InterruptKill} {
+		mode := mode
+		t.Run(string(mode), func(t *testing.T) {
+			t.Parallel()
+
+			config := helpers.NewTestConfigIn(t, helpers.SandboxSubscription)
+			cost.TrackRun(t, config)
+			resourceGroupName := config.GenerateResourceGroupName("intr")
+			terraformOptions := config.TerraformOptions(t, "./fixtures/tag-update", map[string]interface{}{
+				"resource_group_name": resourceGroupName,
+				"location":            config.Location,
+				"name_suffix":         config.UniqueID,
+				"tags":                helpers.StandardTags(t.Name()),
+			})
+			helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+			phases := helpers.TrackPhases(t)
+			report := interruptedApplyReport{}
+			defer func() { helpers.RecordReport(t, "interrupted_apply", string(mode), report) }()
+
+			phases.Start("apply")
+			applied, err := helpers.InterruptApplyE(t, terraformOptions, mode, interruptAfterCreated)
+			report.Apply = applied
+			assert.NoError(t, err, "the apply should only stop because it was interrupted")
+			assert.False(t, applied.Finished, "the apply finished before %d resources were created, so the state is not partial", interruptAfterCreated)
+
+			// Whatever happened to the apply, destroy is the only cleanup
+			phases.Start("destroy")
+			if _, err := helpers.DestroyAfterInterruptE(t, terraformOptions); err != nil {
+				report.DestroyError = err.Error()
+				t.Errorf("Destroying the state left by the %s apply: %v", mode, err)
+			}
+
+			phases.Start("verify")
+			// Deleted resources can stay listed for a few minutes
+			_, err = retry.DoWithRetryE(t, "checking for leaked resources", 10, 30*time.Second, func() (string, error) {
+				leaks, err := helpers.LeakedResourcesE(t, config.SubscriptionID, resourceGroupName, t.Name())
+				if err != nil {
+					return "", err
+				}
+				report.Leaks = leaks
+				if len(leaks) > 0 {
+					return "", fmt.Errorf("leaked:\n  %s", strings.Join(leaks, "\n  "))
+				}
+				return "", nil
+			})
+			assert.NoError(t, err, "the %s apply left resources behind after destroy; remove them with `ttk janitor`", mode)
+			// Untagged resources in the group only show up in Resource Graph,
+			// which is checked once the test ends
+			helpers.CheckGroupLeftoversAfterDestroy(t, config.SubscriptionID, resourceGroupName)
+		})
+	}
+}
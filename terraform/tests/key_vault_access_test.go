@@ -0,0 +1,129 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// keyVaultAPIVersion is the data-plane API version used for access checks
+const keyVaultAPIVersion = "7.4"
+
+// keyVaultAccessCase is one request made as a test principal and the status
+// it must get back
+type keyVaultAccessCase struct {
+	principal string
+	operation string
+	method    string
+	path      string
+	body      string
+	status    int
+}
+
+// keyVaultStatusE makes a data-plane request with token and returns the status
+func keyVaultStatusE(vaultURI, token string, c keyVaultAccessCase) (int, error) {
+	url := fmt.Sprintf("%s/%s?api-version=%s", strings.TrimRight(vaultURI, "/"), c.path, keyVaultAPIVersion)
+	request, err := http.NewRequest(c.method, url, strings.NewReader(c.body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	return response.StatusCode, nil
+}
+
+// TestKeyVaultDataPlaneAccess checks the vault's RBAC model from the outside:
+// a principal holding Key Vault Secrets User can read secrets but not write
+// them, and a principal with no role can do neither
+func TestKeyVaultDataPlaneAccess(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	const secretName = "app-config"
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/key-vault-secrets", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("kvacc"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"secrets":             map[string]string{secretName: config.UniqueID},
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	// The deployer's data-plane role can take a few minutes to propagate
+	terraformOptions.RetryableTerraformErrors[".*ForbiddenByRbac.*"] = "Key Vault role assignment not yet effective, retrying"
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	principals := map[string]*helpers.TestPrincipal{
+		"reader":   helpers.NewTestPrincipal(t, "reader"),
+		"outsider": helpers.NewTestPrincipal(t, "outsider"),
+	}
+
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	vaultID := terraform.Output(t, terraformOptions, "key_vault_id")
+	vaultURI := terraform.Output(t, terraformOptions, "vault_uri")
+	principals["reader"].AssignRole(t, "Key Vault Secrets User", vaultID)
+
+	tokens := map[string]string{}
+	for role, principal := range principals {
+		token, err := principal.AccessTokenE(t, helpers.CurrentCloud(t).KeyVaultResource)
+		if err != nil {
+			t.Fatalf("Getting a Key Vault token for %s: %v", principal, err)
+		}
+		tokens[role] = token
+	}
+
+	// The grant comes first: once it is effective the role assignment has
+	// reached the data plane, so the denials after it are meaningful
+	readPath := "secrets/" + secretName
+	writeBody := `{"value":"overwritten"}`
+	cases := []keyVaultAccessCase{
+		{principal: "reader", operation: "read secret", method: http.MethodGet, path: readPath, status: http.StatusOK},
+		{principal: "reader", operation: "write secret", method: http.MethodPut, path: readPath, body: writeBody, status: http.StatusForbidden},
+		{principal: "outsider", operation: "read secret", method: http.MethodGet, path: readPath, status: http.StatusForbidden},
+		{principal: "outsider", operation: "write secret", method: http.MethodPut, path: readPath, body: writeBody, status: http.StatusForbidden},
+	}
+
+	for i, c := range cases {
+		description := fmt.Sprintf("%s as %s", c.operation, c.principal)
+		if i == 0 {
+			retry.DoWithRetry(t, description, 18, 10*time.Second, func() (string, error) {
+				status, err := keyVaultStatusE(vaultURI, tokens[c.principal], c)
+				if err == nil && status != c.status {
+					err = fmt.Errorf("got %d, want %d", status, c.status)
+				}
+				return "", err
+			})
+			continue
+		}
+
+		status, err := keyVaultStatusE(vaultURI, tokens[c.principal], c)
+		if assert.NoError(t, err, description) {
+			assert.Equal(t, c.status, status, description)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// breakGlassHold is how long break-glass access stays open after the
+	// SLA, so it cannot expire before access had its chance to be restored
+	breakGlassHold = 2 * time.Minute
+	// breakGlassPollInterval is how often the responder tries to read
+	breakGlassPollInterval = 10 * time.Second
+	// maxBreakGlassRevertWindow is how long the responder may still read
+	// once access has been reverted
+	maxBreakGlassRevertWindow = 2 * time.Minute
+)
+
+// breakGlassReport is what TestKeyVaultBreakGlass records
+type breakGlassReport struct {
+	RestoredAfter string   `json:"restored_after"`
+	SLA           string   `json:"sla"`
+	RevertedAfter string   `json:"reverted_after"`
+	RefusedAfter  string   `json:"refused_after"`
+	Opened        []string `json:"opened"`
+	Residue       []string `json:"residue"`
+}
+
+// secretRefused reports whether a read failed because Key Vault refused it,
+// by firewall or RBAC, rather than for a transport error
+func secretRefused(err error) bool {
+	return err != nil && strings.Contains(err.Error(), fmt.Sprintf(": %d ", http.StatusForbidden))
+}
+
+// TestKeyVaultBreakGlass runs the break-glass procedure against a vault
+// locked down like production: default Deny, no IP rules, no bypass and
+// RBAC only. A responder with no role is refused; helpers.OpenBreakGlassE
+// grants it Key Vault Secrets User and a firewall exception for the runner,
+// after which its reads must succeed within helpers.BreakGlassSLA. When the
+// access expires it must be reverted on its own: the responder refused again
+// within maxBreakGlassRevertWindow, and the vault's firewall and role
+// assignments exactly as they were before. Opt in with
+// TEST_KEY_VAULT_BREAK_GLASS=true
+func TestKeyVaultBreakGlass(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_KEY_VAULT_BREAK_GLASS") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_KEY_VAULT_BREAK_GLASS=true to run the break-glass procedure against a locked-down vault")
+	}
+
+	const secretName = "break-glass-probe"
+
+	runnerIP, err := helpers.RunnerPublicIPE(t)
+	if err != nil {
+		t.Fatalf("Finding the runner's public address: %v", err)
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/key-vault-break-glass", map[string]interface{}{
+		"resource_group_name":     config.GenerateResourceGroupName("kvbg"),
+		"location":                config.Location,
+		"name_suffix":             config.UniqueID,
+		"firewall_default_action": "Allow",
+		"tags":                    helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	responder := helpers.NewTestPrincipal(t, "responder")
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	// First apply: the vault with an open firewall, to write the secret
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	vaultID := terraform.Output(t, terraformOptions, "key_vault_id")
+	vaultName := terraform.Output(t, terraformOptions, "key_vault_name")
+	vaultURI := terraform.Output(t, terraformOptions, "vault_uri")
+
+	// The deployer's data-plane role can take a few minutes to propagate
+	retry.DoWithRetry(t, "writing the probe secret", 18, 10*time.Second, func() (string, error) {
+		return helpers.AzCLIE(t, "keyvault", "secret", "set", "--vault-name", vaultName,
+			"--name", secretName, "--value", config.UniqueID, "--query", "id", "--output", "tsv")
+	})
+
+	// Second apply: lock the vault down
+	terraformOptions.Vars["firewall_default_action"] = "Deny"
+	helpers.Apply(t, terraformOptions)
+	phases.Start("verify")
+
+	var rbacOnly bool
+	helpers.AzCLIJSON(t, &rbacOnly, "keyvault", "show", "--ids", vaultID, "--query", "properties.enableRbacAuthorization")
+	if !rbacOnly {
+		t.Fatalf("%s should use RBAC only, access policies would be another way in", vaultName)
+	}
+
+	token, err := responder.AccessTokenE(t, helpers.CurrentCloud(t).KeyVaultResource)
+	if err != nil {
+		t.Fatalf("Getting a Key Vault token for %s: %v", responder, err)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	read := func() error {
+		return helpers.ReadSecretE(client, vaultURI, secretName, token)
+	}
+
+	// Control: before break-glass the responder must be refused, or access
+	// restored afterwards would prove nothing
+	retry.DoWithRetry(t, "waiting for the vault to refuse the responder", 12, 10*time.Second, func() (string, error) {
+		if err := read(); !secretRefused(err) {
+			return "", fmt.Errorf("responder not refused yet: %v", err)
+		}
+		return "", nil
+	})
+
+	baseline, err := helpers.VaultAccessE(t, vaultID)
+	if err != nil {
+		t.Fatalf("Reading the vault's access before break-glass: %v", err)
+	}
+
+	glass, err := helpers.OpenBreakGlassE(t, vaultID, responder.ObjectID, runnerIP, helpers.BreakGlassSLA+breakGlassHold)
+	if err != nil {
+		t.Fatalf("Opening break-glass access: %v", err)
+	}
+	var restoredAfter time.Duration
+	for deadline := glass.OpenedAt.Add(helpers.BreakGlassSLA); time.Now().Before(deadline); time.Sleep(breakGlassPollInterval) {
+		if err := read(); err == nil {
+			restoredAfter = time.Since(glass.OpenedAt)
+			break
+		}
+	}
+	report := breakGlassReport{SLA: helpers.BreakGlassSLA.String()}
+	if restoredAfter > 0 {
+		report.RestoredAfter = restoredAfter.Round(time.Second).String()
+	}
+
+	if opened, err := helpers.VaultAccessE(t, vaultID); assert.NoError(t, err, "reading the vault's access during break-glass") {
+		report.Opened = helpers.DiffVaultAccess(baseline, opened)
+		assert.ElementsMatch(t, []string{
+			"IP rule " + glass.IPRange + " added",
+			"role assignment " + responder.ObjectID + " " + helpers.BreakGlassRole + " added",
+		}, report.Opened, "break-glass should open exactly the firewall exception and the role assignment")
+	}
+
+	revertedAt, err := glass.Wait()
+	report.RevertedAfter = revertedAt.Sub(glass.OpenedAt).Round(time.Second).String()
+	assert.NoError(t, err, "break-glass access should revert when it expires")
+
+	var refusedAfter time.Duration
+	for deadline := revertedAt.Add(maxBreakGlassRevertWindow); time.Now().Before(deadline); time.Sleep(breakGlassPollInterval) {
+		if err := read(); secretRefused(err) {
+			refusedAfter = time.Since(revertedAt)
+			break
+		}
+	}
+	if refusedAfter > 0 {
+		report.RefusedAfter = refusedAfter.Round(time.Second).String()
+	}
+
+	if reverted, err := helpers.VaultAccessE(t, vaultID); assert.NoError(t, err, "reading the vault's access after the revert") {
+		report.Residue = helpers.DiffVaultAccess(baseline, reverted)
+	}
+	helpers.RecordReport(t, "key_vault_break_glass", config.Location, report)
+	t.Logf("Access restored after %s (SLA %s), reverted after %s, refused again after %s",
+		report.RestoredAfter, report.SLA, report.RevertedAfter, report.RefusedAfter)
+
+	assert.NotZero(t, restoredAfter, "the responder should read the secret within the %s SLA", helpers.BreakGlassSLA)
+	assert.NotZero(t, refusedAfter, "the responder should be refused within %s of the revert", maxBreakGlassRevertWindow)
+	assert.Empty(t, report.Residue, "the revert should leave the vault's firewall and role assignments as they were")
+}
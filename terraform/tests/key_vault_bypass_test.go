@@ -0,0 +1,134 @@
+package test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// keyVaultRead is the echo app's report of reading a secret with its
+// managed identity
+type keyVaultRead struct {
+	Status      int    `json:"status"`
+	Code        string `json:"code"`
+	ValueSHA256 string `json:"value_sha256"`
+	Error       string `json:"error"`
+}
+
+// TestKeyVaultTrustedServiceBypass closes the vault firewall the way the dev
+// environment does (default Deny, bypass AzureServices, no IP rules) and has
+// a Container App read a secret with its managed identity at runtime. The
+// environment relies on the bypass for this path, so the read must succeed
+// while the runner, outside Azure's trusted services, is refused
+func TestKeyVaultTrustedServiceBypass(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	const secretName = "bypass-probe"
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	secretValue := "bypass-" + config.UniqueID
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/key-vault-bypass", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("kvb"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	// First apply: vault with an open firewall and the registry
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	vaultName := terraform.Output(t, terraformOptions, "key_vault_name")
+	vaultURI := terraform.Output(t, terraformOptions, "vault_uri")
+
+	// The deployer's data-plane role can take a few minutes to propagate
+	retry.DoWithRetry(t, "writing the probe secret", 18, 10*time.Second, func() (string, error) {
+		return helpers.AzCLIE(t, "keyvault", "secret", "set", "--vault-name", vaultName,
+			"--name", secretName, "--value", secretValue, "--query", "id", "--output", "tsv")
+	})
+
+	phases.Start("build")
+	image := helpers.BuildFixtureImage(t, "echo", terraform.Output(t, terraformOptions, "registry_login_server"))
+
+	// Second apply: close the firewall and deploy the app
+	phases.Start("apply")
+	terraformOptions.Vars["firewall_default_action"] = "Deny"
+	terraformOptions.Vars["container_image"] = image.Reference
+	helpers.Apply(t, terraformOptions)
+	phases.Start("verify")
+
+	// Control: the runner is not a trusted service, so the firewall must
+	// refuse it, otherwise a successful app read would prove nothing
+	retry.DoWithRetry(t, "waiting for the vault firewall to refuse the runner", 12, 10*time.Second, func() (string, error) {
+		_, err := helpers.AzCLIE(t, "keyvault", "secret", "show", "--vault-name", vaultName,
+			"--name", secretName, "--query", "id", "--output", "tsv")
+		if err == nil {
+			return "", fmt.Errorf("runner can still read %s", vaultName)
+		}
+		if !strings.Contains(err.Error(), "ForbiddenByFirewall") {
+			return "", fmt.Errorf("runner refused for another reason: %w", err)
+		}
+		return "", nil
+	})
+
+	applicationURL := terraform.Output(t, terraformOptions, "application_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+
+	readURL := fmt.Sprintf("%s/keyvault?vault=%s&secret=%s", applicationURL, url.QueryEscape(vaultURI), secretName)
+	var read keyVaultRead
+	// The app's Key Vault Secrets User assignment can take minutes to reach
+	// the data plane; a firewall refusal will not change with time
+	_, err := retry.DoWithRetryE(t, "reading the secret from the app", 30, 20*time.Second, func() (string, error) {
+		response, err := http.Get(readURL)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s returned %d", readURL, response.StatusCode)
+		}
+		read = keyVaultRead{}
+		if err := json.NewDecoder(response.Body).Decode(&read); err != nil {
+			return "", err
+		}
+		switch {
+		case read.Status == http.StatusOK:
+			return "", nil
+		case read.Code == "ForbiddenByFirewall":
+			return "", retry.FatalError{Underlying: fmt.Errorf("vault firewall refused the app")}
+		default:
+			return "", fmt.Errorf("app read returned %d %s %s", read.Status, read.Code, read.Error)
+		}
+	})
+
+	if read.Code == "ForbiddenByFirewall" {
+		t.Fatalf("The vault firewall refused the Container App's managed identity: bypass=AzureServices does not "+
+			"cover this path. Reach the vault through a private endpoint or allow the app's subnet instead (%v)", err)
+	}
+	if assert.NoError(t, err, "the app should read the secret through the firewall") {
+		expected := sha256.Sum256([]byte(secretValue))
+		assert.Equal(t, hex.EncodeToString(expected[:]), read.ValueSHA256, "the app should read the secret's current value")
+	}
+}
@@ -0,0 +1,135 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+const cryptoServiceEncryptionUser = "Key Vault Crypto Service Encryption User"
+
+// TestKeyVaultCMKConsumers tests that services encrypted with customer-managed
+// keys from the key-vault module can actually wrap and unwrap with them
+func TestKeyVaultCMKConsumers(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	checkpoint := helpers.NewCheckpoint(t)
+	phases := helpers.TrackPhases(t)
+
+	defer checkpoint.Stage(t, "teardown", func() {
+		phases.Start("destroy")
+		options := checkpoint.LoadOptions(t)
+		helpers.CheckLeftoversAfterDestroy(t, config, options)
+		terraform.Destroy(t, options)
+		checkpoint.Clear(t)
+	})
+
+	checkpoint.Stage(t, "deploy", func() {
+		// Saved before apply so a crash mid-apply can still be torn down, and
+		// reused when resuming so the retried apply picks up where it stopped
+		terraformOptions := checkpoint.LoadOrSaveOptions(t, func() *terraform.Options {
+			options := helpers.DefaultTerraformOptions(t, "./fixtures/key-vault-cmk", map[string]interface{}{
+				"resource_group_name": config.GenerateResourceGroupName("cmk"),
+				"location":            config.Location,
+				"key_vault_name":      fmt.Sprintf("kv-cmk-%s", config.UniqueID),
+				"acr_name":            fmt.Sprintf("acrcmk%s", config.UniqueID),
+				"log_analytics_name":  fmt.Sprintf("log-cmk-%s", config.UniqueID),
+				"app_insights_name":   fmt.Sprintf("appi-cmk-%s", config.UniqueID),
+				// Log Analytics clusters are billed at a 100 GB/day commitment
+				// tier, so they are only exercised when explicitly requested
+				"enable_log_analytics_cmk":   os.Getenv("TEST_LOG_ANALYTICS_CMK") == "true",
+				"log_analytics_cluster_name": fmt.Sprintf("lac-cmk-%s", config.UniqueID),
+				"tags":                       helpers.StandardTags(t.Name()),
+			})
+			helpers.UseIsolatedWorkspace(t, options)
+			// Key creation races the deployer's Key Vault Administrator assignment
+			options.RetryableTerraformErrors[".*ForbiddenByRbac.*"] = "RBAC assignment not yet propagated, retrying"
+			return options
+		})
+
+		phases.Start("apply")
+		helpers.InitAndApply(t, terraformOptions)
+		checkpoint.SaveOutputs(t, terraform.OutputAll(t, terraformOptions))
+	})
+	phases.Start("verify")
+
+	outputs := checkpoint.LoadOutputs(t)
+
+	t.Run("acr", func(t *testing.T) {
+		acrName := outputs["acr_name"].(string)
+		keyID := outputs["acr_key_id"].(string)
+		principalID := outputs["acr_identity_principal_id"].(string)
+
+		assert.True(t, helpers.HasRoleAssignment(t, principalID, keyID, cryptoServiceEncryptionUser),
+			"ACR identity should hold %s on its key", cryptoServiceEncryptionUser)
+
+		var encryption struct {
+			Status             string `json:"status"`
+			KeyVaultProperties struct {
+				KeyIdentifier string `json:"keyIdentifier"`
+			} `json:"keyVaultProperties"`
+		}
+		helpers.AzCLIJSON(t, &encryption, "acr", "encryption", "show", "--name", acrName)
+		assert.Equal(t, "enabled", strings.ToLower(encryption.Status), "ACR encryption should be enabled")
+		assert.Contains(t, encryption.KeyVaultProperties.KeyIdentifier, "/keys/"+outputs["acr_key_name"].(string),
+			"ACR should be encrypted with the key-vault module key")
+
+		// Importing an image writes encrypted layers (wrapKey) and listing its
+		// manifest reads them back (unwrapKey)
+		_, err := helpers.AzCLIE(t, "acr", "import", "--name", acrName,
+			"--source", "mcr.microsoft.com/hello-world:latest", "--image", "hello-world:cmk")
+		assert.NoError(t, err, "Image import into the CMK registry should succeed")
+
+		_, err = helpers.AzCLIE(t, "acr", "manifest", "list-metadata",
+			"--registry", acrName, "--name", "hello-world")
+		assert.NoError(t, err, "Reading manifests from the CMK registry should succeed")
+	})
+
+	t.Run("log_analytics", func(t *testing.T) {
+		if outputs["log_analytics_cluster_id"] == "" {
+			helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_LOG_ANALYTICS_CMK=true to test the Log Analytics dedicated cluster")
+		}
+
+		clusterID := outputs["log_analytics_cluster_id"].(string)
+		keyID := outputs["log_analytics_key_id"].(string)
+		principalID := outputs["log_analytics_cluster_principal_id"].(string)
+
+		assert.True(t, helpers.HasRoleAssignment(t, principalID, keyID, cryptoServiceEncryptionUser),
+			"Log Analytics cluster identity should hold %s on its key", cryptoServiceEncryptionUser)
+
+		// The cluster only reaches Succeeded once it has wrapped its
+		// encryption key with the CMK
+		var cluster struct {
+			ProvisioningState  string `json:"provisioningState"`
+			KeyVaultProperties struct {
+				KeyName string `json:"keyName"`
+			} `json:"keyVaultProperties"`
+		}
+		helpers.AzCLIJSON(t, &cluster, "resource", "show", "--ids", clusterID, "--query", "properties")
+		assert.Equal(t, "Succeeded", cluster.ProvisioningState, "Log Analytics cluster should be provisioned")
+		assert.Equal(t, outputs["log_analytics_key_name"], cluster.KeyVaultProperties.KeyName,
+			"Log Analytics cluster should be encrypted with the key-vault module key")
+
+		var workspace struct {
+			Features struct {
+				ClusterResourceID string `json:"clusterResourceId"`
+			} `json:"features"`
+		}
+		helpers.AzCLIJSON(t, &workspace, "resource", "show",
+			"--ids", outputs["log_analytics_workspace_id"].(string), "--query", "properties")
+		assert.True(t, strings.EqualFold(clusterID, workspace.Features.ClusterResourceID),
+			"Workspace should be linked to the CMK cluster")
+	})
+}
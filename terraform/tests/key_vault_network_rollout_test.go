@@ -0,0 +1,134 @@
+package test
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// networkProbeInterval is how often the runner reads the secret
+	networkProbeInterval = 2 * time.Second
+	// maxNetworkErrorWindow is the longest the runner may be refused in a
+	// row while the firewall changes; it is always allowed through
+	maxNetworkErrorWindow = time.Minute
+	// maxNetworkProbeP95 bounds the 95th percentile of read latency
+	maxNetworkProbeP95 = 3 * time.Second
+	// networkProbeSettle is how long the probe keeps reading after the last
+	// apply, so a late lockout shows up
+	networkProbeSettle = time.Minute
+)
+
+// networkRolloutReport is what TestKeyVaultNetworkRollout records
+type networkRolloutReport struct {
+	Calls        int                   `json:"calls"`
+	Failures     int                   `json:"failures"`
+	P50          string                `json:"p50"`
+	P95          string                `json:"p95"`
+	ErrorWindows []helpers.ErrorWindow `json:"error_windows"`
+}
+
+// TestKeyVaultNetworkRollout reads a secret from the runner every few
+// seconds while the vault firewall is re-applied from default Allow to Deny
+// and back, with the runner's address in the IP rules throughout. A client
+// the rules allow must keep its access through such a rollout: every run of
+// refused or failed reads must end within maxNetworkErrorWindow, the last
+// reads must succeed, and latency must stay within maxNetworkProbeP95. Opt
+// in with TEST_KEY_VAULT_NETWORK_PROBE=true
+func TestKeyVaultNetworkRollout(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_KEY_VAULT_NETWORK_PROBE") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_KEY_VAULT_NETWORK_PROBE=true to read a secret while the vault firewall changes")
+	}
+
+	runnerIP, err := helpers.RunnerPublicIPE(t)
+	if err != nil {
+		t.Fatalf("Finding the runner's public address: %v", err)
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/key-vault-network-rollout", map[string]interface{}{
+		"resource_group_name":     config.GenerateResourceGroupName("kvnet"),
+		"location":                config.Location,
+		"name_suffix":             config.UniqueID,
+		"runner_ip":               runnerIP,
+		"firewall_default_action": "Allow",
+		"tags":                    helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	vaultURI := terraform.Output(t, terraformOptions, "vault_uri")
+	secretName := terraform.Output(t, terraformOptions, "secret_name")
+	var token string
+	helpers.AzCLIJSON(t, &token, "account", "get-access-token", "--resource", helpers.CurrentCloud(t).KeyVaultResource, "--query", "accessToken")
+	client := &http.Client{Timeout: 10 * time.Second}
+	read := func() error {
+		return helpers.ReadSecretE(client, vaultURI, secretName, token)
+	}
+
+	// Start from a working read, so every error the probe sees comes from
+	// the rollout rather than role propagation
+	retry.DoWithRetry(t, "reading the secret before the rollout", 18, 10*time.Second, func() (string, error) {
+		return "", read()
+	})
+
+	probe := helpers.StartProbe(networkProbeInterval, "allow", read)
+	for _, action := range []string{"Deny", "Allow"} {
+		phase := "to-" + action
+		probe.SetPhase(phase)
+		terraformOptions.Vars["firewall_default_action"] = action
+		if _, err := terraform.ApplyE(t, terraformOptions); err != nil {
+			probe.Stop()
+			t.Fatalf("Applying default action %s: %v", action, err)
+		}
+		probe.SetPhase(phase + "-applied")
+	}
+	time.Sleep(networkProbeSettle)
+	calls := probe.Stop()
+
+	windows := helpers.ErrorWindows(calls)
+	failures := 0
+	for _, call := range calls {
+		if call.Failed() {
+			failures++
+		}
+	}
+	report := networkRolloutReport{
+		Calls:        len(calls),
+		Failures:     failures,
+		P50:          helpers.LatencyPercentile(calls, 50).String(),
+		P95:          helpers.LatencyPercentile(calls, 95).String(),
+		ErrorWindows: windows,
+	}
+	helpers.RecordReport(t, "key_vault_network", config.Location, report)
+	t.Logf("%d reads, %d failed, p50 %s, p95 %s", report.Calls, report.Failures, report.P50, report.P95)
+
+	for _, window := range windows {
+		assert.True(t, window.Recovered, "the runner should not be locked out: %s", window)
+		assert.LessOrEqual(t, window.Duration(), maxNetworkErrorWindow, "reads should recover quickly: %s", window)
+	}
+	if assert.NotEmpty(t, calls) {
+		assert.False(t, calls[len(calls)-1].Failed(), "the last read should succeed")
+	}
+	assert.LessOrEqual(t, helpers.LatencyPercentile(calls, 95), maxNetworkProbeP95, "p95 read latency")
+}
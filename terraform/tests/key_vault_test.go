@@ -2,12 +2,19 @@ package test
 
 import (
 	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gruntwork-io/terratest/modules/azure"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/basevars"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,10 +23,11 @@ func TestKeyVaultBasic(t *testing.T) {
 	t.Parallel()
 
 	subscriptionID := azure.GetSubscriptionID(t)
-	uniqueID := strings.ToLower(random.UniqueId())
+	uniqueID := helpers.StageUniqueID(t)
 	resourceGroupName := fmt.Sprintf("rg-kv-test-%s", uniqueID)
 	keyVaultName := fmt.Sprintf("kv-test-%s", uniqueID)
 	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.KeyVault/vaults")
 
 	// Create resource group
 	rgOptions := &terraform.Options{
@@ -32,8 +40,9 @@ func TestKeyVaultBasic(t *testing.T) {
 			},
 		},
 	}
-	defer terraform.Destroy(t, rgOptions)
-	terraform.InitAndApply(t, rgOptions)
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, rgOptions)
+	helpers.InitAndApply(t, rgOptions)
 
 	// Create Key Vault
 	kvOptions := &terraform.Options{
@@ -49,8 +58,12 @@ func TestKeyVaultBasic(t *testing.T) {
 			},
 		},
 	}
-	defer terraform.Destroy(t, kvOptions)
-	terraform.InitAndApply(t, kvOptions)
+	defer helpers.Destroy(t, kvOptions)
+	helpers.InitAndApply(t, kvOptions)
+	helpers.StartValidation(t)
+	helpers.AssertCostProfile(t, kvOptions, "key-vault")
+	helpers.AssertIdempotent(t, kvOptions)
+	helpers.CheckAdvisorRecommendations(t, resourceGroupName)
 
 	// Verify Key Vault exists
 	kv := azure.GetKeyVault(t, resourceGroupName, keyVaultName, subscriptionID)
@@ -63,9 +76,7 @@ func TestKeyVaultBasic(t *testing.T) {
 	assert.NotEmpty(t, outputs["vault_uri"], "Vault URI output should not be empty")
 
 	// Verify vault URI format
-	vaultURI := outputs["vault_uri"].(string)
-	assert.Contains(t, vaultURI, "https://", "Vault URI should use HTTPS")
-	assert.Contains(t, vaultURI, ".vault.azure.net", "Vault URI should be Azure Key Vault")
+	helpers.AssertKeyVaultURI(t, outputs["vault_uri"].(string), keyVaultName)
 }
 
 // TestKeyVaultNameValidation tests Key Vault name validation
@@ -115,13 +126,11 @@ func TestKeyVaultNameValidation(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
+			uniqueID := strings.ToLower(random.UniqueId())
+
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/key-vault",
-				Vars: map[string]interface{}{
-					"name":                tc.kvName,
-					"resource_group_name": "rg-nonexistent",
-					"location":            "eastus2",
-				},
+				Vars:         basevars.MinimalKeyVaultVars(uniqueID).With("name", tc.kvName),
 			}
 
 			if tc.shouldFail {
@@ -152,16 +161,10 @@ func TestKeyVaultSkuValidation(t *testing.T) {
 			t.Parallel()
 
 			uniqueID := strings.ToLower(random.UniqueId())
-			kvName := fmt.Sprintf("kvtest%s", uniqueID)
 
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/key-vault",
-				Vars: map[string]interface{}{
-					"name":                kvName,
-					"resource_group_name": "rg-nonexistent",
-					"location":            "eastus2",
-					"sku_name":            tc.sku,
-				},
+				Vars:         basevars.MinimalKeyVaultVars(uniqueID).With("sku_name", tc.sku),
 			}
 
 			if tc.shouldFail {
@@ -177,9 +180,9 @@ func TestKeyVaultRetentionValidation(t *testing.T) {
 	t.Parallel()
 
 	testCases := []struct {
-		name         string
+		name          string
 		retentionDays int
-		shouldFail   bool
+		shouldFail    bool
 	}{
 		{"minimum_7_days", 7, false},
 		{"maximum_90_days", 90, false},
@@ -193,16 +196,10 @@ func TestKeyVaultRetentionValidation(t *testing.T) {
 			t.Parallel()
 
 			uniqueID := strings.ToLower(random.UniqueId())
-			kvName := fmt.Sprintf("kvtest%s", uniqueID)
 
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/key-vault",
-				Vars: map[string]interface{}{
-					"name":                       kvName,
-					"resource_group_name":        "rg-nonexistent",
-					"location":                   "eastus2",
-					"soft_delete_retention_days": tc.retentionDays,
-				},
+				Vars:         basevars.MinimalKeyVaultVars(uniqueID).With("soft_delete_retention_days", tc.retentionDays),
 			}
 
 			if tc.shouldFail {
@@ -218,14 +215,15 @@ func TestKeyVaultWithNetworkAcls(t *testing.T) {
 	t.Parallel()
 
 	if testing.Short() {
-		t.Skip("Skipping slow test in short mode")
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
 	}
 
 	subscriptionID := azure.GetSubscriptionID(t)
-	uniqueID := strings.ToLower(random.UniqueId())
+	uniqueID := helpers.StageUniqueID(t)
 	resourceGroupName := fmt.Sprintf("rg-kv-acl-test-%s", uniqueID)
 	keyVaultName := fmt.Sprintf("kv-acl-%s", uniqueID)
 	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.KeyVault/vaults")
 
 	// Create resource group
 	rgOptions := &terraform.Options{
@@ -235,8 +233,9 @@ func TestKeyVaultWithNetworkAcls(t *testing.T) {
 			"location": location,
 		},
 	}
-	defer terraform.Destroy(t, rgOptions)
-	terraform.InitAndApply(t, rgOptions)
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, rgOptions)
+	helpers.InitAndApply(t, rgOptions)
 
 	// Create Key Vault with network ACLs
 	kvOptions := &terraform.Options{
@@ -254,10 +253,245 @@ func TestKeyVaultWithNetworkAcls(t *testing.T) {
 			},
 		},
 	}
-	defer terraform.Destroy(t, kvOptions)
-	terraform.InitAndApply(t, kvOptions)
+	defer helpers.Destroy(t, kvOptions)
+	helpers.InitAndApply(t, kvOptions)
+	helpers.StartValidation(t)
 
 	// Verify Key Vault exists
 	kv := azure.GetKeyVault(t, resourceGroupName, keyVaultName, subscriptionID)
 	assert.NotNil(t, kv, "Key Vault should exist")
 }
+
+// keyVaultSecret is the data-plane view of a secret from `az keyvault secret show`
+type keyVaultSecret struct {
+	ID          string            `json:"id"`
+	ContentType string            `json:"contentType"`
+	Tags        map[string]string `json:"tags"`
+	Attributes  struct {
+		NotBefore *time.Time `json:"notBefore"`
+		Expires   *time.Time `json:"expires"`
+	} `json:"attributes"`
+}
+
+// assertSecretMetadata reads a secret from the vault and asserts that its
+// metadata matches what was passed to the module. It returns the version read
+func assertSecretMetadata(t *testing.T, vaultName, secretName string, metadata map[string]interface{}) string {
+	// The query leaves the secret value out of the command output
+	var secret keyVaultSecret
+	helpers.AzCLIJSON(t, &secret, "keyvault", "secret", "show",
+		"--vault-name", vaultName, "--name", secretName, "--query", "{id:id, contentType:contentType, tags:tags, attributes:attributes}")
+
+	assert.Equal(t, metadata["content_type"], secret.ContentType, "content type should round-trip")
+	assert.Equal(t, metadata["tags"], secret.Tags, "tags should round-trip")
+	for attribute, actual := range map[string]*time.Time{
+		"not_before_date": secret.Attributes.NotBefore,
+		"expiration_date": secret.Attributes.Expires,
+	} {
+		expected, _ := time.Parse(time.RFC3339, metadata[attribute].(string))
+		if assert.NotNil(t, actual, "%s should be set", attribute) {
+			assert.True(t, expected.Equal(*actual), "%s should round-trip: expected %s, read %s",
+				attribute, expected.Format(time.RFC3339), actual.Format(time.RFC3339))
+		}
+	}
+	return path.Base(secret.ID)
+}
+
+// TestKeyVaultSecretMetadata passes secret metadata through the module,
+// reads it back from the data plane, then changes the metadata alone. That
+// change must update the current secret version in place rather than
+// replacing the secret or writing a new version
+func TestKeyVaultSecretMetadata(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	const secretName = "app-config"
+	secretAddress := fmt.Sprintf("module.key_vault.azurerm_key_vault_secret.secrets[%q]", secretName)
+
+	// Whole seconds in UTC so the dates compare exactly after the round trip
+	notBefore := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	metadata := map[string]interface{}{
+		"content_type":    "application/json",
+		"not_before_date": notBefore.Format(time.RFC3339),
+		"expiration_date": notBefore.Add(30 * 24 * time.Hour).Format(time.RFC3339),
+		"tags":            map[string]string{"Owner": "platform"},
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/key-vault-secrets", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("kvsec"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"secrets":             map[string]string{secretName: fmt.Sprintf(`{"id":"%s"}`, config.UniqueID)},
+		"secret_metadata":     map[string]interface{}{secretName: metadata},
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	// The deployer's data-plane role can take a few minutes to propagate
+	terraformOptions.RetryableTerraformErrors[".*ForbiddenByRbac.*"] = "Key Vault role assignment not yet effective, retrying"
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	vaultName := terraform.Output(t, terraformOptions, "key_vault_name")
+	version := assertSecretMetadata(t, vaultName, secretName, metadata)
+
+	// Change every metadata attribute but keep the value
+	updatedMetadata := map[string]interface{}{
+		"content_type":    "text/plain",
+		"not_before_date": notBefore.Add(-time.Hour).Format(time.RFC3339),
+		"expiration_date": notBefore.Add(60 * 24 * time.Hour).Format(time.RFC3339),
+		"tags":            map[string]string{"Owner": "platform-tests", "Rotation": "manual"},
+	}
+	updatedVars := map[string]interface{}{}
+	for key, value := range terraformOptions.Vars {
+		updatedVars[key] = value
+	}
+	updatedVars["secret_metadata"] = map[string]interface{}{secretName: updatedMetadata}
+
+	updatedOptions := *terraformOptions
+	updatedOptions.Vars = updatedVars
+	updatedOptions.PlanFilePath = filepath.Join(terraformOptions.TerraformDir, "metadata.tfplan")
+
+	planJSON := helpers.InitAndPlanAndShow(t, &updatedOptions)
+	actions, attributes, err := helpers.PlannedChangeE(planJSON, secretAddress)
+	if err != nil {
+		t.Fatalf("Reading planned change: %v", err)
+	}
+	assert.Equal(t, []string{"update"}, actions, "metadata changes should update %s in place", secretAddress)
+	for _, attribute := range []string{"value", "version", "id", "versionless_id"} {
+		assert.NotContains(t, attributes, attribute, "metadata changes should not write a new secret version")
+	}
+
+	helpers.Apply(t, &updatedOptions)
+	updatedVersion := assertSecretMetadata(t, vaultName, secretName, updatedMetadata)
+	assert.Equal(t, version, updatedVersion, "metadata changes should keep the current secret version")
+}
+
+// secretVersion is a version of a secret from `az keyvault secret list-versions`
+type secretVersion struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Enabled bool      `json:"enabled"`
+		Created time.Time `json:"created"`
+	} `json:"attributes"`
+}
+
+// secretVersions lists the versions of a secret, oldest first
+func secretVersions(t *testing.T, vaultName, secretName string) []secretVersion {
+	var versions []secretVersion
+	helpers.AzCLIJSON(t, &versions, "keyvault", "secret", "list-versions",
+		"--vault-name", vaultName, "--name", secretName)
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Attributes.Created.Before(versions[j].Attributes.Created)
+	})
+	return versions
+}
+
+// secretValue reads the value of one version of a secret
+func secretValue(t *testing.T, vaultName, secretName, version string) string {
+	return strings.TrimSpace(helpers.AzCLI(t, "keyvault", "secret", "show", "--vault-name", vaultName,
+		"--name", secretName, "--version", version, "--query", "value", "--output", "tsv"))
+}
+
+// TestKeyVaultSecretVersioning enforces how the module versions secrets.
+// Changing a value in var.secrets updates the secret in place, which writes a
+// new version and keeps the old one readable. Rolling back to an old value
+// writes yet another version rather than restoring or deleting any, so the
+// full history survives both changes
+func TestKeyVaultSecretVersioning(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	const secretName = "app-config"
+	secretAddress := fmt.Sprintf("module.key_vault.azurerm_key_vault_secret.secrets[%q]", secretName)
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	original := "config-v1-" + config.UniqueID
+	updated := "config-v2-" + config.UniqueID
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/key-vault-secrets", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("kvver"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"secrets":             map[string]string{secretName: original},
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	// The deployer's data-plane role can take a few minutes to propagate
+	terraformOptions.RetryableTerraformErrors[".*ForbiddenByRbac.*"] = "Key Vault role assignment not yet effective, retrying"
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	vaultName := terraform.Output(t, terraformOptions, "key_vault_name")
+	versions := secretVersions(t, vaultName, secretName)
+	if len(versions) != 1 {
+		t.Fatalf("Expected one version of %s after the first apply, found %d", secretName, len(versions))
+	}
+
+	// changeValue plans and applies value, asserting the plan updates the
+	// secret in place, and returns the versions afterwards
+	changeValue := func(step, value string) []secretVersion {
+		vars := map[string]interface{}{}
+		for key, existing := range terraformOptions.Vars {
+			vars[key] = existing
+		}
+		vars["secrets"] = map[string]string{secretName: value}
+
+		options := *terraformOptions
+		options.Vars = vars
+		options.PlanFilePath = filepath.Join(terraformOptions.TerraformDir, step+".tfplan")
+
+		planJSON := helpers.InitAndPlanAndShow(t, &options)
+		actions, attributes, err := helpers.PlannedChangeE(planJSON, secretAddress)
+		if err != nil {
+			t.Fatalf("Reading planned change: %v", err)
+		}
+		assert.Equal(t, []string{"update"}, actions,
+			"%s: a new value should update %s in place; replacing it deletes its history", step, secretAddress)
+		assert.Contains(t, attributes, "value", "%s: the plan should change the value", step)
+
+		helpers.Apply(t, &options)
+		return secretVersions(t, vaultName, secretName)
+	}
+
+	afterUpdate := changeValue("update", updated)
+	if assert.Len(t, afterUpdate, 2, "updating the value should add a version") {
+		assert.Equal(t, versions[0].ID, afterUpdate[0].ID, "the original version should be kept")
+	}
+
+	afterRollback := changeValue("rollback", original)
+	if !assert.Len(t, afterRollback, 3, "rolling back the value should add a version, not restore or delete one") {
+		return
+	}
+	assert.Equal(t, afterUpdate[1].ID, afterRollback[1].ID, "the updated version should be kept")
+
+	for i, expected := range []string{original, updated, original} {
+		version := path.Base(afterRollback[i].ID)
+		assert.True(t, afterRollback[i].Attributes.Enabled, "version %d (%s) should stay enabled", i+1, version)
+		assert.Equal(t, expected, secretValue(t, vaultName, secretName, version),
+			"version %d (%s) should still hold its value", i+1, version)
+	}
+
+	var current keyVaultSecret
+	helpers.AzCLIJSON(t, &current, "keyvault", "secret", "show",
+		"--vault-name", vaultName, "--name", secretName, "--query", "{id:id}")
+	assert.Equal(t, afterRollback[2].ID, current.ID, "the rollback version should be current")
+}
@@ -0,0 +1,166 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// leastPrivilegeModules are the modules the least-privilege fixture can
+// deploy into a resource group created by the runner. resource-group needs
+// subscription scope and private-endpoints a Premium registry and VNet, so
+// they are only documented
+var leastPrivilegeModules = []string{"container-app", "container-registry", "key-vault", "networking", "observability"}
+
+// TestModuleReadmesDocumentPermissions checks that every module lists the
+// roles it needs to deploy and that none of them is Owner
+func TestModuleReadmesDocumentPermissions(t *testing.T) {
+	t.Parallel()
+
+	readmes, err := filepath.Glob("../modules/*/README.md")
+	if err != nil || len(readmes) == 0 {
+		t.Fatalf("Finding module READMEs: %v", err)
+	}
+	for _, readme := range readmes {
+		roles, err := helpers.DocumentedPermissionsE(filepath.Dir(readme))
+		if assert.NoError(t, err) {
+			for _, role := range roles {
+				assert.NotEqual(t, "Owner", role.Role, "%s should not need Owner", readme)
+			}
+		}
+	}
+}
+
+// waitForResourceGroupAccess waits until principal can read the resource
+// group, i.e. until its role assignments have reached Resource Manager
+func waitForResourceGroupAccess(t *testing.T, principal *helpers.TestPrincipal, resourceGroupID string) {
+	resourceManager := helpers.CurrentCloud(t).ResourceManager
+	token, err := principal.AccessTokenE(t, resourceManager)
+	if err != nil {
+		t.Fatalf("Getting a Resource Manager token for %s: %v", principal, err)
+	}
+
+	url := fmt.Sprintf("%s%s?api-version=2021-04-01", resourceManager, resourceGroupID)
+	retry.DoWithRetry(t, fmt.Sprintf("waiting for %s to read %s", principal, resourceGroupID), 30, 10*time.Second, func() (string, error) {
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return "", err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return "", err
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("got %d", response.StatusCode)
+		}
+		return "", nil
+	})
+}
+
+// TestLeastPrivilegeApply deploys each module as a test principal holding
+// only the roles in the module's Required Permissions table, scoped to a
+// resource group the runner created. The apply fails when a module needs
+// more than it documents, e.g. a new role assignment that silently requires
+// Owner
+func TestLeastPrivilegeApply(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_LEAST_PRIVILEGE") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_LEAST_PRIVILEGE=true to apply modules with their documented minimum roles")
+	}
+
+	for _, module := range leastPrivilegeModules {
+		module := module
+		t.Run(module, func(t *testing.T) {
+			t.Parallel()
+
+			roles, err := helpers.DocumentedPermissionsE(filepath.Join("..", "modules", module))
+			if err != nil {
+				t.Fatalf("Reading documented permissions: %v", err)
+			}
+
+			config := helpers.NewTestConfig(t)
+			cost.TrackRun(t, config)
+			resourceGroupName := config.GenerateResourceGroupName("lp")
+			var resourceGroup struct {
+				ID string `json:"id"`
+			}
+			helpers.AzCLIJSON(t, &resourceGroup, "group", "create", "--name", resourceGroupName,
+				"--location", config.Location, "--tags", "Purpose=terratest", "Test="+strings.ReplaceAll(t.Name(), "/", "-"))
+			t.Cleanup(func() {
+				if _, err := helpers.AzCLIE(t, "group", "delete", "--name", resourceGroupName, "--yes", "--no-wait"); err != nil {
+					t.Logf("Could not delete %s, delete it manually: %v", resourceGroupName, err)
+				}
+			})
+
+			principal := helpers.NewTestPrincipal(t, "deployer")
+			for _, role := range roles {
+				if role.Scope != "Resource group" {
+					t.Fatalf("%s is documented at %q; only resource group scope can be tested", role.Role, role.Scope)
+				}
+				principal.AssignRole(t, role.Role, resourceGroup.ID)
+			}
+			waitForResourceGroupAccess(t, principal, resourceGroup.ID)
+
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/least-privilege", map[string]interface{}{
+				"resource_group_name": resourceGroupName,
+				"module":              module,
+				"name_suffix":         config.UniqueID,
+				"tags":                helpers.StandardTags(t.Name()),
+			})
+			// Data-plane roles the module assigns itself still take a few minutes
+			terraformOptions.RetryableTerraformErrors[".*ForbiddenByRbac.*"] = "Key Vault role assignment not yet effective, retrying"
+			terraformOptions.EnvVars = principal.Env(config.SubscriptionID)
+			// State stays local: the principal has no access to the shared backend
+			terraformOptions.TerraformDir = helpers.CopyTerraformDirToTemp(t, terraformOptions.TerraformDir)
+
+			phases := helpers.TrackPhases(t)
+			// The group is the runner's, so only what the principal applied in it
+			// has to be gone
+			defer helpers.AssertResourceGroupEmpty(t, config.SubscriptionID, resourceGroupName)
+			defer helpers.Destroy(t, terraformOptions)
+			defer phases.Start("destroy")
+			phases.Start("apply")
+			_, err = terraform.InitAndApplyE(t, terraformOptions)
+			phases.Start("verify")
+
+			if err == nil {
+				assert.Equal(t, principal.ObjectID, terraform.Output(t, terraformOptions, "principal_object_id"),
+					"terraform should run as the test principal")
+				helpers.AssertOutputsUsable(t, terraformOptions)
+				return
+			}
+
+			missing := helpers.MissingPermissions(err.Error())
+			if len(missing) == 0 {
+				t.Fatalf("Apply with the documented roles failed: %v", err)
+			}
+			var descriptions []string
+			for _, permission := range missing {
+				description := permission.String()
+				if permission.RoleManagement() {
+					description += " (role management: Owner, User Access Administrator or Role Based Access Control Administrator)"
+				}
+				descriptions = append(descriptions, description)
+			}
+			helpers.RecordReport(t, "least_privilege", module, descriptions)
+			t.Errorf("%s needs more than the roles in its README's Required Permissions table:\n  %s",
+				module, strings.Join(descriptions, "\n  "))
+		})
+	}
+}
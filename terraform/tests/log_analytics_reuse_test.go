@@ -0,0 +1,146 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// workspaceReuse is the workspace_reuse report entry of one case
+type workspaceReuse struct {
+	Workspace string `json:"workspace"`
+	Location  string `json:"location"`
+	// Outcome is "recovered", "created" or "failed"
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+	Actionable bool   `json:"actionable,omitempty"`
+}
+
+// TestLogAnalyticsWorkspaceNameReuse soft-deletes the observability module's
+// workspace, as destroying an environment does with the root provider
+// settings, and creates it again under the same name. Azure keeps deleted
+// workspaces for 14 days; the module must either get the workspace back or
+// fail with an error that says a deleted workspace holds the name. After a
+// failure, helpers.PurgeDeletedWorkspaceE must free the name
+func TestLogAnalyticsWorkspaceNameReuse(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	testCases := []struct {
+		name string
+		// otherLocation re-creates the workspace outside the deleted one's region
+		otherLocation bool
+	}{
+		{"same_location", false},
+		{"other_location", true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := helpers.NewTestConfig(t)
+			cost.TrackRun(t, config)
+			reuseLocation := config.Location
+			if tc.otherLocation {
+				for _, location := range helpers.AllowedLocations() {
+					if location != config.Location {
+						reuseLocation = location
+						break
+					}
+				}
+				if reuseLocation == config.Location {
+					helpers.SkipWithReason(t, helpers.SkipRegionCapability, fmt.Sprintf("Only %s is allowed; no other region to re-create the workspace in", config.Location))
+				}
+			}
+
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/log-analytics-reuse", map[string]interface{}{
+				"resource_group_name": config.GenerateResourceGroupName("logreuse"),
+				"location":            config.Location,
+				"name_suffix":         config.UniqueID,
+				"tags":                helpers.StandardTags(t.Name()),
+			})
+			helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+			phases := helpers.TrackPhases(t)
+			helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+			defer helpers.Destroy(t, terraformOptions)
+			defer func() {
+				helpers.PurgeDeletedWorkspaces(t, terraformOptions.Vars["resource_group_name"].(string))
+			}()
+			defer phases.Start("destroy")
+
+			phases.Start("apply")
+			helpers.InitAndApply(t, terraformOptions)
+			resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+			workspaceName := terraform.Output(t, terraformOptions, "workspace_name")
+			customerID := terraform.Output(t, terraformOptions, "workspace_customer_id")
+
+			// Delete the workspace the way the root configuration does
+			terraformOptions.Vars["workspace_enabled"] = false
+			terraformOptions.Vars["permanently_delete_workspace"] = false
+			helpers.Apply(t, terraformOptions)
+			retry.DoWithRetry(t, "waiting for the workspace to be listed as deleted", 10, 30*time.Second, func() (string, error) {
+				deleted, err := helpers.DeletedWorkspacesE(t, resourceGroupName)
+				if err != nil {
+					return "", err
+				}
+				for _, workspace := range deleted {
+					if workspace.Name == workspaceName {
+						return "", nil
+					}
+				}
+				return "", fmt.Errorf("%s is not among the deleted workspaces of %s", workspaceName, resourceGroupName)
+			})
+
+			phases.Start("verify")
+			reuse := workspaceReuse{Workspace: workspaceName, Location: reuseLocation}
+			terraformOptions.Vars["workspace_enabled"] = true
+			terraformOptions.Vars["permanently_delete_workspace"] = true
+			if tc.otherLocation {
+				terraformOptions.Vars["workspace_location"] = reuseLocation
+			}
+
+			_, err := terraform.ApplyE(t, terraformOptions)
+			switch {
+			case err != nil:
+				reuse.Outcome = "failed"
+				reuse.Error = err.Error()
+				reuse.Actionable = helpers.IsActionableWorkspaceReuseError(err, workspaceName)
+			case terraform.Output(t, terraformOptions, "workspace_customer_id") == customerID:
+				reuse.Outcome = "recovered"
+			default:
+				reuse.Outcome = "created"
+			}
+			helpers.RecordReport(t, "workspace_reuse", tc.name, reuse)
+
+			switch reuse.Outcome {
+			case "recovered":
+				helpers.AssertOutputsUsable(t, terraformOptions)
+			case "created":
+				t.Errorf("A new workspace %s replaced the deleted one while it still held the name; its data is silently orphaned", workspaceName)
+			case "failed":
+				assert.True(t, reuse.Actionable,
+					"re-creating %s failed without saying a deleted workspace holds the name: %v", workspaceName, err)
+
+				// Purging must free the name for a fresh workspace
+				if err := helpers.PurgeDeletedWorkspaceE(t, resourceGroupName, workspaceName); err != nil {
+					t.Fatalf("Purging deleted workspace %s: %v", workspaceName, err)
+				}
+				helpers.Apply(t, terraformOptions)
+				assert.NotEqual(t, customerID, terraform.Output(t, terraformOptions, "workspace_customer_id"),
+					"a purged workspace should not come back")
+			}
+		})
+	}
+}
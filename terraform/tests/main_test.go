@@ -0,0 +1,34 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// TestMain destroys the fixtures the run's tests share, such as the webhook
+// receiver and the shared Log Analytics workspace, once every test has
+// finished with them. With SKIP_destroy they are kept, like the deployments
+// that may use them. It also prints and records how many tests were skipped
+// by category, so a run that skipped most of the suite says so
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if skips, err := helpers.RecordSkipSummaryE(); err != nil {
+		fmt.Fprintf(os.Stderr, "Summarizing skipped tests: %v\n", err)
+	} else if skips.Total() > 0 {
+		fmt.Fprintf(os.Stderr, "%s (see %s)\n", skips, helpers.ReportPath("skip_summary"))
+	}
+	if helpers.StageSkipped("destroy") {
+		fmt.Fprintln(os.Stderr, "SKIP_destroy is set, keeping shared fixtures; remove them with `ttk janitor`")
+		os.Exit(code)
+	}
+	if err := helpers.DestroySharedFixturesE(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\nRemove what is left with `ttk janitor`\n", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
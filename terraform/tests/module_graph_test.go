@@ -0,0 +1,30 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// TestModuleDependencyGraph builds the cross-module dependency graph of each
+// environment composition and compares it with the committed one in
+// testdata/module-graphs, so a module cannot quietly grow a dependency on
+// another one or introduce a cycle
+func TestModuleDependencyGraph(t *testing.T) {
+	t.Parallel()
+
+	environments, err := filepath.Glob("../environments/*/main.tf")
+	if err != nil || len(environments) == 0 {
+		t.Fatalf("No environment compositions found: %v", err)
+	}
+
+	for _, composition := range environments {
+		dir := filepath.Dir(composition)
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			t.Parallel()
+
+			helpers.AssertModuleGraph(t, dir, filepath.Base(dir))
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// TestModulesHaveNativeTests fails for a module without a native test file
+// (*.tftest.hcl), so simple plan assertions are written next to the HCL from
+// the start
+func TestModulesHaveNativeTests(t *testing.T) {
+	t.Parallel()
+
+	modules, err := filepath.Glob("../modules/*/versions.tf")
+	if err != nil || len(modules) == 0 {
+		t.Fatalf("No modules found: %v", err)
+	}
+
+	for _, versions := range modules {
+		dir := filepath.Dir(versions)
+		testFiles, err := helpers.TerraformTestFilesE(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(testFiles) == 0 {
+			t.Errorf("Module %s has no native tests; add tests/%s.tftest.hcl with at least one run block", filepath.Base(dir), filepath.Base(dir))
+		}
+	}
+}
+
+// TestModuleNativeTerraformTests runs every module's native tests with
+// `terraform test` and reports each run block as a subtest. The tests plan
+// against mocked providers, so they need no Azure credentials, only provider
+// downloads and terraform >= 1.7
+func TestModuleNativeTerraformTests(t *testing.T) {
+	t.Parallel()
+
+	modules, err := filepath.Glob("../modules/*/versions.tf")
+	if err != nil || len(modules) == 0 {
+		t.Fatalf("No modules found: %v", err)
+	}
+
+	for _, versions := range modules {
+		dir := filepath.Dir(versions)
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			t.Parallel()
+			helpers.AssertTerraformTests(t, dir)
+		})
+	}
+}
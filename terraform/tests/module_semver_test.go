@@ -0,0 +1,30 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// TestModuleSemverCompatibility compares the variables and outputs of every
+// module with its last release in testdata/module-interfaces, so a change
+// that breaks callers cannot ship without a major version in the module's
+// CHANGELOG.md
+func TestModuleSemverCompatibility(t *testing.T) {
+	t.Parallel()
+
+	modules, err := filepath.Glob("../modules/*/variables.tf")
+	if err != nil || len(modules) == 0 {
+		t.Fatalf("No modules found: %v", err)
+	}
+
+	for _, variables := range modules {
+		dir := filepath.Dir(variables)
+		name := filepath.Base(dir)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			helpers.AssertModuleSemver(t, dir, name)
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+)
+
+// TestModuleSourcesPinned walks every composition under terraform/
+// (environments, modules, their examples and the test fixtures) and fails on
+// each module call whose source is not pinned to one release: git sources
+// without a release tag or commit ref, registry sources without an exact
+// version, and remote sources that cannot be pinned at all
+func TestModuleSourcesPinned(t *testing.T) {
+	t.Parallel()
+
+	sources, err := helpers.FindModuleSourcesE("..")
+	if err != nil {
+		t.Fatalf("Reading module sources: %v", err)
+	}
+	if len(sources) == 0 {
+		t.Fatal("No module calls found under terraform/")
+	}
+
+	for _, source := range sources {
+		if err := source.PinningError(); err != nil {
+			t.Errorf("%s:%d module %q: %v", source.File, source.Line, source.Call, err)
+		}
+	}
+	t.Logf("Audited %d module calls", len(sources))
+}
@@ -0,0 +1,157 @@
+package test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/planassert"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// resourceHealthAlertAddress is the observability module's alert in the
+	// observability-alerts fixture
+	resourceHealthAlertAddress = "module.observability.azurerm_monitor_activity_log_alert.resource_health[0]"
+	containerAppResourceType   = "Microsoft.App/containerApps"
+)
+
+// TestObservabilityAlertScopeValidation plans the observability-alerts
+// fixture with explicit alert scopes and resource types. Scopes must be
+// resource groups or subscriptions without duplicates, so an alert never
+// pins individual apps, and valid scopes reach the alert unchanged
+func TestObservabilityAlertScopeValidation(t *testing.T) {
+	t.Parallel()
+
+	// Nothing is deployed, so names are fixed and identical plans can be
+	// reused from the plan cache, also by later runs
+	config := helpers.NewTestConfig(t)
+	subscription := fmt.Sprintf("/subscriptions/%s", config.SubscriptionID)
+	resourceGroup := subscription + "/resourceGroups/rg-apps-validation"
+
+	testCases := []struct {
+		name          string
+		scopes        []string
+		resourceTypes []string
+		expectedError string
+	}{
+		{"resource_group", []string{resourceGroup}, nil, ""},
+		{"subscription_and_resource_group", []string{subscription, resourceGroup + "-2"}, nil, ""},
+		{"multiple_types", []string{resourceGroup}, []string{containerAppResourceType, "Microsoft.App/managedEnvironments"}, ""},
+		{"individual_resource", []string{resourceGroup + "/providers/Microsoft.App/containerApps/ca-api"}, nil, "not individual resources"},
+		{"duplicate_scope", []string{resourceGroup, resourceGroup}, nil, "must not contain duplicates"},
+		{"duplicate_scope_case", []string{resourceGroup, subscription + "/resourceGroups/RG-APPS-VALIDATION"}, nil, "must not contain duplicates"},
+		{"no_resource_types", []string{resourceGroup}, []string{}, "non-empty list of types"},
+		{"invalid_resource_type", []string{resourceGroup}, []string{"containerApps"}, "non-empty list of types"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			vars := map[string]interface{}{
+				"resource_group_name": "rg-obsal-validation",
+				"location":            config.Location,
+				"name_suffix":         "validation",
+				"alert_scopes":        tc.scopes,
+			}
+			if tc.resourceTypes != nil {
+				vars["alert_resource_types"] = tc.resourceTypes
+			}
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/observability-alerts", vars)
+
+			// Every case plans the same fixture, so they share one init
+			planJSON, err := helpers.CachedPlanE(t, terraformOptions)
+			if tc.expectedError != "" {
+				if assert.Error(t, err, "Expected validation error for scopes %v and types %v", tc.scopes, tc.resourceTypes) {
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Planning alert scopes %v: %v", tc.scopes, err)
+			}
+
+			plan := planassert.Parse(t, planJSON)
+			scopes, err := plan.AttributeE(resourceHealthAlertAddress, "scopes")
+			if assert.NoError(t, err) {
+				assert.ElementsMatch(t, tc.scopes, scopes, "the alert should watch exactly the given scopes")
+			}
+		})
+	}
+}
+
+// TestObservabilityAlertCoversNewApps applies the observability module with
+// its Resource Health alert scoped to a resource group holding one container
+// app, then adds a second app. The alert must already cover the first app,
+// adding the second must not change the alert, and the alert must cover the
+// second app once it exists
+func TestObservabilityAlertCoversNewApps(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/observability-alerts", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("obsal"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"app_count":           1,
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	resourceGroupID := terraform.Output(t, terraformOptions, "resource_group_id")
+	alertID := terraform.Output(t, terraformOptions, "resource_health_alert_id")
+
+	alert := helpers.GetActivityLogAlert(t, alertID)
+	assert.True(t, alert.Enabled, "the alert should be enabled")
+	assert.Equal(t, []string{resourceGroupID}, alert.Scopes, "the alert should be scoped to the resource group, not to apps")
+	for _, appID := range terraform.OutputList(t, terraformOptions, "container_app_ids") {
+		assert.True(t, alert.Covers(appID, containerAppResourceType), "the alert should cover %s", appID)
+	}
+
+	// Adding an app must not touch the alert
+	updatedVars := map[string]interface{}{}
+	for key, value := range terraformOptions.Vars {
+		updatedVars[key] = value
+	}
+	updatedVars["app_count"] = 2
+
+	updatedOptions := *terraformOptions
+	updatedOptions.Vars = updatedVars
+	updatedOptions.PlanFilePath = filepath.Join(terraformOptions.TerraformDir, "apps.tfplan")
+
+	planJSON := helpers.InitAndPlanAndShow(t, &updatedOptions)
+	actions, attributes, err := helpers.PlannedChangeE(planJSON, resourceHealthAlertAddress)
+	if err != nil {
+		t.Fatalf("Reading planned change: %v", err)
+	}
+	assert.Equal(t, []string{"no-op"}, actions, "adding an app should not change the alert (changes %v)", attributes)
+
+	phases.Start("apply")
+	helpers.Apply(t, &updatedOptions)
+	phases.Start("verify")
+
+	appIDs := terraform.OutputList(t, &updatedOptions, "container_app_ids")
+	if assert.Len(t, appIDs, 2) {
+		alert = helpers.GetActivityLogAlert(t, alertID)
+		assert.Equal(t, []string{resourceGroupID}, alert.Scopes, "the alert's scopes should not change")
+		assert.True(t, alert.Covers(appIDs[1], containerAppResourceType), "the alert should cover the new app %s", appIDs[1])
+	}
+}
@@ -0,0 +1,145 @@
+package test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/planassert"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// availabilityTestAddress is the observability module's web test in the
+	// observability-availability fixture
+	availabilityTestAddress = "module.observability.azurerm_application_insights_standard_web_test.health[0]"
+	// minimumReportingLocations is how many configured probe locations must
+	// deliver results for a multi-location test to count as working
+	minimumReportingLocations = 2
+)
+
+// availabilityTestLocations are the probe locations the applied test
+// configures, one per continent
+var availabilityTestLocations = []string{"us-va-ash-azr", "emea-nl-ams-azr", "apac-sg-sin-azr"}
+
+// availabilityReport is the availability report entry of a run
+type availabilityReport struct {
+	Locations []string       `json:"locations"`
+	Reporting []string       `json:"reporting"`
+	Results   map[string]int `json:"results"`
+}
+
+// TestObservabilityAvailabilityLocationValidation plans the
+// observability-availability fixture with different test locations. Only
+// known location codes are accepted, each once, and valid lists reach the web
+// test unchanged
+func TestObservabilityAvailabilityLocationValidation(t *testing.T) {
+	t.Parallel()
+
+	// Nothing is deployed, so names are fixed and identical plans can be
+	// reused from the plan cache, also by later runs
+	config := helpers.NewTestConfig(t)
+
+	testCases := []struct {
+		name          string
+		locations     []string
+		expectedError string
+	}{
+		{"single", []string{"us-va-ash-azr"}, ""},
+		{"multiple", availabilityTestLocations, ""},
+		{"edge_locations", []string{"emea-fr-pra-edge", "apac-jp-kaw-edge", "latam-br-gru-edge"}, ""},
+		{"region_name", []string{"us-va-ash-azr", "eastus"}, "availability test location codes"},
+		{"retired_code", []string{"apac-jp-kaw-azr"}, "availability test location codes"},
+		{"wrong_case", []string{"US-VA-ASH-AZR"}, "availability test location codes"},
+		{"duplicate", []string{"us-va-ash-azr", "emea-nl-ams-azr", "us-va-ash-azr"}, "must not contain duplicates"},
+		{"empty", []string{}, "between 1 and 16 locations"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/observability-availability", map[string]interface{}{
+				"resource_group_name": "rg-obsavl-validation",
+				"location":            config.Location,
+				"name_suffix":         "validation",
+				"test_locations":      tc.locations,
+			})
+
+			// Every case plans the same fixture, so they share one init
+			planJSON, err := helpers.CachedPlanE(t, terraformOptions)
+			if tc.expectedError != "" {
+				if assert.Error(t, err, "Expected validation error for test locations %v", tc.locations) {
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Planning test locations %v: %v", tc.locations, err)
+			}
+
+			plan := planassert.Parse(t, planJSON)
+			locations, err := plan.AttributeE(availabilityTestAddress, "geo_locations")
+			if assert.NoError(t, err) {
+				assert.ElementsMatch(t, tc.locations, locations, "the web test should run from exactly the given locations")
+			}
+		})
+	}
+}
+
+// TestObservabilityAvailabilityLocations applies the observability module
+// with an availability test probing from three locations and waits for its
+// results in the workspace. Results must arrive from at least two of the
+// configured locations, so a test silently reduced to one location, or
+// running from locations other than the configured ones, fails. Opt in with
+// TEST_AVAILABILITY_LOCATIONS=true, since the first results take a while
+func TestObservabilityAvailabilityLocations(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_AVAILABILITY_LOCATIONS") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_AVAILABILITY_LOCATIONS=true to verify availability results from multiple probe locations")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/observability-availability", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("avl"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"test_locations":      availabilityTestLocations,
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	testName := terraform.Output(t, terraformOptions, "availability_test_name")
+	workspaceID := terraform.Output(t, terraformOptions, "log_analytics_workspace_id")
+
+	// The test runs every 5 minutes from each location; the first results
+	// can take several runs to reach the workspace
+	results, err := helpers.WaitForAvailabilityLocationsE(t, workspaceID, testName, availabilityTestLocations, minimumReportingLocations, 30*time.Minute)
+	reporting := helpers.ReportingLocations(availabilityTestLocations, results)
+	helpers.RecordReport(t, "availability", t.Name(), availabilityReport{
+		Locations: availabilityTestLocations,
+		Reporting: reporting,
+		Results:   results,
+	})
+	if err != nil {
+		t.Fatalf("Waiting for availability results: %v", err)
+	}
+	assert.GreaterOrEqual(t, len(reporting), minimumReportingLocations, "results should arrive from at least %d configured locations", minimumReportingLocations)
+}
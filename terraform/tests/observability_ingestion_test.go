@@ -0,0 +1,232 @@
+package test
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/planassert"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// The observability module's ingestion alerts in the
+	// observability-ingestion fixture
+	dailyCapAlertAddress           = "module.observability.azurerm_monitor_scheduled_query_rules_alert_v2.daily_cap[0]"
+	ingestionThresholdAlertAddress = "module.observability.azurerm_monitor_scheduled_query_rules_alert_v2.ingestion_threshold[0]"
+	ingestionAnomalyAlertAddress   = "module.observability.azurerm_monitor_scheduled_query_rules_alert_v2.ingestion_anomaly[0]"
+
+	// capFloodFactor is how many times the daily cap the cap-breach test
+	// sends, so the cap is reached however ingestion is metered
+	capFloodFactor = 2
+)
+
+// ingestionReport is the ingestion_alerts report entry of a run
+type ingestionReport struct {
+	DailyQuotaGB  float64 `json:"daily_quota_gb"`
+	SentTraces    int     `json:"sent_traces"`
+	SentBytes     int     `json:"sent_bytes"`
+	AlertRule     string  `json:"alert_rule"`
+	SecondsToFire float64 `json:"seconds_to_fire"`
+}
+
+// TestObservabilityIngestionAlertValidation plans the observability-ingestion
+// fixture with different caps and alert settings. Invalid thresholds, anomaly
+// factors, caps and webhook URLs are rejected; the cap alerts exist only with
+// a cap, and the threshold alert fires at the configured share of it
+func TestObservabilityIngestionAlertValidation(t *testing.T) {
+	t.Parallel()
+
+	// Nothing is deployed, so names are fixed and identical plans can be
+	// reused from the plan cache, also by later runs
+	config := helpers.NewTestConfig(t)
+
+	testCases := []struct {
+		name          string
+		quotaGB       interface{}
+		threshold     int
+		factor        int
+		webhookURL    string
+		expectedError string
+		// expectedQuery is in the threshold alert's query when it is planned
+		expectedQuery  string
+		expectedAlerts []string
+	}{
+		{"quota_2gb", 2, 80, 3, "", "", "IngestedMB > 1600", []string{dailyCapAlertAddress, ingestionThresholdAlertAddress, ingestionAnomalyAlertAddress}},
+		{"threshold_50_percent", 2, 50, 3, "https://hooks.example.com/alerts", "", "IngestedMB > 1000", []string{dailyCapAlertAddress, ingestionThresholdAlertAddress, ingestionAnomalyAlertAddress}},
+		{"no_quota", nil, 80, 3, "", "", "", []string{ingestionAnomalyAlertAddress}},
+		{"threshold_zero", 2, 0, 3, "", "between 1 and 100", "", nil},
+		{"threshold_over_100", 2, 101, 3, "", "between 1 and 100", "", nil},
+		{"anomaly_factor_one", 2, 80, 1, "", "greater than 1", "", nil},
+		{"quota_below_minimum", 0.01, 80, 3, "", "at least 0.023 GB", "", nil},
+		{"http_webhook", 2, 80, 3, "http://hooks.example.com/alerts", "https:// URLs", "", nil},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/observability-ingestion", map[string]interface{}{
+				"resource_group_name":               "rg-obsing-validation",
+				"location":                          config.Location,
+				"name_suffix":                       "validation",
+				"daily_quota_gb":                    tc.quotaGB,
+				"ingestion_alert_threshold_percent": tc.threshold,
+				"ingestion_anomaly_factor":          tc.factor,
+				"webhook_url":                       tc.webhookURL,
+			})
+
+			// Every case plans the same fixture, so they share one init
+			planJSON, err := helpers.CachedPlanE(t, terraformOptions)
+			if tc.expectedError != "" {
+				if assert.Error(t, err, "Expected validation error for quota %v, threshold %d, factor %d and webhook %q",
+					tc.quotaGB, tc.threshold, tc.factor, tc.webhookURL) {
+					assert.Contains(t, err.Error(), tc.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Planning ingestion alerts: %v", err)
+			}
+
+			plan := planassert.Parse(t, planJSON)
+			for _, address := range []string{dailyCapAlertAddress, ingestionThresholdAlertAddress, ingestionAnomalyAlertAddress} {
+				if slices.Contains(tc.expectedAlerts, address) {
+					planassert.AssertResourcePlanned(t, plan, address)
+				} else {
+					planassert.AssertResourceNotPlanned(t, plan, address)
+				}
+			}
+
+			if tc.expectedQuery != "" {
+				query, err := plan.AttributeE(ingestionThresholdAlertAddress, "criteria.0.query")
+				if assert.NoError(t, err) {
+					assert.Contains(t, query, tc.expectedQuery)
+				}
+			}
+		})
+	}
+}
+
+// TestObservabilityIngestionAlerts applies the observability module with a
+// daily cap and ingestion alerts. Each alert rule must be enabled, query the
+// workspace and notify the module's action group
+func TestObservabilityIngestionAlerts(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/observability-ingestion", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("obsing"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	workspaceID := terraform.Output(t, terraformOptions, "log_analytics_workspace_id")
+	actionGroupID := terraform.Output(t, terraformOptions, "alert_action_group_id")
+
+	for _, output := range []string{"daily_cap_alert_id", "ingestion_threshold_alert_id", "ingestion_anomaly_alert_id"} {
+		rule := helpers.GetScheduledQueryRule(t, terraform.Output(t, terraformOptions, output))
+		assert.True(t, rule.Enabled, "%s should be enabled", output)
+		// ARM IDs are compared case-insensitively
+		if assert.Len(t, rule.Scopes, 1, "%s should watch one workspace", output) {
+			assert.True(t, strings.EqualFold(workspaceID, rule.Scopes[0]), "%s should watch %s, not %s", output, workspaceID, rule.Scopes[0])
+		}
+		if assert.Len(t, rule.Actions.ActionGroups, 1, "%s should notify one action group", output) {
+			assert.True(t, strings.EqualFold(actionGroupID, rule.Actions.ActionGroups[0]), "%s should notify %s, not %s", output, actionGroupID, rule.Actions.ActionGroups[0])
+		}
+		assert.NotEmpty(t, rule.Queries(), "%s should have a query", output)
+	}
+
+	capRule := helpers.GetScheduledQueryRule(t, terraform.Output(t, terraformOptions, "daily_cap_alert_id"))
+	assert.Equal(t, 1, capRule.Severity, "reaching the cap loses telemetry, so it should outrank the volume alerts")
+}
+
+// TestObservabilityDailyCapAlert applies the observability module with the
+// smallest daily cap and its alerts posting to the shared webhook receiver,
+// then sends twice the cap in synthetic traces. Once the workspace stops
+// collecting, the daily cap alert must fire and reach the receiver.
+// Opt in with TEST_INGESTION_CAP_ALERT=true, since the cap and the alert
+// take up to hours to react
+func TestObservabilityDailyCapAlert(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_INGESTION_CAP_ALERT") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_INGESTION_CAP_ALERT=true to flood a capped workspace until its daily cap alert fires")
+	}
+
+	receiver := helpers.SharedWebhookReceiver(t)
+	source := helpers.WorkspaceName(helpers.RunID(), t.Name())
+
+	const dailyQuotaGB = 0.023
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/observability-ingestion", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("obscap"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"daily_quota_gb":      dailyQuotaGB,
+		"webhook_url":         receiver.HookURL(source),
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	connectionString := helpers.SensitiveOutput(t, terraformOptions, "app_insights_connection_string")
+	alertRule := "alert-cap-" + terraform.Output(t, terraformOptions, "log_analytics_name")
+
+	// The cap is in decimal GB, as the Usage table reports it
+	floodBytes := int(dailyQuotaGB * capFloodFactor * 1e9)
+	start := time.Now()
+	sent, err := helpers.SendSyntheticTracesE(connectionString, "cap-"+config.UniqueID, floodBytes)
+	if err != nil {
+		t.Fatalf("Sending synthetic traces after %d: %v", sent, err)
+	}
+	t.Logf("Sent %d traces, %d bytes, to reach a %.3f GB cap", sent, floodBytes, dailyQuotaGB)
+
+	payload, err := helpers.WaitForWebhookE(t, receiver, source, func(p helpers.WebhookPayload) bool {
+		alert, err := helpers.DecodeCommonAlertE(p)
+		return err == nil && alert.AlertRule == alertRule && alert.MonitorCondition == "Fired"
+	}, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("The daily cap alert %s never reached the receiver: %v", alertRule, err)
+	}
+
+	helpers.RecordReport(t, "ingestion_alerts", t.Name(), ingestionReport{
+		DailyQuotaGB:  dailyQuotaGB,
+		SentTraces:    sent,
+		SentBytes:     floodBytes,
+		AlertRule:     alertRule,
+		SecondsToFire: payload.ReceivedAt.Sub(start).Seconds(),
+	})
+}
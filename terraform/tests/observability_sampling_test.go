@@ -0,0 +1,91 @@
+package test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+)
+
+const (
+	// samplingPercentage is the observability module setting under test
+	samplingPercentage = 10
+	// samplingRequests is how many synthetic requests are sent; at 10% the
+	// expected 200 ingested are far from the 2000 of ignored sampling
+	samplingRequests = 2000
+)
+
+// samplingReport is the sampling report entry of a run
+type samplingReport struct {
+	Percentage float64                  `json:"percentage"`
+	Sent       int                      `json:"sent"`
+	Low        int                      `json:"low"`
+	High       int                      `json:"high"`
+	Sampled    *helpers.SampledRequests `json:"sampled"`
+}
+
+// TestObservabilityIngestionSampling applies the observability module with
+// sampling_percentage = 10 and sends synthetic requests straight to its
+// Application Insights ingestion endpoint. The number of requests stored in
+// the workspace must be consistent with 10% sampling, and each stored row
+// must stand for 10 requests. A setting that reaches the resource but is
+// ignored at ingestion stores every request once and fails both checks.
+// Opt in with TEST_SAMPLING=true, since ingestion takes a while to settle
+func TestObservabilityIngestionSampling(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_SAMPLING") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_SAMPLING=true to verify Application Insights ingestion sampling")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/observability-sampling", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("smp"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"sampling_percentage": samplingPercentage,
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	connectionString := helpers.SensitiveOutput(t, terraformOptions, "app_insights_connection_string")
+	workspaceID := terraform.Output(t, terraformOptions, "log_analytics_workspace_id")
+
+	// The role keeps this run's requests apart from anything else
+	role := "smp-" + config.UniqueID
+	if err := helpers.SendSyntheticRequestsE(connectionString, role, samplingRequests); err != nil {
+		t.Fatalf("Sending synthetic requests: %v", err)
+	}
+
+	sampled, err := helpers.WaitForSampledRequestsE(t, workspaceID, role, 5*time.Minute, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Waiting for sampled requests: %v", err)
+	}
+
+	low, high := helpers.SamplingBounds(samplingRequests, samplingPercentage)
+	helpers.RecordReport(t, "sampling", t.Name(), samplingReport{
+		Percentage: samplingPercentage,
+		Sent:       samplingRequests,
+		Low:        low,
+		High:       high,
+		Sampled:    sampled,
+	})
+	for _, violation := range helpers.SamplingViolations(samplingRequests, samplingPercentage, sampled) {
+		t.Error(violation)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/gruntwork-io/terratest/modules/azure"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/basevars"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,11 +18,12 @@ func TestObservabilityBasic(t *testing.T) {
 	t.Parallel()
 
 	subscriptionID := azure.GetSubscriptionID(t)
-	uniqueID := strings.ToLower(random.UniqueId())
+	uniqueID := helpers.StageUniqueID(t)
 	resourceGroupName := fmt.Sprintf("rg-obs-test-%s", uniqueID)
 	logAnalyticsName := fmt.Sprintf("log-test-%s", uniqueID)
 	appInsightsName := fmt.Sprintf("appi-test-%s", uniqueID)
 	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.OperationalInsights/workspaces", "Microsoft.Insights/components")
 
 	// Create resource group
 	rgOptions := &terraform.Options{
@@ -33,8 +36,9 @@ func TestObservabilityBasic(t *testing.T) {
 			},
 		},
 	}
-	defer terraform.Destroy(t, rgOptions)
-	terraform.InitAndApply(t, rgOptions)
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, rgOptions)
+	helpers.InitAndApply(t, rgOptions)
 
 	// Create observability stack
 	obsOptions := &terraform.Options{
@@ -50,8 +54,12 @@ func TestObservabilityBasic(t *testing.T) {
 			},
 		},
 	}
-	defer terraform.Destroy(t, obsOptions)
-	terraform.InitAndApply(t, obsOptions)
+	defer helpers.Destroy(t, obsOptions)
+	helpers.InitAndApply(t, obsOptions)
+	helpers.StartValidation(t)
+	helpers.AssertCostProfile(t, obsOptions, "observability")
+	helpers.AssertIdempotent(t, obsOptions)
+	helpers.CheckAdvisorRecommendations(t, resourceGroupName)
 
 	// Verify Log Analytics exists
 	workspace := azure.GetLogAnalyticsWorkspace(t, resourceGroupName, logAnalyticsName, subscriptionID)
@@ -68,6 +76,8 @@ func TestObservabilityBasic(t *testing.T) {
 	assert.NotEmpty(t, outputs["app_insights_id"], "App Insights ID should not be empty")
 	assert.NotEmpty(t, outputs["app_insights_name"], "App Insights name should not be empty")
 	assert.NotEmpty(t, outputs["app_insights_connection_string"], "App Insights connection string should not be empty")
+	helpers.AssertAppInsightsConnectionString(t, fmt.Sprint(outputs["app_insights_connection_string"]),
+		fmt.Sprint(outputs["app_insights_instrumentation_key"]))
 }
 
 // TestObservabilityWithAvailabilityTest tests observability with availability test
@@ -75,14 +85,16 @@ func TestObservabilityWithAvailabilityTest(t *testing.T) {
 	t.Parallel()
 
 	if testing.Short() {
-		t.Skip("Skipping slow test in short mode")
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
 	}
 
-	uniqueID := strings.ToLower(random.UniqueId())
+	subscriptionID := azure.GetSubscriptionID(t)
+	uniqueID := helpers.StageUniqueID(t)
 	resourceGroupName := fmt.Sprintf("rg-obs-webtest-%s", uniqueID)
 	logAnalyticsName := fmt.Sprintf("log-webtest-%s", uniqueID)
 	appInsightsName := fmt.Sprintf("appi-webtest-%s", uniqueID)
 	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.OperationalInsights/workspaces", "Microsoft.Insights/components")
 
 	// Create resource group
 	rgOptions := &terraform.Options{
@@ -92,30 +104,33 @@ func TestObservabilityWithAvailabilityTest(t *testing.T) {
 			"location": location,
 		},
 	}
-	defer terraform.Destroy(t, rgOptions)
-	terraform.InitAndApply(t, rgOptions)
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, rgOptions)
+	helpers.InitAndApply(t, rgOptions)
 
 	// Create observability with availability test
 	obsOptions := &terraform.Options{
 		TerraformDir: "../modules/observability",
 		Vars: map[string]interface{}{
-			"resource_group_name":     resourceGroupName,
-			"location":                location,
-			"log_analytics_name":      logAnalyticsName,
-			"app_insights_name":       appInsightsName,
+			"resource_group_name":      resourceGroupName,
+			"location":                 location,
+			"log_analytics_name":       logAnalyticsName,
+			"app_insights_name":        appInsightsName,
 			"create_availability_test": true,
-			"health_check_url":        "https://www.google.com/health",
+			"health_check_url":         "https://www.google.com/health",
 			"tags": map[string]string{
 				"Environment": "test",
 			},
 		},
 	}
-	defer terraform.Destroy(t, obsOptions)
-	terraform.InitAndApply(t, obsOptions)
+	defer helpers.Destroy(t, obsOptions)
+	helpers.InitAndApply(t, obsOptions)
+	helpers.StartValidation(t)
 
 	// Verify deployment
 	outputs := terraform.OutputAll(t, obsOptions)
 	assert.NotEmpty(t, outputs["app_insights_id"], "App Insights should be created")
+	assert.NotEmpty(t, outputs["availability_test_name"], "Availability test should be created")
 }
 
 // TestObservabilitySamplingValidation tests sampling percentage validation
@@ -142,13 +157,7 @@ func TestObservabilitySamplingValidation(t *testing.T) {
 
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/observability",
-				Vars: map[string]interface{}{
-					"resource_group_name": "rg-nonexistent",
-					"location":            "eastus2",
-					"log_analytics_name":  fmt.Sprintf("log-%s", uniqueID),
-					"app_insights_name":   fmt.Sprintf("appi-%s", uniqueID),
-					"sampling_percentage": tc.sampling,
-				},
+				Vars:         basevars.MinimalObservabilityVars(uniqueID).With("sampling_percentage", tc.sampling),
 			}
 
 			if tc.shouldFail {
@@ -184,13 +193,7 @@ func TestObservabilityApplicationTypeValidation(t *testing.T) {
 
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/observability",
-				Vars: map[string]interface{}{
-					"resource_group_name": "rg-nonexistent",
-					"location":            "eastus2",
-					"log_analytics_name":  fmt.Sprintf("log-%s", uniqueID),
-					"app_insights_name":   fmt.Sprintf("appi-%s", uniqueID),
-					"application_type":    tc.applicationType,
-				},
+				Vars:         basevars.MinimalObservabilityVars(uniqueID).With("application_type", tc.applicationType),
 			}
 
 			if tc.shouldFail {
@@ -224,13 +227,7 @@ func TestObservabilityRetentionValidation(t *testing.T) {
 
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/observability",
-				Vars: map[string]interface{}{
-					"resource_group_name":         "rg-nonexistent",
-					"location":                    "eastus2",
-					"log_analytics_name":          fmt.Sprintf("log-%s", uniqueID),
-					"app_insights_name":           fmt.Sprintf("appi-%s", uniqueID),
-					"log_analytics_retention_days": tc.retention,
-				},
+				Vars:         basevars.MinimalObservabilityVars(uniqueID).With("log_analytics_retention_days", tc.retention),
 			}
 
 			if tc.shouldFail {
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// traceResponse is what the echo fixture's /trace endpoint returns
+type traceResponse struct {
+	Role           string         `json:"role"`
+	TraceID        string         `json:"trace_id"`
+	ParentID       string         `json:"parent_id"`
+	SpanID         string         `json:"span_id"`
+	DependencyID   string         `json:"dependency_id"`
+	Downstream     *traceResponse `json:"downstream"`
+	DownstreamCode int            `json:"downstream_status"`
+	Error          string         `json:"error"`
+	TelemetryError string         `json:"telemetry_error"`
+}
+
+// TestObservabilityDistributedTrace deploys two echo fixture apps wired to
+// the same Application Insights and sends the frontend a request with a W3C
+// traceparent; the frontend calls the backend with its own span as parent.
+// Application Insights must then hold both apps' request spans and the
+// frontend's dependency span under one operation ID, chained parent to child
+func TestObservabilityDistributedTrace(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name": config.GenerateResourceGroupName("trace"),
+			"location":            config.Location,
+			"name_suffix":         config.UniqueID,
+			"tags":                helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/tracing", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	// First apply creates the observability stack and registry; the apps
+	// follow once the echo image is in the registry
+	phases.Start("apply")
+	helpers.DeployWithRegionFallback(t, config, terraformOptions, vars, func() error {
+		if _, err := terraform.InitAndApplyE(t, terraformOptions); err != nil {
+			return err
+		}
+		phases.Start("build")
+		image := helpers.BuildFixtureImage(t, "echo", terraform.Output(t, terraformOptions, "registry_login_server"))
+		terraformOptions.Vars["container_image"] = image.Reference
+		phases.Start("apply")
+		_, err := terraform.ApplyE(t, terraformOptions)
+		return err
+	})
+	phases.Start("verify")
+
+	frontendURL := terraform.Output(t, terraformOptions, "frontend_url")
+	backendURL := terraform.Output(t, terraformOptions, "backend_url")
+	workspaceID := terraform.Output(t, terraformOptions, "log_analytics_workspace_id")
+	helpers.RequireEndpointReady(t, frontendURL)
+	helpers.RequireEndpointReady(t, backendURL)
+
+	traceparent := helpers.NewTraceparent()
+	frontend := sendTracedRequest(t, frontendURL, backendURL, traceparent)
+	if !assert.NotNil(t, frontend.Downstream, "the frontend should return the backend's result") {
+		return
+	}
+	backend := frontend.Downstream
+
+	// Both apps must have joined the caller's trace and chained their spans
+	assert.Equal(t, traceparent.TraceID, frontend.TraceID, "frontend trace ID")
+	assert.Equal(t, traceparent.ParentID, frontend.ParentID, "frontend parent span")
+	assert.Equal(t, traceparent.TraceID, backend.TraceID, "backend trace ID")
+	assert.Equal(t, frontend.DependencyID, backend.ParentID, "backend parent span")
+	assert.Empty(t, frontend.TelemetryError, "frontend telemetry")
+	assert.Empty(t, backend.TelemetryError, "backend telemetry")
+
+	// Ingestion into the workspace usually takes a few minutes
+	var spans []helpers.TraceSpan
+	_, err := retry.DoWithRetryE(t, "waiting for the trace in Application Insights", 40, 15*time.Second, func() (string, error) {
+		var err error
+		spans, err = helpers.TraceSpansE(t, workspaceID, traceparent.TraceID)
+		if err != nil {
+			return "", err
+		}
+		if helpers.FindSpan(spans, "AppRequests", frontend.SpanID) == nil ||
+			helpers.FindSpan(spans, "AppDependencies", frontend.DependencyID) == nil ||
+			helpers.FindSpan(spans, "AppRequests", backend.SpanID) == nil {
+			return "", fmt.Errorf("operation %s has %d of its spans so far", traceparent.TraceID, len(spans))
+		}
+		return "", nil
+	})
+	if err != nil {
+		t.Fatalf("Trace %s did not reach Application Insights: %v", traceparent.TraceID, err)
+	}
+
+	frontendRequest := helpers.FindSpan(spans, "AppRequests", frontend.SpanID)
+	dependency := helpers.FindSpan(spans, "AppDependencies", frontend.DependencyID)
+	backendRequest := helpers.FindSpan(spans, "AppRequests", backend.SpanID)
+
+	for _, span := range []*helpers.TraceSpan{frontendRequest, dependency, backendRequest} {
+		assert.Equal(t, traceparent.TraceID, span.OperationID, "%s %s operation ID", span.Table, span.ID)
+	}
+	assert.Equal(t, traceparent.ParentID, frontendRequest.ParentID, "the frontend request should be a child of the caller")
+	assert.Equal(t, frontendRequest.ID, dependency.ParentID, "the backend call should be a child of the frontend request")
+	assert.Equal(t, dependency.ID, backendRequest.ParentID, "the backend request should be a child of the backend call")
+
+	assert.Equal(t, frontend.Role, frontendRequest.Role)
+	assert.Equal(t, backend.Role, backendRequest.Role)
+	assert.NotEqual(t, frontendRequest.Role, backendRequest.Role, "each app should report its own cloud role")
+}
+
+// sendTracedRequest calls the frontend's /trace endpoint with traceparent and
+// the backend as downstream, retrying until both apps answer
+func sendTracedRequest(t *testing.T, frontendURL, backendURL string, traceparent helpers.Traceparent) *traceResponse {
+	endpoint := fmt.Sprintf("%s/trace?downstream=%s", frontendURL, url.QueryEscape(backendURL+"/trace"))
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var result traceResponse
+	retry.DoWithRetry(t, "sending a traced request through both apps", 30, 20*time.Second, func() (string, error) {
+		request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+		if err != nil {
+			return "", err
+		}
+		request.Header.Set("traceparent", traceparent.String())
+		response, err := client.Do(request)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+
+		result = traceResponse{}
+		if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+			return "", fmt.Errorf("%s returned %d: %w", endpoint, response.StatusCode, err)
+		}
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s returned %d: %s", endpoint, response.StatusCode, result.Error)
+		}
+		return "", nil
+	})
+	return &result
+}
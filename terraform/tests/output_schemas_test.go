@@ -0,0 +1,35 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestModuleOutputSchemas checks that every module has a committed output
+// schema that compiles and describes exactly the outputs it declares. The
+// schemas are what InitAndApply checks applied outputs against, so an output
+// added, renamed or removed without its schema would go unchecked
+func TestModuleOutputSchemas(t *testing.T) {
+	t.Parallel()
+
+	modules, err := filepath.Glob("../modules/*/outputs.tf")
+	if err != nil || len(modules) == 0 {
+		t.Fatalf("No modules found: %v", err)
+	}
+
+	for _, outputsFile := range modules {
+		moduleDir := filepath.Dir(outputsFile)
+		t.Run(filepath.Base(moduleDir), func(t *testing.T) {
+			t.Parallel()
+
+			problems, err := helpers.OutputSchemaDeclarationProblemsE(moduleDir)
+			if err != nil {
+				t.Fatalf("Checking %s: %v", helpers.OutputSchemaPath(filepath.Base(moduleDir)), err)
+			}
+			assert.Empty(t, problems, "%s is out of date", helpers.OutputSchemaPath(filepath.Base(moduleDir)))
+		})
+	}
+}
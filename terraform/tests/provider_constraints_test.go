@@ -0,0 +1,86 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+// azurermSource is the provider whose window the modules are planned under
+const azurermSource = "hashicorp/azurerm"
+
+// TestProviderConstraintsWithinWindow reads the required_providers blocks of
+// every module and fails on each constraint of a provider with a window in
+// testdata/provider-windows.json that does not fall within it: one allowing
+// a version older than its min or a major version past its max, or not
+// allowing its max. A module must constrain every provider with a window
+func TestProviderConstraintsWithinWindow(t *testing.T) {
+	t.Parallel()
+
+	windows, err := helpers.ReadProviderWindowsE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules, err := filepath.Glob("../modules/*/versions.tf")
+	if err != nil || len(modules) == 0 {
+		t.Fatalf("No modules found: %v", err)
+	}
+
+	for _, versions := range modules {
+		dir := filepath.Dir(versions)
+		requirements, err := helpers.FindProviderRequirementsE(dir)
+		if err != nil {
+			t.Fatalf("Reading the required providers of %s: %v", dir, err)
+		}
+		constrained := map[string]bool{}
+		for _, requirement := range requirements {
+			window, exists := windows[requirement.Source]
+			if !exists {
+				continue
+			}
+			constrained[requirement.Source] = true
+			if err := window.CheckConstraint(requirement.Version); err != nil {
+				t.Errorf("%s:%d %s: %v (window %s to %s)", requirement.File, requirement.Line, requirement.Name, err, window.Min, window.Max)
+			}
+		}
+		assert.True(t, constrained[azurermSource], "%s should require %s", filepath.Base(dir), azurermSource)
+	}
+}
+
+// TestProviderMaxVersionPlans plans every module with its provider upgrade
+// dry run inputs after `terraform init -upgrade` with azurerm pinned to the
+// max of its window, so raising the max in testdata/provider-windows.json is
+// only accepted once the modules plan cleanly under it. Planning needs Azure
+// credentials for the provider, but nothing is applied
+func TestProviderMaxVersionPlans(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	windows, err := helpers.ReadProviderWindowsE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	window, exists := windows[azurermSource]
+	if !exists {
+		t.Fatalf("testdata/provider-windows.json has no window for %s", azurermSource)
+	}
+	modules, err := filepath.Glob("../modules/*/versions.tf")
+	if err != nil || len(modules) == 0 {
+		t.Fatalf("No modules found: %v", err)
+	}
+
+	for _, versions := range modules {
+		module := filepath.Base(filepath.Dir(versions))
+		t.Run(module, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := helpers.PlanWithProviderVersionE(t, module, window.Max)
+			assert.NoError(t, err, "%s should plan with azurerm %s, the max of its window", module, window.Max)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestProviderUpgradeDryRun plans every module with the azurerm version its
+// constraints select today and again with the candidate release in
+// TEST_PROVIDER_UPGRADE (e.g. 4.30.0 or 5.0.0-beta1), which overrides the
+// constraint of every module. Errors and planned differences of each module
+// go to the provider_upgrade report; the test fails where only the candidate
+// cannot plan. Nothing is applied
+func TestProviderUpgradeDryRun(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	candidate := os.Getenv("TEST_PROVIDER_UPGRADE")
+	if candidate == "" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_PROVIDER_UPGRADE to an azurerm version to dry-run the upgrade")
+	}
+
+	modules, err := filepath.Glob("../modules/*/versions.tf")
+	if err != nil || len(modules) == 0 {
+		t.Fatalf("No modules found: %v", err)
+	}
+
+	for _, versions := range modules {
+		module := filepath.Base(filepath.Dir(versions))
+		t.Run(module, func(t *testing.T) {
+			t.Parallel()
+
+			result := helpers.DryRunProviderUpgrade(t, module, candidate)
+			helpers.RecordReport(t, "provider_upgrade", module, result)
+
+			if result.BaselineError != "" {
+				t.Logf("%s does not plan with the current provider either:\n%s", module, result.BaselineError)
+			}
+			assert.False(t, result.Breaks(), "%s does not plan with azurerm %s:\n%s", module, candidate, result.CandidateError)
+			if len(result.Differences) > 0 {
+				t.Logf("%s plans differently with azurerm %s:\n  %s", module, candidate, strings.Join(result.Differences, "\n  "))
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// deliveryBranch is the branch the delivery pipeline deploys to dev from
+const deliveryBranch = "refs/heads/dev"
+
+// defaultProjectName is the dev composition's default project_name, which
+// the pipeline's resource names are derived from
+const defaultProjectName = "finrisk"
+
+// pushToDeployMeasurement is how long each step of one push to deploy took
+type pushToDeployMeasurement struct {
+	Region string `json:"region"`
+	Branch string `json:"branch"`
+	Image  string `json:"image"`
+	// InfrastructureSeconds is the composition's apply, the build excluded
+	InfrastructureSeconds float64 `json:"infrastructure_seconds"`
+	BuildSeconds          float64 `json:"build_seconds"`
+	DeploySeconds         float64 `json:"deploy_seconds"`
+	// VerifySeconds is how long the new revision took to pass the smoke tests
+	VerifySeconds float64 `json:"verify_seconds"`
+	TotalSeconds  float64 `json:"total_seconds"`
+	MeasuredAt    string  `json:"measured_at"`
+}
+
+// TestPushToDeploy follows the delivery pipeline (pipelines/
+// azure-pipelines-app.yml) for a push to dev, end to end: the dev
+// composition is applied under a project name of the test's own, the echo
+// fixture is built and pushed as the pipeline's image, tagged with the
+// checkout's version and latest, and the app is moved to that version with
+// `az containerapp update`, then smoke tested on /health and /ready. Pushing
+// and deploying run as the pipeline's workload identity (TEST_CI_CLIENT_ID)
+// signed in with a CI OIDC token, with the roles its service connections
+// hold; Terraform runs as the runner, as it does outside the pipeline.
+// The pipeline's resource names must be those the composition gives its
+// default project. How long each step took goes to the push_to_deploy
+// report. Opt in with TEST_PUSH_TO_DEPLOY=true; the composition's Key Vault
+// has purge protection, so each run leaves a soft-deleted vault behind
+func TestPushToDeploy(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_PUSH_TO_DEPLOY") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_PUSH_TO_DEPLOY=true to deploy the dev composition the way the delivery pipeline does")
+	}
+
+	pipeline, err := helpers.LoadDeliveryPipelineE(helpers.DeliveryPipelineFile, deliveryBranch)
+	if err != nil {
+		t.Fatalf("Reading the delivery pipeline: %v", err)
+	}
+	if !assert.Equal(t, []string{"Build", "Deploy", "Verify"}, pipeline.Stages, "the steps below follow these stages") {
+		return
+	}
+	imageName := pipeline.Variables["imageName"]
+	version, err := helpers.ImageVersionE(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	ci := helpers.NewCIIdentity(t, config.TenantID, config.SubscriptionID)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			// No hyphen, so the registry name keeps the whole project name.
+			// A region fallback gives the config a new UniqueID, so each
+			// attempt gets names of its own
+			"project_name": "ptd" + config.UniqueID,
+			"environment":  "dev",
+			"location":     config.Location,
+			"tags":         helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, "../environments/dev", vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+	// The composition refuses to delete a resource group Azure has added
+	// resources to; a test deployment has to go entirely
+	overrideFile := filepath.Join(terraformOptions.TerraformDir, "providers_override.tf")
+	if err := os.WriteFile(overrideFile, []byte(deletableResourceGroupsOverride), 0o600); err != nil {
+		t.Fatalf("Writing %s: %v", overrideFile, err)
+	}
+
+	phases := helpers.TrackPhases(t)
+	// The composition names the group rg-<project>-<environment>, after the
+	// project of the last attempt
+	helpers.CheckResourceGroupFuncLeftoversAfterDestroy(t, config.SubscriptionID, func() string {
+		return "rg-" + terraformOptions.Vars["project_name"].(string) + "-dev"
+	})
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	start := time.Now()
+	var buildTime time.Duration
+	// The app is created from <registry>/<imageName>:latest, so the registry
+	// comes first and the rest of the composition once the image is in it
+	phases.Start("apply")
+	helpers.DeployWithRegionFallback(t, config, terraformOptions, vars, func() error {
+		if _, err := terraform.InitE(t, terraformOptions); err != nil {
+			return err
+		}
+		if _, err := helpers.ApplyTargetE(t, terraformOptions, "module.container_registry"); err != nil {
+			return err
+		}
+		var registryID string
+		helpers.AzCLIJSON(t, &registryID, "acr", "show", "--name", terraform.Output(t, terraformOptions, "container_registry_name"),
+			"--query", "id")
+		loginServer := terraform.Output(t, terraformOptions, "container_registry_login_server")
+		ci.AssignRole(t, "AcrPush", registryID)
+		ci.AssignRole(t, "Contributor", terraform.Output(t, terraformOptions, "resource_group_id"))
+
+		phases.Start("build")
+		buildStart := time.Now()
+		// Role assignments take a few minutes to reach the registry
+		_, err := retry.DoWithRetryE(t, fmt.Sprintf("pushing %s as %s", imageName, ci), 12, 20*time.Second, func() (string, error) {
+			auth, err := ci.RegistryAuthE(t, loginServer)
+			if err != nil {
+				return "", err
+			}
+			image, err := helpers.PublishFixtureImageE(t, "echo", loginServer, auth)
+			if err != nil {
+				return "", err
+			}
+			for _, tag := range []string{version, "latest"} {
+				if _, err := helpers.TagImageE(image.Reference, imageName, tag, auth); err != nil {
+					return "", err
+				}
+			}
+			return "", nil
+		})
+		if err != nil {
+			return err
+		}
+		buildTime = time.Since(buildStart)
+
+		phases.Start("apply")
+		_, err = terraform.ApplyE(t, terraformOptions)
+		return err
+	})
+	infrastructureTime := time.Since(start) - buildTime
+
+	resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+	fqdn := terraform.Output(t, terraformOptions, "container_app_fqdn")
+	appName := strings.SplitN(fqdn, ".", 2)[0]
+	loginServer := terraform.Output(t, terraformOptions, "container_registry_login_server")
+	image := fmt.Sprintf("%s/%s:%s", loginServer, imageName, version)
+
+	phases.Start("deploy")
+	deployStart := time.Now()
+	if _, err := ci.AzCLIE(t, "containerapp", "update", "--name", appName, "--resource-group", resourceGroupName,
+		"--image", image, "--output", "none"); err != nil {
+		t.Fatalf("Deploying %s as %s: %v", image, ci, err)
+	}
+	deployTime := time.Since(deployStart)
+
+	phases.Start("verify")
+	verifyStart := time.Now()
+	project := terraformOptions.Vars["project_name"].(string)
+	for name, actual := range map[string]string{
+		"containerAppName":  appName,
+		"resourceGroupName": resourceGroupName,
+		"containerRegistry": loginServer,
+	} {
+		assert.Equal(t, pipeline.Variables[name], strings.Replace(actual, project, defaultProjectName, 1),
+			"the pipeline's %s for %s should follow the composition's naming", name, deliveryBranch)
+	}
+
+	var deployed string
+	helpers.AzCLIJSON(t, &deployed, "containerapp", "show", "--resource-group", resourceGroupName,
+		"--name", appName, "--query", "properties.template.containers[0].image")
+	assert.Equal(t, image, deployed, "the app should run the version the pipeline deployed")
+	revision, err := helpers.LatestRevisionNameE(t, resourceGroupName, appName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applicationURL := terraform.Output(t, terraformOptions, "container_app_url")
+	helpers.RequireEndpointReady(t, applicationURL)
+	client := &http.Client{Timeout: 30 * time.Second}
+	// Smoke tests pass once the new revision answers both probes' paths;
+	// until then the old one may
+	retry.DoWithRetry(t, "smoke testing the deployed revision", 30, 10*time.Second, func() (string, error) {
+		for _, path := range []string{"/health", "/ready"} {
+			response, err := client.Get(applicationURL + path)
+			if err != nil {
+				return "", err
+			}
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+			if response.StatusCode != http.StatusOK {
+				return "", fmt.Errorf("%s returned %d", path, response.StatusCode)
+			}
+			if replica := response.Header.Get("X-Echo-Replica"); !strings.HasPrefix(replica, revision) {
+				return "", fmt.Errorf("%s was answered by replica %q, not one of %s", path, replica, revision)
+			}
+		}
+		return "", nil
+	})
+
+	helpers.RecordReport(t, "push_to_deploy", config.Location, pushToDeployMeasurement{
+		Region:                config.Location,
+		Branch:                deliveryBranch,
+		Image:                 image,
+		InfrastructureSeconds: infrastructureTime.Seconds(),
+		BuildSeconds:          buildTime.Seconds(),
+		DeploySeconds:         deployTime.Seconds(),
+		VerifySeconds:         time.Since(verifyStart).Seconds(),
+		TotalSeconds:          time.Since(start).Seconds(),
+		MeasuredAt:            time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// deletableResourceGroupsOverride replaces the dev composition's provider
+// features so destroy can delete resource groups holding resources Azure
+// added. Override files replace nested blocks whole, so the Key Vault
+// settings are repeated
+const deletableResourceGroupsOverride = `provider "azurerm" {
+  features {
+    key_vault {
+      purge_soft_delete_on_destroy               = false
+      purge_soft_deleted_keys_on_destroy         = false
+      purge_soft_deleted_secrets_on_destroy      = false
+      purge_soft_deleted_certificates_on_destroy = false
+    }
+
+    resource_group {
+      prevent_deletion_if_contains_resources = false
+    }
+  }
+}
+`
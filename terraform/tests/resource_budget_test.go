@@ -0,0 +1,86 @@
+package test
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+// budgetedModules returns the module directories, sorted
+func budgetedModules(t *testing.T) []string {
+	versions, err := filepath.Glob("../modules/*/versions.tf")
+	if err != nil || len(versions) == 0 {
+		t.Fatalf("No modules found: %v", err)
+	}
+	modules := make([]string, 0, len(versions))
+	for _, file := range versions {
+		modules = append(modules, filepath.Base(filepath.Dir(file)))
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// TestResourceBudgetsCoverConfigurations checks the committed budgets have
+// exactly one entry per module configuration, so a new module or example
+// cannot go unbudgeted and a removed one leaves nothing stale
+func TestResourceBudgetsCoverConfigurations(t *testing.T) {
+	t.Parallel()
+
+	budgets, err := helpers.LoadResourceBudgetsE(helpers.ResourceBudgetFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{}
+	for _, module := range budgetedModules(t) {
+		configurations, err := helpers.ModuleConfigurations(module)
+		if assert.NoError(t, err) {
+			sort.Strings(configurations)
+			expected[module] = configurations
+		}
+	}
+	actual := map[string][]string{}
+	for module, configurations := range budgets {
+		for configuration, budget := range configurations {
+			actual[module] = append(actual[module], configuration)
+			assert.LessOrEqual(t, budget.Min, budget.Max, "budget of %s (%s)", module, configuration)
+		}
+		sort.Strings(actual[module])
+	}
+	assert.Equal(t, expected, actual, "%s should budget every module's default plan and each of its examples", helpers.ResourceBudgetFile)
+}
+
+// TestModuleResourceBudgets plans every module configuration and fails when
+// one plans a number of resources outside its committed range, e.g. an
+// unintended diagnostic setting per secret. Counts by resource type go to
+// the resource_counts report. Nothing is applied
+func TestModuleResourceBudgets(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping plans against Azure in short mode")
+	}
+
+	for _, module := range budgetedModules(t) {
+		configurations, err := helpers.ModuleConfigurations(module)
+		if err != nil {
+			t.Fatalf("Finding configurations of %s: %v", module, err)
+		}
+		for _, configuration := range configurations {
+			module, configuration := module, configuration
+			t.Run(module+"/"+configuration, func(t *testing.T) {
+				t.Parallel()
+
+				plan, err := helpers.PlanModuleConfigurationE(t, module, configuration)
+				if err != nil {
+					t.Fatalf("Planning %s (%s): %v", module, configuration, err)
+				}
+				counts := helpers.PlannedResourceCounts(plan)
+				helpers.RecordReport(t, "resource_counts", module+"/"+configuration, counts)
+				helpers.AssertResourceBudget(t, module, configuration, counts)
+			})
+		}
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"strings"
 	"testing"
-	"time"
 
 	"github.com/gruntwork-io/terratest/modules/azure"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/armid"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/basevars"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,9 +20,10 @@ func TestResourceGroupBasic(t *testing.T) {
 
 	// Arrange
 	subscriptionID := azure.GetSubscriptionID(t)
-	uniqueID := strings.ToLower(random.UniqueId())
+	uniqueID := helpers.StageUniqueID(t)
 	resourceGroupName := fmt.Sprintf("rg-test-%s", uniqueID)
 	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.Resources/resourceGroups")
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../modules/resource-group/examples/complete",
@@ -36,17 +39,25 @@ func TestResourceGroupBasic(t *testing.T) {
 	}
 
 	// Act - Deploy
-	defer terraform.Destroy(t, terraformOptions)
-	terraform.InitAndApply(t, terraformOptions)
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, terraformOptions)
+	helpers.InitAndApply(t, terraformOptions)
+	helpers.StartValidation(t)
 
 	// Assert
+	helpers.AssertCostProfile(t, terraformOptions, "resource-group")
+	helpers.AssertIdempotent(t, terraformOptions)
+
 	// Verify resource group exists
 	exists := azure.ResourceGroupExists(t, resourceGroupName, subscriptionID)
 	assert.True(t, exists, "Resource group should exist")
 
 	// Verify outputs
-	resourceGroupID := terraform.Output(t, terraformOptions, "resource_group_id")
-	assert.NotEmpty(t, resourceGroupID, "Resource group ID should not be empty")
+	resourceGroupID, err := armid.ParseOfType(terraform.Output(t, terraformOptions, "resource_group_id"), armid.ResourceGroupType)
+	if assert.NoError(t, err, "Resource group ID should be a resource group ID") {
+		assert.True(t, resourceGroupID.Equal(armid.NewResourceGroupID(subscriptionID, resourceGroupName)),
+			"Resource group ID should name %s in subscription %s", resourceGroupName, subscriptionID)
+	}
 
 	outputName := terraform.Output(t, terraformOptions, "resource_group_name")
 	assert.Equal(t, resourceGroupName, outputName, "Output name should match input name")
@@ -90,15 +101,11 @@ func TestResourceGroupNamingConvention(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
+			uniqueID := strings.ToLower(random.UniqueId())
+
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/resource-group",
-				Vars: map[string]interface{}{
-					"name":     tc.inputName,
-					"location": "eastus2",
-					"tags": map[string]string{
-						"Test": "true",
-					},
-				},
+				Vars:         basevars.MinimalResourceGroupVars(uniqueID).With("name", tc.inputName),
 			}
 
 			if tc.shouldFail {
@@ -120,10 +127,10 @@ func TestResourceGroupLocationValidation(t *testing.T) {
 	t.Parallel()
 
 	testCases := []struct {
-		name         string
-		location     string
-		shouldFail   bool
-		description  string
+		name        string
+		location    string
+		shouldFail  bool
+		description string
 	}{
 		{
 			name:        "valid_location_eastus2",
@@ -157,17 +164,10 @@ func TestResourceGroupLocationValidation(t *testing.T) {
 			t.Parallel()
 
 			uniqueID := strings.ToLower(random.UniqueId())
-			resourceGroupName := fmt.Sprintf("rg-test-%s", uniqueID)
 
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../modules/resource-group",
-				Vars: map[string]interface{}{
-					"name":     resourceGroupName,
-					"location": tc.location,
-					"tags": map[string]string{
-						"Test": "true",
-					},
-				},
+				Vars:         basevars.MinimalResourceGroupVars(uniqueID).With("location", tc.location),
 			}
 
 			if tc.shouldFail {
@@ -186,9 +186,10 @@ func TestResourceGroupWithTags(t *testing.T) {
 	t.Parallel()
 
 	subscriptionID := azure.GetSubscriptionID(t)
-	uniqueID := strings.ToLower(random.UniqueId())
+	uniqueID := helpers.StageUniqueID(t)
 	resourceGroupName := fmt.Sprintf("rg-test-%s", uniqueID)
 	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.Resources/resourceGroups")
 
 	customTags := map[string]interface{}{
 		"Environment": "test",
@@ -207,8 +208,10 @@ func TestResourceGroupWithTags(t *testing.T) {
 		},
 	}
 
-	defer terraform.Destroy(t, terraformOptions)
-	terraform.InitAndApply(t, terraformOptions)
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, terraformOptions)
+	helpers.InitAndApply(t, terraformOptions)
+	helpers.StartValidation(t)
 
 	// Verify resource group exists and has correct tags
 	rg := azure.GetAResourceGroup(t, resourceGroupName, subscriptionID)
@@ -228,9 +231,11 @@ func TestResourceGroupWithTags(t *testing.T) {
 func TestResourceGroupOutputs(t *testing.T) {
 	t.Parallel()
 
-	uniqueID := strings.ToLower(random.UniqueId())
+	subscriptionID := azure.GetSubscriptionID(t)
+	uniqueID := helpers.StageUniqueID(t)
 	resourceGroupName := fmt.Sprintf("rg-test-%s", uniqueID)
 	location := "eastus2"
+	helpers.CaptureServiceHealthOnFailure(t, subscriptionID, location, "Microsoft.Resources/resourceGroups")
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../modules/resource-group/examples/complete",
@@ -243,8 +248,10 @@ func TestResourceGroupOutputs(t *testing.T) {
 		},
 	}
 
-	defer terraform.Destroy(t, terraformOptions)
-	terraform.InitAndApply(t, terraformOptions)
+	helpers.CheckGroupLeftoversAfterDestroy(t, subscriptionID, resourceGroupName)
+	defer helpers.Destroy(t, terraformOptions)
+	helpers.InitAndApply(t, terraformOptions)
+	helpers.StartValidation(t)
 
 	// Verify all outputs exist
 	outputs := terraform.OutputAll(t, terraformOptions)
@@ -261,7 +268,9 @@ func TestResourceGroupOutputs(t *testing.T) {
 	}
 
 	// Verify output format
-	resourceGroupID := outputs["resource_group_id"].(string)
-	assert.Contains(t, resourceGroupID, "/subscriptions/", "Resource group ID should be in correct format")
-	assert.Contains(t, resourceGroupID, "/resourceGroups/"+resourceGroupName, "Resource group ID should contain resource group name")
+	resourceGroupID, err := armid.ParseOfType(outputs["resource_group_id"].(string), armid.ResourceGroupType)
+	if assert.NoError(t, err, "Resource group ID should be in correct format") {
+		assert.Equal(t, subscriptionID, resourceGroupID.SubscriptionID, "Resource group ID should be in the test subscription")
+		assert.Equal(t, resourceGroupName, resourceGroupID.ResourceGroup, "Resource group ID should contain resource group name")
+	}
 }
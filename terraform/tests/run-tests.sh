@@ -60,6 +60,7 @@ MODULES:
     key-vault           Key vault module tests
     observability       Log Analytics + App Insights tests
     container-app       Container Apps module tests
+    security            Security baseline of public endpoints (TLS)
 
 EXAMPLES:
     # Run all tests
@@ -173,6 +174,28 @@ else
     exit 1
 fi
 
+# Static analysis of the test harness (gosec is optional locally, required in CI)
+log_info "Running go vet..."
+if go vet ./...; then
+    log_success "go vet passed"
+else
+    log_error "go vet reported problems"
+    exit 1
+fi
+
+if command -v gosec &> /dev/null; then
+    log_info "Running gosec..."
+    if gosec -quiet ./...; then
+        log_success "gosec passed"
+    else
+        log_error "gosec reported security issues"
+        exit 1
+    fi
+else
+    log_warning "gosec not installed, skipping security scan"
+    log_info "Install with: go install github.com/securego/gosec/v2/cmd/gosec@latest"
+fi
+
 # Check if running short tests
 if [[ "$TEST_MODE" == "short" ]]; then
     log_warning "Running in SHORT mode - no actual Azure resources will be created"
@@ -224,9 +247,12 @@ if [[ -n "$MODULE" ]]; then
         container-app)
             TEST_PATTERN="TestContainerApp"
             ;;
+        security)
+            TEST_PATTERN="TestSecurityBaseline"
+            ;;
         *)
             log_error "Unknown module: $MODULE"
-            log_info "Valid modules: resource-group, container-registry, key-vault, observability, container-app"
+            log_info "Valid modules: resource-group, container-registry, key-vault, observability, container-app, security"
             exit 1
             ;;
     esac
@@ -277,6 +303,16 @@ fi
 echo ""
 log_info "Test output saved to: $TEST_OUTPUT_FILE"
 
+if [[ -d logs/reports ]]; then
+    LATEST_REPORT_DIR=$(ls -td logs/reports/*/ 2>/dev/null | head -1)
+    if [[ -n "$LATEST_REPORT_DIR" ]]; then
+        log_info "Test reports saved to: $LATEST_REPORT_DIR"
+        if [[ -f "${LATEST_REPORT_DIR}timeline.html" ]]; then
+            log_info "Test timeline: ${LATEST_REPORT_DIR}timeline.html"
+        fi
+    fi
+fi
+
 # Show test statistics if available
 if command -v grep &> /dev/null; then
     PASSED=$(grep -c "PASS:" "$TEST_OUTPUT_FILE" 2>/dev/null || echo "0")
@@ -0,0 +1,75 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+)
+
+// TestSecurityBaseline deploys one of each public endpoint the modules
+// create and checks them against the security baseline. Results are recorded
+// in the tls report
+func TestSecurityBaseline(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/security-baseline", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("sec"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"tags":                helpers.StandardTags(t.Name()),
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	endpoints := map[string]string{
+		"container_app":      terraform.Output(t, terraformOptions, "container_app_url"),
+		"key_vault":          terraform.Output(t, terraformOptions, "key_vault_uri"),
+		"container_registry": terraform.Output(t, terraformOptions, "registry_login_server"),
+	}
+
+	t.Run("advisor", func(t *testing.T) {
+		helpers.CheckAdvisorRecommendations(t, terraform.Output(t, terraformOptions, "resource_group_name"))
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		for name, endpoint := range endpoints {
+			name, endpoint := name, endpoint
+			t.Run(name, func(t *testing.T) {
+				helpers.RequireEndpointReady(t, endpoint)
+
+				// TLS termination of new endpoints can lag their DNS and ports
+				retry.DoWithRetry(t, fmt.Sprintf("waiting for %s to accept TLS", endpoint), 20, 15*time.Second, func() (string, error) {
+					result, err := helpers.CheckTLSE(endpoint)
+					if err != nil {
+						return "", err
+					}
+					if !result.Versions["TLS 1.2"] {
+						return "", fmt.Errorf("%s does not accept TLS 1.2 yet", result.Address)
+					}
+					return "", nil
+				})
+
+				result := helpers.AssertTLSBaseline(t, endpoint)
+				helpers.RecordReport(t, "tls", name, result)
+			})
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestModuleStack composes the resource group, observability and key-vault
+// modules as separate roots with helpers.NewStack, wiring the workspace of
+// one into the diagnostics of the other. It checks the outputs of each
+// module are enough to compose them, which the environments rely on
+func TestModuleStack(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	resourceGroupName := config.GenerateResourceGroupName("stack")
+	tags := helpers.StandardTags(t.Name())
+	stack, err := helpers.NewStack(
+		helpers.StackModule{
+			Name:   "rg",
+			Module: "resource-group",
+			Vars: map[string]interface{}{
+				"name":     resourceGroupName,
+				"location": config.Location,
+				"tags":     tags,
+			},
+		},
+		helpers.StackModule{
+			Name:   "observability",
+			Module: "observability",
+			Vars: map[string]interface{}{
+				"log_analytics_name": "log-stack-" + config.UniqueID,
+				"app_insights_name":  "appi-stack-" + config.UniqueID,
+				"tags":               tags,
+			},
+			Inputs: map[string]helpers.StackOutputRef{
+				"resource_group_name": helpers.StackOutput("rg", "name"),
+				"location":            helpers.StackOutput("rg", "location"),
+			},
+		},
+		helpers.StackModule{
+			Name:   "key_vault",
+			Module: "key-vault",
+			Vars: map[string]interface{}{
+				"name":                       "kv-stack-" + config.UniqueID,
+				"soft_delete_retention_days": 7,
+				"purge_protection_enabled":   false,
+				"enable_diagnostics":         true,
+				"tags":                       tags,
+			},
+			Inputs: map[string]helpers.StackOutputRef{
+				"resource_group_name":        helpers.StackOutput("rg", "name"),
+				"location":                   helpers.StackOutput("rg", "location"),
+				"log_analytics_workspace_id": helpers.StackOutput("observability", "log_analytics_workspace_id"),
+			},
+			Verify: func(t *testing.T, stack *helpers.Stack) {
+				var settings []struct {
+					WorkspaceID string `json:"workspaceId"`
+				}
+				helpers.AzCLIJSON(t, &settings, "monitor", "diagnostic-settings", "list",
+					"--resource", stack.OutputString("key_vault", "id"))
+				workspaceID := stack.OutputString("observability", "log_analytics_workspace_id")
+				found := false
+				for _, setting := range settings {
+					found = found || strings.EqualFold(setting.WorkspaceID, workspaceID)
+				}
+				assert.True(t, found, "the vault should send diagnostics to the stack's workspace")
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cleanups run last first, so this runs after the stack's teardown
+	helpers.CheckGroupLeftoversAfterDestroy(t, config.SubscriptionID, resourceGroupName)
+	stack.Deploy(t)
+}
@@ -0,0 +1,181 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	suiteMetricsFixture    = "./fixtures/suite-metrics"
+	suiteHealthWorkbook    = "./workbooks/suite-health.json"
+	workbookKQLItemType    = 3
+	workbookKQLItemVersion = "KqlItem/1.0"
+)
+
+// workbookDefinition is the part of a workbook's serialized data the tests
+// read: its items, of which the KQL ones hold a query
+type workbookDefinition struct {
+	Version string `json:"version"`
+	Items   []struct {
+		Type    int    `json:"type"`
+		Name    string `json:"name"`
+		Content struct {
+			Version string `json:"version"`
+			Query   string `json:"query"`
+		} `json:"content"`
+	} `json:"items"`
+}
+
+// suiteHealthQueries returns the KQL queries of the suite health workbook by
+// item name
+func suiteHealthQueries(t *testing.T) map[string]string {
+	content, err := os.ReadFile(suiteHealthWorkbook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var workbook workbookDefinition
+	if err := json.Unmarshal(content, &workbook); err != nil {
+		t.Fatalf("Parsing %s: %v", suiteHealthWorkbook, err)
+	}
+	assert.Equal(t, "Notebook/1.0", workbook.Version, "workbook format")
+
+	queries := map[string]string{}
+	for _, item := range workbook.Items {
+		if item.Type == workbookKQLItemType && item.Content.Version == workbookKQLItemVersion {
+			queries[item.Name] = item.Content.Query
+		}
+	}
+	return queries
+}
+
+// TestSuiteMetricsDefinitions checks the exporter, the fixture's table and
+// stream, and the workbook agree on the table's name and columns, without
+// deploying anything
+func TestSuiteMetricsDefinitions(t *testing.T) {
+	t.Parallel()
+
+	content, err := os.ReadFile(suiteMetricsFixture + "/columns.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var columns []helpers.SuiteMetricsColumn
+	if assert.NoError(t, json.Unmarshal(content, &columns)) {
+		assert.Equal(t, helpers.SuiteMetricsColumns, columns, "columns.json should declare the columns the exporter writes")
+	}
+
+	mainTF, err := os.ReadFile(suiteMetricsFixture + "/main.tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(mainTF), fmt.Sprintf("%q", helpers.SuiteMetricsTable), "the fixture should create the exporter's table")
+	assert.Contains(t, string(mainTF), fmt.Sprintf("%q", helpers.SuiteMetricsStream), "the fixture should declare the exporter's stream")
+
+	queries := suiteHealthQueries(t)
+	assert.NotEmpty(t, queries, "the workbook should have KQL queries")
+	for name, query := range queries {
+		assert.True(t, strings.HasPrefix(query, helpers.SuiteMetricsTable+"\n"),
+			"query %s should start from %s, so the verify step can scope it to one run", name, helpers.SuiteMetricsTable)
+	}
+}
+
+// TestSuiteMetricsExport applies the suite metrics fixture, exports a
+// synthetic run through the Logs Ingestion API as the runner and waits for
+// its rows in the custom table. Every query of the suite health workbook
+// must then run against the table and see the run. Opt in with
+// TEST_SUITE_METRICS=true: a new table and rule can take several minutes to
+// accept and show data
+func TestSuiteMetricsExport(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_SUITE_METRICS") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_SUITE_METRICS=true to export test results to a Log Analytics custom table")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	vars := func() map[string]interface{} {
+		return map[string]interface{}{
+			"resource_group_name": config.GenerateResourceGroupName("metrics"),
+			"location":            config.Location,
+			"name_suffix":         config.UniqueID,
+			"tags":                helpers.StandardTags(t.Name()),
+		}
+	}
+	terraformOptions := helpers.DefaultTerraformOptions(t, suiteMetricsFixture, vars())
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+
+	phases.Start("apply")
+	helpers.DeployWithRegionFallback(t, config, terraformOptions, vars, func() error {
+		_, err := terraform.InitAndApplyE(t, terraformOptions)
+		return err
+	})
+	phases.Start("verify")
+
+	workspaceID := terraform.Output(t, terraformOptions, "log_analytics_workspace_customer_id")
+	runID := fmt.Sprintf("synthetic-%s-%s", helpers.RunID(), config.UniqueID)
+	summary := &helpers.RunSummary{RunID: runID, Tests: map[string]helpers.TestOutcome{
+		"TestSynthetic":         {Status: "pass", DurationSeconds: 42},
+		"TestSynthetic/subtest": {Status: "pass", DurationSeconds: 12.5},
+		"TestSyntheticFailure":  {Status: "fail", DurationSeconds: 300},
+		"TestSyntheticExpected": {Status: helpers.StatusExpectedFailure, DurationSeconds: 60, Issue: "#1"},
+	}}
+	records := helpers.SuiteMetricRecords(summary, helpers.Namespace(), time.Now())
+	client := &helpers.LogsIngestionClient{
+		Endpoint: terraform.Output(t, terraformOptions, "logs_ingestion_endpoint"),
+		RuleID:   terraform.Output(t, terraformOptions, "data_collection_rule_immutable_id"),
+		Token: func() (string, error) {
+			var token string
+			err := helpers.AzCLIJSONE(t, &token, "account", "get-access-token", "--resource", helpers.CurrentCloud(t).MonitorResource,
+				"--query", "accessToken")
+			return token, err
+		},
+	}
+
+	// The publisher role and a new rule take a while to accept uploads
+	retry.DoWithRetry(t, "exporting the synthetic run", 30, 30*time.Second, func() (string, error) {
+		_, err := client.UploadE(records)
+		return "", err
+	})
+
+	query := fmt.Sprintf("%s\n| where RunId == %q\n| summarize Rows = count()", helpers.SuiteMetricsTable, runID)
+	_, err := retry.DoWithRetryE(t, "waiting for the synthetic run in "+helpers.SuiteMetricsTable, 60, 15*time.Second, func() (string, error) {
+		rows, err := helpers.QueryLogAnalyticsE(t, workspaceID, query)
+		if err != nil {
+			return "", err
+		}
+		if len(rows) == 0 || fmt.Sprint(rows[0]["Rows"]) != fmt.Sprint(len(records)) {
+			return "", fmt.Errorf("run %s not fully ingested yet: %v", runID, rows)
+		}
+		return "", nil
+	})
+	if err != nil {
+		t.Fatalf("The synthetic run was not ingested: %v", err)
+	}
+
+	for name, workbookQuery := range suiteHealthQueries(t) {
+		scoped := strings.Replace(workbookQuery, helpers.SuiteMetricsTable+"\n",
+			fmt.Sprintf("%s\n| where RunId == %q\n", helpers.SuiteMetricsTable, runID), 1)
+		rows, err := helpers.QueryLogAnalyticsE(t, workspaceID, scoped)
+		if assert.NoError(t, err, "workbook query %s should run against the table", name) && name == "runs" {
+			assert.Len(t, rows, 1, "the runs query should list the synthetic run")
+		}
+	}
+}
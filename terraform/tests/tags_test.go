@@ -0,0 +1,60 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/cost"
+)
+
+// TestModuleTagUpdate applies every module with one set of tags, then plans
+// a change to var.tags alone. The plan must only update tags in place; any
+// replacement or other attribute change means a module derives something
+// from its tags
+func TestModuleTagUpdate(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+
+	config := helpers.NewTestConfig(t)
+	cost.TrackRun(t, config)
+	tags := helpers.StandardTags(t.Name())
+	terraformOptions := helpers.DefaultTerraformOptions(t, "./fixtures/tag-update", map[string]interface{}{
+		"resource_group_name": config.GenerateResourceGroupName("tag"),
+		"location":            config.Location,
+		"name_suffix":         config.UniqueID,
+		"tags":                tags,
+	})
+	helpers.UseIsolatedWorkspace(t, terraformOptions)
+
+	phases := helpers.TrackPhases(t)
+	helpers.CheckLeftoversAfterDestroy(t, config, terraformOptions)
+	defer helpers.Destroy(t, terraformOptions)
+	defer phases.Start("destroy")
+	phases.Start("apply")
+	helpers.InitAndApply(t, terraformOptions)
+	phases.Start("verify")
+
+	// Change one tag value and add another; everything else stays the same
+	updatedTags := map[string]interface{}{"CostCenter": "platform-tests"}
+	for key, value := range tags {
+		updatedTags[key] = value
+	}
+	updatedTags["Environment"] = "test-retagged"
+
+	updatedVars := map[string]interface{}{}
+	for key, value := range terraformOptions.Vars {
+		updatedVars[key] = value
+	}
+	updatedVars["tags"] = updatedTags
+
+	updatedOptions := *terraformOptions
+	updatedOptions.Vars = updatedVars
+	updatedOptions.PlanFilePath = filepath.Join(terraformOptions.TerraformDir, "tags.tfplan")
+
+	planJSON := helpers.InitAndPlanAndShow(t, &updatedOptions)
+	helpers.AssertTagChangesOnly(t, planJSON)
+}
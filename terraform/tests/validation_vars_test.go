@@ -0,0 +1,22 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers/basevars"
+)
+
+// TestValidationVarsUseBaseBuilders fails when a test planning a module
+// directly spells out the module's base variables instead of starting from
+// its basevars builder, so validation cases only state what they vary
+func TestValidationVarsUseBaseBuilders(t *testing.T) {
+	t.Parallel()
+
+	redeclarations, err := basevars.FindRedeclarationsE(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, position := range redeclarations {
+		t.Errorf("%s declares the module's base variables inline; start from its basevars.Minimal...Vars builder and add the case's delta with With", position)
+	}
+}
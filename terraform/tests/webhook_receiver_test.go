@@ -0,0 +1,70 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pollinate/risk-scoring-api/terraform/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWebhookReceiver checks the run's shared webhook receiver records what
+// senders deliver, both a plain JSON POST and a real ACR webhook ping, so the
+// notification tests built on it fail for their own reasons only
+func TestWebhookReceiver(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		helpers.SkipWithReason(t, helpers.SkipShortMode, "Skipping slow test in short mode")
+	}
+	if os.Getenv("TEST_WEBHOOKS") != "true" {
+		helpers.SkipWithReason(t, helpers.SkipMissingEnv, "Set TEST_WEBHOOKS=true to deploy the shared webhook receiver")
+	}
+
+	receiver := helpers.SharedWebhookReceiver(t)
+	source := helpers.WorkspaceName(helpers.RunID(), t.Name())
+
+	t.Run("post", func(t *testing.T) {
+		marker := fmt.Sprintf("marker-%d", time.Now().UnixNano())
+		if err := helpers.PostWebhookE(receiver.HookURL(source+"-post"), map[string]string{"marker": marker}); err != nil {
+			t.Fatalf("Posting to the receiver: %v", err)
+		}
+
+		payload, err := helpers.WaitForWebhookE(t, receiver, source+"-post", func(p helpers.WebhookPayload) bool {
+			return strings.Contains(p.Body, marker)
+		}, 5*time.Minute)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "application/json", payload.ContentType)
+			assert.NotContains(t, payload.Headers, "Authorization", "sender credentials must not be recorded")
+		}
+	})
+
+	t.Run("acr_ping", func(t *testing.T) {
+		// ACR webhook names are alphanumeric
+		name := "hook" + strings.ReplaceAll(helpers.WorkspaceName("", helpers.RunID()), "-", "")
+		if len(name) > 50 {
+			name = name[:50]
+		}
+		helpers.AzCLI(t, "acr", "webhook", "create", "--registry", receiver.RegistryName, "--name", name,
+			"--uri", receiver.HookURL(source+"-acr"), "--actions", "push")
+		t.Cleanup(func() {
+			if _, err := helpers.AzCLIE(t, "acr", "webhook", "delete", "--registry", receiver.RegistryName, "--name", name); err != nil {
+				t.Logf("Could not delete webhook %s: %v", name, err)
+			}
+		})
+		helpers.AzCLI(t, "acr", "webhook", "ping", "--registry", receiver.RegistryName, "--name", name)
+
+		payload, err := helpers.WaitForWebhookE(t, receiver, source+"-acr", func(p helpers.WebhookPayload) bool {
+			var event struct {
+				Action string `json:"action"`
+			}
+			return p.DecodeJSON(&event) == nil && event.Action == "ping"
+		}, 5*time.Minute)
+		if assert.NoError(t, err, "the ACR ping never reached the receiver") {
+			assert.False(t, payload.Truncated)
+		}
+	})
+}